@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/billing"
+	billingImpl "crm_lite/internal/domains/billing/impl"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	backfillBatchSize int
+	backfillJSON      bool
+)
+
+func init() {
+	backfillCmd.Flags().IntVar(&backfillBatchSize, "batch-size", 500, "每批处理的钱包数")
+	backfillCmd.Flags().BoolVar(&backfillJSON, "json", false, "以 JSON 输出回填报告")
+	rootCmd.AddCommand(backfillCmd)
+}
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill-truth-wallets",
+	Short: "Copy legacy wallets and wallet transactions into the truth tables",
+	Long: `把 wallets / wallet_transactions 中的钱包与流水复制到 bil_wallets / bil_wallet_transactions，钱包ID与流水ID沿用旧表。
+已复制的流水不会重复写入，可反复执行；建议先开启 wallet.storeMode=dual_write 再回填，回填后用 reconcile-wallets 核对两边余额。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := config.GetInstance()
+		logger.InitGlobalLogger(&opts.Logger)
+
+		ctx := context.Background()
+		dbRes := resource.NewDBResource(opts.Database)
+		if err := dbRes.Initialize(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = dbRes.Close(ctx) }()
+
+		report, err := billingImpl.NewTruthBackfiller(dbRes.DB).Backfill(ctx, billing.BackfillOptions{
+			BatchSize: backfillBatchSize,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Backfill failed: %v\n", err)
+			_ = dbRes.Close(ctx)
+			os.Exit(1)
+		}
+
+		if backfillJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			_ = enc.Encode(report)
+			return
+		}
+		fmt.Printf("wallets_scanned=%d wallets_created=%d transactions_copied=%d elapsed=%ds\n",
+			report.WalletsScanned, report.WalletsCreated, report.TransactionsCopied, report.FinishedAt-report.StartedAt)
+	},
+}
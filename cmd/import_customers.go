@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	crmImpl "crm_lite/internal/domains/crm/impl"
+	"crm_lite/internal/validators"
+	"crm_lite/pkg/validator"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importCustomersFile       string
+	importCustomersFormat     string
+	importCustomersDryRun     bool
+	importCustomersAssignedTo int64
+	importCustomersOperatorID int64
+	importCustomersJSON       bool
+)
+
+func init() {
+	importCustomersCmd.Flags().StringVar(&importCustomersFile, "file", "", "导入文件路径（.csv/.xlsx）")
+	importCustomersCmd.Flags().StringVar(&importCustomersFormat, "format", "",
+		fmt.Sprintf("文件格式：%s, %s（默认按扩展名识别）", crm.ImportFormatCSV, crm.ImportFormatXLSX))
+	importCustomersCmd.Flags().BoolVar(&importCustomersDryRun, "dry-run", false, "只校验并输出报告，不写入数据")
+	importCustomersCmd.Flags().Int64Var(&importCustomersAssignedTo, "assigned-to", 0, "未填写负责人的客户分配给该员工ID")
+	importCustomersCmd.Flags().Int64Var(&importCustomersOperatorID, "operator-id", 0, "操作人ID")
+	importCustomersCmd.Flags().BoolVar(&importCustomersJSON, "json", false, "以 JSON 输出导入报告")
+	_ = importCustomersCmd.MarkFlagRequired("file")
+	rootCmd.AddCommand(importCustomersCmd)
+}
+
+var importCustomersCmd = &cobra.Command{
+	Use:   "import-customers",
+	Short: "Import customers from a CSV or XLSX file",
+	Long:  `读取 CSV/XLSX 文件按手机号新建或更新客户，可选入账期初余额，并输出逐行校验报告。存在失败行时以退出码 2 结束。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		format := importCustomersFormat
+		if format == "" {
+			format = crm.ImportFormatFromFilename(importCustomersFile)
+		}
+		if format != crm.ImportFormatCSV && format != crm.ImportFormatXLSX {
+			fmt.Fprintf(os.Stderr, "Unsupported import format: %q\n", format)
+			os.Exit(1)
+		}
+
+		opts := config.GetInstance()
+		logger.InitGlobalLogger(&opts.Logger)
+		validator.RegisterMobileValidator()
+		validators.RegisterCustomValidators()
+
+		file, err := os.Open(importCustomersFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open import file: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = file.Close() }()
+
+		ctx := context.Background()
+		dbRes := resource.NewDBResource(opts.Database)
+		if err := dbRes.Initialize(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = dbRes.Close(ctx) }()
+
+		report, err := crmImpl.NewCustomerImportServiceFromDB(dbRes.DB).ImportCustomers(ctx, file, crm.CustomerImportOptions{
+			Format:            format,
+			DryRun:            importCustomersDryRun,
+			OperatorID:        importCustomersOperatorID,
+			DefaultAssignedTo: importCustomersAssignedTo,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Import customers failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		printCustomerImportReport(report)
+		if report.Failed > 0 {
+			_ = file.Close()
+			_ = dbRes.Close(ctx)
+			os.Exit(2)
+		}
+	},
+}
+
+// printCustomerImportReport 输出导入报告，文本格式只列出失败行
+func printCustomerImportReport(report *crm.CustomerImportReport) {
+	if importCustomersJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
+	}
+
+	fmt.Printf("dry_run=%v total=%d created=%d updated=%d failed=%d opening_balance=%.2f\n",
+		report.DryRun, report.Total, report.Created, report.Updated, report.Failed, float64(report.OpeningBalance)/100)
+	if len(report.IgnoredColumns) > 0 {
+		fmt.Printf("ignored_columns=%s\n", strings.Join(report.IgnoredColumns, ","))
+	}
+	for _, row := range report.Rows {
+		if row.Action == crm.ImportActionFailed {
+			fmt.Printf("row=%d\tphone=%s\terrors=%s\n", row.Row, row.Phone, strings.Join(row.Errors, "; "))
+		}
+	}
+}
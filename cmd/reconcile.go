@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/billing"
+	billingImpl "crm_lite/internal/domains/billing/impl"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	reconcileRepair  bool
+	reconcileSources []string
+	reconcileJSON    bool
+)
+
+func init() {
+	reconcileCmd.Flags().BoolVar(&reconcileRepair, "repair", false, "按流水汇总值修复存在偏差的钱包余额")
+	reconcileCmd.Flags().StringSliceVar(&reconcileSources, "source", nil,
+		fmt.Sprintf("对账数据源，可多次指定：%s, %s（默认全部）", billing.ReconcileSourceLegacy, billing.ReconcileSourceTruth))
+	reconcileCmd.Flags().BoolVar(&reconcileJSON, "json", false, "以 JSON 输出对账报告")
+	rootCmd.AddCommand(reconcileCmd)
+}
+
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile-wallets",
+	Short: "Reconcile wallet balances against wallet transactions",
+	Long:  `按流水重新汇总 wallets 与 bil_wallets 的余额并报告偏差；指定 --repair 时修复偏差。存在未修复的偏差时以退出码 2 结束。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := config.GetInstance()
+		logger.InitGlobalLogger(&opts.Logger)
+
+		ctx := context.Background()
+		dbRes := resource.NewDBResource(opts.Database)
+		if err := dbRes.Initialize(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = dbRes.Close(ctx) }()
+
+		report, err := billingImpl.NewBalanceReconciler(dbRes.DB).Reconcile(ctx, billing.ReconcileOptions{
+			Sources: reconcileSources,
+			Repair:  reconcileRepair,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Reconcile failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		printReconcileReport(report)
+		if len(report.Drifts) > report.Repaired {
+			_ = dbRes.Close(ctx)
+			os.Exit(2)
+		}
+	},
+}
+
+// printReconcileReport 输出对账报告
+func printReconcileReport(report *billing.ReconcileReport) {
+	if reconcileJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
+	}
+
+	fmt.Printf("sources=%v wallets_checked=%d drifts=%d repaired=%d\n",
+		report.Sources, report.WalletsChecked, len(report.Drifts), report.Repaired)
+	for _, d := range report.Drifts {
+		fmt.Printf("%s\twallet=%d\tcustomer=%d\tstored=%d\tcomputed=%d\tdiff=%d\tlast_tx=%d@%d\trepaired=%t\n",
+			d.Source, d.WalletID, d.CustomerID, d.StoredBalance, d.ComputedBalance,
+			d.StoredBalance-d.ComputedBalance, d.LastTxID, d.LastTxAt, d.Repaired)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/billing"
+	billingImpl "crm_lite/internal/domains/billing/impl"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyLedgerSources []string
+	verifyLedgerJSON    bool
+)
+
+func init() {
+	verifyLedgerCmd.Flags().StringSliceVar(&verifyLedgerSources, "source", nil,
+		fmt.Sprintf("校验数据源，可多次指定：%s, %s（默认全部）", billing.ReconcileSourceLegacy, billing.ReconcileSourceTruth))
+	verifyLedgerCmd.Flags().BoolVar(&verifyLedgerJSON, "json", false, "以 JSON 输出校验报告")
+	rootCmd.AddCommand(verifyLedgerCmd)
+}
+
+var verifyLedgerCmd = &cobra.Command{
+	Use:   "verify-wallet-ledger",
+	Short: "Verify the hash chain of wallet transactions",
+	Long:  `逐个钱包沿流水哈希链重算 wallet_transactions 与 bil_wallet_transactions 的哈希，报告每个钱包的第一个断点。存在断点时以退出码 2 结束。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := config.GetInstance()
+		logger.InitGlobalLogger(&opts.Logger)
+
+		ctx := context.Background()
+		dbRes := resource.NewDBResource(opts.Database)
+		if err := dbRes.Initialize(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to connect database: %v\n", err)
+			os.Exit(1)
+		}
+		defer func() { _ = dbRes.Close(ctx) }()
+
+		report, err := billingImpl.NewLedgerVerifier(dbRes.DB).VerifyLedger(ctx, billing.LedgerVerifyOptions{
+			Sources: verifyLedgerSources,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Verify ledger failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		printLedgerVerifyReport(report)
+		if len(report.Breaks) > 0 {
+			_ = dbRes.Close(ctx)
+			os.Exit(2)
+		}
+	},
+}
+
+// printLedgerVerifyReport 输出哈希链校验报告
+func printLedgerVerifyReport(report *billing.LedgerVerifyReport) {
+	if verifyLedgerJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+		return
+	}
+
+	fmt.Printf("sources=%v chain_start_tx_id=%d wallets_checked=%d transactions_checked=%d unsealed=%d breaks=%d\n",
+		report.Sources, report.ChainStartTxID, report.WalletsChecked, report.TransactionsChecked, report.Unsealed, len(report.Breaks))
+	for _, b := range report.Breaks {
+		fmt.Printf("%s\twallet=%d\tcustomer=%d\ttx=%d\treason=%s\texpected=%s\tstored=%s\n",
+			b.Source, b.WalletID, b.CustomerID, b.TxID, b.Reason, b.ExpectedHash, b.StoredHash)
+	}
+}
@@ -0,0 +1,16 @@
+-- +migrate Up
+-- 创建订单状态流转日志表
+-- 记录每次状态流转的原状态、目标状态、操作人及时间，便于审计与追溯
+CREATE TABLE IF NOT EXISTS order_status_logs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  from_status VARCHAR(20) NOT NULL DEFAULT '' COMMENT '原状态，下单时为空',
+  to_status VARCHAR(20) NOT NULL COMMENT '目标状态',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID，0表示系统',
+  reason VARCHAR(255) NULL COMMENT '流转原因',
+  created_at BIGINT NOT NULL COMMENT '流转时间（Unix时间戳）',
+  INDEX idx_order_time (order_id, created_at)
+) ENGINE=InnoDB COMMENT='订单状态流转日志表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS order_status_logs;
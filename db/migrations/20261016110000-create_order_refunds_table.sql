@@ -0,0 +1,38 @@
+-- +migrate Up
+-- 创建退款单表与退款明细表
+-- 支持按订单项及数量部分退款，退款单关联原订单及钱包退款流水
+CREATE TABLE IF NOT EXISTS order_refunds (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  amount BIGINT NOT NULL COMMENT '退款金额（分）',
+  wallet_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '钱包退款流水ID（wallet_transactions.id），0表示无资金退回',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键',
+  reason VARCHAR(255) NULL COMMENT '退款原因',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID，0表示系统',
+  created_at BIGINT NOT NULL COMMENT '退款时间（Unix时间戳）',
+  UNIQUE KEY uk_refund_idem (idempotency_key),
+  INDEX idx_refund_order (order_id, created_at)
+) ENGINE=InnoDB COMMENT='订单退款单表';
+
+CREATE TABLE IF NOT EXISTS order_refund_items (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  refund_id BIGINT NOT NULL COMMENT '退款单ID',
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  order_item_id BIGINT NOT NULL COMMENT '订单项ID',
+  product_id BIGINT NOT NULL COMMENT '产品ID',
+  quantity INT NOT NULL COMMENT '退款数量',
+  amount BIGINT NOT NULL COMMENT '退款金额（分）',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  INDEX idx_refund_item_refund (refund_id),
+  INDEX idx_refund_item_order (order_id, order_item_id)
+) ENGINE=InnoDB COMMENT='订单退款明细表';
+
+-- 支付状态新增 partially_refunded
+ALTER TABLE orders
+  MODIFY COLUMN payment_status VARCHAR(20) NOT NULL DEFAULT 'unpaid' COMMENT '支付状态: unpaid, pending, partially_paid, paid, partially_refunded, refunded';
+
+-- +migrate Down
+ALTER TABLE orders
+  MODIFY COLUMN payment_status VARCHAR(20) NOT NULL DEFAULT 'unpaid' COMMENT '支付状态: unpaid, paid, partially_paid, refunded, pending';
+DROP TABLE IF EXISTS order_refund_items;
+DROP TABLE IF EXISTS order_refunds;
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- 创建 HTTP 幂等键表
+-- Redis 不可用或配置 idempotency.driver=db 时，由幂等中间件存储请求指纹与原始响应
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  idem_key VARCHAR(191) NOT NULL COMMENT '幂等键（按操作人隔离）',
+  fingerprint CHAR(64) NOT NULL COMMENT '请求指纹（method + path + body 的 SHA-256）',
+  status_code INT NOT NULL DEFAULT 0 COMMENT '原始响应HTTP状态码，0表示处理中',
+  content_type VARCHAR(100) NOT NULL DEFAULT '' COMMENT '原始响应Content-Type',
+  response_body MEDIUMTEXT NULL COMMENT '原始响应体（序列化的 resp.Response）',
+  expires_at BIGINT NOT NULL COMMENT '过期时间（Unix时间戳）',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  UNIQUE KEY uk_idem_key (idem_key),
+  INDEX idx_idem_expires (expires_at)
+) ENGINE=InnoDB COMMENT='HTTP幂等键表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS idempotency_keys;
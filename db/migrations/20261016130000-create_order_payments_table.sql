@@ -0,0 +1,24 @@
+-- +migrate Up
+-- 创建订单支付明细表
+-- 支持组合支付（钱包 + 现金 / 线上 / 刷卡），每行记录一种支付方式及金额，钱包支付行关联扣款流水
+CREATE TABLE IF NOT EXISTS order_payments (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  method VARCHAR(20) NOT NULL COMMENT '支付方式: wallet, cash, online, card',
+  amount BIGINT NOT NULL COMMENT '支付金额（分）',
+  wallet_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '钱包扣款流水ID（wallet_transactions.id），非钱包支付为0',
+  idempotency_key VARCHAR(128) NOT NULL COMMENT '幂等键',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID，0表示系统',
+  created_at BIGINT NOT NULL COMMENT '支付时间（Unix时间戳）',
+  UNIQUE KEY uk_payment_idem (idempotency_key),
+  INDEX idx_payment_order (order_id, created_at)
+) ENGINE=InnoDB COMMENT='订单支付明细表';
+
+-- 订单级支付方式新增 mixed（组合支付）
+ALTER TABLE orders
+  MODIFY COLUMN payment_method VARCHAR(20) COMMENT '支付方式: wallet, cash, online, card, mixed';
+
+-- +migrate Down
+ALTER TABLE orders
+  MODIFY COLUMN payment_method VARCHAR(20) COMMENT '支付方式: online, offline_transfer, cash_on_delivery, wallet_balance';
+DROP TABLE IF EXISTS order_payments;
@@ -0,0 +1,57 @@
+-- +migrate Up
+-- 创建优惠券定义、券码与核销记录表
+-- 券码一次性使用，核销记录关联订单及发放活动，订单取消或全额退款时撤销核销并退回券码
+CREATE TABLE IF NOT EXISTS coupons (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  campaign_id BIGINT NOT NULL DEFAULT 0 COMMENT '发放活动ID（marketing_campaigns.id），0表示非活动发放',
+  name VARCHAR(100) NOT NULL COMMENT '优惠券名称',
+  type VARCHAR(20) NOT NULL COMMENT '类型: fixed, percent',
+  value BIGINT NOT NULL COMMENT '优惠值：fixed 为金额（分），percent 为折扣百分比',
+  min_spend BIGINT NOT NULL DEFAULT 0 COMMENT '最低消费（分），按适用商品金额计算',
+  max_discount BIGINT NOT NULL DEFAULT 0 COMMENT '最高优惠（分），0表示不限',
+  product_ids TEXT NULL COMMENT '适用产品ID（JSON数组），为空表示不限',
+  categories TEXT NULL COMMENT '适用产品分类（JSON数组），为空表示不限',
+  valid_from BIGINT NOT NULL COMMENT '生效时间（Unix时间戳）',
+  valid_to BIGINT NOT NULL COMMENT '失效时间（Unix时间戳）',
+  per_customer_limit INT NOT NULL DEFAULT 0 COMMENT '每位客户可使用次数，0表示不限',
+  status VARCHAR(20) NOT NULL DEFAULT 'active' COMMENT '状态: active, disabled',
+  issued_count BIGINT NOT NULL DEFAULT 0 COMMENT '已生成券码数',
+  redeemed_count BIGINT NOT NULL DEFAULT 0 COMMENT '已核销券码数',
+  created_by BIGINT NOT NULL DEFAULT 0 COMMENT '创建人ID',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  INDEX idx_coupon_campaign (campaign_id)
+) ENGINE=InnoDB COMMENT='优惠券定义表';
+
+CREATE TABLE IF NOT EXISTS coupon_codes (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  coupon_id BIGINT NOT NULL COMMENT '优惠券ID',
+  code VARCHAR(32) NOT NULL COMMENT '券码',
+  status VARCHAR(20) NOT NULL DEFAULT 'unused' COMMENT '状态: unused, redeemed',
+  redeemed_by BIGINT NOT NULL DEFAULT 0 COMMENT '核销客户ID',
+  redeemed_at BIGINT NOT NULL DEFAULT 0 COMMENT '核销时间（Unix时间戳）',
+  created_at BIGINT NOT NULL COMMENT '生成时间（Unix时间戳）',
+  UNIQUE KEY uk_coupon_code (code),
+  INDEX idx_coupon_code_coupon (coupon_id)
+) ENGINE=InnoDB COMMENT='优惠券券码表';
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  coupon_id BIGINT NOT NULL COMMENT '优惠券ID',
+  code_id BIGINT NOT NULL COMMENT '券码ID',
+  code VARCHAR(32) NOT NULL COMMENT '券码',
+  campaign_id BIGINT NOT NULL DEFAULT 0 COMMENT '发放活动ID，用于订单来源归因',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  discount BIGINT NOT NULL COMMENT '优惠金额（分）',
+  status VARCHAR(20) NOT NULL COMMENT '状态: redeemed, reversed',
+  created_at BIGINT NOT NULL COMMENT '核销时间（Unix时间戳）',
+  reversed_at BIGINT NOT NULL DEFAULT 0 COMMENT '撤销时间（Unix时间戳）',
+  INDEX idx_redemption_customer (coupon_id, customer_id),
+  INDEX idx_redemption_order (order_id)
+) ENGINE=InnoDB COMMENT='优惠券核销记录表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS coupon_redemptions;
+DROP TABLE IF EXISTS coupon_codes;
+DROP TABLE IF EXISTS coupons;
@@ -0,0 +1,8 @@
+-- +migrate Up
+-- 为 products 表添加服务时长，预约排班按该时长计算可预约时段，下单时快照到 order_items.duration_min_snapshot
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS duration_min INT NOT NULL DEFAULT 0 COMMENT '服务时长（分钟），service 类型产品用于预约排班，0表示不可预约';
+
+-- +migrate Down
+ALTER TABLE products
+  DROP COLUMN IF EXISTS duration_min;
@@ -0,0 +1,47 @@
+-- +migrate Up
+-- 创建员工排班与预约表
+-- 员工每周可预约时间 + 休息时段构成排班日历；预约时长取自产品服务时长快照，完成后关联生成的订单
+CREATE TABLE IF NOT EXISTS staff_availabilities (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  staff_id BIGINT NOT NULL COMMENT '员工ID（admin_users.id）',
+  weekday TINYINT NOT NULL COMMENT '星期：0=周日 ... 6=周六',
+  start_minute INT NOT NULL COMMENT '开始时间（当天第几分钟）',
+  end_minute INT NOT NULL COMMENT '结束时间（当天第几分钟）',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  INDEX idx_availability_staff_weekday (staff_id, weekday)
+) ENGINE=InnoDB COMMENT='员工每周可预约时间表';
+
+CREATE TABLE IF NOT EXISTS staff_time_offs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  staff_id BIGINT NOT NULL COMMENT '员工ID（admin_users.id）',
+  start_at BIGINT NOT NULL COMMENT '开始时间（Unix时间戳）',
+  end_at BIGINT NOT NULL COMMENT '结束时间（Unix时间戳）',
+  reason VARCHAR(255) NULL COMMENT '原因',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  INDEX idx_time_off_staff_start (staff_id, start_at)
+) ENGINE=InnoDB COMMENT='员工休息时段表';
+
+CREATE TABLE IF NOT EXISTS appointments (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  staff_id BIGINT NOT NULL COMMENT '服务员工ID（admin_users.id）',
+  product_id BIGINT NOT NULL COMMENT '服务产品ID',
+  product_name VARCHAR(255) NOT NULL COMMENT '产品名称快照',
+  duration_min INT NOT NULL COMMENT '服务时长快照（分钟）',
+  start_at BIGINT NOT NULL COMMENT '开始时间（Unix时间戳）',
+  end_at BIGINT NOT NULL COMMENT '结束时间（Unix时间戳）',
+  status VARCHAR(20) NOT NULL COMMENT '状态: booked, completed, cancelled',
+  order_id BIGINT NOT NULL DEFAULT 0 COMMENT '完成后生成的订单ID',
+  remark VARCHAR(255) NULL COMMENT '备注',
+  cancel_reason VARCHAR(255) NULL COMMENT '取消原因',
+  created_by BIGINT NOT NULL DEFAULT 0 COMMENT '创建人ID',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  INDEX idx_appointment_staff_start (staff_id, start_at),
+  INDEX idx_appointment_customer (customer_id)
+) ENGINE=InnoDB COMMENT='预约表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS appointments;
+DROP TABLE IF EXISTS staff_time_offs;
+DROP TABLE IF EXISTS staff_availabilities;
@@ -0,0 +1,51 @@
+-- +migrate Up
+-- 创建次卡套餐表
+-- 套餐配置挂在 package 类型产品上；购买后为客户生成套餐，剩余次数只通过套餐流水变更
+CREATE TABLE IF NOT EXISTS package_plans (
+  product_id BIGINT PRIMARY KEY COMMENT '套餐产品ID（products.type = package）',
+  service_product_id BIGINT NOT NULL COMMENT '可抵扣的服务产品ID',
+  total_uses INT NOT NULL COMMENT '每份套餐包含次数',
+  valid_days INT NOT NULL DEFAULT 0 COMMENT '有效天数，0 表示不过期',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）'
+) ENGINE=InnoDB COMMENT='次卡套餐配置表';
+
+CREATE TABLE IF NOT EXISTS customer_packages (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  package_product_id BIGINT NOT NULL COMMENT '套餐产品ID',
+  service_product_id BIGINT NOT NULL COMMENT '可抵扣的服务产品ID',
+  order_id BIGINT NOT NULL COMMENT '购买订单ID',
+  order_item_id BIGINT NOT NULL COMMENT '购买订单项ID',
+  quantity INT NOT NULL COMMENT '购买份数',
+  total_uses INT NOT NULL COMMENT '购买总次数',
+  remaining_uses INT NOT NULL COMMENT '剩余次数（只读，由套餐流水维护）',
+  expires_at BIGINT NOT NULL DEFAULT 0 COMMENT '过期时间（Unix时间戳），0 表示不过期',
+  status VARCHAR(20) NOT NULL COMMENT '状态: active, revoked',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_customer_package_order_item (order_item_id),
+  INDEX idx_customer_package_customer (customer_id, service_product_id)
+) ENGINE=InnoDB COMMENT='客户套餐表';
+
+CREATE TABLE IF NOT EXISTS customer_package_transactions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  customer_package_id BIGINT NOT NULL COMMENT '客户套餐ID',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  type VARCHAR(20) NOT NULL COMMENT '类型: purchase, redeem, restore, revoke',
+  uses INT NOT NULL COMMENT '变更次数（正数）',
+  remaining_after INT NOT NULL COMMENT '变更后剩余次数',
+  order_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联订单ID',
+  order_item_id BIGINT NOT NULL DEFAULT 0 COMMENT '关联订单项ID',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键',
+  note VARCHAR(255) NULL COMMENT '备注',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  UNIQUE KEY uk_customer_package_tx_idem (idempotency_key),
+  INDEX idx_customer_package_tx_package (customer_package_id, created_at),
+  INDEX idx_customer_package_tx_order_item (order_item_id)
+) ENGINE=InnoDB COMMENT='客户套餐流水表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS customer_package_transactions;
+DROP TABLE IF EXISTS customer_packages;
+DROP TABLE IF EXISTS package_plans;
@@ -0,0 +1,51 @@
+-- +migrate Up
+-- 创建员工提成表
+-- 规则按 产品 > 分类 > 默认 的优先级匹配，可按员工当月业绩分档；提成明细为只追加的流水，计提与冲回均带幂等键
+CREATE TABLE IF NOT EXISTS commission_rules (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(100) NOT NULL COMMENT '规则名称',
+  scope VARCHAR(20) NOT NULL COMMENT '适用范围: product, category, default',
+  product_id BIGINT NOT NULL DEFAULT 0 COMMENT '适用产品ID，scope = product 时有效',
+  category VARCHAR(50) NOT NULL DEFAULT '' COMMENT '适用产品分类，scope = category 时有效',
+  type VARCHAR(20) NOT NULL COMMENT '计算方式: percent（万分比）, fixed（每件固定金额，分）',
+  value BIGINT NOT NULL COMMENT '基础提成值，未达到任何分档时使用',
+  is_active TINYINT(1) NOT NULL DEFAULT 1 COMMENT '是否启用',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_commission_rule_scope (scope, product_id, category)
+) ENGINE=InnoDB COMMENT='员工提成规则表';
+
+CREATE TABLE IF NOT EXISTS commission_rule_tiers (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  rule_id BIGINT NOT NULL COMMENT '提成规则ID',
+  min_volume BIGINT NOT NULL COMMENT '当月业绩门槛（分），业绩达到门槛后使用本档提成值',
+  value BIGINT NOT NULL COMMENT '本档提成值，单位同规则计算方式',
+  UNIQUE KEY uk_commission_rule_tier (rule_id, min_volume)
+) ENGINE=InnoDB COMMENT='员工提成分档表';
+
+CREATE TABLE IF NOT EXISTS commission_entries (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  staff_id BIGINT NOT NULL COMMENT '员工ID（订单 assigned_to）',
+  type VARCHAR(20) NOT NULL COMMENT '类型: accrual, reversal',
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  order_item_id BIGINT NOT NULL COMMENT '订单项ID',
+  product_id BIGINT NOT NULL COMMENT '产品ID',
+  refund_id BIGINT NOT NULL DEFAULT 0 COMMENT '退款记录ID，冲回时有效',
+  rule_id BIGINT NOT NULL COMMENT '计提时匹配的提成规则ID',
+  rule_type VARCHAR(20) NOT NULL COMMENT '计提时的计算方式快照',
+  rule_value BIGINT NOT NULL COMMENT '计提时实际使用的提成值快照（含分档）',
+  quantity INT NOT NULL COMMENT '件数',
+  base_amount BIGINT NOT NULL COMMENT '提成基数（分），即订单项分摊订单优惠后的实收金额',
+  amount BIGINT NOT NULL COMMENT '提成金额（分，正数）',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键',
+  occurred_at BIGINT NOT NULL COMMENT '业务发生时间（支付或退款时间，Unix时间戳），用于归属结算周期',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  UNIQUE KEY uk_commission_entry_idem (idempotency_key),
+  INDEX idx_commission_entry_staff (staff_id, occurred_at),
+  INDEX idx_commission_entry_order_item (order_item_id)
+) ENGINE=InnoDB COMMENT='员工提成明细表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS commission_entries;
+DROP TABLE IF EXISTS commission_rule_tiers;
+DROP TABLE IF EXISTS commission_rules;
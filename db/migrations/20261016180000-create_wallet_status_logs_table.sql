@@ -0,0 +1,17 @@
+-- +migrate Up
+-- 创建钱包状态变更日志表
+-- 记录每次冻结/解冻的原状态、目标状态、操作人及原因，便于审计与追溯
+CREATE TABLE IF NOT EXISTS wallet_status_logs (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  wallet_id BIGINT NOT NULL COMMENT '钱包ID',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  from_status TINYINT NOT NULL COMMENT '原状态：1-正常，0-冻结',
+  to_status TINYINT NOT NULL COMMENT '目标状态：1-正常，0-冻结',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID，0表示系统',
+  reason VARCHAR(255) NOT NULL COMMENT '变更原因',
+  created_at BIGINT NOT NULL COMMENT '变更时间（Unix时间戳）',
+  INDEX idx_wallet_status_customer_time (customer_id, created_at)
+) ENGINE=InnoDB COMMENT='钱包状态变更日志表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_status_logs;
@@ -0,0 +1,47 @@
+-- +migrate Up
+-- 充值赠送：赠送金额以独立流水类型入账，便于区分实付资金与赠送资金
+-- recharge_bonus 赠送入账；recharge_refund 退回充值本金；bonus_clawback 退充值时收回赠送
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback') NOT NULL COMMENT '交易类型';
+
+-- 充值赠送规则：充值金额达到门槛即赠送固定金额，多条规则同时满足时取门槛最高的一条
+CREATE TABLE IF NOT EXISTS recharge_bonus_rules (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(100) NOT NULL COMMENT '规则名称，如：充100送20',
+  min_amount BIGINT NOT NULL COMMENT '充值门槛（分）',
+  bonus_amount BIGINT NOT NULL COMMENT '赠送金额（分）',
+  start_at BIGINT NOT NULL DEFAULT 0 COMMENT '生效开始时间（Unix时间戳），0表示不限',
+  end_at BIGINT NOT NULL DEFAULT 0 COMMENT '生效结束时间（Unix时间戳，不含），0表示不限',
+  is_active TINYINT(1) NOT NULL DEFAULT 1 COMMENT '是否启用',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  INDEX idx_recharge_bonus_rule_amount (is_active, min_amount)
+) ENGINE=InnoDB COMMENT='充值赠送规则表';
+
+-- 充值记录：关联本金流水与赠送流水，退充值时据此收回赠送
+CREATE TABLE IF NOT EXISTS wallet_recharges (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  wallet_id BIGINT NOT NULL COMMENT '钱包ID',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  amount BIGINT NOT NULL COMMENT '充值本金（分）',
+  bonus_amount BIGINT NOT NULL DEFAULT 0 COMMENT '赠送金额（分）',
+  rule_id BIGINT NOT NULL DEFAULT 0 COMMENT '命中的赠送规则ID，0表示无规则或手工指定赠送',
+  principal_tx_id BIGINT NOT NULL COMMENT '本金入账流水ID',
+  bonus_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '赠送入账流水ID，无赠送为0',
+  status VARCHAR(20) NOT NULL DEFAULT 'completed' COMMENT '状态：completed-已到账，refunded-已退回',
+  refund_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '退回本金流水ID',
+  clawback_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '收回赠送流水ID',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+  note VARCHAR(255) NULL COMMENT '备注',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键',
+  created_at BIGINT NOT NULL COMMENT '充值时间（Unix时间戳）',
+  refunded_at BIGINT NOT NULL DEFAULT 0 COMMENT '退回时间（Unix时间戳）',
+  UNIQUE KEY uk_wallet_recharge_idem (idempotency_key),
+  INDEX idx_wallet_recharge_customer (customer_id, created_at)
+) ENGINE=InnoDB COMMENT='钱包充值记录表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_recharges;
+DROP TABLE IF EXISTS recharge_bonus_rules;
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out') NOT NULL COMMENT '交易类型';
@@ -0,0 +1,33 @@
+-- +migrate Up
+-- 钱包真相表（TruthService）：由旧表 wallets / wallet_transactions 迁移而来
+-- 迁移期间通过回填命令与双写保持与旧表一致，钱包ID与流水ID沿用旧表，切换后订单支付、退款等记录的流水ID无需改写
+CREATE TABLE IF NOT EXISTS bil_wallets (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  customer_id BIGINT NOT NULL COMMENT '客户ID，每个客户只有一个钱包',
+  balance BIGINT NOT NULL DEFAULT 0 COMMENT '当前余额（分），只读字段，由交易聚合计算',
+  status TINYINT NOT NULL DEFAULT 1 COMMENT '钱包状态：1-正常，0-冻结',
+  updated_at BIGINT NOT NULL DEFAULT 0 COMMENT '最后更新时间（Unix时间戳）',
+  UNIQUE KEY uk_bil_wallet_customer (customer_id)
+) ENGINE=InnoDB COMMENT='钱包真相表';
+
+CREATE TABLE IF NOT EXISTS bil_wallet_transactions (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  wallet_id BIGINT NOT NULL COMMENT '钱包ID',
+  direction ENUM('credit','debit') NOT NULL COMMENT '资金方向：credit-入账，debit-出账',
+  amount BIGINT NOT NULL COMMENT '交易金额（分），始终为正数',
+  type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback') NOT NULL COMMENT '交易类型',
+  biz_ref_type VARCHAR(32) NULL COMMENT '业务引用类型：order/refund/manual等',
+  biz_ref_id BIGINT NULL COMMENT '业务引用ID，如订单ID',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键，与旧表流水一致',
+  operator_id BIGINT NULL COMMENT '操作员ID',
+  reason_code VARCHAR(32) NULL COMMENT '交易原因代码',
+  note VARCHAR(255) NULL COMMENT '备注信息',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  UNIQUE KEY uk_idem (idempotency_key),
+  INDEX idx_wallet_time (wallet_id, created_at),
+  INDEX idx_bil_biz_ref (biz_ref_type, biz_ref_id)
+) ENGINE=InnoDB COMMENT='钱包交易流水真相表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS bil_wallet_transactions;
+DROP TABLE IF EXISTS bil_wallets;
@@ -0,0 +1,32 @@
+-- +migrate Up
+-- 钱包转账：转出方以 transfer_out 出账，转入方以 transfer_in 入账，两笔流水 biz_ref_type=transfer、biz_ref_id 为转账记录ID
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out') NOT NULL COMMENT '交易类型';
+ALTER TABLE bil_wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out') NOT NULL COMMENT '交易类型';
+
+-- 转账记录：一次转账对应一条记录和两笔流水，幂等键在记录上唯一
+CREATE TABLE IF NOT EXISTS wallet_transfers (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  from_customer_id BIGINT NOT NULL COMMENT '转出客户ID',
+  to_customer_id BIGINT NOT NULL COMMENT '转入客户ID',
+  from_wallet_id BIGINT NOT NULL COMMENT '转出钱包ID',
+  to_wallet_id BIGINT NOT NULL COMMENT '转入钱包ID',
+  amount BIGINT NOT NULL COMMENT '转账金额（分）',
+  out_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '转出流水ID',
+  in_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '转入流水ID',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+  note VARCHAR(255) NULL COMMENT '备注',
+  idempotency_key VARCHAR(64) NOT NULL COMMENT '幂等键',
+  created_at BIGINT NOT NULL COMMENT '转账时间（Unix时间戳）',
+  UNIQUE KEY uk_wallet_transfer_idem (idempotency_key),
+  INDEX idx_wallet_transfer_from (from_customer_id, created_at),
+  INDEX idx_wallet_transfer_to (to_customer_id, created_at)
+) ENGINE=InnoDB COMMENT='钱包转账记录表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_transfers;
+ALTER TABLE bil_wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback') NOT NULL COMMENT '交易类型';
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback') NOT NULL COMMENT '交易类型';
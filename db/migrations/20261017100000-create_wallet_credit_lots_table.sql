@@ -0,0 +1,27 @@
+-- +migrate Up
+-- 赠送规则可设置赠送有效天数，0 表示赠送永久有效
+ALTER TABLE recharge_bonus_rules
+  ADD COLUMN bonus_valid_days INT NOT NULL DEFAULT 0 COMMENT '赠送有效天数，0表示永久有效' AFTER bonus_amount;
+
+-- 有效期额度：带过期时间的入账（充值赠送、补偿额度）各对应一条记录
+-- 扣款时按过期时间从早到晚扣减剩余额度，定时任务把到期的剩余额度以 adjust_out 流水扣回
+CREATE TABLE IF NOT EXISTS wallet_credit_lots (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  wallet_id BIGINT NOT NULL COMMENT '钱包ID',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  source_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '入账流水ID',
+  amount BIGINT NOT NULL COMMENT '入账金额（分）',
+  remaining BIGINT NOT NULL COMMENT '剩余未使用金额（分）',
+  expires_at BIGINT NOT NULL COMMENT '过期时间（Unix时间戳）',
+  expired_amount BIGINT NOT NULL DEFAULT 0 COMMENT '过期扣回金额（分）',
+  expired_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '过期扣回流水ID',
+  note VARCHAR(255) NULL COMMENT '备注',
+  created_at BIGINT NOT NULL COMMENT '入账时间（Unix时间戳）',
+  INDEX idx_credit_lot_wallet (wallet_id, expires_at),
+  INDEX idx_credit_lot_customer (customer_id, expires_at),
+  INDEX idx_credit_lot_expires (expires_at, remaining)
+) ENGINE=InnoDB COMMENT='钱包有效期额度表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_credit_lots;
+ALTER TABLE recharge_bonus_rules DROP COLUMN bonus_valid_days;
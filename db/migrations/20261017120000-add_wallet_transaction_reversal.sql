@@ -0,0 +1,13 @@
+-- +migrate Up
+-- 流水冲正：以 reversal 类型写入与原流水方向相反、金额相同的流水，biz_ref_type=reversal、biz_ref_id 为原流水ID，
+-- 幂等键固定为 reversal_<原流水ID>，同一流水只能冲正一次；冲正原因代码写入 reason_code
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out','reversal') NOT NULL COMMENT '交易类型';
+ALTER TABLE bil_wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out','reversal') NOT NULL COMMENT '交易类型';
+
+-- +migrate Down
+ALTER TABLE bil_wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out') NOT NULL COMMENT '交易类型';
+ALTER TABLE wallet_transactions
+  MODIFY COLUMN type ENUM('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out') NOT NULL COMMENT '交易类型';
@@ -0,0 +1,17 @@
+-- +migrate Up
+-- 流水哈希链：hash = SHA-256(prev_hash + 流水内容 JSON)，prev_hash 为同一钱包上一笔流水的 hash，首笔为空；
+-- 本迁移之前写入的流水保持为空，作为链前的历史流水，校验命令 verify-wallet-ledger 从第一笔有哈希的流水开始沿链校验
+ALTER TABLE wallet_transactions
+  ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '' COMMENT '同一钱包上一笔流水的哈希，首笔为空' AFTER created_at,
+  ADD COLUMN hash CHAR(64) NOT NULL DEFAULT '' COMMENT '本流水内容与上一笔哈希的 SHA-256' AFTER prev_hash;
+ALTER TABLE bil_wallet_transactions
+  ADD COLUMN prev_hash CHAR(64) NOT NULL DEFAULT '' COMMENT '同一钱包上一笔流水的哈希，首笔为空' AFTER created_at,
+  ADD COLUMN hash CHAR(64) NOT NULL DEFAULT '' COMMENT '本流水内容与上一笔哈希的 SHA-256' AFTER prev_hash;
+
+-- +migrate Down
+ALTER TABLE bil_wallet_transactions
+  DROP COLUMN hash,
+  DROP COLUMN prev_hash;
+ALTER TABLE wallet_transactions
+  DROP COLUMN hash,
+  DROP COLUMN prev_hash;
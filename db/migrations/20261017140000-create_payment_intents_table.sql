@@ -0,0 +1,28 @@
+-- +migrate Up
+-- 创建在线支付单表
+-- 每次发起在线支付生成一张支付单，渠道回调或主动查询确认支付成功后以 online 方式记入 order_payments
+CREATE TABLE IF NOT EXISTS payment_intents (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  intent_no VARCHAR(64) NOT NULL COMMENT '支付单号，作为渠道侧的商户订单号',
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  provider VARCHAR(32) NOT NULL COMMENT '支付渠道',
+  provider_trade_no VARCHAR(64) NOT NULL DEFAULT '' COMMENT '渠道交易号',
+  amount BIGINT NOT NULL COMMENT '支付金额（分）',
+  status VARCHAR(20) NOT NULL COMMENT '状态: pending, succeeded, failed, refunded',
+  pay_url VARCHAR(512) NOT NULL DEFAULT '' COMMENT '支付链接',
+  order_payment_id BIGINT NOT NULL DEFAULT 0 COMMENT '订单支付明细ID（order_payments.id），支付成功前为0',
+  failure_reason VARCHAR(255) NOT NULL DEFAULT '' COMMENT '失败或退款原因',
+  idempotency_key VARCHAR(128) NOT NULL COMMENT '幂等键',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '发起人ID',
+  paid_at BIGINT NOT NULL DEFAULT 0 COMMENT '支付完成时间（Unix时间戳）',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_intent_no (intent_no),
+  UNIQUE KEY uk_intent_idem (idempotency_key),
+  INDEX idx_intent_order (order_id),
+  INDEX idx_intent_status_time (status, created_at)
+) ENGINE=InnoDB COMMENT='在线支付单表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS payment_intents;
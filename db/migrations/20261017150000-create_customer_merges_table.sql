@@ -0,0 +1,23 @@
+-- +migrate Up
+-- 创建客户合并记录表
+-- 每次合并重复客户写入一条记录，保存被合并客户的资料快照及改挂的数据量
+CREATE TABLE IF NOT EXISTS customer_merges (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  survivor_id BIGINT NOT NULL COMMENT '保留的客户ID',
+  loser_id BIGINT NOT NULL COMMENT '被合并（软删除）的客户ID',
+  moved_contacts BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的联系人数',
+  moved_orders BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的订单数',
+  moved_activities BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的活动数',
+  moved_marketing_records BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的营销记录数',
+  wallet_amount BIGINT NOT NULL DEFAULT 0 COMMENT '转入保留客户钱包的余额（分）',
+  wallet_transfer_id BIGINT NOT NULL DEFAULT 0 COMMENT '钱包转账记录ID（wallet_transfers.id），无余额时为0',
+  loser_snapshot TEXT NOT NULL COMMENT '被合并客户合并前的资料（JSON）',
+  operator_id BIGINT NOT NULL DEFAULT 0 COMMENT '操作人ID',
+  reason VARCHAR(255) NOT NULL DEFAULT '' COMMENT '合并原因',
+  created_at BIGINT NOT NULL COMMENT '合并时间（Unix时间戳）',
+  UNIQUE KEY uk_merge_loser (loser_id),
+  INDEX idx_merge_survivor (survivor_id, created_at)
+) ENGINE=InnoDB COMMENT='客户合并记录表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS customer_merges;
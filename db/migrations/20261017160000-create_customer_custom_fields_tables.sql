@@ -0,0 +1,32 @@
+-- +migrate Up
+-- 创建客户自定义字段定义表
+-- 管理员按业务类型配置客户属性（如发质、车牌号、宠物名），字段类型创建后不可修改
+CREATE TABLE IF NOT EXISTS customer_custom_fields (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  field_key VARCHAR(50) NOT NULL COMMENT '字段标识，客户接口中以此为键',
+  label VARCHAR(50) NOT NULL COMMENT '显示名称',
+  field_type VARCHAR(20) NOT NULL COMMENT '字段类型：text/number/date/enum/multi_select',
+  options TEXT NOT NULL COMMENT '可选项（JSON数组），仅 enum/multi_select 使用',
+  required TINYINT(1) NOT NULL DEFAULT 0 COMMENT '创建客户时是否必填',
+  sort INT NOT NULL DEFAULT 0 COMMENT '排序，越小越靠前',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_custom_field_key (field_key)
+) ENGINE=InnoDB COMMENT='客户自定义字段定义表';
+
+-- 创建客户自定义字段值表
+-- 每个客户每个字段一行，值统一以文本保存：数字为十进制串，日期为 YYYY-MM-DD，多选为 JSON 数组
+CREATE TABLE IF NOT EXISTS customer_custom_field_values (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  customer_id BIGINT NOT NULL COMMENT '客户ID',
+  field_id BIGINT NOT NULL COMMENT '字段ID（customer_custom_fields.id）',
+  value VARCHAR(1024) NOT NULL COMMENT '字段值',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_custom_field_value (customer_id, field_id),
+  INDEX idx_custom_field_value_field (field_id, value(191))
+) ENGINE=InnoDB COMMENT='客户自定义字段值表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS customer_custom_field_values;
+DROP TABLE IF EXISTS customer_custom_fields;
@@ -0,0 +1,23 @@
+-- +migrate Up
+-- 创建客户标签字典表
+-- 客户的 tags 仍保存标签名称；name_key 为去除首尾空白后的小写名称，避免 "VIP" 与 "vip " 并存
+CREATE TABLE IF NOT EXISTS customer_tags (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  name VARCHAR(50) NOT NULL COMMENT '标签名称',
+  name_key VARCHAR(50) NOT NULL COMMENT '唯一键：小写并去除首尾空白的名称',
+  color CHAR(7) NOT NULL DEFAULT '#909399' COMMENT '颜色 #RRGGBB',
+  group_name VARCHAR(50) NOT NULL DEFAULT '' COMMENT '分组，空表示未分组',
+  sort INT NOT NULL DEFAULT 0 COMMENT '组内排序，越小越靠前',
+  created_at BIGINT NOT NULL COMMENT '创建时间（Unix时间戳）',
+  updated_at BIGINT NOT NULL COMMENT '更新时间（Unix时间戳）',
+  UNIQUE KEY uk_customer_tag_name_key (name_key),
+  INDEX idx_customer_tag_group (group_name, sort)
+) ENGINE=InnoDB COMMENT='客户标签字典表';
+
+-- 为 customers.tags 创建多值索引（MySQL 8.0.17+），供 JSON_CONTAINS/JSON_OVERLAPS 标签筛选使用
+-- 索引元素为 CHAR(50)，执行前须确认已有客户标签均不超过 50 个字符
+ALTER TABLE customers ADD INDEX idx_customers_tags ((CAST(tags AS CHAR(50) ARRAY)));
+
+-- +migrate Down
+ALTER TABLE customers DROP INDEX idx_customers_tags;
+DROP TABLE IF EXISTS customer_tags;
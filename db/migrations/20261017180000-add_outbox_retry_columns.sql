@@ -0,0 +1,15 @@
+-- +migrate Up
+-- outbox 投递重试：处理失败的事件记录失败次数与原因，按指数退避在 next_retry_at 之后重试；
+-- 失败次数达到上限的事件不再自动投递（死信），排查后将 attempts 置 0 即可重新投递
+ALTER TABLE sys_outbox
+  ADD COLUMN attempts INT NOT NULL DEFAULT 0 COMMENT '投递失败次数' AFTER processed_at,
+  ADD COLUMN last_error VARCHAR(512) NOT NULL DEFAULT '' COMMENT '最近一次投递失败原因' AFTER attempts,
+  ADD COLUMN next_retry_at BIGINT NOT NULL DEFAULT 0 COMMENT '下次可投递时间（Unix时间戳），0表示立即',
+  ADD INDEX idx_pending (processed_at, attempts, next_retry_at);
+
+-- +migrate Down
+ALTER TABLE sys_outbox
+  DROP INDEX idx_pending,
+  DROP COLUMN next_retry_at,
+  DROP COLUMN last_error,
+  DROP COLUMN attempts;
@@ -0,0 +1,18 @@
+-- +migrate Up
+-- 流水哈希链起点：ID 不大于 start_tx_id 的流水为链前历史流水，之后写入的流水必须带哈希；
+-- 真相表沿用旧表流水ID，两张流水表共用同一起点。起点取第一笔已封存流水的前一个ID，尚无封存流水时取当前最大ID
+CREATE TABLE IF NOT EXISTS wallet_ledger_chain (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  start_tx_id BIGINT NOT NULL COMMENT '链前流水的最大ID',
+  created_at BIGINT NOT NULL COMMENT '记录时间（Unix时间戳）'
+) ENGINE=InnoDB COMMENT='钱包流水哈希链起点表';
+
+INSERT INTO wallet_ledger_chain (start_tx_id, created_at)
+SELECT COALESCE(
+         (SELECT MIN(id) - 1 FROM wallet_transactions WHERE hash <> ''),
+         (SELECT MAX(id) FROM wallet_transactions),
+         0),
+       UNIX_TIMESTAMP();
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_ledger_chain;
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- 有效期额度扣减明细：订单扣款按额度逐条记录扣减金额，订单退款时据此把额度恢复到原额度，保留原过期时间
+CREATE TABLE IF NOT EXISTS wallet_credit_lot_usages (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  lot_id BIGINT NOT NULL COMMENT '有效期额度ID',
+  tx_id BIGINT NOT NULL COMMENT '扣款流水ID',
+  amount BIGINT NOT NULL COMMENT '扣减金额（分）',
+  restored BIGINT NOT NULL DEFAULT 0 COMMENT '退款已恢复金额（分）',
+  created_at BIGINT NOT NULL COMMENT '扣减时间（Unix时间戳）',
+  INDEX idx_credit_lot_usage_tx (tx_id)
+) ENGINE=InnoDB COMMENT='钱包有效期额度扣减明细表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS wallet_credit_lot_usages;
@@ -0,0 +1,20 @@
+-- +migrate Up
+-- 退款去向：退款金额按支付明细顺序分摊到各支付明细并原路退回，
+-- 在线支付明细经收款渠道退款，其余支付方式合并为一笔钱包退款入账
+CREATE TABLE IF NOT EXISTS order_refund_payments (
+  id BIGINT AUTO_INCREMENT PRIMARY KEY,
+  refund_id BIGINT NOT NULL COMMENT '退款单ID',
+  order_id BIGINT NOT NULL COMMENT '订单ID',
+  order_payment_id BIGINT NOT NULL DEFAULT 0 COMMENT '支付明细ID，0表示无对应支付明细（历史订单）',
+  method VARCHAR(20) NOT NULL COMMENT '原支付方式',
+  amount BIGINT NOT NULL COMMENT '退款金额（分）',
+  wallet_tx_id BIGINT NOT NULL DEFAULT 0 COMMENT '钱包退款流水ID，经渠道退款时为0',
+  provider VARCHAR(32) NOT NULL DEFAULT '' COMMENT '退款渠道，退回钱包时为空',
+  provider_refund_no VARCHAR(128) NOT NULL DEFAULT '' COMMENT '渠道退款单号',
+  created_at BIGINT NOT NULL COMMENT '退款时间（Unix时间戳）',
+  INDEX idx_refund_payment_refund (refund_id),
+  INDEX idx_refund_payment_order (order_id, order_payment_id)
+) ENGINE=InnoDB COMMENT='订单退款去向表';
+
+-- +migrate Down
+DROP TABLE IF EXISTS order_refund_payments;
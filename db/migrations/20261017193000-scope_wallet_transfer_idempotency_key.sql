@@ -0,0 +1,10 @@
+-- +migrate Up
+-- 转账幂等键按转出客户隔离：不同客户使用相同的客户端幂等键互不重放
+ALTER TABLE wallet_transfers
+  DROP INDEX uk_wallet_transfer_idem,
+  ADD UNIQUE KEY uk_wallet_transfer_idem (from_customer_id, idempotency_key);
+
+-- +migrate Down
+ALTER TABLE wallet_transfers
+  DROP INDEX uk_wallet_transfer_idem,
+  ADD UNIQUE KEY uk_wallet_transfer_idem (idempotency_key);
@@ -0,0 +1,14 @@
+-- +migrate Up
+-- 客户合并同时改挂服务套餐及流水、预约、优惠券核销，记录各自改挂的数量
+ALTER TABLE customer_merges
+  ADD COLUMN moved_packages BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的服务套餐数' AFTER moved_marketing_records,
+  ADD COLUMN moved_package_transactions BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的服务套餐流水数' AFTER moved_packages,
+  ADD COLUMN moved_appointments BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的预约数' AFTER moved_package_transactions,
+  ADD COLUMN moved_coupon_redemptions BIGINT NOT NULL DEFAULT 0 COMMENT '改挂的优惠券核销记录数' AFTER moved_appointments;
+
+-- +migrate Down
+ALTER TABLE customer_merges
+  DROP COLUMN moved_coupon_redemptions,
+  DROP COLUMN moved_appointments,
+  DROP COLUMN moved_package_transactions,
+  DROP COLUMN moved_packages;
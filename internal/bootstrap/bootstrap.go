@@ -7,9 +7,14 @@ import (
 	"log"
 	"time"
 
+	"crm_lite/internal/common"
 	"crm_lite/internal/core/config"
 	"crm_lite/internal/core/logger"
 	"crm_lite/internal/core/resource"
+	billingImpl "crm_lite/internal/domains/billing/impl"
+	commissionImpl "crm_lite/internal/domains/commission/impl"
+	salesImpl "crm_lite/internal/domains/sales/impl"
+	"crm_lite/internal/middleware"
 	"crm_lite/internal/validators"
 	"crm_lite/pkg/scheduler"
 	"crm_lite/pkg/validator"
@@ -68,6 +73,56 @@ func Bootstrap() (*resource.Manager, *scheduler.LogCleaner, func(), error) {
 			zap.Duration("interval", logCleanupConfig.Interval))
 	}
 
+	// 启动超时订单自动取消任务
+	orderCanceller := scheduler.NewOrderAutoCanceller(&scheduler.OrderAutoCancelConfig{
+		PaymentTimeout: opts.Order.PaymentTimeout,
+		Interval:       opts.Order.AutoCancelInterval,
+		BatchSize:      opts.Order.AutoCancelBatch,
+	}, salesImpl.ProvideSales(resManager))
+	if err := orderCanceller.Start(); err != nil {
+		logger.Error("Failed to start order auto canceller", zap.Error(err))
+	}
+
+	// 启动 outbox 事件投递任务，订阅员工提成计提与冲回
+	outboxDispatcher, err := newOutboxDispatcher(resManager, &opts.Outbox)
+	if err != nil {
+		logger.Error("Failed to create outbox dispatcher", zap.Error(err))
+	} else if err := outboxDispatcher.Start(); err != nil {
+		logger.Error("Failed to start outbox dispatcher", zap.Error(err))
+	}
+
+	// 启动钱包余额定时对账、有效期额度定时过期与过期幂等键清理任务
+	var walletReconciler *scheduler.WalletReconcileJob
+	var walletCreditExpirer *scheduler.WalletCreditExpiryJob
+	var idempotencyPurger *scheduler.IdempotencyPurgeJob
+	if dbRes, err := resource.Get[*resource.DBResource](resManager, resource.DBServiceKey); err != nil {
+		logger.Error("Failed to create wallet reconcile job", zap.Error(err))
+	} else {
+		walletReconciler = scheduler.NewWalletReconcileJob(&scheduler.WalletReconcileConfig{
+			Interval: opts.Wallet.ReconcileInterval,
+			Repair:   opts.Wallet.ReconcileRepair,
+		}, billingImpl.NewBalanceReconciler(dbRes.DB))
+		if err := walletReconciler.Start(); err != nil {
+			logger.Error("Failed to start wallet reconcile job", zap.Error(err))
+		}
+
+		// 启动有效期额度定时过期任务
+		walletCreditExpirer = scheduler.NewWalletCreditExpiryJob(&scheduler.WalletCreditExpiryConfig{
+			Interval: opts.Wallet.CreditExpiryInterval,
+		}, billingImpl.NewCreditExpiryService(dbRes.DB))
+		if err := walletCreditExpirer.Start(); err != nil {
+			logger.Error("Failed to start wallet credit expiry job", zap.Error(err))
+		}
+
+		// 启动过期幂等键清理任务，Redis 不可用时幂等中间件回退到 idempotency_keys 表，因此始终清理
+		idempotencyPurger = scheduler.NewIdempotencyPurgeJob(&scheduler.IdempotencyPurgeConfig{
+			Interval: opts.Idempotency.PurgeInterval,
+		}, middleware.NewDBIdempotencyStore(dbRes.DB))
+		if err := idempotencyPurger.Start(); err != nil {
+			logger.Error("Failed to start idempotency purge job", zap.Error(err))
+		}
+	}
+
 	// 5. 初始化管理员用户和权限系统
 	if err := initSuperAdmin(resManager); err != nil {
 		log.Printf("Warning: Failed to initialize admin user: %v", err)
@@ -81,6 +136,19 @@ func Bootstrap() (*resource.Manager, *scheduler.LogCleaner, func(), error) {
 		if logCleaner != nil {
 			logCleaner.Stop()
 		}
+		orderCanceller.Stop()
+		if outboxDispatcher != nil {
+			outboxDispatcher.Stop()
+		}
+		if walletReconciler != nil {
+			walletReconciler.Stop()
+		}
+		if walletCreditExpirer != nil {
+			walletCreditExpirer.Stop()
+		}
+		if idempotencyPurger != nil {
+			idempotencyPurger.Stop()
+		}
 
 		// 关闭资源管理器
 		if resManager != nil {
@@ -133,6 +201,22 @@ func initResources(resManager *resource.Manager, opts *config.Options) error {
 	return nil
 }
 
+// newOutboxDispatcher 创建 outbox 事件投递任务并注册各域的事件处理函数
+func newOutboxDispatcher(resManager *resource.Manager, outboxOpts *config.OutboxOptions) (*scheduler.OutboxDispatcher, error) {
+	dbRes, err := resource.Get[*resource.DBResource](resManager, resource.DBServiceKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get db resource: %w", err)
+	}
+
+	outboxService := common.NewOutboxServiceImpl(dbRes.DB, common.NewTx(dbRes.DB))
+	commissionImpl.RegisterEventHandlers(outboxService, commissionImpl.NewCommissionService(dbRes.DB))
+
+	return scheduler.NewOutboxDispatcher(&scheduler.OutboxDispatchConfig{
+		Interval:  outboxOpts.DispatchInterval,
+		BatchSize: outboxOpts.DispatchBatch,
+	}, outboxService), nil
+}
+
 // convertToSchedulerConfig 将配置转换为调度器配置
 func convertToSchedulerConfig(logCleanupOpts *config.LogCleanupOptions, logOpts *config.LogOptions) *scheduler.LogCleanupConfig {
 	// 如果没有配置，使用默认配置
@@ -0,0 +1,14 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// IdempotencyKeyDigest 将客户端幂等键归一为定长摘要（sha256 前 16 字节的十六进制，32 个字符）
+// 客户端键最长 128 个字符，而钱包流水、退款单等表的幂等键列为 VARCHAR(64)；
+// 由客户端键派生业务幂等键（拼接订单ID、行号等）前须先取摘要，避免超长被截断或相互冲突
+func IdempotencyKeyDigest(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
@@ -5,6 +5,7 @@ package common
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,11 +15,14 @@ import (
 // OutboxEvent Outbox事件模型
 // 用于在业务事务中记录需要发布的事件，实现最终一致性
 type OutboxEvent struct {
-	ID          int64           `json:"id"`           // 事件ID
-	EventType   string          `json:"event_type"`   // 事件类型
-	Payload     json.RawMessage `json:"payload"`      // 事件载荷（JSON格式）
-	CreatedAt   int64           `json:"created_at"`   // 创建时间（Unix时间戳）
-	ProcessedAt *int64          `json:"processed_at"` // 处理时间（Unix时间戳）
+	ID          int64           `json:"id"`            // 事件ID
+	EventType   string          `json:"event_type"`    // 事件类型
+	Payload     json.RawMessage `json:"payload"`       // 事件载荷（JSON格式）
+	CreatedAt   int64           `json:"created_at"`    // 创建时间（Unix时间戳）
+	ProcessedAt *int64          `json:"processed_at"`  // 处理时间（Unix时间戳）
+	Attempts    int             `json:"attempts"`      // 投递失败次数
+	LastError   string          `json:"last_error"`    // 最近一次投递失败原因
+	NextRetryAt int64           `json:"next_retry_at"` // 下次可投递时间（Unix时间戳）
 }
 
 // OutboxService Outbox事件服务接口
@@ -29,7 +33,8 @@ type OutboxService interface {
 	PublishEvent(ctx context.Context, eventType string, payload interface{}) error
 
 	// ProcessPendingEvents 处理待发布事件
-	// 定期调用，将未处理的事件发送到消息队列或其他系统
+	// 定期调用，将未处理的事件发送到消息队列或其他系统；
+	// 处理失败的事件按指数退避延后重试，失败次数达到上限后不再自动投递，避免长期失败的事件阻塞后续批次
 	ProcessPendingEvents(ctx context.Context, limit int) error
 
 	// MarkEventProcessed 标记事件为已处理
@@ -54,18 +59,26 @@ type OutboxRepository interface {
 // 用于标准化系统中的事件类型，便于统一处理
 const (
 	// 订单相关事件
-	EventTypeOrderPlaced    = "order.placed"    // 订单已下单
-	EventTypeOrderPaid      = "order.paid"      // 订单已支付
-	EventTypeOrderRefunded  = "order.refunded"  // 订单已退款
-	EventTypeOrderCancelled = "order.cancelled" // 订单已取消
+	EventTypeOrderPlaced     = "order.placed"     // 订单已下单
+	EventTypeOrderPaid       = "order.paid"       // 订单已支付
+	EventTypeOrderRefunded   = "order.refunded"   // 订单已退款
+	EventTypeOrderCancelled  = "order.cancelled"  // 订单已取消
+	EventTypeOrderPending    = "order.pending"    // 订单待支付
+	EventTypeOrderConfirmed  = "order.confirmed"  // 订单已确认
+	EventTypeOrderProcessing = "order.processing" // 订单处理中
+	EventTypeOrderShipped    = "order.shipped"    // 订单已发货
+	EventTypeOrderCompleted  = "order.completed"  // 订单已完成
 
 	// 钱包相关事件
 	EventTypeWalletCredited = "wallet.credited" // 钱包入账
 	EventTypeWalletDebited  = "wallet.debited"  // 钱包出账
+	EventTypeWalletFrozen   = "wallet.frozen"   // 钱包已冻结
+	EventTypeWalletUnfrozen = "wallet.unfrozen" // 钱包已解冻
 
 	// 客户相关事件
 	EventTypeCustomerCreated = "customer.created" // 客户已创建
 	EventTypeCustomerUpdated = "customer.updated" // 客户已更新
+	EventTypeCustomerMerged  = "customer.merged"  // 客户已合并
 )
 
 // OrderPlacedEvent 订单下单事件载荷
@@ -75,6 +88,8 @@ type OrderPlacedEvent struct {
 	CustomerID  int64  `json:"customer_id"`
 	TotalAmount int64  `json:"total_amount"`
 	PayMethod   string `json:"pay_method"`
+	SourceType  string `json:"source_type,omitempty"` // 来源类型：marketing/referral/direct
+	SourceID    int64  `json:"source_id,omitempty"`   // 来源ID，如营销活动ID
 	CreatedAt   int64  `json:"created_at"`
 }
 
@@ -89,13 +104,38 @@ type OrderPaidEvent struct {
 }
 
 // OrderRefundedEvent 订单退款事件载荷
+// 部分退款时 Lines 只包含本次退款的订单项，FullyRefunded 标识订单是否已全部退完
 type OrderRefundedEvent struct {
-	OrderID      int64  `json:"order_id"`
-	OrderNo      string `json:"order_no"`
-	CustomerID   int64  `json:"customer_id"`
-	RefundAmount int64  `json:"refund_amount"`
-	Reason       string `json:"reason"`
-	RefundedAt   int64  `json:"refunded_at"`
+	OrderID       int64             `json:"order_id"`
+	OrderNo       string            `json:"order_no"`
+	CustomerID    int64             `json:"customer_id"`
+	RefundID      int64             `json:"refund_id"`
+	RefundAmount  int64             `json:"refund_amount"`
+	Reason        string            `json:"reason"`
+	FullyRefunded bool              `json:"fully_refunded"`
+	Lines         []OrderRefundLine `json:"lines"`
+	RefundedAt    int64             `json:"refunded_at"`
+}
+
+// OrderRefundLine 退款事件中的订单项明细
+type OrderRefundLine struct {
+	OrderItemID int64 `json:"order_item_id"`
+	ProductID   int64 `json:"product_id"`
+	Quantity    int32 `json:"quantity"`
+	Amount      int64 `json:"amount"` // 退款金额（分）
+}
+
+// OrderStatusChangedEvent 订单状态流转事件载荷
+// 用于 confirmed/processing/shipped/completed/cancelled 等无专属载荷的流转
+type OrderStatusChangedEvent struct {
+	OrderID    int64  `json:"order_id"`
+	OrderNo    string `json:"order_no"`
+	CustomerID int64  `json:"customer_id"`
+	FromStatus string `json:"from_status"`
+	ToStatus   string `json:"to_status"`
+	OperatorID int64  `json:"operator_id"`
+	Reason     string `json:"reason"`
+	ChangedAt  int64  `json:"changed_at"`
 }
 
 // WalletCreditedEvent 钱包入账事件载荷
@@ -118,6 +158,15 @@ type WalletDebitedEvent struct {
 	DebitedAt       int64  `json:"debited_at"`
 }
 
+// WalletStatusChangedEvent 钱包冻结/解冻事件载荷
+type WalletStatusChangedEvent struct {
+	WalletID   int64  `json:"wallet_id"`
+	CustomerID int64  `json:"customer_id"`
+	OperatorID int64  `json:"operator_id"`
+	Reason     string `json:"reason"`
+	ChangedAt  int64  `json:"changed_at"`
+}
+
 // CustomerCreatedEvent 客户创建事件载荷
 type CustomerCreatedEvent struct {
 	CustomerID int64  `json:"customer_id"`
@@ -129,6 +178,17 @@ type CustomerCreatedEvent struct {
 	CreatedAt  int64  `json:"created_at"`
 }
 
+// CustomerMergedEvent 客户合并事件载荷
+// 被合并客户的数据已改挂到保留客户，下游按 LoserID 维护的数据需同步迁移
+type CustomerMergedEvent struct {
+	MergeID      int64 `json:"merge_id"`
+	SurvivorID   int64 `json:"survivor_id"`
+	LoserID      int64 `json:"loser_id"`
+	WalletAmount int64 `json:"wallet_amount"`
+	OperatorID   int64 `json:"operator_id"`
+	MergedAt     int64 `json:"merged_at"`
+}
+
 // NewOutboxEvent 创建新的Outbox事件
 func NewOutboxEvent(eventType string, payload interface{}) (*OutboxEvent, error) {
 	payloadBytes, err := json.Marshal(payload)
@@ -143,21 +203,46 @@ func NewOutboxEvent(eventType string, payload interface{}) (*OutboxEvent, error)
 	}, nil
 }
 
+// outbox 投递重试策略：第 n 次失败后延后 outboxRetryBaseDelay * 2^(n-1)，最长 outboxRetryMaxDelay；
+// 失败 OutboxMaxAttempts 次的事件成为死信，保留 processed_at 为 NULL 与 last_error 供排查
+const (
+	OutboxMaxAttempts    = 10
+	outboxRetryBaseDelay = 30 * time.Second
+	outboxRetryMaxDelay  = time.Hour
+	outboxLastErrorLen   = 512
+)
+
+// OutboxEventHandler Outbox事件处理函数
+// 事件处理失败时会按退避策略重新投递，处理函数必须幂等
+type OutboxEventHandler func(ctx context.Context, event *OutboxEvent) error
+
 // OutboxServiceImpl Outbox事件服务实现
 // 基于数据库的事件存储实现，确保事务一致性
 type OutboxServiceImpl struct {
-	db *gorm.DB
-	tx Tx
+	db       *gorm.DB
+	tx       Tx
+	handlers map[string][]OutboxEventHandler
 }
 
 // NewOutboxService 创建Outbox事件服务
 func NewOutboxService(db *gorm.DB, tx Tx) OutboxService {
+	return NewOutboxServiceImpl(db, tx)
+}
+
+// NewOutboxServiceImpl 创建Outbox事件服务实现，用于需要注册事件处理函数的场景
+func NewOutboxServiceImpl(db *gorm.DB, tx Tx) *OutboxServiceImpl {
 	return &OutboxServiceImpl{
-		db: db,
-		tx: tx,
+		db:       db,
+		tx:       tx,
+		handlers: make(map[string][]OutboxEventHandler),
 	}
 }
 
+// Subscribe 注册事件处理函数，需在开始处理事件前完成注册
+func (o *OutboxServiceImpl) Subscribe(eventType string, handler OutboxEventHandler) {
+	o.handlers[eventType] = append(o.handlers[eventType], handler)
+}
+
 // PublishEvent 发布事件到Outbox
 func (o *OutboxServiceImpl) PublishEvent(ctx context.Context, eventType string, payload interface{}) error {
 	event, err := NewOutboxEvent(eventType, payload)
@@ -180,27 +265,71 @@ func (o *OutboxServiceImpl) PublishEvent(ctx context.Context, eventType string,
 }
 
 // ProcessPendingEvents 处理待发布事件
+// 只取未处理、未成为死信且已到重试时间的事件，失败的事件不会在退避期内被重复读取
 func (o *OutboxServiceImpl) ProcessPendingEvents(ctx context.Context, limit int) error {
 	// 查询未处理的事件
 	var events []*OutboxEvent
 	err := o.db.WithContext(ctx).
-		Raw(`SELECT * FROM sys_outbox WHERE processed_at IS NULL ORDER BY created_at LIMIT ?`, limit).
+		Raw(`SELECT * FROM sys_outbox
+			WHERE processed_at IS NULL AND attempts < ? AND next_retry_at <= ?
+			ORDER BY created_at, id LIMIT ?`, OutboxMaxAttempts, time.Now().Unix(), limit).
 		Scan(&events).Error
 
 	if err != nil {
 		return fmt.Errorf("查询待处理事件失败: %w", err)
 	}
 
-	// 处理每个事件
+	// 处理每个事件：依次调用已注册的处理函数，全部成功后标记为已处理
+	// 处理失败的事件记录失败次数并延后重试，不影响其他事件
+	var failed []error
 	for _, event := range events {
-		// 这里可以发送到消息队列或其他系统
-		// 为了演示，我们只是标记为已处理
+		if err := o.dispatch(ctx, event); err != nil {
+			failed = append(failed, fmt.Errorf("事件 %d(%s): %w", event.ID, event.EventType, err))
+			if markErr := o.markEventFailed(ctx, event, err); markErr != nil {
+				failed = append(failed, markErr)
+			}
+			continue
+		}
 		if err := o.MarkEventProcessed(ctx, event.ID); err != nil {
-			// 日志记录但继续处理其他事件
+			failed = append(failed, err)
 			continue
 		}
 	}
 
+	if len(failed) > 0 {
+		return fmt.Errorf("处理事件失败: %w", errors.Join(failed...))
+	}
+	return nil
+}
+
+// markEventFailed 记录事件投递失败，按失败次数计算下次可投递时间
+func (o *OutboxServiceImpl) markEventFailed(ctx context.Context, event *OutboxEvent, cause error) error {
+	attempts := event.Attempts + 1
+	delay := outboxRetryMaxDelay
+	if attempts <= 8 {
+		delay = min(outboxRetryBaseDelay<<(attempts-1), outboxRetryMaxDelay)
+	}
+	lastError := []rune(cause.Error())
+	if len(lastError) > outboxLastErrorLen {
+		lastError = lastError[:outboxLastErrorLen]
+	}
+
+	result := o.db.WithContext(ctx).Exec(`
+		UPDATE sys_outbox SET attempts = ?, last_error = ?, next_retry_at = ? WHERE id = ?
+	`, attempts, string(lastError), time.Now().Add(delay).Unix(), event.ID)
+	if result.Error != nil {
+		return fmt.Errorf("记录事件 %d 投递失败: %w", event.ID, result.Error)
+	}
+	return nil
+}
+
+// dispatch 调用事件类型对应的处理函数，未注册处理函数的事件直接视为处理成功
+func (o *OutboxServiceImpl) dispatch(ctx context.Context, event *OutboxEvent) error {
+	for _, handler := range o.handlers[event.EventType] {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
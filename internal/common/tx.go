@@ -137,6 +137,14 @@ const (
 	ErrCodeOrderNotFound      = "ORDER_NOT_FOUND"      // 订单不存在
 	ErrCodeOrderStatusInvalid = "ORDER_STATUS_INVALID" // 订单状态无效
 	ErrCodeOrderCannotRefund  = "ORDER_CANNOT_REFUND"  // 订单不能退款
+	ErrCodeOrderOverpaid      = "ORDER_OVERPAID"       // 支付金额超过应付金额
+
+	// 在线支付相关错误
+	ErrCodePaymentProviderNotFound = "PAYMENT_PROVIDER_NOT_FOUND" // 支付渠道未注册
+	ErrCodePaymentIntentNotFound   = "PAYMENT_INTENT_NOT_FOUND"   // 在线支付单不存在
+	ErrCodePaymentSignatureInvalid = "PAYMENT_SIGNATURE_INVALID"  // 支付回调签名无效
+	ErrCodePaymentAmountMismatch   = "PAYMENT_AMOUNT_MISMATCH"    // 回调实付金额与支付单金额不一致
+	ErrCodePaymentProviderFailed   = "PAYMENT_PROVIDER_FAILED"    // 支付渠道调用失败
 
 	// 产品相关错误
 	ErrCodeProductNotFound    = "PRODUCT_NOT_FOUND"    // 产品不存在
@@ -144,15 +152,60 @@ const (
 	ErrCodeInsufficientStock  = "INSUFFICIENT_STOCK"   // 库存不足
 
 	// 钱包相关错误
-	ErrCodeWalletNotFound       = "WALLET_NOT_FOUND"      // 钱包不存在
-	ErrCodeInsufficientBalance  = "INSUFFICIENT_BALANCE"  // 余额不足
-	ErrCodeWalletFrozen         = "WALLET_FROZEN"         // 钱包已冻结
+	ErrCodeWalletNotFound         = "WALLET_NOT_FOUND"         // 钱包不存在
+	ErrCodeInsufficientBalance    = "INSUFFICIENT_BALANCE"     // 余额不足
+	ErrCodeWalletFrozen           = "WALLET_FROZEN"            // 钱包已冻结
+	ErrCodeWalletStatusInvalid    = "WALLET_STATUS_INVALID"    // 钱包当前状态不允许该操作（如重复冻结）
+	ErrCodeWalletStoreUnsupported = "WALLET_STORE_UNSUPPORTED" // 当前钱包存储模式不支持该操作
+	ErrCodeStatementUnsigned      = "STATEMENT_UNSIGNED"       // 未配置对账单签名密钥，不能导出对账单
+
+	// 流水冲正相关错误
+	ErrCodeTransactionNotFound     = "TRANSACTION_NOT_FOUND"    // 钱包流水不存在
+	ErrCodeTransactionReversed     = "TRANSACTION_REVERSED"     // 流水已被冲正
+	ErrCodeTransactionIrreversible = "TRANSACTION_IRREVERSIBLE" // 该类型流水不能冲正（充值记录、转账等须走对应的退回流程）
+
+	// 充值相关错误
+	ErrCodeRechargeNotFound          = "RECHARGE_NOT_FOUND"            // 充值记录不存在
+	ErrCodeRechargeStatusInvalid     = "RECHARGE_STATUS_INVALID"       // 充值记录状态不允许该操作（如重复退回）
+	ErrCodeRechargeBonusRuleNotFound = "RECHARGE_BONUS_RULE_NOT_FOUND" // 充值赠送规则不存在
 	ErrCodeDuplicateTransaction = "DUPLICATE_TRANSACTION" // 重复交易
 
+	// 优惠券相关错误
+	ErrCodeCouponNotFound      = "COUPON_NOT_FOUND"      // 优惠券不存在
+	ErrCodeCouponInvalid       = "COUPON_INVALID"        // 优惠券不可用（已使用、已过期、不满足使用条件）
+	ErrCodeCouponLimitExceeded = "COUPON_LIMIT_EXCEEDED" // 超过每人使用次数
+
+	// 预约相关错误
+	ErrCodeAppointmentNotFound      = "APPOINTMENT_NOT_FOUND"      // 预约不存在
+	ErrCodeAppointmentConflict      = "APPOINTMENT_CONFLICT"       // 员工该时段已有预约或休息
+	ErrCodeAppointmentStatusInvalid = "APPOINTMENT_STATUS_INVALID" // 预约当前状态不允许该操作
+	ErrCodeStaffUnavailable         = "STAFF_UNAVAILABLE"          // 不在员工可预约时间内
+
+	// 次卡套餐相关错误
+	ErrCodePackageNotFound     = "PACKAGE_NOT_FOUND"    // 套餐或客户套餐不存在
+	ErrCodePackageInsufficient = "PACKAGE_INSUFFICIENT" // 没有剩余次数足够且未过期的套餐
+	ErrCodePackageUsed         = "PACKAGE_USED"         // 套餐已使用，剩余次数不足以退款
+
+	// 员工提成相关错误
+	ErrCodeCommissionRuleNotFound = "COMMISSION_RULE_NOT_FOUND" // 提成规则不存在
+	ErrCodeCommissionRuleConflict = "COMMISSION_RULE_CONFLICT"  // 同一适用范围已有提成规则
+
 	// 客户相关错误
 	ErrCodeCustomerNotFound = "CUSTOMER_NOT_FOUND" // 客户不存在
 	ErrCodePhoneDuplicate   = "PHONE_DUPLICATE"    // 手机号重复
 
+	// 客户活动相关错误
+	ErrCodeActivityNotFound      = "ACTIVITY_NOT_FOUND"      // 活动不存在
+	ErrCodeActivityStatusInvalid = "ACTIVITY_STATUS_INVALID" // 活动当前状态不允许该操作
+
+	// 客户自定义字段相关错误
+	ErrCodeCustomFieldNotFound = "CUSTOM_FIELD_NOT_FOUND" // 自定义字段不存在
+	ErrCodeCustomFieldExists   = "CUSTOM_FIELD_EXISTS"    // 字段标识已存在
+
+	// 客户标签相关错误
+	ErrCodeTagNotFound = "TAG_NOT_FOUND" // 标签不存在
+	ErrCodeTagExists   = "TAG_EXISTS"    // 标签名称已存在
+
 	// 认证相关错误
 	ErrCodeUnauthorized   = "UNAUTHORIZED"    // 未授权
 	ErrCodeResourceExists = "RESOURCE_EXISTS" // 资源已存在
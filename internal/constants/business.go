@@ -72,6 +72,7 @@ const (
 	OrderStatusConfirmed OrderStatus = "confirmed"
 
 	// Extended statuses for order updates
+	OrderStatusPaid       OrderStatus = "paid"
 	OrderStatusProcessing OrderStatus = "processing"
 	OrderStatusShipped    OrderStatus = "shipped"
 	OrderStatusCompleted  OrderStatus = "completed"
@@ -89,6 +90,7 @@ func ValidOrderCreateStatuses() []string {
 }
 
 // ValidOrderUpdateStatuses returns valid statuses for order updates
+// paid is excluded: an order becomes paid only when its payment lines cover the final amount
 func ValidOrderUpdateStatuses() []string {
 	return []string{
 		string(OrderStatusDraft),
@@ -102,6 +104,56 @@ func ValidOrderUpdateStatuses() []string {
 	}
 }
 
+// PaymentStatus defines valid order payment status values
+type PaymentStatus string
+
+const (
+	PaymentStatusUnpaid            PaymentStatus = "unpaid"
+	PaymentStatusPending           PaymentStatus = "pending"
+	PaymentStatusPartiallyPaid     PaymentStatus = "partially_paid"
+	PaymentStatusPaid              PaymentStatus = "paid"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+)
+
+// ValidPaymentStatuses returns valid order payment statuses
+func ValidPaymentStatuses() []string {
+	return []string{
+		string(PaymentStatusUnpaid),
+		string(PaymentStatusPending),
+		string(PaymentStatusPartiallyPaid),
+		string(PaymentStatusPaid),
+		string(PaymentStatusPartiallyRefunded),
+		string(PaymentStatusRefunded),
+	}
+}
+
+// PaymentMethod defines valid order payment line methods
+type PaymentMethod string
+
+const (
+	PaymentMethodWallet PaymentMethod = "wallet"
+	PaymentMethodCash   PaymentMethod = "cash"
+	PaymentMethodOnline PaymentMethod = "online"
+	PaymentMethodCard   PaymentMethod = "card"
+	PaymentMethodMixed  PaymentMethod = "mixed" // 订单级汇总：多种支付方式组合
+)
+
+// ValidPaymentMethods returns valid payment line methods
+func ValidPaymentMethods() []string {
+	return []string{
+		string(PaymentMethodWallet),
+		string(PaymentMethodCash),
+		string(PaymentMethodOnline),
+		string(PaymentMethodCard),
+	}
+}
+
+// ValidOrderPaymentMethods returns valid order-level payment methods (including mixed)
+func ValidOrderPaymentMethods() []string {
+	return append(ValidPaymentMethods(), string(PaymentMethodMixed))
+}
+
 // ================ Marketing Related Constants ================
 
 // MarketingChannelType defines valid marketing channel types
@@ -178,6 +230,91 @@ func ValidMarketingRecordStatuses() []string {
 	}
 }
 
+// CouponType defines valid coupon discount types
+type CouponType string
+
+const (
+	CouponTypeFixed   CouponType = "fixed"   // 固定金额
+	CouponTypePercent CouponType = "percent" // 按比例折扣
+)
+
+// ValidCouponTypes returns all valid coupon types
+func ValidCouponTypes() []string {
+	return []string{
+		string(CouponTypeFixed),
+		string(CouponTypePercent),
+	}
+}
+
+// CouponStatus defines valid coupon definition status values
+type CouponStatus string
+
+const (
+	CouponStatusActive   CouponStatus = "active"
+	CouponStatusDisabled CouponStatus = "disabled"
+)
+
+// AppointmentStatus defines valid appointment status values
+type AppointmentStatus string
+
+const (
+	AppointmentStatusBooked    AppointmentStatus = "booked"    // 已预约
+	AppointmentStatusCompleted AppointmentStatus = "completed" // 已完成并生成订单
+	AppointmentStatusCancelled AppointmentStatus = "cancelled" // 已取消
+)
+
+// ValidAppointmentStatuses returns all valid appointment statuses
+func ValidAppointmentStatuses() []string {
+	return []string{
+		string(AppointmentStatusBooked),
+		string(AppointmentStatusCompleted),
+		string(AppointmentStatusCancelled),
+	}
+}
+
+// CustomerPackageStatus defines valid customer package status values
+type CustomerPackageStatus string
+
+const (
+	CustomerPackageStatusActive  CustomerPackageStatus = "active"  // 可用
+	CustomerPackageStatusRevoked CustomerPackageStatus = "revoked" // 已退款作废
+)
+
+// PackageTxType defines valid package ledger transaction types
+type PackageTxType string
+
+const (
+	PackageTxTypePurchase PackageTxType = "purchase" // 购买入账
+	PackageTxTypeRedeem   PackageTxType = "redeem"   // 下单抵扣
+	PackageTxTypeRestore  PackageTxType = "restore"  // 抵扣订单取消或退款，次数退回
+	PackageTxTypeRevoke   PackageTxType = "revoke"   // 套餐退款，次数扣回
+)
+
+// CommissionRuleScope defines valid commission rule scopes
+type CommissionRuleScope string
+
+const (
+	CommissionRuleScopeProduct  CommissionRuleScope = "product"  // 指定产品，优先级最高
+	CommissionRuleScopeCategory CommissionRuleScope = "category" // 指定产品分类
+	CommissionRuleScopeDefault  CommissionRuleScope = "default"  // 默认规则，兜底
+)
+
+// CommissionRuleType defines valid commission calculation types
+type CommissionRuleType string
+
+const (
+	CommissionRuleTypePercent CommissionRuleType = "percent" // 按提成基数比例，值为万分比
+	CommissionRuleTypeFixed   CommissionRuleType = "fixed"   // 按件固定金额，值为分
+)
+
+// CommissionEntryType defines valid commission ledger entry types
+type CommissionEntryType string
+
+const (
+	CommissionEntryTypeAccrual  CommissionEntryType = "accrual"  // 订单支付计提
+	CommissionEntryTypeReversal CommissionEntryType = "reversal" // 订单退款冲回
+)
+
 // MarketingExecutionType defines valid marketing execution types
 type MarketingExecutionType string
 
@@ -196,6 +333,12 @@ func ValidMarketingExecutionTypes() []string {
 
 // ================ Wallet Related Constants ================
 
+// WalletStatus 钱包状态，与 wallets.status 取值一致
+const (
+	WalletStatusFrozen int32 = 0 // 冻结：禁止充值和消费，订单退款仍可入账
+	WalletStatusNormal int32 = 1 // 正常
+)
+
 // WalletTransactionType defines valid wallet transaction types
 type WalletTransactionType string
 
@@ -205,6 +348,48 @@ const (
 	WalletTransactionTypeRefund   WalletTransactionType = "refund"
 )
 
+// 钱包流水类型，与 wallet_transactions.type 取值一致
+// 赠送资金使用独立类型入账和收回，报表据此区分实付资金与赠送资金
+const (
+	WalletTxTypeRecharge       = "recharge"        // 充值本金
+	WalletTxTypeRechargeBonus  = "recharge_bonus"  // 充值赠送
+	WalletTxTypeRechargeRefund = "recharge_refund" // 退回充值本金
+	WalletTxTypeBonusClawback  = "bonus_clawback"  // 收回充值赠送
+	WalletTxTypeTransferIn     = "transfer_in"     // 转账转入
+	WalletTxTypeTransferOut    = "transfer_out"    // 转账转出
+	WalletTxTypeReversal       = "reversal"        // 冲正：方向与原流水相反，biz_ref_id 为原流水ID
+)
+
+// WalletReversalReason 冲正原因代码，写入冲正流水的 reason_code
+type WalletReversalReason string
+
+const (
+	WalletReversalReasonWrongAmount    WalletReversalReason = "wrong_amount"    // 金额录入错误
+	WalletReversalReasonWrongCustomer  WalletReversalReason = "wrong_customer"  // 客户选择错误
+	WalletReversalReasonWrongType      WalletReversalReason = "wrong_type"      // 交易类型错误
+	WalletReversalReasonDuplicateEntry WalletReversalReason = "duplicate_entry" // 重复录入
+	WalletReversalReasonOther          WalletReversalReason = "other"           // 其他原因，需在备注中说明
+)
+
+// ValidWalletReversalReasons returns all valid wallet reversal reason codes
+func ValidWalletReversalReasons() []string {
+	return []string{
+		string(WalletReversalReasonWrongAmount),
+		string(WalletReversalReasonWrongCustomer),
+		string(WalletReversalReasonWrongType),
+		string(WalletReversalReasonDuplicateEntry),
+		string(WalletReversalReasonOther),
+	}
+}
+
+// RechargeStatus 充值记录状态
+type RechargeStatus string
+
+const (
+	RechargeStatusCompleted RechargeStatus = "completed" // 已到账
+	RechargeStatusRefunded  RechargeStatus = "refunded"  // 已退回（本金与赠送均已扣回）
+)
+
 // ValidWalletTransactionTypes returns all valid wallet transaction types
 func ValidWalletTransactionTypes() []string {
 	return []string{
@@ -264,6 +449,7 @@ type ProductType string
 const (
 	ProductTypeProduct ProductType = "product"
 	ProductTypeService ProductType = "service"
+	ProductTypePackage ProductType = "package" // 次卡套餐，购买后按次抵扣服务
 )
 
 // ValidProductTypes returns all valid product types
@@ -271,5 +457,6 @@ func ValidProductTypes() []string {
 	return []string{
 		string(ProductTypeProduct),
 		string(ProductTypeService),
+		string(ProductTypePackage),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,420 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/domains/crm/impl"
+	identityImpl "crm_lite/internal/domains/identity/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/internal/middleware"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ActivityController 负责处理客户活动与跟进任务相关的 HTTP 请求
+// 访问控制与客户一致：super_admin、客户负责人本人及其上级可访问客户的活动
+type ActivityController struct {
+	activitySvc  crm.ActivityService
+	hierarchySvc *identityImpl.HierarchyServiceImpl
+	resManager   *resource.Manager
+}
+
+// NewActivityController 创建一个新的 ActivityController 实例
+func NewActivityController(rm *resource.Manager) *ActivityController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for ActivityController: " + err.Error())
+	}
+	return &ActivityController{
+		activitySvc:  impl.NewActivityService(dbRes.DB),
+		hierarchySvc: identityImpl.NewHierarchyService(rm),
+		resManager:   rm,
+	}
+}
+
+// CreateActivity godoc
+// @Summary      创建客户活动
+// @Description  为客户记录电话、会议、拜访、投诉等活动；带计划时间的活动即跟进任务，负责人缺省为当前用户
+// @Tags         Activities
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "客户ID"
+// @Param        activity body dto.ActivityCreateRequest true "活动信息"
+// @Success      201 {object} resp.Response{data=dto.ActivityResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "客户不存在"
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/activities [post]
+func (ac *ActivityController) CreateActivity(c *gin.Context) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.ActivityCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	operatorID, err := GetOperatorID(c, ac.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	createReq := crm.CreateActivityReq{
+		CustomerID: customerID,
+		ContactID:  req.ContactID,
+		Type:       req.Type,
+		Title:      req.Title,
+		Content:    req.Content,
+		Priority:   req.Priority,
+		AssignedTo: req.AssignedTo,
+		OperatorID: operatorID,
+	}
+	if req.ScheduledAt != nil {
+		createReq.ScheduledAt = *req.ScheduledAt
+	}
+	activity, err := ac.activitySvc.CreateActivity(c.Request.Context(), createReq)
+	if err != nil {
+		ac.handleActivityError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toActivityResponse(activity))
+}
+
+// ListCustomerActivities godoc
+// @Summary      获取客户活动列表
+// @Description  按创建时间倒序返回客户的活动
+// @Tags         Activities
+// @Produce      json
+// @Param        id path int true "客户ID"
+// @Param        page query int false "页码" default(1)
+// @Param        page_size query int false "每页大小" default(20)
+// @Param        type query string false "按类型筛选 (call/meeting/email/visit/follow_up/complaint/feedback)"
+// @Param        status query string false "按状态筛选 (planned/in_progress/completed/cancelled)"
+// @Success      200 {object} resp.Response{data=dto.ActivityListResponse}
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/activities [get]
+func (ac *ActivityController) ListCustomerActivities(c *gin.Context) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.ActivityListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	activities, total, err := ac.activitySvc.ListCustomerActivities(c.Request.Context(), crm.ListActivitiesReq{
+		CustomerID: customerID,
+		Type:       req.Type,
+		Status:     req.Status,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	})
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	resp.Success(c, toActivityListResponse(activities, total))
+}
+
+// ListMyOpenTasks godoc
+// @Summary      我的待办任务
+// @Description  返回当前用户负责的未完成活动，按计划时间正序，未安排时间的排在最后
+// @Tags         Activities
+// @Produce      json
+// @Param        page query int false "页码" default(1)
+// @Param        page_size query int false "每页大小" default(20)
+// @Success      200 {object} resp.Response{data=dto.ActivityListResponse}
+// @Security     ApiKeyAuth
+// @Router       /activities/my-tasks [get]
+func (ac *ActivityController) ListMyOpenTasks(c *gin.Context) {
+	var req dto.ActivityTaskListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	operatorID, err := GetOperatorID(c, ac.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	activities, total, err := ac.activitySvc.ListOpenTasks(c.Request.Context(), operatorID, req.Page, req.PageSize)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	resp.Success(c, toActivityListResponse(activities, total))
+}
+
+// ListOverdueActivities godoc
+// @Summary      逾期任务
+// @Description  返回计划时间已过仍未完成的活动，范围为当前用户及其下属负责的活动（super_admin 不限），按计划时间正序
+// @Tags         Activities
+// @Produce      json
+// @Param        page query int false "页码" default(1)
+// @Param        page_size query int false "每页大小" default(20)
+// @Success      200 {object} resp.Response{data=dto.ActivityListResponse}
+// @Security     ApiKeyAuth
+// @Router       /activities/overdue [get]
+func (ac *ActivityController) ListOverdueActivities(c *gin.Context) {
+	var req dto.ActivityTaskListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	operatorID, err := GetOperatorID(c, ac.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	var assignees []int64
+	if !isSuperAdmin(c) {
+		subordinates, err := ac.hierarchySvc.GetSubordinates(c.Request.Context(), operatorID)
+		if err != nil {
+			resp.SystemError(c, err)
+			return
+		}
+		assignees = append([]int64{operatorID}, subordinates...)
+	}
+
+	activities, total, err := ac.activitySvc.ListOverdueActivities(c.Request.Context(), assignees, time.Now(), req.Page, req.PageSize)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	resp.Success(c, toActivityListResponse(activities, total))
+}
+
+// GetActivity godoc
+// @Summary      获取活动详情
+// @Tags         Activities
+// @Produce      json
+// @Param        id path int true "活动ID"
+// @Success      200 {object} resp.Response{data=dto.ActivityResponse}
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "活动不存在"
+// @Security     ApiKeyAuth
+// @Router       /activities/{id} [get]
+func (ac *ActivityController) GetActivity(c *gin.Context) {
+	activity, ok := ac.loadAuthorizedActivity(c)
+	if !ok {
+		return
+	}
+	resp.Success(c, toActivityResponse(activity))
+}
+
+// UpdateActivity godoc
+// @Summary      更新活动
+// @Description  更新活动内容、计划时间、负责人或状态（planned/in_progress/cancelled）；已完成或已取消的活动不能修改
+// @Tags         Activities
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "活动ID"
+// @Param        activity body dto.ActivityUpdateRequest true "更新内容"
+// @Success      200 {object} resp.Response{data=dto.ActivityResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "活动不存在"
+// @Failure      409 {object} resp.Response "活动已完成或已取消"
+// @Security     ApiKeyAuth
+// @Router       /activities/{id} [put]
+func (ac *ActivityController) UpdateActivity(c *gin.Context) {
+	var req dto.ActivityUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	current, ok := ac.loadAuthorizedActivity(c)
+	if !ok {
+		return
+	}
+
+	activity, err := ac.activitySvc.UpdateActivity(c.Request.Context(), crm.UpdateActivityReq{
+		ID:          current.ID,
+		ContactID:   req.ContactID,
+		Type:        req.Type,
+		Title:       req.Title,
+		Content:     req.Content,
+		Status:      req.Status,
+		Priority:    req.Priority,
+		ScheduledAt: req.ScheduledAt,
+		AssignedTo:  req.AssignedTo,
+	})
+	if err != nil {
+		ac.handleActivityError(c, err)
+		return
+	}
+	resp.Success(c, toActivityResponse(activity))
+}
+
+// CompleteActivity godoc
+// @Summary      完成活动
+// @Description  将未完成的活动标记为已完成并记录完成时间，处理结果追加到活动内容
+// @Tags         Activities
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "活动ID"
+// @Param        body body dto.ActivityCompleteRequest false "处理结果"
+// @Success      200 {object} resp.Response{data=dto.ActivityResponse}
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "活动不存在"
+// @Failure      409 {object} resp.Response "活动已完成或已取消"
+// @Security     ApiKeyAuth
+// @Router       /activities/{id}/complete [post]
+func (ac *ActivityController) CompleteActivity(c *gin.Context) {
+	var req dto.ActivityCompleteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.Error(c, resp.CodeInvalidParam, err.Error())
+			return
+		}
+	}
+	current, ok := ac.loadAuthorizedActivity(c)
+	if !ok {
+		return
+	}
+
+	activity, err := ac.activitySvc.CompleteActivity(c.Request.Context(), current.ID, req.Result)
+	if err != nil {
+		ac.handleActivityError(c, err)
+		return
+	}
+	resp.Success(c, toActivityResponse(activity))
+}
+
+// DeleteActivity godoc
+// @Summary      删除活动
+// @Tags         Activities
+// @Produce      json
+// @Param        id path int true "活动ID"
+// @Success      204 {object} resp.Response
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "活动不存在"
+// @Security     ApiKeyAuth
+// @Router       /activities/{id} [delete]
+func (ac *ActivityController) DeleteActivity(c *gin.Context) {
+	current, ok := ac.loadAuthorizedActivity(c)
+	if !ok {
+		return
+	}
+	if err := ac.activitySvc.DeleteActivity(c.Request.Context(), current.ID); err != nil {
+		ac.handleActivityError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeNoContent, nil)
+}
+
+// loadAuthorizedActivity 读取路径中的活动，并按客户层级规则校验访问权限，失败时已写入响应
+func (ac *ActivityController) loadAuthorizedActivity(c *gin.Context) (*crm.Activity, bool) {
+	activityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的活动ID")
+		return nil, false
+	}
+	activity, err := ac.activitySvc.GetActivity(c.Request.Context(), activityID)
+	if err != nil {
+		ac.handleActivityError(c, err)
+		return nil, false
+	}
+
+	if isSuperAdmin(c) {
+		return activity, true
+	}
+	operatorID, err := GetOperatorID(c, ac.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return nil, false
+	}
+	allowed, err := ac.hierarchySvc.CanAccessCustomer(c.Request.Context(), operatorID, activity.CustomerID)
+	if err != nil {
+		resp.SystemError(c, err)
+		return nil, false
+	}
+	if !allowed {
+		resp.Error(c, resp.CodeForbidden, "无权访问该客户")
+		return nil, false
+	}
+	return activity, true
+}
+
+func (ac *ActivityController) handleActivityError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeActivityNotFound, common.ErrCodeCustomerNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeActivityStatusInvalid:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+// isSuperAdmin 当前用户是否拥有 super_admin 角色，与客户访问中间件的放行规则一致
+func isSuperAdmin(c *gin.Context) bool {
+	roles, _ := c.Get(middleware.ContextKeyRoles)
+	list, _ := roles.([]string)
+	for _, r := range list {
+		if r == "super_admin" {
+			return true
+		}
+	}
+	return false
+}
+
+func toActivityResponse(a *crm.Activity) *dto.ActivityResponse {
+	r := &dto.ActivityResponse{
+		ID:         a.ID,
+		CustomerID: a.CustomerID,
+		ContactID:  a.ContactID,
+		Type:       a.Type,
+		Title:      a.Title,
+		Content:    a.Content,
+		Status:     a.Status,
+		Priority:   a.Priority,
+		AssignedTo: a.AssignedTo,
+		CreatedBy:  a.CreatedBy,
+		CreatedAt:  time.Unix(a.CreatedAt, 0),
+		UpdatedAt:  time.Unix(a.UpdatedAt, 0),
+	}
+	if a.ScheduledAt > 0 {
+		scheduledAt := time.Unix(a.ScheduledAt, 0)
+		r.ScheduledAt = &scheduledAt
+		r.Overdue = crm.IsOpenActivityStatus(a.Status) && scheduledAt.Before(time.Now())
+	}
+	if a.CompletedAt > 0 {
+		completedAt := time.Unix(a.CompletedAt, 0)
+		r.CompletedAt = &completedAt
+	}
+	return r
+}
+
+func toActivityListResponse(activities []crm.Activity, total int64) *dto.ActivityListResponse {
+	result := &dto.ActivityListResponse{
+		Activities: make([]*dto.ActivityResponse, len(activities)),
+		Total:      total,
+	}
+	for i := range activities {
+		result.Activities[i] = toActivityResponse(&activities[i])
+	}
+	return result
+}
@@ -0,0 +1,526 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/core/resource"
+	salesImpl "crm_lite/internal/domains/sales/impl"
+	"crm_lite/internal/domains/scheduling"
+	"crm_lite/internal/domains/scheduling/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AppointmentController 负责处理员工排班与预约相关的 HTTP 请求
+type AppointmentController struct {
+	schedulingSvc scheduling.Service
+	resManager    *resource.Manager
+}
+
+// NewAppointmentController 创建一个新的 AppointmentController 实例
+func NewAppointmentController(rm *resource.Manager) *AppointmentController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for AppointmentController: " + err.Error())
+	}
+	schedulingSvc := impl.NewSchedulingService(dbRes.DB, salesImpl.ProvideSales(rm))
+
+	return &AppointmentController{
+		schedulingSvc: schedulingSvc,
+		resManager:    rm,
+	}
+}
+
+// ================ 员工排班 ================
+
+// SetStaffAvailability godoc
+// @Summary      设置员工每周可预约时间
+// @Description  整体替换员工每周可预约时间，已有预约不受影响
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Param        availability body dto.StaffAvailabilityRequest true "每周可预约时间"
+// @Success      200 {object} resp.Response{data=dto.StaffAvailabilityResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "员工不存在"
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/availability [put]
+func (ac *AppointmentController) SetStaffAvailability(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	var req dto.StaffAvailabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	rules := make([]scheduling.AvailabilityRule, len(req.Rules))
+	for i, r := range req.Rules {
+		rules[i] = scheduling.AvailabilityRule{Weekday: r.Weekday, StartTime: r.StartTime, EndTime: r.EndTime}
+	}
+	if err := ac.schedulingSvc.SetStaffAvailability(c.Request.Context(), staffID, rules); err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	ac.respondAvailability(c, staffID)
+}
+
+// GetStaffAvailability godoc
+// @Summary      获取员工每周可预约时间
+// @Tags         Appointments
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Success      200 {object} resp.Response{data=dto.StaffAvailabilityResponse}
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/availability [get]
+func (ac *AppointmentController) GetStaffAvailability(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	ac.respondAvailability(c, staffID)
+}
+
+func (ac *AppointmentController) respondAvailability(c *gin.Context, staffID int64) {
+	rules, err := ac.schedulingSvc.GetStaffAvailability(c.Request.Context(), staffID)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	result := &dto.StaffAvailabilityResponse{StaffID: staffID, Rules: make([]*dto.StaffAvailabilityRule, len(rules))}
+	for i, r := range rules {
+		result.Rules[i] = &dto.StaffAvailabilityRule{Weekday: r.Weekday, StartTime: r.StartTime, EndTime: r.EndTime}
+	}
+	resp.Success(c, result)
+}
+
+// AddStaffTimeOff godoc
+// @Summary      新增员工休息时段
+// @Description  休息时段内不可预约，与已有预约冲突时拒绝
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Param        timeOff body dto.StaffTimeOffRequest true "休息时段"
+// @Success      201 {object} resp.Response{data=dto.StaffTimeOffResponse}
+// @Failure      404 {object} resp.Response "员工不存在"
+// @Failure      409 {object} resp.Response "与已有预约冲突"
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/time-offs [post]
+func (ac *AppointmentController) AddStaffTimeOff(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	var req dto.StaffTimeOffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	timeOff, err := ac.schedulingSvc.AddStaffTimeOff(c.Request.Context(), scheduling.AddTimeOffRequest{
+		StaffID: staffID,
+		StartAt: req.StartAt,
+		EndAt:   req.EndAt,
+		Reason:  req.Reason,
+	})
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toStaffTimeOffResponse(timeOff))
+}
+
+// ListStaffTimeOffs godoc
+// @Summary      查询员工休息时段
+// @Tags         Appointments
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Param        from query string false "起始日期 (YYYY-MM-DD，含)"
+// @Param        to query string false "结束日期 (YYYY-MM-DD，含)"
+// @Success      200 {object} resp.Response{data=[]dto.StaffTimeOffResponse}
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/time-offs [get]
+func (ac *AppointmentController) ListStaffTimeOffs(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	var req dto.StaffTimeOffListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	to := req.To
+	if !to.IsZero() {
+		to = to.AddDate(0, 0, 1) // 结束日期按整天包含
+	}
+
+	timeOffs, err := ac.schedulingSvc.ListStaffTimeOffs(c.Request.Context(), staffID, req.From, to)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	result := make([]*dto.StaffTimeOffResponse, len(timeOffs))
+	for i := range timeOffs {
+		result[i] = toStaffTimeOffResponse(&timeOffs[i])
+	}
+	resp.Success(c, result)
+}
+
+// DeleteStaffTimeOff godoc
+// @Summary      删除员工休息时段
+// @Tags         Appointments
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Param        timeOffId path int true "休息时段ID"
+// @Success      200 {object} resp.Response
+// @Failure      404 {object} resp.Response "休息时段不存在"
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/time-offs/{timeOffId} [delete]
+func (ac *AppointmentController) DeleteStaffTimeOff(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	timeOffID, err := strconv.ParseInt(c.Param("timeOffId"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的休息时段ID")
+		return
+	}
+
+	if err := ac.schedulingSvc.DeleteStaffTimeOff(c.Request.Context(), staffID, timeOffID); err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.Success(c, nil)
+}
+
+// ================ 预约 ================
+
+// ListAvailableSlots godoc
+// @Summary      查询可预约时段
+// @Description  按服务产品时长计算员工某天的可预约时段，已排除休息、已有预约和已过去的时间
+// @Tags         Appointments
+// @Produce      json
+// @Param        staff_id query int true "员工ID"
+// @Param        product_id query int true "服务产品ID"
+// @Param        date query string true "日期 (YYYY-MM-DD)"
+// @Success      200 {object} resp.Response{data=[]dto.AppointmentSlotResponse}
+// @Failure      400 {object} resp.Response "产品不可预约"
+// @Security     ApiKeyAuth
+// @Router       /appointments/slots [get]
+func (ac *AppointmentController) ListAvailableSlots(c *gin.Context) {
+	var req dto.AppointmentSlotRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	slots, err := ac.schedulingSvc.ListAvailableSlots(c.Request.Context(), scheduling.ListSlotsRequest{
+		StaffID:   req.StaffID,
+		ProductID: req.ProductID,
+		Date:      req.Date,
+	})
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	result := make([]*dto.AppointmentSlotResponse, len(slots))
+	for i, s := range slots {
+		result[i] = &dto.AppointmentSlotResponse{StartAt: time.Unix(s.StartAt, 0), EndAt: time.Unix(s.EndAt, 0)}
+	}
+	resp.Success(c, result)
+}
+
+// BookAppointment godoc
+// @Summary      预约
+// @Description  为客户预约员工的服务时段，时长取自服务产品，与员工其他预约或休息冲突时拒绝
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        appointment body dto.AppointmentCreateRequest true "预约信息"
+// @Success      201 {object} resp.Response{data=dto.AppointmentResponse}
+// @Failure      400 {object} resp.Response "请求参数错误或产品不可预约"
+// @Failure      404 {object} resp.Response "客户、员工或产品不存在"
+// @Failure      409 {object} resp.Response "时段冲突或不在员工可预约时间内"
+// @Security     ApiKeyAuth
+// @Router       /appointments [post]
+func (ac *AppointmentController) BookAppointment(c *gin.Context) {
+	var req dto.AppointmentCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(c, ac.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	appointment, err := ac.schedulingSvc.BookAppointment(c.Request.Context(), scheduling.BookAppointmentRequest{
+		CustomerID: req.CustomerID,
+		StaffID:    req.StaffID,
+		ProductID:  req.ProductID,
+		StartAt:    req.StartAt,
+		Remark:     req.Remark,
+		OperatorID: operatorID,
+	})
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toAppointmentResponse(appointment))
+}
+
+// ListAppointments godoc
+// @Summary      获取预约列表
+// @Tags         Appointments
+// @Produce      json
+// @Param        page query int false "页码" default(1)
+// @Param        page_size query int false "每页大小" default(20)
+// @Param        staff_id query int false "按员工筛选"
+// @Param        customer_id query int false "按客户筛选"
+// @Param        status query string false "按状态筛选 (booked/completed/cancelled)"
+// @Param        start_date query string false "预约日期起 (YYYY-MM-DD，含)"
+// @Param        end_date query string false "预约日期止 (YYYY-MM-DD，含)"
+// @Success      200 {object} resp.Response{data=dto.AppointmentListResponse}
+// @Security     ApiKeyAuth
+// @Router       /appointments [get]
+func (ac *AppointmentController) ListAppointments(c *gin.Context) {
+	var req dto.AppointmentListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	listReq := scheduling.ListAppointmentsRequest{
+		StaffID:    req.StaffID,
+		CustomerID: req.CustomerID,
+		Status:     req.Status,
+		From:       req.StartDate,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+	}
+	if !req.EndDate.IsZero() {
+		listReq.To = req.EndDate.AddDate(0, 0, 1) // 结束日期按整天包含
+	}
+
+	appointments, total, err := ac.schedulingSvc.ListAppointments(c.Request.Context(), listReq)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	result := &dto.AppointmentListResponse{
+		Appointments: make([]*dto.AppointmentResponse, len(appointments)),
+		Total:        total,
+	}
+	for i := range appointments {
+		result.Appointments[i] = toAppointmentResponse(&appointments[i])
+	}
+	resp.Success(c, result)
+}
+
+// GetAppointment godoc
+// @Summary      获取预约详情
+// @Tags         Appointments
+// @Produce      json
+// @Param        id path int true "预约ID"
+// @Success      200 {object} resp.Response{data=dto.AppointmentResponse}
+// @Failure      404 {object} resp.Response "预约不存在"
+// @Security     ApiKeyAuth
+// @Router       /appointments/{id} [get]
+func (ac *AppointmentController) GetAppointment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的预约ID")
+		return
+	}
+
+	appointment, err := ac.schedulingSvc.GetAppointment(c.Request.Context(), id)
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.Success(c, toAppointmentResponse(appointment))
+}
+
+// RescheduleAppointment godoc
+// @Summary      改约
+// @Description  修改预约时间，可同时更换服务员工，冲突检测规则同预约
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "预约ID"
+// @Param        body body dto.AppointmentRescheduleRequest true "新的预约时间"
+// @Success      200 {object} resp.Response{data=dto.AppointmentResponse}
+// @Failure      404 {object} resp.Response "预约不存在"
+// @Failure      409 {object} resp.Response "时段冲突或预约已完成/取消"
+// @Security     ApiKeyAuth
+// @Router       /appointments/{id}/reschedule [post]
+func (ac *AppointmentController) RescheduleAppointment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的预约ID")
+		return
+	}
+	var req dto.AppointmentRescheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	appointment, err := ac.schedulingSvc.RescheduleAppointment(c.Request.Context(), scheduling.RescheduleAppointmentRequest{
+		AppointmentID: id,
+		StaffID:       req.StaffID,
+		StartAt:       req.StartAt,
+	})
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.Success(c, toAppointmentResponse(appointment))
+}
+
+// CancelAppointment godoc
+// @Summary      取消预约
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "预约ID"
+// @Param        body body dto.AppointmentCancelRequest false "取消原因"
+// @Success      200 {object} resp.Response{data=dto.AppointmentResponse}
+// @Failure      404 {object} resp.Response "预约不存在"
+// @Failure      409 {object} resp.Response "预约已完成或已取消"
+// @Security     ApiKeyAuth
+// @Router       /appointments/{id}/cancel [post]
+func (ac *AppointmentController) CancelAppointment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的预约ID")
+		return
+	}
+	var req dto.AppointmentCancelRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.Error(c, resp.CodeInvalidParam, err.Error())
+			return
+		}
+	}
+
+	appointment, err := ac.schedulingSvc.CancelAppointment(c.Request.Context(), id, req.Reason)
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.Success(c, toAppointmentResponse(appointment))
+}
+
+// CompleteAppointment godoc
+// @Summary      完成预约并生成订单
+// @Description  将预约的服务项目下单（订单负责人为服务员工），下单失败时预约保持未完成
+// @Tags         Appointments
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "预约ID"
+// @Param        body body dto.AppointmentCompleteRequest false "支付信息"
+// @Success      200 {object} resp.Response{data=dto.AppointmentResponse}
+// @Failure      404 {object} resp.Response "预约不存在"
+// @Failure      409 {object} resp.Response "预约已完成/取消，或余额不足、优惠券不可用、套餐次数不足"
+// @Security     ApiKeyAuth
+// @Router       /appointments/{id}/complete [post]
+func (ac *AppointmentController) CompleteAppointment(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的预约ID")
+		return
+	}
+	var req dto.AppointmentCompleteRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.Error(c, resp.CodeInvalidParam, err.Error())
+			return
+		}
+	}
+	if req.PayMethod == "" && len(req.Payments) == 0 {
+		req.PayMethod = string(constants.PaymentMethodWallet)
+	}
+
+	appointment, err := ac.schedulingSvc.CompleteAppointment(c.Request.Context(), scheduling.CompleteAppointmentRequest{
+		AppointmentID: id,
+		PayMethod:     req.PayMethod,
+		Payments:      toPaymentLines(req.Payments),
+		CouponCode:    req.CouponCode,
+		UsePackage:    req.UsePackage,
+	})
+	if err != nil {
+		handleAppointmentError(c, err)
+		return
+	}
+	resp.Success(c, toAppointmentResponse(appointment))
+}
+
+func handleAppointmentError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeAppointmentNotFound, common.ErrCodeResourceNotFound, common.ErrCodeCustomerNotFound,
+			common.ErrCodeProductNotFound, common.ErrCodeCouponNotFound, common.ErrCodePackageNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeAppointmentConflict, common.ErrCodeAppointmentStatusInvalid, common.ErrCodeStaffUnavailable,
+			common.ErrCodeInsufficientBalance, common.ErrCodeOrderOverpaid, common.ErrCodeCouponInvalid,
+			common.ErrCodeCouponLimitExceeded, common.ErrCodePackageInsufficient, common.ErrCodeWalletFrozen:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam, common.ErrCodeProductNotSellable:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toAppointmentResponse(a *scheduling.Appointment) *dto.AppointmentResponse {
+	return &dto.AppointmentResponse{
+		ID:           a.ID,
+		CustomerID:   a.CustomerID,
+		StaffID:      a.StaffID,
+		ProductID:    a.ProductID,
+		ProductName:  a.ProductName,
+		DurationMin:  a.DurationMin,
+		StartAt:      time.Unix(a.StartAt, 0),
+		EndAt:        time.Unix(a.EndAt, 0),
+		Status:       a.Status,
+		OrderID:      a.OrderID,
+		Remark:       a.Remark,
+		CancelReason: a.CancelReason,
+		CreatedAt:    time.Unix(a.CreatedAt, 0),
+		UpdatedAt:    time.Unix(a.UpdatedAt, 0),
+	}
+}
+
+func toStaffTimeOffResponse(t *scheduling.TimeOff) *dto.StaffTimeOffResponse {
+	return &dto.StaffTimeOffResponse{
+		ID:        t.ID,
+		StaffID:   t.StaffID,
+		StartAt:   time.Unix(t.StartAt, 0),
+		EndAt:     time.Unix(t.EndAt, 0),
+		Reason:    t.Reason,
+		CreatedAt: time.Unix(t.CreatedAt, 0),
+	}
+}
@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/commission"
+	"crm_lite/internal/domains/commission/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CommissionController 负责处理员工提成相关的 HTTP 请求
+type CommissionController struct {
+	commissionSvc commission.Service
+	resManager    *resource.Manager
+}
+
+// NewCommissionController 创建一个新的 CommissionController 实例
+func NewCommissionController(rm *resource.Manager) *CommissionController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for CommissionController: " + err.Error())
+	}
+
+	return &CommissionController{
+		commissionSvc: impl.NewCommissionService(dbRes.DB),
+		resManager:    rm,
+	}
+}
+
+// CreateRule godoc
+// @Summary      创建提成规则
+// @Description  规则按 产品 > 分类 > 默认 的优先级匹配；percent 的值为万分比，fixed 的值为每件金额（分）；分档按员工当月业绩生效
+// @Tags         Commissions
+// @Accept       json
+// @Produce      json
+// @Param        rule body dto.CommissionRuleRequest true "提成规则"
+// @Success      201 {object} resp.Response{data=dto.CommissionRuleResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      409 {object} resp.Response "该适用范围已有提成规则"
+// @Security     ApiKeyAuth
+// @Router       /commission-rules [post]
+func (cc *CommissionController) CreateRule(c *gin.Context) {
+	var req dto.CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	rule, err := cc.commissionSvc.CreateRule(c.Request.Context(), toSaveRuleRequest(&req))
+	if err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toCommissionRuleResponse(rule))
+}
+
+// ListRules godoc
+// @Summary      查询提成规则
+// @Tags         Commissions
+// @Produce      json
+// @Success      200 {object} resp.Response{data=[]dto.CommissionRuleResponse}
+// @Security     ApiKeyAuth
+// @Router       /commission-rules [get]
+func (cc *CommissionController) ListRules(c *gin.Context) {
+	rules, err := cc.commissionSvc.ListRules(c.Request.Context())
+	if err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	result := make([]*dto.CommissionRuleResponse, len(rules))
+	for i := range rules {
+		result[i] = toCommissionRuleResponse(&rules[i])
+	}
+	resp.Success(c, result)
+}
+
+// GetRule godoc
+// @Summary      获取提成规则
+// @Tags         Commissions
+// @Produce      json
+// @Param        id path int true "提成规则ID"
+// @Success      200 {object} resp.Response{data=dto.CommissionRuleResponse}
+// @Failure      404 {object} resp.Response "提成规则不存在"
+// @Security     ApiKeyAuth
+// @Router       /commission-rules/{id} [get]
+func (cc *CommissionController) GetRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的提成规则ID")
+		return
+	}
+
+	rule, err := cc.commissionSvc.GetRule(c.Request.Context(), id)
+	if err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	resp.Success(c, toCommissionRuleResponse(rule))
+}
+
+// UpdateRule godoc
+// @Summary      更新提成规则
+// @Description  分档整体替换；只影响之后计提的提成
+// @Tags         Commissions
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "提成规则ID"
+// @Param        rule body dto.CommissionRuleRequest true "提成规则"
+// @Success      200 {object} resp.Response{data=dto.CommissionRuleResponse}
+// @Failure      404 {object} resp.Response "提成规则不存在"
+// @Failure      409 {object} resp.Response "该适用范围已有提成规则"
+// @Security     ApiKeyAuth
+// @Router       /commission-rules/{id} [put]
+func (cc *CommissionController) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的提成规则ID")
+		return
+	}
+	var req dto.CommissionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	rule, err := cc.commissionSvc.UpdateRule(c.Request.Context(), id, toSaveRuleRequest(&req))
+	if err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	resp.Success(c, toCommissionRuleResponse(rule))
+}
+
+// DeleteRule godoc
+// @Summary      删除提成规则
+// @Tags         Commissions
+// @Produce      json
+// @Param        id path int true "提成规则ID"
+// @Success      200 {object} resp.Response
+// @Failure      404 {object} resp.Response "提成规则不存在"
+// @Security     ApiKeyAuth
+// @Router       /commission-rules/{id} [delete]
+func (cc *CommissionController) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的提成规则ID")
+		return
+	}
+
+	if err := cc.commissionSvc.DeleteRule(c.Request.Context(), id); err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	resp.Success(c, nil)
+}
+
+// GetStaffStatement godoc
+// @Summary      员工提成对账单
+// @Description  返回员工在结算周期内的提成明细与合计，计提按支付时间、冲回按退款时间归属周期
+// @Tags         Commissions
+// @Produce      json
+// @Param        id path int true "员工ID"
+// @Param        start_date query string true "结算周期起 (YYYY-MM-DD，含)"
+// @Param        end_date query string true "结算周期止 (YYYY-MM-DD，含)"
+// @Success      200 {object} resp.Response{data=dto.CommissionStatementResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Security     ApiKeyAuth
+// @Router       /staff/{id}/commissions [get]
+func (cc *CommissionController) GetStaffStatement(c *gin.Context) {
+	staffID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的员工ID")
+		return
+	}
+	var req dto.CommissionStatementRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	endAt := req.EndDate.AddDate(0, 0, 1) // 结束日期按整天包含
+
+	statement, err := cc.commissionSvc.GetStatement(c.Request.Context(), staffID, req.StartDate.Unix(), endAt.Unix())
+	if err != nil {
+		handleCommissionError(c, err)
+		return
+	}
+	result := &dto.CommissionStatementResponse{
+		StaffID:   statement.StaffID,
+		StartDate: req.StartDate.Format("2006-01-02"),
+		EndDate:   req.EndDate.Format("2006-01-02"),
+		Volume:    statement.Volume,
+		Accrued:   statement.Accrued,
+		Reversed:  statement.Reversed,
+		Net:       statement.Net,
+		Entries:   make([]*dto.CommissionEntryResponse, len(statement.Entries)),
+	}
+	for i, e := range statement.Entries {
+		result.Entries[i] = &dto.CommissionEntryResponse{
+			ID:          e.ID,
+			Type:        e.Type,
+			OrderID:     e.OrderID,
+			OrderItemID: e.OrderItemID,
+			ProductID:   e.ProductID,
+			RefundID:    e.RefundID,
+			RuleID:      e.RuleID,
+			RuleType:    e.RuleType,
+			RuleValue:   e.RuleValue,
+			Quantity:    e.Quantity,
+			BaseAmount:  e.BaseAmount,
+			Amount:      e.Amount,
+			OccurredAt:  time.Unix(e.OccurredAt, 0),
+		}
+	}
+	resp.Success(c, result)
+}
+
+// handleCommissionError 将员工提成域业务错误映射为 HTTP 响应
+func handleCommissionError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeCommissionRuleNotFound, common.ErrCodeProductNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeCommissionRuleConflict:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toSaveRuleRequest(req *dto.CommissionRuleRequest) commission.SaveRuleRequest {
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	tiers := make([]commission.RuleTier, len(req.Tiers))
+	for i, t := range req.Tiers {
+		tiers[i] = commission.RuleTier{MinVolume: t.MinVolume, Value: t.Value}
+	}
+	return commission.SaveRuleRequest{
+		Name:      req.Name,
+		Scope:     req.Scope,
+		ProductID: req.ProductID,
+		Category:  req.Category,
+		Type:      req.Type,
+		Value:     req.Value,
+		IsActive:  isActive,
+		Tiers:     tiers,
+	}
+}
+
+func toCommissionRuleResponse(r *commission.Rule) *dto.CommissionRuleResponse {
+	tiers := make([]*dto.CommissionRuleTierResponse, len(r.Tiers))
+	for i, t := range r.Tiers {
+		tiers[i] = &dto.CommissionRuleTierResponse{MinVolume: t.MinVolume, Value: t.Value}
+	}
+	return &dto.CommissionRuleResponse{
+		ID:        r.ID,
+		Name:      r.Name,
+		Scope:     r.Scope,
+		ProductID: r.ProductID,
+		Category:  r.Category,
+		Type:      r.Type,
+		Value:     r.Value,
+		IsActive:  r.IsActive,
+		Tiers:     tiers,
+		CreatedAt: time.Unix(r.CreatedAt, 0),
+		UpdatedAt: time.Unix(r.UpdatedAt, 0),
+	}
+}
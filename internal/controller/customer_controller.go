@@ -2,6 +2,7 @@ package controller
 
 import (
 	"context"
+	"crm_lite/internal/common"
 	"crm_lite/internal/core/resource"
 	billingimpl "crm_lite/internal/domains/billing/impl"
 	"crm_lite/internal/domains/crm"
@@ -64,9 +65,14 @@ func (cc *CustomerController) CreateCustomer(c *gin.Context) {
 		Note:       dtoReq.Note,
 		Source:     dtoReq.Source,
 		AssignedTo: dtoReq.AssignedTo,
+
+		CustomFields: dtoReq.CustomFields,
 	}
 	customer, err := cc.customerService.CreateCustomerLegacy(c.Request.Context(), req)
 	if err != nil {
+		if isInvalidParamError(c, err) {
+			return
+		}
 		if errors.Is(err, crmimpl.ErrPhoneAlreadyExists) {
 			resp.Error(c, resp.CodeConflict, "phone number already exists")
 			return
@@ -83,6 +89,9 @@ func (cc *CustomerController) CreateCustomer(c *gin.Context) {
 // @Tags         Customers
 // @Produce      json
 // @Param        query query     dto.CustomerListRequest false "Query parameters"
+// @Param        custom_fields[key] query string false "Filter by custom field, e.g. custom_fields[hair_type]=curly (text: contains, multi_select: has option, others: exact match)"
+// @Param        tags query []string false "Filter by tags (case-insensitive against the tag dictionary)" collectionFormat(multi)
+// @Param        tag_match query string false "any (default) or all"
 // @Success      200  {object}  resp.Response{data=dto.CustomerListResponse}
 // @Failure      400  {object}  resp.Response
 // @Failure      500  {object}  resp.Response
@@ -102,9 +111,16 @@ func (cc *CustomerController) ListCustomers(c *gin.Context) {
 		Phone:    dtoReq.Phone,
 		Email:    dtoReq.Email,
 		OrderBy:  dtoReq.OrderBy,
+
+		CustomFields: c.QueryMap("custom_fields"),
+		Tags:         dtoReq.Tags,
+		TagMatchAll:  dtoReq.TagMatch == "all",
 	}
 	customers, err := cc.customerService.ListCustomersLegacy(c.Request.Context(), req)
 	if err != nil {
+		if isInvalidParamError(c, err) {
+			return
+		}
 		resp.Error(c, resp.CodeInternalError, "failed to list customers")
 		return
 	}
@@ -196,8 +212,13 @@ func (cc *CustomerController) UpdateCustomer(c *gin.Context) {
 		Note:       dtoReq.Note,
 		Source:     dtoReq.Source,
 		AssignedTo: dtoReq.AssignedTo,
+
+		CustomFields: dtoReq.CustomFields,
 	}
 	if err := cc.customerService.UpdateCustomerLegacy(c.Request.Context(), id, req); err != nil {
+		if isInvalidParamError(c, err) {
+			return
+		}
 		if errors.Is(err, crmimpl.ErrCustomerNotFound) {
 			resp.Error(c, resp.CodeNotFound, "customer not found")
 			return
@@ -233,3 +254,13 @@ func (cc *CustomerController) DeleteCustomer(c *gin.Context) {
 	}
 	resp.Success(c, nil)
 }
+
+// isInvalidParamError 业务参数错误（如自定义字段值不合法）时写入 400 响应并返回 true
+func isInvalidParamError(c *gin.Context, err error) bool {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeInvalidParam {
+		resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/domains/crm/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerFieldController 负责处理客户自定义字段定义相关的 HTTP 请求
+// 写操作仅限管理员，由权限中间件按路由控制
+type CustomerFieldController struct {
+	fieldSvc crm.CustomFieldService
+}
+
+// NewCustomerFieldController 创建一个新的 CustomerFieldController 实例
+func NewCustomerFieldController(rm *resource.Manager) *CustomerFieldController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for CustomerFieldController: " + err.Error())
+	}
+	return &CustomerFieldController{
+		fieldSvc: impl.NewCustomFieldService(dbRes.DB),
+	}
+}
+
+// CreateField godoc
+// @Summary      创建客户自定义字段
+// @Description  定义客户的扩展属性（如发质、车牌号、宠物名），类型为 text/number/date/enum/multi_select；
+// @Description  字段值通过客户接口的 custom_fields 以字段标识为键读写
+// @Tags         CustomerFields
+// @Accept       json
+// @Produce      json
+// @Param        field body dto.CustomerFieldCreateRequest true "字段定义"
+// @Success      201 {object} resp.Response{data=dto.CustomerFieldResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      409 {object} resp.Response "字段标识已存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-fields [post]
+func (fc *CustomerFieldController) CreateField(c *gin.Context) {
+	var req dto.CustomerFieldCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	field, err := fc.fieldSvc.CreateField(c.Request.Context(), crm.CreateCustomFieldReq{
+		Key:      req.Key,
+		Label:    req.Label,
+		Type:     req.Type,
+		Options:  req.Options,
+		Required: req.Required,
+		Sort:     req.Sort,
+	})
+	if err != nil {
+		fc.handleFieldError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toCustomerFieldResponse(field))
+}
+
+// ListFields godoc
+// @Summary      获取客户自定义字段列表
+// @Description  按排序返回全部字段定义
+// @Tags         CustomerFields
+// @Produce      json
+// @Success      200 {object} resp.Response{data=[]dto.CustomerFieldResponse}
+// @Security     ApiKeyAuth
+// @Router       /customer-fields [get]
+func (fc *CustomerFieldController) ListFields(c *gin.Context) {
+	fields, err := fc.fieldSvc.ListFields(c.Request.Context())
+	if err != nil {
+		fc.handleFieldError(c, err)
+		return
+	}
+	result := make([]*dto.CustomerFieldResponse, len(fields))
+	for i := range fields {
+		result[i] = toCustomerFieldResponse(&fields[i])
+	}
+	resp.Success(c, result)
+}
+
+// GetField godoc
+// @Summary      获取客户自定义字段
+// @Tags         CustomerFields
+// @Produce      json
+// @Param        id path int true "字段ID"
+// @Success      200 {object} resp.Response{data=dto.CustomerFieldResponse}
+// @Failure      404 {object} resp.Response "字段不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-fields/{id} [get]
+func (fc *CustomerFieldController) GetField(c *gin.Context) {
+	fieldID, ok := parseCustomerFieldID(c)
+	if !ok {
+		return
+	}
+	field, err := fc.fieldSvc.GetField(c.Request.Context(), fieldID)
+	if err != nil {
+		fc.handleFieldError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerFieldResponse(field))
+}
+
+// UpdateField godoc
+// @Summary      更新客户自定义字段
+// @Description  可修改名称、可选项、是否必填与排序；字段标识与类型不可修改
+// @Tags         CustomerFields
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "字段ID"
+// @Param        field body dto.CustomerFieldUpdateRequest true "更新内容"
+// @Success      200 {object} resp.Response{data=dto.CustomerFieldResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "字段不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-fields/{id} [put]
+func (fc *CustomerFieldController) UpdateField(c *gin.Context) {
+	fieldID, ok := parseCustomerFieldID(c)
+	if !ok {
+		return
+	}
+	var req dto.CustomerFieldUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	field, err := fc.fieldSvc.UpdateField(c.Request.Context(), crm.UpdateCustomFieldReq{
+		ID:       fieldID,
+		Label:    req.Label,
+		Options:  req.Options,
+		Required: req.Required,
+		Sort:     req.Sort,
+	})
+	if err != nil {
+		fc.handleFieldError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerFieldResponse(field))
+}
+
+// DeleteField godoc
+// @Summary      删除客户自定义字段
+// @Description  同时删除全部客户的该字段值
+// @Tags         CustomerFields
+// @Produce      json
+// @Param        id path int true "字段ID"
+// @Success      204 {object} resp.Response
+// @Failure      404 {object} resp.Response "字段不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-fields/{id} [delete]
+func (fc *CustomerFieldController) DeleteField(c *gin.Context) {
+	fieldID, ok := parseCustomerFieldID(c)
+	if !ok {
+		return
+	}
+	if err := fc.fieldSvc.DeleteField(c.Request.Context(), fieldID); err != nil {
+		fc.handleFieldError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeNoContent, nil)
+}
+
+func (fc *CustomerFieldController) handleFieldError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeCustomFieldNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeCustomFieldExists:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+// parseCustomerFieldID 解析路径中的字段ID，失败时已写入响应
+func parseCustomerFieldID(c *gin.Context) (int64, bool) {
+	fieldID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的字段ID")
+		return 0, false
+	}
+	return fieldID, true
+}
+
+func toCustomerFieldResponse(f *crm.CustomField) *dto.CustomerFieldResponse {
+	return &dto.CustomerFieldResponse{
+		ID:        f.ID,
+		Key:       f.Key,
+		Label:     f.Label,
+		Type:      f.Type,
+		Options:   f.Options,
+		Required:  f.Required,
+		Sort:      f.Sort,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
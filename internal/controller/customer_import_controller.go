@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/domains/crm/impl"
+	identityImpl "crm_lite/internal/domains/identity/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customerImportMaxFileSize 导入文件大小上限
+const customerImportMaxFileSize = 10 << 20
+
+// CustomerImportController 负责处理客户批量导入相关的 HTTP 请求
+type CustomerImportController struct {
+	importSvc    crm.CustomerImportService
+	hierarchySvc *identityImpl.HierarchyServiceImpl
+	resManager   *resource.Manager
+}
+
+// NewCustomerImportController 创建一个新的 CustomerImportController 实例
+func NewCustomerImportController(rm *resource.Manager) *CustomerImportController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for CustomerImportController: " + err.Error())
+	}
+	return &CustomerImportController{
+		importSvc:    impl.NewCustomerImportServiceFromDB(dbRes.DB),
+		hierarchySvc: identityImpl.NewHierarchyService(rm),
+		resManager:   rm,
+	}
+}
+
+// ImportCustomers godoc
+// @Summary      批量导入客户
+// @Description  上传 CSV 或 XLSX 文件（首行为表头，支持 name/phone/email/gender/birthday/level/tags/note/source/assigned_to/opening_balance 及对应中文表头），
+// @Description  按手机号新建或更新客户，可选入账期初余额（元，每个客户只入账一次，已入账过的客户再次填写时该行失败）；未填写负责人的客户分配给当前用户；
+// @Description  已存在的客户须有访问权限才会更新。dry_run=true 时只校验并返回报告，不写入数据
+// @Tags         Customers
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        file formData file true "导入文件（.csv/.xlsx）"
+// @Param        dry_run formData bool false "试运行"
+// @Success      200 {object} resp.Response{data=dto.CustomerImportResponse}
+// @Failure      400 {object} resp.Response "文件格式错误或缺少必需列"
+// @Security     ApiKeyAuth
+// @Router       /customers/import [post]
+func (ic *CustomerImportController) ImportCustomers(c *gin.Context) {
+	var req dto.CustomerImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "请上传导入文件")
+		return
+	}
+	if fileHeader.Size > customerImportMaxFileSize {
+		resp.Error(c, resp.CodeInvalidParam, "导入文件不能超过 10MB")
+		return
+	}
+	format := crm.ImportFormatFromFilename(fileHeader.Filename)
+	if format == "" {
+		resp.Error(c, resp.CodeInvalidParam, "仅支持 .csv 或 .xlsx 文件")
+		return
+	}
+	operatorID, err := GetOperatorID(c, ic.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	opts := crm.CustomerImportOptions{
+		Format:            format,
+		DryRun:            req.DryRun,
+		OperatorID:        operatorID,
+		DefaultAssignedTo: operatorID,
+	}
+	if !isSuperAdmin(c) {
+		opts.Authorize = func(ctx context.Context, customerID int64) (bool, error) {
+			return ic.hierarchySvc.CanAccessCustomer(ctx, operatorID, customerID)
+		}
+	}
+	report, err := ic.importSvc.ImportCustomers(c.Request.Context(), file, opts)
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeInvalidParam {
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+		resp.SystemError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerImportResponse(report))
+}
+
+func toCustomerImportResponse(r *crm.CustomerImportReport) *dto.CustomerImportResponse {
+	result := &dto.CustomerImportResponse{
+		DryRun:         r.DryRun,
+		Total:          r.Total,
+		Created:        r.Created,
+		Updated:        r.Updated,
+		Failed:         r.Failed,
+		OpeningBalance: float64(r.OpeningBalance) / 100,
+		IgnoredColumns: r.IgnoredColumns,
+		Rows:           make([]*dto.CustomerImportRowResponse, len(r.Rows)),
+	}
+	for i, row := range r.Rows {
+		result.Rows[i] = &dto.CustomerImportRowResponse{
+			Row:            row.Row,
+			Name:           row.Name,
+			Phone:          row.Phone,
+			Action:         row.Action,
+			CustomerID:     row.CustomerID,
+			OpeningBalance: float64(row.OpeningBalance) / 100,
+			Errors:         row.Errors,
+		}
+	}
+	return result
+}
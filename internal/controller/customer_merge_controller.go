@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/domains/crm/impl"
+	identityImpl "crm_lite/internal/domains/identity/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerMergeController 负责处理重复客户识别与合并相关的 HTTP 请求
+// 路径中的客户由客户访问中间件校验；疑似重复客户与被合并客户同样按客户层级规则过滤
+type CustomerMergeController struct {
+	mergeSvc     crm.CustomerMergeService
+	hierarchySvc *identityImpl.HierarchyServiceImpl
+	resManager   *resource.Manager
+}
+
+// NewCustomerMergeController 创建一个新的 CustomerMergeController 实例
+func NewCustomerMergeController(rm *resource.Manager) *CustomerMergeController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for CustomerMergeController: " + err.Error())
+	}
+	return &CustomerMergeController{
+		mergeSvc:     impl.NewCustomerMergeServiceFromDB(dbRes.DB),
+		hierarchySvc: identityImpl.NewHierarchyService(rm),
+		resManager:   rm,
+	}
+}
+
+// FindDuplicates godoc
+// @Summary      查找疑似重复客户
+// @Description  按规范化手机号、邮箱及姓名相似度查找与该客户疑似重复的客户，按得分降序；只返回当前用户可访问的客户
+// @Tags         Customers
+// @Produce      json
+// @Param        id path int true "客户ID"
+// @Param        limit query int false "最多返回条数" default(20)
+// @Success      200 {object} resp.Response{data=[]dto.DuplicateCandidateResponse}
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "客户不存在"
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/duplicates [get]
+func (mc *CustomerMergeController) FindDuplicates(c *gin.Context) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var query dto.DuplicateQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	candidates, err := mc.mergeSvc.FindDuplicates(c.Request.Context(), customerID, query.Limit)
+	if err != nil {
+		mc.handleMergeError(c, err)
+		return
+	}
+
+	result := make([]*dto.DuplicateCandidateResponse, 0, len(candidates))
+	for _, candidate := range candidates {
+		allowed, ok := mc.canAccessCustomer(c, candidate.Customer.ID)
+		if !ok {
+			return
+		}
+		if !allowed {
+			continue
+		}
+		result = append(result, &dto.DuplicateCandidateResponse{
+			CustomerID:     candidate.Customer.ID,
+			Name:           candidate.Customer.Name,
+			Phone:          candidate.Customer.Phone,
+			Email:          candidate.Customer.Email,
+			AssignedTo:     candidate.Customer.AssignedTo,
+			Reasons:        candidate.Reasons,
+			NameSimilarity: candidate.NameSimilarity,
+			Score:          candidate.Score,
+		})
+	}
+	resp.Success(c, result)
+}
+
+// MergeCustomer godoc
+// @Summary      合并重复客户
+// @Description  将被合并客户的联系人、订单、活动、营销记录、服务套餐、预约、优惠券核销及钱包余额并入路径中的客户，并删除被合并客户（之后不会再按手机号恢复）；任一方钱包冻结时需先解冻
+// @Tags         Customers
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "保留的客户ID"
+// @Param        merge body dto.CustomerMergeRequest true "被合并客户"
+// @Success      201 {object} resp.Response{data=dto.CustomerMergeResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Failure      404 {object} resp.Response "客户不存在"
+// @Failure      409 {object} resp.Response "钱包已冻结"
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/merge [post]
+func (mc *CustomerMergeController) MergeCustomer(c *gin.Context) {
+	survivorID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.CustomerMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	operatorID, err := GetOperatorID(c, mc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+	allowed, ok := mc.canAccessCustomer(c, req.LoserID)
+	if !ok {
+		return
+	}
+	if !allowed {
+		resp.Error(c, resp.CodeForbidden, "无权访问被合并的客户")
+		return
+	}
+
+	merge, err := mc.mergeSvc.MergeCustomers(c.Request.Context(), crm.MergeCustomersReq{
+		SurvivorID: survivorID,
+		LoserID:    req.LoserID,
+		OperatorID: operatorID,
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		mc.handleMergeError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toCustomerMergeResponse(merge))
+}
+
+// ListMerges godoc
+// @Summary      获取客户合并记录
+// @Description  按时间倒序返回并入该客户的合并记录
+// @Tags         Customers
+// @Produce      json
+// @Param        id path int true "客户ID"
+// @Success      200 {object} resp.Response{data=[]dto.CustomerMergeResponse}
+// @Failure      403 {object} resp.Response "无权访问该客户"
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/merges [get]
+func (mc *CustomerMergeController) ListMerges(c *gin.Context) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	merges, err := mc.mergeSvc.ListMerges(c.Request.Context(), customerID)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+	result := make([]*dto.CustomerMergeResponse, len(merges))
+	for i := range merges {
+		result[i] = toCustomerMergeResponse(&merges[i])
+	}
+	resp.Success(c, result)
+}
+
+// canAccessCustomer 按客户层级规则判断当前用户能否访问客户，第二个返回值为 false 时已写入错误响应
+func (mc *CustomerMergeController) canAccessCustomer(c *gin.Context, customerID int64) (bool, bool) {
+	if isSuperAdmin(c) {
+		return true, true
+	}
+	operatorID, err := GetOperatorID(c, mc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return false, false
+	}
+	allowed, err := mc.hierarchySvc.CanAccessCustomer(c.Request.Context(), operatorID, customerID)
+	if err != nil {
+		resp.SystemError(c, err)
+		return false, false
+	}
+	return allowed, true
+}
+
+func (mc *CustomerMergeController) handleMergeError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeCustomerNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeWalletFrozen, common.ErrCodeInsufficientBalance:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toCustomerMergeResponse(m *crm.CustomerMerge) *dto.CustomerMergeResponse {
+	return &dto.CustomerMergeResponse{
+		ID:                       m.ID,
+		SurvivorID:               m.SurvivorID,
+		LoserID:                  m.LoserID,
+		MovedContacts:            m.MovedContacts,
+		MovedOrders:              m.MovedOrders,
+		MovedActivities:          m.MovedActivities,
+		MovedMarketingRecords:    m.MovedMarketingRecords,
+		MovedPackages:            m.MovedPackages,
+		MovedPackageTransactions: m.MovedPackageTransactions,
+		MovedAppointments:        m.MovedAppointments,
+		MovedCouponRedemptions:   m.MovedCouponRedemptions,
+		WalletAmount:             float64(m.WalletAmount) / 100,
+		WalletTransferID:         m.WalletTransferID,
+		LoserSnapshot:            m.LoserSnapshot,
+		OperatorID:               m.OperatorID,
+		Reason:                   m.Reason,
+		CreatedAt:                time.Unix(m.CreatedAt, 0),
+	}
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/domains/crm/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomerTagController 负责处理客户标签字典与批量打标签相关的 HTTP 请求
+// 写操作仅限管理员，由权限中间件按路由控制
+type CustomerTagController struct {
+	tagSvc crm.CustomerTagService
+}
+
+// NewCustomerTagController 创建一个新的 CustomerTagController 实例
+func NewCustomerTagController(rm *resource.Manager) *CustomerTagController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for CustomerTagController: " + err.Error())
+	}
+	return &CustomerTagController{
+		tagSvc: impl.NewCustomerTagService(dbRes.DB),
+	}
+}
+
+// ListTags godoc
+// @Summary      获取客户标签字典
+// @Description  按分组与排序返回字典标签及使用该标签的客户数
+// @Tags         CustomerTags
+// @Produce      json
+// @Param        group query string false "按分组筛选"
+// @Success      200 {object} resp.Response{data=[]dto.CustomerTagResponse}
+// @Security     ApiKeyAuth
+// @Router       /customer-tags [get]
+func (tc *CustomerTagController) ListTags(c *gin.Context) {
+	tags, err := tc.tagSvc.ListTags(c.Request.Context(), c.Query("group"))
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	result := make([]*dto.CustomerTagResponse, len(tags))
+	for i := range tags {
+		result[i] = toCustomerTagResponse(&tags[i])
+	}
+	resp.Success(c, result)
+}
+
+// ListTagUsage godoc
+// @Summary      获取客户标签使用统计
+// @Description  按客户数倒序返回客户上实际使用的全部标签（区分大小写与空白），managed=false 的为字典外写法，可合并到字典标签
+// @Tags         CustomerTags
+// @Produce      json
+// @Success      200 {object} resp.Response{data=[]dto.CustomerTagUsageResponse}
+// @Security     ApiKeyAuth
+// @Router       /customer-tags/usage [get]
+func (tc *CustomerTagController) ListTagUsage(c *gin.Context) {
+	usage, err := tc.tagSvc.ListTagUsage(c.Request.Context())
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	result := make([]*dto.CustomerTagUsageResponse, len(usage))
+	for i, u := range usage {
+		result[i] = &dto.CustomerTagUsageResponse{Name: u.Name, Count: u.Count, Managed: u.Managed}
+	}
+	resp.Success(c, result)
+}
+
+// CreateTag godoc
+// @Summary      创建客户标签
+// @Description  标签名称忽略大小写与首尾空白唯一
+// @Tags         CustomerTags
+// @Accept       json
+// @Produce      json
+// @Param        tag body dto.CustomerTagCreateRequest true "标签信息"
+// @Success      201 {object} resp.Response{data=dto.CustomerTagResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      409 {object} resp.Response "标签已存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-tags [post]
+func (tc *CustomerTagController) CreateTag(c *gin.Context) {
+	var req dto.CustomerTagCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	tag, err := tc.tagSvc.CreateTag(c.Request.Context(), crm.CreateCustomerTagReq{
+		Name:  req.Name,
+		Color: req.Color,
+		Group: req.Group,
+		Sort:  req.Sort,
+	})
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	resp.SuccessWithCode(c, resp.CodeCreated, toCustomerTagResponse(tag))
+}
+
+// UpdateTag godoc
+// @Summary      更新客户标签
+// @Description  修改名称会同步改写所有客户上的该标签；新名称与其他标签重复时请使用合并接口
+// @Tags         CustomerTags
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "标签ID"
+// @Param        tag body dto.CustomerTagUpdateRequest true "更新内容"
+// @Success      200 {object} resp.Response{data=dto.CustomerTagResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "标签不存在"
+// @Failure      409 {object} resp.Response "标签已存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-tags/{id} [put]
+func (tc *CustomerTagController) UpdateTag(c *gin.Context) {
+	tagID, ok := parseCustomerTagID(c)
+	if !ok {
+		return
+	}
+	var req dto.CustomerTagUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	tag, err := tc.tagSvc.UpdateTag(c.Request.Context(), crm.UpdateCustomerTagReq{
+		ID:    tagID,
+		Name:  req.Name,
+		Color: req.Color,
+		Group: req.Group,
+		Sort:  req.Sort,
+	})
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerTagResponse(tag))
+}
+
+// DeleteTag godoc
+// @Summary      删除客户标签
+// @Description  从字典删除标签并从所有客户上移除
+// @Tags         CustomerTags
+// @Produce      json
+// @Param        id path int true "标签ID"
+// @Success      200 {object} resp.Response{data=dto.CustomerTagUpdateResultResponse}
+// @Failure      404 {object} resp.Response "标签不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-tags/{id} [delete]
+func (tc *CustomerTagController) DeleteTag(c *gin.Context) {
+	tagID, ok := parseCustomerTagID(c)
+	if !ok {
+		return
+	}
+	result, err := tc.tagSvc.DeleteTag(c.Request.Context(), tagID)
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerTagUpdateResultResponse(result))
+}
+
+// MergeTags godoc
+// @Summary      合并客户标签
+// @Description  将来源标签在所有客户上替换为路径中的目标标签并去重，字典中的来源标签随后删除；
+// @Description  来源按原样匹配，可用于归并 "vip "、"Vip" 等字典外写法
+// @Tags         CustomerTags
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "目标标签ID"
+// @Param        merge body dto.CustomerTagMergeRequest true "来源标签"
+// @Success      200 {object} resp.Response{data=dto.CustomerTagUpdateResultResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "标签不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-tags/{id}/merge [post]
+func (tc *CustomerTagController) MergeTags(c *gin.Context) {
+	tagID, ok := parseCustomerTagID(c)
+	if !ok {
+		return
+	}
+	var req dto.CustomerTagMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	result, err := tc.tagSvc.MergeTags(c.Request.Context(), crm.MergeCustomerTagsReq{TargetID: tagID, Sources: req.Sources})
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerTagUpdateResultResponse(result))
+}
+
+// BulkTagCustomers godoc
+// @Summary      批量打标签
+// @Description  为按条件筛选出的客户批量添加、移除标签，筛选条件与客户列表一致且至少指定一个；添加的标签须在字典中
+// @Tags         CustomerTags
+// @Accept       json
+// @Produce      json
+// @Param        bulk body dto.CustomerTagBulkRequest true "筛选条件与标签"
+// @Success      200 {object} resp.Response{data=dto.CustomerTagUpdateResultResponse}
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Security     ApiKeyAuth
+// @Router       /customer-tags/bulk [post]
+func (tc *CustomerTagController) BulkTagCustomers(c *gin.Context) {
+	var req dto.CustomerTagBulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	result, err := tc.tagSvc.BulkTagCustomers(c.Request.Context(), crm.BulkTagCustomersReq{
+		Filter: crm.CustomerListRequest{
+			IDs:          req.IDs,
+			Name:         req.Name,
+			Phone:        req.Phone,
+			Email:        req.Email,
+			CustomFields: req.CustomFields,
+			Tags:         req.Tags,
+			TagMatchAll:  req.TagMatch == "all",
+		},
+		Add:    req.Add,
+		Remove: req.Remove,
+	})
+	if err != nil {
+		tc.handleTagError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerTagUpdateResultResponse(result))
+}
+
+func (tc *CustomerTagController) handleTagError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeTagNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeTagExists:
+			resp.Error(c, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+// parseCustomerTagID 解析路径中的标签ID，失败时已写入响应
+func parseCustomerTagID(c *gin.Context) (int64, bool) {
+	tagID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的标签ID")
+		return 0, false
+	}
+	return tagID, true
+}
+
+func toCustomerTagResponse(t *crm.CustomerTag) *dto.CustomerTagResponse {
+	return &dto.CustomerTagResponse{
+		ID:         t.ID,
+		Name:       t.Name,
+		Color:      t.Color,
+		Group:      t.Group,
+		Sort:       t.Sort,
+		UsageCount: t.UsageCount,
+		CreatedAt:  t.CreatedAt,
+		UpdatedAt:  t.UpdatedAt,
+	}
+}
+
+func toCustomerTagUpdateResultResponse(r *crm.CustomerTagUpdateResult) *dto.CustomerTagUpdateResultResponse {
+	return &dto.CustomerTagUpdateResultResponse{Matched: r.Matched, Updated: r.Updated}
+}
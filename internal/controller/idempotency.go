@@ -1,8 +1,13 @@
 package controller
 
-import "github.com/gin-gonic/gin"
+import (
+	"crm_lite/internal/middleware"
 
-// GetIdempotencyKey 从请求头读取幂等键（预留给订单支付/退款收口使用）
+	"github.com/gin-gonic/gin"
+)
+
+// GetIdempotencyKey 从请求头读取幂等键
+// HTTP 层的重放由 middleware.IdempotencyMiddleware 处理，这里用于向领域服务透传业务幂等键
 func GetIdempotencyKey(c *gin.Context) string {
-	return c.GetHeader("Idempotency-Key")
+	return c.GetHeader(middleware.HeaderIdempotencyKey)
 }
@@ -1,12 +1,16 @@
 package controller
 
 import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
 	"crm_lite/internal/core/resource"
 	"crm_lite/internal/domains/marketing"
 	"crm_lite/internal/domains/marketing/impl"
 	"crm_lite/internal/dto"
 	"crm_lite/pkg/resp"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
@@ -17,6 +21,7 @@ import (
 // 已完全迁移到 marketing 域服务
 type MarketingController struct {
 	marketingSvc marketing.Service
+	resManager   *resource.Manager
 }
 
 // NewMarketingController 创建一个新的MarketingController实例
@@ -30,6 +35,7 @@ func NewMarketingController(resManager *resource.Manager) *MarketingController {
 
 	return &MarketingController{
 		marketingSvc: marketingSvc,
+		resManager:   resManager,
 	}
 }
 
@@ -539,3 +545,216 @@ func (mc *MarketingController) GetCustomerSegment(c *gin.Context) {
 	}
 	resp.Success(c, result)
 }
+
+// ================ 优惠券管理 ================
+
+// CreateCoupon godoc
+// @Summary      创建优惠券
+// @Description  创建优惠券定义（固定金额或按比例折扣），可关联营销活动用于订单来源归因
+// @Tags         Marketing
+// @Accept       json
+// @Produce      json
+// @Param        coupon body dto.CouponCreateRequest true "优惠券信息"
+// @Success      201 {object} resp.Response{data=dto.CouponResponse} "创建成功"
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "营销活动未找到"
+// @Failure      500 {object} resp.Response "服务器内部错误"
+// @Security     ApiKeyAuth
+// @Router       /marketing/coupons [post]
+func (mc *MarketingController) CreateCoupon(c *gin.Context) {
+	var req dto.CouponCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(c, mc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	// 固定金额券的优惠值为元，按比例折扣券为百分比
+	value := int64(math.Round(req.Value))
+	if req.Type == string(constants.CouponTypeFixed) {
+		value = yuanToCents(req.Value)
+	}
+
+	coupon, err := mc.marketingSvc.CreateCoupon(c.Request.Context(), marketing.CreateCouponRequest{
+		CampaignID:       req.CampaignID,
+		Name:             req.Name,
+		Type:             req.Type,
+		Value:            value,
+		MinSpend:         yuanToCents(req.MinSpend),
+		MaxDiscount:      yuanToCents(req.MaxDiscount),
+		ProductIDs:       req.ProductIDs,
+		Categories:       req.Categories,
+		ValidFrom:        req.ValidFrom,
+		ValidTo:          req.ValidTo,
+		PerCustomerLimit: req.PerCustomerLimit,
+	}, operatorID)
+	if err != nil {
+		handleCouponError(c, err)
+		return
+	}
+
+	resp.SuccessWithCode(c, resp.CodeCreated, toCouponResponse(coupon))
+}
+
+// GetCoupon godoc
+// @Summary      获取优惠券详情
+// @Description  根据ID获取优惠券定义及发放、核销数量
+// @Tags         Marketing
+// @Produce      json
+// @Param        id path string true "优惠券ID"
+// @Success      200 {object} resp.Response{data=dto.CouponResponse} "获取成功"
+// @Failure      404 {object} resp.Response "优惠券未找到"
+// @Failure      500 {object} resp.Response "服务器内部错误"
+// @Security     ApiKeyAuth
+// @Router       /marketing/coupons/{id} [get]
+func (mc *MarketingController) GetCoupon(c *gin.Context) {
+	couponID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "invalid coupon ID")
+		return
+	}
+
+	coupon, err := mc.marketingSvc.GetCoupon(c.Request.Context(), couponID)
+	if err != nil {
+		handleCouponError(c, err)
+		return
+	}
+
+	resp.Success(c, toCouponResponse(coupon))
+}
+
+// ListCoupons godoc
+// @Summary      获取优惠券列表
+// @Description  分页获取优惠券列表，可按营销活动过滤
+// @Tags         Marketing
+// @Produce      json
+// @Param        page query int false "页码" default(1)
+// @Param        page_size query int false "每页大小" default(10)
+// @Param        campaign_id query int false "营销活动ID"
+// @Success      200 {object} resp.Response{data=dto.CouponListResponse} "获取成功"
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      500 {object} resp.Response "服务器内部错误"
+// @Security     ApiKeyAuth
+// @Router       /marketing/coupons [get]
+func (mc *MarketingController) ListCoupons(c *gin.Context) {
+	var req dto.CouponListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	// 设置默认值
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+
+	coupons, total, err := mc.marketingSvc.ListCoupons(c.Request.Context(), req.CampaignID, req.Page, req.PageSize)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+
+	couponResponses := make([]*dto.CouponResponse, len(coupons))
+	for i := range coupons {
+		couponResponses[i] = toCouponResponse(&coupons[i])
+	}
+
+	resp.Success(c, &dto.CouponListResponse{
+		Coupons: couponResponses,
+		Total:   total,
+	})
+}
+
+// GenerateCouponCodes godoc
+// @Summary      批量生成券码
+// @Description  为优惠券批量生成一次性券码，单次最多 10000 个
+// @Tags         Marketing
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "优惠券ID"
+// @Param        body body dto.CouponCodeGenerateRequest true "生成数量及前缀"
+// @Success      200 {object} resp.Response{data=dto.CouponCodeGenerateResponse} "生成成功"
+// @Failure      400 {object} resp.Response "请求参数错误"
+// @Failure      404 {object} resp.Response "优惠券未找到"
+// @Failure      500 {object} resp.Response "服务器内部错误"
+// @Security     ApiKeyAuth
+// @Router       /marketing/coupons/{id}/codes [post]
+func (mc *MarketingController) GenerateCouponCodes(c *gin.Context) {
+	couponID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "invalid coupon ID")
+		return
+	}
+
+	var req dto.CouponCodeGenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	codes, err := mc.marketingSvc.GenerateCouponCodes(c.Request.Context(), couponID, req.Count, req.Prefix)
+	if err != nil {
+		handleCouponError(c, err)
+		return
+	}
+
+	resp.Success(c, &dto.CouponCodeGenerateResponse{
+		CouponID: couponID,
+		Codes:    codes,
+	})
+}
+
+// handleCouponError 将优惠券业务错误映射为响应码
+func handleCouponError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeCouponNotFound, common.ErrCodeResourceNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toCouponResponse(coupon *marketing.Coupon) *dto.CouponResponse {
+	value := float64(coupon.Value)
+	if coupon.Type == string(constants.CouponTypeFixed) {
+		value = float64(coupon.Value) / 100
+	}
+	return &dto.CouponResponse{
+		ID:               coupon.ID,
+		CampaignID:       coupon.CampaignID,
+		Name:             coupon.Name,
+		Type:             coupon.Type,
+		Value:            value,
+		MinSpend:         float64(coupon.MinSpend) / 100,
+		MaxDiscount:      float64(coupon.MaxDiscount) / 100,
+		ProductIDs:       coupon.ProductIDs,
+		Categories:       coupon.Categories,
+		ValidFrom:        coupon.ValidFrom,
+		ValidTo:          coupon.ValidTo,
+		PerCustomerLimit: coupon.PerCustomerLimit,
+		Status:           coupon.Status,
+		IssuedCount:      coupon.IssuedCount,
+		RedeemedCount:    coupon.RedeemedCount,
+		CreatedBy:        coupon.CreatedBy,
+		CreatedAt:        time.Unix(coupon.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	}
+}
+
+// yuanToCents 元转分
+func yuanToCents(yuan float64) int64 {
+	return int64(math.Round(yuan * 100))
+}
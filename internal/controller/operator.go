@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errOperatorNotFound 上下文中缺少或无法识别操作员身份
+var errOperatorNotFound = errors.New("无效的操作员身份")
+
+// GetOperatorID 从上下文中解析当前操作员的数值 ID
+// JWT 中的 user_id 可能是数值 ID，也可能是 UUID，UUID 时回查 admin_users
+func GetOperatorID(c *gin.Context, rm *resource.Manager) (int64, error) {
+	val, exists := c.Get(middleware.ContextKeyUserID)
+	if !exists {
+		return 0, errOperatorNotFound
+	}
+
+	switch v := val.(type) {
+	case int64:
+		return v, nil
+	case string:
+		if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return id, nil
+		}
+		dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+		if err != nil {
+			return 0, err
+		}
+		q := query.Use(dbRes.DB)
+		admin, err := q.AdminUser.WithContext(c.Request.Context()).Where(q.AdminUser.UUID.Eq(v)).First()
+		if err != nil {
+			return 0, errOperatorNotFound
+		}
+		return admin.ID, nil
+	default:
+		return 0, errOperatorNotFound
+	}
+}
@@ -1,11 +1,15 @@
 package controller
 
 import (
+	"crm_lite/internal/common"
 	"crm_lite/internal/core/resource"
 	"crm_lite/internal/domains/sales"
 	"crm_lite/internal/domains/sales/impl"
 	"crm_lite/internal/dto"
 	"crm_lite/pkg/resp"
+	"errors"
+	"math"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +19,7 @@ import (
 // 已完全迁移到 sales 域服务
 type OrderController struct {
 	salesService sales.Service
+	resManager   *resource.Manager
 }
 
 // NewOrderController 创建一个新的 OrderController 实例。
@@ -25,6 +30,7 @@ func NewOrderController(rm *resource.Manager) *OrderController {
 
 	return &OrderController{
 		salesService: salesService,
+		resManager:   rm,
 	}
 }
 
@@ -36,6 +42,8 @@ func NewOrderController(rm *resource.Manager) *OrderController {
 // @Produce json
 // @Param order body dto.OrderCreateRequest true "订单信息"
 // @Success 200 {object} resp.Response{data=dto.OrderResponse}
+// @Failure 404 {object} resp.Response "客户、产品或券码不存在"
+// @Failure 409 {object} resp.Response "库存或余额不足、优惠券不可用"
 // @Router /orders [post]
 func (cc *OrderController) CreateOrder(c *gin.Context) {
 	var req dto.OrderCreateRequest
@@ -54,14 +62,34 @@ func (cc *OrderController) CreateOrder(c *gin.Context) {
 	// 转换订单项
 	for i, item := range req.Items {
 		salesReq.Items[i] = sales.CreateOrderItemRequest{
-			ProductID: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     item.UnitPrice,
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+			Price:      item.UnitPrice,
+			UsePackage: item.UsePackage,
 		}
 	}
+	salesReq.Payments = toPaymentLines(req.Payments)
+	salesReq.CouponCode = req.CouponCode
 
 	order, err := cc.salesService.CreateOrder(c.Request.Context(), salesReq)
 	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) {
+			switch businessErr.Code {
+			case common.ErrCodeInsufficientStock, common.ErrCodeInsufficientBalance, common.ErrCodeOrderOverpaid,
+				common.ErrCodeCouponInvalid, common.ErrCodeCouponLimitExceeded, common.ErrCodePackageInsufficient,
+				common.ErrCodeWalletFrozen:
+				resp.Error(c, resp.CodeConflict, businessErr.Message)
+				return
+			case common.ErrCodeInvalidParam:
+				resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+				return
+			case common.ErrCodeCustomerNotFound, common.ErrCodeProductNotFound, common.ErrCodeCouponNotFound,
+				common.ErrCodePackageNotFound:
+				resp.Error(c, resp.CodeNotFound, businessErr.Message)
+				return
+			}
+		}
 		resp.SystemError(c, err)
 		return
 	}
@@ -74,7 +102,7 @@ func (cc *OrderController) CreateOrder(c *gin.Context) {
 		OrderDate:   time.Unix(order.CreatedAt, 0),
 		Status:      order.Status,
 		TotalAmount: order.TotalAmount, // Sales领域已经是float64
-		FinalAmount: order.TotalAmount, // 使用TotalAmount作为FinalAmount
+		FinalAmount: order.FinalAmount,
 		Items:       make([]*dto.OrderItemResponse, len(order.Items)),
 		CreatedAt:   time.Unix(order.CreatedAt, 0).Format("2006-01-02 15:04:05"),
 	}
@@ -117,7 +145,7 @@ func (cc *OrderController) GetOrder(c *gin.Context) {
 		OrderDate:   time.Unix(order.CreatedAt, 0),
 		Status:      order.Status,
 		TotalAmount: order.TotalAmount, // Sales领域已经是float64
-		FinalAmount: order.TotalAmount, // 使用TotalAmount作为FinalAmount
+		FinalAmount: order.FinalAmount,
 		Items:       make([]*dto.OrderItemResponse, len(order.Items)),
 		CreatedAt:   time.Unix(order.CreatedAt, 0).Format("2006-01-02 15:04:05"),
 	}
@@ -145,7 +173,16 @@ func (cc *OrderController) GetOrder(c *gin.Context) {
 // @Param page_size query int false "每页大小" default(10)
 // @Param customer_id query int false "按客户 ID 筛选"
 // @Param status query string false "按状态筛选"
-// @Param order_by query string false "排序字段 (e.g., order_date_desc)"
+// @Param assigned_to query int false "按负责员工 ID 筛选"
+// @Param payment_status query string false "按支付状态筛选"
+// @Param payment_method query string false "按支付方式筛选 (wallet/cash/online/card/mixed)"
+// @Param start_date query string false "下单日期起 (YYYY-MM-DD，含)"
+// @Param end_date query string false "下单日期止 (YYYY-MM-DD，含)"
+// @Param min_amount query number false "最终金额下限（元）"
+// @Param max_amount query number false "最终金额上限（元）"
+// @Param order_no query string false "订单号前缀"
+// @Param order_by query string false "排序 (order_date/final_amount/id + _asc/_desc)" default(order_date_desc)
+// @Param cursor query string false "游标，取自上一页的 next_cursor；传入后忽略 page 且不统计总数"
 // @Success 200 {object} resp.Response{data=dto.OrderListResponse}
 // @Router /orders [get]
 func (cc *OrderController) ListOrders(c *gin.Context) {
@@ -165,22 +202,41 @@ func (cc *OrderController) ListOrders(c *gin.Context) {
 
 	// 转换为Sales领域请求
 	salesReq := &sales.ListOrdersRequest{
-		CustomerID: req.CustomerID,
-		Status:     req.Status,
-		Page:       req.Page,
-		PageSize:   req.PageSize,
+		CustomerID:    req.CustomerID,
+		Status:        req.Status,
+		AssignedTo:    req.AssignedTo,
+		PaymentStatus: req.PaymentStatus,
+		PaymentMethod: req.PaymentMethod,
+		OrderDateFrom: req.StartDate,
+		MinAmount:     int64(math.Round(req.MinAmount * 100)),
+		MaxAmount:     int64(math.Round(req.MaxAmount * 100)),
+		OrderNoPrefix: req.OrderNo,
+		OrderBy:       req.OrderBy,
+		Cursor:        req.Cursor,
+		Page:          req.Page,
+		PageSize:      req.PageSize,
+	}
+	if !req.EndDate.IsZero() {
+		// 结束日期按整天包含
+		salesReq.OrderDateTo = req.EndDate.AddDate(0, 0, 1)
 	}
 
 	result, err := cc.salesService.ListOrdersForController(c.Request.Context(), salesReq)
 	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeInvalidParam {
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
 		resp.SystemError(c, err)
 		return
 	}
 
 	// 转换为DTO格式
 	orderListResponse := &dto.OrderListResponse{
-		Orders: make([]*dto.OrderResponse, len(result.Orders)),
-		Total:  result.Total,
+		Orders:     make([]*dto.OrderResponse, len(result.Orders)),
+		Total:      result.Total,
+		NextCursor: result.NextCursor,
 	}
 
 	for i, order := range result.Orders {
@@ -191,7 +247,7 @@ func (cc *OrderController) ListOrders(c *gin.Context) {
 			OrderDate:   time.Unix(order.CreatedAt, 0),
 			Status:      order.Status,
 			TotalAmount: order.TotalAmount, // Sales领域已经是float64
-			FinalAmount: order.TotalAmount, // 使用TotalAmount作为FinalAmount
+			FinalAmount: order.FinalAmount,
 			Items:       make([]*dto.OrderItemResponse, len(order.Items)),
 			CreatedAt:   time.Unix(order.CreatedAt, 0).Format("2006-01-02 15:04:05"),
 		}
@@ -210,3 +266,380 @@ func (cc *OrderController) ListOrders(c *gin.Context) {
 
 	resp.Success(c, orderListResponse)
 }
+
+// UpdateOrderStatus
+// @Summary 订单状态流转
+// @Description 按订单状态机流转订单状态，记录操作人与时间，并写入对应的 order.* 事件；流转到 refunded 时执行退款；paid 不能手工指定，订单在支付明细付清时自动流转
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Param body body dto.OrderStatusUpdateRequest true "目标状态"
+// @Success 200 {object} resp.Response{data=dto.OrderResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 409 {object} resp.Response "当前状态不允许流转"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/status [patch]
+func (cc *OrderController) UpdateOrderStatus(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	var req dto.OrderStatusUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(c, cc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	order, err := cc.salesService.UpdateOrderStatus(c.Request.Context(), sales.UpdateOrderStatusReq{
+		OrderID:    orderID,
+		Status:     req.Status,
+		OperatorID: operatorID,
+		Reason:     req.Reason,
+	})
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) {
+			switch businessErr.Code {
+			case common.ErrCodeOrderNotFound:
+				resp.Error(c, resp.CodeNotFound, "订单未找到")
+				return
+			case common.ErrCodeOrderStatusInvalid, common.ErrCodeOrderCannotRefund, common.ErrCodePackageUsed:
+				resp.Error(c, resp.CodeConflict, businessErr.Error())
+				return
+			case common.ErrCodeInvalidParam:
+				resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+				return
+			}
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, &dto.OrderResponse{
+		ID:          order.ID,
+		OrderNo:     order.OrderNo,
+		CustomerID:  order.CustomerID,
+		OrderDate:   time.Unix(order.CreatedAt, 0),
+		Status:      order.Status,
+		TotalAmount: float64(order.TotalAmount) / 100,
+		FinalAmount: float64(order.FinalAmount) / 100,
+		Items:       []*dto.OrderItemResponse{},
+		CreatedAt:   time.Unix(order.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// GetOrderStatusHistory
+// @Summary 获取订单状态流转记录
+// @Description 按时间正序返回订单的状态流转记录，包含操作人与原因
+// @Tags Orders
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Success 200 {object} resp.Response{data=[]dto.OrderStatusLogResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/status-logs [get]
+func (cc *OrderController) GetOrderStatusHistory(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	logs, err := cc.salesService.GetOrderStatusHistory(c.Request.Context(), orderID)
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeOrderNotFound {
+			resp.Error(c, resp.CodeNotFound, "订单未找到")
+			return
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	result := make([]*dto.OrderStatusLogResponse, len(logs))
+	for i, l := range logs {
+		result[i] = &dto.OrderStatusLogResponse{
+			ID:         l.ID,
+			FromStatus: l.FromStatus,
+			ToStatus:   l.ToStatus,
+			OperatorID: l.OperatorID,
+			Reason:     l.Reason,
+			CreatedAt:  time.Unix(l.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		}
+	}
+
+	resp.Success(c, result)
+}
+
+// RefundOrderItems
+// @Summary 订单部分退款
+// @Description 按订单项及数量退款，订单级折扣按金额比例分摊；全部退完时订单流转为 refunded，否则支付状态为 partially_refunded；退款按支付明细原路退回：在线支付经收款渠道退款，钱包支付退回钱包，现金、刷卡只记录退款去向，由门店线下退还
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Param Idempotency-Key header string false "幂等键，重复提交返回同一退款单"
+// @Param body body dto.OrderRefundRequest true "退款订单项"
+// @Success 200 {object} resp.Response{data=dto.OrderRefundResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 409 {object} resp.Response "订单状态或可退数量不允许退款"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/refunds [post]
+func (cc *OrderController) RefundOrderItems(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	var req dto.OrderRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(c, cc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	items := make([]sales.RefundItemReq, len(req.Items))
+	for i, item := range req.Items {
+		items[i] = sales.RefundItemReq{OrderItemID: item.OrderItemID, Qty: item.Quantity}
+	}
+
+	refund, err := cc.salesService.RefundOrderItems(c.Request.Context(), sales.PartialRefundReq{
+		OrderID:    orderID,
+		Items:      items,
+		Reason:     req.Reason,
+		OperatorID: operatorID,
+		IdemKey:    GetIdempotencyKey(c),
+	})
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) {
+			switch businessErr.Code {
+			case common.ErrCodeOrderNotFound:
+				resp.Error(c, resp.CodeNotFound, "订单未找到")
+				return
+			case common.ErrCodeOrderStatusInvalid, common.ErrCodeOrderCannotRefund, common.ErrCodePackageUsed:
+				resp.Error(c, resp.CodeConflict, businessErr.Message)
+				return
+			case common.ErrCodeInvalidParam:
+				resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+				return
+			}
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, toOrderRefundResponse(refund))
+}
+
+// ListOrderRefunds
+// @Summary 获取订单退款记录
+// @Description 返回订单的全部退款单及退款明细
+// @Tags Orders
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Success 200 {object} resp.Response{data=[]dto.OrderRefundResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/refunds [get]
+func (cc *OrderController) ListOrderRefunds(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	refunds, err := cc.salesService.ListOrderRefunds(c.Request.Context(), orderID)
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeOrderNotFound {
+			resp.Error(c, resp.CodeNotFound, "订单未找到")
+			return
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	result := make([]*dto.OrderRefundResponse, len(refunds))
+	for i := range refunds {
+		result[i] = toOrderRefundResponse(&refunds[i])
+	}
+	resp.Success(c, result)
+}
+
+// toOrderRefundResponse 退款单领域模型转换为 DTO（金额由分转为元）
+// AddOrderPayments
+// @Summary 追加订单支付
+// @Description 为订单追加一笔或多笔支付（wallet/cash/online/card），钱包部分从客户钱包扣款；按已付合计更新支付状态，付清时订单流转为 paid
+// @Tags Orders
+// @Accept json
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Param Idempotency-Key header string false "幂等键，重复提交返回同一组支付明细"
+// @Param body body dto.OrderPaymentRequest true "支付明细"
+// @Success 200 {object} resp.Response{data=[]dto.OrderPaymentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 409 {object} resp.Response "订单状态不允许支付、超额支付或余额不足"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/payments [post]
+func (cc *OrderController) AddOrderPayments(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	var req dto.OrderPaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(c, cc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	payments, err := cc.salesService.AddOrderPayments(c.Request.Context(), sales.AddOrderPaymentReq{
+		OrderID:    orderID,
+		Payments:   toPaymentLines(req.Payments),
+		OperatorID: operatorID,
+		IdemKey:    GetIdempotencyKey(c),
+	})
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) {
+			switch businessErr.Code {
+			case common.ErrCodeOrderNotFound:
+				resp.Error(c, resp.CodeNotFound, "订单未找到")
+				return
+			case common.ErrCodeOrderStatusInvalid, common.ErrCodeOrderOverpaid, common.ErrCodeInsufficientBalance,
+				common.ErrCodeWalletFrozen:
+				resp.Error(c, resp.CodeConflict, businessErr.Message)
+				return
+			case common.ErrCodeInvalidParam:
+				resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+				return
+			}
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, toOrderPaymentResponses(payments))
+}
+
+// ListOrderPayments
+// @Summary 获取订单支付明细
+// @Description 返回订单的全部支付明细
+// @Tags Orders
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Success 200 {object} resp.Response{data=[]dto.OrderPaymentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/payments [get]
+func (cc *OrderController) ListOrderPayments(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	payments, err := cc.salesService.ListOrderPayments(c.Request.Context(), orderID)
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeOrderNotFound {
+			resp.Error(c, resp.CodeNotFound, "订单未找到")
+			return
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, toOrderPaymentResponses(payments))
+}
+
+// toPaymentLines 支付明细 DTO（元）转换为领域请求（分）
+func toPaymentLines(lines []*dto.OrderPaymentLine) []sales.PaymentLineReq {
+	if len(lines) == 0 {
+		return nil
+	}
+	result := make([]sales.PaymentLineReq, len(lines))
+	for i, l := range lines {
+		result[i] = sales.PaymentLineReq{Method: l.Method, Amount: int64(math.Round(l.Amount * 100))}
+	}
+	return result
+}
+
+func toOrderPaymentResponses(payments []sales.OrderPayment) []*dto.OrderPaymentResponse {
+	result := make([]*dto.OrderPaymentResponse, len(payments))
+	for i, p := range payments {
+		result[i] = &dto.OrderPaymentResponse{
+			ID:         p.ID,
+			OrderID:    p.OrderID,
+			Method:     p.Method,
+			Amount:     float64(p.Amount) / 100,
+			WalletTxID: p.WalletTxID,
+			OperatorID: p.OperatorID,
+			CreatedAt:  time.Unix(p.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		}
+	}
+	return result
+}
+
+func toOrderRefundResponse(refund *sales.OrderRefund) *dto.OrderRefundResponse {
+	lines := make([]*dto.OrderRefundLineResponse, len(refund.Lines))
+	for i, l := range refund.Lines {
+		lines[i] = &dto.OrderRefundLineResponse{
+			OrderItemID: l.OrderItemID,
+			ProductID:   l.ProductID,
+			Quantity:    l.Quantity,
+			Amount:      float64(l.Amount) / 100,
+		}
+	}
+	payments := make([]*dto.OrderRefundPaymentResponse, len(refund.Payments))
+	for i, p := range refund.Payments {
+		payments[i] = &dto.OrderRefundPaymentResponse{
+			OrderPaymentID:   p.OrderPaymentID,
+			Method:           p.Method,
+			Amount:           float64(p.Amount) / 100,
+			WalletTxID:       p.WalletTxID,
+			Provider:         p.Provider,
+			ProviderRefundNo: p.ProviderRefundNo,
+		}
+	}
+	return &dto.OrderRefundResponse{
+		ID:         refund.ID,
+		OrderID:    refund.OrderID,
+		Amount:     float64(refund.Amount) / 100,
+		WalletTxID: refund.WalletTxID,
+		Reason:     refund.Reason,
+		OperatorID: refund.OperatorID,
+		Lines:      lines,
+		Payments:   payments,
+		CreatedAt:  time.Unix(refund.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	}
+}
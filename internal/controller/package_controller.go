@@ -0,0 +1,238 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/entitlement"
+	"crm_lite/internal/domains/entitlement/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PackageController 负责处理次卡套餐相关的 HTTP 请求
+type PackageController struct {
+	packageSvc entitlement.Service
+	resManager *resource.Manager
+}
+
+// NewPackageController 创建一个新的 PackageController 实例
+func NewPackageController(rm *resource.Manager) *PackageController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for PackageController: " + err.Error())
+	}
+
+	return &PackageController{
+		packageSvc: impl.NewEntitlementService(dbRes.DB),
+		resManager: rm,
+	}
+}
+
+// SetPackagePlan godoc
+// @Summary      设置套餐配置
+// @Description  为 package 类型产品设置可抵扣的服务、次数与有效期，只影响之后购买的套餐
+// @Tags         Packages
+// @Accept       json
+// @Produce      json
+// @Param        productId path int true "套餐产品ID"
+// @Param        plan body dto.PackagePlanRequest true "套餐配置"
+// @Success      200 {object} resp.Response{data=dto.PackagePlanResponse}
+// @Failure      400 {object} resp.Response "请求参数错误或产品类型不符"
+// @Failure      404 {object} resp.Response "产品不存在"
+// @Security     ApiKeyAuth
+// @Router       /packages/plans/{productId} [put]
+func (pc *PackageController) SetPackagePlan(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("productId"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的产品ID")
+		return
+	}
+	var req dto.PackagePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	plan, err := pc.packageSvc.SetPackagePlan(c.Request.Context(), entitlement.SetPackagePlanRequest{
+		ProductID:        productID,
+		ServiceProductID: req.ServiceProductID,
+		TotalUses:        req.TotalUses,
+		ValidDays:        req.ValidDays,
+	})
+	if err != nil {
+		handlePackageError(c, err)
+		return
+	}
+	resp.Success(c, toPackagePlanResponse(plan))
+}
+
+// GetPackagePlan godoc
+// @Summary      获取套餐配置
+// @Tags         Packages
+// @Produce      json
+// @Param        productId path int true "套餐产品ID"
+// @Success      200 {object} resp.Response{data=dto.PackagePlanResponse}
+// @Failure      404 {object} resp.Response "套餐未配置"
+// @Security     ApiKeyAuth
+// @Router       /packages/plans/{productId} [get]
+func (pc *PackageController) GetPackagePlan(c *gin.Context) {
+	productID, err := strconv.ParseInt(c.Param("productId"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的产品ID")
+		return
+	}
+
+	plan, err := pc.packageSvc.GetPackagePlan(c.Request.Context(), productID)
+	if err != nil {
+		handlePackageError(c, err)
+		return
+	}
+	resp.Success(c, toPackagePlanResponse(plan))
+}
+
+// ListCustomerPackages godoc
+// @Summary      查询客户套餐
+// @Description  返回客户购买的套餐及剩余次数、有效期
+// @Tags         Packages
+// @Produce      json
+// @Param        id path int true "客户ID"
+// @Param        query query dto.CustomerPackageListRequest false "查询参数"
+// @Success      200 {object} resp.Response{data=[]dto.CustomerPackageResponse}
+// @Security     ApiKeyAuth
+// @Router       /customers/{id}/packages [get]
+func (pc *PackageController) ListCustomerPackages(c *gin.Context) {
+	customerID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.CustomerPackageListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	packages, err := pc.packageSvc.ListCustomerPackages(c.Request.Context(), customerID, req.ActiveOnly)
+	if err != nil {
+		handlePackageError(c, err)
+		return
+	}
+	result := make([]*dto.CustomerPackageResponse, len(packages))
+	for i := range packages {
+		result[i] = toCustomerPackageResponse(&packages[i])
+	}
+	resp.Success(c, result)
+}
+
+// GetCustomerPackage godoc
+// @Summary      获取客户套餐详情
+// @Tags         Packages
+// @Produce      json
+// @Param        id path int true "客户套餐ID"
+// @Success      200 {object} resp.Response{data=dto.CustomerPackageResponse}
+// @Failure      404 {object} resp.Response "客户套餐不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-packages/{id} [get]
+func (pc *PackageController) GetCustomerPackage(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户套餐ID")
+		return
+	}
+
+	pkg, err := pc.packageSvc.GetCustomerPackage(c.Request.Context(), id)
+	if err != nil {
+		handlePackageError(c, err)
+		return
+	}
+	resp.Success(c, toCustomerPackageResponse(pkg))
+}
+
+// ListPackageTransactions godoc
+// @Summary      查询套餐使用记录
+// @Description  按时间升序返回购买、抵扣、退回、扣回流水
+// @Tags         Packages
+// @Produce      json
+// @Param        id path int true "客户套餐ID"
+// @Success      200 {object} resp.Response{data=[]dto.PackageTransactionResponse}
+// @Failure      404 {object} resp.Response "客户套餐不存在"
+// @Security     ApiKeyAuth
+// @Router       /customer-packages/{id}/transactions [get]
+func (pc *PackageController) ListPackageTransactions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的客户套餐ID")
+		return
+	}
+
+	txs, err := pc.packageSvc.ListPackageTransactions(c.Request.Context(), id)
+	if err != nil {
+		handlePackageError(c, err)
+		return
+	}
+	result := make([]*dto.PackageTransactionResponse, len(txs))
+	for i, t := range txs {
+		result[i] = &dto.PackageTransactionResponse{
+			ID:             t.ID,
+			Type:           t.Type,
+			Uses:           t.Uses,
+			RemainingAfter: t.RemainingAfter,
+			OrderID:        t.OrderID,
+			OrderItemID:    t.OrderItemID,
+			Note:           t.Note,
+			CreatedAt:      time.Unix(t.CreatedAt, 0),
+		}
+	}
+	resp.Success(c, result)
+}
+
+// handlePackageError 将次卡套餐域业务错误映射为 HTTP 响应
+func handlePackageError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodePackageNotFound, common.ErrCodeProductNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toPackagePlanResponse(p *entitlement.PackagePlan) *dto.PackagePlanResponse {
+	return &dto.PackagePlanResponse{
+		ProductID:        p.ProductID,
+		ServiceProductID: p.ServiceProductID,
+		TotalUses:        p.TotalUses,
+		ValidDays:        p.ValidDays,
+		UpdatedAt:        time.Unix(p.UpdatedAt, 0),
+	}
+}
+
+func toCustomerPackageResponse(p *entitlement.CustomerPackage) *dto.CustomerPackageResponse {
+	r := &dto.CustomerPackageResponse{
+		ID:               p.ID,
+		CustomerID:       p.CustomerID,
+		PackageProductID: p.PackageProductID,
+		ServiceProductID: p.ServiceProductID,
+		OrderID:          p.OrderID,
+		Quantity:         p.Quantity,
+		TotalUses:        p.TotalUses,
+		RemainingUses:    p.RemainingUses,
+		Status:           p.Status,
+		CreatedAt:        time.Unix(p.CreatedAt, 0),
+	}
+	if p.ExpiresAt > 0 {
+		expiresAt := time.Unix(p.ExpiresAt, 0)
+		r.ExpiresAt = &expiresAt
+	}
+	return r
+}
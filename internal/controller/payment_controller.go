@@ -0,0 +1,272 @@
+package controller
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/domains/sales"
+	"crm_lite/internal/domains/sales/impl"
+	"crm_lite/internal/dto"
+	"crm_lite/pkg/resp"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentController 负责处理在线支付相关的 HTTP 请求
+// 包括创建支付单、渠道回调以及卡单对账
+type PaymentController struct {
+	paymentSvc     sales.OnlinePaymentService
+	pendingTimeout time.Duration
+	resManager     *resource.Manager
+}
+
+// NewPaymentController 创建一个新的 PaymentController
+func NewPaymentController(rm *resource.Manager) *PaymentController {
+	dbRes, err := resource.Get[*resource.DBResource](rm, resource.DBServiceKey)
+	if err != nil {
+		panic("Failed to get database resource for PaymentController: " + err.Error())
+	}
+	return &PaymentController{
+		paymentSvc:     impl.NewOnlinePaymentServiceFromConfig(dbRes.DB),
+		pendingTimeout: config.GetInstance().Payment.PendingTimeout,
+		resManager:     rm,
+	}
+}
+
+// CreatePaymentIntent
+// @Summary 创建在线支付单
+// @Description 为订单创建在线支付单并在支付渠道下单，返回支付链接；金额缺省为订单剩余应付金额（扣除进行中的支付单）
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Param Idempotency-Key header string false "幂等键，重复提交返回同一张支付单"
+// @Param body body dto.PaymentIntentCreateRequest false "支付金额与渠道"
+// @Success 201 {object} resp.Response{data=dto.PaymentIntentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误或支付渠道不存在"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 409 {object} resp.Response "订单状态不允许支付或超额支付"
+// @Failure 500 {object} resp.Response "服务器内部错误或支付渠道下单失败"
+// @Router /orders/{id}/payment-intents [post]
+func (pc *PaymentController) CreatePaymentIntent(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	var req dto.PaymentIntentCreateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			resp.Error(c, resp.CodeInvalidParam, err.Error())
+			return
+		}
+	}
+
+	operatorID, err := GetOperatorID(c, pc.resManager)
+	if err != nil {
+		resp.Error(c, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	intent, err := pc.paymentSvc.CreatePaymentIntent(c.Request.Context(), sales.CreatePaymentIntentReq{
+		OrderID:    orderID,
+		Amount:     int64(math.Round(req.Amount * 100)),
+		Provider:   req.Provider,
+		OperatorID: operatorID,
+		IdemKey:    GetIdempotencyKey(c),
+	})
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) {
+			switch businessErr.Code {
+			case common.ErrCodeOrderNotFound:
+				resp.Error(c, resp.CodeNotFound, "订单未找到")
+				return
+			case common.ErrCodeOrderStatusInvalid, common.ErrCodeOrderOverpaid:
+				resp.Error(c, resp.CodeConflict, businessErr.Message)
+				return
+			case common.ErrCodeInvalidParam, common.ErrCodePaymentProviderNotFound:
+				resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+				return
+			}
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.SuccessWithCode(c, resp.CodeCreated, toPaymentIntentResponse(intent))
+}
+
+// ListPaymentIntents
+// @Summary 获取订单在线支付单
+// @Description 返回订单的全部在线支付单，按创建时间正序
+// @Tags Payments
+// @Produce json
+// @Param id path int true "订单 ID"
+// @Success 200 {object} resp.Response{data=[]dto.PaymentIntentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "订单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /orders/{id}/payment-intents [get]
+func (pc *PaymentController) ListPaymentIntents(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的订单ID")
+		return
+	}
+
+	intents, err := pc.paymentSvc.ListOrderPaymentIntents(c.Request.Context(), orderID)
+	if err != nil {
+		var businessErr *common.BusinessError
+		if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeOrderNotFound {
+			resp.Error(c, resp.CodeNotFound, "订单未找到")
+			return
+		}
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, toPaymentIntentResponses(intents))
+}
+
+// HandleWebhook
+// @Summary 支付渠道回调
+// @Description 支付渠道异步通知支付结果，公开访问，以渠道签名鉴权；重复通知返回已处理的支付单，支付成功付清订单时订单流转为 paid 并产生 order.paid 事件
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "支付渠道"
+// @Param X-Payment-Signature header string true "回调签名"
+// @Success 200 {object} resp.Response{data=dto.PaymentIntentResponse}
+// @Failure 400 {object} resp.Response "支付渠道不存在或金额不一致"
+// @Failure 401 {object} resp.Response "签名无效"
+// @Failure 404 {object} resp.Response "支付单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /payments/webhooks/{provider} [post]
+func (pc *PaymentController) HandleWebhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "读取回调内容失败")
+		return
+	}
+
+	intent, err := pc.paymentSvc.HandleCallback(c.Request.Context(), c.Param("provider"), payload, c.GetHeader("X-Payment-Signature"))
+	if err != nil {
+		pc.handlePaymentError(c, err)
+		return
+	}
+
+	resp.Success(c, toPaymentIntentResponse(intent))
+}
+
+// ListStuckPaymentIntents
+// @Summary 查询卡单支付单
+// @Description 查询创建超过指定时长仍为 pending 的支付单（回调可能丢失），用于对账后主动同步
+// @Tags Payments
+// @Produce json
+// @Param older_than_minutes query int false "创建超过多少分钟，缺省取 payment.pendingTimeout"
+// @Param limit query int false "返回条数上限，默认100"
+// @Success 200 {object} resp.Response{data=[]dto.PaymentIntentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /payments/intents/stuck [get]
+func (pc *PaymentController) ListStuckPaymentIntents(c *gin.Context) {
+	var req dto.StuckPaymentIntentQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		resp.Error(c, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	olderThan := pc.pendingTimeout
+	if req.OlderThanMinutes > 0 {
+		olderThan = time.Duration(req.OlderThanMinutes) * time.Minute
+	}
+	intents, err := pc.paymentSvc.ListStuckPaymentIntents(c.Request.Context(), time.Now().Add(-olderThan).Unix(), req.Limit)
+	if err != nil {
+		resp.SystemError(c, err)
+		return
+	}
+
+	resp.Success(c, toPaymentIntentResponses(intents))
+}
+
+// SyncPaymentIntent
+// @Summary 同步支付单状态
+// @Description 主动向支付渠道查询 pending 支付单的支付结果，按回调相同的规则处理；已处理的支付单直接返回
+// @Tags Payments
+// @Produce json
+// @Param id path int true "支付单 ID"
+// @Success 200 {object} resp.Response{data=dto.PaymentIntentResponse}
+// @Failure 400 {object} resp.Response "请求参数错误或金额不一致"
+// @Failure 404 {object} resp.Response "支付单未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误或支付渠道查询失败"
+// @Router /payments/intents/{id}/sync [post]
+func (pc *PaymentController) SyncPaymentIntent(c *gin.Context) {
+	intentID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(c, resp.CodeInvalidParam, "无效的支付单ID")
+		return
+	}
+
+	intent, err := pc.paymentSvc.SyncPaymentIntent(c.Request.Context(), intentID)
+	if err != nil {
+		pc.handlePaymentError(c, err)
+		return
+	}
+
+	resp.Success(c, toPaymentIntentResponse(intent))
+}
+
+// handlePaymentError 回调与同步共用的错误映射
+func (pc *PaymentController) handlePaymentError(c *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodePaymentSignatureInvalid:
+			resp.Error(c, resp.CodeUnauthorized, businessErr.Message)
+			return
+		case common.ErrCodePaymentIntentNotFound, common.ErrCodeOrderNotFound:
+			resp.Error(c, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodePaymentProviderNotFound, common.ErrCodePaymentAmountMismatch:
+			resp.Error(c, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(c, err)
+}
+
+func toPaymentIntentResponse(intent *sales.PaymentIntent) *dto.PaymentIntentResponse {
+	r := &dto.PaymentIntentResponse{
+		ID:              intent.ID,
+		IntentNo:        intent.IntentNo,
+		OrderID:         intent.OrderID,
+		CustomerID:      intent.CustomerID,
+		Provider:        intent.Provider,
+		ProviderTradeNo: intent.ProviderTradeNo,
+		Amount:          float64(intent.Amount) / 100,
+		Status:          intent.Status,
+		PayURL:          intent.PayURL,
+		OrderPaymentID:  intent.OrderPaymentID,
+		FailureReason:   intent.FailureReason,
+		OperatorID:      intent.OperatorID,
+		CreatedAt:       time.Unix(intent.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	}
+	if intent.PaidAt > 0 {
+		r.PaidAt = time.Unix(intent.PaidAt, 0).Format("2006-01-02 15:04:05")
+	}
+	return r
+}
+
+func toPaymentIntentResponses(intents []sales.PaymentIntent) []*dto.PaymentIntentResponse {
+	result := make([]*dto.PaymentIntentResponse, len(intents))
+	for i := range intents {
+		result[i] = toPaymentIntentResponse(&intents[i])
+	}
+	return result
+}
@@ -1,13 +1,18 @@
 package controller
 
 import (
+	"context"
+	"crm_lite/internal/common"
 	"crm_lite/internal/core/resource"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/billing"
 	"crm_lite/internal/domains/billing/impl"
+	identityImpl "crm_lite/internal/domains/identity/impl"
 	"crm_lite/internal/dto"
 	"crm_lite/internal/middleware"
 	"crm_lite/pkg/resp"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -18,8 +23,14 @@ import (
 // WalletController 负责处理钱包相关的 API 请求
 // 已完全迁移到 billing 域服务
 type WalletController struct {
-	billingSvc billing.Service
-	resManager *resource.Manager
+	billingSvc   billing.Service
+	rechargeSvc  billing.RechargeService
+	transferSvc  billing.TransferService
+	creditSvc    billing.CreditExpiryService
+	reversalSvc  billing.ReversalService
+	statementSvc billing.StatementService
+	hierarchySvc *identityImpl.HierarchyServiceImpl
+	resManager   *resource.Manager
 }
 
 // NewWalletController 创建一个新的 WalletController
@@ -29,11 +40,15 @@ func NewWalletController(resManager *resource.Manager) *WalletController {
 	if err != nil {
 		panic("Failed to get database resource for WalletController: " + err.Error())
 	}
-	billingSvc := impl.NewBillingService(dbRes.DB)
-
 	return &WalletController{
-		billingSvc: billingSvc,
-		resManager: resManager,
+		billingSvc:   impl.NewBillingServiceForController(dbRes.DB),
+		rechargeSvc:  impl.NewRechargeService(dbRes.DB),
+		transferSvc:  impl.NewTransferService(dbRes.DB),
+		creditSvc:    impl.NewCreditExpiryService(dbRes.DB),
+		reversalSvc:  impl.NewReversalService(dbRes.DB),
+		statementSvc: impl.NewStatementService(dbRes.DB),
+		hierarchySvc: identityImpl.NewHierarchyService(resManager),
+		resManager:   resManager,
 	}
 }
 
@@ -67,7 +82,8 @@ func (c *WalletController) GetWalletByCustomerID(ctx *gin.Context) {
 		ID:         walletInfo.ID,
 		CustomerID: walletInfo.CustomerID,
 		Balance:    float64(walletInfo.Balance) / 100, // 转换为元
-		Type:       "balance",                         // 默认类型
+		Status:     walletInfo.Status,
+		Type:       "balance", // 默认类型
 		CreatedAt:  time.Unix(walletInfo.UpdatedAt, 0).Format("2006-01-02 15:04:05"),
 	}
 
@@ -146,12 +162,14 @@ func (c *WalletController) CreateTransaction(ctx *gin.Context) {
 		Reason:     req.Remark,
 		OrderID:    &req.RelatedID,
 		OperatorID: operatorID,
+		// 充值时手工指定赠送金额，不填则按充值赠送规则计算
+		BonusAmount: req.BonusAmount,
 	}
 
 	// 4. 调用Billing领域服务
 	transaction, err := c.billingSvc.CreateTransaction(ctx.Request.Context(), billingReq)
 	if err != nil {
-		resp.Error(ctx, http.StatusInternalServerError, err.Error())
+		handleWalletError(ctx, err)
 		return
 	}
 
@@ -172,6 +190,7 @@ func (c *WalletController) CreateTransaction(ctx *gin.Context) {
 
 // GetTransactions @Summary 获取客户钱包交易流水列表
 // @Description 根据客户ID获取其钱包交易记录，支持分页和多条件筛选
+// @Description 每条流水返回冲正状态：reversed/reversed_by_id 表示已被冲正，reversal_of_id 表示本流水为冲正流水
 // @Tags Wallets
 // @Accept json
 // @Produce json
@@ -225,17 +244,8 @@ func (c *WalletController) GetTransactions(ctx *gin.Context) {
 
 	// 转换为DTO格式
 	transactionResponses := make([]*dto.WalletTransactionResponse, len(transactions))
-	for i, transaction := range transactions {
-		transactionResponses[i] = &dto.WalletTransactionResponse{
-			ID:         transaction.ID,
-			Amount:     float64(transaction.Amount) / 100, // 转换为元
-			Type:       transaction.Type,
-			Source:     transaction.BizRefType,
-			Remark:     transaction.Note,
-			RelatedID:  transaction.BizRefID,
-			OperatorID: transaction.OperatorID,
-			CreatedAt:  time.Unix(transaction.CreatedAt, 0).Format("2006-01-02 15:04:05"),
-		}
+	for i := range transactions {
+		transactionResponses[i] = toWalletTransactionResponse(&transactions[i])
 	}
 
 	resp.Success(ctx, dto.ListWalletTransactionsResponse{
@@ -244,6 +254,59 @@ func (c *WalletController) GetTransactions(ctx *gin.Context) {
 	})
 }
 
+// ReverseTransaction @Summary 冲正钱包流水
+// @Description 冲正一笔录入错误的手工流水：写入方向相反、金额相同的 reversal 流水，related_id 为原流水ID。
+// @Description 仅 source 为 manual 的流水可以冲正，同一流水只能冲正一次；下单支付、订单退款、充值记录、转账、有效期额度等流水须走对应的退款或退回流程
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param txId path int true "原流水ID"
+// @Param body body dto.ReverseWalletTransactionRequest true "冲正原因"
+// @Success 201 {object} resp.Response{data=dto.WalletTransactionResponse} "冲正成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "钱包或流水未找到"
+// @Failure 409 {object} resp.Response "已冲正、不可冲正、钱包冻结或余额不足"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/transactions/{txId}/reverse [post]
+func (c *WalletController) ReverseTransaction(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	txID, err := strconv.ParseInt(ctx.Param("txId"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的流水ID")
+		return
+	}
+
+	var req dto.ReverseWalletTransactionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(ctx, c.resManager)
+	if err != nil {
+		resp.Error(ctx, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	reversal, err := c.reversalSvc.ReverseTransaction(ctx.Request.Context(), billing.ReverseTransactionRequest{
+		CustomerID:    customerID,
+		TransactionID: txID,
+		ReasonCode:    req.ReasonCode,
+		OperatorID:    operatorID,
+		Note:          req.Note,
+	})
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.SuccessWithCode(ctx, resp.CodeCreated, toWalletTransactionResponse(reversal))
+}
+
 // ProcessRefund @Summary 处理钱包退款
 // @Description 为客户处理订单退款，将金额退回到钱包
 // @Tags Wallets
@@ -281,3 +344,663 @@ func (c *WalletController) ProcessRefund(ctx *gin.Context) {
 
 	resp.Success(ctx, nil)
 }
+
+// FreezeWallet @Summary 冻结客户钱包
+// @Description 冻结后充值、订单扣款和手工交易均被拒绝，订单退款仍可入账；需填写冻结原因
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param body body dto.WalletStatusRequest true "冻结原因"
+// @Success 200 {object} resp.Response{data=dto.WalletResponse} "冻结成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 409 {object} resp.Response "钱包已处于冻结状态"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/freeze [post]
+func (c *WalletController) FreezeWallet(ctx *gin.Context) {
+	c.changeStatus(ctx, c.billingSvc.FreezeWallet)
+}
+
+// UnfreezeWallet @Summary 解冻客户钱包
+// @Description 恢复钱包的充值与消费能力；需填写解冻原因
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param body body dto.WalletStatusRequest true "解冻原因"
+// @Success 200 {object} resp.Response{data=dto.WalletResponse} "解冻成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "钱包未找到"
+// @Failure 409 {object} resp.Response "钱包未被冻结"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/unfreeze [post]
+func (c *WalletController) UnfreezeWallet(ctx *gin.Context) {
+	c.changeStatus(ctx, c.billingSvc.UnfreezeWallet)
+}
+
+// changeStatus 冻结/解冻的公共处理：解析参数、操作员并调用对应的领域方法
+func (c *WalletController) changeStatus(ctx *gin.Context, change func(context.Context, int64, int64, string) (*billing.WalletInfo, error)) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	var req dto.WalletStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(ctx, c.resManager)
+	if err != nil {
+		resp.Error(ctx, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	walletInfo, err := change(ctx.Request.Context(), customerID, operatorID, req.Reason)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+
+	resp.Success(ctx, &dto.WalletResponse{
+		ID:         walletInfo.ID,
+		CustomerID: walletInfo.CustomerID,
+		Balance:    float64(walletInfo.Balance) / 100, // 转换为元
+		Status:     walletInfo.Status,
+		Type:       "balance",
+		UpdatedAt:  time.Unix(walletInfo.UpdatedAt, 0).Format("2006-01-02 15:04:05"),
+	})
+}
+
+// ListStatusLogs @Summary 获取钱包状态变更日志
+// @Description 按时间倒序返回客户钱包的冻结/解冻记录
+// @Tags Wallets
+// @Produce json
+// @Param id path int true "客户ID"
+// @Success 200 {object} resp.Response{data=[]dto.WalletStatusLogResponse} "成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/status-logs [get]
+func (c *WalletController) ListStatusLogs(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	logs, err := c.billingSvc.ListWalletStatusLogs(ctx.Request.Context(), customerID)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+
+	items := make([]dto.WalletStatusLogResponse, len(logs))
+	for i, l := range logs {
+		items[i] = dto.WalletStatusLogResponse{
+			ID:         l.ID,
+			WalletID:   l.WalletID,
+			FromStatus: l.FromStatus,
+			ToStatus:   l.ToStatus,
+			OperatorID: l.OperatorID,
+			Reason:     l.Reason,
+			CreatedAt:  time.Unix(l.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		}
+	}
+	resp.Success(ctx, items)
+}
+
+// handleWalletError 将钱包域业务错误映射为响应码
+func handleWalletError(ctx *gin.Context, err error) {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		switch businessErr.Code {
+		case common.ErrCodeWalletNotFound, common.ErrCodeRechargeNotFound, common.ErrCodeRechargeBonusRuleNotFound,
+			common.ErrCodeTransactionNotFound, common.ErrCodeCustomerNotFound:
+			resp.Error(ctx, resp.CodeNotFound, businessErr.Message)
+			return
+		case common.ErrCodeWalletFrozen, common.ErrCodeWalletStatusInvalid, common.ErrCodeInsufficientBalance,
+			common.ErrCodeRechargeStatusInvalid, common.ErrCodeWalletStoreUnsupported,
+			common.ErrCodeTransactionReversed, common.ErrCodeTransactionIrreversible, common.ErrCodeStatementUnsigned:
+			resp.Error(ctx, resp.CodeConflict, businessErr.Message)
+			return
+		case common.ErrCodeInvalidParam:
+			resp.Error(ctx, resp.CodeInvalidParam, businessErr.Message)
+			return
+		}
+	}
+	resp.SystemError(ctx, err)
+}
+
+// ListRecharges @Summary 获取客户充值记录
+// @Description 按时间倒序返回客户的充值记录，并汇总实付资金与赠送资金
+// @Tags Wallets
+// @Produce json
+// @Param id path int true "客户ID"
+// @Success 200 {object} resp.Response{data=dto.ListWalletRechargesResponse} "成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/recharges [get]
+func (c *WalletController) ListRecharges(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	recharges, err := c.rechargeSvc.ListRecharges(ctx.Request.Context(), customerID)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	summary, err := c.rechargeSvc.GetRechargeSummary(ctx.Request.Context(), customerID)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+
+	items := make([]*dto.WalletRechargeResponse, len(recharges))
+	for i := range recharges {
+		items[i] = toWalletRechargeResponse(&recharges[i])
+	}
+	resp.Success(ctx, dto.ListWalletRechargesResponse{
+		Recharges: items,
+		Summary: &dto.RechargeSummaryResponse{
+			PaidAmount:     float64(summary.PaidAmount) / 100,
+			RefundedAmount: float64(summary.RefundedAmount) / 100,
+			BonusAmount:    float64(summary.BonusAmount) / 100,
+			ClawbackAmount: float64(summary.ClawbackAmount) / 100,
+			NetPaidAmount:  float64(summary.NetPaidAmount) / 100,
+			NetBonusAmount: float64(summary.NetBonusAmount) / 100,
+		},
+	})
+}
+
+// RefundRecharge @Summary 退回充值
+// @Description 扣回充值本金并收回该笔充值的赠送金额，余额不足以同时扣回时拒绝
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param rechargeId path int true "充值记录ID"
+// @Param body body dto.RefundRechargeRequest true "退回原因"
+// @Success 200 {object} resp.Response{data=dto.WalletRechargeResponse} "退回成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "充值记录未找到"
+// @Failure 409 {object} resp.Response "已退回、钱包冻结或余额不足"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/recharges/{rechargeId}/refund [post]
+func (c *WalletController) RefundRecharge(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	rechargeID, err := strconv.ParseInt(ctx.Param("rechargeId"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的充值记录ID")
+		return
+	}
+
+	var req dto.RefundRechargeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	operatorID, err := GetOperatorID(ctx, c.resManager)
+	if err != nil {
+		resp.Error(ctx, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	recharge, err := c.rechargeSvc.RefundRecharge(ctx.Request.Context(), customerID, rechargeID, operatorID, req.Reason)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.Success(ctx, toWalletRechargeResponse(recharge))
+}
+
+// Transfer @Summary 钱包转账
+// @Description 从路径中客户的钱包转账到另一客户的钱包，扣款与入账在同一事务内完成，双方流水 source 均为 transfer。
+// @Description 转入客户须存在且当前用户按客户层级规则可访问。
+// @Description 转出金额优先扣减有效期额度，扣减的额度按原过期时间转入对方钱包。
+// @Description 必须携带 Idempotency-Key，相同幂等键重复提交返回同一转账记录。
+// @Description 该接口单独受 Casbin 授权控制，除超级管理员外需为角色显式授予本路径的 POST 权限
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "转出客户ID"
+// @Param Idempotency-Key header string true "幂等键"
+// @Param body body dto.WalletTransferRequest true "转账信息"
+// @Success 201 {object} resp.Response{data=dto.WalletTransferResponse} "转账成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 403 {object} resp.Response "无权操作"
+// @Failure 404 {object} resp.Response "转出方钱包或转入客户未找到"
+// @Failure 409 {object} resp.Response "钱包冻结或余额不足"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/transfers [post]
+func (c *WalletController) Transfer(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	var req dto.WalletTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	idem := GetIdempotencyKey(ctx)
+	if idem == "" {
+		resp.Error(ctx, resp.CodeInvalidParam, "转账请求必须携带 Idempotency-Key")
+		return
+	}
+
+	operatorID, err := GetOperatorID(ctx, c.resManager)
+	if err != nil {
+		resp.Error(ctx, resp.CodeUnauthorized, err.Error())
+		return
+	}
+	// 路径中的转出客户由客户访问中间件校验，转入客户同样按客户层级规则校验
+	if !isSuperAdmin(ctx) {
+		allowed, err := c.hierarchySvc.CanAccessCustomer(ctx.Request.Context(), operatorID, req.ToCustomerID)
+		if err != nil {
+			resp.SystemError(ctx, err)
+			return
+		}
+		if !allowed {
+			resp.Error(ctx, resp.CodeForbidden, "无权访问转入客户")
+			return
+		}
+	}
+
+	transfer, err := c.transferSvc.Transfer(ctx.Request.Context(), billing.TransferRequest{
+		FromCustomerID: customerID,
+		ToCustomerID:   req.ToCustomerID,
+		Amount:         int64(math.Round(req.Amount * 100)),
+		OperatorID:     operatorID,
+		Note:           req.Note,
+		Idem:           common.IdempotencyKeyDigest(idem), // 转账记录幂等键列为 VARCHAR(64)，客户端键可达 128 个字符
+	})
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.SuccessWithCode(ctx, resp.CodeCreated, toWalletTransferResponse(transfer))
+}
+
+// ListTransfers @Summary 获取客户转账记录
+// @Description 按时间倒序返回客户转出及转入的转账记录
+// @Tags Wallets
+// @Produce json
+// @Param id path int true "客户ID"
+// @Success 200 {object} resp.Response{data=[]dto.WalletTransferResponse} "成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/transfers [get]
+func (c *WalletController) ListTransfers(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	transfers, err := c.transferSvc.ListTransfers(ctx.Request.Context(), customerID)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	items := make([]*dto.WalletTransferResponse, len(transfers))
+	for i := range transfers {
+		items[i] = toWalletTransferResponse(&transfers[i])
+	}
+	resp.Success(ctx, items)
+}
+
+// GrantCredit @Summary 发放有效期额度
+// @Description 向客户钱包发放带过期时间的额度（如服务补偿），以 adjust_in 入账；扣款时优先使用最早到期的额度，到期未用完的部分由定时任务以 adjust_out 扣回。
+// @Description 必须携带 Idempotency-Key，相同幂等键重复提交返回同一额度
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param Idempotency-Key header string true "幂等键"
+// @Param body body dto.GrantWalletCreditRequest true "额度信息"
+// @Success 201 {object} resp.Response{data=dto.WalletCreditLotResponse} "发放成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 409 {object} resp.Response "钱包已冻结"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/credits [post]
+func (c *WalletController) GrantCredit(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+
+	var req dto.GrantWalletCreditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	idem := GetIdempotencyKey(ctx)
+	if idem == "" {
+		resp.Error(ctx, resp.CodeInvalidParam, "发放额度必须携带 Idempotency-Key")
+		return
+	}
+
+	operatorID, err := GetOperatorID(ctx, c.resManager)
+	if err != nil {
+		resp.Error(ctx, resp.CodeUnauthorized, err.Error())
+		return
+	}
+
+	lot, err := c.creditSvc.GrantCredit(ctx.Request.Context(), billing.GrantCreditRequest{
+		CustomerID: customerID,
+		Amount:     int64(math.Round(req.Amount * 100)),
+		ExpiresAt:  req.ExpiresAt.Unix(),
+		OperatorID: operatorID,
+		Note:       req.Note,
+		Idem:       common.IdempotencyKeyDigest(idem), // 额度入账流水幂等键列为 VARCHAR(64)，客户端键可达 128 个字符
+	})
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.SuccessWithCode(ctx, resp.CodeCreated, toWalletCreditLotResponse(lot))
+}
+
+// ListExpiringCredits @Summary 获取即将过期的额度
+// @Description 返回客户在指定天数内到期且仍有剩余的额度（含已到期但尚未扣回的部分），按过期时间升序，供门店提醒客户尽快使用
+// @Tags Wallets
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param days query int false "查询未来多少天内到期的额度" default(30)
+// @Success 200 {object} resp.Response{data=dto.ExpiringCreditsResponse} "成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/expiring-credits [get]
+func (c *WalletController) ListExpiringCredits(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.ListExpiringCreditsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+
+	before := time.Now().AddDate(0, 0, req.Days).Unix()
+	lots, err := c.creditSvc.ListExpiringCredits(ctx.Request.Context(), customerID, before)
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	res := dto.ExpiringCreditsResponse{Credits: make([]*dto.WalletCreditLotResponse, len(lots))}
+	var total int64
+	for i := range lots {
+		res.Credits[i] = toWalletCreditLotResponse(&lots[i])
+		total += lots[i].Remaining
+	}
+	res.TotalRemaining = float64(total) / 100
+	resp.Success(ctx, res)
+}
+
+// ExportStatement @Summary 导出钱包对账单
+// @Description 返回客户在对账周期内的期初/期末余额、逐笔流水及其哈希，并以 HMAC-SHA256 签名
+// @Description 金额单位为分；签名覆盖 signature 置空后的整份对账单 JSON，head_hash 为周期内最后一笔流水的哈希，可与 verify-wallet-ledger 校验结果对照
+// @Tags Wallets
+// @Produce json
+// @Param id path int true "客户ID"
+// @Param start_date query string true "对账周期起 (YYYY-MM-DD，含)"
+// @Param end_date query string true "对账周期止 (YYYY-MM-DD，含)，跨度不超过366天"
+// @Success 200 {object} resp.Response{data=billing.Statement} "成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "钱包不存在"
+// @Failure 409 {object} resp.Response "未配置对账单签名密钥"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/customers/{id}/wallet/statement [get]
+func (c *WalletController) ExportStatement(ctx *gin.Context) {
+	customerID, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的客户ID")
+		return
+	}
+	var req dto.WalletStatementRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+	endAt := req.EndDate.AddDate(0, 0, 1) // 结束日期按整天包含
+
+	// 直接返回领域对账单，保持与签名内容一致
+	statement, err := c.statementSvc.ExportStatement(ctx.Request.Context(), billing.StatementRequest{
+		CustomerID: customerID,
+		From:       req.StartDate.Unix(),
+		To:         endAt.Unix(),
+	})
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.Success(ctx, statement)
+}
+
+// CreateBonusRule @Summary 创建充值赠送规则
+// @Description 充值金额达到门槛即赠送固定金额，多条规则同时满足时取门槛最高的一条
+// @Tags RechargeBonusRules
+// @Accept json
+// @Produce json
+// @Param body body dto.RechargeBonusRuleRequest true "规则"
+// @Success 201 {object} resp.Response{data=dto.RechargeBonusRuleResponse} "创建成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/recharge-bonus-rules [post]
+func (c *WalletController) CreateBonusRule(ctx *gin.Context) {
+	var req dto.RechargeBonusRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	rule, err := c.rechargeSvc.CreateBonusRule(ctx.Request.Context(), toSaveBonusRuleRequest(&req))
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.SuccessWithCode(ctx, resp.CodeCreated, toBonusRuleResponse(rule))
+}
+
+// ListBonusRules @Summary 获取充值赠送规则列表
+// @Description 按充值门槛升序返回全部充值赠送规则
+// @Tags RechargeBonusRules
+// @Produce json
+// @Success 200 {object} resp.Response{data=[]dto.RechargeBonusRuleResponse} "成功"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/recharge-bonus-rules [get]
+func (c *WalletController) ListBonusRules(ctx *gin.Context) {
+	rules, err := c.rechargeSvc.ListBonusRules(ctx.Request.Context())
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	items := make([]*dto.RechargeBonusRuleResponse, len(rules))
+	for i := range rules {
+		items[i] = toBonusRuleResponse(&rules[i])
+	}
+	resp.Success(ctx, items)
+}
+
+// UpdateBonusRule @Summary 更新充值赠送规则
+// @Description 规则变更只影响之后的充值，已发放的赠送不受影响
+// @Tags RechargeBonusRules
+// @Accept json
+// @Produce json
+// @Param id path int true "规则ID"
+// @Param body body dto.RechargeBonusRuleRequest true "规则"
+// @Success 200 {object} resp.Response{data=dto.RechargeBonusRuleResponse} "更新成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "规则未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/recharge-bonus-rules/{id} [put]
+func (c *WalletController) UpdateBonusRule(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的规则ID")
+		return
+	}
+	var req dto.RechargeBonusRuleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, err.Error())
+		return
+	}
+
+	rule, err := c.rechargeSvc.UpdateBonusRule(ctx.Request.Context(), id, toSaveBonusRuleRequest(&req))
+	if err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.Success(ctx, toBonusRuleResponse(rule))
+}
+
+// DeleteBonusRule @Summary 删除充值赠送规则
+// @Description 删除后不再发放，已发放的赠送不受影响
+// @Tags RechargeBonusRules
+// @Produce json
+// @Param id path int true "规则ID"
+// @Success 200 {object} resp.Response "删除成功"
+// @Failure 400 {object} resp.Response "请求参数错误"
+// @Failure 404 {object} resp.Response "规则未找到"
+// @Failure 500 {object} resp.Response "服务器内部错误"
+// @Router /v1/recharge-bonus-rules/{id} [delete]
+func (c *WalletController) DeleteBonusRule(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		resp.Error(ctx, resp.CodeInvalidParam, "无效的规则ID")
+		return
+	}
+	if err := c.rechargeSvc.DeleteBonusRule(ctx.Request.Context(), id); err != nil {
+		handleWalletError(ctx, err)
+		return
+	}
+	resp.Success(ctx, nil)
+}
+
+func toSaveBonusRuleRequest(req *dto.RechargeBonusRuleRequest) billing.SaveBonusRuleRequest {
+	isActive := true
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+	var startAt, endAt int64
+	if req.StartAt != nil {
+		startAt = req.StartAt.Unix()
+	}
+	if req.EndAt != nil {
+		endAt = req.EndAt.Unix()
+	}
+	return billing.SaveBonusRuleRequest{
+		Name:        req.Name,
+		MinAmount:   int64(math.Round(req.MinAmount * 100)),
+		BonusAmount: int64(math.Round(req.BonusAmount * 100)),
+		ValidDays:   req.ValidDays,
+		StartAt:     startAt,
+		EndAt:       endAt,
+		IsActive:    isActive,
+	}
+}
+
+func toBonusRuleResponse(rule *billing.BonusRule) *dto.RechargeBonusRuleResponse {
+	res := &dto.RechargeBonusRuleResponse{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		MinAmount:   float64(rule.MinAmount) / 100,
+		BonusAmount: float64(rule.BonusAmount) / 100,
+		ValidDays:   rule.ValidDays,
+		IsActive:    rule.IsActive,
+		CreatedAt:   time.Unix(rule.CreatedAt, 0),
+		UpdatedAt:   time.Unix(rule.UpdatedAt, 0),
+	}
+	if rule.StartAt > 0 {
+		t := time.Unix(rule.StartAt, 0)
+		res.StartAt = &t
+	}
+	if rule.EndAt > 0 {
+		t := time.Unix(rule.EndAt, 0)
+		res.EndAt = &t
+	}
+	return res
+}
+
+func toWalletRechargeResponse(r *billing.Recharge) *dto.WalletRechargeResponse {
+	res := &dto.WalletRechargeResponse{
+		ID:            r.ID,
+		WalletID:      r.WalletID,
+		Amount:        float64(r.Amount) / 100,
+		BonusAmount:   float64(r.BonusAmount) / 100,
+		RuleID:        r.RuleID,
+		PrincipalTxID: r.PrincipalTxID,
+		BonusTxID:     r.BonusTxID,
+		Status:        r.Status,
+		OperatorID:    r.OperatorID,
+		Note:          r.Note,
+		CreatedAt:     time.Unix(r.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	}
+	if r.RefundedAt > 0 {
+		res.RefundedAt = time.Unix(r.RefundedAt, 0).Format("2006-01-02 15:04:05")
+	}
+	return res
+}
+
+func toWalletTransferResponse(t *billing.Transfer) *dto.WalletTransferResponse {
+	return &dto.WalletTransferResponse{
+		ID:             t.ID,
+		FromCustomerID: t.FromCustomerID,
+		ToCustomerID:   t.ToCustomerID,
+		Amount:         float64(t.Amount) / 100,
+		OutTxID:        t.OutTxID,
+		InTxID:         t.InTxID,
+		OperatorID:     t.OperatorID,
+		Note:           t.Note,
+		CreatedAt:      time.Unix(t.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+	}
+}
+
+func toWalletCreditLotResponse(l *billing.CreditLot) *dto.WalletCreditLotResponse {
+	return &dto.WalletCreditLotResponse{
+		ID:            l.ID,
+		SourceTxID:    l.SourceTxID,
+		Amount:        float64(l.Amount) / 100,
+		Remaining:     float64(l.Remaining) / 100,
+		ExpiresAt:     time.Unix(l.ExpiresAt, 0),
+		ExpiredAmount: float64(l.ExpiredAmount) / 100,
+		Note:          l.Note,
+		CreatedAt:     time.Unix(l.CreatedAt, 0),
+	}
+}
+
+func toWalletTransactionResponse(t *billing.Transaction) *dto.WalletTransactionResponse {
+	return &dto.WalletTransactionResponse{
+		ID:           t.ID,
+		WalletID:     t.WalletID,
+		Amount:       float64(t.Amount) / 100, // 转换为元
+		Type:         t.Type,
+		Source:       t.BizRefType,
+		Remark:       t.Note,
+		RelatedID:    t.BizRefID,
+		OperatorID:   t.OperatorID,
+		CreatedAt:    time.Unix(t.CreatedAt, 0).Format("2006-01-02 15:04:05"),
+		ReasonCode:   t.ReasonCode,
+		Reversed:     t.ReversedByTxID > 0,
+		ReversedByID: t.ReversedByTxID,
+		ReversalOfID: t.ReversalOfTxID,
+	}
+}
@@ -158,6 +158,43 @@ type CORSOptions struct {
 	MaxAge           time.Duration `mapstructure:"maxAge"`
 }
 
+// IdempotencyOptions HTTP 幂等配置
+type IdempotencyOptions struct {
+	Driver        string        `mapstructure:"driver"`        // 存储驱动: redis, db
+	TTL           time.Duration `mapstructure:"ttl"`           // 原始响应保留时长
+	LockTTL       time.Duration `mapstructure:"lockTTL"`       // 请求处理中的占用时长，超时未完成（如进程崩溃）后允许重试
+	PurgeInterval time.Duration `mapstructure:"purgeInterval"` // idempotency_keys 表过期键的清理间隔，0 表示不启用
+}
+
+// OrderOptions 订单配置
+type OrderOptions struct {
+	PaymentTimeout     time.Duration `mapstructure:"paymentTimeout"`     // 未支付订单超时时间，0 表示不自动取消
+	AutoCancelInterval time.Duration `mapstructure:"autoCancelInterval"` // 超时订单扫描间隔
+	AutoCancelBatch    int           `mapstructure:"autoCancelBatch"`    // 单次扫描最多取消的订单数
+}
+
+// OutboxOptions outbox 事件投递配置
+type OutboxOptions struct {
+	DispatchInterval time.Duration `mapstructure:"dispatchInterval"` // 待处理事件扫描间隔
+	DispatchBatch    int           `mapstructure:"dispatchBatch"`    // 单次扫描最多投递的事件数
+}
+
+// WalletOptions 钱包配置
+type WalletOptions struct {
+	ReconcileInterval    time.Duration `mapstructure:"reconcileInterval"`    // 余额定时对账间隔，0 表示不启用
+	ReconcileRepair      bool          `mapstructure:"reconcileRepair"`      // 定时对账是否自动修复偏差
+	StoreMode            string        `mapstructure:"storeMode"`            // 钱包存储模式：legacy/dual_write/read_compare/truth
+	CreditExpiryInterval time.Duration `mapstructure:"creditExpiryInterval"` // 有效期额度过期处理间隔，0 表示不启用
+	StatementSigningKey  string        `mapstructure:"statementSigningKey"`  // 钱包对账单 HMAC 签名密钥，为空时不能导出对账单
+}
+
+// PaymentOptions 在线支付配置
+type PaymentOptions struct {
+	DefaultProvider string        `mapstructure:"defaultProvider"` // 创建支付单未指定渠道时使用的渠道，为空时必须指定渠道
+	MockSecret      string        `mapstructure:"mockSecret"`      // 内置模拟渠道回调签名密钥，为空时不启用模拟渠道
+	PendingTimeout  time.Duration `mapstructure:"pendingTimeout"`  // 支付单超过该时长仍为 pending 视为卡单，需对账补偿
+}
+
 // CaptchaOptions 人机验证配置
 type CaptchaOptions struct {
 	TurnstileSecret string `mapstructure:"turnstileSecret"` // Cloudflare Turnstile Secret Key
@@ -169,15 +206,20 @@ type CaptchaOptions struct {
 type Options struct {
 	vp *viper.Viper // viper实例
 
-	Server     ServerOptions     `mapstructure:"server"`     // 服务器配置
-	Logger     LogOptions        `mapstructure:"logger"`     // 日志配置
-	LogCleanup LogCleanupOptions `mapstructure:"logCleanup"` // 日志清理配置
-	Database   DBOptions         `mapstructure:"database"`   // 数据库配置
-	Cache      CacheOptions      `mapstructure:"cache"`      // 缓存配置
-	Auth       AuthOptions       `mapstructure:"auth"`       // 认证配置
-	Email      EmailOptions      `mapstructure:"email"`      // 邮件服务配置
-	CORS       CORSOptions       `mapstructure:"cors"`       // CORS配置
-	PprofOn    bool              `mapstructure:"pprofOn"`    // 性能分析开关
+	Server      ServerOptions      `mapstructure:"server"`      // 服务器配置
+	Logger      LogOptions         `mapstructure:"logger"`      // 日志配置
+	LogCleanup  LogCleanupOptions  `mapstructure:"logCleanup"`  // 日志清理配置
+	Database    DBOptions          `mapstructure:"database"`    // 数据库配置
+	Cache       CacheOptions       `mapstructure:"cache"`       // 缓存配置
+	Auth        AuthOptions        `mapstructure:"auth"`        // 认证配置
+	Email       EmailOptions       `mapstructure:"email"`       // 邮件服务配置
+	CORS        CORSOptions        `mapstructure:"cors"`        // CORS配置
+	Idempotency IdempotencyOptions `mapstructure:"idempotency"` // 幂等配置
+	Order       OrderOptions       `mapstructure:"order"`       // 订单配置
+	Outbox      OutboxOptions      `mapstructure:"outbox"`      // outbox 事件投递配置
+	Wallet      WalletOptions      `mapstructure:"wallet"`      // 钱包配置
+	Payment     PaymentOptions     `mapstructure:"payment"`     // 在线支付配置
+	PprofOn     bool               `mapstructure:"pprofOn"`     // 性能分析开关
 }
 
 // ==================== 单例模式 ====================
@@ -340,12 +382,49 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.CORS = CORSOptions{
 		AllowOrigins:     o.getStringSliceWithDefault("cors.allowOrigins", []string{"*"}),
 		AllowMethods:     o.getStringSliceWithDefault("cors.allowMethods", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "HEAD"}),
-		AllowHeaders:     o.getStringSliceWithDefault("cors.allowHeaders", []string{"Origin", "Content-Type", "Authorization", "X-Requested-With"}),
-		ExposeHeaders:    o.getStringSliceWithDefault("cors.exposeHeaders", []string{"Content-Length", "Content-Type"}),
+		AllowHeaders:     o.getStringSliceWithDefault("cors.allowHeaders", []string{"Origin", "Content-Type", "Authorization", "X-Requested-With", "Idempotency-Key"}),
+		ExposeHeaders:    o.getStringSliceWithDefault("cors.exposeHeaders", []string{"Content-Length", "Content-Type", "Idempotent-Replayed"}),
 		AllowCredentials: o.getBoolWithDefault("cors.allowCredentials", true),
 		MaxAge:           o.getDurationWithDefault("cors.maxAge", 12*time.Hour),
 	}
 
+	// 幂等配置
+	o.Idempotency = IdempotencyOptions{
+		Driver:        o.getStringWithDefault("idempotency.driver", "redis"),
+		TTL:           o.getDurationWithDefault("idempotency.ttl", 24*time.Hour),
+		LockTTL:       o.getDurationWithDefault("idempotency.lockTTL", time.Minute),
+		PurgeInterval: o.getDurationWithDefault("idempotency.purgeInterval", time.Hour),
+	}
+
+	// 订单配置
+	o.Order = OrderOptions{
+		PaymentTimeout:     o.getDurationWithDefault("order.paymentTimeout", 30*time.Minute),
+		AutoCancelInterval: o.getDurationWithDefault("order.autoCancelInterval", time.Minute),
+		AutoCancelBatch:    o.getIntWithDefault("order.autoCancelBatch", 100),
+	}
+
+	// outbox 事件投递配置
+	o.Outbox = OutboxOptions{
+		DispatchInterval: o.getDurationWithDefault("outbox.dispatchInterval", 10*time.Second),
+		DispatchBatch:    o.getIntWithDefault("outbox.dispatchBatch", 100),
+	}
+
+	// 钱包配置
+	o.Wallet = WalletOptions{
+		ReconcileInterval:    o.getDurationWithDefault("wallet.reconcileInterval", 24*time.Hour),
+		ReconcileRepair:      o.getBoolWithDefault("wallet.reconcileRepair", false),
+		StoreMode:            o.getStringWithDefault("wallet.storeMode", "legacy"),
+		CreditExpiryInterval: o.getDurationWithDefault("wallet.creditExpiryInterval", time.Hour),
+		StatementSigningKey:  o.getStringWithDefault("wallet.statementSigningKey", ""),
+	}
+
+	// 在线支付配置
+	o.Payment = PaymentOptions{
+		DefaultProvider: o.getStringWithDefault("payment.defaultProvider", ""),
+		MockSecret:      o.getStringWithDefault("payment.mockSecret", ""),
+		PendingTimeout:  o.getDurationWithDefault("payment.pendingTimeout", 30*time.Minute),
+	}
+
 	// 其他配置
 	o.PprofOn = o.getBoolWithDefault("pprofOn", false)
 }
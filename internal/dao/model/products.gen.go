@@ -22,8 +22,9 @@ type Product struct {
 	Price         float64        `gorm:"column:price;type:decimal(10,2);not null;default:0.00" json:"price"`
 	Cost          float64        `gorm:"column:cost;type:decimal(10,2);default:0.00" json:"cost"`
 	StockQuantity int32          `gorm:"column:stock_quantity;type:int(11)" json:"stock_quantity"`
-	MinStockLevel int32          `gorm:"column:min_stock_level;type:int(11);comment:最小库存预警" json:"min_stock_level"` // 最小库存预警
-	Unit          string         `gorm:"column:unit;type:varchar(20);default:个;comment:单位" json:"unit"`             // 单位
+	MinStockLevel int32          `gorm:"column:min_stock_level;type:int(11);comment:最小库存预警" json:"min_stock_level"`                                 // 最小库存预警
+	Unit          string         `gorm:"column:unit;type:varchar(20);default:个;comment:单位" json:"unit"`                                             // 单位
+	DurationMin   int32          `gorm:"column:duration_min;type:int(11);not null;comment:服务时长（分钟），service 类型产品用于预约排班，0表示不可预约" json:"duration_min"` // 服务时长（分钟），service 类型产品用于预约排班，0表示不可预约
 	IsActive      bool           `gorm:"column:is_active;type:tinyint(1);default:1" json:"is_active"`
 	CreatedAt     time.Time      `gorm:"column:created_at;type:timestamp;default:current_timestamp()" json:"created_at"`
 	UpdatedAt     time.Time      `gorm:"column:updated_at;type:timestamp;default:current_timestamp()" json:"updated_at"`
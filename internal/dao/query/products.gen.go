@@ -38,6 +38,7 @@ func newProduct(db *gorm.DB, opts ...gen.DOOption) product {
 	_product.StockQuantity = field.NewInt32(tableName, "stock_quantity")
 	_product.MinStockLevel = field.NewInt32(tableName, "min_stock_level")
 	_product.Unit = field.NewString(tableName, "unit")
+	_product.DurationMin = field.NewInt32(tableName, "duration_min")
 	_product.IsActive = field.NewBool(tableName, "is_active")
 	_product.CreatedAt = field.NewTime(tableName, "created_at")
 	_product.UpdatedAt = field.NewTime(tableName, "updated_at")
@@ -62,6 +63,7 @@ type product struct {
 	StockQuantity field.Int32
 	MinStockLevel field.Int32  // 最小库存预警
 	Unit          field.String // 单位
+	DurationMin   field.Int32  // 服务时长（分钟），service 类型产品用于预约排班，0表示不可预约
 	IsActive      field.Bool
 	CreatedAt     field.Time
 	UpdatedAt     field.Time
@@ -92,6 +94,7 @@ func (p *product) updateTableName(table string) *product {
 	p.StockQuantity = field.NewInt32(table, "stock_quantity")
 	p.MinStockLevel = field.NewInt32(table, "min_stock_level")
 	p.Unit = field.NewString(table, "unit")
+	p.DurationMin = field.NewInt32(table, "duration_min")
 	p.IsActive = field.NewBool(table, "is_active")
 	p.CreatedAt = field.NewTime(table, "created_at")
 	p.UpdatedAt = field.NewTime(table, "updated_at")
@@ -112,7 +115,7 @@ func (p *product) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (p *product) fillFieldMap() {
-	p.fieldMap = make(map[string]field.Expr, 14)
+	p.fieldMap = make(map[string]field.Expr, 15)
 	p.fieldMap["id"] = p.ID
 	p.fieldMap["name"] = p.Name
 	p.fieldMap["description"] = p.Description
@@ -123,6 +126,7 @@ func (p *product) fillFieldMap() {
 	p.fieldMap["stock_quantity"] = p.StockQuantity
 	p.fieldMap["min_stock_level"] = p.MinStockLevel
 	p.fieldMap["unit"] = p.Unit
+	p.fieldMap["duration_min"] = p.DurationMin
 	p.fieldMap["is_active"] = p.IsActive
 	p.fieldMap["created_at"] = p.CreatedAt
 	p.fieldMap["updated_at"] = p.UpdatedAt
@@ -33,6 +33,9 @@ func newSysOutbox(db *gorm.DB, opts ...gen.DOOption) sysOutbox {
 	_sysOutbox.Payload = field.NewString(tableName, "payload")
 	_sysOutbox.CreatedAt = field.NewInt64(tableName, "created_at")
 	_sysOutbox.ProcessedAt = field.NewInt64(tableName, "processed_at")
+	_sysOutbox.Attempts = field.NewInt32(tableName, "attempts")
+	_sysOutbox.LastError = field.NewString(tableName, "last_error")
+	_sysOutbox.NextRetryAt = field.NewInt64(tableName, "next_retry_at")
 
 	_sysOutbox.fillFieldMap()
 
@@ -49,6 +52,9 @@ type sysOutbox struct {
 	Payload     field.String // 事件载荷数据
 	CreatedAt   field.Int64  // 创建时间（Unix时间戳）
 	ProcessedAt field.Int64  // 处理时间（Unix时间戳），NULL表示未处理
+	Attempts    field.Int32  // 投递失败次数
+	LastError   field.String // 最近一次投递失败原因
+	NextRetryAt field.Int64  // 下次可投递时间（Unix时间戳），0表示立即
 
 	fieldMap map[string]field.Expr
 }
@@ -70,6 +76,9 @@ func (s *sysOutbox) updateTableName(table string) *sysOutbox {
 	s.Payload = field.NewString(table, "payload")
 	s.CreatedAt = field.NewInt64(table, "created_at")
 	s.ProcessedAt = field.NewInt64(table, "processed_at")
+	s.Attempts = field.NewInt32(table, "attempts")
+	s.LastError = field.NewString(table, "last_error")
+	s.NextRetryAt = field.NewInt64(table, "next_retry_at")
 
 	s.fillFieldMap()
 
@@ -86,12 +95,15 @@ func (s *sysOutbox) GetFieldByName(fieldName string) (field.OrderExpr, bool) {
 }
 
 func (s *sysOutbox) fillFieldMap() {
-	s.fieldMap = make(map[string]field.Expr, 5)
+	s.fieldMap = make(map[string]field.Expr, 8)
 	s.fieldMap["id"] = s.ID
 	s.fieldMap["event_type"] = s.EventType
 	s.fieldMap["payload"] = s.Payload
 	s.fieldMap["created_at"] = s.CreatedAt
 	s.fieldMap["processed_at"] = s.ProcessedAt
+	s.fieldMap["attempts"] = s.Attempts
+	s.fieldMap["last_error"] = s.LastError
+	s.fieldMap["next_retry_at"] = s.NextRetryAt
 }
 
 func (s sysOutbox) clone(db *gorm.DB) sysOutbox {
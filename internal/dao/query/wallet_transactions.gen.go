@@ -40,6 +40,8 @@ func newWalletTransaction(db *gorm.DB, opts ...gen.DOOption) walletTransaction {
 	_walletTransaction.ReasonCode = field.NewString(tableName, "reason_code")
 	_walletTransaction.Note = field.NewString(tableName, "note")
 	_walletTransaction.CreatedAt = field.NewInt64(tableName, "created_at")
+	_walletTransaction.PrevHash = field.NewString(tableName, "prev_hash")
+	_walletTransaction.Hash = field.NewString(tableName, "hash")
 
 	_walletTransaction.fillFieldMap()
 
@@ -62,6 +64,8 @@ type walletTransaction struct {
 	ReasonCode     field.String // 交易原因代码
 	Note           field.String // 备注信息
 	CreatedAt      field.Int64  // 创建时间（Unix时间戳）
+	PrevHash       field.String // 同一钱包上一笔流水的哈希，首笔为空
+	Hash           field.String // 本流水内容与上一笔哈希的 SHA-256
 
 	fieldMap map[string]field.Expr
 }
@@ -90,6 +94,8 @@ func (w *walletTransaction) updateTableName(table string) *walletTransaction {
 	w.ReasonCode = field.NewString(table, "reason_code")
 	w.Note = field.NewString(table, "note")
 	w.CreatedAt = field.NewInt64(table, "created_at")
+	w.PrevHash = field.NewString(table, "prev_hash")
+	w.Hash = field.NewString(table, "hash")
 
 	w.fillFieldMap()
 
@@ -106,7 +112,7 @@ func (w *walletTransaction) GetFieldByName(fieldName string) (field.OrderExpr, b
 }
 
 func (w *walletTransaction) fillFieldMap() {
-	w.fieldMap = make(map[string]field.Expr, 12)
+	w.fieldMap = make(map[string]field.Expr, 14)
 	w.fieldMap["id"] = w.ID
 	w.fieldMap["wallet_id"] = w.WalletID
 	w.fieldMap["direction"] = w.Direction
@@ -119,6 +125,8 @@ func (w *walletTransaction) fillFieldMap() {
 	w.fieldMap["reason_code"] = w.ReasonCode
 	w.fieldMap["note"] = w.Note
 	w.fieldMap["created_at"] = w.CreatedAt
+	w.fieldMap["prev_hash"] = w.PrevHash
+	w.fieldMap["hash"] = w.Hash
 }
 
 func (w walletTransaction) clone(db *gorm.DB) walletTransaction {
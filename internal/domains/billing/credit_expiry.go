@@ -0,0 +1,56 @@
+package billing
+
+import "context"
+
+// GrantCreditRequest 发放有效期额度请求（补偿、活动赠送等）
+type GrantCreditRequest struct {
+	CustomerID int64  `json:"customer_id"`
+	Amount     int64  `json:"amount"`     // 发放金额（分）
+	ExpiresAt  int64  `json:"expires_at"` // 过期时间（Unix时间戳），必须晚于当前时间
+	OperatorID int64  `json:"operator_id"`
+	Note       string `json:"note"`
+	Idem       string `json:"idem"` // 幂等键，相同幂等键返回已有额度
+}
+
+// CreditLot 有效期额度
+// 扣款时按过期时间从早到晚扣减 Remaining，到期仍有剩余的部分由过期任务以 adjust_out 流水扣回
+type CreditLot struct {
+	ID            int64  `json:"id"`
+	WalletID      int64  `json:"wallet_id"`
+	CustomerID    int64  `json:"customer_id"`
+	SourceTxID    int64  `json:"source_tx_id"`   // 入账流水ID
+	Amount        int64  `json:"amount"`         // 入账金额（分）
+	Remaining     int64  `json:"remaining"`      // 剩余未使用金额（分）
+	ExpiresAt     int64  `json:"expires_at"`     // 过期时间（Unix时间戳）
+	ExpiredAmount int64  `json:"expired_amount"` // 过期扣回金额（分）
+	ExpiredTxID   int64  `json:"expired_tx_id"`  // 过期扣回流水ID，未过期为0
+	Note          string `json:"note"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+// ExpireCreditsOptions 额度过期处理选项
+type ExpireCreditsOptions struct {
+	Now       int64 // 以该时间判断是否过期，0 表示当前时间
+	BatchSize int   // 每批处理的钱包数，默认 500
+}
+
+// ExpireCreditsReport 额度过期处理报告
+type ExpireCreditsReport struct {
+	StartedAt      int64 `json:"started_at"`
+	FinishedAt     int64 `json:"finished_at"`
+	WalletsScanned int   `json:"wallets_scanned"` // 存在到期额度的钱包数
+	LotsExpired    int   `json:"lots_expired"`    // 处理的到期额度数
+	AmountExpired  int64 `json:"amount_expired"`  // 扣回的金额合计（分）
+}
+
+// CreditExpiryService 有效期额度服务接口
+type CreditExpiryService interface {
+	// GrantCredit 发放有效期额度：以 adjust_in 入账并记录额度，冻结的钱包返回 WALLET_FROZEN
+	GrantCredit(ctx context.Context, req GrantCreditRequest) (*CreditLot, error)
+
+	// ListExpiringCredits 查询客户在 before 之前到期且仍有剩余的额度，按过期时间升序
+	ListExpiringCredits(ctx context.Context, customerID, before int64) ([]CreditLot, error)
+
+	// ExpireCredits 扣回全部已到期额度的剩余金额，可重复执行
+	ExpireCredits(ctx context.Context, opts ExpireCreditsOptions) (*ExpireCreditsReport, error)
+}
@@ -52,10 +52,13 @@ func TestBillingServiceIntegration(t *testing.T) {
 			operator_id INTEGER DEFAULT 0,
 			reason_code TEXT,
 			note TEXT,
-			created_at INTEGER NOT NULL
+			created_at INTEGER NOT NULL,
+			prev_hash TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL DEFAULT ''
 		)
 	`).Error
 	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&WalletCreditLotRecord{}, &WalletCreditLotUsageRecord{}))
 
 	// 创建billing服务（使用具体实现类以访问Legacy方法）
 	tx := common.NewTx(db)
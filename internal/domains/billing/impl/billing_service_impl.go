@@ -6,43 +6,50 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
 	"crm_lite/internal/dao/model"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/billing"
 	"crm_lite/internal/dto"
 
+	"gorm.io/gen"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // BillingServiceImpl billing域服务实现
 // 实现余额只读原则：所有余额变更通过交易记录实现
 type BillingServiceImpl struct {
-	db *gorm.DB
-	q  *query.Query
-	tx common.Tx
+	db        *gorm.DB
+	q         *query.Query
+	tx        common.Tx
+	outboxSvc common.OutboxService
 }
 
 // NewBillingServiceImpl 创建billing服务实例
 // db: 数据库连接
 // tx: 事务管理器
 func NewBillingServiceImpl(db *gorm.DB, tx common.Tx) billing.Service {
+	return newBillingServiceImpl(db, tx)
+}
+
+func newBillingServiceImpl(db *gorm.DB, tx common.Tx) *BillingServiceImpl {
 	return &BillingServiceImpl{
-		db: db,
-		q:  query.Use(db),
-		tx: tx,
+		db:        db,
+		q:         query.Use(db),
+		tx:        tx,
+		outboxSvc: common.NewOutboxService(db, tx),
 	}
 }
 
 // NewBillingService 创建billing服务实例（简化版本）
+// 按 wallet.storeMode 配置选择旧表、迁移期或真相表实现
 func NewBillingService(db *gorm.DB) billing.Service {
-	return &BillingServiceImpl{
-		db: db,
-		q:  query.Use(db),
-		tx: common.NewTx(db),
-	}
+	return NewBillingServiceForMode(db, common.NewTx(db), storeMode())
 }
 
 // Credit 钱包入账操作
@@ -69,11 +76,14 @@ func (s *BillingServiceImpl) Credit(ctx context.Context, customerID int64, amoun
 			return fmt.Errorf("检查幂等性失败: %w", err)
 		}
 
-		// 2. 获取或创建钱包（带行锁）
+		// 2. 获取或创建钱包（带行锁），冻结的钱包不允许充值
 		wallet, err := s.getOrCreateWalletWithLock(ctx, txQuery, customerID)
 		if err != nil {
 			return fmt.Errorf("获取钱包失败: %w", err)
 		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
 
 		// 3. 创建交易记录
 		transaction := &model.WalletTransaction{
@@ -81,7 +91,7 @@ func (s *BillingServiceImpl) Credit(ctx context.Context, customerID int64, amoun
 			Direction:      "credit",
 			Amount:         amount,
 			Type:           "recharge", // 默认为充值类型
-			BizRefType:     "manual",
+			BizRefType:     manualBizRefType,
 			BizRefID:       0,
 			IdempotencyKey: idem,
 			OperatorID:     0, // 系统操作
@@ -89,6 +99,9 @@ func (s *BillingServiceImpl) Credit(ctx context.Context, customerID int64, amoun
 			CreatedAt:      time.Now().Unix(),
 		}
 
+		if err := sealWalletTx(ctx, txQuery, transaction); err != nil {
+			return err
+		}
 		err = txQuery.WalletTransaction.WithContext(ctx).Create(transaction)
 		if err != nil {
 			return fmt.Errorf("创建交易记录失败: %w", err)
@@ -111,6 +124,11 @@ func (s *BillingServiceImpl) Credit(ctx context.Context, customerID int64, amoun
 // DebitForOrder 为订单扣款
 // 专用于订单支付场景，必须关联订单ID
 func (s *BillingServiceImpl) DebitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
+	return s.debitForOrder(ctx, customerID, orderID, amount, idem, orderBizRefType)
+}
+
+// debitForOrder 写入 order_pay 扣款流水，bizRefType 区分订单流程（order）与手工交易（manual）
+func (s *BillingServiceImpl) debitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem, bizRefType string) error {
 	if amount <= 0 {
 		return common.NewBusinessError(common.ErrCodeInvalidParam, "扣款金额必须大于0")
 	}
@@ -131,11 +149,14 @@ func (s *BillingServiceImpl) DebitForOrder(ctx context.Context, customerID, orde
 			return fmt.Errorf("检查幂等性失败: %w", err)
 		}
 
-		// 2. 获取钱包并加行锁
+		// 2. 获取钱包并加行锁，冻结的钱包不允许扣款
 		wallet, err := s.getWalletWithLock(ctx, txQuery, customerID)
 		if err != nil {
 			return err
 		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
 
 		// 3. 检查余额是否足够
 		if wallet.Balance < amount {
@@ -148,7 +169,7 @@ func (s *BillingServiceImpl) DebitForOrder(ctx context.Context, customerID, orde
 			Direction:      "debit",
 			Amount:         amount,
 			Type:           "order_pay",
-			BizRefType:     "order",
+			BizRefType:     bizRefType,
 			BizRefID:       orderID,
 			IdempotencyKey: idem,
 			OperatorID:     0, // 系统操作
@@ -156,6 +177,9 @@ func (s *BillingServiceImpl) DebitForOrder(ctx context.Context, customerID, orde
 			CreatedAt:      time.Now().Unix(),
 		}
 
+		if err := sealWalletTx(ctx, txQuery, transaction); err != nil {
+			return err
+		}
 		err = txQuery.WalletTransaction.WithContext(ctx).Create(transaction)
 		if err != nil {
 			return fmt.Errorf("创建交易记录失败: %w", err)
@@ -171,13 +195,21 @@ func (s *BillingServiceImpl) DebitForOrder(ctx context.Context, customerID, orde
 			return fmt.Errorf("更新钱包余额失败: %w", err)
 		}
 
-		return nil
+		// 6. 优先使用最早到期的有效期额度
+		return consumeCreditLots(ctx, txDB, wallet.ID, amount, 0, transaction.ID)
 	})
 }
 
 // CreditForRefund 订单退款入账
-// 专用于订单退款场景，必须关联原订单ID
+// 专用于订单退款场景，必须关联原订单ID；冻结的钱包仍允许退款入账，避免资金滞留；
+// 原订单扣款使用的有效期额度随退款恢复，不会变成永久余额
 func (s *BillingServiceImpl) CreditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
+	return s.creditForRefund(ctx, customerID, orderID, amount, idem, orderBizRefType)
+}
+
+// creditForRefund 写入 order_refund 入账流水，bizRefType 区分订单流程（order）与手工交易（manual）；
+// 手工退款与订单扣款无关，不恢复有效期额度
+func (s *BillingServiceImpl) creditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem, bizRefType string) error {
 	if amount <= 0 {
 		return common.NewBusinessError(common.ErrCodeInvalidParam, "退款金额必须大于0")
 	}
@@ -198,7 +230,7 @@ func (s *BillingServiceImpl) CreditForRefund(ctx context.Context, customerID, or
 			return fmt.Errorf("检查幂等性失败: %w", err)
 		}
 
-		// 2. 获取钱包并加行锁
+		// 2. 获取钱包并加行锁（不校验冻结状态）
 		wallet, err := s.getWalletWithLock(ctx, txQuery, customerID)
 		if err != nil {
 			return err
@@ -210,7 +242,7 @@ func (s *BillingServiceImpl) CreditForRefund(ctx context.Context, customerID, or
 			Direction:      "credit",
 			Amount:         amount,
 			Type:           "order_refund",
-			BizRefType:     "order",
+			BizRefType:     bizRefType,
 			BizRefID:       orderID,
 			IdempotencyKey: idem,
 			OperatorID:     0, // 系统操作
@@ -218,6 +250,9 @@ func (s *BillingServiceImpl) CreditForRefund(ctx context.Context, customerID, or
 			CreatedAt:      time.Now().Unix(),
 		}
 
+		if err := sealWalletTx(ctx, txQuery, transaction); err != nil {
+			return err
+		}
 		err = txQuery.WalletTransaction.WithContext(ctx).Create(transaction)
 		if err != nil {
 			return fmt.Errorf("创建交易记录失败: %w", err)
@@ -233,7 +268,11 @@ func (s *BillingServiceImpl) CreditForRefund(ctx context.Context, customerID, or
 			return fmt.Errorf("更新钱包余额失败: %w", err)
 		}
 
-		return nil
+		// 5. 恢复订单扣款时使用的有效期额度，保留原过期时间
+		if bizRefType != orderBizRefType {
+			return nil
+		}
+		return restoreOrderCreditLots(ctx, txDB, &model.WalletTransaction{}, wallet.ID, orderID, amount, transaction.ID)
 	})
 }
 
@@ -273,7 +312,7 @@ func (s *BillingServiceImpl) GetTransactionHistory(ctx context.Context, customer
 	offset := (page - 1) * pageSize
 	transactions, err := s.q.WalletTransaction.WithContext(ctx).
 		Where(s.q.WalletTransaction.WalletID.Eq(wallet.ID)).
-		Order(s.q.WalletTransaction.CreatedAt.Desc()).
+		Order(s.q.WalletTransaction.CreatedAt.Desc(), s.q.WalletTransaction.ID.Desc()).
 		Offset(offset).
 		Limit(pageSize).
 		Find()
@@ -282,35 +321,62 @@ func (s *BillingServiceImpl) GetTransactionHistory(ctx context.Context, customer
 		return nil, fmt.Errorf("查询交易记录失败: %w", err)
 	}
 
-	// 3. 转换为领域模型
+	// 3. 转换为领域模型并填充冲正状态
 	result := make([]billing.Transaction, len(transactions))
 	for i, tx := range transactions {
-		result[i] = billing.Transaction{
-			ID:             tx.ID,
-			WalletID:       tx.WalletID,
-			Direction:      tx.Direction,
-			Amount:         tx.Amount,
-			Type:           tx.Type,
-			BizRefType:     tx.BizRefType,
-			BizRefID:       tx.BizRefID,
-			IdempotencyKey: tx.IdempotencyKey,
-			OperatorID:     tx.OperatorID,
-			ReasonCode:     tx.ReasonCode,
-			Note:           tx.Note,
-			CreatedAt:      tx.CreatedAt,
-		}
+		result[i] = *toBillingTransaction(tx)
+	}
+	if err := fillReversalStatus(ctx, s.db, &model.WalletTransaction{}, result); err != nil {
+		return nil, err
 	}
 
 	return result, nil
 }
 
+// GetTransactionByIdemKey 根据幂等键查询交易
+// 使用上下文中的事务连接，可读到同一事务内尚未提交的流水
+func (s *BillingServiceImpl) GetTransactionByIdemKey(ctx context.Context, idem string) (*billing.Transaction, error) {
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	tx, err := txQuery.WalletTransaction.WithContext(ctx).
+		Where(txQuery.WalletTransaction.IdempotencyKey.Eq(idem)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeResourceNotFound, "交易不存在")
+		}
+		return nil, fmt.Errorf("查询交易记录失败: %w", err)
+	}
+
+	return toBillingTransaction(tx), nil
+}
+
+func toBillingTransaction(tx *model.WalletTransaction) *billing.Transaction {
+	return &billing.Transaction{
+		ID:             tx.ID,
+		WalletID:       tx.WalletID,
+		Direction:      tx.Direction,
+		Amount:         tx.Amount,
+		Type:           tx.Type,
+		BizRefType:     tx.BizRefType,
+		BizRefID:       tx.BizRefID,
+		IdempotencyKey: tx.IdempotencyKey,
+		OperatorID:     tx.OperatorID,
+		ReasonCode:     tx.ReasonCode,
+		Note:           tx.Note,
+		CreatedAt:      tx.CreatedAt,
+		PrevHash:       tx.PrevHash,
+		Hash:           tx.Hash,
+	}
+}
+
 // getOrCreateWalletWithLock 获取或创建钱包（带行锁）
 // 用于确保钱包存在且获得排他锁
 func (s *BillingServiceImpl) getOrCreateWalletWithLock(ctx context.Context, txQuery *query.Query, customerID int64) (*model.Wallet, error) {
 	// 1. 尝试获取现有钱包（加行锁）
 	wallet, err := txQuery.Wallet.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where(txQuery.Wallet.CustomerID.Eq(customerID)).
-		First() // 注意：真正的FOR UPDATE需要原生SQL，这里先使用简化版本
+		First()
 
 	if err == nil {
 		return wallet, nil
@@ -322,7 +388,7 @@ func (s *BillingServiceImpl) getOrCreateWalletWithLock(ctx context.Context, txQu
 	newWallet := &model.Wallet{
 		CustomerID: customerID,
 		Balance:    0,
-		Status:     1, // 1-正常
+		Status:     constants.WalletStatusNormal,
 		UpdatedAt:  time.Now().Unix(),
 	}
 
@@ -335,9 +401,10 @@ func (s *BillingServiceImpl) getOrCreateWalletWithLock(ctx context.Context, txQu
 }
 
 // getWalletWithLock 获取钱包并加行锁
-// 用于扣款等需要排他访问的操作
+// 用于扣款等需要排他访问的操作，冻结状态由调用方按业务场景校验
 func (s *BillingServiceImpl) getWalletWithLock(ctx context.Context, txQuery *query.Query, customerID int64) (*model.Wallet, error) {
 	wallet, err := txQuery.Wallet.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
 		Where(txQuery.Wallet.CustomerID.Eq(customerID)).
 		First()
 
@@ -347,12 +414,29 @@ func (s *BillingServiceImpl) getWalletWithLock(ctx context.Context, txQuery *que
 		return nil, fmt.Errorf("查询钱包失败: %w", err)
 	}
 
-	// 检查钱包状态
-	if wallet.Status != 1 {
-		return nil, common.NewBusinessError(common.ErrCodeWalletFrozen, "钱包已冻结")
+	return wallet, nil
+}
+
+// ensureWalletActive 校验钱包未被冻结
+func ensureWalletActive(wallet *model.Wallet) error {
+	if wallet.Status != constants.WalletStatusNormal {
+		return common.NewBusinessError(common.ErrCodeWalletFrozen, "钱包已冻结")
 	}
+	return nil
+}
 
-	return wallet, nil
+// checkWalletNotFrozen 手工交易前校验钱包未被冻结，钱包不存在时视为正常
+func (s *BillingServiceImpl) checkWalletNotFrozen(ctx context.Context, customerID int64) error {
+	wallet, err := s.q.Wallet.WithContext(ctx).
+		Where(s.q.Wallet.CustomerID.Eq(customerID)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("查询钱包失败: %w", err)
+	}
+	return ensureWalletActive(wallet)
 }
 
 // ===== Legacy 兼容接口实现 =====
@@ -427,27 +511,44 @@ func (s *BillingServiceImpl) CreateTransaction(ctx context.Context, req *billing
 	// 转换金额为分
 	amount := int64(req.Amount * 100)
 
+	// 手工交易（含手工退款）一律不允许操作冻结的钱包
+	if err := s.checkWalletNotFrozen(ctx, req.CustomerID); err != nil {
+		return nil, err
+	}
+
 	var err error
+	var txID, walletID int64
 	switch req.Type {
 	case "recharge":
-		// 充值操作
+		// 充值操作：按赠送规则（或手工指定金额）同时发放赠送
 		reason := req.Reason
 		if reason == "" {
 			reason = "充值"
 		}
-		err = s.Credit(ctx, req.CustomerID, amount, reason, idemKey)
+		var recharge *billing.Recharge
+		recharge, err = s.Recharge(ctx, billing.RechargeRequest{
+			CustomerID:  req.CustomerID,
+			Amount:      amount,
+			BonusAmount: int64(math.Round(req.BonusAmount * 100)),
+			OperatorID:  req.OperatorID,
+			Note:        reason,
+			Idem:        idemKey,
+		})
+		if recharge != nil {
+			txID, walletID = recharge.PrincipalTxID, recharge.WalletID
+		}
 	case "consume":
 		// 消费操作
 		if req.OrderID == nil {
 			return nil, errors.New("consume操作必须指定OrderID")
 		}
-		err = s.DebitForOrder(ctx, req.CustomerID, *req.OrderID, amount, idemKey)
+		err = s.debitForOrder(ctx, req.CustomerID, *req.OrderID, amount, idemKey, manualBizRefType)
 	case "refund":
 		// 退款操作
 		if req.OrderID == nil {
 			return nil, errors.New("refund操作必须指定OrderID")
 		}
-		err = s.CreditForRefund(ctx, req.CustomerID, *req.OrderID, amount, idemKey)
+		err = s.creditForRefund(ctx, req.CustomerID, *req.OrderID, amount, idemKey, manualBizRefType)
 	default:
 		return nil, errors.New("unsupported transaction type")
 	}
@@ -456,10 +557,10 @@ func (s *BillingServiceImpl) CreateTransaction(ctx context.Context, req *billing
 		return nil, err
 	}
 
-	// 返回交易记录（这里简化实现，实际应该查询数据库）
+	// 返回交易记录（这里简化实现，实际应该查询数据库；充值时返回本金流水ID）
 	return &billing.Transaction{
-		ID:             0, // 实际应该从数据库获取
-		WalletID:       0, // 实际应该从数据库获取
+		ID:             txID,
+		WalletID:       walletID,
 		Direction:      getDirection(req.Type),
 		Amount:         amount,
 		Type:           req.Type,
@@ -487,10 +588,8 @@ func getDirection(txType string) string {
 
 func getBizRefType(txType string) string {
 	switch txType {
-	case "consume", "refund":
-		return "order"
-	case "recharge":
-		return "manual"
+	case "consume", "refund", "recharge":
+		return manualBizRefType
 	default:
 		return "unknown"
 	}
@@ -504,10 +603,51 @@ func getBizRefID(orderID *int64) int64 {
 }
 
 // GetTransactions 获取交易历史（符合接口定义）
+// 分页查询，可按交易类型筛选，按时间倒序，并填充冲正状态
 func (s *BillingServiceImpl) GetTransactions(ctx context.Context, customerID int64, req *billing.TransactionHistoryRequest) ([]billing.Transaction, int64, error) {
-	// 这里简化实现，实际应该查询数据库
-	// TODO: 实现数据库查询逻辑
-	return []billing.Transaction{}, 0, nil
+	wallet, err := s.q.Wallet.WithContext(ctx).
+		Where(s.q.Wallet.CustomerID.Eq(customerID)).
+		First()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return []billing.Transaction{}, 0, nil
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("查询钱包失败: %w", err)
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	conds := []gen.Condition{s.q.WalletTransaction.WalletID.Eq(wallet.ID)}
+	if req.Type != "" {
+		conds = append(conds, s.q.WalletTransaction.Type.Eq(req.Type))
+	}
+
+	total, err := s.q.WalletTransaction.WithContext(ctx).Where(conds...).Count()
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询交易记录失败: %w", err)
+	}
+	transactions, err := s.q.WalletTransaction.WithContext(ctx).
+		Where(conds...).
+		Order(s.q.WalletTransaction.CreatedAt.Desc(), s.q.WalletTransaction.ID.Desc()).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find()
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询交易记录失败: %w", err)
+	}
+
+	result := make([]billing.Transaction, len(transactions))
+	for i, tx := range transactions {
+		result[i] = *toBillingTransaction(tx)
+	}
+	if err := fillReversalStatus(ctx, s.db, &model.WalletTransaction{}, result); err != nil {
+		return nil, 0, err
+	}
+	return result, total, nil
 }
 
 // GetWalletByCustomerID 获取客户钱包信息（符合接口定义）
@@ -538,28 +678,41 @@ func (s *BillingServiceImpl) CreateTransactionLegacy(ctx context.Context, custom
 	// 根据交易类型生成幂等键
 	idemKey := fmt.Sprintf("%s_%d_%d_%d", req.Type, customerID, time.Now().UnixNano(), operatorID)
 
+	// 手工交易（含手工退款）一律不允许操作冻结的钱包
+	if err := s.checkWalletNotFrozen(ctx, customerID); err != nil {
+		return err
+	}
+
 	switch req.Type {
 	case "recharge":
-		// 充值操作
+		// 充值操作：按赠送规则（或手工指定金额）同时发放赠送
 		reason := fmt.Sprintf("充值 - %s", req.Remark)
 		if req.Remark == "" {
 			reason = "充值"
 		}
-		return s.Credit(ctx, customerID, int64(req.Amount*100), reason, idemKey)
+		_, err := s.Recharge(ctx, billing.RechargeRequest{
+			CustomerID:  customerID,
+			Amount:      int64(req.Amount * 100),
+			BonusAmount: int64(math.Round(req.BonusAmount * 100)),
+			OperatorID:  operatorID,
+			Note:        reason,
+			Idem:        idemKey,
+		})
+		return err
 
 	case "consume":
 		// 消费操作 - 转换为订单扣款（需要关联业务ID）
 		if req.RelatedID == 0 {
 			return errors.New("consume操作必须指定RelatedID")
 		}
-		return s.DebitForOrder(ctx, customerID, req.RelatedID, int64(req.Amount*100), idemKey)
+		return s.debitForOrder(ctx, customerID, req.RelatedID, int64(req.Amount*100), idemKey, manualBizRefType)
 
 	case "refund":
 		// 退款操作
 		if req.RelatedID == 0 {
 			return errors.New("refund操作必须指定RelatedID")
 		}
-		return s.CreditForRefund(ctx, customerID, req.RelatedID, int64(req.Amount*100), idemKey)
+		return s.creditForRefund(ctx, customerID, req.RelatedID, int64(req.Amount*100), idemKey, manualBizRefType)
 
 	default:
 		return errors.New("unsupported transaction type")
@@ -625,7 +778,7 @@ func (s *BillingServiceImpl) GetTransactionsLegacy(ctx context.Context, customer
 
 	// 4. 查询交易记录
 	transactions, err := query.
-		Order(s.q.WalletTransaction.CreatedAt.Desc()).
+		Order(s.q.WalletTransaction.CreatedAt.Desc(), s.q.WalletTransaction.ID.Desc()).
 		Limit(req.Limit).
 		Offset(offset).
 		Find()
@@ -694,6 +847,7 @@ func (s *BillingServiceImpl) toWalletResponse(wallet *model.Wallet) *dto.WalletR
 		FrozenBalance:  0,                             // 新模型暂不支持冻结金额
 		TotalRecharged: 0,                             // 新模型不存储此字段，需要从交易记录计算
 		TotalConsumed:  0,                             // 新模型不存储此字段，需要从交易记录计算
+		Status:         wallet.Status,
 		CreatedAt:      wallet.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:      time.Unix(wallet.UpdatedAt, 0).Format("2006-01-02 15:04:05"),
 	}
@@ -0,0 +1,422 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// creditLotBizRefType 有效期额度发放与过期扣回流水的业务引用类型，biz_ref_id 为额度ID
+	creditLotBizRefType = "credit_lot"
+	// creditExpireNote 过期扣回流水的备注
+	creditExpireNote = "有效期额度过期"
+)
+
+// GrantCredit 发放有效期额度
+// 以 adjust_in 入账，额度记录关联入账流水，相同幂等键返回已有额度
+func (s *BillingServiceImpl) GrantCredit(ctx context.Context, req billing.GrantCreditRequest) (*billing.CreditLot, error) {
+	if err := validateGrantCredit(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.CreditLot
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 幂等性检查
+		existing, err := txQuery.WalletTransaction.WithContext(ctx).
+			Where(txQuery.WalletTransaction.IdempotencyKey.Eq(req.Idem)).
+			First()
+		if err == nil {
+			result, err = findCreditLotBySourceTx(ctx, txDB, existing.ID)
+			return err
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查幂等性失败: %w", err)
+		}
+
+		// 2. 获取或创建钱包（带行锁），冻结的钱包不允许发放
+		wallet, err := s.getOrCreateWalletWithLock(ctx, txQuery, req.CustomerID)
+		if err != nil {
+			return fmt.Errorf("获取钱包失败: %w", err)
+		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
+
+		// 3. 写入额度记录，再按额度ID写入入账流水
+		lot, err := createCreditLot(ctx, txDB, wallet.ID, req.CustomerID, req.Amount, req.ExpiresAt, req.Note)
+		if err != nil {
+			return err
+		}
+		credit, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       wallet.ID,
+			Direction:      "credit",
+			Amount:         req.Amount,
+			Type:           "adjust_in",
+			BizRefType:     creditLotBizRefType,
+			BizRefID:       lot.ID,
+			IdempotencyKey: req.Idem,
+			OperatorID:     req.OperatorID,
+			Note:           req.Note,
+		})
+		if err != nil {
+			return err
+		}
+		if err := bindCreditLotSource(ctx, txDB, lot, credit.ID); err != nil {
+			return err
+		}
+		result = toCreditLot(lot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListExpiringCredits 查询客户在 before 之前到期且仍有剩余的额度
+func (s *BillingServiceImpl) ListExpiringCredits(ctx context.Context, customerID, before int64) ([]billing.CreditLot, error) {
+	return listExpiringCredits(ctx, s.db, customerID, before)
+}
+
+// ExpireCredits 逐个钱包扣回已到期额度的剩余金额
+func (s *BillingServiceImpl) ExpireCredits(ctx context.Context, opts billing.ExpireCreditsOptions) (*billing.ExpireCreditsReport, error) {
+	return expireCredits(ctx, s.db, opts, s.expireCustomerCredits)
+}
+
+// expireCustomerCredits 在钱包行锁内扣回客户全部已到期额度的剩余金额
+// 过期由系统执行，冻结的钱包同样扣回；扣回金额不超过钱包余额
+func (s *BillingServiceImpl) expireCustomerCredits(ctx context.Context, customerID, now int64) (lots int, amount int64, err error) {
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		wallet, err := s.getWalletWithLock(ctx, txQuery, customerID)
+		if err != nil {
+			return err
+		}
+		due, err := findDueCreditLots(ctx, txDB, wallet.ID, now)
+		if err != nil {
+			return err
+		}
+
+		balance := wallet.Balance
+		for i := range due {
+			lot := &due[i]
+			expired := min(lot.Remaining, balance)
+			var txID int64
+			if expired > 0 {
+				tx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+					WalletID:       wallet.ID,
+					Direction:      "debit",
+					Amount:         expired,
+					Type:           "adjust_out",
+					BizRefType:     creditLotBizRefType,
+					BizRefID:       lot.ID,
+					IdempotencyKey: fmt.Sprintf("credit_expire_%d", lot.ID),
+					Note:           creditExpireNote,
+				})
+				if err != nil {
+					return err
+				}
+				txID = tx.ID
+				balance -= expired
+			}
+			if err := closeCreditLot(ctx, txDB, lot, expired, txID); err != nil {
+				return err
+			}
+			lots++
+			amount += expired
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return lots, amount, nil
+}
+
+// ===== 有效期额度公共逻辑，旧表与真相表实现共用 wallet_credit_lots =====
+
+// createCreditLot 创建有效期额度，入账流水写入后由 bindCreditLotSource 回填流水ID
+func createCreditLot(ctx context.Context, db *gorm.DB, walletID, customerID, amount, expiresAt int64, note string) (*WalletCreditLotRecord, error) {
+	lot := &WalletCreditLotRecord{
+		WalletID:   walletID,
+		CustomerID: customerID,
+		Amount:     amount,
+		Remaining:  amount,
+		ExpiresAt:  expiresAt,
+		Note:       note,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := db.WithContext(ctx).Create(lot).Error; err != nil {
+		return nil, fmt.Errorf("创建有效期额度失败: %w", err)
+	}
+	return lot, nil
+}
+
+// bindCreditLotSource 回填额度的入账流水ID
+func bindCreditLotSource(ctx context.Context, db *gorm.DB, lot *WalletCreditLotRecord, sourceTxID int64) error {
+	lot.SourceTxID = sourceTxID
+	if err := db.WithContext(ctx).Model(lot).Update("source_tx_id", sourceTxID).Error; err != nil {
+		return fmt.Errorf("更新有效期额度失败: %w", err)
+	}
+	return nil
+}
+
+// findCreditLotBySourceTx 按入账流水查询额度
+func findCreditLotBySourceTx(ctx context.Context, db *gorm.DB, sourceTxID int64) (*billing.CreditLot, error) {
+	var lot WalletCreditLotRecord
+	if err := db.WithContext(ctx).Where("source_tx_id = ?", sourceTxID).First(&lot).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeDuplicateTransaction, "幂等键已被其他交易使用")
+		}
+		return nil, fmt.Errorf("查询有效期额度失败: %w", err)
+	}
+	return toCreditLot(&lot), nil
+}
+
+// consumeCreditLots 扣款时按过期时间从早到晚扣减有效期额度，调用方需已持有钱包行锁
+// preferSourceTxID 大于0时优先扣减该入账流水对应的额度（如退充值时先扣减本次赠送）；
+// debitTxID 大于0时逐条记录扣减明细，订单退款时据此恢复额度；
+// 额度剩余合计不超过钱包余额，扣款金额超出额度剩余的部分视为使用永久余额
+func consumeCreditLots(ctx context.Context, db *gorm.DB, walletID, amount, preferSourceTxID, debitTxID int64) error {
+	var lots []WalletCreditLotRecord
+	if err := db.WithContext(ctx).
+		Where("wallet_id = ? AND remaining > 0", walletID).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return fmt.Errorf("查询有效期额度失败: %w", err)
+	}
+	if preferSourceTxID > 0 {
+		sort.SliceStable(lots, func(i, j int) bool {
+			return lots[i].SourceTxID == preferSourceTxID && lots[j].SourceTxID != preferSourceTxID
+		})
+	}
+
+	now := time.Now().Unix()
+	for i := 0; i < len(lots) && amount > 0; i++ {
+		used := min(lots[i].Remaining, amount)
+		if err := db.WithContext(ctx).Model(&WalletCreditLotRecord{}).Where("id = ?", lots[i].ID).
+			Update("remaining", gorm.Expr("remaining - ?", used)).Error; err != nil {
+			return fmt.Errorf("扣减有效期额度失败: %w", err)
+		}
+		if debitTxID > 0 {
+			usage := &WalletCreditLotUsageRecord{LotID: lots[i].ID, TxID: debitTxID, Amount: used, CreatedAt: now}
+			if err := db.WithContext(ctx).Create(usage).Error; err != nil {
+				return fmt.Errorf("记录有效期额度扣减明细失败: %w", err)
+			}
+		}
+		amount -= used
+	}
+	return nil
+}
+
+// restoreOrderCreditLots 订单退款时恢复该订单扣款所扣减的有效期额度，调用方需已持有钱包行锁
+// table 为流水所在表的模型；按扣减的倒序恢复，累计不超过退款金额，超出部分视为退回永久余额。
+// 未过期的额度直接加回剩余，已过期的额度按原过期时间新建一笔，由过期任务照常扣回
+func restoreOrderCreditLots(ctx context.Context, db *gorm.DB, table interface{}, walletID, orderID, amount, refundTxID int64) error {
+	var debitTxIDs []int64
+	if err := db.WithContext(ctx).Model(table).
+		Where("wallet_id = ? AND type = ? AND biz_ref_type = ? AND biz_ref_id = ?", walletID, "order_pay", orderBizRefType, orderID).
+		Pluck("id", &debitTxIDs).Error; err != nil {
+		return fmt.Errorf("查询订单扣款流水失败: %w", err)
+	}
+	return restoreCreditLots(ctx, db, debitTxIDs, amount, refundTxID)
+}
+
+// restoreCreditLots 恢复指定扣款流水扣减的有效期额度，调用方需已持有钱包行锁
+// 按扣减的倒序恢复，累计不超过 amount；已过期的额度按原过期时间新建一笔并关联 creditTxID
+func restoreCreditLots(ctx context.Context, db *gorm.DB, debitTxIDs []int64, amount, creditTxID int64) error {
+	if len(debitTxIDs) == 0 {
+		return nil
+	}
+	var usages []WalletCreditLotUsageRecord
+	if err := db.WithContext(ctx).
+		Where("tx_id IN ? AND restored < amount", debitTxIDs).
+		Order("id DESC").
+		Find(&usages).Error; err != nil {
+		return fmt.Errorf("查询有效期额度扣减明细失败: %w", err)
+	}
+
+	now := time.Now().Unix()
+	for i := 0; i < len(usages) && amount > 0; i++ {
+		u := &usages[i]
+		restored := min(u.Amount-u.Restored, amount)
+		var lot WalletCreditLotRecord
+		if err := db.WithContext(ctx).Where("id = ?", u.LotID).First(&lot).Error; err != nil {
+			return fmt.Errorf("查询有效期额度失败: %w", err)
+		}
+		if lot.ExpiresAt > now {
+			if err := db.WithContext(ctx).Model(&WalletCreditLotRecord{}).Where("id = ?", lot.ID).
+				Update("remaining", gorm.Expr("remaining + ?", restored)).Error; err != nil {
+				return fmt.Errorf("恢复有效期额度失败: %w", err)
+			}
+		} else {
+			reissued, err := createCreditLot(ctx, db, lot.WalletID, lot.CustomerID, restored, lot.ExpiresAt, lot.Note)
+			if err != nil {
+				return err
+			}
+			if err := bindCreditLotSource(ctx, db, reissued, creditTxID); err != nil {
+				return err
+			}
+		}
+		if err := db.WithContext(ctx).Model(&WalletCreditLotUsageRecord{}).Where("id = ?", u.ID).
+			Update("restored", gorm.Expr("restored + ?", restored)).Error; err != nil {
+			return fmt.Errorf("更新有效期额度扣减明细失败: %w", err)
+		}
+		amount -= restored
+	}
+	return nil
+}
+
+// transferCreditLots 转账时把转出流水扣减的有效期额度按原过期时间在转入方钱包重建，调用方需已持有双方钱包行锁
+// 转出方扣减须以 debitTxID 记录明细；重建的额度关联转入流水 creditTxID，到期后由过期任务从转入方扣回
+func transferCreditLots(ctx context.Context, db *gorm.DB, debitTxID, toWalletID, toCustomerID, creditTxID int64) error {
+	var usages []WalletCreditLotUsageRecord
+	if err := db.WithContext(ctx).Where("tx_id = ?", debitTxID).Order("id ASC").Find(&usages).Error; err != nil {
+		return fmt.Errorf("查询有效期额度扣减明细失败: %w", err)
+	}
+	for _, u := range usages {
+		var lot WalletCreditLotRecord
+		if err := db.WithContext(ctx).Where("id = ?", u.LotID).First(&lot).Error; err != nil {
+			return fmt.Errorf("查询有效期额度失败: %w", err)
+		}
+		moved, err := createCreditLot(ctx, db, toWalletID, toCustomerID, u.Amount, lot.ExpiresAt, lot.Note)
+		if err != nil {
+			return err
+		}
+		if err := bindCreditLotSource(ctx, db, moved, creditTxID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findDueCreditLots 查询钱包已到期且仍有剩余的额度，调用方需已持有钱包行锁
+func findDueCreditLots(ctx context.Context, db *gorm.DB, walletID, now int64) ([]WalletCreditLotRecord, error) {
+	var lots []WalletCreditLotRecord
+	if err := db.WithContext(ctx).
+		Where("wallet_id = ? AND remaining > 0 AND expires_at <= ?", walletID, now).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return nil, fmt.Errorf("查询到期额度失败: %w", err)
+	}
+	return lots, nil
+}
+
+// closeCreditLot 记录额度过期扣回结果并清零剩余
+func closeCreditLot(ctx context.Context, db *gorm.DB, lot *WalletCreditLotRecord, expired, txID int64) error {
+	if err := db.WithContext(ctx).Model(lot).Updates(map[string]interface{}{
+		"remaining":      0,
+		"expired_amount": expired,
+		"expired_tx_id":  txID,
+	}).Error; err != nil {
+		return fmt.Errorf("更新有效期额度失败: %w", err)
+	}
+	return nil
+}
+
+// listExpiringCredits 查询客户在 before 之前到期且仍有剩余的额度，按过期时间升序
+func listExpiringCredits(ctx context.Context, db *gorm.DB, customerID, before int64) ([]billing.CreditLot, error) {
+	var records []WalletCreditLotRecord
+	if err := db.WithContext(ctx).
+		Where("customer_id = ? AND remaining > 0 AND expires_at <= ?", customerID, before).
+		Order("expires_at ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询有效期额度失败: %w", err)
+	}
+	lots := make([]billing.CreditLot, len(records))
+	for i := range records {
+		lots[i] = *toCreditLot(&records[i])
+	}
+	return lots, nil
+}
+
+// expireCredits 按客户分批处理已到期额度，每个客户由 expireFn 在独立事务内扣回
+func expireCredits(ctx context.Context, db *gorm.DB, opts billing.ExpireCreditsOptions,
+	expireFn func(ctx context.Context, customerID, now int64) (int, int64, error)) (*billing.ExpireCreditsReport, error) {
+	report := &billing.ExpireCreditsReport{StartedAt: time.Now().Unix()}
+	now := opts.Now
+	if now <= 0 {
+		now = report.StartedAt
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	var lastID int64
+	for {
+		var customerIDs []int64
+		if err := db.WithContext(ctx).Model(&WalletCreditLotRecord{}).
+			Distinct("customer_id").
+			Where("remaining > 0 AND expires_at <= ? AND customer_id > ?", now, lastID).
+			Order("customer_id ASC").
+			Limit(opts.BatchSize).
+			Pluck("customer_id", &customerIDs).Error; err != nil {
+			return nil, fmt.Errorf("查询到期额度失败: %w", err)
+		}
+		for _, customerID := range customerIDs {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			lots, amount, err := expireFn(ctx, customerID, now)
+			if err != nil {
+				return nil, fmt.Errorf("处理客户 %d 的到期额度失败: %w", customerID, err)
+			}
+			report.WalletsScanned++
+			report.LotsExpired += lots
+			report.AmountExpired += amount
+		}
+		if len(customerIDs) < opts.BatchSize {
+			break
+		}
+		lastID = customerIDs[len(customerIDs)-1]
+	}
+	report.FinishedAt = time.Now().Unix()
+	return report, nil
+}
+
+func validateGrantCredit(req *billing.GrantCreditRequest) error {
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "发放金额必须大于0")
+	}
+	if req.ExpiresAt <= time.Now().Unix() {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "过期时间必须晚于当前时间")
+	}
+	if req.Idem == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "幂等键不能为空")
+	}
+	return nil
+}
+
+func toCreditLot(r *WalletCreditLotRecord) *billing.CreditLot {
+	return &billing.CreditLot{
+		ID:            r.ID,
+		WalletID:      r.WalletID,
+		CustomerID:    r.CustomerID,
+		SourceTxID:    r.SourceTxID,
+		Amount:        r.Amount,
+		Remaining:     r.Remaining,
+		ExpiresAt:     r.ExpiresAt,
+		ExpiredAmount: r.ExpiredAmount,
+		ExpiredTxID:   r.ExpiredTxID,
+		Note:          r.Note,
+		CreatedAt:     r.CreatedAt,
+	}
+}
+
+var _ billing.CreditExpiryService = (*BillingServiceImpl)(nil)
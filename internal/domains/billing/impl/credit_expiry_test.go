@@ -0,0 +1,192 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestWalletCreditExpiry 有效期额度：发放、按到期先后扣减、即将过期查询、到期扣回
+func TestWalletCreditExpiry(t *testing.T) {
+	db := setupWalletDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	svc := newBillingServiceImpl(db, tx)
+	customerID := int64(6001)
+	now := time.Now().Unix()
+	day := int64(86400)
+
+	require.NoError(t, svc.Credit(ctx, customerID, 10000, "充值", "credit_seed_1"))
+
+	var soon, later *billing.CreditLot
+	t.Run("发放额度并以 adjust_in 入账", func(t *testing.T) {
+		var err error
+		later, err = svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 3000, ExpiresAt: now + 30*day, OperatorID: 1, Note: "服务补偿", Idem: "credit_grant_1"})
+		require.NoError(t, err)
+		soon, err = svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 2000, ExpiresAt: now + 3*day, OperatorID: 1, Note: "活动赠送", Idem: "credit_grant_2"})
+		require.NoError(t, err)
+		assert.NotZero(t, soon.SourceTxID)
+		assert.Equal(t, int64(2000), soon.Remaining)
+
+		txs, err := svc.GetTransactionHistory(ctx, customerID, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, txs, 3)
+		assert.Equal(t, "adjust_in", txs[0].Type)
+		assert.Equal(t, creditLotBizRefType, txs[0].BizRefType)
+		assert.Equal(t, soon.ID, txs[0].BizRefID)
+
+		again, err := svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 2000, ExpiresAt: now + 3*day, Idem: "credit_grant_2"})
+		require.NoError(t, err)
+		assert.Equal(t, soon.ID, again.ID)
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(15000), balance)
+
+		_, err = svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 100, ExpiresAt: now - day, Idem: "credit_grant_3"})
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+	})
+
+	t.Run("扣款优先使用最早到期的额度", func(t *testing.T) {
+		require.NoError(t, svc.DebitForOrder(ctx, customerID, 1, 2500, "credit_debit_1"))
+
+		lots, err := svc.ListExpiringCredits(ctx, customerID, now+60*day)
+		require.NoError(t, err)
+		require.Len(t, lots, 1, "最早到期的额度已用完")
+		assert.Equal(t, later.ID, lots[0].ID)
+		assert.Equal(t, int64(2500), lots[0].Remaining)
+
+		lots, err = svc.ListExpiringCredits(ctx, customerID, now+7*day)
+		require.NoError(t, err)
+		assert.Empty(t, lots)
+	})
+
+	t.Run("到期额度以 adjust_out 扣回且可重复执行", func(t *testing.T) {
+		report, err := svc.ExpireCredits(ctx, billing.ExpireCreditsOptions{Now: now + 31*day})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.WalletsScanned)
+		assert.Equal(t, 1, report.LotsExpired)
+		assert.Equal(t, int64(2500), report.AmountExpired)
+
+		txs, err := svc.GetTransactionHistory(ctx, customerID, 1, 10)
+		require.NoError(t, err)
+		assert.Equal(t, "adjust_out", txs[0].Type)
+		assert.Equal(t, creditLotBizRefType, txs[0].BizRefType)
+		assert.Equal(t, later.ID, txs[0].BizRefID)
+		assert.Equal(t, int64(2500), txs[0].Amount)
+
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), balance)
+
+		report, err = svc.ExpireCredits(ctx, billing.ExpireCreditsOptions{Now: now + 31*day})
+		require.NoError(t, err)
+		assert.Zero(t, report.LotsExpired)
+	})
+
+	t.Run("充值赠送按规则有效天数过期，退回时只收回未过期部分", func(t *testing.T) {
+		_, err := svc.CreateBonusRule(ctx, billing.SaveBonusRuleRequest{Name: "充100送20限7天", MinAmount: 10000, BonusAmount: 2000, ValidDays: 7, IsActive: true})
+		require.NoError(t, err)
+		r, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 10000, Idem: "credit_recharge_1"})
+		require.NoError(t, err)
+		require.Equal(t, int64(2000), r.BonusAmount)
+
+		lots, err := svc.ListExpiringCredits(ctx, customerID, now+8*day)
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, r.BonusTxID, lots[0].SourceTxID)
+
+		require.NoError(t, svc.DebitForOrder(ctx, customerID, 2, 500, "credit_debit_2"))
+		report, err := svc.ExpireCredits(ctx, billing.ExpireCreditsOptions{Now: now + 8*day})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), report.AmountExpired)
+
+		_, err = svc.RefundRecharge(ctx, customerID, r.ID, 9, "客户申请退款")
+		require.NoError(t, err)
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9500), balance, "赠送已消费500、过期扣回1500，退回时仅收回剩余的500")
+	})
+
+	t.Run("双写同步发放与过期扣回", func(t *testing.T) {
+		createTruthTables(t, db)
+		_, err := NewTruthBackfiller(db).Backfill(ctx, billing.BackfillOptions{})
+		require.NoError(t, err)
+
+		migrating := NewMigratingService(db, tx, false)
+		_, err = migrating.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 1000, ExpiresAt: now + day, Idem: "credit_grant_4"})
+		require.NoError(t, err)
+		report, err := migrating.ExpireCredits(ctx, billing.ExpireCreditsOptions{Now: now + 2*day})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), report.AmountExpired)
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("真相表发放与过期扣回", func(t *testing.T) {
+		truth := NewTruthServiceWithTx(db, tx)
+		lot, err := truth.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: customerID, Amount: 800, ExpiresAt: now + day, Idem: "credit_grant_5"})
+		require.NoError(t, err)
+		require.NoError(t, truth.DebitForOrder(ctx, customerID, 3, 300, "credit_debit_3"))
+
+		lots, err := truth.ListExpiringCredits(ctx, customerID, now+2*day)
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, lot.ID, lots[0].ID)
+		assert.Equal(t, int64(500), lots[0].Remaining)
+
+		report, err := truth.ExpireCredits(ctx, billing.ExpireCreditsOptions{Now: now + 2*day})
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), report.AmountExpired)
+	})
+
+	t.Run("订单退款恢复扣款使用的额度并保留原过期时间", func(t *testing.T) {
+		other := int64(6002)
+		require.NoError(t, svc.Credit(ctx, other, 500, "充值", "credit_restore_seed"))
+		soonLot, err := svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: other, Amount: 1000, ExpiresAt: now + 3*day, Idem: "credit_restore_grant_1"})
+		require.NoError(t, err)
+		laterLot, err := svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: other, Amount: 2000, ExpiresAt: now + 30*day, Idem: "credit_restore_grant_2"})
+		require.NoError(t, err)
+		require.NoError(t, svc.DebitForOrder(ctx, other, 11, 3200, "credit_restore_debit"))
+
+		lotsOf := func() []WalletCreditLotRecord {
+			var lots []WalletCreditLotRecord
+			require.NoError(t, db.Where("customer_id = ?", other).Order("id ASC").Find(&lots).Error)
+			return lots
+		}
+		lots := lotsOf()
+		require.Len(t, lots, 2)
+		assert.Zero(t, lots[0].Remaining)
+		assert.Zero(t, lots[1].Remaining)
+
+		// 部分退款按扣减倒序恢复，先恢复后扣减的额度；重放不会重复恢复
+		require.NoError(t, svc.CreditForRefund(ctx, other, 11, 1500, "credit_restore_refund_1"))
+		require.NoError(t, svc.CreditForRefund(ctx, other, 11, 1500, "credit_restore_refund_1"))
+		lots = lotsOf()
+		assert.Zero(t, lots[0].Remaining)
+		assert.Equal(t, int64(1500), lots[1].Remaining)
+		assert.Equal(t, laterLot.ExpiresAt, lots[1].ExpiresAt)
+
+		// 已过期的额度按原过期时间重新发放一笔，由过期任务扣回
+		expiredAt := now - 1
+		require.NoError(t, db.Model(&WalletCreditLotRecord{}).Where("id = ?", soonLot.ID).Update("expires_at", expiredAt).Error)
+		require.NoError(t, svc.CreditForRefund(ctx, other, 11, 1700, "credit_restore_refund_2"))
+		refund, err := svc.GetTransactionByIdemKey(ctx, "credit_restore_refund_2")
+		require.NoError(t, err)
+		lots = lotsOf()
+		require.Len(t, lots, 3)
+		assert.Zero(t, lots[0].Remaining)
+		assert.Equal(t, int64(2000), lots[1].Remaining)
+		assert.Equal(t, int64(1000), lots[2].Remaining)
+		assert.Equal(t, expiredAt, lots[2].ExpiresAt)
+		assert.Equal(t, refund.ID, lots[2].SourceTxID)
+
+		balance, err := svc.GetBalance(ctx, other)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3500), balance, "超出额度扣减的200退回为永久余额")
+	})
+}
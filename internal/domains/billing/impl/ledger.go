@@ -0,0 +1,374 @@
+package impl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+)
+
+// statementSignAlgorithm 对账单签名算法
+const statementSignAlgorithm = "HMAC-SHA256"
+
+// statementMaxRange 单份对账单允许的最大时间跨度
+const statementMaxRange = 366 * 24 * time.Hour
+
+// ===== 流水哈希链 =====
+
+// ledgerContent 参与哈希计算的流水内容，字段顺序固定，新增字段只能追加
+// 不含流水ID：哈希在写入前计算，且真相表复制流水时沿用旧表ID，两边的链完全一致
+type ledgerContent struct {
+	WalletID       int64  `json:"wallet_id"`
+	Direction      string `json:"direction"`
+	Amount         int64  `json:"amount"`
+	Type           string `json:"type"`
+	BizRefType     string `json:"biz_ref_type"`
+	BizRefID       int64  `json:"biz_ref_id"`
+	IdempotencyKey string `json:"idempotency_key"`
+	OperatorID     int64  `json:"operator_id"`
+	ReasonCode     string `json:"reason_code"`
+	Note           string `json:"note"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// ledgerHash 计算流水哈希：SHA-256(上一笔流水哈希 + 流水内容 JSON)，十六进制小写
+func ledgerHash(prevHash string, t *billing.Transaction) string {
+	content, _ := json.Marshal(ledgerContent{
+		WalletID:       t.WalletID,
+		Direction:      t.Direction,
+		Amount:         t.Amount,
+		Type:           t.Type,
+		BizRefType:     t.BizRefType,
+		BizRefID:       t.BizRefID,
+		IdempotencyKey: t.IdempotencyKey,
+		OperatorID:     t.OperatorID,
+		ReasonCode:     t.ReasonCode,
+		Note:           t.Note,
+		CreatedAt:      t.CreatedAt,
+	})
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sealWalletTx 为即将写入旧表的流水计算 prev_hash 与 hash
+// 调用方需持有钱包行锁，保证同一钱包的流水串行追加；CreatedAt 必须在调用前赋值
+func sealWalletTx(ctx context.Context, txQuery *query.Query, t *model.WalletTransaction) error {
+	var prevHash string
+	last, err := txQuery.WalletTransaction.WithContext(ctx).
+		Select(txQuery.WalletTransaction.Hash).
+		Where(txQuery.WalletTransaction.WalletID.Eq(t.WalletID)).
+		Order(txQuery.WalletTransaction.ID.Desc()).
+		First()
+	if err == nil {
+		prevHash = last.Hash
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("查询上一笔流水失败: %w", err)
+	}
+	t.PrevHash = prevHash
+	t.Hash = ledgerHash(prevHash, toBillingTransaction(t))
+	return nil
+}
+
+// sealBilWalletTx 为即将写入真相表的流水计算 prev_hash 与 hash，要求同 sealWalletTx
+func sealBilWalletTx(tx *gorm.DB, rec *BilWalletTx) error {
+	var prevHashes []string
+	if err := tx.Model(&BilWalletTx{}).
+		Where("wallet_id = ?", rec.WalletID).
+		Order("id DESC").Limit(1).
+		Pluck("hash", &prevHashes).Error; err != nil {
+		return fmt.Errorf("查询上一笔流水失败: %w", err)
+	}
+	rec.PrevHash = ""
+	if len(prevHashes) > 0 {
+		rec.PrevHash = prevHashes[0]
+	}
+	rec.Hash = ledgerHash(rec.PrevHash, toTruthTransaction(rec))
+	return nil
+}
+
+// ===== 哈希链校验 =====
+
+// LedgerChainVerifier 流水哈希链校验实现
+// 启用哈希链之前写入的流水 hash 为空，视为链前的历史流水，不参与校验；
+// 链前流水的范围由迁移记录在 wallet_ledger_chain 中，起点之后 hash 为空的流水按断点报告，抹去哈希无法绕过校验
+type LedgerChainVerifier struct {
+	db *gorm.DB
+}
+
+// NewLedgerVerifier 创建流水哈希链校验实例
+func NewLedgerVerifier(db *gorm.DB) *LedgerChainVerifier {
+	return &LedgerChainVerifier{db: db}
+}
+
+// VerifyLedger 校验全部钱包的流水哈希链
+func (v *LedgerChainVerifier) VerifyLedger(ctx context.Context, opts billing.LedgerVerifyOptions) (*billing.LedgerVerifyReport, error) {
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = []string{billing.ReconcileSourceLegacy, billing.ReconcileSourceTruth}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	var chain WalletLedgerChainRecord
+	if err := v.db.WithContext(ctx).Order("id ASC").First(&chain).Error; err != nil {
+		return nil, fmt.Errorf("查询流水哈希链起点失败，请确认已执行 wallet_ledger_chain 迁移: %w", err)
+	}
+
+	report := &billing.LedgerVerifyReport{
+		StartedAt:      time.Now().Unix(),
+		Sources:        []string{},
+		ChainStartTxID: chain.StartTxID,
+		Breaks:         []billing.LedgerBreak{},
+	}
+	for _, source := range sources {
+		tables, ok := reconcileSources[source]
+		if !ok {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("未知的校验数据源: %s", source))
+		}
+		// 尚未迁移真相表的环境跳过对应数据源
+		migrator := v.db.WithContext(ctx).Migrator()
+		if !migrator.HasTable(tables.wallets) || !migrator.HasTable(tables.transactions) {
+			continue
+		}
+		report.Sources = append(report.Sources, source)
+		if err := v.verifySource(ctx, source, tables, opts.BatchSize, report); err != nil {
+			return nil, err
+		}
+	}
+	report.FinishedAt = time.Now().Unix()
+	return report, nil
+}
+
+// verifySource 按钱包ID分批校验单个数据源
+func (v *LedgerChainVerifier) verifySource(ctx context.Context, source string, tables reconcileTables, batchSize int, report *billing.LedgerVerifyReport) error {
+	var lastID int64
+	for {
+		var wallets []reconcileWallet
+		if err := v.db.WithContext(ctx).Table(tables.wallets).
+			Select("id, customer_id, balance").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Scan(&wallets).Error; err != nil {
+			return fmt.Errorf("查询钱包失败(%s): %w", source, err)
+		}
+		if len(wallets) == 0 {
+			return nil
+		}
+
+		for _, w := range wallets {
+			report.WalletsChecked++
+			brk, err := v.verifyWallet(ctx, tables.transactions, w.ID, batchSize, report.ChainStartTxID, report)
+			if err != nil {
+				return fmt.Errorf("校验钱包流水失败(%s): %w", source, err)
+			}
+			if brk != nil {
+				brk.Source, brk.WalletID, brk.CustomerID = source, w.ID, w.CustomerID
+				report.Breaks = append(report.Breaks, *brk)
+			}
+		}
+
+		if len(wallets) < batchSize {
+			return nil
+		}
+		lastID = wallets[len(wallets)-1].ID
+	}
+}
+
+// verifyWallet 按流水ID顺序分段读取单个钱包的流水并沿链校验，返回第一个断点，链完整时返回 nil
+// startTxID 为哈希链起点，只有不大于该值的流水允许不带哈希
+func (v *LedgerChainVerifier) verifyWallet(ctx context.Context, table string, walletID int64, batchSize int, startTxID int64, report *billing.LedgerVerifyReport) (*billing.LedgerBreak, error) {
+	var (
+		lastID   int64
+		prevHash string
+		sealed   bool
+	)
+	for {
+		var txs []model.WalletTransaction
+		if err := v.db.WithContext(ctx).Table(table).
+			Where("wallet_id = ? AND id > ?", walletID, lastID).
+			Order("id ASC").
+			Limit(batchSize).
+			Find(&txs).Error; err != nil {
+			return nil, err
+		}
+
+		for i := range txs {
+			t := &txs[i]
+			if t.Hash == "" {
+				if sealed {
+					return &billing.LedgerBreak{TxID: t.ID, Reason: billing.LedgerBreakUnsealedAfterSealed, ExpectedHash: prevHash}, nil
+				}
+				if t.ID > startTxID {
+					return &billing.LedgerBreak{TxID: t.ID, Reason: billing.LedgerBreakUnsealedAfterStart}, nil
+				}
+				report.Unsealed++
+				continue
+			}
+			sealed = true
+			report.TransactionsChecked++
+			if t.PrevHash != prevHash {
+				return &billing.LedgerBreak{TxID: t.ID, Reason: billing.LedgerBreakPrevHashMismatch, ExpectedHash: prevHash, StoredHash: t.PrevHash}, nil
+			}
+			if expected := ledgerHash(t.PrevHash, toBillingTransaction(t)); t.Hash != expected {
+				return &billing.LedgerBreak{TxID: t.ID, Reason: billing.LedgerBreakHashMismatch, ExpectedHash: expected, StoredHash: t.Hash}, nil
+			}
+			prevHash = t.Hash
+		}
+
+		if len(txs) < batchSize {
+			return nil, nil
+		}
+		lastID = txs[len(txs)-1].ID
+	}
+}
+
+// ===== 签名对账单 =====
+
+// LedgerStatementService 钱包对账单导出实现，旧表与真相表共用，按数据源选择表
+type LedgerStatementService struct {
+	db         *gorm.DB
+	tables     reconcileTables
+	signingKey []byte
+}
+
+// NewLedgerStatementService 创建读取指定数据源的对账单服务实例
+// source 为 billing.ReconcileSourceLegacy 或 billing.ReconcileSourceTruth，signingKey 为空时导出返回错误
+func NewLedgerStatementService(db *gorm.DB, source, signingKey string) *LedgerStatementService {
+	return &LedgerStatementService{db: db, tables: reconcileSources[source], signingKey: []byte(signingKey)}
+}
+
+// ExportStatement 导出签名对账单
+// 期初余额为 From 之前流水的汇总，明细逐笔给出余额与流水哈希，HeadHash 为 To 之前最后一笔流水的哈希
+func (s *LedgerStatementService) ExportStatement(ctx context.Context, req billing.StatementRequest) (*billing.Statement, error) {
+	if req.From >= req.To {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "对账单起始时间必须早于截止时间")
+	}
+	if time.Duration(req.To-req.From)*time.Second > statementMaxRange {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "对账单时间跨度不能超过366天")
+	}
+	if len(s.signingKey) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeStatementUnsigned, "未配置对账单签名密钥 wallet.statementSigningKey，不能导出对账单")
+	}
+
+	db := s.db.WithContext(ctx)
+	var wallets []reconcileWallet
+	if err := db.Table(s.tables.wallets).Select("id, customer_id, balance").
+		Where("customer_id = ?", req.CustomerID).Limit(1).
+		Scan(&wallets).Error; err != nil {
+		return nil, fmt.Errorf("查询钱包失败: %w", err)
+	}
+	if len(wallets) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeWalletNotFound, "钱包不存在")
+	}
+	wallet := wallets[0]
+
+	// 1. 期初余额与期初哈希
+	opening, err := s.sumBefore(db, wallet.ID, req.From)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &billing.Statement{
+		CustomerID:     req.CustomerID,
+		WalletID:       wallet.ID,
+		From:           req.From,
+		To:             req.To,
+		OpeningBalance: opening,
+		Entries:        []billing.StatementEntry{},
+		SignAlgorithm:  statementSignAlgorithm,
+	}
+	var heads []string
+	if err := db.Table(s.tables.transactions).
+		Where("wallet_id = ? AND created_at < ?", wallet.ID, req.From).
+		Order("id DESC").Limit(1).
+		Pluck("hash", &heads).Error; err != nil {
+		return nil, fmt.Errorf("查询期初流水失败: %w", err)
+	}
+	if len(heads) > 0 {
+		stmt.HeadHash = heads[0]
+	}
+
+	// 2. 期间明细
+	var txs []model.WalletTransaction
+	if err := db.Table(s.tables.transactions).
+		Where("wallet_id = ? AND created_at >= ? AND created_at < ?", wallet.ID, req.From, req.To).
+		Order("id ASC").
+		Find(&txs).Error; err != nil {
+		return nil, fmt.Errorf("查询钱包流水失败: %w", err)
+	}
+	balance := stmt.OpeningBalance
+	for _, t := range txs {
+		if t.Direction == "credit" {
+			balance += t.Amount
+			stmt.TotalCredit += t.Amount
+		} else {
+			balance -= t.Amount
+			stmt.TotalDebit += t.Amount
+		}
+		stmt.Entries = append(stmt.Entries, billing.StatementEntry{
+			TxID:         t.ID,
+			Direction:    t.Direction,
+			Amount:       t.Amount,
+			Type:         t.Type,
+			BizRefType:   t.BizRefType,
+			BizRefID:     t.BizRefID,
+			Note:         t.Note,
+			CreatedAt:    t.CreatedAt,
+			BalanceAfter: balance,
+			Hash:         t.Hash,
+		})
+		stmt.HeadHash = t.Hash
+	}
+	stmt.ClosingBalance = balance
+	stmt.GeneratedAt = time.Now().Unix()
+
+	// 3. 签名
+	if stmt.Signature, err = signStatement(stmt, s.signingKey); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// sumBefore 汇总钱包在 before 之前的流水余额
+func (s *LedgerStatementService) sumBefore(db *gorm.DB, walletID, before int64) (int64, error) {
+	var balance int64
+	if err := db.Table(s.tables.transactions).
+		Select("COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0)").
+		Where("wallet_id = ? AND created_at < ?", walletID, before).
+		Scan(&balance).Error; err != nil {
+		return 0, fmt.Errorf("汇总期初余额失败: %w", err)
+	}
+	return balance, nil
+}
+
+// signStatement 计算对账单签名：Signature 置空后整份对账单 JSON 的 HMAC-SHA256，十六进制小写
+func signStatement(stmt *billing.Statement, key []byte) (string, error) {
+	unsigned := *stmt
+	unsigned.Signature = ""
+	payload, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", fmt.Errorf("序列化对账单失败: %w", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+var (
+	_ billing.LedgerVerifier   = (*LedgerChainVerifier)(nil)
+	_ billing.StatementService = (*LedgerStatementService)(nil)
+)
@@ -0,0 +1,183 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// TestWalletLedgerHashChain 流水哈希链：逐笔链接、校验发现篡改与删除、真相表沿用同一条链、签名对账单
+func TestWalletLedgerHashChain(t *testing.T) {
+	db := setupWalletDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	svc := newBillingServiceImpl(db, tx)
+	verifier := NewLedgerVerifier(db)
+	customerID := int64(8001)
+
+	// 启用哈希链之前的历史流水
+	require.NoError(t, svc.Credit(ctx, customerID, 10000, "充值", "ledger_seed_1"))
+	require.NoError(t, db.Model(&model.WalletTransaction{}).Where("idempotency_key = ?", "ledger_seed_1").
+		Updates(map[string]interface{}{"prev_hash": "", "hash": ""}).Error)
+	seed, err := svc.GetTransactionByIdemKey(ctx, "ledger_seed_1")
+	require.NoError(t, err)
+	createLedgerChain(t, db, seed.ID)
+
+	require.NoError(t, svc.Credit(ctx, customerID, 5000, "充值", "ledger_credit_1"))
+	require.NoError(t, svc.DebitForOrder(ctx, customerID, 91, 3000, "ledger_debit_1"))
+	require.NoError(t, svc.CreditForRefund(ctx, customerID, 91, 1000, "ledger_refund_1"))
+	createWalletCustomers(t, db, 8002)
+	_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: customerID, ToCustomerID: 8002, Amount: 2000, Idem: "ledger_transfer_1"})
+	require.NoError(t, err)
+
+	t.Run("每笔流水链接上一笔流水的哈希", func(t *testing.T) {
+		txs, err := svc.GetTransactionHistory(ctx, customerID, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, txs, 5)
+		// 交易历史按时间倒序，最早的一笔为链前流水
+		assert.Empty(t, txs[4].Hash)
+		assert.Empty(t, txs[3].PrevHash, "链前流水之后的第一笔流水从空哈希开始")
+		for i := 3; i >= 0; i-- {
+			assert.Len(t, txs[i].Hash, 64)
+			assert.Equal(t, ledgerHash(txs[i].PrevHash, &txs[i]), txs[i].Hash)
+			if i < 3 {
+				assert.Equal(t, txs[i+1].Hash, txs[i].PrevHash)
+			}
+		}
+	})
+
+	t.Run("链完整时校验通过", func(t *testing.T) {
+		report, err := verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{BatchSize: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{billing.ReconcileSourceLegacy}, report.Sources)
+		assert.Equal(t, seed.ID, report.ChainStartTxID)
+		assert.Equal(t, 2, report.WalletsChecked)
+		assert.Equal(t, 5, report.TransactionsChecked)
+		assert.Equal(t, 1, report.Unsealed)
+		assert.Empty(t, report.Breaks)
+	})
+
+	t.Run("签名对账单", func(t *testing.T) {
+		st := NewLedgerStatementService(db, billing.ReconcileSourceLegacy, "test-key")
+		now := time.Now().Unix()
+		stmt, err := st.ExportStatement(ctx, billing.StatementRequest{CustomerID: customerID, From: now - 3600, To: now + 3600})
+		require.NoError(t, err)
+		assert.Zero(t, stmt.OpeningBalance)
+		assert.Equal(t, int64(16000), stmt.TotalCredit)
+		assert.Equal(t, int64(5000), stmt.TotalDebit)
+		assert.Equal(t, int64(11000), stmt.ClosingBalance)
+		require.Len(t, stmt.Entries, 5)
+		assert.Equal(t, stmt.ClosingBalance, stmt.Entries[4].BalanceAfter)
+		assert.Equal(t, stmt.Entries[4].Hash, stmt.HeadHash)
+		assert.Equal(t, "HMAC-SHA256", stmt.SignAlgorithm)
+
+		expected, err := signStatement(stmt, []byte("test-key"))
+		require.NoError(t, err)
+		assert.Equal(t, expected, stmt.Signature)
+		other, err := signStatement(stmt, []byte("other-key"))
+		require.NoError(t, err)
+		assert.NotEqual(t, other, stmt.Signature)
+
+		tampered := *stmt
+		tampered.ClosingBalance++
+		resigned, err := signStatement(&tampered, []byte("test-key"))
+		require.NoError(t, err)
+		assert.NotEqual(t, resigned, stmt.Signature, "修改对账单内容后签名不再匹配")
+
+		_, err = st.ExportStatement(ctx, billing.StatementRequest{CustomerID: 8999, From: now - 3600, To: now})
+		assertBusinessCode(t, err, common.ErrCodeWalletNotFound)
+		_, err = st.ExportStatement(ctx, billing.StatementRequest{CustomerID: customerID, From: now - 400*86400, To: now})
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+		_, err = NewLedgerStatementService(db, billing.ReconcileSourceLegacy, "").
+			ExportStatement(ctx, billing.StatementRequest{CustomerID: customerID, From: now - 3600, To: now})
+		assertBusinessCode(t, err, common.ErrCodeStatementUnsigned)
+	})
+
+	t.Run("双写与真相表沿用同一条链", func(t *testing.T) {
+		createTruthTables(t, db)
+		_, err := NewTruthBackfiller(db).Backfill(ctx, billing.BackfillOptions{})
+		require.NoError(t, err)
+		migrating := NewMigratingService(db, tx, false)
+		require.NoError(t, migrating.Credit(ctx, customerID, 700, "充值", "ledger_credit_2"))
+		assertTruthMirrorsLegacy(t, db)
+
+		truth := NewTruthServiceWithTx(db, tx)
+		require.NoError(t, truth.DebitForOrder(ctx, customerID, 92, 300, "ledger_debit_2"))
+		report, err := verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{Sources: []string{billing.ReconcileSourceTruth}})
+		require.NoError(t, err)
+		assert.Equal(t, 7, report.TransactionsChecked)
+		assert.Empty(t, report.Breaks)
+	})
+
+	t.Run("篡改金额与删除流水被报告为断点", func(t *testing.T) {
+		tampered, err := svc.GetTransactionByIdemKey(ctx, "ledger_debit_1")
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&model.WalletTransaction{}).Where("id = ?", tampered.ID).Update("amount", 300).Error)
+
+		report, err := verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{Sources: []string{billing.ReconcileSourceLegacy}})
+		require.NoError(t, err)
+		require.Len(t, report.Breaks, 1)
+		brk := report.Breaks[0]
+		assert.Equal(t, customerID, brk.CustomerID)
+		assert.Equal(t, tampered.ID, brk.TxID)
+		assert.Equal(t, billing.LedgerBreakHashMismatch, brk.Reason)
+		assert.Equal(t, tampered.Hash, brk.StoredHash)
+
+		removed, err := NewTruthServiceWithTx(db, tx).GetTransactionByIdemKey(ctx, "ledger_refund_1")
+		require.NoError(t, err)
+		require.NoError(t, db.Delete(&BilWalletTx{}, removed.ID).Error)
+		report, err = verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{Sources: []string{billing.ReconcileSourceTruth}})
+		require.NoError(t, err)
+		require.Len(t, report.Breaks, 1)
+		assert.Equal(t, billing.LedgerBreakPrevHashMismatch, report.Breaks[0].Reason)
+		assert.Equal(t, removed.Hash, report.Breaks[0].StoredHash, "下一笔流水仍指向被删除流水的哈希")
+	})
+
+	t.Run("抹去钱包全部哈希被报告为断点", func(t *testing.T) {
+		// 收款方钱包只有一笔转入流水，抹去哈希后看起来像链前流水，但其ID在链起点之后
+		transferIn, err := svc.GetTransactionHistory(ctx, 8002, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, transferIn, 1)
+		require.NoError(t, db.Model(&model.WalletTransaction{}).Where("id = ?", transferIn[0].ID).
+			Updates(map[string]interface{}{"prev_hash": "", "hash": ""}).Error)
+
+		report, err := verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{Sources: []string{billing.ReconcileSourceLegacy}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Unsealed, "只有链起点之前的流水计为链前流水")
+		var brk *billing.LedgerBreak
+		for i := range report.Breaks {
+			if report.Breaks[i].CustomerID == 8002 {
+				brk = &report.Breaks[i]
+			}
+		}
+		require.NotNil(t, brk)
+		assert.Equal(t, transferIn[0].ID, brk.TxID)
+		assert.Equal(t, billing.LedgerBreakUnsealedAfterStart, brk.Reason)
+	})
+
+	t.Run("缺少链起点时拒绝校验", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM wallet_ledger_chain").Error)
+		_, err := verifier.VerifyLedger(ctx, billing.LedgerVerifyOptions{})
+		assert.Error(t, err)
+	})
+}
+
+// createLedgerChain 创建哈希链起点表并写入起点，对应迁移 create_wallet_ledger_chain_table
+func createLedgerChain(t *testing.T, db *gorm.DB, startTxID int64) {
+	require.NoError(t, db.Exec(`
+		CREATE TABLE wallet_ledger_chain (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			start_tx_id INTEGER NOT NULL,
+			created_at INTEGER NOT NULL
+		)
+	`).Error)
+	require.NoError(t, db.Create(&WalletLedgerChainRecord{StartTxID: startTxID, CreatedAt: time.Now().Unix()}).Error)
+}
@@ -0,0 +1,341 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+	"crm_lite/internal/dto"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigratingService 钱包迁移期的 billing 服务（dual_write / read_compare 模式）
+// 所有请求以 BillingServiceImpl（旧表）为准；写入成功后在同一事务内把受影响的钱包同步到真相表，
+// 同步失败时整笔写入回滚。compareReads 开启时，读取请求额外读取真相表并记录不一致，返回值仍以旧表为准
+type MigratingService struct {
+	*BillingServiceImpl
+	compareReads bool
+	logger       *logger.Logger
+}
+
+// NewMigratingService 创建钱包迁移期的 billing 服务实例
+func NewMigratingService(db *gorm.DB, tx common.Tx, compareReads bool) *MigratingService {
+	return &MigratingService{
+		BillingServiceImpl: newBillingServiceImpl(db, tx),
+		compareReads:       compareReads,
+		logger:             logger.GetGlobalLogger(),
+	}
+}
+
+// ===== 双写 =====
+
+// dualWrite 在同一事务内执行旧表写入并同步到真相表
+func (s *MigratingService) dualWrite(ctx context.Context, customerID int64, fn func(ctx context.Context) error) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := fn(ctx); err != nil {
+			return err
+		}
+		return s.syncCustomer(ctx, customerID)
+	})
+}
+
+// syncCustomer 把客户的旧表钱包同步到真相表，钱包不存在时无需同步
+func (s *MigratingService) syncCustomer(ctx context.Context, customerID int64) error {
+	txDB := s.tx.GetDB(ctx)
+	var wallet model.Wallet
+	err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_id = ?", customerID).
+		Take(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询钱包失败: %w", err)
+	}
+	if _, err := syncTruthWallet(ctx, txDB, &wallet); err != nil {
+		return fmt.Errorf("同步真相表失败: %w", err)
+	}
+	return nil
+}
+
+// Credit 入账并同步到真相表
+func (s *MigratingService) Credit(ctx context.Context, customerID int64, amount int64, reason, idem string) error {
+	return s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		return s.BillingServiceImpl.Credit(ctx, customerID, amount, reason, idem)
+	})
+}
+
+// DebitForOrder 订单扣款并同步到真相表
+func (s *MigratingService) DebitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
+	return s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		return s.BillingServiceImpl.DebitForOrder(ctx, customerID, orderID, amount, idem)
+	})
+}
+
+// CreditForRefund 订单退款入账并同步到真相表
+func (s *MigratingService) CreditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
+	return s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		return s.BillingServiceImpl.CreditForRefund(ctx, customerID, orderID, amount, idem)
+	})
+}
+
+// FreezeWallet 冻结钱包并同步到真相表
+func (s *MigratingService) FreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	var info *billing.WalletInfo
+	err := s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		var err error
+		info, err = s.BillingServiceImpl.FreezeWallet(ctx, customerID, operatorID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// UnfreezeWallet 解冻钱包并同步到真相表
+func (s *MigratingService) UnfreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	var info *billing.WalletInfo
+	err := s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		var err error
+		info, err = s.BillingServiceImpl.UnfreezeWallet(ctx, customerID, operatorID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// CreateTransaction 创建手工交易并同步到真相表
+func (s *MigratingService) CreateTransaction(ctx context.Context, req *billing.CreateTransactionRequest) (*billing.Transaction, error) {
+	var result *billing.Transaction
+	err := s.dualWrite(ctx, req.CustomerID, func(ctx context.Context) error {
+		var err error
+		result, err = s.BillingServiceImpl.CreateTransaction(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Recharge 充值（含赠送）并同步到真相表
+func (s *MigratingService) Recharge(ctx context.Context, req billing.RechargeRequest) (*billing.Recharge, error) {
+	var result *billing.Recharge
+	err := s.dualWrite(ctx, req.CustomerID, func(ctx context.Context) error {
+		var err error
+		result, err = s.BillingServiceImpl.Recharge(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RefundRecharge 退回充值并同步到真相表
+func (s *MigratingService) RefundRecharge(ctx context.Context, customerID, rechargeID, operatorID int64, reason string) (*billing.Recharge, error) {
+	var result *billing.Recharge
+	err := s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		var err error
+		result, err = s.BillingServiceImpl.RefundRecharge(ctx, customerID, rechargeID, operatorID, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Transfer 钱包转账并把转出、转入双方钱包同步到真相表
+func (s *MigratingService) Transfer(ctx context.Context, req billing.TransferRequest) (*billing.Transfer, error) {
+	var result *billing.Transfer
+	err := s.dualWrite(ctx, req.FromCustomerID, func(ctx context.Context) error {
+		var err error
+		if result, err = s.BillingServiceImpl.Transfer(ctx, req); err != nil {
+			return err
+		}
+		return s.syncCustomer(ctx, req.ToCustomerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReverseTransaction 冲正流水并同步到真相表
+func (s *MigratingService) ReverseTransaction(ctx context.Context, req billing.ReverseTransactionRequest) (*billing.Transaction, error) {
+	var result *billing.Transaction
+	err := s.dualWrite(ctx, req.CustomerID, func(ctx context.Context) error {
+		var err error
+		result, err = s.BillingServiceImpl.ReverseTransaction(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GrantCredit 发放有效期额度并同步到真相表
+func (s *MigratingService) GrantCredit(ctx context.Context, req billing.GrantCreditRequest) (*billing.CreditLot, error) {
+	var result *billing.CreditLot
+	err := s.dualWrite(ctx, req.CustomerID, func(ctx context.Context) error {
+		var err error
+		result, err = s.BillingServiceImpl.GrantCredit(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ExpireCredits 扣回已到期额度，每个钱包扣回后在同一事务内同步到真相表
+func (s *MigratingService) ExpireCredits(ctx context.Context, opts billing.ExpireCreditsOptions) (*billing.ExpireCreditsReport, error) {
+	return expireCredits(ctx, s.db, opts, func(ctx context.Context, customerID, now int64) (lots int, amount int64, err error) {
+		err = s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+			lots, amount, err = s.BillingServiceImpl.expireCustomerCredits(ctx, customerID, now)
+			return err
+		})
+		return lots, amount, err
+	})
+}
+
+// CreateWallet 创建钱包并同步到真相表（Legacy兼容）
+func (s *MigratingService) CreateWallet(ctx context.Context, customerID int64, walletType string) (*model.Wallet, error) {
+	var wallet *model.Wallet
+	err := s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		var err error
+		wallet, err = s.BillingServiceImpl.CreateWallet(ctx, customerID, walletType)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// CreateTransactionLegacy 创建交易并同步到真相表（Legacy兼容）
+func (s *MigratingService) CreateTransactionLegacy(ctx context.Context, customerID int64, operatorID int64, req *dto.WalletTransactionRequest) error {
+	return s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		return s.BillingServiceImpl.CreateTransactionLegacy(ctx, customerID, operatorID, req)
+	})
+}
+
+// ProcessRefund 处理退款并同步到真相表（Legacy兼容）
+func (s *MigratingService) ProcessRefund(ctx context.Context, customerID int64, operatorID int64, req *dto.WalletRefundRequest) error {
+	return s.dualWrite(ctx, customerID, func(ctx context.Context) error {
+		return s.BillingServiceImpl.ProcessRefund(ctx, customerID, operatorID, req)
+	})
+}
+
+// ===== 读比对 =====
+
+// GetBalance 获取客户钱包余额，read_compare 模式下比对真相表
+func (s *MigratingService) GetBalance(ctx context.Context, customerID int64) (int64, error) {
+	balance, err := s.BillingServiceImpl.GetBalance(ctx, customerID)
+	if err == nil && s.compareReads {
+		s.compareWallet(ctx, customerID)
+	}
+	return balance, err
+}
+
+// GetWalletByCustomerID 获取客户钱包信息，read_compare 模式下比对真相表
+func (s *MigratingService) GetWalletByCustomerID(ctx context.Context, customerID int64) (*billing.WalletInfo, error) {
+	info, err := s.BillingServiceImpl.GetWalletByCustomerID(ctx, customerID)
+	var bizErr *common.BusinessError
+	if s.compareReads && (err == nil || errors.As(err, &bizErr) && bizErr.Code == common.ErrCodeWalletNotFound) {
+		s.compareWallet(ctx, customerID)
+	}
+	return info, err
+}
+
+// GetTransactionByIdemKey 根据幂等键查询交易，read_compare 模式下比对真相表
+func (s *MigratingService) GetTransactionByIdemKey(ctx context.Context, idem string) (*billing.Transaction, error) {
+	tx, err := s.BillingServiceImpl.GetTransactionByIdemKey(ctx, idem)
+	if err == nil && s.compareReads {
+		s.compareTransaction(ctx, tx)
+	}
+	return tx, err
+}
+
+// compareWallet 比对客户在旧表与真相表中的钱包，不一致时记录告警日志
+func (s *MigratingService) compareWallet(ctx context.Context, customerID int64) {
+	db := s.tx.GetDB(ctx).WithContext(ctx)
+	var legacy model.Wallet
+	legacyErr := db.Where("customer_id = ?", customerID).Take(&legacy).Error
+	var truth BilWallet
+	truthErr := db.Where("customer_id = ?", customerID).Take(&truth).Error
+
+	legacyFound, truthFound := legacyErr == nil, truthErr == nil
+	if (legacyErr != nil && !errors.Is(legacyErr, gorm.ErrRecordNotFound)) ||
+		(truthErr != nil && !errors.Is(truthErr, gorm.ErrRecordNotFound)) {
+		s.logger.Warn("钱包读比对查询失败",
+			zap.Int64("customer_id", customerID),
+			zap.NamedError("legacy_error", legacyErr),
+			zap.NamedError("truth_error", truthErr))
+		return
+	}
+	if !legacyFound && !truthFound {
+		return
+	}
+	if legacyFound && truthFound && legacy.ID == truth.ID &&
+		legacy.Balance == truth.Balance && legacy.Status == int32(truth.Status) {
+		return
+	}
+	s.logger.Warn("钱包读比对不一致",
+		zap.Int64("customer_id", customerID),
+		zap.Bool("legacy_found", legacyFound),
+		zap.Bool("truth_found", truthFound),
+		zap.Int64("legacy_wallet_id", legacy.ID),
+		zap.Int64("truth_wallet_id", truth.ID),
+		zap.Int64("legacy_balance", legacy.Balance),
+		zap.Int64("truth_balance", truth.Balance),
+		zap.Int32("legacy_status", legacy.Status),
+		zap.Int8("truth_status", truth.Status))
+}
+
+// compareTransaction 比对旧表流水在真相表中的副本，不一致时记录告警日志
+func (s *MigratingService) compareTransaction(ctx context.Context, legacy *billing.Transaction) {
+	var truth BilWalletTx
+	err := s.tx.GetDB(ctx).WithContext(ctx).
+		Where("idempotency_key = ?", legacy.IdempotencyKey).
+		Take(&truth).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		s.logger.Warn("流水读比对查询失败",
+			zap.String("idempotency_key", legacy.IdempotencyKey),
+			zap.Error(err))
+		return
+	}
+	if err == nil && truth.ID == legacy.ID && truth.WalletID == legacy.WalletID &&
+		truth.Direction == legacy.Direction && truth.Amount == legacy.Amount && truth.Type == legacy.Type {
+		return
+	}
+	s.logger.Warn("流水读比对不一致",
+		zap.String("idempotency_key", legacy.IdempotencyKey),
+		zap.Bool("truth_found", err == nil),
+		zap.Int64("legacy_tx_id", legacy.ID),
+		zap.Int64("truth_tx_id", truth.ID),
+		zap.String("legacy_direction", legacy.Direction),
+		zap.String("truth_direction", truth.Direction),
+		zap.Int64("legacy_amount", legacy.Amount),
+		zap.Int64("truth_amount", truth.Amount))
+}
+
+var (
+	_ billing.Service             = (*MigratingService)(nil)
+	_ billing.RechargeService     = (*MigratingService)(nil)
+	_ billing.TransferService     = (*MigratingService)(nil)
+	_ billing.CreditExpiryService = (*MigratingService)(nil)
+	_ billing.ReversalService     = (*MigratingService)(nil)
+)
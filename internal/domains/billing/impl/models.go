@@ -0,0 +1,109 @@
+package impl
+
+// WalletStatusLogRecord 映射 wallet_status_logs（钱包状态变更日志）
+type WalletStatusLogRecord struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	WalletID   int64  `gorm:"column:wallet_id;not null"`
+	CustomerID int64  `gorm:"column:customer_id;index:idx_wallet_status_customer_time,priority:1;not null"`
+	FromStatus int32  `gorm:"column:from_status;not null"`
+	ToStatus   int32  `gorm:"column:to_status;not null"`
+	OperatorID int64  `gorm:"column:operator_id;not null;default:0"`
+	Reason     string `gorm:"column:reason;size:255;not null"`
+	CreatedAt  int64  `gorm:"column:created_at;index:idx_wallet_status_customer_time,priority:2;not null"`
+}
+
+func (WalletStatusLogRecord) TableName() string { return "wallet_status_logs" }
+
+// RechargeBonusRuleRecord 映射 recharge_bonus_rules（充值赠送规则）
+type RechargeBonusRuleRecord struct {
+	ID          int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Name        string `gorm:"column:name;size:100;not null"`
+	MinAmount   int64  `gorm:"column:min_amount;not null"`   // 分
+	BonusAmount int64  `gorm:"column:bonus_amount;not null"` // 分
+	ValidDays   int    `gorm:"column:bonus_valid_days;not null;default:0"`
+	StartAt     int64  `gorm:"column:start_at;not null;default:0"`
+	EndAt       int64  `gorm:"column:end_at;not null;default:0"`
+	IsActive    bool   `gorm:"column:is_active;not null;default:true"`
+	CreatedAt   int64  `gorm:"column:created_at;not null"`
+	UpdatedAt   int64  `gorm:"column:updated_at;not null"`
+}
+
+func (RechargeBonusRuleRecord) TableName() string { return "recharge_bonus_rules" }
+
+// WalletRechargeRecord 映射 wallet_recharges（充值记录）
+type WalletRechargeRecord struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	WalletID       int64  `gorm:"column:wallet_id;not null"`
+	CustomerID     int64  `gorm:"column:customer_id;index:idx_wallet_recharge_customer,priority:1;not null"`
+	Amount         int64  `gorm:"column:amount;not null"`                 // 分
+	BonusAmount    int64  `gorm:"column:bonus_amount;not null;default:0"` // 分
+	RuleID         int64  `gorm:"column:rule_id;not null;default:0"`
+	PrincipalTxID  int64  `gorm:"column:principal_tx_id;not null"`
+	BonusTxID      int64  `gorm:"column:bonus_tx_id;not null;default:0"`
+	Status         string `gorm:"column:status;size:20;not null;default:'completed'"`
+	RefundTxID     int64  `gorm:"column:refund_tx_id;not null;default:0"`
+	ClawbackTxID   int64  `gorm:"column:clawback_tx_id;not null;default:0"`
+	OperatorID     int64  `gorm:"column:operator_id;not null;default:0"`
+	Note           string `gorm:"column:note;size:255"`
+	IdempotencyKey string `gorm:"column:idempotency_key;size:64;uniqueIndex:uk_wallet_recharge_idem;not null"`
+	CreatedAt      int64  `gorm:"column:created_at;index:idx_wallet_recharge_customer,priority:2;not null"`
+	RefundedAt     int64  `gorm:"column:refunded_at;not null;default:0"`
+}
+
+func (WalletRechargeRecord) TableName() string { return "wallet_recharges" }
+
+// WalletTransferRecord 映射 wallet_transfers（钱包转账记录）
+type WalletTransferRecord struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	FromCustomerID int64  `gorm:"column:from_customer_id;index:idx_wallet_transfer_from,priority:1;uniqueIndex:uk_wallet_transfer_idem,priority:1;not null"`
+	ToCustomerID   int64  `gorm:"column:to_customer_id;index:idx_wallet_transfer_to,priority:1;not null"`
+	FromWalletID   int64  `gorm:"column:from_wallet_id;not null"`
+	ToWalletID     int64  `gorm:"column:to_wallet_id;not null"`
+	Amount         int64  `gorm:"column:amount;not null"` // 分
+	OutTxID        int64  `gorm:"column:out_tx_id;not null;default:0"`
+	InTxID         int64  `gorm:"column:in_tx_id;not null;default:0"`
+	OperatorID     int64  `gorm:"column:operator_id;not null;default:0"`
+	Note           string `gorm:"column:note;size:255"`
+	IdempotencyKey string `gorm:"column:idempotency_key;size:64;uniqueIndex:uk_wallet_transfer_idem,priority:2;not null"`
+	CreatedAt      int64  `gorm:"column:created_at;index:idx_wallet_transfer_from,priority:2;index:idx_wallet_transfer_to,priority:2;not null"`
+}
+
+func (WalletTransferRecord) TableName() string { return "wallet_transfers" }
+
+// WalletCreditLotRecord 映射 wallet_credit_lots（有效期额度）
+type WalletCreditLotRecord struct {
+	ID            int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	WalletID      int64  `gorm:"column:wallet_id;index:idx_credit_lot_wallet,priority:1;not null"`
+	CustomerID    int64  `gorm:"column:customer_id;index:idx_credit_lot_customer,priority:1;not null"`
+	SourceTxID    int64  `gorm:"column:source_tx_id;not null;default:0"`
+	Amount        int64  `gorm:"column:amount;not null"`                                            // 分
+	Remaining     int64  `gorm:"column:remaining;index:idx_credit_lot_expires,priority:2;not null"` // 分
+	ExpiresAt     int64  `gorm:"column:expires_at;index:idx_credit_lot_wallet,priority:2;index:idx_credit_lot_customer,priority:2;index:idx_credit_lot_expires,priority:1;not null"`
+	ExpiredAmount int64  `gorm:"column:expired_amount;not null;default:0"`
+	ExpiredTxID   int64  `gorm:"column:expired_tx_id;not null;default:0"`
+	Note          string `gorm:"column:note;size:255"`
+	CreatedAt     int64  `gorm:"column:created_at;not null"`
+}
+
+func (WalletCreditLotRecord) TableName() string { return "wallet_credit_lots" }
+
+// WalletCreditLotUsageRecord 映射 wallet_credit_lot_usages（订单扣款的有效期额度扣减明细）
+type WalletCreditLotUsageRecord struct {
+	ID        int64 `gorm:"column:id;primaryKey;autoIncrement"`
+	LotID     int64 `gorm:"column:lot_id;not null"`
+	TxID      int64 `gorm:"column:tx_id;index:idx_credit_lot_usage_tx;not null"` // 扣款流水ID
+	Amount    int64 `gorm:"column:amount;not null"`                              // 分
+	Restored  int64 `gorm:"column:restored;not null;default:0"`                  // 退款已恢复金额（分）
+	CreatedAt int64 `gorm:"column:created_at;not null"`
+}
+
+func (WalletCreditLotUsageRecord) TableName() string { return "wallet_credit_lot_usages" }
+
+// WalletLedgerChainRecord 映射 wallet_ledger_chain（流水哈希链起点），由迁移写入唯一一行
+type WalletLedgerChainRecord struct {
+	ID        int64 `gorm:"column:id;primaryKey;autoIncrement"`
+	StartTxID int64 `gorm:"column:start_tx_id;not null"` // 链前流水的最大ID，之后的流水必须带哈希
+	CreatedAt int64 `gorm:"column:created_at;not null"`
+}
+
+func (WalletLedgerChainRecord) TableName() string { return "wallet_ledger_chain" }
@@ -1,27 +1,137 @@
 package impl
 
 import (
+	"context"
+
 	"crm_lite/internal/common"
-	"crm_lite/internal/dao/query"
+	"crm_lite/internal/core/config"
 	"crm_lite/internal/domains/billing"
 
 	"gorm.io/gorm"
 )
 
+// storeMode 读取钱包存储模式配置，未配置或配置无效时使用 legacy
+func storeMode() string {
+	mode := config.GetInstance().Wallet.StoreMode
+	if !billing.IsValidStoreMode(mode) {
+		return billing.StoreModeLegacy
+	}
+	return mode
+}
+
+// NewBillingServiceForMode 按钱包存储模式创建 billing 服务实例
+// legacy 使用旧表实现；dual_write / read_compare 使用迁移期实现；truth 切换到真相表实现
+func NewBillingServiceForMode(db *gorm.DB, tx common.Tx, mode string) billing.Service {
+	switch mode {
+	case billing.StoreModeDualWrite:
+		return NewMigratingService(db, tx, false)
+	case billing.StoreModeReadCompare:
+		return NewMigratingService(db, tx, true)
+	case billing.StoreModeTruth:
+		return NewTruthServiceWithTx(db, tx)
+	default:
+		return newBillingServiceImpl(db, tx)
+	}
+}
+
 // NewBillingServiceForController 为控制器创建 billing 服务实例
-// 支持 Legacy 兼容接口，便于现有控制器调用
-func NewBillingServiceForController(db *gorm.DB) *BillingServiceImpl {
-	q := query.Use(db)
+// 按 wallet.storeMode 配置选择实现
+func NewBillingServiceForController(db *gorm.DB) billing.Service {
+	return NewBillingServiceForMode(db, common.NewTx(db), storeMode())
+}
+
+// NewRechargeService 创建充值及充值赠送服务实例
+// 赠送规则与充值记录两种模式共用；truth 模式下充值、退回充值与汇总读写真相表流水
+func NewRechargeService(db *gorm.DB) billing.RechargeService {
+	tx := common.NewTx(db)
+	switch storeMode() {
+	case billing.StoreModeDualWrite:
+		return NewMigratingService(db, tx, false)
+	case billing.StoreModeReadCompare:
+		return NewMigratingService(db, tx, true)
+	case billing.StoreModeTruth:
+		return &truthRechargeService{RechargeService: newBillingServiceImpl(db, tx), truth: NewTruthServiceWithTx(db, tx)}
+	default:
+		return newBillingServiceImpl(db, tx)
+	}
+}
+
+// NewTransferService 创建钱包转账服务实例，按 wallet.storeMode 配置选择实现
+func NewTransferService(db *gorm.DB) billing.TransferService {
+	tx := common.NewTx(db)
+	switch storeMode() {
+	case billing.StoreModeDualWrite:
+		return NewMigratingService(db, tx, false)
+	case billing.StoreModeReadCompare:
+		return NewMigratingService(db, tx, true)
+	case billing.StoreModeTruth:
+		return NewTruthServiceWithTx(db, tx)
+	default:
+		return newBillingServiceImpl(db, tx)
+	}
+}
+
+// NewCreditExpiryService 创建有效期额度服务实例，按 wallet.storeMode 配置选择实现
+func NewCreditExpiryService(db *gorm.DB) billing.CreditExpiryService {
+	tx := common.NewTx(db)
+	switch storeMode() {
+	case billing.StoreModeDualWrite:
+		return NewMigratingService(db, tx, false)
+	case billing.StoreModeReadCompare:
+		return NewMigratingService(db, tx, true)
+	case billing.StoreModeTruth:
+		return NewTruthServiceWithTx(db, tx)
+	default:
+		return newBillingServiceImpl(db, tx)
+	}
+}
+
+// NewReversalService 创建流水冲正服务实例，按 wallet.storeMode 配置选择实现
+func NewReversalService(db *gorm.DB) billing.ReversalService {
 	tx := common.NewTx(db)
-	return &BillingServiceImpl{
-		db: db,
-		q:  q,
-		tx: tx,
+	switch storeMode() {
+	case billing.StoreModeDualWrite:
+		return NewMigratingService(db, tx, false)
+	case billing.StoreModeReadCompare:
+		return NewMigratingService(db, tx, true)
+	case billing.StoreModeTruth:
+		return NewTruthServiceWithTx(db, tx)
+	default:
+		return newBillingServiceImpl(db, tx)
+	}
+}
+
+// NewStatementService 创建钱包对账单服务实例
+// truth 模式读取真相表，其余模式读取旧表；签名密钥取 wallet.statementSigningKey 配置
+func NewStatementService(db *gorm.DB) billing.StatementService {
+	source := billing.ReconcileSourceLegacy
+	if storeMode() == billing.StoreModeTruth {
+		source = billing.ReconcileSourceTruth
 	}
+	return NewLedgerStatementService(db, source, config.GetInstance().Wallet.StatementSigningKey)
 }
 
 // NewBillingServiceWithTx 创建带事务管理的 billing 服务实例
-// 用于跨域事务协调
+// 用于跨域事务协调，按 wallet.storeMode 配置选择实现
 func NewBillingServiceWithTx(db *gorm.DB, tx common.Tx) billing.Service {
-	return NewBillingServiceImpl(db, tx)
+	return NewBillingServiceForMode(db, tx, storeMode())
+}
+
+// truthRechargeService truth 模式下的充值服务
+// 赠送规则维护与充值记录查询沿用旧实现，涉及流水的充值、退回充值与汇总由真相表实现处理
+type truthRechargeService struct {
+	billing.RechargeService
+	truth *TruthService
+}
+
+func (s *truthRechargeService) Recharge(ctx context.Context, req billing.RechargeRequest) (*billing.Recharge, error) {
+	return s.truth.Recharge(ctx, req)
+}
+
+func (s *truthRechargeService) RefundRecharge(ctx context.Context, customerID, rechargeID, operatorID int64, reason string) (*billing.Recharge, error) {
+	return s.truth.RefundRecharge(ctx, customerID, rechargeID, operatorID, reason)
+}
+
+func (s *truthRechargeService) GetRechargeSummary(ctx context.Context, customerID int64) (*billing.RechargeSummary, error) {
+	return s.truth.GetRechargeSummary(ctx, customerID)
 }
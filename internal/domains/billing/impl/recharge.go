@@ -0,0 +1,509 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rechargeBizRefType 充值相关流水的业务引用类型，biz_ref_id 为充值记录ID
+const rechargeBizRefType = "recharge"
+
+// CreateBonusRule 创建充值赠送规则
+func (s *BillingServiceImpl) CreateBonusRule(ctx context.Context, req billing.SaveBonusRuleRequest) (*billing.BonusRule, error) {
+	if err := validateBonusRule(&req); err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	record := &RechargeBonusRuleRecord{
+		Name:        req.Name,
+		MinAmount:   req.MinAmount,
+		BonusAmount: req.BonusAmount,
+		ValidDays:   req.ValidDays,
+		StartAt:     req.StartAt,
+		EndAt:       req.EndAt,
+		IsActive:    req.IsActive,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建充值赠送规则失败: %w", err)
+	}
+	return toBonusRule(record), nil
+}
+
+// UpdateBonusRule 更新充值赠送规则
+func (s *BillingServiceImpl) UpdateBonusRule(ctx context.Context, id int64, req billing.SaveBonusRuleRequest) (*billing.BonusRule, error) {
+	if err := validateBonusRule(&req); err != nil {
+		return nil, err
+	}
+	db := s.tx.GetDB(ctx).WithContext(ctx)
+	var record RechargeBonusRuleRecord
+	if err := db.First(&record, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeRechargeBonusRuleNotFound, "充值赠送规则不存在")
+		}
+		return nil, fmt.Errorf("查询充值赠送规则失败: %w", err)
+	}
+	record.Name = req.Name
+	record.MinAmount = req.MinAmount
+	record.BonusAmount = req.BonusAmount
+	record.ValidDays = req.ValidDays
+	record.StartAt = req.StartAt
+	record.EndAt = req.EndAt
+	record.IsActive = req.IsActive
+	record.UpdatedAt = time.Now().Unix()
+	if err := db.Save(&record).Error; err != nil {
+		return nil, fmt.Errorf("更新充值赠送规则失败: %w", err)
+	}
+	return toBonusRule(&record), nil
+}
+
+// DeleteBonusRule 删除充值赠送规则
+func (s *BillingServiceImpl) DeleteBonusRule(ctx context.Context, id int64) error {
+	result := s.tx.GetDB(ctx).WithContext(ctx).Delete(&RechargeBonusRuleRecord{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("删除充值赠送规则失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.NewBusinessError(common.ErrCodeRechargeBonusRuleNotFound, "充值赠送规则不存在")
+	}
+	return nil
+}
+
+// ListBonusRules 查询全部充值赠送规则，按门槛升序
+func (s *BillingServiceImpl) ListBonusRules(ctx context.Context) ([]billing.BonusRule, error) {
+	var records []RechargeBonusRuleRecord
+	if err := s.db.WithContext(ctx).Order("min_amount ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询充值赠送规则失败: %w", err)
+	}
+	rules := make([]billing.BonusRule, len(records))
+	for i := range records {
+		rules[i] = *toBonusRule(&records[i])
+	}
+	return rules, nil
+}
+
+// Recharge 充值
+// 本金与赠送在同一事务内分别入账，充值记录关联两笔流水，供退回与报表使用
+func (s *BillingServiceImpl) Recharge(ctx context.Context, req billing.RechargeRequest) (*billing.Recharge, error) {
+	if err := validateRecharge(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Recharge
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 幂等性检查
+		var existing WalletRechargeRecord
+		err := txDB.WithContext(ctx).Where("idempotency_key = ?", req.Idem).First(&existing).Error
+		if err == nil {
+			result = toRecharge(&existing)
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查幂等性失败: %w", err)
+		}
+
+		// 2. 获取或创建钱包（带行锁），冻结的钱包不允许充值
+		wallet, err := s.getOrCreateWalletWithLock(ctx, txQuery, req.CustomerID)
+		if err != nil {
+			return fmt.Errorf("获取钱包失败: %w", err)
+		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
+
+		// 3. 确定赠送金额后写入充值记录，再按记录ID写入本金与赠送流水
+		now := time.Now().Unix()
+		record, bonusExpiresAt, err := createRechargeRecord(ctx, txDB, &req, wallet.ID, now)
+		if err != nil {
+			return err
+		}
+		bonus := record.BonusAmount
+
+		principal, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       wallet.ID,
+			Direction:      "credit",
+			Amount:         req.Amount,
+			Type:           constants.WalletTxTypeRecharge,
+			BizRefType:     rechargeBizRefType,
+			BizRefID:       record.ID,
+			IdempotencyKey: req.Idem,
+			OperatorID:     req.OperatorID,
+			Note:           req.Note,
+		})
+		if err != nil {
+			return err
+		}
+		record.PrincipalTxID = principal.ID
+
+		if bonus > 0 {
+			bonusTx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+				WalletID:       wallet.ID,
+				Direction:      "credit",
+				Amount:         bonus,
+				Type:           constants.WalletTxTypeRechargeBonus,
+				BizRefType:     rechargeBizRefType,
+				BizRefID:       record.ID,
+				IdempotencyKey: fmt.Sprintf("recharge_bonus_%d", record.ID),
+				OperatorID:     req.OperatorID,
+				Note:           fmt.Sprintf("充值赠送: %d", record.ID),
+			})
+			if err != nil {
+				return err
+			}
+			record.BonusTxID = bonusTx.ID
+
+			if bonusExpiresAt > 0 {
+				lot, err := createCreditLot(ctx, txDB, wallet.ID, req.CustomerID, bonus, bonusExpiresAt, fmt.Sprintf("充值赠送: %d", record.ID))
+				if err != nil {
+					return err
+				}
+				if err := bindCreditLotSource(ctx, txDB, lot, bonusTx.ID); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := txDB.WithContext(ctx).Model(record).
+			Updates(map[string]interface{}{"principal_tx_id": record.PrincipalTxID, "bonus_tx_id": record.BonusTxID}).Error; err != nil {
+			return fmt.Errorf("更新充值记录失败: %w", err)
+		}
+		result = toRecharge(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RefundRecharge 退回充值
+// 本金与赠送必须一并扣回，避免客户退掉本金后继续使用赠送金额
+func (s *BillingServiceImpl) RefundRecharge(ctx context.Context, customerID, rechargeID, operatorID int64, reason string) (*billing.Recharge, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "退回原因不能为空")
+	}
+
+	var result *billing.Recharge
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 锁定充值记录
+		var record WalletRechargeRecord
+		if err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND customer_id = ?", rechargeID, customerID).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodeRechargeNotFound, "充值记录不存在")
+			}
+			return fmt.Errorf("查询充值记录失败: %w", err)
+		}
+		if record.Status != string(constants.RechargeStatusCompleted) {
+			return common.NewBusinessError(common.ErrCodeRechargeStatusInvalid, "充值已退回")
+		}
+
+		// 2. 锁定钱包并校验余额，已过期扣回的赠送不再重复收回
+		wallet, err := s.getWalletWithLock(ctx, txQuery, customerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
+		clawback, err := bonusClawbackAmount(ctx, txDB, &record)
+		if err != nil {
+			return err
+		}
+		if wallet.Balance < record.Amount+clawback {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "钱包余额不足以扣回充值本金及赠送")
+		}
+
+		// 3. 扣回本金与赠送
+		refundTx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       wallet.ID,
+			Direction:      "debit",
+			Amount:         record.Amount,
+			Type:           constants.WalletTxTypeRechargeRefund,
+			BizRefType:     rechargeBizRefType,
+			BizRefID:       record.ID,
+			IdempotencyKey: fmt.Sprintf("recharge_refund_%d", record.ID),
+			OperatorID:     operatorID,
+			Note:           reason,
+		})
+		if err != nil {
+			return err
+		}
+		record.RefundTxID = refundTx.ID
+
+		if clawback > 0 {
+			clawbackTx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+				WalletID:       wallet.ID,
+				Direction:      "debit",
+				Amount:         clawback,
+				Type:           constants.WalletTxTypeBonusClawback,
+				BizRefType:     rechargeBizRefType,
+				BizRefID:       record.ID,
+				IdempotencyKey: fmt.Sprintf("bonus_clawback_%d", record.ID),
+				OperatorID:     operatorID,
+				Note:           reason,
+			})
+			if err != nil {
+				return err
+			}
+			record.ClawbackTxID = clawbackTx.ID
+		}
+		if err := consumeCreditLots(ctx, txDB, wallet.ID, record.Amount+clawback, record.BonusTxID, 0); err != nil {
+			return err
+		}
+
+		// 4. 更新充值记录状态
+		record.Status = string(constants.RechargeStatusRefunded)
+		record.RefundedAt = time.Now().Unix()
+		if err := txDB.WithContext(ctx).Model(&record).Updates(map[string]interface{}{
+			"status":         record.Status,
+			"refund_tx_id":   record.RefundTxID,
+			"clawback_tx_id": record.ClawbackTxID,
+			"refunded_at":    record.RefundedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("更新充值记录失败: %w", err)
+		}
+		result = toRecharge(&record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListRecharges 查询客户充值记录，按时间倒序
+func (s *BillingServiceImpl) ListRecharges(ctx context.Context, customerID int64) ([]billing.Recharge, error) {
+	var records []WalletRechargeRecord
+	if err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).
+		Order("created_at DESC, id DESC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询充值记录失败: %w", err)
+	}
+	recharges := make([]billing.Recharge, len(records))
+	for i := range records {
+		recharges[i] = *toRecharge(&records[i])
+	}
+	return recharges, nil
+}
+
+// GetRechargeSummary 按流水类型汇总客户实付资金与赠送资金
+func (s *BillingServiceImpl) GetRechargeSummary(ctx context.Context, customerID int64) (*billing.RechargeSummary, error) {
+	return rechargeSummary(ctx, s.db, &model.Wallet{}, &model.WalletTransaction{}, customerID)
+}
+
+// rechargeSummary 按流水类型汇总客户实付资金与赠送资金，walletTable、txTable 为钱包与流水所在表的模型
+func rechargeSummary(ctx context.Context, db *gorm.DB, walletTable, txTable interface{}, customerID int64) (*billing.RechargeSummary, error) {
+	summary := &billing.RechargeSummary{}
+	var walletIDs []int64
+	if err := db.WithContext(ctx).Model(walletTable).Where("customer_id = ?", customerID).Limit(1).
+		Pluck("id", &walletIDs).Error; err != nil {
+		return nil, fmt.Errorf("查询钱包失败: %w", err)
+	}
+	if len(walletIDs) == 0 {
+		return summary, nil
+	}
+
+	var rows []struct {
+		Type  string
+		Total int64
+	}
+	if err := db.WithContext(ctx).Model(txTable).
+		Select("type, COALESCE(SUM(amount), 0) AS total").
+		Where("wallet_id = ? AND type IN ?", walletIDs[0], []string{
+			constants.WalletTxTypeRecharge, constants.WalletTxTypeRechargeRefund,
+			constants.WalletTxTypeRechargeBonus, constants.WalletTxTypeBonusClawback,
+		}).
+		Group("type").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总充值流水失败: %w", err)
+	}
+	for _, r := range rows {
+		switch r.Type {
+		case constants.WalletTxTypeRecharge:
+			summary.PaidAmount = r.Total
+		case constants.WalletTxTypeRechargeRefund:
+			summary.RefundedAmount = r.Total
+		case constants.WalletTxTypeRechargeBonus:
+			summary.BonusAmount = r.Total
+		case constants.WalletTxTypeBonusClawback:
+			summary.ClawbackAmount = r.Total
+		}
+	}
+	summary.NetPaidAmount = summary.PaidAmount - summary.RefundedAmount
+	summary.NetBonusAmount = summary.BonusAmount - summary.ClawbackAmount
+	return summary, nil
+}
+
+// createRechargeRecord 确定赠送金额并写入充值记录，返回记录及赠送额度的过期时间（0 表示不过期）
+// 手工指定赠送金额优先，否则按规则匹配；未指定过期时间时按规则的有效天数计算。旧表与真相表实现共用
+func createRechargeRecord(ctx context.Context, db *gorm.DB, req *billing.RechargeRequest, walletID, now int64) (*WalletRechargeRecord, int64, error) {
+	bonus, ruleID, bonusExpiresAt := req.BonusAmount, int64(0), req.BonusExpiresAt
+	if bonus == 0 {
+		rule, err := matchBonusRule(ctx, db, req.Amount, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if rule != nil {
+			bonus, ruleID = rule.BonusAmount, rule.ID
+			if bonusExpiresAt == 0 && rule.ValidDays > 0 {
+				bonusExpiresAt = now + int64(rule.ValidDays)*86400
+			}
+		}
+	}
+
+	record := &WalletRechargeRecord{
+		WalletID:       walletID,
+		CustomerID:     req.CustomerID,
+		Amount:         req.Amount,
+		BonusAmount:    bonus,
+		RuleID:         ruleID,
+		Status:         string(constants.RechargeStatusCompleted),
+		OperatorID:     req.OperatorID,
+		Note:           req.Note,
+		IdempotencyKey: req.Idem,
+		CreatedAt:      now,
+	}
+	if err := db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, 0, fmt.Errorf("创建充值记录失败: %w", err)
+	}
+	return record, bonusExpiresAt, nil
+}
+
+// matchBonusRule 匹配当前生效且门槛最高的赠送规则，无匹配时返回 nil
+func matchBonusRule(ctx context.Context, db *gorm.DB, amount, now int64) (*RechargeBonusRuleRecord, error) {
+	var rule RechargeBonusRuleRecord
+	err := db.WithContext(ctx).
+		Where("is_active = ? AND min_amount <= ? AND start_at <= ? AND (end_at = 0 OR end_at > ?)", true, amount, now, now).
+		Order("min_amount DESC, bonus_amount DESC").
+		First(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查询充值赠送规则失败: %w", err)
+	}
+	return &rule, nil
+}
+
+// appendWalletTx 写入流水并同步更新钱包余额，调用方需已持有钱包行锁并设置 BizRefType
+func (s *BillingServiceImpl) appendWalletTx(ctx context.Context, txQuery *query.Query, tx *model.WalletTransaction) (*model.WalletTransaction, error) {
+	tx.CreatedAt = time.Now().Unix()
+	if err := sealWalletTx(ctx, txQuery, tx); err != nil {
+		return nil, err
+	}
+	if err := txQuery.WalletTransaction.WithContext(ctx).Create(tx); err != nil {
+		return nil, fmt.Errorf("创建交易记录失败: %w", err)
+	}
+
+	delta := tx.Amount
+	if tx.Direction == "debit" {
+		delta = -tx.Amount
+	}
+	if _, err := txQuery.Wallet.WithContext(ctx).
+		Where(txQuery.Wallet.ID.Eq(tx.WalletID)).
+		UpdateSimple(txQuery.Wallet.Balance.Add(delta), txQuery.Wallet.UpdatedAt.Value(tx.CreatedAt)); err != nil {
+		return nil, fmt.Errorf("更新钱包余额失败: %w", err)
+	}
+	return tx, nil
+}
+
+// bonusClawbackAmount 计算退充值时需收回的赠送金额：赠送金额扣除已过期扣回的部分
+func bonusClawbackAmount(ctx context.Context, db *gorm.DB, record *WalletRechargeRecord) (int64, error) {
+	if record.BonusTxID == 0 {
+		return 0, nil
+	}
+	var lot WalletCreditLotRecord
+	err := db.WithContext(ctx).Where("source_tx_id = ?", record.BonusTxID).First(&lot).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return record.BonusAmount, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("查询赠送额度失败: %w", err)
+	}
+	return record.BonusAmount - lot.ExpiredAmount, nil
+}
+
+func validateRecharge(req *billing.RechargeRequest) error {
+	if req.Amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "充值金额必须大于0")
+	}
+	if req.BonusAmount < 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "赠送金额不能为负数")
+	}
+	if req.BonusExpiresAt != 0 && req.BonusExpiresAt <= time.Now().Unix() {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "赠送过期时间必须晚于当前时间")
+	}
+	if req.Idem == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "幂等键不能为空")
+	}
+	return nil
+}
+
+func validateBonusRule(req *billing.SaveBonusRuleRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "规则名称不能为空")
+	}
+	if req.MinAmount <= 0 || req.BonusAmount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "充值门槛和赠送金额必须大于0")
+	}
+	if req.ValidDays < 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "赠送有效天数不能为负数")
+	}
+	if req.StartAt < 0 || req.EndAt < 0 || (req.EndAt > 0 && req.EndAt <= req.StartAt) {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "生效结束时间必须晚于开始时间")
+	}
+	return nil
+}
+
+func toBonusRule(r *RechargeBonusRuleRecord) *billing.BonusRule {
+	return &billing.BonusRule{
+		ID:          r.ID,
+		Name:        r.Name,
+		MinAmount:   r.MinAmount,
+		BonusAmount: r.BonusAmount,
+		ValidDays:   r.ValidDays,
+		StartAt:     r.StartAt,
+		EndAt:       r.EndAt,
+		IsActive:    r.IsActive,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+func toRecharge(r *WalletRechargeRecord) *billing.Recharge {
+	return &billing.Recharge{
+		ID:            r.ID,
+		WalletID:      r.WalletID,
+		CustomerID:    r.CustomerID,
+		Amount:        r.Amount,
+		BonusAmount:   r.BonusAmount,
+		RuleID:        r.RuleID,
+		PrincipalTxID: r.PrincipalTxID,
+		BonusTxID:     r.BonusTxID,
+		Status:        r.Status,
+		OperatorID:    r.OperatorID,
+		Note:          r.Note,
+		CreatedAt:     r.CreatedAt,
+		RefundedAt:    r.RefundedAt,
+	}
+}
+
+var _ billing.RechargeService = (*BillingServiceImpl)(nil)
@@ -0,0 +1,99 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRechargeBonus 充值赠送：规则匹配、赠送单独入账、退回充值收回赠送
+func TestRechargeBonus(t *testing.T) {
+	db := setupWalletDB(t)
+	svc := newBillingServiceImpl(db, common.NewTx(db))
+	ctx := context.Background()
+	customerID := int64(3001)
+	now := time.Now().Unix()
+
+	_, err := svc.CreateBonusRule(ctx, billing.SaveBonusRuleRequest{Name: "充100送20", MinAmount: 10000, BonusAmount: 2000, IsActive: true})
+	require.NoError(t, err)
+	_, err = svc.CreateBonusRule(ctx, billing.SaveBonusRuleRequest{Name: "充500送150", MinAmount: 50000, BonusAmount: 15000, IsActive: true})
+	require.NoError(t, err)
+	_, err = svc.CreateBonusRule(ctx, billing.SaveBonusRuleRequest{Name: "已过期", MinAmount: 10000, BonusAmount: 90000, StartAt: now - 7200, EndAt: now - 3600, IsActive: true})
+	require.NoError(t, err)
+
+	t.Run("按门槛最高的生效规则赠送", func(t *testing.T) {
+		r, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 60000, Idem: "bonus_recharge_1"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(15000), r.BonusAmount)
+		assert.NotZero(t, r.BonusTxID)
+
+		r2, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 5000, Idem: "bonus_recharge_2"})
+		require.NoError(t, err)
+		assert.Zero(t, r2.BonusAmount, "未达门槛不赠送")
+		assert.Zero(t, r2.BonusTxID)
+
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(80000), balance)
+	})
+
+	t.Run("相同幂等键不重复充值", func(t *testing.T) {
+		_, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 60000, Idem: "bonus_recharge_1"})
+		require.NoError(t, err)
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(80000), balance)
+	})
+
+	t.Run("退回充值收回赠送", func(t *testing.T) {
+		recharges, err := svc.ListRecharges(ctx, customerID)
+		require.NoError(t, err)
+		require.Len(t, recharges, 2)
+		first := recharges[1]
+
+		r, err := svc.RefundRecharge(ctx, customerID, first.ID, 9, "客户申请退款")
+		require.NoError(t, err)
+		assert.Equal(t, "refunded", r.Status)
+
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), balance)
+
+		_, err = svc.RefundRecharge(ctx, customerID, first.ID, 9, "重复退回")
+		assertBusinessCode(t, err, common.ErrCodeRechargeStatusInvalid)
+	})
+
+	t.Run("赠送已消费时余额不足拒绝退回", func(t *testing.T) {
+		r, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 10000, Idem: "bonus_recharge_3"})
+		require.NoError(t, err)
+		require.Equal(t, int64(2000), r.BonusAmount)
+		require.NoError(t, svc.DebitForOrder(ctx, customerID, 1, 10000, "bonus_debit_1"))
+
+		_, err = svc.RefundRecharge(ctx, customerID, r.ID, 9, "客户申请退款")
+		assertBusinessCode(t, err, common.ErrCodeInsufficientBalance)
+	})
+
+	t.Run("手工指定赠送金额", func(t *testing.T) {
+		r, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 10000, BonusAmount: 500, Idem: "bonus_recharge_4"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), r.BonusAmount)
+		assert.Zero(t, r.RuleID)
+	})
+
+	t.Run("汇总区分实付与赠送", func(t *testing.T) {
+		summary, err := svc.GetRechargeSummary(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(85000), summary.PaidAmount)
+		assert.Equal(t, int64(60000), summary.RefundedAmount)
+		assert.Equal(t, int64(17500), summary.BonusAmount)
+		assert.Equal(t, int64(15000), summary.ClawbackAmount)
+		assert.Equal(t, int64(25000), summary.NetPaidAmount)
+		assert.Equal(t, int64(2500), summary.NetBonusAmount)
+	})
+}
@@ -0,0 +1,203 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reconcileTables 对账数据源对应的钱包表与流水表
+type reconcileTables struct {
+	wallets      string
+	transactions string
+}
+
+var reconcileSources = map[string]reconcileTables{
+	billing.ReconcileSourceLegacy: {wallets: "wallets", transactions: "wallet_transactions"},
+	billing.ReconcileSourceTruth:  {wallets: "bil_wallets", transactions: "bil_wallet_transactions"},
+}
+
+// BalanceReconciler 钱包余额对账实现
+// 先批量比对余额与流水汇总，再对疑似偏差的钱包加行锁复核，避免把进行中的交易误报为偏差
+type BalanceReconciler struct {
+	db *gorm.DB
+}
+
+// NewBalanceReconciler 创建钱包余额对账实例
+func NewBalanceReconciler(db *gorm.DB) *BalanceReconciler {
+	return &BalanceReconciler{db: db}
+}
+
+// reconcileWallet 钱包余额快照
+type reconcileWallet struct {
+	ID         int64
+	CustomerID int64
+	Balance    int64
+}
+
+// reconcileAgg 按钱包汇总的流水
+type reconcileAgg struct {
+	WalletID int64
+	Balance  int64
+	LastTxID int64
+}
+
+// Reconcile 对账全部钱包
+func (r *BalanceReconciler) Reconcile(ctx context.Context, opts billing.ReconcileOptions) (*billing.ReconcileReport, error) {
+	sources := opts.Sources
+	if len(sources) == 0 {
+		sources = []string{billing.ReconcileSourceLegacy, billing.ReconcileSourceTruth}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	report := &billing.ReconcileReport{
+		StartedAt: time.Now().Unix(),
+		Sources:   []string{},
+		Drifts:    []billing.BalanceDrift{},
+	}
+	for _, source := range sources {
+		tables, ok := reconcileSources[source]
+		if !ok {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("未知的对账数据源: %s", source))
+		}
+		// 尚未迁移真相表的环境跳过对应数据源
+		migrator := r.db.WithContext(ctx).Migrator()
+		if !migrator.HasTable(tables.wallets) || !migrator.HasTable(tables.transactions) {
+			continue
+		}
+		report.Sources = append(report.Sources, source)
+		if err := r.reconcileSource(ctx, source, tables, opts, report); err != nil {
+			return nil, err
+		}
+	}
+	report.FinishedAt = time.Now().Unix()
+	return report, nil
+}
+
+// reconcileSource 按钱包ID分批对账单个数据源
+func (r *BalanceReconciler) reconcileSource(ctx context.Context, source string, tables reconcileTables, opts billing.ReconcileOptions, report *billing.ReconcileReport) error {
+	var lastID int64
+	for {
+		var wallets []reconcileWallet
+		if err := r.db.WithContext(ctx).Table(tables.wallets).
+			Select("id, customer_id, balance").
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(opts.BatchSize).
+			Scan(&wallets).Error; err != nil {
+			return fmt.Errorf("查询钱包失败(%s): %w", source, err)
+		}
+		if len(wallets) == 0 {
+			return nil
+		}
+
+		ids := make([]int64, len(wallets))
+		for i, w := range wallets {
+			ids[i] = w.ID
+		}
+		aggs, err := sumWalletTransactions(ctx, r.db, tables.transactions, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, w := range wallets {
+			report.WalletsChecked++
+			if aggs[w.ID].Balance == w.Balance {
+				continue
+			}
+			drift, err := r.verifyDrift(ctx, source, tables, w.ID, opts.Repair)
+			if err != nil {
+				return err
+			}
+			if drift == nil {
+				continue
+			}
+			if drift.Repaired {
+				report.Repaired++
+			}
+			report.Drifts = append(report.Drifts, *drift)
+		}
+
+		if len(wallets) < opts.BatchSize {
+			return nil
+		}
+		lastID = wallets[len(wallets)-1].ID
+	}
+}
+
+// verifyDrift 加行锁复核单个钱包的偏差，复核后一致时返回 nil；repair 为 true 时同时修复
+func (r *BalanceReconciler) verifyDrift(ctx context.Context, source string, tables reconcileTables, walletID int64, repair bool) (*billing.BalanceDrift, error) {
+	var drift *billing.BalanceDrift
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var wallet reconcileWallet
+		if err := tx.Table(tables.wallets).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id, customer_id, balance").Where("id = ?", walletID).
+			Scan(&wallet).Error; err != nil {
+			return fmt.Errorf("锁定钱包失败(%s): %w", source, err)
+		}
+		aggs, err := sumWalletTransactions(ctx, tx, tables.transactions, []int64{walletID})
+		if err != nil {
+			return err
+		}
+		agg := aggs[walletID]
+		if agg.Balance == wallet.Balance {
+			return nil
+		}
+
+		drift = &billing.BalanceDrift{
+			Source:          source,
+			WalletID:        wallet.ID,
+			CustomerID:      wallet.CustomerID,
+			StoredBalance:   wallet.Balance,
+			ComputedBalance: agg.Balance,
+			LastTxID:        agg.LastTxID,
+		}
+		if agg.LastTxID > 0 {
+			if err := tx.Table(tables.transactions).Select("created_at").
+				Where("id = ?", agg.LastTxID).Scan(&drift.LastTxAt).Error; err != nil {
+				return fmt.Errorf("查询最后一笔流水失败(%s): %w", source, err)
+			}
+		}
+		if !repair {
+			return nil
+		}
+
+		if err := tx.Table(tables.wallets).Where("id = ?", walletID).
+			Updates(map[string]interface{}{"balance": agg.Balance, "updated_at": time.Now().Unix()}).Error; err != nil {
+			return fmt.Errorf("修复钱包余额失败(%s): %w", source, err)
+		}
+		drift.Repaired = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return drift, nil
+}
+
+// sumWalletTransactions 按钱包汇总流水：入账为正、出账为负，并返回最后一笔流水ID
+func sumWalletTransactions(ctx context.Context, db *gorm.DB, table string, walletIDs []int64) (map[int64]reconcileAgg, error) {
+	var rows []reconcileAgg
+	if err := db.WithContext(ctx).Table(table).
+		Select("wallet_id, COALESCE(SUM(CASE WHEN direction = 'credit' THEN amount ELSE -amount END), 0) AS balance, MAX(id) AS last_tx_id").
+		Where("wallet_id IN ?", walletIDs).
+		Group("wallet_id").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总钱包流水失败(%s): %w", table, err)
+	}
+	aggs := make(map[int64]reconcileAgg, len(rows))
+	for _, row := range rows {
+		aggs[row.WalletID] = row
+	}
+	return aggs, nil
+}
+
+var _ billing.Reconciler = (*BalanceReconciler)(nil)
@@ -0,0 +1,68 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBalanceReconcile 余额对账：发现偏差、按数据源过滤、修复后复查一致
+func TestBalanceReconcile(t *testing.T) {
+	db := setupWalletDB(t)
+	createTruthTables(t, db)
+
+	ctx := context.Background()
+	svc := NewBillingServiceForController(db)
+	require.NoError(t, svc.Credit(ctx, 4001, 10000, "充值", "reconcile_credit_1"))
+	require.NoError(t, svc.DebitForOrder(ctx, 4001, 1, 3000, "reconcile_debit_1"))
+	require.NoError(t, svc.Credit(ctx, 4002, 5000, "充值", "reconcile_credit_2"))
+
+	// 人为制造偏差：legacy 钱包余额被直接改写；真相表钱包缺少一笔流水对应的余额
+	require.NoError(t, db.Exec(`UPDATE wallets SET balance = 9999 WHERE customer_id = 4002`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO bil_wallets (customer_id, balance, updated_at) VALUES (4001, 100, 0)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO bil_wallet_transactions (wallet_id, direction, amount, type, idempotency_key, created_at)
+		VALUES (1, 'credit', 300, 'recharge', 'bil_1', 1700000000), (1, 'debit', 100, 'order_pay', 'bil_2', 1700000100)`).Error)
+
+	reconciler := NewBalanceReconciler(db)
+
+	t.Run("报告两类数据源的偏差", func(t *testing.T) {
+		report, err := reconciler.Reconcile(ctx, billing.ReconcileOptions{BatchSize: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []string{billing.ReconcileSourceLegacy, billing.ReconcileSourceTruth}, report.Sources)
+		assert.Equal(t, 3, report.WalletsChecked)
+		require.Len(t, report.Drifts, 2)
+
+		legacy := report.Drifts[0]
+		assert.Equal(t, billing.ReconcileSourceLegacy, legacy.Source)
+		assert.Equal(t, int64(9999), legacy.StoredBalance)
+		assert.Equal(t, int64(5000), legacy.ComputedBalance)
+		assert.NotZero(t, legacy.LastTxID)
+		assert.False(t, legacy.Repaired)
+
+		truth := report.Drifts[1]
+		assert.Equal(t, billing.ReconcileSourceTruth, truth.Source)
+		assert.Equal(t, int64(100), truth.StoredBalance)
+		assert.Equal(t, int64(200), truth.ComputedBalance)
+		assert.Equal(t, int64(1700000100), truth.LastTxAt)
+	})
+
+	t.Run("按数据源修复后复查一致", func(t *testing.T) {
+		report, err := reconciler.Reconcile(ctx, billing.ReconcileOptions{Sources: []string{billing.ReconcileSourceLegacy}, Repair: true})
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 1)
+		assert.Equal(t, 1, report.Repaired)
+
+		balance, err := svc.GetBalance(ctx, 4002)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), balance)
+
+		report, err = reconciler.Reconcile(ctx, billing.ReconcileOptions{})
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 1, "真相表偏差未修复")
+		assert.Equal(t, billing.ReconcileSourceTruth, report.Drifts[0].Source)
+	})
+}
@@ -0,0 +1,217 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// reversalBizRefType 冲正流水的业务引用类型，biz_ref_id 为被冲正的原流水ID
+	reversalBizRefType = "reversal"
+	// manualBizRefType 手工交易流水的业务引用类型，手工扣款与手工退款的 biz_ref_id 为关联订单ID
+	manualBizRefType = "manual"
+	// orderBizRefType 下单支付与订单退款流水的业务引用类型，biz_ref_id 为订单ID
+	orderBizRefType = "order"
+)
+
+// reversibleTxTypes 允许冲正的流水类型：手工交易产生的充值、扣款、退款与余额调整，且须为 manual 流水
+// 订单流程的扣款与退款关联支付明细和退款单，充值赠送、退回充值、转账、冲正流水由各自流程维护关联记录，均不能直接冲正
+var reversibleTxTypes = map[string]bool{
+	constants.WalletTxTypeRecharge: true,
+	"order_pay":                    true,
+	"order_refund":                 true,
+	"adjust_in":                    true,
+	"adjust_out":                   true,
+}
+
+// ReverseTransaction 冲正流水
+// 在钱包行锁内写入方向相反、金额相同的 reversal 流水，幂等键固定为 reversal_<原流水ID>，同一流水只能冲正一次
+func (s *BillingServiceImpl) ReverseTransaction(ctx context.Context, req billing.ReverseTransactionRequest) (*billing.Transaction, error) {
+	if err := validateReversal(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Transaction
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 先锁定钱包再查询原流水，同一钱包的冲正串行执行
+		wallet, err := s.getWalletWithLock(ctx, txQuery, req.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureWalletActive(wallet); err != nil {
+			return err
+		}
+		original, err := txQuery.WalletTransaction.WithContext(ctx).
+			Where(txQuery.WalletTransaction.ID.Eq(req.TransactionID), txQuery.WalletTransaction.WalletID.Eq(wallet.ID)).
+			First()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.NewBusinessError(common.ErrCodeTransactionNotFound, "流水不存在")
+		} else if err != nil {
+			return fmt.Errorf("查询流水失败: %w", err)
+		}
+
+		// 2. 校验可冲正，冲正入账流水时余额须足够
+		if err := checkReversible(ctx, txDB, &model.WalletTransaction{}, toBillingTransaction(original)); err != nil {
+			return err
+		}
+		direction := reverseDirection(original.Direction)
+		if direction == "debit" && wallet.Balance < original.Amount {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "钱包余额不足，无法冲正")
+		}
+
+		// 3. 写入冲正流水；冲正入账时与其他扣款一样扣减有效期额度，冲正扣款时恢复原扣款扣减的有效期额度
+		reversal, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       wallet.ID,
+			Direction:      direction,
+			Amount:         original.Amount,
+			Type:           constants.WalletTxTypeReversal,
+			BizRefType:     reversalBizRefType,
+			BizRefID:       original.ID,
+			IdempotencyKey: reversalIdemKey(original.ID),
+			OperatorID:     req.OperatorID,
+			ReasonCode:     req.ReasonCode,
+			Note:           reversalNote(req.Note, original.ID),
+		})
+		if err != nil {
+			return err
+		}
+		if err := reverseCreditLots(ctx, txDB, wallet.ID, original.ID, original.Amount, direction, reversal.ID); err != nil {
+			return err
+		}
+		result = toBillingTransaction(reversal)
+		result.ReversalOfTxID = original.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ===== 冲正公共逻辑，旧表与真相表实现共用 =====
+
+// checkReversible 校验流水可冲正：类型允许且尚未被冲正，table 为流水所在表的模型
+func checkReversible(ctx context.Context, db *gorm.DB, table interface{}, original *billing.Transaction) error {
+	if !reversibleTxTypes[original.Type] {
+		return common.NewBusinessError(common.ErrCodeTransactionIrreversible, "该类型流水不能冲正")
+	}
+	switch original.BizRefType {
+	case manualBizRefType:
+		// 手工交易可以冲正
+	case rechargeBizRefType:
+		return common.NewBusinessError(common.ErrCodeTransactionIrreversible, "充值记录的流水请通过退回充值处理")
+	case creditLotBizRefType:
+		return common.NewBusinessError(common.ErrCodeTransactionIrreversible, "有效期额度的流水不能冲正")
+	case orderBizRefType:
+		return common.NewBusinessError(common.ErrCodeTransactionIrreversible, "订单支付或退款的流水请通过订单退款处理")
+	default:
+		return common.NewBusinessError(common.ErrCodeTransactionIrreversible, "仅手工交易的流水可以冲正")
+	}
+
+	var count int64
+	if err := db.WithContext(ctx).Model(table).
+		Where("idempotency_key = ?", reversalIdemKey(original.ID)).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询冲正流水失败: %w", err)
+	}
+	if count > 0 {
+		return common.NewBusinessError(common.ErrCodeTransactionReversed, "该流水已冲正")
+	}
+	return nil
+}
+
+// reverseCreditLots 同步冲正流水对有效期额度的影响，调用方需已持有钱包行锁
+// 冲正入账（direction=debit）按最早到期扣减额度；冲正扣款（direction=credit）恢复原扣款扣减的额度
+func reverseCreditLots(ctx context.Context, db *gorm.DB, walletID, originalTxID, amount int64, direction string, reversalTxID int64) error {
+	if direction == "debit" {
+		return consumeCreditLots(ctx, db, walletID, amount, 0, 0)
+	}
+	return restoreCreditLots(ctx, db, []int64{originalTxID}, amount, reversalTxID)
+}
+
+// fillReversalStatus 填充流水的冲正状态：冲正流水记录原流水ID，已被冲正的流水记录冲正流水ID
+func fillReversalStatus(ctx context.Context, db *gorm.DB, table interface{}, txs []billing.Transaction) error {
+	if len(txs) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(txs))
+	for i := range txs {
+		ids[i] = txs[i].ID
+		if txs[i].Type == constants.WalletTxTypeReversal {
+			txs[i].ReversalOfTxID = txs[i].BizRefID
+		}
+	}
+
+	var rows []struct {
+		ID       int64
+		BizRefID int64
+	}
+	if err := db.WithContext(ctx).Model(table).
+		Select("id, biz_ref_id").
+		Where("type = ? AND biz_ref_id IN ?", constants.WalletTxTypeReversal, ids).
+		Scan(&rows).Error; err != nil {
+		return fmt.Errorf("查询冲正流水失败: %w", err)
+	}
+	reversedBy := make(map[int64]int64, len(rows))
+	for _, r := range rows {
+		reversedBy[r.BizRefID] = r.ID
+	}
+	for i := range txs {
+		txs[i].ReversedByTxID = reversedBy[txs[i].ID]
+	}
+	return nil
+}
+
+func reversalIdemKey(originalTxID int64) string {
+	return fmt.Sprintf("reversal_%d", originalTxID)
+}
+
+func reverseDirection(direction string) string {
+	if direction == "debit" {
+		return "credit"
+	}
+	return "debit"
+}
+
+func reversalNote(note string, originalTxID int64) string {
+	if note != "" {
+		return note
+	}
+	return fmt.Sprintf("冲正流水: %d", originalTxID)
+}
+
+func validateReversal(req *billing.ReverseTransactionRequest) error {
+	req.Note = strings.TrimSpace(req.Note)
+	if req.TransactionID <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "流水ID无效")
+	}
+	valid := false
+	for _, reason := range constants.ValidWalletReversalReasons() {
+		if req.ReasonCode == reason {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "冲正原因代码无效")
+	}
+	if req.ReasonCode == string(constants.WalletReversalReasonOther) && req.Note == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "冲正原因为其他时必须填写备注")
+	}
+	return nil
+}
+
+var _ billing.ReversalService = (*BillingServiceImpl)(nil)
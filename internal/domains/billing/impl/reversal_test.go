@@ -0,0 +1,162 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTransactionReversal 流水冲正：反向流水关联原流水、禁止重复冲正、交易历史展示冲正状态
+func TestTransactionReversal(t *testing.T) {
+	db := setupWalletDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	svc := newBillingServiceImpl(db, tx)
+	customerID := int64(7001)
+	orderID := int64(88)
+
+	require.NoError(t, svc.Credit(ctx, customerID, 10000, "充值", "reversal_seed_1"))
+	consume, err := svc.CreateTransaction(ctx, &billing.CreateTransactionRequest{CustomerID: customerID, Amount: 30, Type: "consume", OrderID: &orderID, OperatorID: 1})
+	require.NoError(t, err)
+	original, err := svc.GetTransactionByIdemKey(ctx, consume.IdempotencyKey)
+	require.NoError(t, err)
+	var drainIdem string
+
+	t.Run("写入反向流水并关联原流水", func(t *testing.T) {
+		reversal, err := svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: original.ID, ReasonCode: "wrong_amount", OperatorID: 2})
+		require.NoError(t, err)
+		assert.Equal(t, constants.WalletTxTypeReversal, reversal.Type)
+		assert.Equal(t, "credit", reversal.Direction)
+		assert.Equal(t, int64(3000), reversal.Amount)
+		assert.Equal(t, original.ID, reversal.BizRefID)
+		assert.Equal(t, original.ID, reversal.ReversalOfTxID)
+		assert.Equal(t, "wrong_amount", reversal.ReasonCode)
+
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), balance)
+	})
+
+	t.Run("同一流水不能重复冲正，冲正流水本身不能冲正", func(t *testing.T) {
+		_, err := svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: original.ID, ReasonCode: "duplicate_entry"})
+		assertBusinessCode(t, err, common.ErrCodeTransactionReversed)
+
+		txs, err := svc.GetTransactionHistory(ctx, customerID, 1, 1)
+		require.NoError(t, err)
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: txs[0].ID, ReasonCode: "wrong_type"})
+		assertBusinessCode(t, err, common.ErrCodeTransactionIrreversible)
+	})
+
+	t.Run("原因代码必填且须有效，流水须属于该客户", func(t *testing.T) {
+		_, err := svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: original.ID})
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: original.ID, ReasonCode: "other"})
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+
+		require.NoError(t, svc.Credit(ctx, 7002, 500, "充值", "reversal_seed_2"))
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: 7002, TransactionID: original.ID, ReasonCode: "wrong_customer"})
+		assertBusinessCode(t, err, common.ErrCodeTransactionNotFound)
+	})
+
+	t.Run("交易历史展示冲正状态", func(t *testing.T) {
+		txs, total, err := svc.GetTransactions(ctx, customerID, &billing.TransactionHistoryRequest{Page: 1, PageSize: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, txs, 3)
+		reversal, consumed, seed := txs[0], txs[1], txs[2]
+		assert.Equal(t, original.ID, reversal.ReversalOfTxID)
+		assert.Equal(t, reversal.ID, consumed.ReversedByTxID)
+		assert.Zero(t, seed.ReversedByTxID)
+		assert.Zero(t, seed.ReversalOfTxID)
+
+		txs, total, err = svc.GetTransactions(ctx, customerID, &billing.TransactionHistoryRequest{Page: 1, PageSize: 10, Type: "order_pay"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, reversal.ID, txs[0].ReversedByTxID)
+	})
+
+	t.Run("手工交易标记为 manual，订单流程的扣款与退款不能冲正", func(t *testing.T) {
+		assert.Equal(t, manualBizRefType, original.BizRefType)
+		assert.Equal(t, orderID, original.BizRefID)
+
+		require.NoError(t, svc.DebitForOrder(ctx, customerID, orderID, 100, "reversal_order_pay_1"))
+		require.NoError(t, svc.CreditForRefund(ctx, customerID, orderID, 100, "reversal_order_refund_1"))
+		for _, idem := range []string{"reversal_order_pay_1", "reversal_order_refund_1"} {
+			orderTx, err := svc.GetTransactionByIdemKey(ctx, idem)
+			require.NoError(t, err)
+			assert.Equal(t, orderBizRefType, orderTx.BizRefType)
+			_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: orderTx.ID, ReasonCode: "wrong_amount"})
+			assertBusinessCode(t, err, common.ErrCodeTransactionIrreversible)
+		}
+	})
+
+	t.Run("冲正入账流水时余额不足拒绝", func(t *testing.T) {
+		drain, err := svc.CreateTransaction(ctx, &billing.CreateTransactionRequest{CustomerID: customerID, Amount: 90, Type: "consume", OrderID: &orderID, OperatorID: 1})
+		require.NoError(t, err)
+		drainIdem = drain.IdempotencyKey
+		seed, err := svc.GetTransactionByIdemKey(ctx, "reversal_seed_1")
+		require.NoError(t, err)
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: seed.ID, ReasonCode: "wrong_amount"})
+		assertBusinessCode(t, err, common.ErrCodeInsufficientBalance)
+	})
+
+	t.Run("充值记录的流水须通过退回充值处理", func(t *testing.T) {
+		r, err := svc.Recharge(ctx, billing.RechargeRequest{CustomerID: customerID, Amount: 1000, Idem: "reversal_recharge_1"})
+		require.NoError(t, err)
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: r.PrincipalTxID, ReasonCode: "wrong_amount"})
+		assertBusinessCode(t, err, common.ErrCodeTransactionIrreversible)
+	})
+
+	t.Run("冲正手工扣款时恢复其扣减的有效期额度", func(t *testing.T) {
+		lotCustomer := int64(7003)
+		lot, err := svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: lotCustomer, Amount: 2000, ExpiresAt: time.Now().Add(72 * time.Hour).Unix(), Note: "服务补偿", Idem: "reversal_lot_1"})
+		require.NoError(t, err)
+		consume, err := svc.CreateTransaction(ctx, &billing.CreateTransactionRequest{CustomerID: lotCustomer, Amount: 15, Type: "consume", OrderID: &orderID, OperatorID: 1})
+		require.NoError(t, err)
+		debit, err := svc.GetTransactionByIdemKey(ctx, consume.IdempotencyKey)
+		require.NoError(t, err)
+
+		_, err = svc.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: lotCustomer, TransactionID: debit.ID, ReasonCode: "wrong_customer"})
+		require.NoError(t, err)
+		var remaining int64
+		require.NoError(t, db.Model(&WalletCreditLotRecord{}).Where("id = ?", lot.ID).Pluck("remaining", &remaining).Error)
+		assert.Equal(t, int64(2000), remaining, "冲正后额度恢复，不变成永久余额")
+	})
+
+	t.Run("双写同步冲正流水", func(t *testing.T) {
+		createTruthTables(t, db)
+		_, err := NewTruthBackfiller(db).Backfill(ctx, billing.BackfillOptions{})
+		require.NoError(t, err)
+
+		migrating := NewMigratingService(db, tx, false)
+		debit, err := svc.GetTransactionByIdemKey(ctx, drainIdem)
+		require.NoError(t, err)
+		_, err = migrating.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: debit.ID, ReasonCode: "duplicate_entry"})
+		require.NoError(t, err)
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("真相表冲正", func(t *testing.T) {
+		truth := NewTruthServiceWithTx(db, tx)
+		seed, err := truth.GetTransactionByIdemKey(ctx, "reversal_seed_1")
+		require.NoError(t, err)
+		reversal, err := truth.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: seed.ID, ReasonCode: "other", Note: "测试数据"})
+		require.NoError(t, err)
+		assert.Equal(t, "debit", reversal.Direction)
+
+		txs, _, err := truth.GetTransactions(ctx, customerID, &billing.TransactionHistoryRequest{Page: 1, PageSize: 20, Type: constants.WalletTxTypeRecharge})
+		require.NoError(t, err)
+		require.NotEmpty(t, txs)
+		assert.Equal(t, reversal.ID, txs[len(txs)-1].ReversedByTxID)
+
+		_, err = truth.ReverseTransaction(ctx, billing.ReverseTransactionRequest{CustomerID: customerID, TransactionID: seed.ID, ReasonCode: "wrong_amount"})
+		assertBusinessCode(t, err, common.ErrCodeTransactionReversed)
+	})
+}
@@ -0,0 +1,219 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// transferBizRefType 转账流水的业务引用类型，biz_ref_id 为转账记录ID
+const transferBizRefType = "transfer"
+
+// Transfer 钱包转账
+// 转出与转入在同一事务内完成，两个钱包按客户ID升序加锁，避免相向转账时互相等待
+func (s *BillingServiceImpl) Transfer(ctx context.Context, req billing.TransferRequest) (*billing.Transfer, error) {
+	if err := validateTransfer(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Transfer
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 幂等性检查，幂等键按转出客户隔离
+		var existing WalletTransferRecord
+		err := txDB.WithContext(ctx).Where("from_customer_id = ? AND idempotency_key = ?", req.FromCustomerID, req.Idem).First(&existing).Error
+		if err == nil {
+			result = toTransfer(&existing)
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查幂等性失败: %w", err)
+		}
+		if err := ensureTransferTarget(ctx, txDB, req.ToCustomerID); err != nil {
+			return err
+		}
+
+		// 2. 按客户ID升序锁定双方钱包：转出方钱包必须存在，转入方钱包不存在时创建
+		var from, to *model.Wallet
+		lockFrom := func() (err error) {
+			from, err = s.getWalletWithLock(ctx, txQuery, req.FromCustomerID)
+			return err
+		}
+		lockTo := func() (err error) {
+			to, err = s.getOrCreateWalletWithLock(ctx, txQuery, req.ToCustomerID)
+			return err
+		}
+		locks := []func() error{lockFrom, lockTo}
+		if req.ToCustomerID < req.FromCustomerID {
+			locks = []func() error{lockTo, lockFrom}
+		}
+		for _, lock := range locks {
+			if err := lock(); err != nil {
+				return err
+			}
+		}
+		if err := ensureWalletActive(from); err != nil {
+			return err
+		}
+		if err := ensureWalletActive(to); err != nil {
+			return err
+		}
+		if from.Balance < req.Amount {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "转出方钱包余额不足")
+		}
+
+		// 3. 写入转账记录，再按记录ID写入转出与转入流水
+		record := &WalletTransferRecord{
+			FromCustomerID: req.FromCustomerID,
+			ToCustomerID:   req.ToCustomerID,
+			FromWalletID:   from.ID,
+			ToWalletID:     to.ID,
+			Amount:         req.Amount,
+			OperatorID:     req.OperatorID,
+			Note:           req.Note,
+			IdempotencyKey: req.Idem,
+			CreatedAt:      time.Now().Unix(),
+		}
+		if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建转账记录失败: %w", err)
+		}
+
+		outTx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       from.ID,
+			Direction:      "debit",
+			Amount:         req.Amount,
+			Type:           constants.WalletTxTypeTransferOut,
+			BizRefType:     transferBizRefType,
+			BizRefID:       record.ID,
+			IdempotencyKey: fmt.Sprintf("transfer_out_%d", record.ID),
+			OperatorID:     req.OperatorID,
+			Note:           req.Note,
+		})
+		if err != nil {
+			return err
+		}
+		if err := consumeCreditLots(ctx, txDB, from.ID, req.Amount, 0, outTx.ID); err != nil {
+			return err
+		}
+		inTx, err := s.appendWalletTx(ctx, txQuery, &model.WalletTransaction{
+			WalletID:       to.ID,
+			Direction:      "credit",
+			Amount:         req.Amount,
+			Type:           constants.WalletTxTypeTransferIn,
+			BizRefType:     transferBizRefType,
+			BizRefID:       record.ID,
+			IdempotencyKey: fmt.Sprintf("transfer_in_%d", record.ID),
+			OperatorID:     req.OperatorID,
+			Note:           req.Note,
+		})
+		if err != nil {
+			return err
+		}
+		// 转出的有效期额度随转账转入，保留原过期时间
+		if err := transferCreditLots(ctx, txDB, outTx.ID, to.ID, req.ToCustomerID, inTx.ID); err != nil {
+			return err
+		}
+
+		record.OutTxID, record.InTxID = outTx.ID, inTx.ID
+		if err := txDB.WithContext(ctx).Model(record).
+			Updates(map[string]interface{}{"out_tx_id": record.OutTxID, "in_tx_id": record.InTxID}).Error; err != nil {
+			return fmt.Errorf("更新转账记录失败: %w", err)
+		}
+		result = toTransfer(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LockBalance 在调用方事务中锁定客户钱包并返回余额，钱包不存在时返回 0
+func (s *BillingServiceImpl) LockBalance(ctx context.Context, customerID int64) (int64, error) {
+	var wallet model.Wallet
+	err := s.tx.GetDB(ctx).WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("customer_id = ?", customerID).First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("查询钱包失败: %w", err)
+	}
+	return wallet.Balance, nil
+}
+
+// ListTransfers 查询客户转出及转入的转账记录，按时间倒序
+func (s *BillingServiceImpl) ListTransfers(ctx context.Context, customerID int64) ([]billing.Transfer, error) {
+	return listTransfers(ctx, s.db, customerID)
+}
+
+// listTransfers 查询客户转账记录，旧表与真相表实现共用 wallet_transfers
+func listTransfers(ctx context.Context, db *gorm.DB, customerID int64) ([]billing.Transfer, error) {
+	var records []WalletTransferRecord
+	if err := db.WithContext(ctx).
+		Where("from_customer_id = ? OR to_customer_id = ?", customerID, customerID).
+		Order("created_at DESC, id DESC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询转账记录失败: %w", err)
+	}
+	transfers := make([]billing.Transfer, len(records))
+	for i := range records {
+		transfers[i] = *toTransfer(&records[i])
+	}
+	return transfers, nil
+}
+
+// ensureTransferTarget 校验转入客户存在且未删除
+// 钱包表没有外键，转入方钱包不存在时会自动创建，不校验会把资金转入孤立钱包
+func ensureTransferTarget(ctx context.Context, db *gorm.DB, customerID int64) error {
+	var count int64
+	if err := db.WithContext(ctx).Model(&model.Customer{}).Where("id = ?", customerID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询转入客户失败: %w", err)
+	}
+	if count == 0 {
+		return common.NewBusinessError(common.ErrCodeCustomerNotFound, "转入客户不存在")
+	}
+	return nil
+}
+
+func validateTransfer(req *billing.TransferRequest) error {
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "转账金额必须大于0")
+	}
+	if req.FromCustomerID == req.ToCustomerID {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "不能向同一客户转账")
+	}
+	if req.Idem == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "幂等键不能为空")
+	}
+	return nil
+}
+
+func toTransfer(r *WalletTransferRecord) *billing.Transfer {
+	return &billing.Transfer{
+		ID:             r.ID,
+		FromCustomerID: r.FromCustomerID,
+		ToCustomerID:   r.ToCustomerID,
+		FromWalletID:   r.FromWalletID,
+		ToWalletID:     r.ToWalletID,
+		Amount:         r.Amount,
+		OutTxID:        r.OutTxID,
+		InTxID:         r.InTxID,
+		OperatorID:     r.OperatorID,
+		Note:           r.Note,
+		CreatedAt:      r.CreatedAt,
+	}
+}
+
+var _ billing.TransferService = (*BillingServiceImpl)(nil)
@@ -0,0 +1,160 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/billing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletTransfer(t *testing.T) {
+	db := setupWalletDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	svc := newBillingServiceImpl(db, tx)
+	from, to := int64(5002), int64(5001)
+	createWalletCustomers(t, db, 5001, 5002, 5003, 5005, 5006, 5007, 5008)
+	require.NoError(t, db.Exec("INSERT INTO customers (id, name, deleted_at) VALUES (5004, '已删除客户', CURRENT_TIMESTAMP)").Error)
+	require.NoError(t, svc.Credit(ctx, from, 10000, "充值", "transfer_seed_1"))
+
+	t.Run("双方流水均可在交易历史中查到", func(t *testing.T) {
+		transfer, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: to, Amount: 3000, OperatorID: 1, Note: "家庭共享", Idem: "transfer_1"})
+		require.NoError(t, err)
+		assert.NotZero(t, transfer.OutTxID)
+		assert.NotZero(t, transfer.InTxID)
+
+		fromTxs, err := svc.GetTransactionHistory(ctx, from, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, fromTxs, 2)
+		assert.Equal(t, constants.WalletTxTypeTransferOut, fromTxs[0].Type)
+		assert.Equal(t, "debit", fromTxs[0].Direction)
+		assert.Equal(t, transferBizRefType, fromTxs[0].BizRefType)
+		assert.Equal(t, transfer.ID, fromTxs[0].BizRefID)
+
+		toTxs, err := svc.GetTransactionHistory(ctx, to, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, toTxs, 1)
+		assert.Equal(t, constants.WalletTxTypeTransferIn, toTxs[0].Type)
+		assert.Equal(t, transferBizRefType, toTxs[0].BizRefType)
+		assert.Equal(t, transfer.InTxID, toTxs[0].ID)
+
+		fromBalance, err := svc.GetBalance(ctx, from)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7000), fromBalance)
+		toBalance, err := svc.GetBalance(ctx, to)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3000), toBalance)
+	})
+
+	t.Run("相同幂等键不重复转账", func(t *testing.T) {
+		first, err := svc.ListTransfers(ctx, to)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+
+		again, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: to, Amount: 3000, Idem: "transfer_1"})
+		require.NoError(t, err)
+		assert.Equal(t, first[0].ID, again.ID)
+		balance, err := svc.GetBalance(ctx, from)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7000), balance)
+	})
+
+	t.Run("转入客户不存在或已删除时拒绝", func(t *testing.T) {
+		_, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: 5999, Amount: 100, Idem: "transfer_missing"})
+		assertBusinessCode(t, err, common.ErrCodeCustomerNotFound)
+		_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: 5004, Amount: 100, Idem: "transfer_deleted"})
+		assertBusinessCode(t, err, common.ErrCodeCustomerNotFound)
+
+		var count int64
+		require.NoError(t, db.Table("wallets").Where("customer_id IN ?", []int64{5999, 5004}).Count(&count).Error)
+		assert.Zero(t, count, "不为不存在的客户创建钱包")
+	})
+
+	t.Run("余额不足或参数无效时拒绝且不产生流水", func(t *testing.T) {
+		_, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: to, ToCustomerID: from, Amount: 5000, Idem: "transfer_2"})
+		assertBusinessCode(t, err, common.ErrCodeInsufficientBalance)
+		_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: from, Amount: 100, Idem: "transfer_3"})
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+		_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: 5999, ToCustomerID: from, Amount: 100, Idem: "transfer_4"})
+		assertBusinessCode(t, err, common.ErrCodeWalletNotFound)
+
+		toTxs, err := svc.GetTransactionHistory(ctx, to, 1, 10)
+		require.NoError(t, err)
+		assert.Len(t, toTxs, 1)
+	})
+
+	t.Run("任一方钱包冻结时拒绝", func(t *testing.T) {
+		_, err := svc.FreezeWallet(ctx, to, 1, "风控")
+		require.NoError(t, err)
+		_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: to, Amount: 100, Idem: "transfer_5"})
+		assertBusinessCode(t, err, common.ErrCodeWalletFrozen)
+		_, err = svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: to, ToCustomerID: from, Amount: 100, Idem: "transfer_6"})
+		assertBusinessCode(t, err, common.ErrCodeWalletFrozen)
+		_, err = svc.UnfreezeWallet(ctx, to, 1, "解除风控")
+		require.NoError(t, err)
+	})
+
+	t.Run("双写同步转出与转入双方", func(t *testing.T) {
+		createTruthTables(t, db)
+		_, err := NewTruthBackfiller(db).Backfill(ctx, billing.BackfillOptions{})
+		require.NoError(t, err)
+
+		migrating := NewMigratingService(db, tx, false)
+		_, err = migrating.Transfer(ctx, billing.TransferRequest{FromCustomerID: from, ToCustomerID: 5003, Amount: 2000, Idem: "transfer_7"})
+		require.NoError(t, err)
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("真相表转账", func(t *testing.T) {
+		truth := NewTruthServiceWithTx(db, tx)
+		transfer, err := truth.Transfer(ctx, billing.TransferRequest{FromCustomerID: 5003, ToCustomerID: to, Amount: 500, Idem: "transfer_8"})
+		require.NoError(t, err)
+
+		txs, total, err := truth.GetTransactions(ctx, to, &billing.TransactionHistoryRequest{Page: 1, PageSize: 10, Type: constants.WalletTxTypeTransferIn})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, transfer.InTxID, txs[0].ID)
+		balance, err := truth.GetBalance(ctx, 5003)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1500), balance)
+
+		_, err = truth.Transfer(ctx, billing.TransferRequest{FromCustomerID: 5003, ToCustomerID: to, Amount: 5000, Idem: "transfer_9"})
+		assertBusinessCode(t, err, common.ErrCodeInsufficientBalance)
+	})
+
+	t.Run("幂等键按转出客户隔离", func(t *testing.T) {
+		require.NoError(t, svc.Credit(ctx, 5005, 1000, "充值", "transfer_seed_2"))
+		transfer, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: 5005, ToCustomerID: 5006, Amount: 1000, Idem: "transfer_1"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5005), transfer.FromCustomerID)
+		balance, err := svc.GetBalance(ctx, 5006)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), balance, "其他客户的相同幂等键不重放已有转账")
+	})
+
+	t.Run("有效期额度按原过期时间随转账转入", func(t *testing.T) {
+		expiresAt := time.Now().Unix() + 3*86400
+		_, err := svc.GrantCredit(ctx, billing.GrantCreditRequest{CustomerID: 5007, Amount: 2000, ExpiresAt: expiresAt, Note: "活动赠送", Idem: "transfer_seed_3"})
+		require.NoError(t, err)
+		require.NoError(t, svc.Credit(ctx, 5007, 1000, "充值", "transfer_seed_4"))
+
+		transfer, err := svc.Transfer(ctx, billing.TransferRequest{FromCustomerID: 5007, ToCustomerID: 5008, Amount: 2500, Idem: "transfer_10"})
+		require.NoError(t, err)
+
+		lots, err := svc.ListExpiringCredits(ctx, 5007, expiresAt)
+		require.NoError(t, err)
+		assert.Empty(t, lots, "转出方先扣减有效期额度")
+		lots, err = svc.ListExpiringCredits(ctx, 5008, expiresAt)
+		require.NoError(t, err)
+		require.Len(t, lots, 1)
+		assert.Equal(t, int64(2000), lots[0].Remaining)
+		assert.Equal(t, expiresAt, lots[0].ExpiresAt)
+		assert.Equal(t, transfer.InTxID, lots[0].SourceTxID)
+	})
+}
@@ -0,0 +1,172 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// truthSyncResult 单个钱包同步到真相表的结果
+type truthSyncResult struct {
+	created bool // 是否新建了真相表钱包
+	copied  int  // 新复制的流水数
+}
+
+// syncTruthWallet 把旧表钱包及其尚未复制的流水同步到真相表
+// 调用方需持有旧表钱包的行锁；流水只追加且ID递增，只需复制ID大于真相表已有最大流水ID的部分，
+// 余额与状态以旧表为准覆盖。钱包ID与流水ID沿用旧表，切换后其他表中记录的流水ID仍然有效
+func syncTruthWallet(ctx context.Context, db *gorm.DB, wallet *model.Wallet) (truthSyncResult, error) {
+	var result truthSyncResult
+
+	// 1. 确保真相表钱包存在且与旧表钱包ID一致
+	var truth BilWallet
+	err := db.WithContext(ctx).Where("customer_id = ?", wallet.CustomerID).Take(&truth).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		truth = BilWallet{
+			ID:         wallet.ID,
+			CustomerID: wallet.CustomerID,
+			Balance:    wallet.Balance,
+			Status:     int8(wallet.Status),
+			UpdatedAt:  wallet.UpdatedAt,
+		}
+		if err := db.WithContext(ctx).Create(&truth).Error; err != nil {
+			return result, fmt.Errorf("创建真相表钱包失败: %w", err)
+		}
+		result.created = true
+	case err != nil:
+		return result, fmt.Errorf("查询真相表钱包失败: %w", err)
+	case truth.ID != wallet.ID:
+		return result, fmt.Errorf("真相表钱包ID与旧表不一致: customer_id=%d wallet_id=%d bil_wallet_id=%d",
+			wallet.CustomerID, wallet.ID, truth.ID)
+	}
+
+	// 2. 复制尚未同步的流水
+	var lastID int64
+	if err := db.WithContext(ctx).Model(&BilWalletTx{}).
+		Where("wallet_id = ?", wallet.ID).
+		Select("COALESCE(MAX(id), 0)").
+		Scan(&lastID).Error; err != nil {
+		return result, fmt.Errorf("查询真相表流水失败: %w", err)
+	}
+	var txs []model.WalletTransaction
+	if err := db.WithContext(ctx).
+		Where("wallet_id = ? AND id > ?", wallet.ID, lastID).
+		Order("id ASC").
+		Find(&txs).Error; err != nil {
+		return result, fmt.Errorf("查询钱包流水失败: %w", err)
+	}
+	if len(txs) > 0 {
+		records := make([]BilWalletTx, len(txs))
+		for i, t := range txs {
+			records[i] = BilWalletTx{
+				ID:             t.ID,
+				WalletID:       t.WalletID,
+				Direction:      t.Direction,
+				Amount:         t.Amount,
+				Type:           t.Type,
+				BizRefType:     t.BizRefType,
+				BizRefID:       t.BizRefID,
+				IdempotencyKey: t.IdempotencyKey,
+				OperatorID:     t.OperatorID,
+				ReasonCode:     t.ReasonCode,
+				Note:           t.Note,
+				CreatedAt:      t.CreatedAt,
+				PrevHash:       t.PrevHash,
+				Hash:           t.Hash,
+			}
+		}
+		if err := db.WithContext(ctx).Create(&records).Error; err != nil {
+			return result, fmt.Errorf("复制钱包流水失败: %w", err)
+		}
+		result.copied = len(records)
+	}
+
+	// 3. 以旧表为准覆盖余额与状态（新建时同样覆盖，冻结状态 0 会被 status 列默认值忽略）
+	if err := db.WithContext(ctx).Model(&BilWallet{}).Where("id = ?", wallet.ID).
+		Updates(map[string]interface{}{
+			"balance":    wallet.Balance,
+			"status":     wallet.Status,
+			"updated_at": wallet.UpdatedAt,
+		}).Error; err != nil {
+		return result, fmt.Errorf("更新真相表钱包失败: %w", err)
+	}
+	return result, nil
+}
+
+// TruthBackfiller 真相表回填实现
+// 逐个钱包在旧表行锁内同步，与双写并发执行时不会漏复制或重复复制流水
+type TruthBackfiller struct {
+	db *gorm.DB
+}
+
+// NewTruthBackfiller 创建真相表回填实例
+func NewTruthBackfiller(db *gorm.DB) *TruthBackfiller {
+	return &TruthBackfiller{db: db}
+}
+
+// Backfill 把全部旧表钱包及流水回填到真相表
+func (b *TruthBackfiller) Backfill(ctx context.Context, opts billing.BackfillOptions) (*billing.BackfillReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	report := &billing.BackfillReport{StartedAt: time.Now().Unix()}
+	var lastID int64
+	for {
+		var ids []int64
+		if err := b.db.WithContext(ctx).Model(&model.Wallet{}).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(opts.BatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("查询钱包失败: %w", err)
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			result, err := b.backfillWallet(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			report.WalletsScanned++
+			if result.created {
+				report.WalletsCreated++
+			}
+			report.TransactionsCopied += result.copied
+		}
+		if len(ids) < opts.BatchSize {
+			break
+		}
+		lastID = ids[len(ids)-1]
+	}
+	report.FinishedAt = time.Now().Unix()
+	return report, nil
+}
+
+// backfillWallet 在旧表钱包行锁内同步单个钱包
+func (b *TruthBackfiller) backfillWallet(ctx context.Context, walletID int64) (truthSyncResult, error) {
+	var result truthSyncResult
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var wallet model.Wallet
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", walletID).
+			Take(&wallet).Error; err != nil {
+			return fmt.Errorf("查询钱包失败: %w", err)
+		}
+		r, err := syncTruthWallet(ctx, tx, &wallet)
+		result = r
+		return err
+	})
+	return result, err
+}
+
+var _ billing.Backfiller = (*TruthBackfiller)(nil)
@@ -0,0 +1,223 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/gorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTruthTables 创建真相表 bil_wallets / bil_wallet_transactions
+func createTruthTables(t *testing.T, db *gorm.DB) {
+	require.NoError(t, db.Exec(`
+		CREATE TABLE bil_wallets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL UNIQUE,
+			balance INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 1,
+			updated_at INTEGER NOT NULL DEFAULT 0
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE bil_wallet_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			wallet_id INTEGER NOT NULL,
+			direction TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			biz_ref_type TEXT,
+			biz_ref_id INTEGER,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			operator_id INTEGER DEFAULT 0,
+			reason_code TEXT,
+			note TEXT,
+			created_at INTEGER NOT NULL,
+			prev_hash TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL DEFAULT ''
+		)
+	`).Error)
+}
+
+// assertTruthMirrorsLegacy 断言真相表与旧表的钱包和流水完全一致（含ID）
+func assertTruthMirrorsLegacy(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	var wallets []model.Wallet
+	require.NoError(t, db.Order("id").Find(&wallets).Error)
+	var truthWallets []BilWallet
+	require.NoError(t, db.Order("id").Find(&truthWallets).Error)
+	require.Len(t, truthWallets, len(wallets))
+	for i, w := range wallets {
+		assert.Equal(t, w.ID, truthWallets[i].ID)
+		assert.Equal(t, w.CustomerID, truthWallets[i].CustomerID)
+		assert.Equal(t, w.Balance, truthWallets[i].Balance)
+		assert.Equal(t, w.Status, int32(truthWallets[i].Status))
+	}
+
+	var txs []model.WalletTransaction
+	require.NoError(t, db.Order("id").Find(&txs).Error)
+	var truthTxs []BilWalletTx
+	require.NoError(t, db.Order("id").Find(&truthTxs).Error)
+	require.Len(t, truthTxs, len(txs))
+	for i, tx := range txs {
+		assert.Equal(t, tx.ID, truthTxs[i].ID)
+		assert.Equal(t, tx.WalletID, truthTxs[i].WalletID)
+		assert.Equal(t, tx.Amount, truthTxs[i].Amount)
+		assert.Equal(t, tx.IdempotencyKey, truthTxs[i].IdempotencyKey)
+		assert.Equal(t, tx.Hash, truthTxs[i].Hash)
+	}
+}
+
+// TestTruthMigration 旧表到真相表的回填、双写、读比对与切换
+func TestTruthMigration(t *testing.T) {
+	db := setupWalletDB(t)
+	createTruthTables(t, db)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+
+	legacy := NewBillingServiceForMode(db, tx, billing.StoreModeLegacy)
+	require.NoError(t, legacy.Credit(ctx, 4101, 10000, "充值", "migrate_credit_1"))
+	require.NoError(t, legacy.DebitForOrder(ctx, 4101, 1, 3000, "migrate_debit_1"))
+	require.NoError(t, legacy.Credit(ctx, 4102, 5000, "充值", "migrate_credit_2"))
+	_, err := legacy.FreezeWallet(ctx, 4102, 1, "风控")
+	require.NoError(t, err)
+
+	t.Run("回填复制历史并可重复执行", func(t *testing.T) {
+		backfiller := NewTruthBackfiller(db)
+		report, err := backfiller.Backfill(ctx, billing.BackfillOptions{BatchSize: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.WalletsScanned)
+		assert.Equal(t, 2, report.WalletsCreated)
+		assert.Equal(t, 3, report.TransactionsCopied)
+		assertTruthMirrorsLegacy(t, db)
+
+		report, err = backfiller.Backfill(ctx, billing.BackfillOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.WalletsScanned)
+		assert.Zero(t, report.WalletsCreated)
+		assert.Zero(t, report.TransactionsCopied)
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("双写在同一事务内同步真相表", func(t *testing.T) {
+		svc := NewBillingServiceForMode(db, tx, billing.StoreModeDualWrite)
+		require.NoError(t, svc.Credit(ctx, 4101, 2000, "充值", "migrate_credit_3"))
+		require.NoError(t, svc.Credit(ctx, 4103, 800, "充值", "migrate_credit_4"))
+		_, err := svc.UnfreezeWallet(ctx, 4102, 1, "解除风控")
+		require.NoError(t, err)
+
+		recharge, err := NewMigratingService(db, tx, false).Recharge(ctx, billing.RechargeRequest{
+			CustomerID: 4102, Amount: 10000, BonusAmount: 1000, Idem: "migrate_recharge_1",
+		})
+		require.NoError(t, err)
+		assert.NotZero(t, recharge.BonusTxID)
+
+		// 旧表写入失败时真相表不产生任何记录
+		err = svc.DebitForOrder(ctx, 4103, 2, 100000, "migrate_debit_2")
+		assertBusinessCode(t, err, common.ErrCodeInsufficientBalance)
+
+		// 重复请求在旧表上是幂等的空操作，同步同样不会重复写入
+		require.NoError(t, svc.Credit(ctx, 4101, 2000, "充值", "migrate_credit_3"))
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("读比对以旧表为准返回", func(t *testing.T) {
+		require.NoError(t, db.Exec(`UPDATE bil_wallets SET balance = 1 WHERE customer_id = 4101`).Error)
+		svc := NewBillingServiceForMode(db, tx, billing.StoreModeReadCompare)
+		balance, err := svc.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(9000), balance)
+
+		// 下一次写入按旧表覆盖真相表余额
+		require.NoError(t, svc.Credit(ctx, 4101, 1000, "充值", "migrate_credit_5"))
+		assertTruthMirrorsLegacy(t, db)
+	})
+
+	t.Run("切换到真相表后只写真相表", func(t *testing.T) {
+		svc := NewBillingServiceForMode(db, tx, billing.StoreModeTruth)
+		require.IsType(t, &TruthService{}, svc)
+
+		balance, err := svc.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), balance)
+
+		var maxLegacyID int64
+		require.NoError(t, db.Model(&model.WalletTransaction{}).Select("MAX(id)").Scan(&maxLegacyID).Error)
+		require.NoError(t, svc.DebitForOrder(ctx, 4101, 3, 4000, "migrate_debit_3"))
+		created, err := svc.GetTransactionByIdemKey(ctx, "migrate_debit_3")
+		require.NoError(t, err)
+		assert.Greater(t, created.ID, maxLegacyID)
+
+		info, err := svc.GetWalletByCustomerID(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6000), info.Balance)
+		legacyBalance, err := legacy.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), legacyBalance)
+
+		txs, total, err := svc.GetTransactions(ctx, 4101, &billing.TransactionHistoryRequest{Page: 1, PageSize: 2, Type: "recharge"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Len(t, txs, 2)
+
+		_, err = svc.FreezeWallet(ctx, 4103, 1, "风控")
+		require.NoError(t, err)
+		err = svc.Credit(ctx, 4103, 100, "充值", "migrate_credit_6")
+		assertBusinessCode(t, err, common.ErrCodeWalletFrozen)
+		require.NoError(t, svc.CreditForRefund(ctx, 4103, 4, 100, "migrate_refund_1"))
+		balance, err = svc.GetBalance(ctx, 4103)
+		require.NoError(t, err)
+		assert.Equal(t, int64(900), balance)
+		assert.Equal(t, int32(constants.WalletStatusFrozen), mustTruthStatus(t, db, 4103))
+
+	})
+
+	t.Run("切换到真相表后充值赠送与退回充值写真相表", func(t *testing.T) {
+		rechargeSvc := &truthRechargeService{RechargeService: newBillingServiceImpl(db, tx), truth: NewTruthServiceWithTx(db, tx)}
+		truth := NewTruthServiceWithTx(db, tx)
+		before, err := truth.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+
+		recharge, err := rechargeSvc.Recharge(ctx, billing.RechargeRequest{CustomerID: 4101, Amount: 10000, BonusAmount: 1000, BonusExpiresAt: time.Now().Add(72 * time.Hour).Unix(), Idem: "migrate_recharge_2"})
+		require.NoError(t, err)
+		principal, err := truth.GetTransactionByIdemKey(ctx, "migrate_recharge_2")
+		require.NoError(t, err)
+		assert.Equal(t, principal.ID, recharge.PrincipalTxID)
+		assert.NotZero(t, recharge.BonusTxID)
+		balance, err := truth.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, before+11000, balance)
+		legacyBalance, err := legacy.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), legacyBalance, "旧表不再写入")
+
+		summary, err := rechargeSvc.GetRechargeSummary(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1000), summary.BonusAmount)
+
+		refunded, err := rechargeSvc.RefundRecharge(ctx, 4101, recharge.ID, 1, "客户申请退款")
+		require.NoError(t, err)
+		assert.Equal(t, string(constants.RechargeStatusRefunded), refunded.Status)
+		balance, err = truth.GetBalance(ctx, 4101)
+		require.NoError(t, err)
+		assert.Equal(t, before, balance, "本金与赠送一并扣回")
+		lots, err := truth.ListExpiringCredits(ctx, 4101, time.Now().Add(96*time.Hour).Unix())
+		require.NoError(t, err)
+		assert.Empty(t, lots, "赠送额度随退回充值用完")
+	})
+}
+
+func mustTruthStatus(t *testing.T, db *gorm.DB, customerID int64) int32 {
+	t.Helper()
+	var w BilWallet
+	require.NoError(t, db.Where("customer_id = ?", customerID).First(&w).Error)
+	return int32(w.Status)
+}
@@ -19,7 +19,7 @@ type BilWalletTx struct {
 	WalletID       int64  `gorm:"column:wallet_id;index:idx_wallet_time"`
 	Direction      string `gorm:"column:direction;type:enum('credit','debit');not null"`
 	Amount         int64  `gorm:"column:amount;not null"` // cents, positive
-	Type           string `gorm:"column:type;type:enum('recharge','order_pay','order_refund','adjust_in','adjust_out');not null"`
+	Type           string `gorm:"column:type;type:enum('recharge','order_pay','order_refund','adjust_in','adjust_out','recharge_bonus','recharge_refund','bonus_clawback','transfer_in','transfer_out','reversal');not null"`
 	BizRefType     string `gorm:"column:biz_ref_type"`
 	BizRefID       int64  `gorm:"column:biz_ref_id"`
 	IdempotencyKey string `gorm:"column:idempotency_key;uniqueIndex:uk_idem;size:64;not null"`
@@ -27,6 +27,8 @@ type BilWalletTx struct {
 	ReasonCode     string `gorm:"column:reason_code"`
 	Note           string `gorm:"column:note;size:255"`
 	CreatedAt      int64  `gorm:"column:created_at;not null"`
+	PrevHash       string `gorm:"column:prev_hash;size:64;not null;default:''"` // 同一钱包上一笔流水的哈希
+	Hash           string `gorm:"column:hash;size:64;not null;default:''"`      // 本流水的哈希
 	// shadow fields for convenience
 	CreatedAtTime time.Time `gorm:"-"`
 }
@@ -3,8 +3,13 @@ package impl
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
 
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
 	"crm_lite/internal/core/resource"
 	"crm_lite/internal/domains/billing"
 
@@ -12,9 +17,12 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-// TruthService 为后续替换 adapter 的真相实现骨架（暂不接入控制器）
+// TruthService 基于真相表 bil_wallets / bil_wallet_transactions 的 billing 实现
+// wallet.storeMode = truth 时由 provider 返回；写操作通过 common.Tx 执行，可加入调用方的跨域事务
 type TruthService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	tx        common.Tx
+	outboxSvc common.OutboxService
 }
 
 func NewTruthService(rm *resource.Manager) (*TruthService, error) {
@@ -22,56 +30,89 @@ func NewTruthService(rm *resource.Manager) (*TruthService, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &TruthService{db: dbRes.DB}, nil
+	return NewTruthServiceWithTx(dbRes.DB, common.NewTx(dbRes.DB)), nil
+}
+
+// NewTruthServiceWithTx 创建带事务管理的真相表 billing 服务实例
+func NewTruthServiceWithTx(db *gorm.DB, tx common.Tx) *TruthService {
+	return &TruthService{db: db, tx: tx, outboxSvc: common.NewOutboxService(db, tx)}
 }
 
 func (s *TruthService) Credit(ctx context.Context, customerID int64, amount int64, reason, idem string) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		wallet, err := s.lockWallet(tx, customerID)
+	if amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "入账金额必须大于0")
+	}
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		wallet, err := s.lockOrCreateWallet(tx, customerID)
 		if err != nil {
 			return err
 		}
+		if err := ensureBilWalletActive(wallet); err != nil {
+			return err
+		}
 		if err := s.ensureIdem(tx, idem); err != nil {
 			return err
 		}
 		now := time.Now().Unix()
-		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: amount, Type: "recharge", IdempotencyKey: idem, ReasonCode: reason, CreatedAt: now}
-		if err := tx.Create(rec).Error; err != nil {
+		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: amount, Type: "recharge", BizRefType: manualBizRefType, IdempotencyKey: idem, ReasonCode: reason, CreatedAt: now}
+		if err := s.appendTx(tx, rec); err != nil {
 			return err
 		}
 		// 派生更新余额（仍保持“余额只读”语义：余额=累计派生值）
-		return tx.Model(&BilWallet{}).Where("id = ?", wallet.ID).Update("balance", gorm.Expr("balance + ?", amount)).Error
+		return s.addBalance(tx, wallet.ID, amount, now)
 	})
 }
 
 func (s *TruthService) DebitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return s.debitForOrder(ctx, customerID, orderID, amount, idem, orderBizRefType)
+}
+
+// debitForOrder 写入 order_pay 扣款流水，bizRefType 区分订单流程（order）与手工交易（manual）
+func (s *TruthService) debitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem, bizRefType string) error {
+	if amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "扣款金额必须大于0")
+	}
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
 		wallet, err := s.lockWallet(tx, customerID)
 		if err != nil {
 			return err
 		}
-		if err := s.ensureIdem(tx, idem); err != nil {
+		if err := ensureBilWalletActive(wallet); err != nil {
 			return err
 		}
-		// 余额校验
-		var bal int64
-		if err := tx.Model(&BilWallet{}).Where("id = ?", wallet.ID).Select("balance").Scan(&bal).Error; err != nil {
+		if err := s.ensureIdem(tx, idem); err != nil {
 			return err
 		}
-		if bal < amount {
-			return errors.New("insufficient balance")
+		// 余额校验（钱包行已加锁）
+		if wallet.Balance < amount {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "余额不足")
 		}
 		now := time.Now().Unix()
-		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "debit", Amount: amount, Type: "order_pay", BizRefType: "order", BizRefID: orderID, IdempotencyKey: idem, CreatedAt: now}
-		if err := tx.Create(rec).Error; err != nil {
+		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "debit", Amount: amount, Type: "order_pay", BizRefType: bizRefType, BizRefID: orderID, IdempotencyKey: idem, CreatedAt: now}
+		if err := s.appendTx(tx, rec); err != nil {
+			return err
+		}
+		if err := s.addBalance(tx, wallet.ID, -amount, now); err != nil {
 			return err
 		}
-		return tx.Model(&BilWallet{}).Where("id = ?", wallet.ID).Update("balance", gorm.Expr("balance - ?", amount)).Error
+		return consumeCreditLots(ctx, tx, wallet.ID, amount, 0, rec.ID)
 	})
 }
 
 func (s *TruthService) CreditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
-	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	return s.creditForRefund(ctx, customerID, orderID, amount, idem, orderBizRefType)
+}
+
+// creditForRefund 写入 order_refund 入账流水，bizRefType 区分订单流程（order）与手工交易（manual）；
+// 手工退款与订单扣款无关，不恢复有效期额度
+func (s *TruthService) creditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem, bizRefType string) error {
+	if amount <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "退款金额必须大于0")
+	}
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
 		wallet, err := s.lockWallet(tx, customerID)
 		if err != nil {
 			return err
@@ -80,23 +121,71 @@ func (s *TruthService) CreditForRefund(ctx context.Context, customerID, orderID
 			return err
 		}
 		now := time.Now().Unix()
-		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: amount, Type: "order_refund", BizRefType: "order", BizRefID: orderID, IdempotencyKey: idem, CreatedAt: now}
-		if err := tx.Create(rec).Error; err != nil {
+		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: amount, Type: "order_refund", BizRefType: bizRefType, BizRefID: orderID, IdempotencyKey: idem, CreatedAt: now}
+		if err := s.appendTx(tx, rec); err != nil {
+			return err
+		}
+		if err := s.addBalance(tx, wallet.ID, amount, now); err != nil {
 			return err
 		}
-		return tx.Model(&BilWallet{}).Where("id = ?", wallet.ID).Update("balance", gorm.Expr("balance + ?", amount)).Error
+		if bizRefType != orderBizRefType {
+			return nil
+		}
+		return restoreOrderCreditLots(ctx, tx, &BilWalletTx{}, wallet.ID, orderID, amount, rec.ID)
 	})
 }
 
 // helpers
+
+// appendTx 计算哈希链后写入真相表流水，调用方需持有钱包行锁
+func (s *TruthService) appendTx(tx *gorm.DB, rec *BilWalletTx) error {
+	if err := sealBilWalletTx(tx, rec); err != nil {
+		return err
+	}
+	return tx.Create(rec).Error
+}
+
 func (s *TruthService) lockWallet(tx *gorm.DB, customerID int64) (*BilWallet, error) {
 	var w BilWallet
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("customer_id = ?", customerID).First(&w).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeWalletNotFound, "钱包不存在")
+		}
 		return nil, err
 	}
 	return &w, nil
 }
 
+// lockOrCreateWallet 获取钱包并加行锁，钱包不存在时创建
+func (s *TruthService) lockOrCreateWallet(tx *gorm.DB, customerID int64) (*BilWallet, error) {
+	var w BilWallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("customer_id = ?", customerID).First(&w).Error
+	if err == nil {
+		return &w, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	w = BilWallet{CustomerID: customerID, Balance: 0, Status: int8(constants.WalletStatusNormal), UpdatedAt: time.Now().Unix()}
+	if err := tx.Create(&w).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (s *TruthService) addBalance(tx *gorm.DB, walletID, delta, now int64) error {
+	return tx.Model(&BilWallet{}).Where("id = ?", walletID).
+		Updates(map[string]interface{}{"balance": gorm.Expr("balance + ?", delta), "updated_at": now}).Error
+}
+
+// ensureBilWalletActive 校验钱包未被冻结；退款入账不做此校验
+func ensureBilWalletActive(w *BilWallet) error {
+	if int32(w.Status) != constants.WalletStatusNormal {
+		return common.NewBusinessError(common.ErrCodeWalletFrozen, "钱包已冻结")
+	}
+	return nil
+}
+
 func (s *TruthService) ensureIdem(tx *gorm.DB, idem string) error {
 	if idem == "" {
 		return errors.New("idempotency key required")
@@ -106,58 +195,657 @@ func (s *TruthService) ensureIdem(tx *gorm.DB, idem string) error {
 		return err
 	}
 	if count > 0 {
-		return errors.New("duplicate idempotency key")
+		return common.NewBusinessError(common.ErrCodeDuplicateTransaction, "重复的幂等键")
 	}
 	return nil
 }
 
-// GetBalance 获取客户钱包余额 - 临时实现
+// GetBalance 获取客户钱包余额，钱包不存在时返回0
 func (s *TruthService) GetBalance(ctx context.Context, customerID int64) (int64, error) {
-	// TODO: 在PR-2中实现具体逻辑
-	return 0, nil
+	var w BilWallet
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Where("customer_id = ?", customerID).First(&w).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return w.Balance, nil
 }
 
-// GetTransactionHistory 获取交易历史 - 临时实现
+// GetTransactionHistory 分页查询交易历史，按时间倒序
 func (s *TruthService) GetTransactionHistory(ctx context.Context, customerID int64, page, pageSize int) ([]billing.Transaction, error) {
-	// TODO: 在PR-2中实现具体逻辑
-	return []billing.Transaction{}, nil
+	txs, _, err := s.GetTransactions(ctx, customerID, &billing.TransactionHistoryRequest{Page: page, PageSize: pageSize})
+	return txs, err
 }
 
-// GetWalletByCustomerID 获取客户钱包信息 - 临时实现
+// GetWalletByCustomerID 获取客户钱包信息，钱包不存在时返回 WALLET_NOT_FOUND
 func (s *TruthService) GetWalletByCustomerID(ctx context.Context, customerID int64) (*billing.WalletInfo, error) {
-	// TODO: 在PR-2中实现具体逻辑
-	return &billing.WalletInfo{
-		ID:         0,
-		CustomerID: customerID,
-		Balance:    0,
-		Status:     1,
-		UpdatedAt:  0,
-	}, nil
+	var w BilWallet
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Where("customer_id = ?", customerID).First(&w).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeWalletNotFound, "钱包不存在")
+		}
+		return nil, err
+	}
+	return &billing.WalletInfo{ID: w.ID, CustomerID: w.CustomerID, Balance: w.Balance, Status: int32(w.Status), UpdatedAt: w.UpdatedAt}, nil
 }
 
-// CreateTransaction 创建交易 - 临时实现
+// CreateTransaction 创建手工交易：recharge 充值（按规则或手工指定金额发放赠送）、consume 扣款、refund 退款
 func (s *TruthService) CreateTransaction(ctx context.Context, req *billing.CreateTransactionRequest) (*billing.Transaction, error) {
-	// TODO: 在PR-2中实现具体逻辑
-	return &billing.Transaction{
-		ID:             0,
-		WalletID:       0,
-		Direction:      "credit",
-		Amount:         int64(req.Amount * 100),
-		Type:           req.Type,
-		BizRefType:     "manual",
-		BizRefID:       0,
-		IdempotencyKey: "",
-		OperatorID:     req.OperatorID,
-		ReasonCode:     req.Reason,
-		Note:           req.Reason,
-		CreatedAt:      0,
-	}, nil
-}
-
-// GetTransactions 获取交易历史 - 临时实现
+	idemKey := fmt.Sprintf("%s_%d_%d_%d", req.Type, req.CustomerID, time.Now().UnixNano(), req.OperatorID)
+	amount := int64(req.Amount * 100)
+
+	var result *billing.Transaction
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		// 手工交易（含手工退款）一律不允许操作冻结的钱包
+		var w BilWallet
+		err := s.tx.GetDB(ctx).WithContext(ctx).Where("customer_id = ?", req.CustomerID).First(&w).Error
+		if err == nil {
+			if err := ensureBilWalletActive(&w); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		switch req.Type {
+		case "recharge":
+			reason := req.Reason
+			if reason == "" {
+				reason = "充值"
+			}
+			_, err = s.Recharge(ctx, billing.RechargeRequest{
+				CustomerID:  req.CustomerID,
+				Amount:      amount,
+				BonusAmount: int64(math.Round(req.BonusAmount * 100)),
+				OperatorID:  req.OperatorID,
+				Note:        reason,
+				Idem:        idemKey,
+			})
+		case "consume":
+			if req.OrderID == nil {
+				return errors.New("consume操作必须指定OrderID")
+			}
+			err = s.debitForOrder(ctx, req.CustomerID, *req.OrderID, amount, idemKey, manualBizRefType)
+		case "refund":
+			if req.OrderID == nil {
+				return errors.New("refund操作必须指定OrderID")
+			}
+			err = s.creditForRefund(ctx, req.CustomerID, *req.OrderID, amount, idemKey, manualBizRefType)
+		default:
+			return errors.New("unsupported transaction type")
+		}
+		if err != nil {
+			return err
+		}
+		result, err = s.GetTransactionByIdemKey(ctx, idemKey)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTransactions 分页查询交易历史，可按交易类型筛选，按时间倒序
 func (s *TruthService) GetTransactions(ctx context.Context, customerID int64, req *billing.TransactionHistoryRequest) ([]billing.Transaction, int64, error) {
-	// TODO: 在PR-2中实现具体逻辑
-	return []billing.Transaction{}, 0, nil
+	db := s.tx.GetDB(ctx).WithContext(ctx)
+	var w BilWallet
+	if err := db.Where("customer_id = ?", customerID).First(&w).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []billing.Transaction{}, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	q := db.Model(&BilWalletTx{}).Where("wallet_id = ?", w.ID)
+	if req.Type != "" {
+		q = q.Where("type = ?", req.Type)
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var records []BilWalletTx
+	if err := q.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, err
+	}
+	txs := make([]billing.Transaction, len(records))
+	for i := range records {
+		txs[i] = *toTruthTransaction(&records[i])
+	}
+	if err := fillReversalStatus(ctx, db, &BilWalletTx{}, txs); err != nil {
+		return nil, 0, err
+	}
+	return txs, total, nil
+}
+
+// GetTransactionByIdemKey 根据幂等键查询真相表流水
+// 使用上下文中的事务连接，可读到同一事务内尚未提交的流水
+func (s *TruthService) GetTransactionByIdemKey(ctx context.Context, idem string) (*billing.Transaction, error) {
+	var rec BilWalletTx
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Where("idempotency_key = ?", idem).First(&rec).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeResourceNotFound, "交易不存在")
+		}
+		return nil, err
+	}
+	return toTruthTransaction(&rec), nil
+}
+
+func toTruthTransaction(rec *BilWalletTx) *billing.Transaction {
+	return &billing.Transaction{
+		ID:             rec.ID,
+		WalletID:       rec.WalletID,
+		Direction:      rec.Direction,
+		Amount:         rec.Amount,
+		Type:           rec.Type,
+		BizRefType:     rec.BizRefType,
+		BizRefID:       rec.BizRefID,
+		IdempotencyKey: rec.IdempotencyKey,
+		OperatorID:     rec.OperatorID,
+		ReasonCode:     rec.ReasonCode,
+		Note:           rec.Note,
+		CreatedAt:      rec.CreatedAt,
+		PrevHash:       rec.PrevHash,
+		Hash:           rec.Hash,
+	}
+}
+
+// FreezeWallet 冻结真相表钱包，钱包不存在时先创建再冻结
+func (s *TruthService) FreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return s.changeStatus(ctx, customerID, operatorID, reason, constants.WalletStatusFrozen)
+}
+
+// UnfreezeWallet 解冻真相表钱包
+func (s *TruthService) UnfreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return s.changeStatus(ctx, customerID, operatorID, reason, constants.WalletStatusNormal)
+}
+
+// changeStatus 在同一事务内变更钱包状态、写入状态日志并发布 outbox 事件，与 BillingServiceImpl 行为一致
+func (s *TruthService) changeStatus(ctx context.Context, customerID, operatorID int64, reason string, toStatus int32) (*billing.WalletInfo, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "变更原因不能为空")
+	}
+
+	var info *billing.WalletInfo
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		var wallet *BilWallet
+		var err error
+		if toStatus == constants.WalletStatusFrozen {
+			wallet, err = s.lockOrCreateWallet(tx, customerID)
+		} else {
+			wallet, err = s.lockWallet(tx, customerID)
+		}
+		if err != nil {
+			return err
+		}
+		if int32(wallet.Status) == toStatus {
+			return common.NewBusinessError(common.ErrCodeWalletStatusInvalid, "钱包状态未变化")
+		}
+		now := time.Now().Unix()
+		if err := tx.Model(&BilWallet{}).Where("id = ?", wallet.ID).
+			Updates(map[string]interface{}{"status": toStatus, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		log := &WalletStatusLogRecord{WalletID: wallet.ID, CustomerID: customerID, FromStatus: int32(wallet.Status), ToStatus: toStatus, OperatorID: operatorID, Reason: reason, CreatedAt: now}
+		if err := tx.Create(log).Error; err != nil {
+			return err
+		}
+		eventType := common.EventTypeWalletUnfrozen
+		if toStatus == constants.WalletStatusFrozen {
+			eventType = common.EventTypeWalletFrozen
+		}
+		event := common.WalletStatusChangedEvent{WalletID: wallet.ID, CustomerID: customerID, OperatorID: operatorID, Reason: reason, ChangedAt: now}
+		if err := s.outboxSvc.PublishEvent(ctx, eventType, event); err != nil {
+			return fmt.Errorf("发布钱包状态事件失败: %w", err)
+		}
+		info = &billing.WalletInfo{ID: wallet.ID, CustomerID: customerID, Balance: wallet.Balance, Status: toStatus, UpdatedAt: now}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListWalletStatusLogs 查询钱包状态变更日志
+func (s *TruthService) ListWalletStatusLogs(ctx context.Context, customerID int64) ([]billing.WalletStatusLog, error) {
+	var records []WalletStatusLogRecord
+	if err := s.db.WithContext(ctx).Where("customer_id = ?", customerID).Order("created_at DESC, id DESC").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	logs := make([]billing.WalletStatusLog, len(records))
+	for i, r := range records {
+		logs[i] = billing.WalletStatusLog{ID: r.ID, WalletID: r.WalletID, CustomerID: r.CustomerID, FromStatus: r.FromStatus, ToStatus: r.ToStatus, OperatorID: r.OperatorID, Reason: r.Reason, CreatedAt: r.CreatedAt}
+	}
+	return logs, nil
+}
+
+// Transfer 真相表钱包转账，与 BillingServiceImpl.Transfer 行为一致，转账记录同样写入 wallet_transfers
+func (s *TruthService) Transfer(ctx context.Context, req billing.TransferRequest) (*billing.Transfer, error) {
+	if err := validateTransfer(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Transfer
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		var existing WalletTransferRecord
+		err := tx.Where("from_customer_id = ? AND idempotency_key = ?", req.FromCustomerID, req.Idem).First(&existing).Error
+		if err == nil {
+			result = toTransfer(&existing)
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := ensureTransferTarget(ctx, tx, req.ToCustomerID); err != nil {
+			return err
+		}
+
+		// 按客户ID升序加锁，避免相向转账时互相等待
+		var from, to *BilWallet
+		lockFrom := func() (err error) {
+			from, err = s.lockWallet(tx, req.FromCustomerID)
+			return err
+		}
+		lockTo := func() (err error) {
+			to, err = s.lockOrCreateWallet(tx, req.ToCustomerID)
+			return err
+		}
+		locks := []func() error{lockFrom, lockTo}
+		if req.ToCustomerID < req.FromCustomerID {
+			locks = []func() error{lockTo, lockFrom}
+		}
+		for _, lock := range locks {
+			if err := lock(); err != nil {
+				return err
+			}
+		}
+		if err := ensureBilWalletActive(from); err != nil {
+			return err
+		}
+		if err := ensureBilWalletActive(to); err != nil {
+			return err
+		}
+		if from.Balance < req.Amount {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "转出方钱包余额不足")
+		}
+
+		now := time.Now().Unix()
+		record := &WalletTransferRecord{FromCustomerID: req.FromCustomerID, ToCustomerID: req.ToCustomerID, FromWalletID: from.ID, ToWalletID: to.ID, Amount: req.Amount, OperatorID: req.OperatorID, Note: req.Note, IdempotencyKey: req.Idem, CreatedAt: now}
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		outTx := &BilWalletTx{WalletID: from.ID, Direction: "debit", Amount: req.Amount, Type: constants.WalletTxTypeTransferOut, BizRefType: transferBizRefType, BizRefID: record.ID, IdempotencyKey: fmt.Sprintf("transfer_out_%d", record.ID), OperatorID: req.OperatorID, Note: req.Note, CreatedAt: now}
+		inTx := &BilWalletTx{WalletID: to.ID, Direction: "credit", Amount: req.Amount, Type: constants.WalletTxTypeTransferIn, BizRefType: transferBizRefType, BizRefID: record.ID, IdempotencyKey: fmt.Sprintf("transfer_in_%d", record.ID), OperatorID: req.OperatorID, Note: req.Note, CreatedAt: now}
+		for _, rec := range []*BilWalletTx{outTx, inTx} {
+			if err := s.appendTx(tx, rec); err != nil {
+				return err
+			}
+		}
+		if err := s.addBalance(tx, from.ID, -req.Amount, now); err != nil {
+			return err
+		}
+		if err := consumeCreditLots(ctx, tx, from.ID, req.Amount, 0, outTx.ID); err != nil {
+			return err
+		}
+		if err := s.addBalance(tx, to.ID, req.Amount, now); err != nil {
+			return err
+		}
+		if err := transferCreditLots(ctx, tx, outTx.ID, to.ID, req.ToCustomerID, inTx.ID); err != nil {
+			return err
+		}
+
+		record.OutTxID, record.InTxID = outTx.ID, inTx.ID
+		if err := tx.Model(record).Updates(map[string]interface{}{"out_tx_id": record.OutTxID, "in_tx_id": record.InTxID}).Error; err != nil {
+			return err
+		}
+		result = toTransfer(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReverseTransaction 在真相表冲正流水，与 BillingServiceImpl.ReverseTransaction 行为一致
+func (s *TruthService) ReverseTransaction(ctx context.Context, req billing.ReverseTransactionRequest) (*billing.Transaction, error) {
+	if err := validateReversal(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Transaction
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		w, err := s.lockWallet(tx, req.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureBilWalletActive(w); err != nil {
+			return err
+		}
+		var original BilWalletTx
+		if err := tx.Where("id = ? AND wallet_id = ?", req.TransactionID, w.ID).First(&original).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodeTransactionNotFound, "流水不存在")
+			}
+			return err
+		}
+		if err := checkReversible(ctx, tx, &BilWalletTx{}, toTruthTransaction(&original)); err != nil {
+			return err
+		}
+		direction, delta := reverseDirection(original.Direction), original.Amount
+		if direction == "debit" {
+			if w.Balance < original.Amount {
+				return common.NewBusinessError(common.ErrCodeInsufficientBalance, "钱包余额不足，无法冲正")
+			}
+			delta = -original.Amount
+		}
+
+		now := time.Now().Unix()
+		rec := &BilWalletTx{WalletID: w.ID, Direction: direction, Amount: original.Amount, Type: constants.WalletTxTypeReversal, BizRefType: reversalBizRefType, BizRefID: original.ID, IdempotencyKey: reversalIdemKey(original.ID), OperatorID: req.OperatorID, ReasonCode: req.ReasonCode, Note: reversalNote(req.Note, original.ID), CreatedAt: now}
+		if err := s.appendTx(tx, rec); err != nil {
+			return err
+		}
+		if err := s.addBalance(tx, w.ID, delta, now); err != nil {
+			return err
+		}
+		if err := reverseCreditLots(ctx, tx, w.ID, original.ID, original.Amount, direction, rec.ID); err != nil {
+			return err
+		}
+		result = toTruthTransaction(rec)
+		result.ReversalOfTxID = original.ID
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// LockBalance 在调用方事务中锁定真相表钱包并返回余额，钱包不存在时返回 0
+func (s *TruthService) LockBalance(ctx context.Context, customerID int64) (int64, error) {
+	w, err := s.lockWallet(s.tx.GetDB(ctx).WithContext(ctx), customerID)
+	if err != nil {
+		var bizErr *common.BusinessError
+		if errors.As(err, &bizErr) && bizErr.Code == common.ErrCodeWalletNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return w.Balance, nil
+}
+
+// ListTransfers 查询客户转出及转入的转账记录，按时间倒序
+func (s *TruthService) ListTransfers(ctx context.Context, customerID int64) ([]billing.Transfer, error) {
+	return listTransfers(ctx, s.db, customerID)
+}
+
+// Recharge 真相表充值，与 BillingServiceImpl.Recharge 行为一致，充值记录同样写入 wallet_recharges
+func (s *TruthService) Recharge(ctx context.Context, req billing.RechargeRequest) (*billing.Recharge, error) {
+	if err := validateRecharge(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.Recharge
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		var existing WalletRechargeRecord
+		err := tx.Where("idempotency_key = ?", req.Idem).First(&existing).Error
+		if err == nil {
+			result = toRecharge(&existing)
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		wallet, err := s.lockOrCreateWallet(tx, req.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureBilWalletActive(wallet); err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		record, bonusExpiresAt, err := createRechargeRecord(ctx, tx, &req, wallet.ID, now)
+		if err != nil {
+			return err
+		}
+
+		principal := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: req.Amount, Type: constants.WalletTxTypeRecharge, BizRefType: rechargeBizRefType, BizRefID: record.ID, IdempotencyKey: req.Idem, OperatorID: req.OperatorID, Note: req.Note, CreatedAt: now}
+		if err := s.appendTx(tx, principal); err != nil {
+			return err
+		}
+		record.PrincipalTxID = principal.ID
+		if record.BonusAmount > 0 {
+			note := fmt.Sprintf("充值赠送: %d", record.ID)
+			bonusTx := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: record.BonusAmount, Type: constants.WalletTxTypeRechargeBonus, BizRefType: rechargeBizRefType, BizRefID: record.ID, IdempotencyKey: fmt.Sprintf("recharge_bonus_%d", record.ID), OperatorID: req.OperatorID, Note: note, CreatedAt: now}
+			if err := s.appendTx(tx, bonusTx); err != nil {
+				return err
+			}
+			record.BonusTxID = bonusTx.ID
+			if bonusExpiresAt > 0 {
+				lot, err := createCreditLot(ctx, tx, wallet.ID, req.CustomerID, record.BonusAmount, bonusExpiresAt, note)
+				if err != nil {
+					return err
+				}
+				if err := bindCreditLotSource(ctx, tx, lot, bonusTx.ID); err != nil {
+					return err
+				}
+			}
+		}
+		if err := s.addBalance(tx, wallet.ID, req.Amount+record.BonusAmount, now); err != nil {
+			return err
+		}
+
+		if err := tx.Model(record).Updates(map[string]interface{}{"principal_tx_id": record.PrincipalTxID, "bonus_tx_id": record.BonusTxID}).Error; err != nil {
+			return err
+		}
+		result = toRecharge(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RefundRecharge 在真相表退回充值，与 BillingServiceImpl.RefundRecharge 行为一致
+func (s *TruthService) RefundRecharge(ctx context.Context, customerID, rechargeID, operatorID int64, reason string) (*billing.Recharge, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "退回原因不能为空")
+	}
+
+	var result *billing.Recharge
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		var record WalletRechargeRecord
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND customer_id = ?", rechargeID, customerID).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodeRechargeNotFound, "充值记录不存在")
+			}
+			return err
+		}
+		if record.Status != string(constants.RechargeStatusCompleted) {
+			return common.NewBusinessError(common.ErrCodeRechargeStatusInvalid, "充值已退回")
+		}
+
+		wallet, err := s.lockWallet(tx, customerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureBilWalletActive(wallet); err != nil {
+			return err
+		}
+		clawback, err := bonusClawbackAmount(ctx, tx, &record)
+		if err != nil {
+			return err
+		}
+		if wallet.Balance < record.Amount+clawback {
+			return common.NewBusinessError(common.ErrCodeInsufficientBalance, "钱包余额不足以扣回充值本金及赠送")
+		}
+
+		now := time.Now().Unix()
+		refundTx := &BilWalletTx{WalletID: wallet.ID, Direction: "debit", Amount: record.Amount, Type: constants.WalletTxTypeRechargeRefund, BizRefType: rechargeBizRefType, BizRefID: record.ID, IdempotencyKey: fmt.Sprintf("recharge_refund_%d", record.ID), OperatorID: operatorID, Note: reason, CreatedAt: now}
+		if err := s.appendTx(tx, refundTx); err != nil {
+			return err
+		}
+		record.RefundTxID = refundTx.ID
+		if clawback > 0 {
+			clawbackTx := &BilWalletTx{WalletID: wallet.ID, Direction: "debit", Amount: clawback, Type: constants.WalletTxTypeBonusClawback, BizRefType: rechargeBizRefType, BizRefID: record.ID, IdempotencyKey: fmt.Sprintf("bonus_clawback_%d", record.ID), OperatorID: operatorID, Note: reason, CreatedAt: now}
+			if err := s.appendTx(tx, clawbackTx); err != nil {
+				return err
+			}
+			record.ClawbackTxID = clawbackTx.ID
+		}
+		if err := s.addBalance(tx, wallet.ID, -(record.Amount + clawback), now); err != nil {
+			return err
+		}
+		if err := consumeCreditLots(ctx, tx, wallet.ID, record.Amount+clawback, record.BonusTxID, 0); err != nil {
+			return err
+		}
+
+		record.Status = string(constants.RechargeStatusRefunded)
+		record.RefundedAt = now
+		if err := tx.Model(&record).Updates(map[string]interface{}{
+			"status":         record.Status,
+			"refund_tx_id":   record.RefundTxID,
+			"clawback_tx_id": record.ClawbackTxID,
+			"refunded_at":    record.RefundedAt,
+		}).Error; err != nil {
+			return err
+		}
+		result = toRecharge(&record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetRechargeSummary 按真相表流水汇总客户实付资金与赠送资金
+func (s *TruthService) GetRechargeSummary(ctx context.Context, customerID int64) (*billing.RechargeSummary, error) {
+	return rechargeSummary(ctx, s.db, &BilWallet{}, &BilWalletTx{}, customerID)
+}
+
+// GrantCredit 在真相表发放有效期额度，与 BillingServiceImpl.GrantCredit 行为一致
+func (s *TruthService) GrantCredit(ctx context.Context, req billing.GrantCreditRequest) (*billing.CreditLot, error) {
+	if err := validateGrantCredit(&req); err != nil {
+		return nil, err
+	}
+
+	var result *billing.CreditLot
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		var existing BilWalletTx
+		err := tx.Where("idempotency_key = ?", req.Idem).First(&existing).Error
+		if err == nil {
+			result, err = findCreditLotBySourceTx(ctx, tx, existing.ID)
+			return err
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		wallet, err := s.lockOrCreateWallet(tx, req.CustomerID)
+		if err != nil {
+			return err
+		}
+		if err := ensureBilWalletActive(wallet); err != nil {
+			return err
+		}
+		lot, err := createCreditLot(ctx, tx, wallet.ID, req.CustomerID, req.Amount, req.ExpiresAt, req.Note)
+		if err != nil {
+			return err
+		}
+		now := time.Now().Unix()
+		rec := &BilWalletTx{WalletID: wallet.ID, Direction: "credit", Amount: req.Amount, Type: "adjust_in", BizRefType: creditLotBizRefType, BizRefID: lot.ID, IdempotencyKey: req.Idem, OperatorID: req.OperatorID, Note: req.Note, CreatedAt: now}
+		if err := s.appendTx(tx, rec); err != nil {
+			return err
+		}
+		if err := s.addBalance(tx, wallet.ID, req.Amount, now); err != nil {
+			return err
+		}
+		if err := bindCreditLotSource(ctx, tx, lot, rec.ID); err != nil {
+			return err
+		}
+		result = toCreditLot(lot)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListExpiringCredits 查询客户在 before 之前到期且仍有剩余的额度
+func (s *TruthService) ListExpiringCredits(ctx context.Context, customerID, before int64) ([]billing.CreditLot, error) {
+	return listExpiringCredits(ctx, s.db, customerID, before)
+}
+
+// ExpireCredits 逐个钱包扣回已到期额度的剩余金额
+func (s *TruthService) ExpireCredits(ctx context.Context, opts billing.ExpireCreditsOptions) (*billing.ExpireCreditsReport, error) {
+	return expireCredits(ctx, s.db, opts, s.expireCustomerCredits)
+}
+
+// expireCustomerCredits 在钱包行锁内扣回客户全部已到期额度的剩余金额，扣回金额不超过钱包余额
+func (s *TruthService) expireCustomerCredits(ctx context.Context, customerID, now int64) (lots int, amount int64, err error) {
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		tx := s.tx.GetDB(ctx).WithContext(ctx)
+		wallet, err := s.lockWallet(tx, customerID)
+		if err != nil {
+			return err
+		}
+		due, err := findDueCreditLots(ctx, tx, wallet.ID, now)
+		if err != nil {
+			return err
+		}
+
+		balance := wallet.Balance
+		for i := range due {
+			lot := &due[i]
+			expired := min(lot.Remaining, balance)
+			var txID int64
+			if expired > 0 {
+				ts := time.Now().Unix()
+				rec := &BilWalletTx{WalletID: wallet.ID, Direction: "debit", Amount: expired, Type: "adjust_out", BizRefType: creditLotBizRefType, BizRefID: lot.ID, IdempotencyKey: fmt.Sprintf("credit_expire_%d", lot.ID), Note: creditExpireNote, CreatedAt: ts}
+				if err := s.appendTx(tx, rec); err != nil {
+					return err
+				}
+				if err := s.addBalance(tx, wallet.ID, -expired, ts); err != nil {
+					return err
+				}
+				txID = rec.ID
+				balance -= expired
+			}
+			if err := closeCreditLot(ctx, tx, lot, expired, txID); err != nil {
+				return err
+			}
+			lots++
+			amount += expired
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return lots, amount, nil
 }
 
 var _ billing.Service = (*TruthService)(nil)
+var _ billing.TransferService = (*TruthService)(nil)
+var _ billing.CreditExpiryService = (*TruthService)(nil)
+var _ billing.ReversalService = (*TruthService)(nil)
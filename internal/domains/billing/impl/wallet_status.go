@@ -0,0 +1,134 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/billing"
+)
+
+// FreezeWallet 冻结客户钱包
+// 钱包不存在时先创建再冻结，保证之后的首次充值同样被拦截
+func (s *BillingServiceImpl) FreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return s.changeWalletStatus(ctx, customerID, operatorID, reason, constants.WalletStatusFrozen)
+}
+
+// UnfreezeWallet 解冻客户钱包
+func (s *BillingServiceImpl) UnfreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return s.changeWalletStatus(ctx, customerID, operatorID, reason, constants.WalletStatusNormal)
+}
+
+// changeWalletStatus 在同一事务内变更钱包状态、写入状态日志并发布 outbox 事件
+func (s *BillingServiceImpl) changeWalletStatus(ctx context.Context, customerID, operatorID int64, reason string, toStatus int32) (*billing.WalletInfo, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "变更原因不能为空")
+	}
+
+	var result *billing.WalletInfo
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		txQuery := query.Use(txDB)
+
+		// 1. 获取钱包并加行锁
+		var wallet *model.Wallet
+		var err error
+		if toStatus == constants.WalletStatusFrozen {
+			wallet, err = s.getOrCreateWalletWithLock(ctx, txQuery, customerID)
+		} else {
+			wallet, err = s.getWalletWithLock(ctx, txQuery, customerID)
+		}
+		if err != nil {
+			return err
+		}
+		if wallet.Status == toStatus {
+			if toStatus == constants.WalletStatusFrozen {
+				return common.NewBusinessError(common.ErrCodeWalletStatusInvalid, "钱包已处于冻结状态")
+			}
+			return common.NewBusinessError(common.ErrCodeWalletStatusInvalid, "钱包未被冻结")
+		}
+
+		// 2. 更新状态
+		now := time.Now().Unix()
+		if _, err := txQuery.Wallet.WithContext(ctx).
+			Where(txQuery.Wallet.ID.Eq(wallet.ID)).
+			UpdateSimple(txQuery.Wallet.Status.Value(toStatus), txQuery.Wallet.UpdatedAt.Value(now)); err != nil {
+			return fmt.Errorf("更新钱包状态失败: %w", err)
+		}
+
+		// 3. 写入状态日志
+		log := &WalletStatusLogRecord{
+			WalletID:   wallet.ID,
+			CustomerID: customerID,
+			FromStatus: wallet.Status,
+			ToStatus:   toStatus,
+			OperatorID: operatorID,
+			Reason:     reason,
+			CreatedAt:  now,
+		}
+		if err := txDB.WithContext(ctx).Create(log).Error; err != nil {
+			return fmt.Errorf("写入钱包状态日志失败: %w", err)
+		}
+
+		// 4. 发布状态变更事件
+		eventType := common.EventTypeWalletUnfrozen
+		if toStatus == constants.WalletStatusFrozen {
+			eventType = common.EventTypeWalletFrozen
+		}
+		event := common.WalletStatusChangedEvent{
+			WalletID:   wallet.ID,
+			CustomerID: customerID,
+			OperatorID: operatorID,
+			Reason:     reason,
+			ChangedAt:  now,
+		}
+		if err := s.outboxSvc.PublishEvent(ctx, eventType, event); err != nil {
+			return fmt.Errorf("发布钱包状态事件失败: %w", err)
+		}
+
+		result = &billing.WalletInfo{
+			ID:         wallet.ID,
+			CustomerID: wallet.CustomerID,
+			Balance:    wallet.Balance,
+			Status:     toStatus,
+			UpdatedAt:  now,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListWalletStatusLogs 查询客户钱包的状态变更日志，按时间倒序
+func (s *BillingServiceImpl) ListWalletStatusLogs(ctx context.Context, customerID int64) ([]billing.WalletStatusLog, error) {
+	var records []WalletStatusLogRecord
+	if err := s.db.WithContext(ctx).
+		Where("customer_id = ?", customerID).
+		Order("created_at DESC, id DESC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询钱包状态日志失败: %w", err)
+	}
+
+	logs := make([]billing.WalletStatusLog, len(records))
+	for i, r := range records {
+		logs[i] = billing.WalletStatusLog{
+			ID:         r.ID,
+			WalletID:   r.WalletID,
+			CustomerID: r.CustomerID,
+			FromStatus: r.FromStatus,
+			ToStatus:   r.ToStatus,
+			OperatorID: r.OperatorID,
+			Reason:     r.Reason,
+			CreatedAt:  r.CreatedAt,
+		}
+	}
+	return logs, nil
+}
@@ -0,0 +1,165 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupWalletDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Exec(`
+		CREATE TABLE wallets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			balance INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at INTEGER NOT NULL DEFAULT 0
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE wallet_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			wallet_id INTEGER NOT NULL,
+			direction TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			biz_ref_type TEXT,
+			biz_ref_id INTEGER,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			operator_id INTEGER DEFAULT 0,
+			reason_code TEXT,
+			note TEXT,
+			created_at INTEGER NOT NULL,
+			prev_hash TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL DEFAULT ''
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE sys_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			processed_at INTEGER NULL
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			deleted_at DATETIME
+		)
+	`).Error)
+	require.NoError(t, db.AutoMigrate(&WalletStatusLogRecord{}, &RechargeBonusRuleRecord{}, &WalletRechargeRecord{}, &WalletTransferRecord{}, &WalletCreditLotRecord{}, &WalletCreditLotUsageRecord{}))
+	return db
+}
+
+// createWalletCustomers 创建转账等需要校验客户存在的测试客户
+func createWalletCustomers(t *testing.T, db *gorm.DB, ids ...int64) {
+	t.Helper()
+	for _, id := range ids {
+		require.NoError(t, db.Exec("INSERT INTO customers (id, name) VALUES (?, ?)", id, "测试客户").Error)
+	}
+}
+
+// assertBusinessCode 断言错误为指定代码的业务错误
+func assertBusinessCode(t *testing.T, err error, code string) {
+	t.Helper()
+	var businessErr *common.BusinessError
+	require.True(t, errors.As(err, &businessErr), "应该是业务错误: %v", err)
+	assert.Equal(t, code, businessErr.Code)
+}
+
+// TestWalletFreeze 钱包冻结/解冻及冻结状态校验
+func TestWalletFreeze(t *testing.T) {
+	db := setupWalletDB(t)
+	svc := NewBillingServiceImpl(db, common.NewTx(db))
+	ctx := context.Background()
+	customerID := int64(2001)
+	operatorID := int64(7)
+
+	require.NoError(t, svc.Credit(ctx, customerID, 10000, "充值", "freeze_credit_1"))
+
+	t.Run("冻结后拒绝充值、扣款和手工交易", func(t *testing.T) {
+		info, err := svc.FreezeWallet(ctx, customerID, operatorID, "疑似盗刷")
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), info.Status)
+
+		assertBusinessCode(t, svc.Credit(ctx, customerID, 100, "充值", "freeze_credit_2"), common.ErrCodeWalletFrozen)
+		assertBusinessCode(t, svc.DebitForOrder(ctx, customerID, 1, 100, "freeze_debit_1"), common.ErrCodeWalletFrozen)
+
+		orderID := int64(1)
+		_, err = svc.CreateTransaction(ctx, &billing.CreateTransactionRequest{
+			CustomerID: customerID, Amount: 1, Type: "refund", OrderID: &orderID, OperatorID: operatorID,
+		})
+		assertBusinessCode(t, err, common.ErrCodeWalletFrozen)
+
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10000), balance)
+	})
+
+	t.Run("冻结期间订单退款仍可入账", func(t *testing.T) {
+		require.NoError(t, svc.CreditForRefund(ctx, customerID, 1, 500, "freeze_refund_1"))
+		balance, err := svc.GetBalance(ctx, customerID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10500), balance)
+	})
+
+	t.Run("重复冻结被拒绝", func(t *testing.T) {
+		_, err := svc.FreezeWallet(ctx, customerID, operatorID, "再次冻结")
+		assertBusinessCode(t, err, common.ErrCodeWalletStatusInvalid)
+	})
+
+	t.Run("解冻后恢复扣款", func(t *testing.T) {
+		_, err := svc.UnfreezeWallet(ctx, customerID, operatorID, "核实无误")
+		require.NoError(t, err)
+		require.NoError(t, svc.DebitForOrder(ctx, customerID, 2, 500, "freeze_debit_2"))
+
+		_, err = svc.UnfreezeWallet(ctx, customerID, operatorID, "重复解冻")
+		assertBusinessCode(t, err, common.ErrCodeWalletStatusInvalid)
+	})
+
+	t.Run("冻结原因必填", func(t *testing.T) {
+		_, err := svc.FreezeWallet(ctx, customerID, operatorID, "  ")
+		assertBusinessCode(t, err, common.ErrCodeInvalidParam)
+	})
+
+	t.Run("状态日志与事件", func(t *testing.T) {
+		logs, err := svc.ListWalletStatusLogs(ctx, customerID)
+		require.NoError(t, err)
+		require.Len(t, logs, 2)
+		assert.Equal(t, int32(0), logs[0].FromStatus)
+		assert.Equal(t, int32(1), logs[0].ToStatus)
+		assert.Equal(t, "核实无误", logs[0].Reason)
+		assert.Equal(t, "疑似盗刷", logs[1].Reason)
+		assert.Equal(t, operatorID, logs[1].OperatorID)
+
+		var events []string
+		require.NoError(t, db.Raw(`SELECT event_type FROM sys_outbox ORDER BY id`).Scan(&events).Error)
+		assert.Equal(t, []string{common.EventTypeWalletFrozen, common.EventTypeWalletUnfrozen}, events)
+	})
+
+	t.Run("无钱包客户可直接冻结，之后首次充值被拒绝", func(t *testing.T) {
+		newCustomer := int64(2002)
+		_, err := svc.UnfreezeWallet(ctx, newCustomer, operatorID, "解冻")
+		assertBusinessCode(t, err, common.ErrCodeWalletNotFound)
+
+		_, err = svc.FreezeWallet(ctx, newCustomer, operatorID, "预防性冻结")
+		require.NoError(t, err)
+		assertBusinessCode(t, svc.Credit(ctx, newCustomer, 100, "充值", "freeze_credit_3"), common.ErrCodeWalletFrozen)
+	})
+}
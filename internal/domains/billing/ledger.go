@@ -0,0 +1,92 @@
+package billing
+
+import "context"
+
+// 哈希链断点原因
+const (
+	LedgerBreakPrevHashMismatch    = "prev_hash_mismatch"    // prev_hash 与上一笔流水的 hash 不一致（流水被删除、插入或重排）
+	LedgerBreakHashMismatch        = "hash_mismatch"         // 按流水内容重算的 hash 与存储值不一致（流水内容被修改）
+	LedgerBreakUnsealedAfterSealed = "unsealed_after_sealed" // 已封存的流水之后出现未计算哈希的流水
+	LedgerBreakUnsealedAfterStart  = "unsealed_after_start"  // 哈希链起点之后出现未计算哈希的流水（哈希被抹去）
+)
+
+// LedgerVerifyOptions 流水哈希链校验选项
+type LedgerVerifyOptions struct {
+	Sources   []string // 校验数据源（ReconcileSourceLegacy / ReconcileSourceTruth），为空时校验全部数据源
+	BatchSize int      // 每批校验的钱包数及每次读取的流水数，默认 500
+}
+
+// LedgerBreak 钱包哈希链的第一个断点
+type LedgerBreak struct {
+	Source       string `json:"source"`        // 数据源
+	WalletID     int64  `json:"wallet_id"`     // 钱包ID
+	CustomerID   int64  `json:"customer_id"`   // 客户ID
+	TxID         int64  `json:"tx_id"`         // 断点所在流水ID
+	Reason       string `json:"reason"`        // 断点原因
+	ExpectedHash string `json:"expected_hash"` // 期望值：prev_hash_mismatch 为上一笔流水的 hash，hash_mismatch 为重算的 hash
+	StoredHash   string `json:"stored_hash"`   // 存储值：prev_hash_mismatch 为 prev_hash，hash_mismatch 为 hash
+}
+
+// LedgerVerifyReport 流水哈希链校验报告
+type LedgerVerifyReport struct {
+	StartedAt           int64         `json:"started_at"`
+	FinishedAt          int64         `json:"finished_at"`
+	Sources             []string      `json:"sources"`              // 实际校验的数据源（表不存在的数据源会被跳过）
+	ChainStartTxID      int64         `json:"chain_start_tx_id"`    // 哈希链起点：ID 不大于该值的流水为链前历史流水
+	WalletsChecked      int           `json:"wallets_checked"`      // 校验的钱包数
+	TransactionsChecked int           `json:"transactions_checked"` // 校验的已封存流水数
+	Unsealed            int           `json:"unsealed"`             // 哈希链起点之前写入、未计算哈希的流水数
+	Breaks              []LedgerBreak `json:"breaks"`               // 哈希链断裂的钱包，每个钱包只报告第一个断点
+}
+
+// LedgerVerifier 流水哈希链校验接口
+// 每笔流水的 hash 覆盖其内容与同一钱包上一笔流水的 hash，任何修改、删除或插入都会使链在该处断开
+type LedgerVerifier interface {
+	// VerifyLedger 按流水ID顺序逐个钱包校验哈希链，报告每个钱包的第一个断点
+	VerifyLedger(ctx context.Context, opts LedgerVerifyOptions) (*LedgerVerifyReport, error)
+}
+
+// StatementRequest 钱包对账单导出请求
+type StatementRequest struct {
+	CustomerID int64 `json:"customer_id"`
+	From       int64 `json:"from"` // 起始时间（Unix时间戳，含）
+	To         int64 `json:"to"`   // 截止时间（Unix时间戳，不含）
+}
+
+// StatementEntry 对账单明细
+type StatementEntry struct {
+	TxID         int64  `json:"tx_id"`
+	Direction    string `json:"direction"`
+	Amount       int64  `json:"amount"` // 金额（分）
+	Type         string `json:"type"`
+	BizRefType   string `json:"biz_ref_type"`
+	BizRefID     int64  `json:"biz_ref_id"`
+	Note         string `json:"note"`
+	CreatedAt    int64  `json:"created_at"`
+	BalanceAfter int64  `json:"balance_after"` // 本笔流水后的余额（分）
+	Hash         string `json:"hash"`          // 流水哈希，可与流水表逐笔核对
+}
+
+// Statement 签名的钱包对账单
+// Signature 为 Signature 置空后整份对账单 JSON 的 HMAC，接收方用相同密钥重算即可确认对账单未被篡改
+type Statement struct {
+	CustomerID     int64            `json:"customer_id"`
+	WalletID       int64            `json:"wallet_id"`
+	From           int64            `json:"from"`
+	To             int64            `json:"to"`
+	OpeningBalance int64            `json:"opening_balance"` // 期初余额（分）：From 之前流水的汇总
+	ClosingBalance int64            `json:"closing_balance"` // 期末余额（分）
+	TotalCredit    int64            `json:"total_credit"`    // 期间入账合计（分）
+	TotalDebit     int64            `json:"total_debit"`     // 期间出账合计（分）
+	Entries        []StatementEntry `json:"entries"`
+	HeadHash       string           `json:"head_hash"` // To 之前最后一笔流水的 hash，锚定对账单在哈希链上的位置
+	GeneratedAt    int64            `json:"generated_at"`
+	SignAlgorithm  string           `json:"sign_algorithm"` // 签名算法，固定为 HMAC-SHA256
+	Signature      string           `json:"signature"`      // 十六进制签名
+}
+
+// StatementService 钱包对账单服务接口
+type StatementService interface {
+	// ExportStatement 导出客户在 [From, To) 内的签名对账单，钱包不存在返回 WALLET_NOT_FOUND
+	ExportStatement(ctx context.Context, req StatementRequest) (*Statement, error)
+}
@@ -0,0 +1,42 @@
+package billing
+
+import "context"
+
+// 钱包存储模式：旧表 wallets / wallet_transactions 迁移到真相表 bil_wallets / bil_wallet_transactions 的各个阶段
+// 推荐的切换顺序：legacy → dual_write → 回填 → read_compare → 对账无偏差后 truth
+const (
+	StoreModeLegacy      = "legacy"       // 只读写旧表（默认）
+	StoreModeDualWrite   = "dual_write"   // 以旧表为准，每次写入后在同一事务内同步到真相表
+	StoreModeReadCompare = "read_compare" // 在双写基础上，读取时同时读真相表并记录不一致
+	StoreModeTruth       = "truth"        // 只读写真相表（TruthService）
+)
+
+// IsValidStoreMode 检查钱包存储模式是否有效
+func IsValidStoreMode(mode string) bool {
+	switch mode {
+	case StoreModeLegacy, StoreModeDualWrite, StoreModeReadCompare, StoreModeTruth:
+		return true
+	}
+	return false
+}
+
+// BackfillOptions 真相表回填选项
+type BackfillOptions struct {
+	BatchSize int // 每批处理的钱包数，默认 500
+}
+
+// BackfillReport 真相表回填报告
+type BackfillReport struct {
+	StartedAt          int64 `json:"started_at"`
+	FinishedAt         int64 `json:"finished_at"`
+	WalletsScanned     int   `json:"wallets_scanned"`     // 扫描的旧表钱包数
+	WalletsCreated     int   `json:"wallets_created"`     // 新建的真相表钱包数
+	TransactionsCopied int   `json:"transactions_copied"` // 新复制的流水数
+}
+
+// Backfiller 真相表回填接口
+// 按钱包逐个把旧表的钱包与流水复制到真相表，钱包ID与流水ID沿用旧表；
+// 已复制的流水不会重复写入，可反复执行，也可在双写开启后执行以补齐历史数据
+type Backfiller interface {
+	Backfill(ctx context.Context, opts BackfillOptions) (*BackfillReport, error)
+}
@@ -0,0 +1,97 @@
+package billing
+
+import "context"
+
+// BonusRule 充值赠送规则
+// 充值金额达到门槛即赠送固定金额；同时满足多条规则时取门槛最高的一条
+type BonusRule struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`         // 规则名称，如：充100送20
+	MinAmount   int64  `json:"min_amount"`   // 充值门槛（分）
+	BonusAmount int64  `json:"bonus_amount"` // 赠送金额（分）
+	ValidDays   int    `json:"valid_days"`   // 赠送有效天数，0表示永久有效
+	StartAt     int64  `json:"start_at"`     // 生效开始时间，0表示不限
+	EndAt       int64  `json:"end_at"`       // 生效结束时间（不含），0表示不限
+	IsActive    bool   `json:"is_active"`    // 是否启用
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+// SaveBonusRuleRequest 创建或更新充值赠送规则请求
+type SaveBonusRuleRequest struct {
+	Name        string `json:"name"`
+	MinAmount   int64  `json:"min_amount"`
+	BonusAmount int64  `json:"bonus_amount"`
+	ValidDays   int    `json:"valid_days"`
+	StartAt     int64  `json:"start_at"`
+	EndAt       int64  `json:"end_at"`
+	IsActive    bool   `json:"is_active"`
+}
+
+// RechargeRequest 充值请求
+type RechargeRequest struct {
+	CustomerID     int64  `json:"customer_id"`
+	Amount         int64  `json:"amount"`           // 充值本金（分）
+	BonusAmount    int64  `json:"bonus_amount"`     // 手工指定赠送金额（分），0 表示按赠送规则计算
+	BonusExpiresAt int64  `json:"bonus_expires_at"` // 赠送过期时间（Unix时间戳），0 表示按命中规则的有效天数计算，规则未设置时永久有效
+	OperatorID     int64  `json:"operator_id"`
+	Note           string `json:"note"`
+	Idem           string `json:"idem"` // 幂等键，相同幂等键返回已有充值记录
+}
+
+// Recharge 充值记录
+// 本金与赠送分别入账，退回时本金与赠送一并扣回
+type Recharge struct {
+	ID            int64  `json:"id"`
+	WalletID      int64  `json:"wallet_id"`
+	CustomerID    int64  `json:"customer_id"`
+	Amount        int64  `json:"amount"`          // 充值本金（分）
+	BonusAmount   int64  `json:"bonus_amount"`    // 赠送金额（分）
+	RuleID        int64  `json:"rule_id"`         // 命中的赠送规则ID，0表示无规则或手工指定
+	PrincipalTxID int64  `json:"principal_tx_id"` // 本金入账流水ID
+	BonusTxID     int64  `json:"bonus_tx_id"`     // 赠送入账流水ID
+	Status        string `json:"status"`          // 状态：completed/refunded
+	OperatorID    int64  `json:"operator_id"`
+	Note          string `json:"note"`
+	CreatedAt     int64  `json:"created_at"`
+	RefundedAt    int64  `json:"refunded_at"`
+}
+
+// RechargeSummary 客户充值资金汇总，区分实付资金与赠送资金
+type RechargeSummary struct {
+	PaidAmount     int64 `json:"paid_amount"`      // 累计充值本金（分）
+	RefundedAmount int64 `json:"refunded_amount"`  // 累计退回本金（分）
+	BonusAmount    int64 `json:"bonus_amount"`     // 累计赠送（分）
+	ClawbackAmount int64 `json:"clawback_amount"`  // 累计收回赠送（分）
+	NetPaidAmount  int64 `json:"net_paid_amount"`  // 实付净额 = 本金 - 退回本金
+	NetBonusAmount int64 `json:"net_bonus_amount"` // 赠送净额 = 赠送 - 收回赠送
+}
+
+// RechargeService 充值及充值赠送服务接口
+type RechargeService interface {
+	// CreateBonusRule 创建充值赠送规则
+	CreateBonusRule(ctx context.Context, req SaveBonusRuleRequest) (*BonusRule, error)
+
+	// UpdateBonusRule 更新充值赠送规则，只影响之后的充值
+	UpdateBonusRule(ctx context.Context, id int64, req SaveBonusRuleRequest) (*BonusRule, error)
+
+	// DeleteBonusRule 删除充值赠送规则，已发放的赠送不受影响
+	DeleteBonusRule(ctx context.Context, id int64) error
+
+	// ListBonusRules 查询全部充值赠送规则，按门槛升序
+	ListBonusRules(ctx context.Context) ([]BonusRule, error)
+
+	// Recharge 充值：本金以 recharge 入账，赠送以 recharge_bonus 单独入账
+	// 赠送设置了过期时间时同时记录有效期额度；冻结的钱包返回 WALLET_FROZEN
+	Recharge(ctx context.Context, req RechargeRequest) (*Recharge, error)
+
+	// RefundRecharge 退回充值：扣回本金（recharge_refund）并收回赠送（bonus_clawback），已过期扣回的赠送不再重复收回
+	// 余额不足以同时扣回本金与赠送时返回 INSUFFICIENT_BALANCE，已退回时返回 RECHARGE_STATUS_INVALID
+	RefundRecharge(ctx context.Context, customerID, rechargeID, operatorID int64, reason string) (*Recharge, error)
+
+	// ListRecharges 查询客户充值记录，按时间倒序
+	ListRecharges(ctx context.Context, customerID int64) ([]Recharge, error)
+
+	// GetRechargeSummary 汇总客户实付资金与赠送资金
+	GetRechargeSummary(ctx context.Context, customerID int64) (*RechargeSummary, error)
+}
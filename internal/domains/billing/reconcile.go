@@ -0,0 +1,45 @@
+package billing
+
+import "context"
+
+// 对账数据源
+const (
+	ReconcileSourceLegacy = "wallets"     // wallets / wallet_transactions（BillingServiceImpl）
+	ReconcileSourceTruth  = "bil_wallets" // bil_wallets / bil_wallet_transactions（TruthService）
+)
+
+// ReconcileOptions 余额对账选项
+type ReconcileOptions struct {
+	Sources   []string // 对账数据源，为空时对账全部数据源
+	Repair    bool     // 是否将余额修复为流水汇总值
+	BatchSize int      // 每批检查的钱包数，默认 500
+}
+
+// BalanceDrift 钱包余额偏差
+type BalanceDrift struct {
+	Source          string `json:"source"`           // 数据源
+	WalletID        int64  `json:"wallet_id"`        // 钱包ID
+	CustomerID      int64  `json:"customer_id"`      // 客户ID
+	StoredBalance   int64  `json:"stored_balance"`   // 钱包表中的余额（分）
+	ComputedBalance int64  `json:"computed_balance"` // 按流水汇总的余额（分）
+	LastTxID        int64  `json:"last_tx_id"`       // 最后一笔流水ID，无流水为0
+	LastTxAt        int64  `json:"last_tx_at"`       // 最后一笔流水时间（Unix时间戳）
+	Repaired        bool   `json:"repaired"`         // 是否已修复
+}
+
+// ReconcileReport 余额对账报告
+type ReconcileReport struct {
+	StartedAt      int64          `json:"started_at"`
+	FinishedAt     int64          `json:"finished_at"`
+	Sources        []string       `json:"sources"`         // 实际对账的数据源（表不存在的数据源会被跳过）
+	WalletsChecked int            `json:"wallets_checked"` // 检查的钱包数
+	Drifts         []BalanceDrift `json:"drifts"`          // 存在偏差的钱包
+	Repaired       int            `json:"repaired"`        // 已修复的钱包数
+}
+
+// Reconciler 钱包余额对账接口
+// wallets.balance 是流水的只读投影，对账按流水重新汇总余额并报告偏差
+type Reconciler interface {
+	// Reconcile 对账全部钱包；Repair 为 true 时在行锁内重算并修复偏差
+	Reconcile(ctx context.Context, opts ReconcileOptions) (*ReconcileReport, error)
+}
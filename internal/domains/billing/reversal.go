@@ -0,0 +1,21 @@
+package billing
+
+import "context"
+
+// ReverseTransactionRequest 冲正流水请求
+type ReverseTransactionRequest struct {
+	CustomerID    int64  `json:"customer_id"`
+	TransactionID int64  `json:"transaction_id"` // 被冲正的原流水ID，须属于该客户的钱包
+	ReasonCode    string `json:"reason_code"`    // 冲正原因代码，取值见 constants.ValidWalletReversalReasons
+	OperatorID    int64  `json:"operator_id"`
+	Note          string `json:"note"` // 备注，原因代码为 other 时必填
+}
+
+// ReversalService 钱包流水冲正服务接口
+type ReversalService interface {
+	// ReverseTransaction 冲正一笔手工录入错误的流水：写入方向相反、金额相同的 reversal 流水并关联原流水
+	// 仅手工交易（biz_ref_type=manual）的流水可以冲正；同一流水只能冲正一次，重复冲正返回 TRANSACTION_REVERSED；
+	// 下单支付、订单退款、充值记录、转账、有效期额度等流水须走对应流程，返回 TRANSACTION_IRREVERSIBLE
+	// 冻结的钱包返回 WALLET_FROZEN，冲正入账流水时余额不足返回 INSUFFICIENT_BALANCE
+	ReverseTransaction(ctx context.Context, req ReverseTransactionRequest) (*Transaction, error)
+}
@@ -22,6 +22,8 @@ type Service interface {
 	// CreditForRefund 订单退款入账
 	// 专用于订单退款场景的入账操作
 	// 必须关联原订单ID，确保退款可追溯
+	// 冻结的钱包仍允许退款入账，避免客户资金滞留在已退款的订单上
+	// 原订单扣款使用的有效期额度随退款恢复，保留原过期时间
 	CreditForRefund(ctx context.Context, customerID, orderID int64, amount int64, idem string) error
 
 	// GetBalance 获取客户钱包余额
@@ -32,6 +34,23 @@ type Service interface {
 	// 分页查询客户的钱包交易记录
 	GetTransactionHistory(ctx context.Context, customerID int64, page, pageSize int) ([]Transaction, error)
 
+	// GetTransactionByIdemKey 根据幂等键查询交易
+	// 用于业务方在同一事务内回查刚写入的交易流水，交易不存在时返回 RESOURCE_NOT_FOUND
+	GetTransactionByIdemKey(ctx context.Context, idem string) (*Transaction, error)
+
+	// FreezeWallet 冻结客户钱包
+	// 冻结后充值、订单扣款和手工交易均返回 WALLET_FROZEN；钱包不存在时先创建再冻结
+	// 已冻结时返回 WALLET_STATUS_INVALID，成功后写入状态日志并发布 wallet.frozen 事件
+	FreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*WalletInfo, error)
+
+	// UnfreezeWallet 解冻客户钱包
+	// 钱包不存在时返回 WALLET_NOT_FOUND，未冻结时返回 WALLET_STATUS_INVALID
+	// 成功后写入状态日志并发布 wallet.unfrozen 事件
+	UnfreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*WalletInfo, error)
+
+	// ListWalletStatusLogs 查询客户钱包的状态变更日志，按时间倒序
+	ListWalletStatusLogs(ctx context.Context, customerID int64) ([]WalletStatusLog, error)
+
 	// 控制器接口 - 兼容现有控制器
 	GetWalletByCustomerID(ctx context.Context, customerID int64) (*WalletInfo, error)
 	CreateTransaction(ctx context.Context, req *CreateTransactionRequest) (*Transaction, error)
@@ -46,6 +65,8 @@ type CreateTransactionRequest struct {
 	Reason     string  `json:"reason"`
 	OrderID    *int64  `json:"order_id,omitempty"`
 	OperatorID int64   `json:"operator_id"`
+	// BonusAmount 充值时手工指定的赠送金额（元），0 表示按充值赠送规则计算
+	BonusAmount float64 `json:"bonus_amount,omitempty"`
 }
 
 // TransactionHistoryRequest 交易历史请求
@@ -70,6 +91,14 @@ type Transaction struct {
 	ReasonCode     string `json:"reason_code"`     // 原因代码
 	Note           string `json:"note"`            // 备注
 	CreatedAt      int64  `json:"created_at"`      // 创建时间（Unix时间戳）
+
+	// 冲正状态，查询交易历史时填充
+	ReversedByTxID int64 `json:"reversed_by_tx_id,omitempty"` // 冲正该流水的流水ID，未冲正为0
+	ReversalOfTxID int64 `json:"reversal_of_tx_id,omitempty"` // 本流水为冲正流水时，被冲正的原流水ID
+
+	// 哈希链：hash = SHA-256(prev_hash + 流水内容)，启用哈希链之前的历史流水为空
+	PrevHash string `json:"prev_hash"` // 同一钱包上一笔流水的哈希
+	Hash     string `json:"hash"`      // 本流水的哈希
 }
 
 // WalletInfo 钱包信息
@@ -82,6 +111,18 @@ type WalletInfo struct {
 	UpdatedAt  int64 `json:"updated_at"`  // 最后更新时间
 }
 
+// WalletStatusLog 钱包状态变更日志
+type WalletStatusLog struct {
+	ID         int64  `json:"id"`
+	WalletID   int64  `json:"wallet_id"`   // 钱包ID
+	CustomerID int64  `json:"customer_id"` // 客户ID
+	FromStatus int32  `json:"from_status"` // 原状态：1-正常，0-冻结
+	ToStatus   int32  `json:"to_status"`   // 目标状态：1-正常，0-冻结
+	OperatorID int64  `json:"operator_id"` // 操作人ID
+	Reason     string `json:"reason"`      // 变更原因
+	CreatedAt  int64  `json:"created_at"`  // 变更时间（Unix时间戳）
+}
+
 // Repository 钱包域数据访问接口
 // 定义钱包相关的数据持久化操作
 type Repository interface {
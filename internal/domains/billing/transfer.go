@@ -0,0 +1,45 @@
+package billing
+
+import "context"
+
+// TransferRequest 钱包转账请求
+type TransferRequest struct {
+	FromCustomerID int64  `json:"from_customer_id"`
+	ToCustomerID   int64  `json:"to_customer_id"`
+	Amount         int64  `json:"amount"` // 转账金额（分）
+	OperatorID     int64  `json:"operator_id"`
+	Note           string `json:"note"`
+	Idem           string `json:"idem"` // 幂等键，相同幂等键返回已有转账记录
+}
+
+// Transfer 钱包转账记录
+// 转出流水 transfer_out 与转入流水 transfer_in 的 biz_ref_type 均为 transfer，biz_ref_id 为转账记录ID
+type Transfer struct {
+	ID             int64  `json:"id"`
+	FromCustomerID int64  `json:"from_customer_id"`
+	ToCustomerID   int64  `json:"to_customer_id"`
+	FromWalletID   int64  `json:"from_wallet_id"`
+	ToWalletID     int64  `json:"to_wallet_id"`
+	Amount         int64  `json:"amount"`    // 转账金额（分）
+	OutTxID        int64  `json:"out_tx_id"` // 转出流水ID
+	InTxID         int64  `json:"in_tx_id"`  // 转入流水ID
+	OperatorID     int64  `json:"operator_id"`
+	Note           string `json:"note"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// TransferService 钱包转账服务接口
+type TransferService interface {
+	// Transfer 在同一事务内从转出方钱包扣款并入账到转入方钱包，转入方钱包不存在时自动创建
+	// 转出金额优先扣减转出方的有效期额度，扣减的额度按原过期时间在转入方钱包重建
+	// 转入客户不存在或已删除返回 CUSTOMER_NOT_FOUND，任一方钱包冻结返回 WALLET_FROZEN，转出方余额不足返回 INSUFFICIENT_BALANCE
+	// 幂等键按转出客户隔离
+	Transfer(ctx context.Context, req TransferRequest) (*Transfer, error)
+
+	// LockBalance 在调用方事务中锁定客户钱包并返回余额，钱包不存在时返回 0
+	// 用于先读余额再转出全部余额的场景，锁持有到事务结束，期间余额不会被其他交易改变
+	LockBalance(ctx context.Context, customerID int64) (int64, error)
+
+	// ListTransfers 查询客户转出及转入的转账记录，按时间倒序
+	ListTransfers(ctx context.Context, customerID int64) ([]Transfer, error)
+}
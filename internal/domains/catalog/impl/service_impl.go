@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
 	"crm_lite/internal/dao/model"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/catalog"
@@ -23,11 +25,15 @@ var (
 
 // ServiceImpl 通过 gorm-gen query 访问产品数据
 type ServiceImpl struct {
-	q *query.Query
+	q  *query.Query
+	tx common.Tx // 可选，库存变更时用于加入调用方事务
 }
 
 func New(q *query.Query) *ServiceImpl { return &ServiceImpl{q: q} }
 
+// NewWithTx 创建带事务管理器的产品服务，库存扣减/回补会复用 ctx 中的事务
+func NewWithTx(q *query.Query, tx common.Tx) *ServiceImpl { return &ServiceImpl{q: q, tx: tx} }
+
 // toDomain 将 DAO 模型转换为领域对象
 func toDomain(p *model.Product) catalog.Product {
 	// Price decimal 元 → 分
@@ -40,13 +46,21 @@ func toDomain(p *model.Product) catalog.Product {
 		ID:          p.ID,
 		Name:        p.Name,
 		Price:       priceCents,
-		DurationMin: 0,          // 现有模型暂无时长字段，占位 0
-		Status:      status,     // on/off 派生自 is_active
-		Type:        "product",  // 默认类型
+		DurationMin: p.DurationMin,
+		Status:      status, // on/off 派生自 is_active
+		Type:        productType(p.Type),
 		Category:    p.Category, // 使用现有的 Category 字段
 	}
 }
 
+// productType 产品类型，历史数据为空时视为实物产品
+func productType(t string) string {
+	if t == "" {
+		return string(constants.ProductTypeProduct)
+	}
+	return t
+}
+
 // toProductResponse 将 model.Product 转换为 catalog.ProductResponse
 func (s *ServiceImpl) toProductResponse(p *model.Product) *catalog.ProductResponse {
 	if p == nil {
@@ -59,6 +73,7 @@ func (s *ServiceImpl) toProductResponse(p *model.Product) *catalog.ProductRespon
 		Price:       int64(math.Round(p.Price * 100)), // 元转分
 		SKU:         p.Category,
 		Stock:       p.StockQuantity,
+		DurationMin: p.DurationMin,
 		CreatedAt:   p.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:   p.UpdatedAt.Format("2006-01-02 15:04:05"),
 	}
@@ -75,7 +90,8 @@ func (s *ServiceImpl) Get(ctx context.Context, id int64) (catalog.Product, error
 
 // EnsureSellable 校验产品可售
 func (s *ServiceImpl) EnsureSellable(ctx context.Context, id int64) error {
-	p, err := s.q.Product.WithContext(ctx).Where(s.q.Product.ID.Eq(id)).First()
+	q := s.txQuery(ctx)
+	p, err := q.Product.WithContext(ctx).Where(q.Product.ID.Eq(id)).First()
 	if err != nil {
 		return ErrProductNotFound
 	}
@@ -87,7 +103,8 @@ func (s *ServiceImpl) EnsureSellable(ctx context.Context, id int64) error {
 
 // BatchGet 批量获取产品信息
 func (s *ServiceImpl) BatchGet(ctx context.Context, ids []int64) ([]catalog.Product, error) {
-	products, err := s.q.Product.WithContext(ctx).Where(s.q.Product.ID.In(ids...)).Find()
+	q := s.txQuery(ctx)
+	products, err := q.Product.WithContext(ctx).Where(q.Product.ID.In(ids...)).Find()
 	if err != nil {
 		return nil, err
 	}
@@ -115,6 +132,7 @@ func (s *ServiceImpl) CreateProduct(ctx context.Context, req *catalog.CreateProd
 		Price:         float64(req.Price) / 100, // 分转元
 		Category:      req.SKU,
 		StockQuantity: req.Stock,
+		DurationMin:   req.DurationMin,
 		IsActive:      true, // 新产品默认激活
 	}
 
@@ -227,6 +245,9 @@ func (s *ServiceImpl) UpdateProduct(ctx context.Context, idStr string, req *cata
 	if req.Stock >= 0 {
 		updates["stock_quantity"] = req.Stock
 	}
+	if req.DurationMin > 0 {
+		updates["duration_min"] = req.DurationMin
+	}
 
 	if len(updates) > 0 {
 		if _, err := s.q.Product.WithContext(ctx).Where(s.q.Product.ID.Eq(id)).Updates(updates); err != nil {
@@ -0,0 +1,104 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/catalog"
+
+	"gorm.io/gorm"
+)
+
+// ReserveStock 扣减库存
+// 使用 stock_quantity >= qty 的条件更新，影响行数为 0 即视为库存不足
+func (s *ServiceImpl) ReserveStock(ctx context.Context, items []catalog.StockItem) error {
+	txQuery := s.txQuery(ctx)
+
+	products, qtyMap, err := s.stockProducts(ctx, txQuery, items)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		qty := qtyMap[p.ID]
+		if productType(p.Type) != string(constants.ProductTypeProduct) || qty <= 0 {
+			continue
+		}
+
+		info, err := txQuery.Product.WithContext(ctx).
+			Where(txQuery.Product.ID.Eq(p.ID), txQuery.Product.StockQuantity.Gte(qty)).
+			UpdateSimple(txQuery.Product.StockQuantity.Sub(qty))
+		if err != nil {
+			return fmt.Errorf("扣减库存失败: %w", err)
+		}
+		if info.RowsAffected == 0 {
+			return common.NewBusinessErrorWithDetails(common.ErrCodeInsufficientStock,
+				fmt.Sprintf("产品 %s 库存不足", p.Name),
+				fmt.Sprintf("请求数量：%d", qty))
+		}
+	}
+
+	return nil
+}
+
+// ReleaseStock 回补库存
+func (s *ServiceImpl) ReleaseStock(ctx context.Context, items []catalog.StockItem) error {
+	txQuery := s.txQuery(ctx)
+
+	products, qtyMap, err := s.stockProducts(ctx, txQuery, items)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range products {
+		qty := qtyMap[p.ID]
+		if productType(p.Type) != string(constants.ProductTypeProduct) || qty <= 0 {
+			continue
+		}
+
+		if _, err := txQuery.Product.WithContext(ctx).
+			Where(txQuery.Product.ID.Eq(p.ID)).
+			UpdateSimple(txQuery.Product.StockQuantity.Add(qty)); err != nil {
+			return fmt.Errorf("回补库存失败: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// txQuery 优先使用 ctx 中的事务连接
+func (s *ServiceImpl) txQuery(ctx context.Context) *query.Query {
+	if s.tx != nil {
+		return query.Use(s.tx.GetDB(ctx))
+	}
+	return s.q
+}
+
+// stockProducts 合并同一产品的数量并加载产品（含已删除产品，保证回补不丢失）
+func (s *ServiceImpl) stockProducts(ctx context.Context, txQuery *query.Query, items []catalog.StockItem) ([]*model.Product, map[int64]int32, error) {
+	qtyMap := make(map[int64]int32, len(items))
+	ids := make([]int64, 0, len(items))
+	for _, it := range items {
+		if _, ok := qtyMap[it.ProductID]; !ok {
+			ids = append(ids, it.ProductID)
+		}
+		qtyMap[it.ProductID] += it.Qty
+	}
+	if len(ids) == 0 {
+		return nil, qtyMap, nil
+	}
+
+	products, err := txQuery.Product.WithContext(ctx).Unscoped().Where(txQuery.Product.ID.In(ids...)).Find()
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, nil, fmt.Errorf("查询产品失败: %w", err)
+	}
+	if len(products) != len(ids) {
+		return nil, nil, common.NewBusinessError(common.ErrCodeProductNotFound, "部分产品不存在")
+	}
+
+	return products, qtyMap, nil
+}
@@ -12,10 +12,16 @@ type Product struct {
 	Price       int64  `json:"price"`        // 价格（分为单位，避免浮点精度问题）
 	DurationMin int32  `json:"duration_min"` // 服务时长（分钟），用于预约类产品
 	Status      string `json:"status"`       // 状态：active/inactive/deleted
-	Type        string `json:"type"`         // 类型：product/service
+	Type        string `json:"type"`         // 类型：product/service/package
 	Category    string `json:"category"`     // 分类
 }
 
+// StockItem 库存变更项
+type StockItem struct {
+	ProductID int64 `json:"product_id"` // 产品ID
+	Qty       int32 `json:"qty"`        // 数量
+}
+
 // CreateProductRequest 创建产品请求
 type CreateProductRequest struct {
 	Name        string `json:"name" binding:"required"`
@@ -23,6 +29,7 @@ type CreateProductRequest struct {
 	Price       int64  `json:"price" binding:"required,min=1"`
 	SKU         string `json:"sku" binding:"required"`
 	Stock       int32  `json:"stock" binding:"min=0"`
+	DurationMin int32  `json:"duration_min" binding:"min=0"` // 服务时长（分钟），service 类型产品可预约
 }
 
 // UpdateProductRequest 更新产品请求
@@ -31,6 +38,7 @@ type UpdateProductRequest struct {
 	Description string `json:"description"`
 	Price       int64  `json:"price" binding:"min=1"`
 	Stock       int32  `json:"stock" binding:"min=0"`
+	DurationMin int32  `json:"duration_min" binding:"min=0"` // 服务时长（分钟），0 表示不修改
 }
 
 // ProductListRequest 产品列表请求
@@ -51,6 +59,7 @@ type ProductResponse struct {
 	Price       int64  `json:"price"`
 	SKU         string `json:"sku"`
 	Stock       int32  `json:"stock"`
+	DurationMin int32  `json:"duration_min"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
@@ -78,6 +87,15 @@ type Service interface {
 	// 用于订单下单时一次性获取多个产品信息，提高性能
 	BatchGet(ctx context.Context, ids []int64) ([]Product, error)
 
+	// ReserveStock 扣减库存
+	// 在调用方事务中执行（ctx 携带事务），通过条件更新保证并发下不超卖
+	// service 类型产品不校验库存；库存不足返回 INSUFFICIENT_STOCK 业务错误
+	ReserveStock(ctx context.Context, items []StockItem) error
+
+	// ReleaseStock 回补库存
+	// 用于订单取消、退款时归还已扣减的库存，service 类型产品跳过
+	ReleaseStock(ctx context.Context, items []StockItem) error
+
 	// Controller 接口 - 供 HTTP API 调用
 	CreateProduct(ctx context.Context, req *CreateProductRequest) (*ProductResponse, error)
 	GetProductByID(ctx context.Context, idStr string) (*ProductResponse, error)
@@ -0,0 +1,314 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/commission"
+
+	"gorm.io/gorm"
+)
+
+// AccrueOrder 为已支付订单的负责员工计提提成
+// 提成基数为订单项实收金额按订单级优惠（如优惠券）等比分摊后的金额；
+// 分档按员工支付当月的净业绩（含本单）确定，本单所有订单项使用同一档
+func (s *CommissionServiceImpl) AccrueOrder(ctx context.Context, req commission.AccrueRequest) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		q := query.Use(txDB)
+
+		// 1. 订单未分配员工不计提
+		order, err := q.Order.WithContext(ctx).Unscoped().Where(q.Order.ID.Eq(req.OrderID)).First()
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+			}
+			return fmt.Errorf("查询订单失败: %w", err)
+		}
+		if order.AssignedTo == 0 {
+			return nil
+		}
+
+		// 2. 幂等性检查：计提在同一事务内完成，已有任一订单项的计提即视为已处理
+		var accrued int64
+		if err := txDB.WithContext(ctx).Model(&CommissionEntryRecord{}).
+			Where("order_id = ? AND type = ?", order.ID, string(constants.CommissionEntryTypeAccrual)).
+			Count(&accrued).Error; err != nil {
+			return fmt.Errorf("检查幂等性失败: %w", err)
+		}
+		if accrued > 0 {
+			return nil
+		}
+
+		items, err := q.OrderItem.WithContext(ctx).Where(q.OrderItem.OrderID.Eq(order.ID)).Order(q.OrderItem.ID).Find()
+		if err != nil {
+			return fmt.Errorf("查询订单项失败: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		// 3. 计算各订单项提成基数
+		totalCents := yuanToCents(order.TotalAmount)
+		finalCents := yuanToCents(order.FinalAmount)
+		bases := make([]int64, len(items))
+		var orderVolume int64
+		for i, item := range items {
+			if totalCents > 0 {
+				bases[i] = int64(math.Round(float64(yuanToCents(item.FinalPrice)) * float64(finalCents) / float64(totalCents)))
+			}
+			orderVolume += bases[i]
+		}
+
+		// 4. 匹配规则并确定分档
+		productIDs := make([]int64, len(items))
+		for i, item := range items {
+			productIDs[i] = item.ProductID
+		}
+		products, err := s.catalogSvc.BatchGet(ctx, productIDs)
+		if err != nil {
+			return fmt.Errorf("获取产品信息失败: %w", err)
+		}
+		categories := make(map[int64]string, len(products))
+		for _, p := range products {
+			categories[p.ID] = p.Category
+		}
+		rules, err := s.loadActiveRules(ctx, txDB)
+		if err != nil {
+			return err
+		}
+
+		monthStart, monthEnd := monthRange(req.PaidAt)
+		volume, err := s.netVolume(ctx, txDB, order.AssignedTo, monthStart, monthEnd)
+		if err != nil {
+			return err
+		}
+		volume += orderVolume
+
+		// 5. 写入计提明细
+		now := time.Now().Unix()
+		for i, item := range items {
+			rule := rules.match(item.ProductID, categories[item.ProductID])
+			if rule == nil {
+				continue
+			}
+			value := rule.valueFor(volume)
+			var amount int64
+			if rule.Type == string(constants.CommissionRuleTypeFixed) {
+				amount = value * int64(item.Quantity)
+			} else {
+				amount = int64(math.Round(float64(bases[i]) * float64(value) / maxPercentValue))
+			}
+			if amount <= 0 {
+				continue
+			}
+			record := &CommissionEntryRecord{
+				StaffID:        order.AssignedTo,
+				Type:           string(constants.CommissionEntryTypeAccrual),
+				OrderID:        order.ID,
+				OrderItemID:    item.ID,
+				ProductID:      item.ProductID,
+				RuleID:         rule.ID,
+				RuleType:       rule.Type,
+				RuleValue:      value,
+				Quantity:       item.Quantity,
+				BaseAmount:     bases[i],
+				Amount:         amount,
+				IdempotencyKey: fmt.Sprintf("commission_accrue_%d", item.ID),
+				OccurredAt:     req.PaidAt,
+				CreatedAt:      now,
+			}
+			if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+				return fmt.Errorf("创建提成明细失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// ReverseRefund 按退款数量比例冲回已计提的提成
+// 订单项全部退完时冲回剩余的全部提成，避免分次退款的取整误差
+func (s *CommissionServiceImpl) ReverseRefund(ctx context.Context, req commission.ReverseRequest) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		now := time.Now().Unix()
+
+		for _, line := range req.Lines {
+			if line.Quantity <= 0 {
+				continue
+			}
+
+			// 1. 幂等性检查
+			idem := fmt.Sprintf("commission_reverse_%d_%d", req.RefundID, line.OrderItemID)
+			var done int64
+			if err := txDB.WithContext(ctx).Model(&CommissionEntryRecord{}).
+				Where("idempotency_key = ?", idem).Count(&done).Error; err != nil {
+				return fmt.Errorf("检查幂等性失败: %w", err)
+			}
+			if done > 0 {
+				continue
+			}
+
+			// 2. 未计提的订单项无需冲回
+			var entries []CommissionEntryRecord
+			if err := txDB.WithContext(ctx).Where("order_item_id = ?", line.OrderItemID).
+				Order("id ASC").Find(&entries).Error; err != nil {
+				return fmt.Errorf("查询提成明细失败: %w", err)
+			}
+			var accrual *CommissionEntryRecord
+			var reversedQty int32
+			var reversedAmount, reversedBase int64
+			for i := range entries {
+				e := &entries[i]
+				if e.Type == string(constants.CommissionEntryTypeAccrual) {
+					accrual = e
+				} else {
+					reversedQty += e.Quantity
+					reversedAmount += e.Amount
+					reversedBase += e.BaseAmount
+				}
+			}
+			if accrual == nil {
+				continue
+			}
+			remainingQty := accrual.Quantity - reversedQty
+			qty := line.Quantity
+			if qty > remainingQty {
+				qty = remainingQty
+			}
+			if qty <= 0 {
+				continue
+			}
+
+			// 3. 按数量比例计算冲回金额
+			amount := accrual.Amount - reversedAmount
+			base := accrual.BaseAmount - reversedBase
+			if qty < remainingQty {
+				amount = int64(math.Round(float64(accrual.Amount) * float64(qty) / float64(accrual.Quantity)))
+				base = int64(math.Round(float64(accrual.BaseAmount) * float64(qty) / float64(accrual.Quantity)))
+			}
+
+			record := &CommissionEntryRecord{
+				StaffID:        accrual.StaffID,
+				Type:           string(constants.CommissionEntryTypeReversal),
+				OrderID:        accrual.OrderID,
+				OrderItemID:    accrual.OrderItemID,
+				ProductID:      accrual.ProductID,
+				RefundID:       req.RefundID,
+				RuleID:         accrual.RuleID,
+				RuleType:       accrual.RuleType,
+				RuleValue:      accrual.RuleValue,
+				Quantity:       qty,
+				BaseAmount:     base,
+				Amount:         amount,
+				IdempotencyKey: idem,
+				OccurredAt:     req.RefundedAt,
+				CreatedAt:      now,
+			}
+			if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+				return fmt.Errorf("创建提成冲回明细失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// activeRule 启用中的提成规则及其分档
+type activeRule struct {
+	CommissionRuleRecord
+	tiers []commission.RuleTier
+}
+
+// valueFor 返回当月业绩对应的提成值：门槛最高且已达到的分档，未达到任何分档时使用基础值
+func (r *activeRule) valueFor(volume int64) int64 {
+	value := r.Value
+	for _, t := range r.tiers {
+		if volume < t.MinVolume {
+			break
+		}
+		value = t.Value
+	}
+	return value
+}
+
+// ruleSet 按适用范围索引的启用规则
+type ruleSet struct {
+	byProduct  map[int64]*activeRule
+	byCategory map[string]*activeRule
+	fallback   *activeRule
+}
+
+// match 按 产品 > 分类 > 默认 的优先级匹配规则，无匹配时返回 nil
+func (rs *ruleSet) match(productID int64, category string) *activeRule {
+	if r, ok := rs.byProduct[productID]; ok {
+		return r
+	}
+	if r, ok := rs.byCategory[category]; ok && category != "" {
+		return r
+	}
+	return rs.fallback
+}
+
+// loadActiveRules 加载全部启用中的提成规则
+func (s *CommissionServiceImpl) loadActiveRules(ctx context.Context, db *gorm.DB) (*ruleSet, error) {
+	var records []CommissionRuleRecord
+	if err := db.WithContext(ctx).Where("is_active = ?", true).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询提成规则失败: %w", err)
+	}
+	ids := make([]int64, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	tiers, err := s.loadTiers(ctx, db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := &ruleSet{
+		byProduct:  make(map[int64]*activeRule),
+		byCategory: make(map[string]*activeRule),
+	}
+	for _, r := range records {
+		rule := &activeRule{CommissionRuleRecord: r, tiers: tiers[r.ID]}
+		switch constants.CommissionRuleScope(r.Scope) {
+		case constants.CommissionRuleScopeProduct:
+			rs.byProduct[r.ProductID] = rule
+		case constants.CommissionRuleScopeCategory:
+			rs.byCategory[r.Category] = rule
+		case constants.CommissionRuleScopeDefault:
+			rs.fallback = rule
+		}
+	}
+	return rs, nil
+}
+
+// netVolume 统计员工在 [startAt, endAt) 内的净业绩：计提基数 - 冲回基数
+func (s *CommissionServiceImpl) netVolume(ctx context.Context, db *gorm.DB, staffID, startAt, endAt int64) (int64, error) {
+	var volume struct{ Total int64 }
+	if err := db.WithContext(ctx).Model(&CommissionEntryRecord{}).
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN -base_amount ELSE base_amount END), 0) AS total",
+			string(constants.CommissionEntryTypeReversal)).
+		Where("staff_id = ? AND occurred_at >= ? AND occurred_at < ?", staffID, startAt, endAt).
+		Scan(&volume).Error; err != nil {
+		return 0, fmt.Errorf("统计员工业绩失败: %w", err)
+	}
+	return volume.Total, nil
+}
+
+// monthRange 返回时间戳所在自然月的 [开始, 结束) 时间戳
+func monthRange(ts int64) (int64, int64) {
+	t := time.Unix(ts, 0)
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start.Unix(), start.AddDate(0, 1, 0).Unix()
+}
+
+// yuanToCents 订单表金额（元）转换为分
+func yuanToCents(yuan float64) int64 {
+	return int64(math.Round(yuan * 100))
+}
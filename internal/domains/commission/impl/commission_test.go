@@ -0,0 +1,307 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/query"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/commission"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newCommissionTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Exec(`
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_no TEXT UNIQUE NOT NULL,
+			customer_id INTEGER NOT NULL,
+			contact_id INTEGER DEFAULT 0,
+			order_date DATETIME,
+			status TEXT DEFAULT 'pending',
+			payment_status TEXT DEFAULT 'unpaid',
+			total_amount REAL DEFAULT 0,
+			discount_amount REAL DEFAULT 0,
+			final_amount REAL DEFAULT 0,
+			payment_method TEXT,
+			remark TEXT,
+			assigned_to INTEGER DEFAULT 0,
+			created_by INTEGER DEFAULT 0,
+			deleted_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE order_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			product_name TEXT NOT NULL,
+			product_name_snapshot TEXT,
+			quantity INTEGER NOT NULL,
+			unit_price REAL NOT NULL,
+			unit_price_snapshot INTEGER,
+			duration_min_snapshot INTEGER DEFAULT 0,
+			discount_amount REAL DEFAULT 0,
+			final_price REAL NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT,
+			type TEXT DEFAULT 'product',
+			category TEXT,
+			price DECIMAL(10,2) NOT NULL DEFAULT 0,
+			cost DECIMAL(10,2) DEFAULT 0,
+			stock_quantity INTEGER DEFAULT 0,
+			min_stock_level INTEGER DEFAULT 0,
+			unit TEXT DEFAULT '个',
+			duration_min INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE sys_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			processed_at INTEGER NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_retry_at INTEGER NOT NULL DEFAULT 0
+		)
+	`).Error)
+	require.NoError(t, db.AutoMigrate(&CommissionRuleRecord{}, &CommissionRuleTierRecord{}, &CommissionEntryRecord{}))
+	return db
+}
+
+// TestCommissionAccrual 员工提成单元测试
+// 覆盖规则优先级、订单优惠分摊、月度业绩分档、退款按比例冲回以及 outbox 事件驱动
+func TestCommissionAccrual(t *testing.T) {
+	db := newCommissionTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.Exec(`INSERT INTO products (name, type, category, price, is_active) VALUES
+		('精剪', 'service', '美发', 100.00, 1),
+		('烫发', 'service', '美发', 300.00, 1),
+		('洗发水', 'product', '洗护', 50.00, 1)`).Error)
+	const haircutID, permID, shampooID, stylist = int64(1), int64(2), int64(3), int64(7)
+
+	tx := common.NewTx(db)
+	svc := NewCommissionServiceImpl(db, tx, catalogImpl.NewWithTx(query.Use(db), tx))
+	outbox := common.NewOutboxServiceImpl(db, tx)
+	RegisterEventHandlers(outbox, svc)
+
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+	// createOrder 创建已支付订单，items 为 {产品ID, 数量, 实收金额(元)}
+	createOrder := func(t *testing.T, orderNo string, assignedTo int64, discount float64, items [][3]float64) int64 {
+		var total float64
+		for _, it := range items {
+			total += it[2]
+		}
+		res := db.Exec(`INSERT INTO orders (order_no, customer_id, status, payment_status, total_amount, discount_amount, final_amount, assigned_to)
+			VALUES (?, 1, 'paid', 'paid', ?, ?, ?, ?)`, orderNo, total, discount, total-discount, assignedTo)
+		require.NoError(t, res.Error)
+		var orderID int64
+		require.NoError(t, db.Raw(`SELECT id FROM orders WHERE order_no = ?`, orderNo).Scan(&orderID).Error)
+		for _, it := range items {
+			require.NoError(t, db.Exec(`INSERT INTO order_items (order_id, product_id, product_name, quantity, unit_price, final_price)
+				VALUES (?, ?, 'item', ?, ?, ?)`, orderID, int64(it[0]), int32(it[1]), it[2]/it[1], it[2]).Error)
+		}
+		return orderID
+	}
+	itemID := func(t *testing.T, orderID, productID int64) int64 {
+		var id int64
+		require.NoError(t, db.Raw(`SELECT id FROM order_items WHERE order_id = ? AND product_id = ?`, orderID, productID).Scan(&id).Error)
+		return id
+	}
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	paidAt := monthStart.Add(time.Hour).Unix()
+
+	t.Run("规则校验与范围冲突", func(t *testing.T) {
+		_, err := svc.CreateRule(ctx, commission.SaveRuleRequest{Name: "超额", Scope: "default", Type: "percent", Value: 10001, IsActive: true})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		_, err = svc.CreateRule(ctx, commission.SaveRuleRequest{Name: "缺产品", Scope: "product", Type: "fixed", Value: 100, IsActive: true})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		_, err = svc.CreateRule(ctx, commission.SaveRuleRequest{Name: "默认", Scope: "default", Type: "percent", Value: 500, IsActive: true})
+		require.NoError(t, err)
+		_, err = svc.CreateRule(ctx, commission.SaveRuleRequest{Name: "默认2", Scope: "default", Type: "percent", Value: 800, IsActive: true})
+		assertCode(t, err, common.ErrCodeCommissionRuleConflict)
+
+		// 美发分类 10%，当月业绩满 500 元后 15%；烫发按件固定 30 元
+		rule, err := svc.CreateRule(ctx, commission.SaveRuleRequest{
+			Name: "美发", Scope: "category", Category: "美发", Type: "percent", Value: 1000, IsActive: true,
+			Tiers: []commission.RuleTier{{MinVolume: 50000, Value: 1500}},
+		})
+		require.NoError(t, err)
+		assert.Len(t, rule.Tiers, 1)
+		_, err = svc.CreateRule(ctx, commission.SaveRuleRequest{Name: "烫发", Scope: "product", ProductID: permID, Type: "fixed", Value: 3000, IsActive: true})
+		require.NoError(t, err)
+
+		rules, err := svc.ListRules(ctx)
+		require.NoError(t, err)
+		assert.Len(t, rules, 3)
+	})
+
+	t.Run("按优先级匹配规则并分摊订单优惠", func(t *testing.T) {
+		// 精剪 100 + 洗发水 50，订单优惠 15 元，按 90% 分摊：精剪基数 90，洗发水基数 45
+		firstOrder := createOrder(t, "CM-1", stylist, 15, [][3]float64{{float64(haircutID), 1, 100}, {float64(shampooID), 1, 50}})
+		require.NoError(t, svc.AccrueOrder(ctx, commission.AccrueRequest{OrderID: firstOrder, PaidAt: paidAt}))
+		// 重复投递不重复计提
+		require.NoError(t, svc.AccrueOrder(ctx, commission.AccrueRequest{OrderID: firstOrder, PaidAt: paidAt}))
+
+		statement, err := svc.GetStatement(ctx, stylist, monthStart.Unix(), monthStart.AddDate(0, 1, 0).Unix())
+		require.NoError(t, err)
+		require.Len(t, statement.Entries, 2)
+		assert.Equal(t, int64(9000), statement.Entries[0].BaseAmount)
+		assert.Equal(t, int64(900), statement.Entries[0].Amount) // 美发分类 10%
+		assert.Equal(t, int64(4500), statement.Entries[1].BaseAmount)
+		assert.Equal(t, int64(225), statement.Entries[1].Amount) // 默认 5%
+		assert.Equal(t, int64(13500), statement.Volume)
+		assert.Equal(t, int64(1125), statement.Net)
+	})
+
+	t.Run("未分配员工不计提", func(t *testing.T) {
+		orderID := createOrder(t, "CM-2", 0, 0, [][3]float64{{float64(haircutID), 1, 100}})
+		require.NoError(t, svc.AccrueOrder(ctx, commission.AccrueRequest{OrderID: orderID, PaidAt: paidAt}))
+		var count int64
+		require.NoError(t, db.Model(&CommissionEntryRecord{}).Where("order_id = ?", orderID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	var tierOrder int64
+	t.Run("当月业绩达标后使用分档比例，固定提成按件计", func(t *testing.T) {
+		// 当月已有 135 元，本单 精剪 400 + 烫发 2 件 600，合计 1135 元达到 500 元门槛
+		tierOrder = createOrder(t, "CM-3", stylist, 0, [][3]float64{{float64(haircutID), 4, 400}, {float64(permID), 2, 600}})
+		require.NoError(t, svc.AccrueOrder(ctx, commission.AccrueRequest{OrderID: tierOrder, PaidAt: paidAt + 60}))
+
+		var entries []CommissionEntryRecord
+		require.NoError(t, db.Where("order_id = ?", tierOrder).Order("id ASC").Find(&entries).Error)
+		require.Len(t, entries, 2)
+		assert.Equal(t, int64(1500), entries[0].RuleValue)
+		assert.Equal(t, int64(6000), entries[0].Amount) // 400 元 × 15%
+		assert.Equal(t, int64(6000), entries[1].Amount) // 30 元 × 2 件
+	})
+
+	t.Run("退款按数量比例冲回并计入退款周期", func(t *testing.T) {
+		haircutItem := itemID(t, tierOrder, haircutID)
+		refundedAt := monthStart.AddDate(0, 1, 0).Add(time.Hour).Unix()
+
+		require.NoError(t, svc.ReverseRefund(ctx, commission.ReverseRequest{
+			OrderID: tierOrder, RefundID: 1, RefundedAt: refundedAt,
+			Lines: []commission.ReverseLine{{OrderItemID: haircutItem, Quantity: 3}},
+		}))
+		// 重复投递不重复冲回
+		require.NoError(t, svc.ReverseRefund(ctx, commission.ReverseRequest{
+			OrderID: tierOrder, RefundID: 1, RefundedAt: refundedAt,
+			Lines: []commission.ReverseLine{{OrderItemID: haircutItem, Quantity: 3}},
+		}))
+		// 超出剩余数量时只冲回剩余部分
+		require.NoError(t, svc.ReverseRefund(ctx, commission.ReverseRequest{
+			OrderID: tierOrder, RefundID: 2, RefundedAt: refundedAt,
+			Lines: []commission.ReverseLine{{OrderItemID: haircutItem, Quantity: 5}},
+		}))
+
+		next, err := svc.GetStatement(ctx, stylist, monthStart.AddDate(0, 1, 0).Unix(), monthStart.AddDate(0, 2, 0).Unix())
+		require.NoError(t, err)
+		require.Len(t, next.Entries, 2)
+		assert.Equal(t, int64(4500), next.Entries[0].Amount)
+		assert.Equal(t, int32(1), next.Entries[1].Quantity)
+		assert.Equal(t, int64(1500), next.Entries[1].Amount)
+		assert.Equal(t, int64(6000), next.Reversed)
+		assert.Equal(t, int64(-6000), next.Net)
+
+		current, err := svc.GetStatement(ctx, stylist, monthStart.Unix(), monthStart.AddDate(0, 1, 0).Unix())
+		require.NoError(t, err)
+		assert.Equal(t, int64(13125), current.Accrued)
+		assert.Zero(t, current.Reversed)
+	})
+
+	t.Run("outbox 事件驱动计提与冲回", func(t *testing.T) {
+		orderID := createOrder(t, "CM-4", stylist, 0, [][3]float64{{float64(haircutID), 1, 100}})
+		haircutItem := itemID(t, orderID, haircutID)
+		require.NoError(t, tx.WithTx(ctx, func(ctx context.Context) error {
+			if err := outbox.PublishEvent(ctx, common.EventTypeOrderPaid, common.OrderPaidEvent{OrderID: orderID, PaidAt: paidAt + 120}); err != nil {
+				return err
+			}
+			return outbox.PublishEvent(ctx, common.EventTypeOrderRefunded, common.OrderRefundedEvent{
+				OrderID: orderID, RefundID: 3, RefundedAt: paidAt + 180,
+				Lines: []common.OrderRefundLine{{OrderItemID: haircutItem, Quantity: 1}},
+			})
+		}))
+		require.NoError(t, outbox.ProcessPendingEvents(ctx, 10))
+
+		var entries []CommissionEntryRecord
+		require.NoError(t, db.Where("order_id = ?", orderID).Order("id ASC").Find(&entries).Error)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "accrual", entries[0].Type)
+		assert.Equal(t, "reversal", entries[1].Type)
+		assert.Equal(t, entries[0].Amount, entries[1].Amount)
+
+		var pending int64
+		require.NoError(t, db.Raw(`SELECT COUNT(*) FROM sys_outbox WHERE processed_at IS NULL`).Scan(&pending).Error)
+		assert.Zero(t, pending)
+	})
+
+	t.Run("持续失败的事件退避重试且不阻塞后续事件", func(t *testing.T) {
+		orderID := createOrder(t, "CM-5", stylist, 0, [][3]float64{{float64(haircutID), 1, 100}})
+		require.NoError(t, tx.WithTx(ctx, func(ctx context.Context) error {
+			// 订单不存在，计提始终返回 ORDER_NOT_FOUND
+			if err := outbox.PublishEvent(ctx, common.EventTypeOrderPaid, common.OrderPaidEvent{OrderID: 999999, PaidAt: paidAt}); err != nil {
+				return err
+			}
+			return outbox.PublishEvent(ctx, common.EventTypeOrderPaid, common.OrderPaidEvent{OrderID: orderID, PaidAt: paidAt})
+		}))
+
+		assert.Error(t, outbox.ProcessPendingEvents(ctx, 1))
+		var failed common.OutboxEvent
+		require.NoError(t, db.Raw(`SELECT * FROM sys_outbox WHERE processed_at IS NULL`).Scan(&failed).Error)
+		assert.Equal(t, 1, failed.Attempts)
+		assert.Contains(t, failed.LastError, common.ErrCodeOrderNotFound)
+		assert.Greater(t, failed.NextRetryAt, time.Now().Unix())
+
+		// 失败事件在退避期内不再被读取，后续事件得以投递
+		require.NoError(t, outbox.ProcessPendingEvents(ctx, 1))
+		var accrued int64
+		require.NoError(t, db.Model(&CommissionEntryRecord{}).Where("order_id = ?", orderID).Count(&accrued).Error)
+		assert.Equal(t, int64(1), accrued)
+
+		// 失败次数达到上限后成为死信，不再自动投递
+		require.NoError(t, db.Exec(`UPDATE sys_outbox SET attempts = ?, next_retry_at = 0 WHERE id = ?`, common.OutboxMaxAttempts, failed.ID).Error)
+		require.NoError(t, outbox.ProcessPendingEvents(ctx, 10))
+		require.NoError(t, db.Exec(`UPDATE sys_outbox SET attempts = ? WHERE id = ?`, common.OutboxMaxAttempts-1, failed.ID).Error)
+		assert.Error(t, outbox.ProcessPendingEvents(ctx, 10), "未达上限时仍会重试")
+	})
+
+	t.Run("删除规则", func(t *testing.T) {
+		rules, err := svc.ListRules(ctx)
+		require.NoError(t, err)
+		require.NoError(t, svc.DeleteRule(ctx, rules[0].ID))
+		_, err = svc.GetRule(ctx, rules[0].ID)
+		assertCode(t, err, common.ErrCodeCommissionRuleNotFound)
+	})
+}
@@ -0,0 +1,39 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/commission"
+)
+
+// RegisterEventHandlers 订阅订单事件：order.paid 计提提成，order.refunded 冲回提成
+// 计提与冲回均带幂等键，事件重复投递不会重复入账
+func RegisterEventHandlers(outbox *common.OutboxServiceImpl, svc commission.Service) {
+	outbox.Subscribe(common.EventTypeOrderPaid, func(ctx context.Context, event *common.OutboxEvent) error {
+		var paid common.OrderPaidEvent
+		if err := json.Unmarshal(event.Payload, &paid); err != nil {
+			return fmt.Errorf("解析订单支付事件失败: %w", err)
+		}
+		return svc.AccrueOrder(ctx, commission.AccrueRequest{OrderID: paid.OrderID, PaidAt: paid.PaidAt})
+	})
+
+	outbox.Subscribe(common.EventTypeOrderRefunded, func(ctx context.Context, event *common.OutboxEvent) error {
+		var refunded common.OrderRefundedEvent
+		if err := json.Unmarshal(event.Payload, &refunded); err != nil {
+			return fmt.Errorf("解析订单退款事件失败: %w", err)
+		}
+		lines := make([]commission.ReverseLine, len(refunded.Lines))
+		for i, l := range refunded.Lines {
+			lines[i] = commission.ReverseLine{OrderItemID: l.OrderItemID, Quantity: l.Quantity}
+		}
+		return svc.ReverseRefund(ctx, commission.ReverseRequest{
+			OrderID:    refunded.OrderID,
+			RefundID:   refunded.RefundID,
+			Lines:      lines,
+			RefundedAt: refunded.RefundedAt,
+		})
+	})
+}
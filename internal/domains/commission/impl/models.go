@@ -0,0 +1,49 @@
+package impl
+
+// CommissionRuleRecord 映射 commission_rules（提成规则）
+type CommissionRuleRecord struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Name      string `gorm:"column:name;size:100;not null"`
+	Scope     string `gorm:"column:scope;size:20;uniqueIndex:uk_commission_rule_scope,priority:1;not null"`
+	ProductID int64  `gorm:"column:product_id;uniqueIndex:uk_commission_rule_scope,priority:2;not null;default:0"`
+	Category  string `gorm:"column:category;size:50;uniqueIndex:uk_commission_rule_scope,priority:3;not null;default:''"`
+	Type      string `gorm:"column:type;size:20;not null"`
+	Value     int64  `gorm:"column:value;not null"`
+	IsActive  bool   `gorm:"column:is_active;not null;default:true"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+	UpdatedAt int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CommissionRuleRecord) TableName() string { return "commission_rules" }
+
+// CommissionRuleTierRecord 映射 commission_rule_tiers（业绩分档）
+type CommissionRuleTierRecord struct {
+	ID        int64 `gorm:"column:id;primaryKey;autoIncrement"`
+	RuleID    int64 `gorm:"column:rule_id;uniqueIndex:uk_commission_rule_tier,priority:1;not null"`
+	MinVolume int64 `gorm:"column:min_volume;uniqueIndex:uk_commission_rule_tier,priority:2;not null"` // 分
+	Value     int64 `gorm:"column:value;not null"`
+}
+
+func (CommissionRuleTierRecord) TableName() string { return "commission_rule_tiers" }
+
+// CommissionEntryRecord 映射 commission_entries（提成明细，只追加）
+type CommissionEntryRecord struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	StaffID        int64  `gorm:"column:staff_id;index:idx_commission_entry_staff,priority:1;not null"`
+	Type           string `gorm:"column:type;size:20;not null"`
+	OrderID        int64  `gorm:"column:order_id;not null"`
+	OrderItemID    int64  `gorm:"column:order_item_id;index:idx_commission_entry_order_item;not null"`
+	ProductID      int64  `gorm:"column:product_id;not null"`
+	RefundID       int64  `gorm:"column:refund_id;not null;default:0"`
+	RuleID         int64  `gorm:"column:rule_id;not null"`
+	RuleType       string `gorm:"column:rule_type;size:20;not null"`
+	RuleValue      int64  `gorm:"column:rule_value;not null"`
+	Quantity       int32  `gorm:"column:quantity;not null"`
+	BaseAmount     int64  `gorm:"column:base_amount;not null"` // 分
+	Amount         int64  `gorm:"column:amount;not null"`      // 分，正数
+	IdempotencyKey string `gorm:"column:idempotency_key;size:64;uniqueIndex:uk_commission_entry_idem;not null"`
+	OccurredAt     int64  `gorm:"column:occurred_at;index:idx_commission_entry_staff,priority:2;not null"`
+	CreatedAt      int64  `gorm:"column:created_at;not null"`
+}
+
+func (CommissionEntryRecord) TableName() string { return "commission_entries" }
@@ -0,0 +1,17 @@
+package impl
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/query"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/commission"
+
+	"gorm.io/gorm"
+)
+
+// NewCommissionService 创建员工提成服务实例
+func NewCommissionService(db *gorm.DB) commission.Service {
+	tx := common.NewTx(db)
+	catalogService := catalogImpl.NewWithTx(query.Use(db), tx)
+	return NewCommissionServiceImpl(db, tx, catalogService)
+}
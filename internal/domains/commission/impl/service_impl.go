@@ -0,0 +1,367 @@
+// Package impl commission域的完整实现
+// 实现提成规则匹配、按月业绩分档、幂等计提与按比例冲回
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/commission"
+
+	"gorm.io/gorm"
+)
+
+// maxPercentValue 比例提成上限（万分比），即 100%
+const maxPercentValue = 10000
+
+// CommissionServiceImpl 员工提成域服务实现
+// 提成明细只追加不修改：计提与冲回各自写入一条带幂等键的明细
+type CommissionServiceImpl struct {
+	db         *gorm.DB
+	tx         common.Tx
+	catalogSvc catalog.Service
+}
+
+// NewCommissionServiceImpl 创建员工提成服务实例
+func NewCommissionServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service) *CommissionServiceImpl {
+	return &CommissionServiceImpl{
+		db:         db,
+		tx:         tx,
+		catalogSvc: catalogSvc,
+	}
+}
+
+// ===== 提成规则 =====
+
+// CreateRule 创建提成规则
+func (s *CommissionServiceImpl) CreateRule(ctx context.Context, req commission.SaveRuleRequest) (*commission.Rule, error) {
+	if err := s.validateRule(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	record := &CommissionRuleRecord{
+		Name:      req.Name,
+		Scope:     req.Scope,
+		ProductID: req.ProductID,
+		Category:  req.Category,
+		Type:      req.Type,
+		Value:     req.Value,
+		IsActive:  req.IsActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		if err := s.checkScopeConflict(ctx, txDB, record, 0); err != nil {
+			return err
+		}
+		if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建提成规则失败: %w", err)
+		}
+		return s.replaceTiers(ctx, txDB, record.ID, req.Tiers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toRule(record, req.Tiers), nil
+}
+
+// UpdateRule 更新提成规则
+func (s *CommissionServiceImpl) UpdateRule(ctx context.Context, id int64, req commission.SaveRuleRequest) (*commission.Rule, error) {
+	if err := s.validateRule(ctx, &req); err != nil {
+		return nil, err
+	}
+
+	var record *CommissionRuleRecord
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		existing, err := s.getRule(ctx, txDB, id)
+		if err != nil {
+			return err
+		}
+		existing.Name = req.Name
+		existing.Scope = req.Scope
+		existing.ProductID = req.ProductID
+		existing.Category = req.Category
+		existing.Type = req.Type
+		existing.Value = req.Value
+		existing.IsActive = req.IsActive
+		existing.UpdatedAt = time.Now().Unix()
+		if err := s.checkScopeConflict(ctx, txDB, existing, id); err != nil {
+			return err
+		}
+		if err := txDB.WithContext(ctx).Save(existing).Error; err != nil {
+			return fmt.Errorf("更新提成规则失败: %w", err)
+		}
+		record = existing
+		return s.replaceTiers(ctx, txDB, id, req.Tiers)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toRule(record, req.Tiers), nil
+}
+
+// DeleteRule 删除提成规则及其分档
+func (s *CommissionServiceImpl) DeleteRule(ctx context.Context, id int64) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		if _, err := s.getRule(ctx, txDB, id); err != nil {
+			return err
+		}
+		if err := txDB.WithContext(ctx).Where("rule_id = ?", id).Delete(&CommissionRuleTierRecord{}).Error; err != nil {
+			return fmt.Errorf("删除提成分档失败: %w", err)
+		}
+		if err := txDB.WithContext(ctx).Where("id = ?", id).Delete(&CommissionRuleRecord{}).Error; err != nil {
+			return fmt.Errorf("删除提成规则失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetRule 获取提成规则
+func (s *CommissionServiceImpl) GetRule(ctx context.Context, id int64) (*commission.Rule, error) {
+	record, err := s.getRule(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := s.loadTiers(ctx, s.db, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	return toRule(record, tiers[id]), nil
+}
+
+// ListRules 查询全部提成规则
+func (s *CommissionServiceImpl) ListRules(ctx context.Context) ([]commission.Rule, error) {
+	var records []CommissionRuleRecord
+	if err := s.db.WithContext(ctx).Order("id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询提成规则失败: %w", err)
+	}
+	ids := make([]int64, len(records))
+	for i, r := range records {
+		ids[i] = r.ID
+	}
+	tiers, err := s.loadTiers(ctx, s.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]commission.Rule, len(records))
+	for i := range records {
+		result[i] = *toRule(&records[i], tiers[records[i].ID])
+	}
+	return result, nil
+}
+
+// ===== 对账单 =====
+
+// GetStatement 查询员工提成对账单
+func (s *CommissionServiceImpl) GetStatement(ctx context.Context, staffID int64, startAt, endAt int64) (*commission.Statement, error) {
+	if endAt <= startAt {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "结束时间必须晚于开始时间")
+	}
+
+	var records []CommissionEntryRecord
+	if err := s.db.WithContext(ctx).
+		Where("staff_id = ? AND occurred_at >= ? AND occurred_at < ?", staffID, startAt, endAt).
+		Order("occurred_at ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询提成明细失败: %w", err)
+	}
+
+	statement := &commission.Statement{
+		StaffID: staffID,
+		StartAt: startAt,
+		EndAt:   endAt,
+		Entries: make([]commission.Entry, len(records)),
+	}
+	for i := range records {
+		r := &records[i]
+		if r.Type == string(constants.CommissionEntryTypeReversal) {
+			statement.Reversed += r.Amount
+			statement.Volume -= r.BaseAmount
+		} else {
+			statement.Accrued += r.Amount
+			statement.Volume += r.BaseAmount
+		}
+		statement.Entries[i] = *toEntry(r)
+	}
+	statement.Net = statement.Accrued - statement.Reversed
+	return statement, nil
+}
+
+// ===== 内部方法 =====
+
+// validateRule 校验并规范化提成规则：与适用范围无关的字段清零，分档按门槛升序
+func (s *CommissionServiceImpl) validateRule(ctx context.Context, req *commission.SaveRuleRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Name == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "规则名称不能为空")
+	}
+
+	switch constants.CommissionRuleScope(req.Scope) {
+	case constants.CommissionRuleScopeProduct:
+		if req.ProductID <= 0 {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "产品规则必须指定产品")
+		}
+		products, err := s.catalogSvc.BatchGet(ctx, []int64{req.ProductID})
+		if err != nil {
+			return fmt.Errorf("获取产品信息失败: %w", err)
+		}
+		if len(products) == 0 {
+			return common.NewBusinessError(common.ErrCodeProductNotFound, "产品不存在")
+		}
+		req.Category = ""
+	case constants.CommissionRuleScopeCategory:
+		if req.Category == "" {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "分类规则必须指定产品分类")
+		}
+		req.ProductID = 0
+	case constants.CommissionRuleScopeDefault:
+		req.ProductID = 0
+		req.Category = ""
+	default:
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "无效的适用范围")
+	}
+
+	if req.Type != string(constants.CommissionRuleTypePercent) && req.Type != string(constants.CommissionRuleTypeFixed) {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "无效的计算方式")
+	}
+	if err := validateRuleValue(req.Type, req.Value); err != nil {
+		return err
+	}
+
+	sort.Slice(req.Tiers, func(i, j int) bool { return req.Tiers[i].MinVolume < req.Tiers[j].MinVolume })
+	for i, tier := range req.Tiers {
+		if tier.MinVolume <= 0 {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "分档业绩门槛必须大于0")
+		}
+		if i > 0 && tier.MinVolume == req.Tiers[i-1].MinVolume {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "分档业绩门槛不能重复")
+		}
+		if err := validateRuleValue(req.Type, tier.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRuleValue 校验提成值：比例不超过 100%，金额不能为负
+func validateRuleValue(ruleType string, value int64) error {
+	if value < 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "提成值不能为负数")
+	}
+	if ruleType == string(constants.CommissionRuleTypePercent) && value > maxPercentValue {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "提成比例不能超过100%")
+	}
+	return nil
+}
+
+// checkScopeConflict 检查同一适用范围是否已有其他规则
+func (s *CommissionServiceImpl) checkScopeConflict(ctx context.Context, db *gorm.DB, record *CommissionRuleRecord, excludeID int64) error {
+	var count int64
+	if err := db.WithContext(ctx).Model(&CommissionRuleRecord{}).
+		Where("scope = ? AND product_id = ? AND category = ? AND id <> ?",
+			record.Scope, record.ProductID, record.Category, excludeID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("检查提成规则失败: %w", err)
+	}
+	if count > 0 {
+		return common.NewBusinessError(common.ErrCodeCommissionRuleConflict, "该适用范围已有提成规则")
+	}
+	return nil
+}
+
+// replaceTiers 整体替换规则的业绩分档
+func (s *CommissionServiceImpl) replaceTiers(ctx context.Context, db *gorm.DB, ruleID int64, tiers []commission.RuleTier) error {
+	if err := db.WithContext(ctx).Where("rule_id = ?", ruleID).Delete(&CommissionRuleTierRecord{}).Error; err != nil {
+		return fmt.Errorf("删除提成分档失败: %w", err)
+	}
+	if len(tiers) == 0 {
+		return nil
+	}
+	records := make([]CommissionRuleTierRecord, len(tiers))
+	for i, t := range tiers {
+		records[i] = CommissionRuleTierRecord{RuleID: ruleID, MinVolume: t.MinVolume, Value: t.Value}
+	}
+	if err := db.WithContext(ctx).Create(&records).Error; err != nil {
+		return fmt.Errorf("创建提成分档失败: %w", err)
+	}
+	return nil
+}
+
+// getRule 查询提成规则，不存在时返回 COMMISSION_RULE_NOT_FOUND
+func (s *CommissionServiceImpl) getRule(ctx context.Context, db *gorm.DB, id int64) (*CommissionRuleRecord, error) {
+	var record CommissionRuleRecord
+	if err := db.WithContext(ctx).Where("id = ?", id).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCommissionRuleNotFound, "提成规则不存在")
+		}
+		return nil, fmt.Errorf("查询提成规则失败: %w", err)
+	}
+	return &record, nil
+}
+
+// loadTiers 批量查询规则的业绩分档，按门槛升序
+func (s *CommissionServiceImpl) loadTiers(ctx context.Context, db *gorm.DB, ruleIDs []int64) (map[int64][]commission.RuleTier, error) {
+	result := make(map[int64][]commission.RuleTier, len(ruleIDs))
+	if len(ruleIDs) == 0 {
+		return result, nil
+	}
+	var records []CommissionRuleTierRecord
+	if err := db.WithContext(ctx).Where("rule_id IN ?", ruleIDs).
+		Order("rule_id ASC, min_volume ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询提成分档失败: %w", err)
+	}
+	for _, r := range records {
+		result[r.RuleID] = append(result[r.RuleID], commission.RuleTier{MinVolume: r.MinVolume, Value: r.Value})
+	}
+	return result, nil
+}
+
+func toRule(r *CommissionRuleRecord, tiers []commission.RuleTier) *commission.Rule {
+	if tiers == nil {
+		tiers = []commission.RuleTier{}
+	}
+	return &commission.Rule{
+		ID:        r.ID,
+		Name:      r.Name,
+		Scope:     r.Scope,
+		ProductID: r.ProductID,
+		Category:  r.Category,
+		Type:      r.Type,
+		Value:     r.Value,
+		IsActive:  r.IsActive,
+		Tiers:     tiers,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
+
+func toEntry(r *CommissionEntryRecord) *commission.Entry {
+	return &commission.Entry{
+		ID:          r.ID,
+		StaffID:     r.StaffID,
+		Type:        r.Type,
+		OrderID:     r.OrderID,
+		OrderItemID: r.OrderItemID,
+		ProductID:   r.ProductID,
+		RefundID:    r.RefundID,
+		RuleID:      r.RuleID,
+		RuleType:    r.RuleType,
+		RuleValue:   r.RuleValue,
+		Quantity:    r.Quantity,
+		BaseAmount:  r.BaseAmount,
+		Amount:      r.Amount,
+		OccurredAt:  r.OccurredAt,
+		CreatedAt:   r.CreatedAt,
+	}
+}
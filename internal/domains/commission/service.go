@@ -0,0 +1,121 @@
+// Package commission 员工提成域服务接口
+// 职责：提成规则（按产品/分类、比例或固定金额、按当月业绩分档）、订单支付计提、退款冲回、结算周期提成对账单
+// 核心原则：提成明细只追加不修改，计提与冲回均带幂等键，由 outbox 事件驱动
+package commission
+
+import "context"
+
+// Rule 提成规则
+// 匹配优先级：产品规则 > 分类规则 > 默认规则，同一范围只能有一条规则
+type Rule struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`       // 规则名称
+	Scope     string     `json:"scope"`      // 适用范围：product/category/default
+	ProductID int64      `json:"product_id"` // 适用产品ID，scope = product 时有效
+	Category  string     `json:"category"`   // 适用产品分类，scope = category 时有效
+	Type      string     `json:"type"`       // 计算方式：percent（万分比）/fixed（每件固定金额，分）
+	Value     int64      `json:"value"`      // 基础提成值，未达到任何分档时使用
+	IsActive  bool       `json:"is_active"`  // 是否启用
+	Tiers     []RuleTier `json:"tiers"`      // 业绩分档，按门槛升序
+	CreatedAt int64      `json:"created_at"`
+	UpdatedAt int64      `json:"updated_at"`
+}
+
+// RuleTier 业绩分档
+// 员工当月业绩（含本单）达到门槛后，使用门槛最高的一档提成值
+type RuleTier struct {
+	MinVolume int64 `json:"min_volume"` // 当月业绩门槛（分）
+	Value     int64 `json:"value"`      // 本档提成值，单位同规则计算方式
+}
+
+// SaveRuleRequest 创建或更新提成规则请求
+// 规则变更只影响之后计提的提成，已计提的明细保留计提时的提成值快照
+type SaveRuleRequest struct {
+	Name      string     `json:"name"`
+	Scope     string     `json:"scope"`
+	ProductID int64      `json:"product_id"`
+	Category  string     `json:"category"`
+	Type      string     `json:"type"`
+	Value     int64      `json:"value"`
+	IsActive  bool       `json:"is_active"`
+	Tiers     []RuleTier `json:"tiers"`
+}
+
+// Entry 提成明细
+type Entry struct {
+	ID          int64  `json:"id"`
+	StaffID     int64  `json:"staff_id"`      // 员工ID
+	Type        string `json:"type"`          // 类型：accrual/reversal
+	OrderID     int64  `json:"order_id"`      // 订单ID
+	OrderItemID int64  `json:"order_item_id"` // 订单项ID
+	ProductID   int64  `json:"product_id"`    // 产品ID
+	RefundID    int64  `json:"refund_id"`     // 退款记录ID，冲回时有效
+	RuleID      int64  `json:"rule_id"`       // 计提时匹配的规则ID
+	RuleType    string `json:"rule_type"`     // 计提时的计算方式
+	RuleValue   int64  `json:"rule_value"`    // 计提时实际使用的提成值（含分档）
+	Quantity    int32  `json:"quantity"`      // 件数
+	BaseAmount  int64  `json:"base_amount"`   // 提成基数（分）
+	Amount      int64  `json:"amount"`        // 提成金额（分，正数）
+	OccurredAt  int64  `json:"occurred_at"`   // 业务发生时间（Unix时间戳）
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// AccrueRequest 订单支付计提请求，来自 order.paid 事件
+type AccrueRequest struct {
+	OrderID int64 `json:"order_id"` // 订单ID
+	PaidAt  int64 `json:"paid_at"`  // 支付时间，决定提成归属的结算周期
+}
+
+// ReverseRequest 订单退款冲回请求，来自 order.refunded 事件
+type ReverseRequest struct {
+	OrderID    int64         `json:"order_id"`    // 订单ID
+	RefundID   int64         `json:"refund_id"`   // 退款记录ID
+	Lines      []ReverseLine `json:"lines"`       // 本次退款的订单项
+	RefundedAt int64         `json:"refunded_at"` // 退款时间，冲回计入退款所在的结算周期
+}
+
+// ReverseLine 冲回的订单项
+type ReverseLine struct {
+	OrderItemID int64 `json:"order_item_id"` // 订单项ID
+	Quantity    int32 `json:"quantity"`      // 退款数量
+}
+
+// Statement 员工提成对账单
+type Statement struct {
+	StaffID  int64   `json:"staff_id"`
+	StartAt  int64   `json:"start_at"` // 周期开始（含，Unix时间戳）
+	EndAt    int64   `json:"end_at"`   // 周期结束（不含，Unix时间戳）
+	Volume   int64   `json:"volume"`   // 周期内净业绩（分）：计提基数 - 冲回基数
+	Accrued  int64   `json:"accrued"`  // 计提合计（分）
+	Reversed int64   `json:"reversed"` // 冲回合计（分）
+	Net      int64   `json:"net"`      // 应发提成（分）
+	Entries  []Entry `json:"entries"`  // 明细，按发生时间升序
+}
+
+// Service 员工提成域服务接口
+type Service interface {
+	// CreateRule 创建提成规则，同一适用范围已有规则时返回 COMMISSION_RULE_CONFLICT
+	CreateRule(ctx context.Context, req SaveRuleRequest) (*Rule, error)
+
+	// UpdateRule 更新提成规则，分档整体替换
+	UpdateRule(ctx context.Context, id int64, req SaveRuleRequest) (*Rule, error)
+
+	// DeleteRule 删除提成规则，已计提的明细不受影响
+	DeleteRule(ctx context.Context, id int64) error
+
+	// GetRule 获取提成规则，不存在时返回 COMMISSION_RULE_NOT_FOUND
+	GetRule(ctx context.Context, id int64) (*Rule, error)
+
+	// ListRules 查询全部提成规则
+	ListRules(ctx context.Context) ([]Rule, error)
+
+	// AccrueOrder 为已支付订单的负责员工计提提成
+	// 订单未分配员工或订单项没有匹配规则时不计提；同一订单项只计提一次
+	AccrueOrder(ctx context.Context, req AccrueRequest) error
+
+	// ReverseRefund 按退款数量比例冲回已计提的提成，同一退款只冲回一次
+	ReverseRefund(ctx context.Context, req ReverseRequest) error
+
+	// GetStatement 查询员工在 [startAt, endAt) 周期内的提成对账单
+	GetStatement(ctx context.Context, staffID int64, startAt, endAt int64) (*Statement, error)
+}
@@ -0,0 +1,136 @@
+package crm
+
+import (
+	"context"
+	"time"
+)
+
+// 活动类型
+const (
+	ActivityTypeCall      = "call"      // 电话
+	ActivityTypeMeeting   = "meeting"   // 会议
+	ActivityTypeEmail     = "email"     // 邮件
+	ActivityTypeVisit     = "visit"     // 拜访
+	ActivityTypeFollowUp  = "follow_up" // 跟进任务
+	ActivityTypeComplaint = "complaint" // 投诉
+	ActivityTypeFeedback  = "feedback"  // 反馈
+)
+
+// 活动状态：planned、in_progress 为未完成，completed、cancelled 为终态
+const (
+	ActivityStatusPlanned    = "planned"
+	ActivityStatusInProgress = "in_progress"
+	ActivityStatusCompleted  = "completed"
+	ActivityStatusCancelled  = "cancelled"
+)
+
+// 活动优先级
+const (
+	ActivityPriorityLow    = "low"
+	ActivityPriorityMedium = "medium"
+	ActivityPriorityHigh   = "high"
+	ActivityPriorityUrgent = "urgent"
+)
+
+// ValidActivityTypes 全部活动类型
+func ValidActivityTypes() []string {
+	return []string{ActivityTypeCall, ActivityTypeMeeting, ActivityTypeEmail, ActivityTypeVisit,
+		ActivityTypeFollowUp, ActivityTypeComplaint, ActivityTypeFeedback}
+}
+
+// ValidActivityPriorities 全部活动优先级
+func ValidActivityPriorities() []string {
+	return []string{ActivityPriorityLow, ActivityPriorityMedium, ActivityPriorityHigh, ActivityPriorityUrgent}
+}
+
+// IsOpenActivityStatus 是否为未完成状态
+func IsOpenActivityStatus(status string) bool {
+	return status == ActivityStatusPlanned || status == ActivityStatusInProgress
+}
+
+// Activity 客户活动（电话、会议、拜访、跟进任务、投诉等）
+// 带计划时间与负责人的活动即跟进任务，计划时间已过仍未完成视为逾期
+type Activity struct {
+	ID          int64  `json:"id"`
+	CustomerID  int64  `json:"customer_id"`  // 客户ID
+	ContactID   int64  `json:"contact_id"`   // 联系人ID，0 表示未指定
+	Type        string `json:"type"`         // 活动类型
+	Title       string `json:"title"`        // 标题
+	Content     string `json:"content"`      // 内容，完成时追加处理结果
+	Status      string `json:"status"`       // 状态：planned/in_progress/completed/cancelled
+	Priority    string `json:"priority"`     // 优先级：low/medium/high/urgent
+	ScheduledAt int64  `json:"scheduled_at"` // 计划时间（Unix时间戳），0 表示未安排
+	CompletedAt int64  `json:"completed_at"` // 完成时间（Unix时间戳），未完成为0
+	AssignedTo  int64  `json:"assigned_to"`  // 负责人ID
+	CreatedBy   int64  `json:"created_by"`   // 创建人ID
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+}
+
+// CreateActivityReq 创建活动请求
+type CreateActivityReq struct {
+	CustomerID  int64     `json:"customer_id"`  // 客户ID
+	ContactID   int64     `json:"contact_id"`   // 联系人ID（可选），须属于该客户
+	Type        string    `json:"type"`         // 活动类型
+	Title       string    `json:"title"`        // 标题
+	Content     string    `json:"content"`      // 内容
+	Priority    string    `json:"priority"`     // 优先级，缺省为 medium
+	ScheduledAt time.Time `json:"scheduled_at"` // 计划时间（可选）
+	AssignedTo  int64     `json:"assigned_to"`  // 负责人，缺省为创建人
+	OperatorID  int64     `json:"operator_id"`  // 创建人ID
+}
+
+// UpdateActivityReq 更新活动请求，nil 字段保持不变
+// 状态只能在 planned、in_progress、cancelled 之间调整，完成须调用 CompleteActivity
+type UpdateActivityReq struct {
+	ID          int64      `json:"id"`
+	ContactID   *int64     `json:"contact_id"`
+	Type        *string    `json:"type"`
+	Title       *string    `json:"title"`
+	Content     *string    `json:"content"`
+	Status      *string    `json:"status"`
+	Priority    *string    `json:"priority"`
+	ScheduledAt *time.Time `json:"scheduled_at"`
+	AssignedTo  *int64     `json:"assigned_to"`
+}
+
+// ListActivitiesReq 活动列表请求
+type ListActivitiesReq struct {
+	CustomerID int64   `json:"customer_id,omitempty"` // 按客户筛选
+	AssignedTo []int64 `json:"assigned_to,omitempty"` // 负责人范围，为空表示不限
+	Type       string  `json:"type,omitempty"`        // 按类型筛选
+	Status     string  `json:"status,omitempty"`      // 按状态筛选
+	OpenOnly   bool    `json:"open_only,omitempty"`   // 仅未完成（planned、in_progress）
+	DueBefore  int64   `json:"due_before,omitempty"`  // 计划时间早于该时间（Unix时间戳），用于逾期查询
+	Page       int     `json:"page"`
+	PageSize   int     `json:"page_size"`
+}
+
+// ActivityService 客户活动与跟进任务服务接口
+// 访问控制由调用方按客户层级规则（负责人本人及其上级）完成，服务只负责业务规则
+type ActivityService interface {
+	// CreateActivity 创建活动，客户须存在
+	CreateActivity(ctx context.Context, req CreateActivityReq) (*Activity, error)
+
+	// GetActivity 获取活动详情
+	GetActivity(ctx context.Context, activityID int64) (*Activity, error)
+
+	// UpdateActivity 更新活动，已完成或已取消的活动不能修改
+	UpdateActivity(ctx context.Context, req UpdateActivityReq) (*Activity, error)
+
+	// DeleteActivity 软删除活动
+	DeleteActivity(ctx context.Context, activityID int64) error
+
+	// CompleteActivity 完成活动，记录完成时间，result 非空时追加到内容
+	CompleteActivity(ctx context.Context, activityID int64, result string) (*Activity, error)
+
+	// ListCustomerActivities 查询客户的活动，按创建时间倒序
+	ListCustomerActivities(ctx context.Context, req ListActivitiesReq) ([]Activity, int64, error)
+
+	// ListOpenTasks 查询负责人的未完成任务，按计划时间正序，未安排时间的排在最后
+	ListOpenTasks(ctx context.Context, assignedTo int64, page, pageSize int) ([]Activity, int64, error)
+
+	// ListOverdueActivities 查询计划时间早于 now 且仍未完成的活动，按计划时间正序
+	// assignees 为负责人范围，为空表示不限
+	ListOverdueActivities(ctx context.Context, assignees []int64, now time.Time, page, pageSize int) ([]Activity, int64, error)
+}
@@ -0,0 +1,76 @@
+package crm
+
+import "context"
+
+// 自定义字段类型
+const (
+	CustomFieldTypeText        = "text"         // 文本
+	CustomFieldTypeNumber      = "number"       // 数字
+	CustomFieldTypeDate        = "date"         // 日期，格式 YYYY-MM-DD
+	CustomFieldTypeEnum        = "enum"         // 单选，取值须为可选项之一
+	CustomFieldTypeMultiSelect = "multi_select" // 多选，取值为可选项的子集
+)
+
+// ValidCustomFieldTypes 全部自定义字段类型
+func ValidCustomFieldTypes() []string {
+	return []string{CustomFieldTypeText, CustomFieldTypeNumber, CustomFieldTypeDate,
+		CustomFieldTypeEnum, CustomFieldTypeMultiSelect}
+}
+
+// CustomFieldHasOptions 该类型是否需要配置可选项
+func CustomFieldHasOptions(typ string) bool {
+	return typ == CustomFieldTypeEnum || typ == CustomFieldTypeMultiSelect
+}
+
+// CustomField 客户自定义字段定义
+// 由管理员按业务类型配置（如发质、车牌号、宠物名），字段值随客户保存在 CustomFields 中，以 Key 为键
+type CustomField struct {
+	ID        int64    `json:"id"`
+	Key       string   `json:"key"`      // 字段标识，小写字母开头，仅含小写字母、数字和下划线，创建后不可修改
+	Label     string   `json:"label"`    // 显示名称
+	Type      string   `json:"type"`     // 字段类型，创建后不可修改
+	Options   []string `json:"options"`  // 可选项，仅 enum/multi_select 使用
+	Required  bool     `json:"required"` // 创建客户时是否必填
+	Sort      int      `json:"sort"`     // 排序，越小越靠前
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
+
+// CreateCustomFieldReq 创建自定义字段请求
+type CreateCustomFieldReq struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
+	Sort     int      `json:"sort"`
+}
+
+// UpdateCustomFieldReq 更新自定义字段请求，nil 表示不修改
+// 删减可选项不影响已保存的客户字段值，再次保存该客户时才按新的可选项校验
+type UpdateCustomFieldReq struct {
+	ID       int64     `json:"id"`
+	Label    *string   `json:"label"`
+	Options  *[]string `json:"options"`
+	Required *bool     `json:"required"`
+	Sort     *int      `json:"sort"`
+}
+
+// CustomFieldService 客户自定义字段定义服务接口
+// 字段值的校验、保存与筛选由 Service 的客户接口完成
+type CustomFieldService interface {
+	// CreateField 创建字段，Key 重复返回 CUSTOM_FIELD_EXISTS
+	CreateField(ctx context.Context, req CreateCustomFieldReq) (*CustomField, error)
+
+	// GetField 获取字段定义
+	GetField(ctx context.Context, fieldID int64) (*CustomField, error)
+
+	// UpdateField 更新字段的名称、可选项、是否必填与排序
+	UpdateField(ctx context.Context, req UpdateCustomFieldReq) (*CustomField, error)
+
+	// DeleteField 删除字段及全部客户的该字段值
+	DeleteField(ctx context.Context, fieldID int64) error
+
+	// ListFields 按排序返回全部字段
+	ListFields(ctx context.Context) ([]CustomField, error)
+}
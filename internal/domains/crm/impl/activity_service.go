@@ -0,0 +1,354 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/crm"
+
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+// activityTitleMaxLen 活动标题最大长度，与 activities.title 列宽一致
+const activityTitleMaxLen = 200
+
+// ActivityServiceImpl 客户活动与跟进任务服务实现
+// 基于 activities 表，软删除由 deleted_at 处理
+type ActivityServiceImpl struct {
+	db *gorm.DB
+	q  *query.Query
+}
+
+// NewActivityService 创建客户活动服务实例
+func NewActivityService(db *gorm.DB) *ActivityServiceImpl {
+	return &ActivityServiceImpl{
+		db: db,
+		q:  query.Use(db),
+	}
+}
+
+// CreateActivity 创建活动
+func (s *ActivityServiceImpl) CreateActivity(ctx context.Context, req crm.CreateActivityReq) (*crm.Activity, error) {
+	if err := validateActivityFields(req.Type, req.Title, req.Priority); err != nil {
+		return nil, err
+	}
+	if _, err := s.q.Customer.WithContext(ctx).Where(s.q.Customer.ID.Eq(req.CustomerID)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCustomerNotFound, "客户不存在")
+		}
+		return nil, fmt.Errorf("查询客户失败: %w", err)
+	}
+	if err := s.checkContact(ctx, req.CustomerID, req.ContactID); err != nil {
+		return nil, err
+	}
+
+	priority := req.Priority
+	if priority == "" {
+		priority = crm.ActivityPriorityMedium
+	}
+	assignedTo := req.AssignedTo
+	if assignedTo == 0 {
+		assignedTo = req.OperatorID
+	}
+
+	activity := &model.Activity{
+		CustomerID:  req.CustomerID,
+		ContactID:   req.ContactID,
+		Type:        req.Type,
+		Title:       req.Title,
+		Content:     req.Content,
+		Status:      crm.ActivityStatusPlanned,
+		Priority:    priority,
+		ScheduledAt: req.ScheduledAt,
+		AssignedTo:  assignedTo,
+		CreatedBy:   req.OperatorID,
+	}
+	// 未安排或未完成的时间列保持 NULL
+	omit := []field.Expr{s.q.Activity.CompletedAt}
+	if req.ScheduledAt.IsZero() {
+		omit = append(omit, s.q.Activity.ScheduledAt)
+	}
+	if err := s.q.Activity.WithContext(ctx).Omit(omit...).Create(activity); err != nil {
+		return nil, fmt.Errorf("创建活动失败: %w", err)
+	}
+	return s.GetActivity(ctx, activity.ID)
+}
+
+// GetActivity 获取活动详情
+func (s *ActivityServiceImpl) GetActivity(ctx context.Context, activityID int64) (*crm.Activity, error) {
+	activity, err := s.q.Activity.WithContext(ctx).Where(s.q.Activity.ID.Eq(activityID)).First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeActivityNotFound, "活动不存在")
+		}
+		return nil, fmt.Errorf("查询活动失败: %w", err)
+	}
+	return toActivity(activity), nil
+}
+
+// UpdateActivity 更新活动
+// 以未完成状态为条件更新，并发完成或取消后再更新返回 ACTIVITY_STATUS_INVALID
+func (s *ActivityServiceImpl) UpdateActivity(ctx context.Context, req crm.UpdateActivityReq) (*crm.Activity, error) {
+	current, err := s.GetActivity(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !crm.IsOpenActivityStatus(current.Status) {
+		return nil, common.NewBusinessError(common.ErrCodeActivityStatusInvalid, "已完成或已取消的活动不能修改")
+	}
+
+	updates := make(map[string]interface{})
+	if req.Type != nil || req.Title != nil || req.Priority != nil {
+		typ, title, priority := current.Type, current.Title, current.Priority
+		if req.Type != nil {
+			typ = *req.Type
+			updates["type"] = typ
+		}
+		if req.Title != nil {
+			title = *req.Title
+			updates["title"] = title
+		}
+		if req.Priority != nil {
+			priority = *req.Priority
+			updates["priority"] = priority
+		}
+		if err := validateActivityFields(typ, title, priority); err != nil {
+			return nil, err
+		}
+	}
+	if req.ContactID != nil {
+		if err := s.checkContact(ctx, current.CustomerID, *req.ContactID); err != nil {
+			return nil, err
+		}
+		updates["contact_id"] = *req.ContactID
+	}
+	if req.Content != nil {
+		updates["content"] = *req.Content
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case crm.ActivityStatusPlanned, crm.ActivityStatusInProgress, crm.ActivityStatusCancelled:
+			updates["status"] = *req.Status
+		case crm.ActivityStatusCompleted:
+			return nil, common.NewBusinessError(common.ErrCodeActivityStatusInvalid, "完成活动请使用完成接口")
+		default:
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的活动状态: %s", *req.Status))
+		}
+	}
+	if req.ScheduledAt != nil {
+		if req.ScheduledAt.IsZero() {
+			updates["scheduled_at"] = nil
+		} else {
+			updates["scheduled_at"] = *req.ScheduledAt
+		}
+	}
+	if req.AssignedTo != nil {
+		updates["assigned_to"] = *req.AssignedTo
+	}
+	if len(updates) == 0 {
+		return current, nil
+	}
+
+	if err := s.updateOpenActivity(ctx, req.ID, updates); err != nil {
+		return nil, err
+	}
+	return s.GetActivity(ctx, req.ID)
+}
+
+// DeleteActivity 软删除活动
+func (s *ActivityServiceImpl) DeleteActivity(ctx context.Context, activityID int64) error {
+	info, err := s.q.Activity.WithContext(ctx).Where(s.q.Activity.ID.Eq(activityID)).Delete()
+	if err != nil {
+		return fmt.Errorf("删除活动失败: %w", err)
+	}
+	if info.RowsAffected == 0 {
+		return common.NewBusinessError(common.ErrCodeActivityNotFound, "活动不存在")
+	}
+	return nil
+}
+
+// CompleteActivity 完成活动
+func (s *ActivityServiceImpl) CompleteActivity(ctx context.Context, activityID int64, result string) (*crm.Activity, error) {
+	current, err := s.GetActivity(ctx, activityID)
+	if err != nil {
+		return nil, err
+	}
+	if !crm.IsOpenActivityStatus(current.Status) {
+		return nil, common.NewBusinessError(common.ErrCodeActivityStatusInvalid, "活动已完成或已取消")
+	}
+
+	updates := map[string]interface{}{
+		"status":       crm.ActivityStatusCompleted,
+		"completed_at": time.Now(),
+	}
+	if result != "" {
+		content := result
+		if current.Content != "" {
+			content = current.Content + "\n\n【处理结果】" + result
+		}
+		updates["content"] = content
+	}
+	if err := s.updateOpenActivity(ctx, activityID, updates); err != nil {
+		return nil, err
+	}
+	return s.GetActivity(ctx, activityID)
+}
+
+// ListCustomerActivities 查询客户的活动
+func (s *ActivityServiceImpl) ListCustomerActivities(ctx context.Context, req crm.ListActivitiesReq) ([]crm.Activity, int64, error) {
+	return s.listActivities(ctx, req, "created_at DESC, id DESC")
+}
+
+// ListOpenTasks 查询负责人的未完成任务
+func (s *ActivityServiceImpl) ListOpenTasks(ctx context.Context, assignedTo int64, page, pageSize int) ([]crm.Activity, int64, error) {
+	return s.listActivities(ctx, crm.ListActivitiesReq{
+		AssignedTo: []int64{assignedTo},
+		OpenOnly:   true,
+		Page:       page,
+		PageSize:   pageSize,
+	}, "CASE WHEN scheduled_at IS NULL THEN 1 ELSE 0 END, scheduled_at ASC, id ASC")
+}
+
+// ListOverdueActivities 查询逾期未完成的活动
+func (s *ActivityServiceImpl) ListOverdueActivities(ctx context.Context, assignees []int64, now time.Time, page, pageSize int) ([]crm.Activity, int64, error) {
+	return s.listActivities(ctx, crm.ListActivitiesReq{
+		AssignedTo: assignees,
+		OpenOnly:   true,
+		DueBefore:  now.Unix(),
+		Page:       page,
+		PageSize:   pageSize,
+	}, "scheduled_at ASC, id ASC")
+}
+
+// listActivities 按条件分页查询活动
+func (s *ActivityServiceImpl) listActivities(ctx context.Context, req crm.ListActivitiesReq, orderBy string) ([]crm.Activity, int64, error) {
+	db := s.db.WithContext(ctx).Model(&model.Activity{})
+	if req.CustomerID > 0 {
+		db = db.Where("customer_id = ?", req.CustomerID)
+	}
+	if len(req.AssignedTo) > 0 {
+		db = db.Where("assigned_to IN ?", req.AssignedTo)
+	}
+	if req.Type != "" {
+		db = db.Where("type = ?", req.Type)
+	}
+	if req.Status != "" {
+		db = db.Where("status = ?", req.Status)
+	}
+	if req.OpenOnly {
+		db = db.Where("status IN ?", []string{crm.ActivityStatusPlanned, crm.ActivityStatusInProgress})
+	}
+	if req.DueBefore > 0 {
+		db = db.Where("scheduled_at IS NOT NULL AND scheduled_at < ?", time.Unix(req.DueBefore, 0))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询活动总数失败: %w", err)
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var records []*model.Activity
+	if err := db.Order(orderBy).Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询活动列表失败: %w", err)
+	}
+
+	result := make([]crm.Activity, len(records))
+	for i, r := range records {
+		result[i] = *toActivity(r)
+	}
+	return result, total, nil
+}
+
+// updateOpenActivity 仅在活动仍未完成时更新
+func (s *ActivityServiceImpl) updateOpenActivity(ctx context.Context, activityID int64, updates map[string]interface{}) error {
+	a := s.q.Activity
+	info, err := a.WithContext(ctx).
+		Where(a.ID.Eq(activityID), a.Status.In(crm.ActivityStatusPlanned, crm.ActivityStatusInProgress)).
+		Updates(updates)
+	if err != nil {
+		return fmt.Errorf("更新活动失败: %w", err)
+	}
+	if info.RowsAffected == 0 {
+		return common.NewBusinessError(common.ErrCodeActivityStatusInvalid, "活动已完成或已取消")
+	}
+	return nil
+}
+
+// checkContact 校验联系人属于该客户，contactID 为 0 表示不指定
+func (s *ActivityServiceImpl) checkContact(ctx context.Context, customerID, contactID int64) error {
+	if contactID == 0 {
+		return nil
+	}
+	c := s.q.Contact
+	count, err := c.WithContext(ctx).Where(c.ID.Eq(contactID), c.CustomerID.Eq(customerID)).Count()
+	if err != nil {
+		return fmt.Errorf("查询联系人失败: %w", err)
+	}
+	if count == 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "联系人不属于该客户")
+	}
+	return nil
+}
+
+func validateActivityFields(typ, title, priority string) error {
+	if !containsString(crm.ValidActivityTypes(), typ) {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的活动类型: %s", typ))
+	}
+	if title == "" || utf8.RuneCountInString(title) > activityTitleMaxLen {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("活动标题不能为空且不超过%d个字符", activityTitleMaxLen))
+	}
+	if priority != "" && !containsString(crm.ValidActivityPriorities(), priority) {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的活动优先级: %s", priority))
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func toActivity(a *model.Activity) *crm.Activity {
+	activity := &crm.Activity{
+		ID:         a.ID,
+		CustomerID: a.CustomerID,
+		ContactID:  a.ContactID,
+		Type:       a.Type,
+		Title:      a.Title,
+		Content:    a.Content,
+		Status:     a.Status,
+		Priority:   a.Priority,
+		AssignedTo: a.AssignedTo,
+		CreatedBy:  a.CreatedBy,
+		CreatedAt:  a.CreatedAt.Unix(),
+		UpdatedAt:  a.UpdatedAt.Unix(),
+	}
+	if !a.ScheduledAt.IsZero() {
+		activity.ScheduledAt = a.ScheduledAt.Unix()
+	}
+	if !a.CompletedAt.IsZero() {
+		activity.CompletedAt = a.CompletedAt.Unix()
+	}
+	return activity
+}
+
+var _ crm.ActivityService = (*ActivityServiceImpl)(nil)
@@ -0,0 +1,227 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/crm"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newActivityTestDB 创建内存数据库并初始化活动相关表结构
+// activities 表按迁移脚本保留可空列，覆盖 NULL 计划时间、完成时间的读写
+func newActivityTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	for _, ddl := range []string{`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			phone TEXT,
+			email TEXT,
+			gender TEXT DEFAULT 'unknown',
+			birthday DATETIME,
+			level TEXT DEFAULT '普通',
+			tags TEXT,
+			note TEXT,
+			source TEXT DEFAULT 'manual',
+			assigned_to INTEGER DEFAULT 0,
+			deleted_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`, `
+		CREATE TABLE contacts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			phone TEXT,
+			email TEXT,
+			position TEXT,
+			is_primary INTEGER DEFAULT 0,
+			note TEXT,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)`, `
+		CREATE TABLE activities (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			contact_id INTEGER,
+			type TEXT NOT NULL,
+			title TEXT NOT NULL,
+			content TEXT,
+			status TEXT DEFAULT 'planned',
+			priority TEXT DEFAULT 'medium',
+			scheduled_at DATETIME,
+			completed_at DATETIME,
+			assigned_to INTEGER,
+			created_by INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)`,
+	} {
+		require.NoError(t, db.Exec(ddl).Error)
+	}
+	return db
+}
+
+// TestActivityService 客户活动：创建校验、更新与完成的状态约束、我的待办、逾期与客户活动列表、软删除
+func TestActivityService(t *testing.T) {
+	db := newActivityTestDB(t)
+	ctx := context.Background()
+	svc := NewActivityService(db)
+
+	customer := &model.Customer{Name: "活动客户", Phone: "13800000001", AssignedTo: 11}
+	require.NoError(t, db.Create(customer).Error)
+	other := &model.Customer{Name: "其他客户", Phone: "13800000002", AssignedTo: 12}
+	require.NoError(t, db.Create(other).Error)
+	contact := &model.Contact{CustomerID: customer.ID, Name: "张经理"}
+	require.NoError(t, db.Create(contact).Error)
+
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+	now := time.Now()
+
+	t.Run("创建活动", func(t *testing.T) {
+		activity, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID: customer.ID,
+			ContactID:  contact.ID,
+			Type:       crm.ActivityTypeCall,
+			Title:      "首次回访",
+			OperatorID: 11,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, crm.ActivityStatusPlanned, activity.Status)
+		assert.Equal(t, crm.ActivityPriorityMedium, activity.Priority)
+		assert.Equal(t, int64(11), activity.AssignedTo, "负责人缺省为创建人")
+		assert.Zero(t, activity.ScheduledAt)
+		assert.Zero(t, activity.CompletedAt)
+
+		_, err = svc.CreateActivity(ctx, crm.CreateActivityReq{CustomerID: customer.ID, Type: "sms", Title: "短信"})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = svc.CreateActivity(ctx, crm.CreateActivityReq{CustomerID: customer.ID, Type: crm.ActivityTypeCall})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = svc.CreateActivity(ctx, crm.CreateActivityReq{CustomerID: 9999, Type: crm.ActivityTypeCall, Title: "回访"})
+		assertCode(t, err, common.ErrCodeCustomerNotFound)
+		_, err = svc.CreateActivity(ctx, crm.CreateActivityReq{CustomerID: other.ID, ContactID: contact.ID, Type: crm.ActivityTypeCall, Title: "回访"})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+	})
+
+	t.Run("更新与完成", func(t *testing.T) {
+		activity, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID:  customer.ID,
+			Type:        crm.ActivityTypeFollowUp,
+			Title:       "跟进报价",
+			Content:     "确认报价单",
+			ScheduledAt: now.Add(24 * time.Hour),
+			OperatorID:  11,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, now.Add(24*time.Hour).Unix(), activity.ScheduledAt)
+
+		status := crm.ActivityStatusInProgress
+		priority := crm.ActivityPriorityHigh
+		updated, err := svc.UpdateActivity(ctx, crm.UpdateActivityReq{ID: activity.ID, Status: &status, Priority: &priority})
+		require.NoError(t, err)
+		assert.Equal(t, crm.ActivityStatusInProgress, updated.Status)
+		assert.Equal(t, crm.ActivityPriorityHigh, updated.Priority)
+
+		completed := crm.ActivityStatusCompleted
+		_, err = svc.UpdateActivity(ctx, crm.UpdateActivityReq{ID: activity.ID, Status: &completed})
+		assertCode(t, err, common.ErrCodeActivityStatusInvalid)
+
+		done, err := svc.CompleteActivity(ctx, activity.ID, "客户接受报价")
+		require.NoError(t, err)
+		assert.Equal(t, crm.ActivityStatusCompleted, done.Status)
+		assert.NotZero(t, done.CompletedAt)
+		assert.Contains(t, done.Content, "确认报价单")
+		assert.Contains(t, done.Content, "客户接受报价")
+
+		_, err = svc.CompleteActivity(ctx, activity.ID, "")
+		assertCode(t, err, common.ErrCodeActivityStatusInvalid)
+		title := "修改标题"
+		_, err = svc.UpdateActivity(ctx, crm.UpdateActivityReq{ID: activity.ID, Title: &title})
+		assertCode(t, err, common.ErrCodeActivityStatusInvalid)
+		_, err = svc.CompleteActivity(ctx, 9999, "")
+		assertCode(t, err, common.ErrCodeActivityNotFound)
+	})
+
+	t.Run("我的待办与逾期", func(t *testing.T) {
+		overdue, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID: other.ID, Type: crm.ActivityTypeVisit, Title: "上门拜访",
+			ScheduledAt: now.Add(-2 * time.Hour), AssignedTo: 12, OperatorID: 11,
+		})
+		require.NoError(t, err)
+		cancelled, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID: other.ID, Type: crm.ActivityTypeMeeting, Title: "已取消的会议",
+			ScheduledAt: now.Add(-time.Hour), AssignedTo: 12, OperatorID: 12,
+		})
+		require.NoError(t, err)
+		status := crm.ActivityStatusCancelled
+		_, err = svc.UpdateActivity(ctx, crm.UpdateActivityReq{ID: cancelled.ID, Status: &status})
+		require.NoError(t, err)
+		soon, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID: other.ID, Type: crm.ActivityTypeCall, Title: "电话确认",
+			ScheduledAt: now.Add(time.Hour), AssignedTo: 12, OperatorID: 12,
+		})
+		require.NoError(t, err)
+		unscheduled, err := svc.CreateActivity(ctx, crm.CreateActivityReq{
+			CustomerID: other.ID, Type: crm.ActivityTypeFeedback, Title: "整理反馈", OperatorID: 12,
+		})
+		require.NoError(t, err)
+
+		tasks, total, err := svc.ListOpenTasks(ctx, 12, 1, 20)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, tasks, 3)
+		assert.Equal(t, []int64{overdue.ID, soon.ID, unscheduled.ID}, []int64{tasks[0].ID, tasks[1].ID, tasks[2].ID},
+			"按计划时间正序，未安排时间的排在最后")
+
+		list, total, err := svc.ListOverdueActivities(ctx, []int64{12}, now, 1, 20)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		require.Len(t, list, 1)
+		assert.Equal(t, overdue.ID, list[0].ID)
+
+		list, _, err = svc.ListOverdueActivities(ctx, []int64{11}, now, 1, 20)
+		require.NoError(t, err)
+		assert.Empty(t, list, "负责人范围之外的逾期活动不返回")
+		list, _, err = svc.ListOverdueActivities(ctx, nil, now.Add(2*time.Hour), 1, 20)
+		require.NoError(t, err)
+		assert.Len(t, list, 2)
+	})
+
+	t.Run("客户活动列表与删除", func(t *testing.T) {
+		list, total, err := svc.ListCustomerActivities(ctx, crm.ListActivitiesReq{CustomerID: customer.ID, Page: 1, PageSize: 20})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		require.Len(t, list, 2)
+		assert.Greater(t, list[0].ID, list[1].ID, "按创建时间倒序")
+
+		list, total, err = svc.ListCustomerActivities(ctx, crm.ListActivitiesReq{CustomerID: customer.ID, Status: crm.ActivityStatusCompleted})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+
+		require.NoError(t, svc.DeleteActivity(ctx, list[0].ID))
+		_, err = svc.GetActivity(ctx, list[0].ID)
+		assertCode(t, err, common.ErrCodeActivityNotFound)
+		assertCode(t, svc.DeleteActivity(ctx, list[0].ID), common.ErrCodeActivityNotFound)
+
+		_, total, err = svc.ListCustomerActivities(ctx, crm.ListActivitiesReq{CustomerID: customer.ID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+	})
+}
@@ -14,6 +14,7 @@ import (
 	"crm_lite/internal/domains/crm"
 	"crm_lite/pkg/utils"
 
+	"gorm.io/gen"
 	"gorm.io/gorm"
 )
 
@@ -29,8 +30,11 @@ var (
 
 // CRMServiceImpl CRM域服务实现
 type CRMServiceImpl struct {
-	q         *query.Query
-	walletSvc WalletPort
+	q            *query.Query
+	db           *gorm.DB
+	walletSvc    WalletPort
+	customFields *CustomFieldServiceImpl
+	tags         *CustomerTagServiceImpl
 }
 
 // WalletPort 钱包服务端口接口 - 最小化依赖
@@ -41,9 +45,14 @@ type WalletPort interface {
 
 // NewCRMService 创建 CRM 服务实例
 func NewCRMService(q *query.Query, walletSvc WalletPort) *CRMServiceImpl {
+	// 自定义字段与标签字典表不在 gen 模型中，复用 q 的连接并清除其上的 customers 模型
+	db := q.Customer.UnderlyingDB().Session(&gorm.Session{NewDB: true, Initialized: true})
 	return &CRMServiceImpl{
-		q:         q,
-		walletSvc: walletSvc,
+		q:            q,
+		db:           db,
+		walletSvc:    walletSvc,
+		customFields: NewCustomFieldService(db),
+		tags:         NewCustomerTagService(db),
 	}
 }
 
@@ -76,6 +85,22 @@ func (s *CRMServiceImpl) toCustomerResponse(customer *model.Customer) *crm.Custo
 	}
 }
 
+// attachCustomFields 为客户响应填充自定义字段值
+func (s *CRMServiceImpl) attachCustomFields(ctx context.Context, responses ...*crm.CustomerResponse) error {
+	ids := make([]int64, len(responses))
+	for i, r := range responses {
+		ids[i] = r.ID
+	}
+	values, err := s.customFields.loadValues(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, r := range responses {
+		r.CustomFields = values[r.ID]
+	}
+	return nil
+}
+
 func (s *CRMServiceImpl) toContactResponse(c *model.Contact) *crm.ContactResponse {
 	return &crm.ContactResponse{
 		ID:         c.ID,
@@ -172,8 +197,27 @@ func (s *CRMServiceImpl) CreateCustomerLegacy(ctx context.Context, req *crm.Cust
 	var customerToReturn *model.Customer
 	isNewCreation := false
 
+	customValues, err := s.customFields.normalizeValues(ctx, req.CustomFields, true)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.tags.normalizeTags(ctx, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+
 	if req.Phone != "" {
 		existingCustomer, err := s.q.Customer.WithContext(ctx).Unscoped().Where(s.q.Customer.Phone.Eq(req.Phone)).First()
+		if err == nil && existingCustomer.DeletedAt.Valid {
+			// 已合并到其他客户的客户不恢复，手机号仍被其占用
+			survivorID, err := mergedInto(ctx, s.db, existingCustomer.ID)
+			if err != nil {
+				return nil, err
+			}
+			if survivorID > 0 {
+				return nil, ErrPhoneAlreadyExists
+			}
+		}
 		if err == nil {
 			if existingCustomer.DeletedAt.Valid {
 				// 恢复并更新
@@ -182,7 +226,7 @@ func (s *CRMServiceImpl) CreateCustomerLegacy(ctx context.Context, req *crm.Cust
 				existingCustomer.Gender = req.Gender
 				existingCustomer.Level = req.Level
 
-				tagsJSON, err := json.Marshal(req.Tags)
+				tagsJSON, err := json.Marshal(tags)
 				if err != nil {
 					return nil, fmt.Errorf("failed to marshal tags: %w", err)
 				}
@@ -237,7 +281,7 @@ func (s *CRMServiceImpl) CreateCustomerLegacy(ctx context.Context, req *crm.Cust
 			AssignedTo: req.AssignedTo,
 		}
 
-		tagsJSON, err := json.Marshal(req.Tags)
+		tagsJSON, err := json.Marshal(tags)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal tags: %w", err)
 		}
@@ -257,6 +301,11 @@ func (s *CRMServiceImpl) CreateCustomerLegacy(ctx context.Context, req *crm.Cust
 		isNewCreation = true
 	}
 
+	// 恢复的客户与新建一致，不保留删除前的自定义字段值
+	if err := s.customFields.saveValues(ctx, customerToReturn.ID, customValues, !isNewCreation); err != nil {
+		return nil, err
+	}
+
 	// 创建钱包
 	if _, err := s.walletSvc.CreateWallet(ctx, customerToReturn.ID, "balance"); err != nil {
 		if isNewCreation {
@@ -264,7 +313,11 @@ func (s *CRMServiceImpl) CreateCustomerLegacy(ctx context.Context, req *crm.Cust
 		}
 	}
 
-	return s.toCustomerResponse(customerToReturn), nil
+	resp := s.toCustomerResponse(customerToReturn)
+	if err := s.attachCustomFields(ctx, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 // GetCustomerByIDLegacy 根据 ID 获取客户
@@ -285,26 +338,19 @@ func (s *CRMServiceImpl) GetCustomerByIDLegacy(ctx context.Context, id string) (
 	if balance, errW := s.walletSvc.GetWalletByCustomerID(ctx, customer.ID); errW == nil {
 		resp.WalletBalance = balance
 	}
+	if err := s.attachCustomFields(ctx, resp); err != nil {
+		return nil, err
+	}
 	return resp, nil
 }
 
 // ListCustomersLegacy 获取客户列表
 func (s *CRMServiceImpl) ListCustomersLegacy(ctx context.Context, req *crm.CustomerListRequest) (*crm.CustomerListResponse, error) {
-	q := s.q.Customer.WithContext(ctx).Where(s.q.Customer.DeletedAt.IsNull())
-
-	if len(req.IDs) > 0 {
-		q = q.Where(s.q.Customer.ID.In(req.IDs...))
-	} else {
-		if req.Name != "" {
-			q = q.Where(s.q.Customer.Name.Like("%" + req.Name + "%"))
-		}
-		if req.Phone != "" {
-			q = q.Where(s.q.Customer.Phone.Eq(req.Phone))
-		}
-		if req.Email != "" {
-			q = q.Where(s.q.Customer.Email.Eq(req.Email))
-		}
+	conds, err := customerListConds(ctx, s.q, s.customFields, s.tags, req)
+	if err != nil {
+		return nil, err
 	}
+	q := s.q.Customer.WithContext(ctx).Where(conds...)
 
 	if req.OrderBy != "" {
 		parts := strings.Split(req.OrderBy, "_")
@@ -346,6 +392,11 @@ func (s *CRMServiceImpl) ListCustomersLegacy(ctx context.Context, req *crm.Custo
 		}
 		customerResponses = append(customerResponses, resp)
 	}
+	if len(customerResponses) > 0 {
+		if err := s.attachCustomFields(ctx, customerResponses...); err != nil {
+			return nil, err
+		}
+	}
 
 	return &crm.CustomerListResponse{
 		Total:     total,
@@ -353,6 +404,39 @@ func (s *CRMServiceImpl) ListCustomersLegacy(ctx context.Context, req *crm.Custo
 	}, nil
 }
 
+// customerListConds 构建客户列表的筛选条件，批量打标签复用同一套条件；指定 IDs 时忽略其他筛选条件
+func customerListConds(ctx context.Context, q *query.Query, customFields *CustomFieldServiceImpl, tags *CustomerTagServiceImpl, req *crm.CustomerListRequest) ([]gen.Condition, error) {
+	conds := []gen.Condition{q.Customer.DeletedAt.IsNull()}
+	if len(req.IDs) > 0 {
+		return append(conds, q.Customer.ID.In(req.IDs...)), nil
+	}
+
+	if req.Name != "" {
+		conds = append(conds, q.Customer.Name.Like("%"+req.Name+"%"))
+	}
+	if req.Phone != "" {
+		conds = append(conds, q.Customer.Phone.Eq(req.Phone))
+	}
+	if req.Email != "" {
+		conds = append(conds, q.Customer.Email.Eq(req.Email))
+	}
+	fieldConds, err := customFields.filterConditions(ctx, req.CustomFields)
+	if err != nil {
+		return nil, err
+	}
+	conds = append(conds, fieldConds...)
+	if len(req.Tags) > 0 {
+		tagCond, err := tags.filterCondition(ctx, req.Tags, req.TagMatchAll)
+		if err != nil {
+			return nil, err
+		}
+		if tagCond != nil {
+			conds = append(conds, tagCond)
+		}
+	}
+	return conds, nil
+}
+
 // UpdateCustomerLegacy 更新客户
 func (s *CRMServiceImpl) UpdateCustomerLegacy(ctx context.Context, id string, req *crm.CustomerUpdateRequest) error {
 	idNum, errConv := strconv.ParseInt(id, 10, 64)
@@ -360,6 +444,11 @@ func (s *CRMServiceImpl) UpdateCustomerLegacy(ctx context.Context, id string, re
 		return ErrCustomerNotFound
 	}
 
+	customValues, err := s.customFields.normalizeValues(ctx, req.CustomFields, false)
+	if err != nil {
+		return err
+	}
+
 	if req.Phone != "" {
 		count, err := s.q.Customer.WithContext(ctx).
 			Where(s.q.Customer.Phone.Eq(req.Phone), s.q.Customer.ID.Neq(idNum)).
@@ -390,7 +479,11 @@ func (s *CRMServiceImpl) UpdateCustomerLegacy(ctx context.Context, id string, re
 		updates["level"] = req.Level
 	}
 	if req.Tags != nil {
-		tagsJSON, err := json.Marshal(*req.Tags)
+		tags, err := s.tags.normalizeTags(ctx, *req.Tags)
+		if err != nil {
+			return err
+		}
+		tagsJSON, err := json.Marshal(tags)
 		if err != nil {
 			return fmt.Errorf("failed to marshal tags: %w", err)
 		}
@@ -413,18 +506,28 @@ func (s *CRMServiceImpl) UpdateCustomerLegacy(ctx context.Context, id string, re
 		updates["birthday"] = birthday
 	}
 
-	if len(updates) == 0 {
+	if len(updates) == 0 && len(customValues) == 0 {
 		return nil
 	}
 
-	result, err := s.q.Customer.WithContext(ctx).Where(s.q.Customer.ID.Eq(idNum)).Updates(updates)
-	if err != nil {
-		return err
-	}
-	if result.RowsAffected == 0 {
-		return ErrCustomerNotFound
+	if len(updates) > 0 {
+		result, err := s.q.Customer.WithContext(ctx).Where(s.q.Customer.ID.Eq(idNum)).Updates(updates)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return ErrCustomerNotFound
+		}
+	} else {
+		count, err := s.q.Customer.WithContext(ctx).Where(s.q.Customer.ID.Eq(idNum)).Count()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrCustomerNotFound
+		}
 	}
-	return nil
+	return s.customFields.saveValues(ctx, idNum, customValues, false)
 }
 
 // DeleteCustomerLegacy 删除客户
@@ -0,0 +1,554 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/crm"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	customFieldLabelMaxLen  = 50   // 与 customer_custom_fields.label 列宽一致
+	customFieldOptionMaxLen = 50   // 单个可选项最大长度
+	customFieldMaxOptions   = 100  // 可选项数量上限
+	customFieldTextMaxLen   = 500  // text 类型字段值最大长度
+	customFieldValueMaxLen  = 1024 // 与 customer_custom_field_values.value 列宽一致
+)
+
+// customFieldKeyPattern 字段标识格式，与 customer_custom_fields.field_key 列宽一致
+var customFieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// CustomFieldServiceImpl 客户自定义字段服务实现
+// 字段定义保存在 customer_custom_fields，客户的字段值保存在 customer_custom_field_values
+type CustomFieldServiceImpl struct {
+	db *gorm.DB
+}
+
+// NewCustomFieldService 创建客户自定义字段服务实例
+func NewCustomFieldService(db *gorm.DB) *CustomFieldServiceImpl {
+	return &CustomFieldServiceImpl{db: db}
+}
+
+// CreateField 创建自定义字段
+func (s *CustomFieldServiceImpl) CreateField(ctx context.Context, req crm.CreateCustomFieldReq) (*crm.CustomField, error) {
+	if !customFieldKeyPattern.MatchString(req.Key) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "字段标识须以小写字母开头，仅含小写字母、数字和下划线，且不超过50个字符")
+	}
+	if !containsString(crm.ValidCustomFieldTypes(), req.Type) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的字段类型: %s", req.Type))
+	}
+	if err := validateCustomFieldLabel(req.Label); err != nil {
+		return nil, err
+	}
+	options, err := normalizeCustomFieldOptions(req.Type, req.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&CustomFieldRecord{}).Where("field_key = ?", req.Key).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询自定义字段失败: %w", err)
+	}
+	if count > 0 {
+		return nil, common.NewBusinessError(common.ErrCodeCustomFieldExists, fmt.Sprintf("字段标识已存在: %s", req.Key))
+	}
+
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, fmt.Errorf("序列化可选项失败: %w", err)
+	}
+	now := time.Now().Unix()
+	record := &CustomFieldRecord{
+		Key:       req.Key,
+		Label:     strings.TrimSpace(req.Label),
+		Type:      req.Type,
+		Options:   string(optionsJSON),
+		Required:  req.Required,
+		Sort:      req.Sort,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建自定义字段失败: %w", err)
+	}
+	return toCustomField(record), nil
+}
+
+// GetField 获取自定义字段
+func (s *CustomFieldServiceImpl) GetField(ctx context.Context, fieldID int64) (*crm.CustomField, error) {
+	record, err := s.getFieldRecord(ctx, fieldID)
+	if err != nil {
+		return nil, err
+	}
+	return toCustomField(record), nil
+}
+
+// UpdateField 更新自定义字段，Key 与类型不可修改
+func (s *CustomFieldServiceImpl) UpdateField(ctx context.Context, req crm.UpdateCustomFieldReq) (*crm.CustomField, error) {
+	record, err := s.getFieldRecord(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.Label != nil {
+		if err := validateCustomFieldLabel(*req.Label); err != nil {
+			return nil, err
+		}
+		updates["label"] = strings.TrimSpace(*req.Label)
+	}
+	if req.Options != nil {
+		options, err := normalizeCustomFieldOptions(record.Type, *req.Options)
+		if err != nil {
+			return nil, err
+		}
+		optionsJSON, err := json.Marshal(options)
+		if err != nil {
+			return nil, fmt.Errorf("序列化可选项失败: %w", err)
+		}
+		updates["options"] = string(optionsJSON)
+	}
+	if req.Required != nil {
+		updates["required"] = *req.Required
+	}
+	if req.Sort != nil {
+		updates["sort"] = *req.Sort
+	}
+	if len(updates) == 0 {
+		return toCustomField(record), nil
+	}
+
+	updates["updated_at"] = time.Now().Unix()
+	if err := s.db.WithContext(ctx).Model(&CustomFieldRecord{}).Where("id = ?", req.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新自定义字段失败: %w", err)
+	}
+	return s.GetField(ctx, req.ID)
+}
+
+// DeleteField 删除自定义字段及全部客户的该字段值
+func (s *CustomFieldServiceImpl) DeleteField(ctx context.Context, fieldID int64) error {
+	if _, err := s.getFieldRecord(ctx, fieldID); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", fieldID).Delete(&CustomFieldValueRecord{}).Error; err != nil {
+			return fmt.Errorf("删除自定义字段值失败: %w", err)
+		}
+		if err := tx.Delete(&CustomFieldRecord{}, fieldID).Error; err != nil {
+			return fmt.Errorf("删除自定义字段失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// ListFields 按排序返回全部自定义字段
+func (s *CustomFieldServiceImpl) ListFields(ctx context.Context) ([]crm.CustomField, error) {
+	var records []CustomFieldRecord
+	if err := s.db.WithContext(ctx).Order("sort ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询自定义字段失败: %w", err)
+	}
+	fields := make([]crm.CustomField, len(records))
+	for i := range records {
+		fields[i] = *toCustomField(&records[i])
+	}
+	return fields, nil
+}
+
+func (s *CustomFieldServiceImpl) getFieldRecord(ctx context.Context, fieldID int64) (*CustomFieldRecord, error) {
+	var record CustomFieldRecord
+	if err := s.db.WithContext(ctx).First(&record, fieldID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCustomFieldNotFound, "自定义字段不存在")
+		}
+		return nil, fmt.Errorf("查询自定义字段失败: %w", err)
+	}
+	return &record, nil
+}
+
+// loadFieldsByKey 读取全部字段定义，按 Key 索引
+func (s *CustomFieldServiceImpl) loadFieldsByKey(ctx context.Context) (map[string]*CustomFieldRecord, error) {
+	var records []CustomFieldRecord
+	if err := s.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询自定义字段失败: %w", err)
+	}
+	fields := make(map[string]*CustomFieldRecord, len(records))
+	for i := range records {
+		fields[records[i].Key] = &records[i]
+	}
+	return fields, nil
+}
+
+// normalizeValues 校验客户的自定义字段值并编码为存储文本
+// 返回字段ID到编码值的映射，nil 表示清空该字段；creating 为 true 时要求提供全部必填字段
+func (s *CustomFieldServiceImpl) normalizeValues(ctx context.Context, values map[string]interface{}, creating bool) (map[int64]*string, error) {
+	if len(values) == 0 && !creating {
+		return nil, nil
+	}
+	fields, err := s.loadFieldsByKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(map[int64]*string, len(values))
+	for _, key := range keys {
+		field, ok := fields[key]
+		if !ok {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("未知的自定义字段: %s", key))
+		}
+		encoded, err := encodeCustomFieldValue(field, values[key])
+		if err != nil {
+			return nil, err
+		}
+		if encoded == "" {
+			if field.Required {
+				return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("自定义字段 %s 为必填项", field.Label))
+			}
+			result[field.ID] = nil
+			continue
+		}
+		result[field.ID] = &encoded
+	}
+
+	if creating {
+		for _, field := range fields {
+			if field.Required && result[field.ID] == nil {
+				return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("自定义字段 %s 为必填项", field.Label))
+			}
+		}
+	}
+	return result, nil
+}
+
+// saveValues 写入 normalizeValues 的结果，replace 为 true 时先清空客户的全部字段值
+func (s *CustomFieldServiceImpl) saveValues(ctx context.Context, customerID int64, values map[int64]*string, replace bool) error {
+	if len(values) == 0 && !replace {
+		return nil
+	}
+	now := time.Now().Unix()
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if replace {
+			if err := tx.Where("customer_id = ?", customerID).Delete(&CustomFieldValueRecord{}).Error; err != nil {
+				return fmt.Errorf("清空自定义字段值失败: %w", err)
+			}
+		}
+		for fieldID, value := range values {
+			if value == nil {
+				if err := tx.Where("customer_id = ? AND field_id = ?", customerID, fieldID).Delete(&CustomFieldValueRecord{}).Error; err != nil {
+					return fmt.Errorf("清空自定义字段值失败: %w", err)
+				}
+				continue
+			}
+			record := &CustomFieldValueRecord{
+				CustomerID: customerID,
+				FieldID:    fieldID,
+				Value:      *value,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "customer_id"}, {Name: "field_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+			}).Create(record).Error; err != nil {
+				return fmt.Errorf("保存自定义字段值失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// loadValues 批量读取客户的自定义字段值，返回客户ID到 Key→值 的映射
+func (s *CustomFieldServiceImpl) loadValues(ctx context.Context, customerIDs []int64) (map[int64]map[string]interface{}, error) {
+	result := make(map[int64]map[string]interface{})
+	if len(customerIDs) == 0 {
+		return result, nil
+	}
+	var values []CustomFieldValueRecord
+	if err := s.db.WithContext(ctx).Where("customer_id IN ?", customerIDs).Find(&values).Error; err != nil {
+		return nil, fmt.Errorf("查询自定义字段值失败: %w", err)
+	}
+	if len(values) == 0 {
+		return result, nil
+	}
+
+	var records []CustomFieldRecord
+	if err := s.db.WithContext(ctx).Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询自定义字段失败: %w", err)
+	}
+	fields := make(map[int64]*CustomFieldRecord, len(records))
+	for i := range records {
+		fields[records[i].ID] = &records[i]
+	}
+
+	for _, v := range values {
+		field, ok := fields[v.FieldID]
+		if !ok {
+			continue
+		}
+		if result[v.CustomerID] == nil {
+			result[v.CustomerID] = make(map[string]interface{})
+		}
+		result[v.CustomerID][field.Key] = decodeCustomFieldValue(field, v.Value)
+	}
+	return result, nil
+}
+
+// filterConditions 将自定义字段筛选条件转换为客户表的查询条件
+// text 模糊匹配，multi_select 包含该选项，其余类型精确匹配；空值的条件忽略
+func (s *CustomFieldServiceImpl) filterConditions(ctx context.Context, filters map[string]string) ([]gen.Condition, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	fields, err := s.loadFieldsByKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conds := make([]gen.Condition, 0, len(keys))
+	for _, key := range keys {
+		customField, ok := fields[key]
+		if !ok {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("未知的自定义字段: %s", key))
+		}
+		value := strings.TrimSpace(filters[key])
+		if value == "" {
+			continue
+		}
+
+		cond, arg := "value = ?", value
+		switch customField.Type {
+		case crm.CustomFieldTypeText:
+			cond, arg = "value LIKE ?", "%"+value+"%"
+		case crm.CustomFieldTypeMultiSelect:
+			// 多选值以 JSON 数组保存，按带引号的选项匹配以避免命中其他选项的子串
+			quoted, _ := json.Marshal(value)
+			cond, arg = "value LIKE ?", "%"+string(quoted)+"%"
+		case crm.CustomFieldTypeNumber, crm.CustomFieldTypeDate:
+			if arg, err = encodeCustomFieldValue(customField, value); err != nil {
+				return nil, err
+			}
+		}
+		conds = append(conds, field.NewUnsafeFieldRaw(
+			"customers.id IN (SELECT customer_id FROM customer_custom_field_values WHERE field_id = ? AND "+cond+")",
+			customField.ID, arg))
+	}
+	return conds, nil
+}
+
+func validateCustomFieldLabel(label string) error {
+	label = strings.TrimSpace(label)
+	if label == "" || utf8.RuneCountInString(label) > customFieldLabelMaxLen {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("字段名称不能为空且不超过%d个字符", customFieldLabelMaxLen))
+	}
+	return nil
+}
+
+// normalizeCustomFieldOptions 校验可选项并去除首尾空白，非选项类型返回空列表
+func normalizeCustomFieldOptions(typ string, options []string) ([]string, error) {
+	if !crm.CustomFieldHasOptions(typ) {
+		if len(options) > 0 {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("%s 类型的字段不支持可选项", typ))
+		}
+		return []string{}, nil
+	}
+	if len(options) == 0 || len(options) > customFieldMaxOptions {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("单选和多选字段须有1到%d个可选项", customFieldMaxOptions))
+	}
+
+	result := make([]string, 0, len(options))
+	for _, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > customFieldOptionMaxLen {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("可选项不能为空且不超过%d个字符", customFieldOptionMaxLen))
+		}
+		if containsString(result, option) {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("可选项重复: %s", option))
+		}
+		result = append(result, option)
+	}
+	return result, nil
+}
+
+// encodeCustomFieldValue 按字段类型校验值并编码为存储文本，nil 或空值返回空字符串
+// 数字编码为最短十进制串，多选编码为去重后的 JSON 数组
+func encodeCustomFieldValue(field *CustomFieldRecord, raw interface{}) (string, error) {
+	if raw == nil {
+		return "", nil
+	}
+	invalid := func(expect string) error {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("自定义字段 %s 须为%s", field.Label, expect))
+	}
+
+	var encoded string
+	switch field.Type {
+	case crm.CustomFieldTypeText:
+		v, ok := raw.(string)
+		if !ok {
+			return "", invalid("文本")
+		}
+		encoded = strings.TrimSpace(v)
+		if utf8.RuneCountInString(encoded) > customFieldTextMaxLen {
+			return "", invalid(fmt.Sprintf("不超过%d个字符的文本", customFieldTextMaxLen))
+		}
+	case crm.CustomFieldTypeNumber:
+		var f float64
+		switch v := raw.(type) {
+		case float64:
+			f = v
+		case int:
+			f = float64(v)
+		case int64:
+			f = float64(v)
+		case json.Number:
+			parsed, err := v.Float64()
+			if err != nil {
+				return "", invalid("数字")
+			}
+			f = parsed
+		case string:
+			v = strings.TrimSpace(v)
+			if v == "" {
+				return "", nil
+			}
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return "", invalid("数字")
+			}
+			f = parsed
+		default:
+			return "", invalid("数字")
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", invalid("数字")
+		}
+		encoded = strconv.FormatFloat(f, 'f', -1, 64)
+	case crm.CustomFieldTypeDate:
+		v, ok := raw.(string)
+		if !ok {
+			return "", invalid("YYYY-MM-DD 格式的日期")
+		}
+		encoded = strings.TrimSpace(v)
+		if encoded != "" {
+			if _, err := time.Parse("2006-01-02", encoded); err != nil {
+				return "", invalid("YYYY-MM-DD 格式的日期")
+			}
+		}
+	case crm.CustomFieldTypeEnum:
+		v, ok := raw.(string)
+		if !ok {
+			return "", invalid("可选项之一")
+		}
+		encoded = strings.TrimSpace(v)
+		if encoded != "" && !containsString(customFieldOptions(field), encoded) {
+			return "", invalid("可选项之一")
+		}
+	case crm.CustomFieldTypeMultiSelect:
+		var items []string
+		switch v := raw.(type) {
+		case []string:
+			items = v
+		case []interface{}:
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return "", invalid("可选项组成的数组")
+				}
+				items = append(items, s)
+			}
+		default:
+			return "", invalid("可选项组成的数组")
+		}
+		options := customFieldOptions(field)
+		selected := make([]string, 0, len(items))
+		for _, item := range items {
+			item = strings.TrimSpace(item)
+			if !containsString(options, item) {
+				return "", invalid("可选项组成的数组")
+			}
+			if !containsString(selected, item) {
+				selected = append(selected, item)
+			}
+		}
+		if len(selected) == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(selected)
+		if err != nil {
+			return "", fmt.Errorf("序列化自定义字段值失败: %w", err)
+		}
+		encoded = string(data)
+	default:
+		return "", invalid(field.Type)
+	}
+
+	if utf8.RuneCountInString(encoded) > customFieldValueMaxLen {
+		return "", common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("自定义字段 %s 的值过长", field.Label))
+	}
+	return encoded, nil
+}
+
+// decodeCustomFieldValue 将存储文本还原为响应值：number 为 float64，multi_select 为 []string，其余为 string
+func decodeCustomFieldValue(field *CustomFieldRecord, value string) interface{} {
+	switch field.Type {
+	case crm.CustomFieldTypeNumber:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case crm.CustomFieldTypeMultiSelect:
+		var items []string
+		if err := json.Unmarshal([]byte(value), &items); err == nil {
+			return items
+		}
+	}
+	return value
+}
+
+func customFieldOptions(field *CustomFieldRecord) []string {
+	var options []string
+	_ = json.Unmarshal([]byte(field.Options), &options)
+	return options
+}
+
+func toCustomField(r *CustomFieldRecord) *crm.CustomField {
+	options := customFieldOptions(r)
+	if options == nil {
+		options = []string{}
+	}
+	return &crm.CustomField{
+		ID:        r.ID,
+		Key:       r.Key,
+		Label:     r.Label,
+		Type:      r.Type,
+		Options:   options,
+		Required:  r.Required,
+		Sort:      r.Sort,
+		CreatedAt: r.CreatedAt,
+		UpdatedAt: r.UpdatedAt,
+	}
+}
@@ -0,0 +1,164 @@
+package impl
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+	billingimpl "crm_lite/internal/domains/billing/impl"
+	"crm_lite/internal/domains/crm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCustomFields 客户自定义字段：字段定义校验、创建/更新客户时的取值校验与必填、列表筛选、删除字段清理字段值
+func TestCustomFields(t *testing.T) {
+	db := newMergeTestDB(t)
+	ctx := context.Background()
+	fieldSvc := NewCustomFieldService(db)
+	billingSvc := billingimpl.NewBillingServiceForMode(db, common.NewTx(db), billing.StoreModeLegacy)
+	crmSvc := NewCRMServiceWithBilling(db, billingSvc)
+
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+
+	hairType, err := fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{
+		Key: "hair_type", Label: "发质", Type: crm.CustomFieldTypeEnum, Options: []string{" 干性", "油性"}, Required: true, Sort: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"干性", "油性"}, hairType.Options)
+	_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "car_plate", Label: "车牌号", Type: crm.CustomFieldTypeText, Sort: 1})
+	require.NoError(t, err)
+	_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "pet_count", Label: "宠物数量", Type: crm.CustomFieldTypeNumber, Sort: 3})
+	require.NoError(t, err)
+	_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "first_visit", Label: "首次到店", Type: crm.CustomFieldTypeDate, Sort: 4})
+	require.NoError(t, err)
+	services, err := fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{
+		Key: "services", Label: "偏好服务", Type: crm.CustomFieldTypeMultiSelect, Options: []string{"洗护", "染发", "烫发"}, Sort: 5,
+	})
+	require.NoError(t, err)
+
+	t.Run("字段定义校验", func(t *testing.T) {
+		_, err := fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "hair_type", Label: "发质", Type: crm.CustomFieldTypeText})
+		assertCode(t, err, common.ErrCodeCustomFieldExists)
+		_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "Hair-Type", Label: "发质", Type: crm.CustomFieldTypeText})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "color", Label: "颜色", Type: crm.CustomFieldTypeEnum})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "color", Label: "颜色", Type: crm.CustomFieldTypeEnum, Options: []string{"红", "红"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "note2", Label: "备注", Type: crm.CustomFieldTypeText, Options: []string{"a"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = fieldSvc.CreateField(ctx, crm.CreateCustomFieldReq{Key: "color", Label: "颜色", Type: "color"})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		options := []string{"干性", "油性", "混合性"}
+		updated, err := fieldSvc.UpdateField(ctx, crm.UpdateCustomFieldReq{ID: hairType.ID, Options: &options})
+		require.NoError(t, err)
+		assert.Equal(t, options, updated.Options)
+		assert.Equal(t, "发质", updated.Label)
+
+		fields, err := fieldSvc.ListFields(ctx)
+		require.NoError(t, err)
+		require.Len(t, fields, 5)
+		assert.Equal(t, "car_plate", fields[0].Key, "按排序返回")
+	})
+
+	var customerID int64
+	t.Run("创建客户校验字段值", func(t *testing.T) {
+		_, err := crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "缺必填", Phone: "13800000011"})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "未知字段", Phone: "13800000011",
+			CustomFields: map[string]interface{}{"hair_type": "干性", "unknown": "x"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "非法选项", Phone: "13800000011",
+			CustomFields: map[string]interface{}{"hair_type": "卷发"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "非法日期", Phone: "13800000011",
+			CustomFields: map[string]interface{}{"hair_type": "干性", "first_visit": "2024/01/02"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		created, err := crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "王小美", Phone: "13800000011",
+			CustomFields: map[string]interface{}{
+				"hair_type":   "干性",
+				"car_plate":   "沪A12345",
+				"pet_count":   "2",
+				"first_visit": "2024-01-02",
+				"services":    []interface{}{"染发", "洗护", "染发"},
+			}})
+		require.NoError(t, err)
+		customerID = created.ID
+		assert.Equal(t, map[string]interface{}{
+			"hair_type":   "干性",
+			"car_plate":   "沪A12345",
+			"pet_count":   float64(2),
+			"first_visit": "2024-01-02",
+			"services":    []string{"染发", "洗护"},
+		}, created.CustomFields)
+
+		_, err = crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "李小花", Phone: "13800000012",
+			CustomFields: map[string]interface{}{"hair_type": "油性", "car_plate": "京B67890", "pet_count": 1.5, "services": []string{"烫发"}}})
+		require.NoError(t, err)
+	})
+
+	t.Run("更新客户只修改提供的字段", func(t *testing.T) {
+		id := strconv.FormatInt(customerID, 10)
+		err := crmSvc.UpdateCustomerLegacy(ctx, id, &crm.CustomerUpdateRequest{
+			CustomFields: map[string]interface{}{"car_plate": nil, "pet_count": 3},
+		})
+		require.NoError(t, err)
+		err = crmSvc.UpdateCustomerLegacy(ctx, id, &crm.CustomerUpdateRequest{CustomFields: map[string]interface{}{"hair_type": nil}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		err = crmSvc.UpdateCustomerLegacy(ctx, id, &crm.CustomerUpdateRequest{CustomFields: map[string]interface{}{"pet_count": "两只"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		err = crmSvc.UpdateCustomerLegacy(ctx, "9999", &crm.CustomerUpdateRequest{CustomFields: map[string]interface{}{"pet_count": 1}})
+		assert.ErrorIs(t, err, ErrCustomerNotFound)
+
+		customer, err := crmSvc.GetCustomerByIDLegacy(ctx, id)
+		require.NoError(t, err)
+		assert.NotContains(t, customer.CustomFields, "car_plate")
+		assert.Equal(t, float64(3), customer.CustomFields["pet_count"])
+		assert.Equal(t, "干性", customer.CustomFields["hair_type"])
+	})
+
+	t.Run("按自定义字段筛选客户列表", func(t *testing.T) {
+		list := func(filters map[string]string) []string {
+			result, err := crmSvc.ListCustomersLegacy(ctx, &crm.CustomerListRequest{Page: 1, PageSize: 10, CustomFields: filters})
+			require.NoError(t, err)
+			names := make([]string, 0, len(result.Customers))
+			for _, c := range result.Customers {
+				names = append(names, c.Name)
+			}
+			return names
+		}
+		assert.Equal(t, []string{"李小花"}, list(map[string]string{"car_plate": "B678"}))
+		assert.Equal(t, []string{"王小美"}, list(map[string]string{"hair_type": "干性"}))
+		assert.Equal(t, []string{"王小美"}, list(map[string]string{"services": "洗护"}))
+		assert.Equal(t, []string{"李小花"}, list(map[string]string{"pet_count": "1.50"}))
+		assert.Empty(t, list(map[string]string{"hair_type": "干性", "services": "烫发"}))
+		assert.Len(t, list(map[string]string{"hair_type": ""}), 2, "空值条件忽略")
+
+		_, err := crmSvc.ListCustomersLegacy(ctx, &crm.CustomerListRequest{Page: 1, PageSize: 10, CustomFields: map[string]string{"unknown": "x"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = crmSvc.ListCustomersLegacy(ctx, &crm.CustomerListRequest{Page: 1, PageSize: 10, CustomFields: map[string]string{"pet_count": "abc"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+	})
+
+	t.Run("删除字段清理字段值", func(t *testing.T) {
+		require.NoError(t, fieldSvc.DeleteField(ctx, services.ID))
+		var count int64
+		require.NoError(t, db.Model(&CustomFieldValueRecord{}).Where("field_id = ?", services.ID).Count(&count).Error)
+		assert.Zero(t, count)
+		assertCode(t, fieldSvc.DeleteField(ctx, services.ID), common.ErrCodeCustomFieldNotFound)
+
+		customer, err := crmSvc.GetCustomerByIDLegacy(ctx, strconv.FormatInt(customerID, 10))
+		require.NoError(t, err)
+		assert.NotContains(t, customer.CustomFields, "services")
+	})
+}
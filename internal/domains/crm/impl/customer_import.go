@@ -0,0 +1,486 @@
+package impl
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains"
+	"crm_lite/internal/domains/billing"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/dto"
+	"crm_lite/internal/validators"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// 导入列，对应 dto.CustomerCreateRequest 的字段，另加期初余额
+const (
+	importColumnName           = "name"
+	importColumnPhone          = "phone"
+	importColumnEmail          = "email"
+	importColumnGender         = "gender"
+	importColumnBirthday       = "birthday"
+	importColumnLevel          = "level"
+	importColumnTags           = "tags"
+	importColumnNote           = "note"
+	importColumnSource         = "source"
+	importColumnAssignedTo     = "assigned_to"
+	importColumnOpeningBalance = "opening_balance"
+)
+
+// importColumnAliases 表头别名，表头忽略大小写与首尾空白
+var importColumnAliases = map[string]string{
+	"name": importColumnName, "姓名": importColumnName, "客户姓名": importColumnName,
+	"phone": importColumnPhone, "手机号": importColumnPhone, "手机": importColumnPhone, "电话": importColumnPhone,
+	"email": importColumnEmail, "邮箱": importColumnEmail,
+	"gender": importColumnGender, "性别": importColumnGender,
+	"birthday": importColumnBirthday, "生日": importColumnBirthday,
+	"level": importColumnLevel, "等级": importColumnLevel, "客户等级": importColumnLevel,
+	"tags": importColumnTags, "标签": importColumnTags,
+	"note": importColumnNote, "备注": importColumnNote,
+	"source": importColumnSource, "来源": importColumnSource,
+	"assigned_to": importColumnAssignedTo, "负责人id": importColumnAssignedTo,
+	"opening_balance": importColumnOpeningBalance, "期初余额": importColumnOpeningBalance,
+}
+
+// importGenderAliases 性别的中文写法
+var importGenderAliases = map[string]string{"男": "male", "女": "female", "未知": "unknown"}
+
+// CustomerImportServiceImpl 客户批量导入服务实现
+// 校验沿用创建客户接口的绑定规则（含自定义校验器）与 CustomerDomain.Validate，期初余额通过 billing 入账
+type CustomerImportServiceImpl struct {
+	tx      common.Tx
+	billing billing.Service
+}
+
+// NewCustomerImportService 创建客户导入服务实例
+// 校验依赖注册到 gin 绑定引擎的 mobile 与客户自定义校验器，调用方需先完成注册
+func NewCustomerImportService(tx common.Tx, billingSvc billing.Service) *CustomerImportServiceImpl {
+	return &CustomerImportServiceImpl{
+		tx:      tx,
+		billing: billingSvc,
+	}
+}
+
+// importRow 解析后的数据行
+type importRow struct {
+	line           int
+	req            dto.CustomerCreateRequest
+	provided       map[string]bool // 该行填写了的列
+	openingBalance int64
+}
+
+// ImportCustomers 导入客户
+func (s *CustomerImportServiceImpl) ImportCustomers(ctx context.Context, r io.Reader, opts crm.CustomerImportOptions) (*crm.CustomerImportReport, error) {
+	records, err := readImportRecords(r, opts.Format)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "导入文件为空")
+	}
+	columns, ignored := mapImportHeader(records[0])
+	if _, ok := columns[importColumnName]; !ok {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "导入文件缺少姓名列")
+	}
+	if _, ok := columns[importColumnPhone]; !ok {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "导入文件缺少手机号列")
+	}
+
+	// 标签按字典统一写法，字典在逐行事务之外读取一次
+	dictionary, err := loadCustomerTagDictionary(s.tx.GetDB(ctx).WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &crm.CustomerImportReport{DryRun: opts.DryRun, IgnoredColumns: ignored, Rows: []crm.CustomerImportRow{}}
+	seenPhones := make(map[string]int)
+	for i, record := range records[1:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		if report.Total >= crm.CustomerImportMaxRows {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam,
+				fmt.Sprintf("单次最多导入 %d 行", crm.CustomerImportMaxRows))
+		}
+		report.Total++
+
+		row, errs := parseImportRow(i+2, record, columns)
+		if tags, err := normalizeCustomerTags(row.req.Tags, dictionary); err != nil {
+			errs = append(errs, importErrorMessage(err))
+		} else {
+			row.req.Tags = tags
+		}
+		result := crm.CustomerImportRow{Row: row.line, Name: row.req.Name, Phone: row.req.Phone, OpeningBalance: row.openingBalance}
+		if len(errs) == 0 {
+			errs = validateImportRow(row)
+		}
+		if len(errs) == 0 && row.req.Phone != "" {
+			if first, ok := seenPhones[row.req.Phone]; ok {
+				errs = append(errs, fmt.Sprintf("手机号与第 %d 行重复", first))
+			} else {
+				seenPhones[row.req.Phone] = row.line
+			}
+		}
+		if len(errs) == 0 {
+			if row.req.AssignedTo == 0 {
+				row.req.AssignedTo = opts.DefaultAssignedTo
+			}
+			result.CustomerID, result.Action, err = s.importRow(ctx, row, opts)
+			if err != nil {
+				errs = append(errs, importErrorMessage(err))
+			}
+		}
+
+		if len(errs) > 0 {
+			result.Action, result.Errors = crm.ImportActionFailed, errs
+			report.Failed++
+		} else {
+			if result.Action == crm.ImportActionCreated {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+			report.OpeningBalance += row.openingBalance
+		}
+		report.Rows = append(report.Rows, result)
+	}
+	return report, nil
+}
+
+// importRow 按手机号新建或更新客户（已删除的客户恢复后更新，与创建客户接口一致；已合并的客户不恢复），并入账期初余额
+func (s *CustomerImportServiceImpl) importRow(ctx context.Context, row *importRow, opts crm.CustomerImportOptions) (int64, string, error) {
+	var customerID int64
+	action := crm.ImportActionCreated
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		var existing model.Customer
+		err := txDB.WithContext(ctx).Unscoped().Where("phone = ?", row.req.Phone).First(&existing).Error
+		switch {
+		case err == nil:
+			action, customerID = crm.ImportActionUpdated, existing.ID
+			if existing.DeletedAt.Valid {
+				// 已合并到其他客户的客户不恢复
+				survivorID, err := mergedInto(ctx, txDB, existing.ID)
+				if err != nil {
+					return err
+				}
+				if survivorID > 0 {
+					return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("该手机号的客户已合并到客户 %d", survivorID))
+				}
+			}
+			if opts.Authorize != nil {
+				allowed, err := opts.Authorize(ctx, existing.ID)
+				if err != nil {
+					return err
+				}
+				if !allowed {
+					return common.NewBusinessError(common.ErrCodePermissionDenied, "无权更新该客户")
+				}
+			}
+			if row.openingBalance > 0 {
+				if err := s.ensureNoOpeningBalance(ctx, existing.ID); err != nil {
+					return err
+				}
+			}
+			if opts.DryRun {
+				return nil
+			}
+			updates, err := importUpdates(row)
+			if err != nil {
+				return err
+			}
+			updates["deleted_at"] = nil
+			if err := txDB.WithContext(ctx).Unscoped().Model(&model.Customer{}).
+				Where("id = ?", existing.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新客户失败: %w", err)
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if opts.DryRun {
+				return nil
+			}
+			customer, err := importNewCustomer(row)
+			if err != nil {
+				return err
+			}
+			if err := txDB.WithContext(ctx).Create(customer).Error; err != nil {
+				return fmt.Errorf("创建客户失败: %w", err)
+			}
+			customerID = customer.ID
+		default:
+			return fmt.Errorf("查询客户失败: %w", err)
+		}
+
+		if row.openingBalance > 0 && !opts.DryRun {
+			return s.billing.Credit(ctx, customerID, row.openingBalance, "期初余额导入", openingBalanceIdemKey(customerID))
+		}
+		return nil
+	})
+	return customerID, action, err
+}
+
+// ensureNoOpeningBalance 校验客户尚未导入过期初余额
+// 期初余额按客户幂等入账，重复导入不会入账，须报告为失败而不是计入成功行的期初余额合计
+func (s *CustomerImportServiceImpl) ensureNoOpeningBalance(ctx context.Context, customerID int64) error {
+	_, err := s.billing.GetTransactionByIdemKey(ctx, openingBalanceIdemKey(customerID))
+	if err == nil {
+		return common.NewBusinessError(common.ErrCodeDuplicateTransaction, "该客户已导入过期初余额，不能重复入账；仅更新资料时请清空期初余额")
+	}
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) && businessErr.Code == common.ErrCodeResourceNotFound {
+		return nil
+	}
+	return fmt.Errorf("查询期初余额流水失败: %w", err)
+}
+
+// openingBalanceIdemKey 期初余额入账的幂等键，每个客户只入账一次
+func openingBalanceIdemKey(customerID int64) string {
+	return fmt.Sprintf("customer_import_opening_%d", customerID)
+}
+
+// readImportRecords 读取导入文件的全部行，XLSX 读取第一个工作表
+func readImportRecords(r io.Reader, format string) ([][]string, error) {
+	switch format {
+	case crm.ImportFormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("CSV 解析失败: %v", err))
+		}
+		if len(records) > 0 && len(records[0]) > 0 {
+			// Excel 另存的 CSV 带 UTF-8 BOM
+			records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+		}
+		return records, nil
+	case crm.ImportFormatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("XLSX 解析失败: %v", err))
+		}
+		defer func() { _ = f.Close() }()
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil
+		}
+		records, err := f.GetRows(sheets[0])
+		if err != nil {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("XLSX 读取失败: %v", err))
+		}
+		return records, nil
+	}
+	return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "仅支持 csv 或 xlsx 格式")
+}
+
+// mapImportHeader 将表头映射为导入列，返回列名到下标的映射及无法识别的表头
+func mapImportHeader(header []string) (map[string]int, []string) {
+	columns := make(map[string]int)
+	var ignored []string
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		column, ok := importColumnAliases[strings.ToLower(h)]
+		if !ok {
+			ignored = append(ignored, h)
+			continue
+		}
+		if _, dup := columns[column]; !dup {
+			columns[column] = i
+		}
+	}
+	return columns, ignored
+}
+
+// parseImportRow 按列映射解析一行，返回格式错误（如负责人ID、期初余额不是数字）
+func parseImportRow(line int, record []string, columns map[string]int) (*importRow, []string) {
+	row := &importRow{line: line, provided: make(map[string]bool)}
+	cell := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		v := strings.TrimSpace(record[i])
+		if v != "" {
+			row.provided[column] = true
+		}
+		return v
+	}
+
+	var errs []string
+	row.req.Name = cell(importColumnName)
+	row.req.Phone = cell(importColumnPhone)
+	if row.req.Phone != "" {
+		row.req.Phone = normalizePhone(row.req.Phone)
+	}
+	row.req.Email = cell(importColumnEmail)
+	row.req.Gender = cell(importColumnGender)
+	if g, ok := importGenderAliases[row.req.Gender]; ok {
+		row.req.Gender = g
+	}
+	row.req.Birthday = cell(importColumnBirthday)
+	row.req.Level = cell(importColumnLevel)
+	row.req.Note = cell(importColumnNote)
+	row.req.Source = cell(importColumnSource)
+	if tags := cell(importColumnTags); tags != "" {
+		for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == '，' || r == '、' || r == ';' || r == '；' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				row.req.Tags = append(row.req.Tags, tag)
+			}
+		}
+	}
+	if v := cell(importColumnAssignedTo); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			errs = append(errs, "负责人ID必须是非负整数")
+		}
+		row.req.AssignedTo = id
+	}
+	if v := cell(importColumnOpeningBalance); v != "" {
+		yuan, err := strconv.ParseFloat(v, 64)
+		if err != nil || yuan < 0 || math.IsInf(yuan, 0) || math.IsNaN(yuan) {
+			errs = append(errs, "期初余额必须是非负数字（元）")
+		} else {
+			row.openingBalance = int64(math.Round(yuan * 100))
+		}
+	}
+	return row, errs
+}
+
+// validateImportRow 先按创建客户接口的绑定规则校验，通过后再用 CustomerDomain.Validate 校验领域规则
+// 未填写的性别、等级按数据库默认值参与领域校验
+func validateImportRow(row *importRow) []string {
+	if err := binding.Validator.ValidateStruct(&row.req); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return []string{err.Error()}
+		}
+		errs := make([]string, 0, len(fieldErrs))
+		for _, fe := range fieldErrs {
+			errs = append(errs, validators.GetValidationErrorMessage(fe))
+		}
+		return errs
+	}
+
+	customer := &domains.CustomerDomain{
+		Name:       row.req.Name,
+		Phone:      row.req.Phone,
+		Email:      row.req.Email,
+		Gender:     row.req.Gender,
+		Level:      row.req.Level,
+		Tags:       row.req.Tags,
+		Source:     row.req.Source,
+		AssignedTo: row.req.AssignedTo,
+	}
+	if customer.Gender == "" {
+		customer.Gender = string(constants.CustomerGenderUnknown)
+	}
+	if customer.Level == "" {
+		customer.Level = string(constants.CustomerLevelNormal)
+	}
+	if err := customer.Validate(); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
+
+// importNewCustomer 由导入行构造新客户，未填写的性别、等级、来源取默认值
+func importNewCustomer(row *importRow) (*model.Customer, error) {
+	customer := &model.Customer{
+		Name:       row.req.Name,
+		Phone:      row.req.Phone,
+		Email:      row.req.Email,
+		Gender:     row.req.Gender,
+		Level:      row.req.Level,
+		Note:       row.req.Note,
+		Source:     row.req.Source,
+		AssignedTo: row.req.AssignedTo,
+	}
+	if customer.Gender == "" {
+		customer.Gender = string(constants.CustomerGenderUnknown)
+	}
+	if customer.Level == "" {
+		customer.Level = string(constants.CustomerLevelNormal)
+	}
+	if customer.Source == "" {
+		customer.Source = string(constants.CustomerSourceManual)
+	}
+	tags := row.req.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("序列化标签失败: %w", err)
+	}
+	customer.Tags = string(tagsJSON)
+	if row.req.Birthday != "" {
+		birthday, err := time.Parse("2006-01-02", row.req.Birthday)
+		if err != nil {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "生日格式应为 YYYY-MM-DD")
+		}
+		customer.Birthday = birthday
+	}
+	return customer, nil
+}
+
+// importUpdates 由导入行构造更新字段，只覆盖该行填写了的列，空单元格保留原值
+func importUpdates(row *importRow) (map[string]interface{}, error) {
+	updates := map[string]interface{}{"name": row.req.Name}
+	set := func(column string, value interface{}) {
+		if row.provided[column] {
+			updates[column] = value
+		}
+	}
+	set(importColumnEmail, row.req.Email)
+	set(importColumnGender, row.req.Gender)
+	set(importColumnLevel, row.req.Level)
+	set(importColumnNote, row.req.Note)
+	set(importColumnSource, row.req.Source)
+	set(importColumnAssignedTo, row.req.AssignedTo)
+	if row.provided[importColumnTags] {
+		tagsJSON, err := json.Marshal(row.req.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("序列化标签失败: %w", err)
+		}
+		updates["tags"] = string(tagsJSON)
+	}
+	if row.provided[importColumnBirthday] {
+		birthday, err := time.Parse("2006-01-02", row.req.Birthday)
+		if err != nil {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "生日格式应为 YYYY-MM-DD")
+		}
+		updates["birthday"] = birthday
+	}
+	return updates, nil
+}
+
+// importErrorMessage 行处理失败时写入报告的错误信息，业务错误只取提示信息
+func importErrorMessage(err error) string {
+	var businessErr *common.BusinessError
+	if errors.As(err, &businessErr) {
+		return businessErr.Message
+	}
+	return err.Error()
+}
+
+func isBlankRecord(record []string) bool {
+	return strings.TrimSpace(strings.Join(record, "")) == ""
+}
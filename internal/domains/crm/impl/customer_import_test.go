@@ -0,0 +1,155 @@
+package impl
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+	billingimpl "crm_lite/internal/domains/billing/impl"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/validators"
+	"crm_lite/pkg/validator"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCustomerImport 客户导入：逐行校验报告、试运行不写库、按手机号更新、期初余额只入账一次、XLSX 解析
+func TestCustomerImport(t *testing.T) {
+	validator.RegisterMobileValidator()
+	validators.RegisterCustomValidators()
+
+	db := newMergeTestDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	billingSvc := billingimpl.NewBillingServiceForMode(db, tx, billing.StoreModeLegacy)
+	svc := NewCustomerImportService(tx, billingSvc)
+
+	existing := &model.Customer{Name: "老客户", Phone: "13900000009", Email: "old@example.com", Level: "金牌", AssignedTo: 5}
+	require.NoError(t, db.Create(existing).Error)
+
+	csvFile := "\ufeff姓名,手机号,邮箱,性别,等级,标签,期初余额,微信\n" +
+		"张三,+86 138-0000-0001,zhangsan@example.com,男,银牌,VIP、老客户,100.50,zs\n" +
+		",13800000002,,,,,,\n" +
+		"李四,12345,bad-email,,,,,\n" +
+		"王五,13800000003,,,钻石,,,\n" +
+		"赵六,13800000001,,,,,,\n" +
+		",,,,,,,\n" +
+		"老客户改名,13900000009,,,,,20,\n"
+	importCSV := func(t *testing.T, dryRun bool) *crm.CustomerImportReport {
+		report, err := svc.ImportCustomers(ctx, strings.NewReader(csvFile), crm.CustomerImportOptions{
+			Format: crm.ImportFormatCSV, DryRun: dryRun, OperatorID: 1, DefaultAssignedTo: 7,
+		})
+		require.NoError(t, err)
+		return report
+	}
+	assertReport := func(t *testing.T, report *crm.CustomerImportReport) {
+		assert.Equal(t, 6, report.Total, "空行不计入")
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 4, report.Failed)
+		assert.Equal(t, int64(12050), report.OpeningBalance)
+		assert.Equal(t, []string{"微信"}, report.IgnoredColumns)
+		require.Len(t, report.Rows, 6)
+
+		rows := make(map[int]crm.CustomerImportRow)
+		for _, row := range report.Rows {
+			rows[row.Row] = row
+		}
+		assert.Equal(t, crm.ImportActionCreated, rows[2].Action)
+		assert.Equal(t, "13800000001", rows[2].Phone, "手机号规范化")
+		assert.Equal(t, int64(10050), rows[2].OpeningBalance)
+		assert.Equal(t, crm.ImportActionFailed, rows[3].Action, "缺少姓名")
+		assert.Len(t, rows[4].Errors, 2, "手机号与邮箱均不合法")
+		assert.Equal(t, crm.ImportActionFailed, rows[5].Action, "等级不在可选范围")
+		require.Len(t, rows[6].Errors, 1)
+		assert.Contains(t, rows[6].Errors[0], "第 2 行")
+		assert.Equal(t, crm.ImportActionUpdated, rows[8].Action)
+		assert.Equal(t, existing.ID, rows[8].CustomerID)
+	}
+
+	t.Run("试运行只返回报告", func(t *testing.T) {
+		report := importCSV(t, true)
+		assert.True(t, report.DryRun)
+		assertReport(t, report)
+
+		var count int64
+		require.NoError(t, db.Model(&model.Customer{}).Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+		balance, err := billingSvc.GetBalance(ctx, existing.ID)
+		require.NoError(t, err)
+		assert.Zero(t, balance)
+	})
+
+	t.Run("导入新建与更新客户并入账期初余额", func(t *testing.T) {
+		report := importCSV(t, false)
+		assertReport(t, report)
+
+		var created model.Customer
+		require.NoError(t, db.Where("phone = ?", "13800000001").First(&created).Error)
+		assert.Equal(t, "张三", created.Name)
+		assert.Equal(t, "male", created.Gender)
+		assert.Equal(t, "银牌", created.Level)
+		assert.Equal(t, "manual", created.Source)
+		assert.Equal(t, int64(7), created.AssignedTo, "未填写负责人时使用默认负责人")
+		assert.Equal(t, `["VIP","老客户"]`, created.Tags)
+
+		var updated model.Customer
+		require.NoError(t, db.First(&updated, existing.ID).Error)
+		assert.Equal(t, "老客户改名", updated.Name)
+		assert.Equal(t, "old@example.com", updated.Email, "空单元格保留原值")
+		assert.Equal(t, "金牌", updated.Level)
+		assert.Equal(t, int64(5), updated.AssignedTo)
+
+		balance, err := billingSvc.GetBalance(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10050), balance)
+
+		again := importCSV(t, false)
+		balance, err = billingSvc.GetBalance(ctx, created.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(10050), balance, "重复导入不重复入账期初余额")
+		assert.Zero(t, again.Updated, "已导入期初余额的行记为失败")
+		assert.Equal(t, 6, again.Failed)
+		assert.Zero(t, again.OpeningBalance, "未入账的期初余额不计入合计")
+		require.Len(t, again.Rows[0].Errors, 1)
+		assert.Contains(t, again.Rows[0].Errors[0], "已导入过期初余额")
+	})
+
+	t.Run("无权更新的客户记为失败", func(t *testing.T) {
+		report, err := svc.ImportCustomers(ctx, strings.NewReader("name,phone\n老客户,13900000009\n"), crm.CustomerImportOptions{
+			Format:    crm.ImportFormatCSV,
+			Authorize: func(context.Context, int64) (bool, error) { return false, nil },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, []string{"无权更新该客户"}, report.Rows[0].Errors)
+	})
+
+	t.Run("XLSX 与文件级错误", func(t *testing.T) {
+		f := excelize.NewFile()
+		require.NoError(t, f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Name", "Phone", "Gender"}))
+		require.NoError(t, f.SetSheetRow("Sheet1", "A2", &[]interface{}{"孙七", 13800000004, "female"}))
+		buf, err := f.WriteToBuffer()
+		require.NoError(t, err)
+
+		report, err := svc.ImportCustomers(ctx, bytes.NewReader(buf.Bytes()), crm.CustomerImportOptions{Format: crm.ImportFormatXLSX})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Created)
+		assert.NotZero(t, report.Rows[0].CustomerID)
+
+		var businessErr *common.BusinessError
+		_, err = svc.ImportCustomers(ctx, strings.NewReader("姓名,邮箱\n张三,a@b.com\n"), crm.CustomerImportOptions{Format: crm.ImportFormatCSV})
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeInvalidParam, businessErr.Code)
+		_, err = svc.ImportCustomers(ctx, strings.NewReader("not a zip"), crm.CustomerImportOptions{Format: crm.ImportFormatXLSX})
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeInvalidParam, businessErr.Code)
+	})
+}
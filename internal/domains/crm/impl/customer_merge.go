@@ -0,0 +1,400 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+	"crm_lite/internal/domains/crm"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// duplicateScanBatchSize 查找重复客户时分批扫描客户表的批大小
+const duplicateScanBatchSize = 500
+
+// CustomerMergeServiceImpl 重复客户识别与合并服务实现
+// 钱包余额迁移复用 billing 转账，转出/转入流水与客户合并在同一事务内提交
+type CustomerMergeServiceImpl struct {
+	db        *gorm.DB
+	tx        common.Tx
+	transfers billing.TransferService
+	outbox    common.OutboxService
+}
+
+// NewCustomerMergeService 创建客户合并服务实例
+// transfers 须基于同一数据库，余额读取与转账加入合并事务执行
+func NewCustomerMergeService(db *gorm.DB, tx common.Tx, transfers billing.TransferService) *CustomerMergeServiceImpl {
+	return &CustomerMergeServiceImpl{
+		db:        db,
+		tx:        tx,
+		transfers: transfers,
+		outbox:    common.NewOutboxService(db, tx),
+	}
+}
+
+// FindDuplicates 查找疑似重复客户
+// 手机号、邮箱格式不统一，无法在 SQL 中比较，按批扫描客户的姓名、手机号、邮箱在内存中匹配
+func (s *CustomerMergeServiceImpl) FindDuplicates(ctx context.Context, customerID int64, limit int) ([]crm.DuplicateCandidate, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	target, err := s.getCustomer(ctx, s.db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	phone, email, name := normalizePhone(target.Phone), normalizeEmail(target.Email), normalizeName(target.Name)
+
+	var candidates []crm.DuplicateCandidate
+	var batch []model.Customer
+	err = s.db.WithContext(ctx).Model(&model.Customer{}).
+		Select("id", "name", "phone", "email").
+		Where("id <> ?", customerID).
+		FindInBatches(&batch, duplicateScanBatchSize, func(*gorm.DB, int) error {
+			for _, c := range batch {
+				candidate := crm.DuplicateCandidate{Customer: crm.Customer{ID: c.ID}}
+				if phone != "" && normalizePhone(c.Phone) == phone {
+					candidate.Reasons = append(candidate.Reasons, crm.DuplicateReasonPhone)
+				}
+				if email != "" && normalizeEmail(c.Email) == email {
+					candidate.Reasons = append(candidate.Reasons, crm.DuplicateReasonEmail)
+				}
+				candidate.NameSimilarity = nameSimilarity(name, normalizeName(c.Name))
+				if candidate.NameSimilarity >= crm.DuplicateNameSimilarity {
+					candidate.Reasons = append(candidate.Reasons, crm.DuplicateReasonName)
+				}
+				if len(candidate.Reasons) == 0 {
+					continue
+				}
+				candidate.Score = candidate.NameSimilarity
+				if candidate.Reasons[0] != crm.DuplicateReasonName {
+					candidate.Score = 1
+				}
+				candidates = append(candidates, candidate)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("扫描客户失败: %w", err)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Customer.ID < candidates[j].Customer.ID
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+
+	// 补全候选客户的完整资料
+	ids := make([]int64, len(candidates))
+	for i := range candidates {
+		ids[i] = candidates[i].Customer.ID
+	}
+	var customers []model.Customer
+	if len(ids) > 0 {
+		if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&customers).Error; err != nil {
+			return nil, fmt.Errorf("查询客户失败: %w", err)
+		}
+	}
+	byID := make(map[int64]*model.Customer, len(customers))
+	for i := range customers {
+		byID[customers[i].ID] = &customers[i]
+	}
+	for i := range candidates {
+		if c, ok := byID[candidates[i].Customer.ID]; ok {
+			candidates[i].Customer = toDomainCustomer(c)
+		}
+	}
+	return candidates, nil
+}
+
+// MergeCustomers 合并客户
+// 被合并客户的钱包在事务内加锁后读取余额，锁持有到转出全部余额，期间其他交易无法改变余额
+func (s *CustomerMergeServiceImpl) MergeCustomers(ctx context.Context, req crm.MergeCustomersReq) (*crm.CustomerMerge, error) {
+	if req.SurvivorID <= 0 || req.LoserID <= 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "客户ID无效")
+	}
+	if req.SurvivorID == req.LoserID {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "不能将客户合并到自身")
+	}
+
+	var result *crm.CustomerMerge
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		// 1. 按客户ID升序锁定双方，被合并客户须未删除（已合并的客户同样视为不存在）
+		ids := []int64{req.SurvivorID, req.LoserID}
+		if req.LoserID < req.SurvivorID {
+			ids = []int64{req.LoserID, req.SurvivorID}
+		}
+		locked := make(map[int64]*model.Customer, 2)
+		for _, id := range ids {
+			c, err := s.getCustomer(ctx, txDB.Clauses(clause.Locking{Strength: "UPDATE"}), id)
+			if err != nil {
+				return err
+			}
+			locked[id] = c
+		}
+		loser := locked[req.LoserID]
+		snapshot, err := json.Marshal(toDomainCustomer(loser))
+		if err != nil {
+			return fmt.Errorf("序列化客户资料失败: %w", err)
+		}
+
+		balance, err := s.transfers.LockBalance(ctx, req.LoserID)
+		if err != nil {
+			return fmt.Errorf("查询被合并客户钱包余额失败: %w", err)
+		}
+
+		// 2. 改挂联系人、订单、活动、营销记录、服务套餐及流水、预约、优惠券核销；
+		// 保留客户已有主联系人时，并入的联系人不再作为主联系人
+		record := &CustomerMergeRecord{
+			SurvivorID:    req.SurvivorID,
+			LoserID:       req.LoserID,
+			WalletAmount:  balance,
+			LoserSnapshot: string(snapshot),
+			OperatorID:    req.OperatorID,
+			Reason:        req.Reason,
+			CreatedAt:     time.Now().Unix(),
+		}
+		var primaryContacts int64
+		if err := txDB.WithContext(ctx).Model(&model.Contact{}).
+			Where("customer_id = ? AND is_primary = ?", req.SurvivorID, true).
+			Count(&primaryContacts).Error; err != nil {
+			return fmt.Errorf("查询主联系人失败: %w", err)
+		}
+		contactUpdates := map[string]interface{}{"customer_id": req.SurvivorID}
+		if primaryContacts > 0 {
+			contactUpdates["is_primary"] = false
+		}
+		survivorRef := map[string]interface{}{"customer_id": req.SurvivorID}
+		moves := []struct {
+			table   string
+			column  string
+			updates map[string]interface{}
+			moved   *int64 // 不单独计数时为 nil
+		}{
+			{model.TableNameContact, "customer_id", contactUpdates, &record.MovedContacts},
+			{model.TableNameOrder, "customer_id", survivorRef, &record.MovedOrders},
+			{model.TableNameActivity, "customer_id", survivorRef, &record.MovedActivities},
+			{model.TableNameMarketingRecord, "customer_id", survivorRef, &record.MovedMarketingRecords},
+			{"customer_packages", "customer_id", survivorRef, &record.MovedPackages},
+			{"customer_package_transactions", "customer_id", survivorRef, &record.MovedPackageTransactions},
+			{"appointments", "customer_id", survivorRef, &record.MovedAppointments},
+			{"coupon_redemptions", "customer_id", survivorRef, &record.MovedCouponRedemptions},
+			// 券码上的核销客户与核销记录保持一致
+			{"coupon_codes", "redeemed_by", map[string]interface{}{"redeemed_by": req.SurvivorID}, nil},
+		}
+		for _, m := range moves {
+			// 按表名更新不带软删除条件，已删除的历史数据一并改挂
+			res := txDB.WithContext(ctx).Table(m.table).Where(m.column+" = ?", req.LoserID).Updates(m.updates)
+			if res.Error != nil {
+				return fmt.Errorf("改挂 %s 失败: %w", m.table, res.Error)
+			}
+			if m.moved != nil {
+				*m.moved = res.RowsAffected
+			}
+		}
+
+		// 3. 写入合并记录，以记录ID作为钱包转账的幂等键
+		if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建合并记录失败: %w", err)
+		}
+		if balance > 0 {
+			transfer, err := s.transfers.Transfer(ctx, billing.TransferRequest{
+				FromCustomerID: req.LoserID,
+				ToCustomerID:   req.SurvivorID,
+				Amount:         balance,
+				OperatorID:     req.OperatorID,
+				Note:           fmt.Sprintf("客户合并 #%d", record.ID),
+				Idem:           fmt.Sprintf("customer_merge_%d", record.ID),
+			})
+			if err != nil {
+				return err
+			}
+			record.WalletTransferID = transfer.ID
+			if err := txDB.WithContext(ctx).Model(record).Update("wallet_transfer_id", transfer.ID).Error; err != nil {
+				return fmt.Errorf("更新合并记录失败: %w", err)
+			}
+		}
+
+		// 4. 软删除被合并客户并发布事件，合并记录即被合并客户的标记，按手机号新建或导入客户时不再恢复
+		if err := txDB.WithContext(ctx).Delete(&model.Customer{}, req.LoserID).Error; err != nil {
+			return fmt.Errorf("删除被合并客户失败: %w", err)
+		}
+		if err := s.outbox.PublishEvent(ctx, common.EventTypeCustomerMerged, common.CustomerMergedEvent{
+			MergeID:      record.ID,
+			SurvivorID:   record.SurvivorID,
+			LoserID:      record.LoserID,
+			WalletAmount: record.WalletAmount,
+			OperatorID:   record.OperatorID,
+			MergedAt:     record.CreatedAt,
+		}); err != nil {
+			return err
+		}
+
+		result = toCustomerMerge(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListMerges 查询并入该客户的合并记录
+func (s *CustomerMergeServiceImpl) ListMerges(ctx context.Context, survivorID int64) ([]crm.CustomerMerge, error) {
+	var records []CustomerMergeRecord
+	if err := s.db.WithContext(ctx).
+		Where("survivor_id = ?", survivorID).
+		Order("created_at DESC, id DESC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询合并记录失败: %w", err)
+	}
+	merges := make([]crm.CustomerMerge, len(records))
+	for i := range records {
+		merges[i] = *toCustomerMerge(&records[i])
+	}
+	return merges, nil
+}
+
+// mergedInto 查询客户被合并到的保留客户ID，未被合并时返回 0
+// 被合并客户仅软删除并保留手机号，按手机号恢复已删除客户前须排除，否则会复活已并入其他客户的资料
+func mergedInto(ctx context.Context, db *gorm.DB, customerID int64) (int64, error) {
+	var records []CustomerMergeRecord
+	if err := db.WithContext(ctx).Where("loser_id = ?", customerID).Limit(1).Find(&records).Error; err != nil {
+		return 0, fmt.Errorf("查询合并记录失败: %w", err)
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return records[0].SurvivorID, nil
+}
+
+// getCustomer 查询未删除的客户，不存在时返回 CUSTOMER_NOT_FOUND
+func (s *CustomerMergeServiceImpl) getCustomer(ctx context.Context, db *gorm.DB, customerID int64) (*model.Customer, error) {
+	var c model.Customer
+	if err := db.WithContext(ctx).Where("id = ?", customerID).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCustomerNotFound, fmt.Sprintf("客户 %d 不存在", customerID))
+		}
+		return nil, fmt.Errorf("查询客户失败: %w", err)
+	}
+	return &c, nil
+}
+
+// normalizePhone 规范化手机号：只保留数字，去掉 00 国际冠码及 86 国家码
+func normalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	digits = strings.TrimPrefix(digits, "00")
+	if len(digits) > 11 && strings.HasPrefix(digits, "86") {
+		digits = digits[2:]
+	}
+	return digits
+}
+
+// normalizeEmail 规范化邮箱：去掉首尾空白并转小写
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeName 规范化姓名：转小写并去掉空白与标点（如 "张 三"、"Li·Ming"）
+func normalizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// nameSimilarity 按编辑距离计算姓名相似度：1 - 距离 / 较长姓名的字符数
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+func toDomainCustomer(c *model.Customer) crm.Customer {
+	customer := crm.Customer{
+		ID:         c.ID,
+		Name:       c.Name,
+		Phone:      c.Phone,
+		Email:      c.Email,
+		Gender:     c.Gender,
+		Level:      c.Level,
+		Note:       c.Note,
+		Source:     c.Source,
+		AssignedTo: c.AssignedTo,
+		CreatedAt:  c.CreatedAt.Unix(),
+		UpdatedAt:  c.UpdatedAt.Unix(),
+	}
+	if !c.Birthday.IsZero() {
+		customer.Birthday = c.Birthday.Format("2006-01-02")
+	}
+	if c.Tags != "" {
+		_ = json.Unmarshal([]byte(c.Tags), &customer.Tags)
+	}
+	return customer
+}
+
+func toCustomerMerge(r *CustomerMergeRecord) *crm.CustomerMerge {
+	return &crm.CustomerMerge{
+		ID:                       r.ID,
+		SurvivorID:               r.SurvivorID,
+		LoserID:                  r.LoserID,
+		MovedContacts:            r.MovedContacts,
+		MovedOrders:              r.MovedOrders,
+		MovedActivities:          r.MovedActivities,
+		MovedMarketingRecords:    r.MovedMarketingRecords,
+		MovedPackages:            r.MovedPackages,
+		MovedPackageTransactions: r.MovedPackageTransactions,
+		MovedAppointments:        r.MovedAppointments,
+		MovedCouponRedemptions:   r.MovedCouponRedemptions,
+		WalletAmount:             r.WalletAmount,
+		WalletTransferID:         r.WalletTransferID,
+		LoserSnapshot:            r.LoserSnapshot,
+		OperatorID:               r.OperatorID,
+		Reason:                   r.Reason,
+		CreatedAt:                r.CreatedAt,
+	}
+}
@@ -0,0 +1,267 @@
+package impl
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/billing"
+	billingimpl "crm_lite/internal/domains/billing/impl"
+	"crm_lite/internal/domains/crm"
+	"crm_lite/internal/validators"
+	"crm_lite/pkg/validator"
+
+	"gorm.io/gorm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMergeTestDB 在活动测试库基础上补充订单、营销记录、服务套餐、预约、优惠券核销、钱包及合并记录表
+// 钱包以外的业务表只保留合并涉及的列
+func newMergeTestDB(t *testing.T) *gorm.DB {
+	db := newActivityTestDB(t)
+	for _, ddl := range []string{`
+		CREATE TABLE orders (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_no TEXT NOT NULL,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE marketing_records (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			campaign_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE wallets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL,
+			balance INTEGER NOT NULL DEFAULT 0,
+			status INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at INTEGER NOT NULL DEFAULT 0
+		)`, `
+		CREATE TABLE wallet_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			wallet_id INTEGER NOT NULL,
+			direction TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			biz_ref_type TEXT,
+			biz_ref_id INTEGER,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			operator_id INTEGER DEFAULT 0,
+			reason_code TEXT,
+			note TEXT,
+			created_at INTEGER NOT NULL,
+			prev_hash TEXT NOT NULL DEFAULT '',
+			hash TEXT NOT NULL DEFAULT ''
+		)`, `
+		CREATE TABLE customer_packages (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE customer_package_transactions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_package_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE appointments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE coupon_codes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code TEXT NOT NULL,
+			redeemed_by INTEGER NOT NULL DEFAULT 0
+		)`, `
+		CREATE TABLE coupon_redemptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code_id INTEGER NOT NULL,
+			customer_id INTEGER NOT NULL
+		)`, `
+		CREATE TABLE sys_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			processed_at INTEGER NULL
+		)`,
+	} {
+		require.NoError(t, db.Exec(ddl).Error)
+	}
+	require.NoError(t, db.AutoMigrate(&billingimpl.WalletStatusLogRecord{}, &billingimpl.WalletTransferRecord{}, &billingimpl.WalletCreditLotRecord{}, &billingimpl.WalletCreditLotUsageRecord{}, &CustomerMergeRecord{},
+		&CustomFieldRecord{}, &CustomFieldValueRecord{}, &CustomerTagRecord{}))
+	return db
+}
+
+// TestCustomerMerge 重复客户：规范化手机号/邮箱/姓名相似度匹配，合并改挂数据、迁移钱包余额、软删除并发布事件
+func TestCustomerMerge(t *testing.T) {
+	db := newMergeTestDB(t)
+	ctx := context.Background()
+	tx := common.NewTx(db)
+	billingSvc := billingimpl.NewBillingServiceForMode(db, tx, billing.StoreModeLegacy)
+	svc := NewCustomerMergeService(db, tx, billingSvc.(billing.TransferService))
+
+	survivor := &model.Customer{Name: "张三", Phone: "13800000001", Email: "zhangsan@example.com", AssignedTo: 11}
+	require.NoError(t, db.Create(survivor).Error)
+	byPhone := &model.Customer{Name: "张 三", Phone: "+86 138-0000-0001", AssignedTo: 11}
+	require.NoError(t, db.Create(byPhone).Error)
+	byEmail := &model.Customer{Name: "Zhang San", Phone: "13900000002", Email: " ZhangSan@Example.com "}
+	require.NoError(t, db.Create(byEmail).Error)
+	byName := &model.Customer{Name: "John Smith", Phone: "13700000003"}
+	require.NoError(t, db.Create(byName).Error)
+	similar := &model.Customer{Name: "Jon Smith", Phone: "13600000004"}
+	require.NoError(t, db.Create(similar).Error)
+	unrelated := &model.Customer{Name: "李四", Phone: "13500000005"}
+	require.NoError(t, db.Create(unrelated).Error)
+
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+
+	t.Run("规范化匹配", func(t *testing.T) {
+		assert.Equal(t, "13800000001", normalizePhone("+86 138-0000-0001"))
+		assert.Equal(t, "13800000001", normalizePhone("0086 13800000001"))
+		assert.InDelta(t, 8.0/9, nameSimilarity(normalizeName("John Smith"), normalizeName("Jon Smith")), 1e-9)
+
+		candidates, err := svc.FindDuplicates(ctx, survivor.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, candidates, 2)
+		assert.Equal(t, byPhone.ID, candidates[0].Customer.ID)
+		assert.Equal(t, []string{crm.DuplicateReasonPhone, crm.DuplicateReasonName}, candidates[0].Reasons)
+		assert.Equal(t, "张 三", candidates[0].Customer.Name, "返回候选客户完整资料")
+		assert.Equal(t, byEmail.ID, candidates[1].Customer.ID)
+		assert.Equal(t, []string{crm.DuplicateReasonEmail}, candidates[1].Reasons)
+		assert.Equal(t, float64(1), candidates[1].Score)
+
+		candidates, err = svc.FindDuplicates(ctx, byName.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, candidates, 1)
+		assert.Equal(t, similar.ID, candidates[0].Customer.ID)
+		assert.Equal(t, []string{crm.DuplicateReasonName}, candidates[0].Reasons)
+		assert.Equal(t, candidates[0].NameSimilarity, candidates[0].Score)
+
+		_, err = svc.FindDuplicates(ctx, 9999, 10)
+		assertCode(t, err, common.ErrCodeCustomerNotFound)
+	})
+
+	t.Run("合并改挂数据并迁移钱包余额", func(t *testing.T) {
+		require.NoError(t, db.Create(&model.Contact{CustomerID: survivor.ID, Name: "主联系人", IsPrimary: true}).Error)
+		require.NoError(t, db.Create(&model.Contact{CustomerID: byPhone.ID, Name: "原主联系人", IsPrimary: true}).Error)
+		require.NoError(t, db.Exec(`INSERT INTO orders (order_no, customer_id) VALUES ('SO1', ?), ('SO2', ?)`, byPhone.ID, byPhone.ID).Error)
+		require.NoError(t, db.Exec(`INSERT INTO marketing_records (campaign_id, customer_id) VALUES (1, ?)`, byPhone.ID).Error)
+		require.NoError(t, db.Create(&model.Activity{CustomerID: byPhone.ID, Type: crm.ActivityTypeCall, Title: "回访"}).Error)
+		require.NoError(t, db.Exec(`INSERT INTO customer_packages (customer_id) VALUES (?)`, byPhone.ID).Error)
+		require.NoError(t, db.Exec(`INSERT INTO customer_package_transactions (customer_package_id, customer_id) VALUES (1, ?), (1, ?)`, byPhone.ID, byPhone.ID).Error)
+		require.NoError(t, db.Exec(`INSERT INTO appointments (customer_id) VALUES (?)`, byPhone.ID).Error)
+		require.NoError(t, db.Exec(`INSERT INTO coupon_codes (code, redeemed_by) VALUES ('C1', ?)`, byPhone.ID).Error)
+		require.NoError(t, db.Exec(`INSERT INTO coupon_redemptions (code_id, customer_id) VALUES (1, ?)`, byPhone.ID).Error)
+		require.NoError(t, billingSvc.Credit(ctx, byPhone.ID, 5000, "充值", "merge_seed_1"))
+		require.NoError(t, billingSvc.Credit(ctx, survivor.ID, 1000, "充值", "merge_seed_2"))
+
+		merge, err := svc.MergeCustomers(ctx, crm.MergeCustomersReq{SurvivorID: survivor.ID, LoserID: byPhone.ID, OperatorID: 1, Reason: "重复录入"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), merge.MovedContacts)
+		assert.Equal(t, int64(2), merge.MovedOrders)
+		assert.Equal(t, int64(1), merge.MovedActivities)
+		assert.Equal(t, int64(1), merge.MovedMarketingRecords)
+		assert.Equal(t, int64(1), merge.MovedPackages)
+		assert.Equal(t, int64(2), merge.MovedPackageTransactions)
+		assert.Equal(t, int64(1), merge.MovedAppointments)
+		assert.Equal(t, int64(1), merge.MovedCouponRedemptions)
+		assert.Equal(t, int64(5000), merge.WalletAmount)
+		assert.NotZero(t, merge.WalletTransferID)
+		assert.Contains(t, merge.LoserSnapshot, "+86 138-0000-0001")
+
+		var moved int64
+		require.NoError(t, db.Model(&model.Contact{}).Where("customer_id = ?", survivor.ID).Count(&moved).Error)
+		assert.Equal(t, int64(2), moved)
+		var primaries int64
+		require.NoError(t, db.Model(&model.Contact{}).Where("customer_id = ? AND is_primary = ?", survivor.ID, true).Count(&primaries).Error)
+		assert.Equal(t, int64(1), primaries, "保留客户已有主联系人，并入的联系人取消主联系人")
+		require.NoError(t, db.Table("orders").Where("customer_id = ?", byPhone.ID).Count(&moved).Error)
+		assert.Zero(t, moved)
+		require.NoError(t, db.Table("coupon_codes").Where("redeemed_by = ?", survivor.ID).Count(&moved).Error)
+		assert.Equal(t, int64(1), moved, "券码核销客户随核销记录改挂")
+
+		balance, err := billingSvc.GetBalance(ctx, survivor.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6000), balance)
+		balance, err = billingSvc.GetBalance(ctx, byPhone.ID)
+		require.NoError(t, err)
+		assert.Zero(t, balance)
+		history, err := billingSvc.GetTransactionHistory(ctx, byPhone.ID, 1, 10)
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, "debit", history[0].Direction, "被合并客户保留转出流水")
+
+		var loser model.Customer
+		require.NoError(t, db.Unscoped().First(&loser, byPhone.ID).Error)
+		assert.True(t, loser.DeletedAt.Valid)
+
+		var payload string
+		require.NoError(t, db.Raw(`SELECT payload FROM sys_outbox WHERE event_type = ?`, common.EventTypeCustomerMerged).Scan(&payload).Error)
+		assert.Contains(t, payload, `"loser_id"`)
+
+		merges, err := svc.ListMerges(ctx, survivor.ID)
+		require.NoError(t, err)
+		require.Len(t, merges, 1)
+		assert.Equal(t, merge.ID, merges[0].ID)
+
+		candidates, err := svc.FindDuplicates(ctx, survivor.ID, 10)
+		require.NoError(t, err)
+		require.Len(t, candidates, 1, "已合并的客户不再作为候选")
+	})
+
+	t.Run("拒绝无效合并且不留下改动", func(t *testing.T) {
+		_, err := svc.MergeCustomers(ctx, crm.MergeCustomersReq{SurvivorID: survivor.ID, LoserID: survivor.ID})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = svc.MergeCustomers(ctx, crm.MergeCustomersReq{SurvivorID: survivor.ID, LoserID: byPhone.ID})
+		assertCode(t, err, common.ErrCodeCustomerNotFound)
+
+		require.NoError(t, db.Create(&model.Contact{CustomerID: byEmail.ID, Name: "邮箱客户联系人"}).Error)
+		require.NoError(t, billingSvc.Credit(ctx, byEmail.ID, 300, "充值", "merge_seed_3"))
+		_, err = billingSvc.FreezeWallet(ctx, byEmail.ID, 1, "风控")
+		require.NoError(t, err)
+		_, err = svc.MergeCustomers(ctx, crm.MergeCustomersReq{SurvivorID: survivor.ID, LoserID: byEmail.ID, OperatorID: 1})
+		assertCode(t, err, common.ErrCodeWalletFrozen)
+
+		var contacts int64
+		require.NoError(t, db.Model(&model.Contact{}).Where("customer_id = ?", byEmail.ID).Count(&contacts).Error)
+		assert.Equal(t, int64(1), contacts, "钱包冻结时整体回滚")
+		var merges int64
+		require.NoError(t, db.Model(&CustomerMergeRecord{}).Count(&merges).Error)
+		assert.Equal(t, int64(1), merges)
+	})
+
+	t.Run("已合并的客户不再按手机号恢复", func(t *testing.T) {
+		validator.RegisterMobileValidator()
+		validators.RegisterCustomValidators()
+
+		merged := &model.Customer{Name: "王五", Phone: "13800000088"}
+		require.NoError(t, db.Create(merged).Error)
+		merge, err := svc.MergeCustomers(ctx, crm.MergeCustomersReq{SurvivorID: survivor.ID, LoserID: merged.ID, OperatorID: 1})
+		require.NoError(t, err)
+		assert.Zero(t, merge.WalletAmount, "没有钱包时余额为 0")
+		assert.Zero(t, merge.WalletTransferID)
+
+		crmSvc := NewCRMServiceWithBilling(db, billingSvc)
+		_, err = crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "王五", Phone: merged.Phone})
+		assert.ErrorIs(t, err, ErrPhoneAlreadyExists)
+
+		report, err := NewCustomerImportService(tx, billingSvc).ImportCustomers(ctx,
+			strings.NewReader("姓名,手机号\n王五,13800000088\n"), crm.CustomerImportOptions{Format: crm.ImportFormatCSV, OperatorID: 1})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+		require.Len(t, report.Rows[0].Errors, 1)
+		assert.Contains(t, report.Rows[0].Errors[0], "已合并")
+
+		var loser model.Customer
+		require.NoError(t, db.Unscoped().First(&loser, merged.ID).Error)
+		assert.True(t, loser.DeletedAt.Valid, "被合并客户保持删除")
+	})
+}
@@ -0,0 +1,593 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/crm"
+
+	"gorm.io/gen"
+	"gorm.io/gen/field"
+	"gorm.io/gorm"
+)
+
+const (
+	customerTagGroupMaxLen = 50  // 与 customer_tags.group_name 列宽一致
+	customerTagBatchSize   = 200 // 批量改写客户标签时每批读取的客户数
+)
+
+var customerTagColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// CustomerTagServiceImpl 客户标签字典与批量打标服务实现
+// 字典保存在 customer_tags，客户上的标签仍为 customers.tags 中的名称数组；
+// MySQL 下按标签筛选使用 JSON_OVERLAPS/JSON_CONTAINS（可命中 customers.tags 的多值索引），其他数据库使用 json_each
+type CustomerTagServiceImpl struct {
+	db           *gorm.DB
+	q            *query.Query
+	customFields *CustomFieldServiceImpl
+}
+
+// NewCustomerTagService 创建客户标签服务实例
+func NewCustomerTagService(db *gorm.DB) *CustomerTagServiceImpl {
+	return &CustomerTagServiceImpl{
+		db:           db,
+		q:            query.Use(db),
+		customFields: NewCustomFieldService(db),
+	}
+}
+
+// CreateTag 创建标签
+func (s *CustomerTagServiceImpl) CreateTag(ctx context.Context, req crm.CreateCustomerTagReq) (*crm.CustomerTag, error) {
+	name := strings.TrimSpace(req.Name)
+	if err := validateCustomerTagName(name); err != nil {
+		return nil, err
+	}
+	color, err := normalizeCustomerTagColor(req.Color)
+	if err != nil {
+		return nil, err
+	}
+	group, err := normalizeCustomerTagGroup(req.Group)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNameAvailable(ctx, name, 0); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	record := &CustomerTagRecord{
+		Name:      name,
+		NameKey:   customerTagKey(name),
+		Color:     color,
+		Group:     group,
+		Sort:      req.Sort,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建标签失败: %w", err)
+	}
+	return toCustomerTag(record, 0), nil
+}
+
+// UpdateTag 更新标签，改名时在同一事务中改写客户上的旧名称
+func (s *CustomerTagServiceImpl) UpdateTag(ctx context.Context, req crm.UpdateCustomerTagReq) (*crm.CustomerTag, error) {
+	record, err := s.getTagRecord(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	renamed := ""
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if err := validateCustomerTagName(name); err != nil {
+			return nil, err
+		}
+		if name != record.Name {
+			if err := s.checkNameAvailable(ctx, name, record.ID); err != nil {
+				return nil, err
+			}
+			updates["name"], updates["name_key"] = name, customerTagKey(name)
+			renamed = name
+		}
+	}
+	if req.Color != nil {
+		color, err := normalizeCustomerTagColor(*req.Color)
+		if err != nil {
+			return nil, err
+		}
+		updates["color"] = color
+	}
+	if req.Group != nil {
+		group, err := normalizeCustomerTagGroup(*req.Group)
+		if err != nil {
+			return nil, err
+		}
+		updates["group_name"] = group
+	}
+	if req.Sort != nil {
+		updates["sort"] = *req.Sort
+	}
+	if len(updates) == 0 {
+		return s.getTagWithUsage(ctx, record)
+	}
+
+	updates["updated_at"] = time.Now().Unix()
+	cond := s.tagMatchCondition([]string{record.Name}, false)
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&CustomerTagRecord{}).Where("id = ?", record.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新标签失败: %w", err)
+		}
+		if renamed == "" {
+			return nil
+		}
+		_, err := rewriteCustomerTags(ctx, tx, []gen.Condition{cond}, func(tags []string) []string {
+			return replaceCustomerTags(tags, []string{record.Name}, renamed)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	record, err = s.getTagRecord(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getTagWithUsage(ctx, record)
+}
+
+// DeleteTag 删除标签并从所有客户上移除
+func (s *CustomerTagServiceImpl) DeleteTag(ctx context.Context, tagID int64) (*crm.CustomerTagUpdateResult, error) {
+	record, err := s.getTagRecord(ctx, tagID)
+	if err != nil {
+		return nil, err
+	}
+	cond := s.tagMatchCondition([]string{record.Name}, false)
+
+	var result *crm.CustomerTagUpdateResult
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&CustomerTagRecord{}, record.ID).Error; err != nil {
+			return fmt.Errorf("删除标签失败: %w", err)
+		}
+		var err error
+		result, err = rewriteCustomerTags(ctx, tx, []gen.Condition{cond}, func(tags []string) []string {
+			return removeCustomerTags(tags, []string{record.Name})
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListTags 按分组、排序返回字典标签及使用数
+func (s *CustomerTagServiceImpl) ListTags(ctx context.Context, group string) ([]crm.CustomerTag, error) {
+	db := s.db.WithContext(ctx).Order("group_name ASC, sort ASC, id ASC")
+	if group != "" {
+		db = db.Where("group_name = ?", group)
+	}
+	var records []CustomerTagRecord
+	if err := db.Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	usage, err := s.tagUsageCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]crm.CustomerTag, len(records))
+	for i := range records {
+		tags[i] = *toCustomerTag(&records[i], usage[records[i].Name])
+	}
+	return tags, nil
+}
+
+// ListTagUsage 返回客户上实际使用的全部标签，按客户数倒序
+func (s *CustomerTagServiceImpl) ListTagUsage(ctx context.Context) ([]crm.CustomerTagUsage, error) {
+	usage, err := s.tagUsageCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := s.db.WithContext(ctx).Model(&CustomerTagRecord{}).Pluck("name", &names).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	managed := make(map[string]bool, len(names))
+	for _, name := range names {
+		managed[name] = true
+	}
+
+	result := make([]crm.CustomerTagUsage, 0, len(usage))
+	for name, count := range usage {
+		result = append(result, crm.CustomerTagUsage{Name: name, Count: count, Managed: managed[name]})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// MergeTags 将来源标签在所有客户上替换为目标标签，并删除字典中的来源标签
+func (s *CustomerTagServiceImpl) MergeTags(ctx context.Context, req crm.MergeCustomerTagsReq) (*crm.CustomerTagUpdateResult, error) {
+	target, err := s.getTagRecord(ctx, req.TargetID)
+	if err != nil {
+		return nil, err
+	}
+	// 来源按原样匹配，以便合并 "vip " 这类带空白的历史写法
+	sources := make([]string, 0, len(req.Sources))
+	for _, source := range req.Sources {
+		if source != "" && source != target.Name && !containsString(sources, source) {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "请指定要合并的来源标签")
+	}
+	cond := s.tagMatchCondition(sources, false)
+
+	var result *crm.CustomerTagUpdateResult
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("name IN ? AND id <> ?", sources, target.ID).Delete(&CustomerTagRecord{}).Error; err != nil {
+			return fmt.Errorf("删除来源标签失败: %w", err)
+		}
+		var err error
+		result, err = rewriteCustomerTags(ctx, tx, []gen.Condition{cond}, func(tags []string) []string {
+			return replaceCustomerTags(tags, sources, target.Name)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// BulkTagCustomers 为筛选出的客户批量添加、移除标签
+func (s *CustomerTagServiceImpl) BulkTagCustomers(ctx context.Context, req crm.BulkTagCustomersReq) (*crm.CustomerTagUpdateResult, error) {
+	if len(req.Add) == 0 && len(req.Remove) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "请指定要添加或移除的标签")
+	}
+	filter := req.Filter
+	if len(filter.IDs) == 0 && filter.Name == "" && filter.Phone == "" && filter.Email == "" &&
+		len(filter.Tags) == 0 && len(filter.CustomFields) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "批量打标签须指定筛选条件")
+	}
+
+	dictionary, err := s.dictionary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	add := make([]string, 0, len(req.Add))
+	for _, tag := range req.Add {
+		name, ok := dictionary[customerTagKey(tag)]
+		if !ok {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("标签不在字典中: %s", tag))
+		}
+		if !containsString(add, name) {
+			add = append(add, name)
+		}
+	}
+	// 移除时字典中的标签按字典写法匹配，字典外的按原样匹配
+	remove := make([]string, 0, len(req.Remove))
+	for _, tag := range req.Remove {
+		if name, ok := dictionary[customerTagKey(tag)]; ok {
+			tag = name
+		}
+		if tag != "" && !containsString(remove, tag) {
+			remove = append(remove, tag)
+		}
+	}
+	for _, tag := range add {
+		if containsString(remove, tag) {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("标签不能同时添加和移除: %s", tag))
+		}
+	}
+
+	conds, err := customerListConds(ctx, s.q, s.customFields, s, &filter)
+	if err != nil {
+		return nil, err
+	}
+	var result *crm.CustomerTagUpdateResult
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = rewriteCustomerTags(ctx, tx, conds, func(tags []string) []string {
+			tags = removeCustomerTags(tags, remove)
+			for _, tag := range add {
+				if !containsString(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			return tags
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeTags 规范化写入客户的标签：去除首尾空白与空标签，字典中的标签统一为字典写法，忽略大小写去重
+// 字典外的标签保留，可在标签使用统计中发现后合并
+func (s *CustomerTagServiceImpl) normalizeTags(ctx context.Context, tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return []string{}, nil
+	}
+	dictionary, err := s.dictionary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeCustomerTags(tags, dictionary)
+}
+
+// filterCondition 构建客户列表的标签筛选条件，字典中的标签按字典写法匹配；没有有效标签时返回 nil
+func (s *CustomerTagServiceImpl) filterCondition(ctx context.Context, tags []string, matchAll bool) (gen.Condition, error) {
+	dictionary, err := s.dictionary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if name, ok := dictionary[customerTagKey(tag)]; ok {
+			tag = name
+		}
+		if tag != "" && !containsString(names, tag) {
+			names = append(names, tag)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return s.tagMatchCondition(names, matchAll), nil
+}
+
+// tagMatchCondition 按标签名称原样匹配 customers.tags，matchAll 为 true 时须包含全部名称
+func (s *CustomerTagServiceImpl) tagMatchCondition(names []string, matchAll bool) gen.Condition {
+	if s.db.Dialector.Name() == "mysql" {
+		namesJSON, _ := json.Marshal(names)
+		if matchAll {
+			return field.NewUnsafeFieldRaw("JSON_CONTAINS(customers.tags, CAST(? AS JSON))", string(namesJSON))
+		}
+		return field.NewUnsafeFieldRaw("JSON_OVERLAPS(customers.tags, CAST(? AS JSON))", string(namesJSON))
+	}
+	if matchAll {
+		return field.NewUnsafeFieldRaw(
+			"(SELECT COUNT(DISTINCT json_each.value) FROM json_each(customers.tags) WHERE json_each.value IN ?) = ?", names, len(names))
+	}
+	return field.NewUnsafeFieldRaw("EXISTS (SELECT 1 FROM json_each(customers.tags) WHERE json_each.value IN ?)", names)
+}
+
+// tagUsageCounts 统计未删除客户上每个标签（按原样区分大小写与空白）的客户数
+func (s *CustomerTagServiceImpl) tagUsageCounts(ctx context.Context) (map[string]int64, error) {
+	sql := `SELECT json_each.value AS name, COUNT(DISTINCT customers.id) AS count
+		FROM customers, json_each(customers.tags)
+		WHERE customers.deleted_at IS NULL GROUP BY json_each.value`
+	if s.db.Dialector.Name() == "mysql" {
+		// utf8mb4_0900_bin 区分大小写且不忽略尾部空格，"VIP" 与 "vip " 分开统计
+		sql = `SELECT jt.name, COUNT(DISTINCT customers.id) AS count
+			FROM customers, JSON_TABLE(customers.tags, '$[*]' COLUMNS (name VARCHAR(255) COLLATE utf8mb4_0900_bin PATH '$')) AS jt
+			WHERE customers.deleted_at IS NULL AND jt.name IS NOT NULL GROUP BY jt.name`
+	}
+	var rows []struct {
+		Name  string
+		Count int64
+	}
+	if err := s.db.WithContext(ctx).Raw(sql).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计标签使用数失败: %w", err)
+	}
+	usage := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		usage[row.Name] = row.Count
+	}
+	return usage, nil
+}
+
+// dictionary 读取标签字典，返回 name_key 到字典写法的映射
+func (s *CustomerTagServiceImpl) dictionary(ctx context.Context) (map[string]string, error) {
+	return loadCustomerTagDictionary(s.db.WithContext(ctx))
+}
+
+func (s *CustomerTagServiceImpl) checkNameAvailable(ctx context.Context, name string, excludeID int64) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&CustomerTagRecord{}).
+		Where("name_key = ? AND id <> ?", customerTagKey(name), excludeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询标签失败: %w", err)
+	}
+	if count > 0 {
+		return common.NewBusinessError(common.ErrCodeTagExists, fmt.Sprintf("标签已存在: %s，如需归并请使用合并标签", name))
+	}
+	return nil
+}
+
+func (s *CustomerTagServiceImpl) getTagRecord(ctx context.Context, tagID int64) (*CustomerTagRecord, error) {
+	var record CustomerTagRecord
+	if err := s.db.WithContext(ctx).First(&record, tagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeTagNotFound, "标签不存在")
+		}
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	return &record, nil
+}
+
+func (s *CustomerTagServiceImpl) getTagWithUsage(ctx context.Context, record *CustomerTagRecord) (*crm.CustomerTag, error) {
+	usage, err := s.tagUsageCounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toCustomerTag(record, usage[record.Name]), nil
+}
+
+// rewriteCustomerTags 在事务 tx 中按 ID 分批读取满足条件的客户，用 fn 改写标签，只更新发生变化的客户
+func rewriteCustomerTags(ctx context.Context, tx *gorm.DB, conds []gen.Condition, fn func(tags []string) []string) (*crm.CustomerTagUpdateResult, error) {
+	q := query.Use(tx)
+	result := &crm.CustomerTagUpdateResult{}
+	var batch []*model.Customer
+	err := q.Customer.WithContext(ctx).Select(q.Customer.ID, q.Customer.Tags).Where(conds...).
+		FindInBatches(&batch, customerTagBatchSize, func(gen.Dao, int) error {
+			for _, c := range batch {
+				result.Matched++
+				current := decodeCustomerTags(c.Tags)
+				updated := fn(append([]string(nil), current...))
+				if equalStrings(current, updated) {
+					continue
+				}
+				tagsJSON, err := json.Marshal(updated)
+				if err != nil {
+					return fmt.Errorf("序列化客户标签失败: %w", err)
+				}
+				if _, err := q.Customer.WithContext(ctx).Where(q.Customer.ID.Eq(c.ID)).Update(q.Customer.Tags, string(tagsJSON)); err != nil {
+					return fmt.Errorf("更新客户标签失败: %w", err)
+				}
+				result.Updated++
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// loadCustomerTagDictionary 读取标签字典，返回 name_key 到字典写法的映射
+func loadCustomerTagDictionary(db *gorm.DB) (map[string]string, error) {
+	var records []CustomerTagRecord
+	if err := db.Select("name", "name_key").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询标签失败: %w", err)
+	}
+	dictionary := make(map[string]string, len(records))
+	for _, r := range records {
+		dictionary[r.NameKey] = r.Name
+	}
+	return dictionary, nil
+}
+
+// normalizeCustomerTags 见 CustomerTagServiceImpl.normalizeTags，dictionary 为 name_key 到字典写法的映射
+func normalizeCustomerTags(tags []string, dictionary map[string]string) ([]string, error) {
+	result := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if err := validateCustomerTagName(tag); err != nil {
+			return nil, err
+		}
+		key := customerTagKey(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if name, ok := dictionary[key]; ok {
+			tag = name
+		}
+		result = append(result, tag)
+	}
+	return result, nil
+}
+
+// customerTagKey 标签的唯一键：去除首尾空白后转小写
+func customerTagKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func validateCustomerTagName(name string) error {
+	if name == "" || utf8.RuneCountInString(name) > crm.CustomerTagMaxLen {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("标签不能为空且不超过%d个字符", crm.CustomerTagMaxLen))
+	}
+	return nil
+}
+
+func normalizeCustomerTagColor(color string) (string, error) {
+	color = strings.TrimSpace(color)
+	if color == "" {
+		return crm.CustomerTagDefaultColor, nil
+	}
+	if !customerTagColorPattern.MatchString(color) {
+		return "", common.NewBusinessError(common.ErrCodeInvalidParam, "标签颜色须为 #RRGGBB 格式")
+	}
+	return strings.ToUpper(color), nil
+}
+
+func normalizeCustomerTagGroup(group string) (string, error) {
+	group = strings.TrimSpace(group)
+	if utf8.RuneCountInString(group) > customerTagGroupMaxLen {
+		return "", common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("标签分组不能超过%d个字符", customerTagGroupMaxLen))
+	}
+	return group, nil
+}
+
+// replaceCustomerTags 将 sources 中的标签替换为 target，保持原有顺序并去重
+func replaceCustomerTags(tags, sources []string, target string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if containsString(sources, tag) {
+			tag = target
+		}
+		if !containsString(result, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func removeCustomerTags(tags, names []string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !containsString(names, tag) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func decodeCustomerTags(raw string) []string {
+	var tags []string
+	if raw != "" {
+		_ = json.Unmarshal([]byte(raw), &tags)
+	}
+	return tags
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toCustomerTag(r *CustomerTagRecord, usage int64) *crm.CustomerTag {
+	return &crm.CustomerTag{
+		ID:         r.ID,
+		Name:       r.Name,
+		Color:      r.Color,
+		Group:      r.Group,
+		Sort:       r.Sort,
+		UsageCount: usage,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
@@ -0,0 +1,183 @@
+package impl
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/domains/billing"
+	billingimpl "crm_lite/internal/domains/billing/impl"
+	"crm_lite/internal/domains/crm"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCustomerTags 客户标签字典：字典校验、写入客户时统一写法、按标签筛选、批量打标签、使用统计、改名/合并/删除改写客户标签
+func TestCustomerTags(t *testing.T) {
+	db := newMergeTestDB(t)
+	ctx := context.Background()
+	tagSvc := NewCustomerTagService(db)
+	billingSvc := billingimpl.NewBillingServiceForMode(db, common.NewTx(db), billing.StoreModeLegacy)
+	crmSvc := NewCRMServiceWithBilling(db, billingSvc)
+
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+	customerTags := func(t *testing.T, id int64) []string {
+		customer, err := crmSvc.GetCustomerByIDLegacy(ctx, strconv.FormatInt(id, 10))
+		require.NoError(t, err)
+		return customer.Tags
+	}
+
+	vip, err := tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: " VIP ", Color: "#e6a23c", Group: "等级", Sort: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "VIP", vip.Name)
+	assert.Equal(t, "#E6A23C", vip.Color)
+	regular, err := tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: "老客", Group: "等级", Sort: 2})
+	require.NoError(t, err)
+	assert.Equal(t, crm.CustomerTagDefaultColor, regular.Color)
+	dyed, err := tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: "染发", Group: "偏好"})
+	require.NoError(t, err)
+
+	t.Run("字典校验", func(t *testing.T) {
+		_, err := tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: "vip"})
+		assertCode(t, err, common.ErrCodeTagExists)
+		_, err = tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: "  "})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = tagSvc.CreateTag(ctx, crm.CreateCustomerTagReq{Name: "红色", Color: "red"})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		name := "老客"
+		_, err = tagSvc.UpdateTag(ctx, crm.UpdateCustomerTagReq{ID: vip.ID, Name: &name})
+		assertCode(t, err, common.ErrCodeTagExists)
+		_, err = tagSvc.UpdateTag(ctx, crm.UpdateCustomerTagReq{ID: 9999, Name: &name})
+		assertCode(t, err, common.ErrCodeTagNotFound)
+
+		tags, err := tagSvc.ListTags(ctx, "等级")
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+		assert.Equal(t, "VIP", tags[0].Name, "按组内排序返回")
+	})
+
+	var wangID, liID, zhaoID int64
+	t.Run("写入客户时统一为字典写法", func(t *testing.T) {
+		wang, err := crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "王小美", Phone: "13800000021", Tags: []string{"vip ", "染发", "VIP", "新客"}})
+		require.NoError(t, err)
+		wangID = wang.ID
+		assert.Equal(t, []string{"VIP", "染发", "新客"}, wang.Tags, "字典外标签保留")
+
+		li, err := crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "李小花", Phone: "13800000022", Tags: []string{"老客"}})
+		require.NoError(t, err)
+		liID = li.ID
+		zhao, err := crmSvc.CreateCustomerLegacy(ctx, &crm.CustomerCreateRequest{Name: "赵小云", Phone: "13800000023"})
+		require.NoError(t, err)
+		zhaoID = zhao.ID
+
+		tags := []string{"老客", "Vip"}
+		require.NoError(t, crmSvc.UpdateCustomerLegacy(ctx, strconv.FormatInt(liID, 10), &crm.CustomerUpdateRequest{Tags: &tags}))
+		assert.Equal(t, []string{"老客", "VIP"}, customerTags(t, liID))
+	})
+
+	t.Run("按标签筛选客户列表", func(t *testing.T) {
+		list := func(tags []string, matchAll bool) []string {
+			result, err := crmSvc.ListCustomersLegacy(ctx, &crm.CustomerListRequest{Page: 1, PageSize: 10, OrderBy: "id_asc", Tags: tags, TagMatchAll: matchAll})
+			require.NoError(t, err)
+			names := make([]string, 0, len(result.Customers))
+			for _, c := range result.Customers {
+				names = append(names, c.Name)
+			}
+			return names
+		}
+		assert.Equal(t, []string{"王小美", "李小花"}, list([]string{"vip"}, false))
+		assert.Equal(t, []string{"王小美", "李小花"}, list([]string{"染发", "老客"}, false))
+		assert.Equal(t, []string{"王小美"}, list([]string{"vip", "染发"}, true))
+		assert.Empty(t, list([]string{"染发", "老客"}, true))
+		assert.Len(t, list([]string{" "}, false), 3, "空标签条件忽略")
+	})
+
+	t.Run("批量打标签", func(t *testing.T) {
+		_, err := tagSvc.BulkTagCustomers(ctx, crm.BulkTagCustomersReq{Add: []string{"老客"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = tagSvc.BulkTagCustomers(ctx, crm.BulkTagCustomersReq{Filter: crm.CustomerListRequest{Tags: []string{"VIP"}}, Add: []string{"未建档"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		_, err = tagSvc.BulkTagCustomers(ctx, crm.BulkTagCustomersReq{Filter: crm.CustomerListRequest{Tags: []string{"VIP"}}, Add: []string{"老客"}, Remove: []string{"老客"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		result, err := tagSvc.BulkTagCustomers(ctx, crm.BulkTagCustomersReq{
+			Filter: crm.CustomerListRequest{Tags: []string{"VIP"}},
+			Add:    []string{"老客"},
+			Remove: []string{"染发"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &crm.CustomerTagUpdateResult{Matched: 2, Updated: 1}, result, "李小花已有老客且无染发")
+		assert.Equal(t, []string{"VIP", "新客", "老客"}, customerTags(t, wangID))
+
+		result, err = tagSvc.BulkTagCustomers(ctx, crm.BulkTagCustomersReq{
+			Filter: crm.CustomerListRequest{IDs: []int64{zhaoID}},
+			Add:    []string{"染发"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &crm.CustomerTagUpdateResult{Matched: 1, Updated: 1}, result)
+		assert.Equal(t, []string{"染发"}, customerTags(t, zhaoID))
+	})
+
+	t.Run("使用统计包含字典外写法", func(t *testing.T) {
+		// 模拟字典上线前写入的历史标签
+		require.NoError(t, db.Table("customers").Where("id = ?", zhaoID).Update("tags", `["染发","vip "]`).Error)
+
+		usage, err := tagSvc.ListTagUsage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []crm.CustomerTagUsage{
+			{Name: "VIP", Count: 2, Managed: true},
+			{Name: "老客", Count: 2, Managed: true},
+			{Name: "vip ", Count: 1, Managed: false},
+			{Name: "新客", Count: 1, Managed: false},
+			{Name: "染发", Count: 1, Managed: true},
+		}, usage)
+
+		tags, err := tagSvc.ListTags(ctx, "")
+		require.NoError(t, err)
+		counts := make(map[string]int64, len(tags))
+		for _, tag := range tags {
+			counts[tag.Name] = tag.UsageCount
+		}
+		assert.Equal(t, map[string]int64{"VIP": 2, "老客": 2, "染发": 1}, counts)
+	})
+
+	t.Run("改名同步改写客户标签", func(t *testing.T) {
+		name := "会员"
+		renamed, err := tagSvc.UpdateTag(ctx, crm.UpdateCustomerTagReq{ID: vip.ID, Name: &name})
+		require.NoError(t, err)
+		assert.Equal(t, "会员", renamed.Name)
+		assert.Equal(t, int64(2), renamed.UsageCount)
+		assert.Equal(t, []string{"会员", "新客", "老客"}, customerTags(t, wangID))
+		assert.Equal(t, []string{"染发", "vip "}, customerTags(t, zhaoID), "字典外写法不受改名影响")
+	})
+
+	t.Run("合并标签", func(t *testing.T) {
+		_, err := tagSvc.MergeTags(ctx, crm.MergeCustomerTagsReq{TargetID: vip.ID, Sources: []string{"会员"}})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		result, err := tagSvc.MergeTags(ctx, crm.MergeCustomerTagsReq{TargetID: vip.ID, Sources: []string{"vip ", "老客"}})
+		require.NoError(t, err)
+		assert.Equal(t, &crm.CustomerTagUpdateResult{Matched: 3, Updated: 3}, result)
+		assert.Equal(t, []string{"会员", "新客"}, customerTags(t, wangID))
+		assert.Equal(t, []string{"会员"}, customerTags(t, liID))
+		assert.Equal(t, []string{"染发", "会员"}, customerTags(t, zhaoID))
+
+		_, err = tagSvc.UpdateTag(ctx, crm.UpdateCustomerTagReq{ID: regular.ID})
+		assertCode(t, err, common.ErrCodeTagNotFound)
+	})
+
+	t.Run("删除标签从客户上移除", func(t *testing.T) {
+		result, err := tagSvc.DeleteTag(ctx, dyed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &crm.CustomerTagUpdateResult{Matched: 1, Updated: 1}, result)
+		assert.Equal(t, []string{"会员"}, customerTags(t, zhaoID))
+		_, err = tagSvc.DeleteTag(ctx, dyed.ID)
+		assertCode(t, err, common.ErrCodeTagNotFound)
+	})
+}
@@ -0,0 +1,65 @@
+package impl
+
+// CustomerMergeRecord 映射 customer_merges（客户合并记录）
+type CustomerMergeRecord struct {
+	ID                       int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	SurvivorID               int64  `gorm:"column:survivor_id;not null;index:idx_merge_survivor,priority:1"`
+	LoserID                  int64  `gorm:"column:loser_id;not null;uniqueIndex:uk_merge_loser"`
+	MovedContacts            int64  `gorm:"column:moved_contacts;not null;default:0"`
+	MovedOrders              int64  `gorm:"column:moved_orders;not null;default:0"`
+	MovedActivities          int64  `gorm:"column:moved_activities;not null;default:0"`
+	MovedMarketingRecords    int64  `gorm:"column:moved_marketing_records;not null;default:0"`
+	MovedPackages            int64  `gorm:"column:moved_packages;not null;default:0"`
+	MovedPackageTransactions int64  `gorm:"column:moved_package_transactions;not null;default:0"`
+	MovedAppointments        int64  `gorm:"column:moved_appointments;not null;default:0"`
+	MovedCouponRedemptions   int64  `gorm:"column:moved_coupon_redemptions;not null;default:0"`
+	WalletAmount             int64  `gorm:"column:wallet_amount;not null;default:0"` // cents
+	WalletTransferID         int64  `gorm:"column:wallet_transfer_id;not null;default:0"`
+	LoserSnapshot            string `gorm:"column:loser_snapshot;type:text;not null"`
+	OperatorID               int64  `gorm:"column:operator_id;not null;default:0"`
+	Reason                   string `gorm:"column:reason;size:255;not null;default:''"`
+	CreatedAt                int64  `gorm:"column:created_at;not null;index:idx_merge_survivor,priority:2"`
+}
+
+func (CustomerMergeRecord) TableName() string { return "customer_merges" }
+
+// CustomFieldRecord 映射 customer_custom_fields（客户自定义字段定义）
+type CustomFieldRecord struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Key       string `gorm:"column:field_key;size:50;not null;uniqueIndex:uk_custom_field_key"`
+	Label     string `gorm:"column:label;size:50;not null"`
+	Type      string `gorm:"column:field_type;size:20;not null"`
+	Options   string `gorm:"column:options;type:text;not null"` // JSON array
+	Required  bool   `gorm:"column:required;not null;default:false"`
+	Sort      int    `gorm:"column:sort;not null;default:0"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+	UpdatedAt int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CustomFieldRecord) TableName() string { return "customer_custom_fields" }
+
+// CustomFieldValueRecord 映射 customer_custom_field_values（客户自定义字段值）
+type CustomFieldValueRecord struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CustomerID int64  `gorm:"column:customer_id;not null;uniqueIndex:uk_custom_field_value,priority:1"`
+	FieldID    int64  `gorm:"column:field_id;not null;uniqueIndex:uk_custom_field_value,priority:2;index:idx_custom_field_value_field"`
+	Value      string `gorm:"column:value;size:1024;not null"`
+	CreatedAt  int64  `gorm:"column:created_at;not null"`
+	UpdatedAt  int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CustomFieldValueRecord) TableName() string { return "customer_custom_field_values" }
+
+// CustomerTagRecord 映射 customer_tags（客户标签字典）
+type CustomerTagRecord struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	Name      string `gorm:"column:name;size:50;not null"`
+	NameKey   string `gorm:"column:name_key;size:50;not null;uniqueIndex:uk_customer_tag_name_key"` // lower(trim(name))
+	Color     string `gorm:"column:color;size:7;not null"`
+	Group     string `gorm:"column:group_name;size:50;not null;default:''"`
+	Sort      int    `gorm:"column:sort;not null;default:0"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+	UpdatedAt int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CustomerTagRecord) TableName() string { return "customer_tags" }
@@ -1,8 +1,10 @@
 package impl
 
 import (
+	"crm_lite/internal/common"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/billing"
+	billingimpl "crm_lite/internal/domains/billing/impl"
 	"crm_lite/internal/domains/crm"
 
 	"gorm.io/gorm"
@@ -20,3 +22,15 @@ func NewCRMServiceWithBilling(db *gorm.DB, billingSvc billing.Service) crm.Servi
 	walletAdapter := newBillingAdapter(billingSvc)
 	return NewCRMService(q, walletAdapter)
 }
+
+// NewCustomerMergeServiceFromDB 创建客户合并服务实例
+// 钱包余额加锁读取与转账按 wallet.storeMode 配置选择 billing 实现
+func NewCustomerMergeServiceFromDB(db *gorm.DB) crm.CustomerMergeService {
+	return NewCustomerMergeService(db, common.NewTx(db), billingimpl.NewTransferService(db))
+}
+
+// NewCustomerImportServiceFromDB 创建客户导入服务实例，期初余额按 wallet.storeMode 配置选择 billing 实现
+func NewCustomerImportServiceFromDB(db *gorm.DB) crm.CustomerImportService {
+	tx := common.NewTx(db)
+	return NewCustomerImportService(tx, billingimpl.NewBillingServiceWithTx(db, tx))
+}
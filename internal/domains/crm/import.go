@@ -0,0 +1,77 @@
+package crm
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// 客户导入文件格式
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatXLSX = "xlsx"
+)
+
+// CustomerImportMaxRows 单次导入的最大数据行数
+const CustomerImportMaxRows = 5000
+
+// 导入行处理结果；试运行时 created/updated 表示将要执行的操作
+const (
+	ImportActionCreated = "created"
+	ImportActionUpdated = "updated"
+	ImportActionFailed  = "failed"
+)
+
+// ImportFormatFromFilename 按文件扩展名识别导入格式，无法识别时返回空字符串
+func ImportFormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return ImportFormatCSV
+	case ".xlsx":
+		return ImportFormatXLSX
+	}
+	return ""
+}
+
+// CustomerImportOptions 客户导入选项
+type CustomerImportOptions struct {
+	Format            string // csv/xlsx
+	DryRun            bool   // 只校验并预判新建/更新，不写入数据
+	OperatorID        int64
+	DefaultAssignedTo int64 // 未填写负责人时使用的员工ID，0 表示不分配
+
+	// Authorize 校验能否更新已存在的客户，为 nil 时不校验
+	Authorize func(ctx context.Context, customerID int64) (bool, error)
+}
+
+// CustomerImportRow 单行导入结果，Row 为文件中的行号（表头为第 1 行）
+type CustomerImportRow struct {
+	Row            int      `json:"row"`
+	Name           string   `json:"name"`
+	Phone          string   `json:"phone"` // 规范化后的手机号
+	Action         string   `json:"action"`
+	CustomerID     int64    `json:"customer_id"`     // 新建行试运行时为 0
+	OpeningBalance int64    `json:"opening_balance"` // 期初余额（分）
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// CustomerImportReport 客户导入报告
+type CustomerImportReport struct {
+	DryRun         bool                `json:"dry_run"`
+	Total          int                 `json:"total"`
+	Created        int                 `json:"created"`
+	Updated        int                 `json:"updated"`
+	Failed         int                 `json:"failed"`
+	OpeningBalance int64               `json:"opening_balance"`           // 成功行的期初余额合计（分）
+	IgnoredColumns []string            `json:"ignored_columns,omitempty"` // 无法识别的表头
+	Rows           []CustomerImportRow `json:"rows"`
+}
+
+// CustomerImportService 客户批量导入服务接口
+type CustomerImportService interface {
+	// ImportCustomers 读取 CSV/XLSX 文件，首行为表头，按手机号新建或更新客户
+	// 每行在独立事务中处理，失败行不影响其他行；期初余额每个客户只入账一次，已入账过的客户再次填写期初余额时该行记为失败
+	// 文件无法解析、缺少姓名或手机号列、超过行数上限时返回 INVALID_PARAM
+	ImportCustomers(ctx context.Context, r io.Reader, opts CustomerImportOptions) (*CustomerImportReport, error)
+}
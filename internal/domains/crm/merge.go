@@ -0,0 +1,67 @@
+package crm
+
+import "context"
+
+// 重复客户匹配原因
+const (
+	DuplicateReasonPhone = "phone" // 规范化后的手机号相同
+	DuplicateReasonEmail = "email" // 邮箱相同（忽略大小写与首尾空白）
+	DuplicateReasonName  = "name"  // 姓名相似度达到阈值
+)
+
+// DuplicateNameSimilarity 姓名相似度阈值，按规范化姓名的编辑距离计算，取值 0~1
+const DuplicateNameSimilarity = 0.8
+
+// DuplicateCandidate 疑似重复客户
+// Score 为匹配得分：手机号或邮箱相同记 1，仅姓名相似时取姓名相似度
+type DuplicateCandidate struct {
+	Customer       Customer `json:"customer"`
+	Reasons        []string `json:"reasons"`         // 匹配原因：phone/email/name
+	NameSimilarity float64  `json:"name_similarity"` // 姓名相似度
+	Score          float64  `json:"score"`
+}
+
+// MergeCustomersReq 合并客户请求，将 LoserID 的数据并入 SurvivorID
+type MergeCustomersReq struct {
+	SurvivorID int64  `json:"survivor_id"` // 保留的客户
+	LoserID    int64  `json:"loser_id"`    // 被合并的客户，合并后软删除
+	OperatorID int64  `json:"operator_id"`
+	Reason     string `json:"reason"`
+}
+
+// CustomerMerge 客户合并审计记录
+// LoserSnapshot 保存被合并客户合并前的资料（JSON），便于追溯
+type CustomerMerge struct {
+	ID                       int64  `json:"id"`
+	SurvivorID               int64  `json:"survivor_id"`
+	LoserID                  int64  `json:"loser_id"`
+	MovedContacts            int64  `json:"moved_contacts"`
+	MovedOrders              int64  `json:"moved_orders"`
+	MovedActivities          int64  `json:"moved_activities"`
+	MovedMarketingRecords    int64  `json:"moved_marketing_records"`
+	MovedPackages            int64  `json:"moved_packages"`             // 服务套餐
+	MovedPackageTransactions int64  `json:"moved_package_transactions"` // 服务套餐流水
+	MovedAppointments        int64  `json:"moved_appointments"`
+	MovedCouponRedemptions   int64  `json:"moved_coupon_redemptions"`
+	WalletAmount             int64  `json:"wallet_amount"`      // 转入保留客户钱包的余额（分）
+	WalletTransferID         int64  `json:"wallet_transfer_id"` // 钱包转账记录ID，余额为 0 时为 0
+	LoserSnapshot            string `json:"loser_snapshot"`
+	OperatorID               int64  `json:"operator_id"`
+	Reason                   string `json:"reason"`
+	CreatedAt                int64  `json:"created_at"`
+}
+
+// CustomerMergeService 重复客户识别与合并服务接口
+type CustomerMergeService interface {
+	// FindDuplicates 查找与指定客户疑似重复的客户，按得分降序，最多返回 limit 条
+	FindDuplicates(ctx context.Context, customerID int64, limit int) ([]DuplicateCandidate, error)
+
+	// MergeCustomers 在同一事务内将被合并客户的联系人、订单、活动、营销记录、服务套餐及流水、预约、优惠券核销改挂到保留客户，
+	// 钱包余额通过一对转账流水转入保留客户，软删除被合并客户，写入合并记录并发布 customer.merged 事件
+	// 被合并客户不会再被创建客户或客户导入按手机号恢复
+	// 任一方钱包冻结返回 WALLET_FROZEN，需先解冻再合并
+	MergeCustomers(ctx context.Context, req MergeCustomersReq) (*CustomerMerge, error)
+
+	// ListMerges 查询并入该客户的合并记录，按时间倒序
+	ListMerges(ctx context.Context, survivorID int64) ([]CustomerMerge, error)
+}
@@ -79,6 +79,9 @@ type CustomerCreateRequest struct {
 	Note       string   `json:"note"`
 	Source     string   `json:"source"`
 	AssignedTo int64    `json:"assigned_to"`
+
+	// CustomFields 自定义字段值，以字段 Key 为键；必填字段须提供
+	CustomFields map[string]interface{} `json:"custom_fields"`
 }
 
 // CustomerUpdateRequest 更新客户请求 - 兼容现有 DTO
@@ -93,6 +96,9 @@ type CustomerUpdateRequest struct {
 	Note       string    `json:"note"`
 	Source     string    `json:"source"`
 	AssignedTo int64     `json:"assigned_to"`
+
+	// CustomFields 只更新提供的字段，值为 nil 表示清空（必填字段不可清空）
+	CustomFields map[string]interface{} `json:"custom_fields"`
 }
 
 // CustomerListRequest 客户列表请求 - 兼容现有 DTO
@@ -104,6 +110,13 @@ type CustomerListRequest struct {
 	Phone    string  `json:"phone"`
 	Email    string  `json:"email"`
 	OrderBy  string  `json:"order_by"`
+
+	// CustomFields 按自定义字段筛选，以字段 Key 为键：text 模糊匹配，multi_select 包含该选项，其余类型精确匹配
+	CustomFields map[string]string `json:"custom_fields"`
+
+	// Tags 按标签筛选，名称忽略大小写匹配字典；TagMatchAll 为 true 时须包含全部标签，否则包含任一即可
+	Tags        []string `json:"tags"`
+	TagMatchAll bool     `json:"tag_match_all"`
 }
 
 // CustomerResponse 客户响应 - 兼容现有 DTO
@@ -122,6 +135,9 @@ type CustomerResponse struct {
 	WalletBalance int64    `json:"wallet_balance"`
 	CreatedAt     string   `json:"created_at"`
 	UpdatedAt     string   `json:"updated_at"`
+
+	// CustomFields 自定义字段值：number 为数字，multi_select 为字符串数组，其余为字符串
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"`
 }
 
 // CustomerListResponse 客户列表响应 - 兼容现有 DTO
@@ -0,0 +1,92 @@
+package crm
+
+import "context"
+
+// CustomerTagMaxLen 单个客户标签最大长度，与 customers.tags 多值索引的 CHAR(50) 一致
+const CustomerTagMaxLen = 50
+
+// CustomerTagDefaultColor 未指定颜色时使用的标签颜色
+const CustomerTagDefaultColor = "#909399"
+
+// CustomerTag 标签字典中的客户标签
+// 客户的 tags 仍保存标签名称；写入客户时按名称忽略大小写匹配字典，统一为字典中的写法
+type CustomerTag struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`        // 标签名称，忽略大小写唯一
+	Color      string `json:"color"`       // 颜色，#RRGGBB
+	Group      string `json:"group"`       // 分组，空表示未分组
+	Sort       int    `json:"sort"`        // 组内排序，越小越靠前
+	UsageCount int64  `json:"usage_count"` // 使用该标签的客户数（不含已删除客户）
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+// CreateCustomerTagReq 创建标签请求
+type CreateCustomerTagReq struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	Group string `json:"group"`
+	Sort  int    `json:"sort"`
+}
+
+// UpdateCustomerTagReq 更新标签请求，nil 表示不修改
+// 修改名称会同步改写所有客户上的该标签；新名称与其他标签重复时应使用 MergeTags
+type UpdateCustomerTagReq struct {
+	ID    int64   `json:"id"`
+	Name  *string `json:"name"`
+	Color *string `json:"color"`
+	Group *string `json:"group"`
+	Sort  *int    `json:"sort"`
+}
+
+// CustomerTagUsage 客户上实际使用的标签及客户数，Managed 表示该名称在标签字典中
+type CustomerTagUsage struct {
+	Name    string `json:"name"`
+	Count   int64  `json:"count"`
+	Managed bool   `json:"managed"`
+}
+
+// MergeCustomerTagsReq 合并标签请求
+// Sources 为按原样匹配的标签名称，可以是字典外的历史写法（如 "vip "）；字典中的来源标签合并后删除
+type MergeCustomerTagsReq struct {
+	TargetID int64    `json:"target_id"`
+	Sources  []string `json:"sources"`
+}
+
+// BulkTagCustomersReq 批量打标签请求
+// Filter 的筛选条件与客户列表一致（分页参数不生效），至少须指定一个条件；Add 中的标签须在字典中
+type BulkTagCustomersReq struct {
+	Filter CustomerListRequest `json:"filter"`
+	Add    []string            `json:"add"`
+	Remove []string            `json:"remove"`
+}
+
+// CustomerTagUpdateResult 标签批量改写结果
+type CustomerTagUpdateResult struct {
+	Matched int64 `json:"matched"` // 命中的客户数
+	Updated int64 `json:"updated"` // 标签实际发生变化的客户数
+}
+
+// CustomerTagService 客户标签字典与批量打标服务接口
+type CustomerTagService interface {
+	// CreateTag 创建标签，名称忽略大小写重复时返回 TAG_EXISTS
+	CreateTag(ctx context.Context, req CreateCustomerTagReq) (*CustomerTag, error)
+
+	// UpdateTag 更新标签，改名时同步改写客户标签
+	UpdateTag(ctx context.Context, req UpdateCustomerTagReq) (*CustomerTag, error)
+
+	// DeleteTag 删除标签并从所有客户上移除
+	DeleteTag(ctx context.Context, tagID int64) (*CustomerTagUpdateResult, error)
+
+	// ListTags 按分组与排序返回字典标签及使用数，group 为空时返回全部
+	ListTags(ctx context.Context, group string) ([]CustomerTag, error)
+
+	// ListTagUsage 按客户数倒序返回客户上实际使用的全部标签，用于发现字典外的写法
+	ListTagUsage(ctx context.Context) ([]CustomerTagUsage, error)
+
+	// MergeTags 将来源标签在所有客户上替换为目标标签并去重
+	MergeTags(ctx context.Context, req MergeCustomerTagsReq) (*CustomerTagUpdateResult, error)
+
+	// BulkTagCustomers 为筛选出的客户批量添加、移除标签
+	BulkTagCustomers(ctx context.Context, req BulkTagCustomersReq) (*CustomerTagUpdateResult, error)
+}
@@ -0,0 +1,49 @@
+package impl
+
+// PackagePlanRecord 映射 package_plans（套餐配置）
+type PackagePlanRecord struct {
+	ProductID        int64 `gorm:"column:product_id;primaryKey;autoIncrement:false"`
+	ServiceProductID int64 `gorm:"column:service_product_id;not null"`
+	TotalUses        int32 `gorm:"column:total_uses;not null"`
+	ValidDays        int32 `gorm:"column:valid_days;not null;default:0"` // 0 表示不过期
+	CreatedAt        int64 `gorm:"column:created_at;not null"`
+	UpdatedAt        int64 `gorm:"column:updated_at;not null"`
+}
+
+func (PackagePlanRecord) TableName() string { return "package_plans" }
+
+// CustomerPackageRecord 映射 customer_packages（客户套餐，剩余次数只读 by contract）
+type CustomerPackageRecord struct {
+	ID               int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CustomerID       int64  `gorm:"column:customer_id;index:idx_customer_package_customer,priority:1;not null"`
+	PackageProductID int64  `gorm:"column:package_product_id;not null"`
+	ServiceProductID int64  `gorm:"column:service_product_id;index:idx_customer_package_customer,priority:2;not null"`
+	OrderID          int64  `gorm:"column:order_id;not null"`
+	OrderItemID      int64  `gorm:"column:order_item_id;uniqueIndex:uk_customer_package_order_item;not null"`
+	Quantity         int32  `gorm:"column:quantity;not null"`
+	TotalUses        int32  `gorm:"column:total_uses;not null"`
+	RemainingUses    int32  `gorm:"column:remaining_uses;not null"`
+	ExpiresAt        int64  `gorm:"column:expires_at;not null;default:0"` // 0 表示不过期
+	Status           string `gorm:"column:status;size:20;not null"`
+	CreatedAt        int64  `gorm:"column:created_at;not null"`
+	UpdatedAt        int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CustomerPackageRecord) TableName() string { return "customer_packages" }
+
+// CustomerPackageTxRecord 映射 customer_package_transactions（套餐流水，真相表）
+type CustomerPackageTxRecord struct {
+	ID                int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CustomerPackageID int64  `gorm:"column:customer_package_id;index:idx_customer_package_tx_package,priority:1;not null"`
+	CustomerID        int64  `gorm:"column:customer_id;not null"`
+	Type              string `gorm:"column:type;size:20;not null"`
+	Uses              int32  `gorm:"column:uses;not null"` // 正数
+	RemainingAfter    int32  `gorm:"column:remaining_after;not null"`
+	OrderID           int64  `gorm:"column:order_id;not null;default:0"`
+	OrderItemID       int64  `gorm:"column:order_item_id;index:idx_customer_package_tx_order_item;not null;default:0"`
+	IdempotencyKey    string `gorm:"column:idempotency_key;uniqueIndex:uk_customer_package_tx_idem;size:64;not null"`
+	Note              string `gorm:"column:note;size:255"`
+	CreatedAt         int64  `gorm:"column:created_at;index:idx_customer_package_tx_package,priority:2;not null"`
+}
+
+func (CustomerPackageTxRecord) TableName() string { return "customer_package_transactions" }
@@ -0,0 +1,17 @@
+package impl
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/query"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/entitlement"
+
+	"gorm.io/gorm"
+)
+
+// NewEntitlementService 创建次卡套餐服务实例
+func NewEntitlementService(db *gorm.DB) entitlement.Service {
+	tx := common.NewTx(db)
+	catalogService := catalogImpl.NewWithTx(query.Use(db), tx)
+	return NewEntitlementServiceImpl(db, tx, catalogService)
+}
@@ -0,0 +1,503 @@
+// Package impl entitlement域的完整实现
+// 实现剩余次数只读、幂等性、行级锁等核心特性
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/entitlement"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EntitlementServiceImpl 次卡套餐域服务实现
+// 剩余次数只读原则：所有次数变更通过套餐流水实现
+type EntitlementServiceImpl struct {
+	db         *gorm.DB
+	tx         common.Tx
+	catalogSvc catalog.Service
+}
+
+// NewEntitlementServiceImpl 创建次卡套餐服务实例
+// tx 需与 sales 域共享，保证下单、退款与套餐流水在同一事务中
+func NewEntitlementServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service) *EntitlementServiceImpl {
+	return &EntitlementServiceImpl{
+		db:         db,
+		tx:         tx,
+		catalogSvc: catalogSvc,
+	}
+}
+
+// ===== 套餐配置 =====
+
+// SetPackagePlan 设置套餐配置
+func (s *EntitlementServiceImpl) SetPackagePlan(ctx context.Context, req entitlement.SetPackagePlanRequest) (*entitlement.PackagePlan, error) {
+	if req.TotalUses <= 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "套餐次数必须大于0")
+	}
+	if req.ValidDays < 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "有效天数不能为负数")
+	}
+
+	products, err := s.catalogSvc.BatchGet(ctx, []int64{req.ProductID, req.ServiceProductID})
+	if err != nil {
+		return nil, fmt.Errorf("获取产品信息失败: %w", err)
+	}
+	productMap := make(map[int64]catalog.Product, len(products))
+	for _, p := range products {
+		productMap[p.ID] = p
+	}
+	pkg, ok := productMap[req.ProductID]
+	if !ok {
+		return nil, common.NewBusinessError(common.ErrCodeProductNotFound, "套餐产品不存在")
+	}
+	if pkg.Type != string(constants.ProductTypePackage) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "产品类型不是套餐")
+	}
+	svc, ok := productMap[req.ServiceProductID]
+	if !ok {
+		return nil, common.NewBusinessError(common.ErrCodeProductNotFound, "可抵扣的服务产品不存在")
+	}
+	if svc.Type != string(constants.ProductTypeService) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "套餐只能抵扣服务类产品")
+	}
+
+	now := time.Now().Unix()
+	record := &PackagePlanRecord{
+		ProductID:        req.ProductID,
+		ServiceProductID: req.ServiceProductID,
+		TotalUses:        req.TotalUses,
+		ValidDays:        req.ValidDays,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+		existing, err := s.getPlan(ctx, txDB, req.ProductID)
+		if err == nil {
+			record.CreatedAt = existing.CreatedAt
+			return txDB.WithContext(ctx).Model(&PackagePlanRecord{}).Where("product_id = ?", req.ProductID).
+				Updates(map[string]interface{}{
+					"service_product_id": record.ServiceProductID,
+					"total_uses":         record.TotalUses,
+					"valid_days":         record.ValidDays,
+					"updated_at":         record.UpdatedAt,
+				}).Error
+		}
+		var bizErr *common.BusinessError
+		if !errors.As(err, &bizErr) {
+			return err
+		}
+		if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建套餐配置失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPackagePlan(record), nil
+}
+
+// GetPackagePlan 获取套餐配置
+func (s *EntitlementServiceImpl) GetPackagePlan(ctx context.Context, productID int64) (*entitlement.PackagePlan, error) {
+	record, err := s.getPlan(ctx, s.tx.GetDB(ctx), productID)
+	if err != nil {
+		return nil, err
+	}
+	return toPackagePlan(record), nil
+}
+
+// ===== 下单集成 =====
+
+// GrantForOrderItem 为购买套餐的订单项发放客户套餐
+// 有效期自发放时起算，次数与有效期取自当前套餐配置
+func (s *EntitlementServiceImpl) GrantForOrderItem(ctx context.Context, req entitlement.GrantRequest) (*entitlement.CustomerPackage, error) {
+	if req.Qty <= 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "购买份数必须大于0")
+	}
+
+	var result *entitlement.CustomerPackage
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		// 1. 幂等性检查：同一订单项只发放一次
+		var existing CustomerPackageRecord
+		err := txDB.WithContext(ctx).Where("order_item_id = ?", req.OrderItemID).First(&existing).Error
+		if err == nil {
+			result = toCustomerPackage(&existing)
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查幂等性失败: %w", err)
+		}
+
+		// 2. 按套餐配置计算次数与有效期
+		plan, err := s.getPlan(ctx, txDB, req.PackageProductID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		var expiresAt int64
+		if plan.ValidDays > 0 {
+			expiresAt = now.AddDate(0, 0, int(plan.ValidDays)).Unix()
+		}
+
+		record := &CustomerPackageRecord{
+			CustomerID:       req.CustomerID,
+			PackageProductID: req.PackageProductID,
+			ServiceProductID: plan.ServiceProductID,
+			OrderID:          req.OrderID,
+			OrderItemID:      req.OrderItemID,
+			Quantity:         req.Qty,
+			TotalUses:        plan.TotalUses * req.Qty,
+			ExpiresAt:        expiresAt,
+			Status:           string(constants.CustomerPackageStatusActive),
+			CreatedAt:        now.Unix(),
+			UpdatedAt:        now.Unix(),
+		}
+		if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建客户套餐失败: %w", err)
+		}
+
+		// 3. 购买入账流水
+		if _, err := s.appendTx(ctx, record, string(constants.PackageTxTypePurchase), record.TotalUses,
+			req.OrderID, req.OrderItemID, fmt.Sprintf("package_purchase_%d", req.OrderItemID), "购买套餐"); err != nil {
+			return err
+		}
+		result = toCustomerPackage(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Redeem 按次抵扣服务
+// 候选套餐加行锁后选择最早过期的一条，不跨套餐拆分抵扣
+func (s *EntitlementServiceImpl) Redeem(ctx context.Context, req entitlement.RedeemRequest) (*entitlement.PackageTransaction, error) {
+	if req.Qty <= 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "抵扣次数必须大于0")
+	}
+	if req.IdemKey == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "幂等键不能为空")
+	}
+
+	var result *entitlement.PackageTransaction
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		// 1. 幂等性检查
+		existing, err := s.getTxByIdem(ctx, txDB, req.IdemKey)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			result = toPackageTransaction(existing)
+			return nil
+		}
+
+		// 2. 锁定可用套餐并选择最早过期的一条
+		var candidates []CustomerPackageRecord
+		if err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("customer_id = ? AND service_product_id = ? AND status = ? AND remaining_uses >= ?",
+				req.CustomerID, req.ServiceProductID, string(constants.CustomerPackageStatusActive), req.Qty).
+			Where("expires_at = 0 OR expires_at > ?", time.Now().Unix()).
+			Find(&candidates).Error; err != nil {
+			return fmt.Errorf("查询客户套餐失败: %w", err)
+		}
+		if len(candidates) == 0 {
+			return common.NewBusinessError(common.ErrCodePackageInsufficient, "没有可用的套餐次数")
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if (a.ExpiresAt == 0) != (b.ExpiresAt == 0) {
+				return b.ExpiresAt == 0
+			}
+			if a.ExpiresAt != b.ExpiresAt {
+				return a.ExpiresAt < b.ExpiresAt
+			}
+			return a.ID < b.ID
+		})
+
+		// 3. 抵扣流水
+		record, err := s.appendTx(ctx, &candidates[0], string(constants.PackageTxTypeRedeem), -req.Qty,
+			req.OrderID, req.OrderItemID, req.IdemKey, fmt.Sprintf("订单抵扣: %d", req.OrderID))
+		if err != nil {
+			return err
+		}
+		result = toPackageTransaction(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReverseOrderItem 冲回订单项对应的套餐变更
+func (s *EntitlementServiceImpl) ReverseOrderItem(ctx context.Context, req entitlement.ReverseRequest) error {
+	if req.Qty <= 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "冲回数量必须大于0")
+	}
+	if req.IdemKey == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "幂等键不能为空")
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		// 1. 幂等性检查
+		existing, err := s.getTxByIdem(ctx, txDB, req.IdemKey)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return nil
+		}
+
+		// 2. 抵扣订单项：退回尚未退回的抵扣次数
+		var redeemTxs []CustomerPackageTxRecord
+		if err := txDB.WithContext(ctx).
+			Where("order_item_id = ? AND type IN ?", req.OrderItemID,
+				[]string{string(constants.PackageTxTypeRedeem), string(constants.PackageTxTypeRestore)}).
+			Find(&redeemTxs).Error; err != nil {
+			return fmt.Errorf("查询抵扣流水失败: %w", err)
+		}
+		if len(redeemTxs) > 0 {
+			var outstanding int32
+			for _, t := range redeemTxs {
+				if t.Type == string(constants.PackageTxTypeRedeem) {
+					outstanding += t.Uses
+				} else {
+					outstanding -= t.Uses
+				}
+			}
+			uses := req.Qty
+			if uses > outstanding {
+				uses = outstanding
+			}
+			if uses <= 0 {
+				return nil
+			}
+			pkg, err := s.lockCustomerPackage(ctx, "id = ?", redeemTxs[0].CustomerPackageID)
+			if err != nil {
+				return err
+			}
+			_, err = s.appendTx(ctx, pkg, string(constants.PackageTxTypeRestore), uses,
+				redeemTxs[0].OrderID, req.OrderItemID, req.IdemKey, req.Note)
+			return err
+		}
+
+		// 3. 购买订单项：按份数扣回次数，已使用的次数不可退
+		pkg, err := s.lockCustomerPackage(ctx, "order_item_id = ?", req.OrderItemID)
+		if err != nil {
+			var bizErr *common.BusinessError
+			if errors.As(err, &bizErr) && bizErr.Code == common.ErrCodePackageNotFound {
+				return nil
+			}
+			return err
+		}
+		if pkg.Status == string(constants.CustomerPackageStatusRevoked) {
+			return nil
+		}
+		uses := pkg.TotalUses / pkg.Quantity * req.Qty
+		if pkg.RemainingUses < uses {
+			return common.NewBusinessErrorWithDetails(common.ErrCodePackageUsed, "套餐已使用，不能退款",
+				fmt.Sprintf("剩余次数：%d，需扣回：%d", pkg.RemainingUses, uses))
+		}
+		_, err = s.appendTx(ctx, pkg, string(constants.PackageTxTypeRevoke), -uses,
+			pkg.OrderID, req.OrderItemID, req.IdemKey, req.Note)
+		return err
+	})
+}
+
+// ===== 查询 =====
+
+// ListCustomerPackages 查询客户套餐
+func (s *EntitlementServiceImpl) ListCustomerPackages(ctx context.Context, customerID int64, activeOnly bool) ([]entitlement.CustomerPackage, error) {
+	db := s.db.WithContext(ctx).Where("customer_id = ?", customerID)
+	if activeOnly {
+		db = db.Where("status = ? AND remaining_uses > 0", string(constants.CustomerPackageStatusActive)).
+			Where("expires_at = 0 OR expires_at > ?", time.Now().Unix())
+	}
+
+	var records []CustomerPackageRecord
+	if err := db.Order("id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询客户套餐失败: %w", err)
+	}
+	result := make([]entitlement.CustomerPackage, len(records))
+	for i := range records {
+		result[i] = *toCustomerPackage(&records[i])
+	}
+	return result, nil
+}
+
+// GetCustomerPackage 获取客户套餐详情
+func (s *EntitlementServiceImpl) GetCustomerPackage(ctx context.Context, customerPackageID int64) (*entitlement.CustomerPackage, error) {
+	var record CustomerPackageRecord
+	if err := s.db.WithContext(ctx).Where("id = ?", customerPackageID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodePackageNotFound, "客户套餐不存在")
+		}
+		return nil, fmt.Errorf("查询客户套餐失败: %w", err)
+	}
+	return toCustomerPackage(&record), nil
+}
+
+// ListPackageTransactions 查询客户套餐的使用流水
+func (s *EntitlementServiceImpl) ListPackageTransactions(ctx context.Context, customerPackageID int64) ([]entitlement.PackageTransaction, error) {
+	if _, err := s.GetCustomerPackage(ctx, customerPackageID); err != nil {
+		return nil, err
+	}
+
+	var records []CustomerPackageTxRecord
+	if err := s.db.WithContext(ctx).Where("customer_package_id = ?", customerPackageID).
+		Order("id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询套餐流水失败: %w", err)
+	}
+	result := make([]entitlement.PackageTransaction, len(records))
+	for i := range records {
+		result[i] = *toPackageTransaction(&records[i])
+	}
+	return result, nil
+}
+
+// ===== 内部方法 =====
+
+// appendTx 写入套餐流水并原子更新剩余次数，必须在事务中对已加锁的套餐调用
+// delta 为正表示入账，为负表示扣减；扣回后剩余为 0 的套餐标记为作废
+func (s *EntitlementServiceImpl) appendTx(ctx context.Context, pkg *CustomerPackageRecord, txType string, delta int32, orderID, orderItemID int64, idem, note string) (*CustomerPackageTxRecord, error) {
+	txDB := s.tx.GetDB(ctx)
+	now := time.Now().Unix()
+
+	uses := delta
+	remaining := pkg.RemainingUses + delta
+	if uses < 0 {
+		uses = -uses
+	}
+	status := pkg.Status
+	if txType == string(constants.PackageTxTypeRevoke) && remaining == 0 {
+		status = string(constants.CustomerPackageStatusRevoked)
+	}
+
+	record := &CustomerPackageTxRecord{
+		CustomerPackageID: pkg.ID,
+		CustomerID:        pkg.CustomerID,
+		Type:              txType,
+		Uses:              uses,
+		RemainingAfter:    remaining,
+		OrderID:           orderID,
+		OrderItemID:       orderItemID,
+		IdempotencyKey:    idem,
+		Note:              note,
+		CreatedAt:         now,
+	}
+	if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建套餐流水失败: %w", err)
+	}
+
+	if err := txDB.WithContext(ctx).Model(&CustomerPackageRecord{}).Where("id = ?", pkg.ID).
+		Updates(map[string]interface{}{
+			"remaining_uses": remaining,
+			"status":         status,
+			"updated_at":     now,
+		}).Error; err != nil {
+		return nil, fmt.Errorf("更新套餐剩余次数失败: %w", err)
+	}
+	pkg.RemainingUses = remaining
+	pkg.Status = status
+	pkg.UpdatedAt = now
+	return record, nil
+}
+
+// getPlan 查询套餐配置，不存在时返回 PACKAGE_NOT_FOUND
+func (s *EntitlementServiceImpl) getPlan(ctx context.Context, db *gorm.DB, productID int64) (*PackagePlanRecord, error) {
+	var record PackagePlanRecord
+	if err := db.WithContext(ctx).Where("product_id = ?", productID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodePackageNotFound, "套餐未配置")
+		}
+		return nil, fmt.Errorf("查询套餐配置失败: %w", err)
+	}
+	return &record, nil
+}
+
+// getTxByIdem 按幂等键查询套餐流水，不存在时返回 nil
+func (s *EntitlementServiceImpl) getTxByIdem(ctx context.Context, db *gorm.DB, idem string) (*CustomerPackageTxRecord, error) {
+	var record CustomerPackageTxRecord
+	err := db.WithContext(ctx).Where("idempotency_key = ?", idem).First(&record).Error
+	if err == nil {
+		return &record, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("检查幂等性失败: %w", err)
+}
+
+// lockCustomerPackage 在事务中锁定客户套餐
+func (s *EntitlementServiceImpl) lockCustomerPackage(ctx context.Context, cond string, args ...interface{}) (*CustomerPackageRecord, error) {
+	var record CustomerPackageRecord
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(cond, args...).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodePackageNotFound, "客户套餐不存在")
+		}
+		return nil, fmt.Errorf("查询客户套餐失败: %w", err)
+	}
+	return &record, nil
+}
+
+func toPackagePlan(r *PackagePlanRecord) *entitlement.PackagePlan {
+	return &entitlement.PackagePlan{
+		ProductID:        r.ProductID,
+		ServiceProductID: r.ServiceProductID,
+		TotalUses:        r.TotalUses,
+		ValidDays:        r.ValidDays,
+		CreatedAt:        r.CreatedAt,
+		UpdatedAt:        r.UpdatedAt,
+	}
+}
+
+func toCustomerPackage(r *CustomerPackageRecord) *entitlement.CustomerPackage {
+	return &entitlement.CustomerPackage{
+		ID:               r.ID,
+		CustomerID:       r.CustomerID,
+		PackageProductID: r.PackageProductID,
+		ServiceProductID: r.ServiceProductID,
+		OrderID:          r.OrderID,
+		OrderItemID:      r.OrderItemID,
+		Quantity:         r.Quantity,
+		TotalUses:        r.TotalUses,
+		RemainingUses:    r.RemainingUses,
+		ExpiresAt:        r.ExpiresAt,
+		Status:           r.Status,
+		CreatedAt:        r.CreatedAt,
+		UpdatedAt:        r.UpdatedAt,
+	}
+}
+
+func toPackageTransaction(r *CustomerPackageTxRecord) *entitlement.PackageTransaction {
+	return &entitlement.PackageTransaction{
+		ID:                r.ID,
+		CustomerPackageID: r.CustomerPackageID,
+		CustomerID:        r.CustomerID,
+		Type:              r.Type,
+		Uses:              r.Uses,
+		RemainingAfter:    r.RemainingAfter,
+		OrderID:           r.OrderID,
+		OrderItemID:       r.OrderItemID,
+		IdempotencyKey:    r.IdempotencyKey,
+		Note:              r.Note,
+		CreatedAt:         r.CreatedAt,
+	}
+}
@@ -0,0 +1,118 @@
+// Package entitlement 次卡套餐域服务接口
+// 职责：套餐配置、客户套餐（剩余次数 + 有效期）、按次抵扣与退回、使用流水
+// 核心原则：与 billing 钱包并列的独立账本，剩余次数只读，一切变更通过带幂等键的套餐流水实现
+package entitlement
+
+import "context"
+
+// PackagePlan 套餐配置，挂在 package 类型产品上
+// 例如「精剪 10 次卡」：购买 1 份获得 10 次精剪服务，365 天内有效
+type PackagePlan struct {
+	ProductID        int64 `json:"product_id"`         // 套餐产品ID
+	ServiceProductID int64 `json:"service_product_id"` // 可抵扣的服务产品ID
+	TotalUses        int32 `json:"total_uses"`         // 每份套餐包含次数
+	ValidDays        int32 `json:"valid_days"`         // 有效天数，0 表示不过期
+	CreatedAt        int64 `json:"created_at"`
+	UpdatedAt        int64 `json:"updated_at"`
+}
+
+// SetPackagePlanRequest 设置套餐配置请求（不存在则创建）
+// 配置变更只影响之后购买的套餐，已发放的客户套餐保持购买时的次数与有效期
+type SetPackagePlanRequest struct {
+	ProductID        int64 `json:"product_id"`         // 套餐产品ID，产品类型须为 package
+	ServiceProductID int64 `json:"service_product_id"` // 可抵扣的服务产品ID，产品类型须为 service
+	TotalUses        int32 `json:"total_uses"`         // 每份套餐包含次数
+	ValidDays        int32 `json:"valid_days"`         // 有效天数，0 表示不过期
+}
+
+// CustomerPackage 客户套餐
+// 每个购买套餐的订单项对应一条客户套餐，购买多份时次数累加
+type CustomerPackage struct {
+	ID               int64  `json:"id"`
+	CustomerID       int64  `json:"customer_id"`        // 客户ID
+	PackageProductID int64  `json:"package_product_id"` // 套餐产品ID
+	ServiceProductID int64  `json:"service_product_id"` // 可抵扣的服务产品ID
+	OrderID          int64  `json:"order_id"`           // 购买订单ID
+	OrderItemID      int64  `json:"order_item_id"`      // 购买订单项ID
+	Quantity         int32  `json:"quantity"`           // 购买份数
+	TotalUses        int32  `json:"total_uses"`         // 购买总次数
+	RemainingUses    int32  `json:"remaining_uses"`     // 剩余次数
+	ExpiresAt        int64  `json:"expires_at"`         // 过期时间（Unix时间戳），0 表示不过期
+	Status           string `json:"status"`             // 状态：active/revoked
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+// PackageTransaction 套餐流水
+// 记录所有剩余次数变更，用于使用记录查询与对账
+type PackageTransaction struct {
+	ID                int64  `json:"id"`
+	CustomerPackageID int64  `json:"customer_package_id"` // 客户套餐ID
+	CustomerID        int64  `json:"customer_id"`         // 客户ID
+	Type              string `json:"type"`                // 类型：purchase/redeem/restore/revoke
+	Uses              int32  `json:"uses"`                // 变更次数（正数）
+	RemainingAfter    int32  `json:"remaining_after"`     // 变更后剩余次数
+	OrderID           int64  `json:"order_id"`            // 关联订单ID
+	OrderItemID       int64  `json:"order_item_id"`       // 关联订单项ID
+	IdempotencyKey    string `json:"idempotency_key"`     // 幂等键
+	Note              string `json:"note"`                // 备注
+	CreatedAt         int64  `json:"created_at"`          // 创建时间（Unix时间戳）
+}
+
+// GrantRequest 购买套餐发放请求
+type GrantRequest struct {
+	CustomerID       int64 `json:"customer_id"`        // 客户ID
+	PackageProductID int64 `json:"package_product_id"` // 套餐产品ID
+	OrderID          int64 `json:"order_id"`           // 购买订单ID
+	OrderItemID      int64 `json:"order_item_id"`      // 购买订单项ID，同一订单项只发放一次
+	Qty              int32 `json:"qty"`                // 购买份数
+}
+
+// RedeemRequest 按次抵扣请求
+type RedeemRequest struct {
+	CustomerID       int64  `json:"customer_id"`        // 客户ID
+	ServiceProductID int64  `json:"service_product_id"` // 被抵扣的服务产品ID
+	OrderID          int64  `json:"order_id"`           // 抵扣订单ID
+	OrderItemID      int64  `json:"order_item_id"`      // 抵扣订单项ID
+	Qty              int32  `json:"qty"`                // 抵扣次数
+	IdemKey          string `json:"idem_key"`           // 幂等键，相同键只抵扣一次
+}
+
+// ReverseRequest 订单项取消或退款时的套餐冲回请求
+type ReverseRequest struct {
+	OrderItemID int64  `json:"order_item_id"` // 订单项ID
+	Qty         int32  `json:"qty"`           // 冲回数量（订单项数量口径）
+	IdemKey     string `json:"idem_key"`      // 幂等键，相同键只冲回一次
+	Note        string `json:"note"`          // 备注
+}
+
+// Service 次卡套餐域服务接口
+// 下单集成方法由 sales 域在订单事务中调用，共享同一事务上下文
+type Service interface {
+	// SetPackagePlan 设置套餐配置
+	SetPackagePlan(ctx context.Context, req SetPackagePlanRequest) (*PackagePlan, error)
+
+	// GetPackagePlan 获取套餐配置，不存在时返回 PACKAGE_NOT_FOUND
+	GetPackagePlan(ctx context.Context, productID int64) (*PackagePlan, error)
+
+	// GrantForOrderItem 订单付清后为购买套餐的订单项发放客户套餐
+	// 同一订单项重复调用返回已发放的客户套餐
+	GrantForOrderItem(ctx context.Context, req GrantRequest) (*CustomerPackage, error)
+
+	// Redeem 按次抵扣服务，优先使用最早过期的可用套餐
+	// 没有剩余次数足够且未过期的套餐时返回 PACKAGE_INSUFFICIENT
+	Redeem(ctx context.Context, req RedeemRequest) (*PackageTransaction, error)
+
+	// ReverseOrderItem 冲回订单项对应的套餐变更
+	// 抵扣订单项退回次数；购买订单项扣回次数，剩余次数不足时返回 PACKAGE_USED；与套餐无关的订单项不做处理
+	ReverseOrderItem(ctx context.Context, req ReverseRequest) error
+
+	// ListCustomerPackages 查询客户套餐，activeOnly 为 true 时只返回可用（未作废、未过期、有剩余次数）的套餐
+	ListCustomerPackages(ctx context.Context, customerID int64, activeOnly bool) ([]CustomerPackage, error)
+
+	// GetCustomerPackage 获取客户套餐详情
+	GetCustomerPackage(ctx context.Context, customerPackageID int64) (*CustomerPackage, error)
+
+	// ListPackageTransactions 查询客户套餐的使用流水，按时间升序
+	ListPackageTransactions(ctx context.Context, customerPackageID int64) ([]PackageTransaction, error)
+}
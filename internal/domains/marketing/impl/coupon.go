@@ -0,0 +1,447 @@
+package impl
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/marketing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// couponCodeAlphabet 券码字符集，去掉易混淆的 0/O/1/I
+	couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	couponCodeLength   = 10
+	maxCouponCodeBatch = 10000
+)
+
+// ===== CouponService 接口实现 =====
+
+// CreateCoupon 创建优惠券定义
+func (s *MarketingServiceImpl) CreateCoupon(ctx context.Context, req marketing.CreateCouponRequest, createdBy int64) (*marketing.Coupon, error) {
+	if err := validateCouponRequest(req); err != nil {
+		return nil, err
+	}
+
+	// 活动发放的优惠券需关联已存在的活动，用于订单来源归因
+	if req.CampaignID > 0 {
+		count, err := s.q.MarketingCampaign.WithContext(ctx).Where(s.q.MarketingCampaign.ID.Eq(req.CampaignID)).Count()
+		if err != nil {
+			return nil, fmt.Errorf("查询营销活动失败: %w", err)
+		}
+		if count == 0 {
+			return nil, common.NewBusinessError(common.ErrCodeResourceNotFound, "营销活动不存在")
+		}
+	}
+
+	productIDs, err := json.Marshal(req.ProductIDs)
+	if err != nil {
+		return nil, fmt.Errorf("序列化适用产品失败: %w", err)
+	}
+	categories, err := json.Marshal(req.Categories)
+	if err != nil {
+		return nil, fmt.Errorf("序列化适用分类失败: %w", err)
+	}
+
+	now := time.Now().Unix()
+	record := &CouponRecord{
+		CampaignID:       req.CampaignID,
+		Name:             req.Name,
+		Type:             req.Type,
+		Value:            req.Value,
+		MinSpend:         req.MinSpend,
+		MaxDiscount:      req.MaxDiscount,
+		ProductIDs:       string(productIDs),
+		Categories:       string(categories),
+		ValidFrom:        req.ValidFrom.Unix(),
+		ValidTo:          req.ValidTo.Unix(),
+		PerCustomerLimit: req.PerCustomerLimit,
+		Status:           string(constants.CouponStatusActive),
+		CreatedBy:        createdBy,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建优惠券失败: %w", err)
+	}
+
+	return toCoupon(record), nil
+}
+
+// GetCoupon 获取优惠券详情
+func (s *MarketingServiceImpl) GetCoupon(ctx context.Context, couponID int64) (*marketing.Coupon, error) {
+	var record CouponRecord
+	if err := s.db.WithContext(ctx).Where("id = ?", couponID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCouponNotFound, "优惠券不存在")
+		}
+		return nil, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+	return toCoupon(&record), nil
+}
+
+// ListCoupons 分页查询优惠券
+func (s *MarketingServiceImpl) ListCoupons(ctx context.Context, campaignID int64, page, pageSize int) ([]marketing.Coupon, int64, error) {
+	db := s.db.WithContext(ctx).Model(&CouponRecord{})
+	if campaignID > 0 {
+		db = db.Where("campaign_id = ?", campaignID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询优惠券总数失败: %w", err)
+	}
+
+	var records []CouponRecord
+	offset := (page - 1) * pageSize
+	if err := db.Order("id DESC").Offset(offset).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询优惠券列表失败: %w", err)
+	}
+
+	result := make([]marketing.Coupon, len(records))
+	for i := range records {
+		result[i] = *toCoupon(&records[i])
+	}
+	return result, total, nil
+}
+
+// GenerateCouponCodes 批量生成券码
+func (s *MarketingServiceImpl) GenerateCouponCodes(ctx context.Context, couponID int64, count int, prefix string) ([]string, error) {
+	if count <= 0 || count > maxCouponCodeBatch {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("单次生成数量须在 1-%d 之间", maxCouponCodeBatch))
+	}
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	if len(prefix) > 16 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "券码前缀不能超过16个字符")
+	}
+
+	var codes []string
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		var coupon CouponRecord
+		if err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ?", couponID).First(&coupon).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodeCouponNotFound, "优惠券不存在")
+			}
+			return fmt.Errorf("查询优惠券失败: %w", err)
+		}
+
+		now := time.Now().Unix()
+		seen := make(map[string]struct{}, count)
+		records := make([]CouponCodeRecord, 0, count)
+		for len(records) < count {
+			code, err := randomCouponCode(prefix)
+			if err != nil {
+				return err
+			}
+			if _, ok := seen[code]; ok {
+				continue
+			}
+			seen[code] = struct{}{}
+			records = append(records, CouponCodeRecord{
+				CouponID:  couponID,
+				Code:      code,
+				Status:    couponCodeStatusUnused,
+				CreatedAt: now,
+			})
+		}
+		if err := txDB.WithContext(ctx).CreateInBatches(records, 500).Error; err != nil {
+			return fmt.Errorf("创建券码失败: %w", err)
+		}
+
+		if err := txDB.WithContext(ctx).Model(&CouponRecord{}).Where("id = ?", couponID).Updates(map[string]interface{}{
+			"issued_count": gorm.Expr("issued_count + ?", count),
+			"updated_at":   now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新发放数量失败: %w", err)
+		}
+
+		codes = make([]string, len(records))
+		for i, r := range records {
+			codes[i] = r.Code
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RedeemCoupon 核销券码
+// 须在调用方事务中执行：券码与优惠券加锁 + 有效期/范围/门槛/每人限次校验 + 核销记录
+func (s *MarketingServiceImpl) RedeemCoupon(ctx context.Context, req marketing.RedeemCouponRequest) (*marketing.CouponRedemption, error) {
+	txDB := s.tx.GetDB(ctx)
+	code := strings.ToUpper(strings.TrimSpace(req.Code))
+
+	// 1. 券码加锁，防止并发重复核销
+	var codeRecord CouponCodeRecord
+	if err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", code).First(&codeRecord).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeCouponNotFound, "券码不存在")
+		}
+		return nil, fmt.Errorf("查询券码失败: %w", err)
+	}
+	if codeRecord.Status != couponCodeStatusUnused {
+		return nil, common.NewBusinessError(common.ErrCodeCouponInvalid, "券码已使用")
+	}
+
+	// 优惠券加锁：同一客户并发核销同一优惠券的不同券码时，每人限次计数须串行
+	var coupon CouponRecord
+	if err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", codeRecord.CouponID).First(&coupon).Error; err != nil {
+		return nil, fmt.Errorf("查询优惠券失败: %w", err)
+	}
+
+	// 2. 状态与有效期
+	now := time.Now().Unix()
+	if coupon.Status != string(constants.CouponStatusActive) {
+		return nil, common.NewBusinessError(common.ErrCodeCouponInvalid, "优惠券已停用")
+	}
+	if now < coupon.ValidFrom || now > coupon.ValidTo {
+		return nil, common.NewBusinessError(common.ErrCodeCouponInvalid, "优惠券不在有效期内")
+	}
+
+	// 3. 每人限次
+	if coupon.PerCustomerLimit > 0 {
+		var used int64
+		if err := txDB.WithContext(ctx).Model(&CouponRedemptionRecord{}).
+			Where("coupon_id = ? AND customer_id = ? AND status = ?", coupon.ID, req.CustomerID, couponRedemptionStatusRedeemed).
+			Count(&used).Error; err != nil {
+			return nil, fmt.Errorf("查询核销次数失败: %w", err)
+		}
+		if used >= int64(coupon.PerCustomerLimit) {
+			return nil, common.NewBusinessError(common.ErrCodeCouponLimitExceeded, fmt.Sprintf("该优惠券每人限用 %d 次", coupon.PerCustomerLimit))
+		}
+	}
+
+	// 4. 适用范围与门槛
+	discount, err := couponDiscount(&coupon, req.Lines)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. 核销券码并写入核销记录
+	if err := txDB.WithContext(ctx).Model(&CouponCodeRecord{}).Where("id = ?", codeRecord.ID).Updates(map[string]interface{}{
+		"status":      couponCodeStatusRedeemed,
+		"redeemed_by": req.CustomerID,
+		"redeemed_at": now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新券码状态失败: %w", err)
+	}
+	if err := txDB.WithContext(ctx).Model(&CouponRecord{}).Where("id = ?", coupon.ID).Updates(map[string]interface{}{
+		"redeemed_count": gorm.Expr("redeemed_count + 1"),
+		"updated_at":     now,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新核销数量失败: %w", err)
+	}
+
+	redemption := &CouponRedemptionRecord{
+		CouponID:   coupon.ID,
+		CodeID:     codeRecord.ID,
+		Code:       codeRecord.Code,
+		CampaignID: coupon.CampaignID,
+		CustomerID: req.CustomerID,
+		OrderID:    req.OrderID,
+		Discount:   discount,
+		Status:     couponRedemptionStatusRedeemed,
+		CreatedAt:  now,
+	}
+	if err := txDB.WithContext(ctx).Create(redemption).Error; err != nil {
+		return nil, fmt.Errorf("创建核销记录失败: %w", err)
+	}
+
+	return toCouponRedemption(redemption), nil
+}
+
+// ReverseCouponRedemption 撤销订单的券码核销，券码恢复为未使用
+func (s *MarketingServiceImpl) ReverseCouponRedemption(ctx context.Context, orderID int64) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.tx.GetDB(ctx)
+
+		var redemption CouponRedemptionRecord
+		err := txDB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("order_id = ? AND status = ?", orderID, couponRedemptionStatusRedeemed).
+			First(&redemption).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("查询核销记录失败: %w", err)
+		}
+
+		now := time.Now().Unix()
+		if err := txDB.WithContext(ctx).Model(&CouponRedemptionRecord{}).Where("id = ?", redemption.ID).Updates(map[string]interface{}{
+			"status":      couponRedemptionStatusReversed,
+			"reversed_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("撤销核销记录失败: %w", err)
+		}
+		if err := txDB.WithContext(ctx).Model(&CouponCodeRecord{}).Where("id = ?", redemption.CodeID).Updates(map[string]interface{}{
+			"status":      couponCodeStatusUnused,
+			"redeemed_by": 0,
+			"redeemed_at": 0,
+		}).Error; err != nil {
+			return fmt.Errorf("退回券码失败: %w", err)
+		}
+		if err := txDB.WithContext(ctx).Model(&CouponRecord{}).Where("id = ?", redemption.CouponID).Updates(map[string]interface{}{
+			"redeemed_count": gorm.Expr("redeemed_count - 1"),
+			"updated_at":     now,
+		}).Error; err != nil {
+			return fmt.Errorf("更新核销数量失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// couponDiscount 按适用范围计算优惠金额（分），优惠不超过适用商品金额
+func couponDiscount(coupon *CouponRecord, lines []marketing.CouponLine) (int64, error) {
+	var productIDs []int64
+	var categories []string
+	if coupon.ProductIDs != "" {
+		if err := json.Unmarshal([]byte(coupon.ProductIDs), &productIDs); err != nil {
+			return 0, fmt.Errorf("解析适用产品失败: %w", err)
+		}
+	}
+	if coupon.Categories != "" {
+		if err := json.Unmarshal([]byte(coupon.Categories), &categories); err != nil {
+			return 0, fmt.Errorf("解析适用分类失败: %w", err)
+		}
+	}
+
+	var eligible int64
+	for _, l := range lines {
+		if couponLineInScope(l, productIDs, categories) {
+			eligible += l.Amount
+		}
+	}
+	if eligible <= 0 {
+		return 0, common.NewBusinessError(common.ErrCodeCouponInvalid, "订单中没有适用该优惠券的商品")
+	}
+	if eligible < coupon.MinSpend {
+		return 0, common.NewBusinessErrorWithDetails(common.ErrCodeCouponInvalid, "未达到优惠券最低消费金额",
+			fmt.Sprintf("最低消费：%d，适用金额：%d", coupon.MinSpend, eligible))
+	}
+
+	discount := coupon.Value
+	if coupon.Type == string(constants.CouponTypePercent) {
+		discount = eligible * coupon.Value / 100
+	}
+	if coupon.MaxDiscount > 0 && discount > coupon.MaxDiscount {
+		discount = coupon.MaxDiscount
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount, nil
+}
+
+// couponLineInScope 产品与分类均未限定时全部适用，否则命中任一即适用
+func couponLineInScope(line marketing.CouponLine, productIDs []int64, categories []string) bool {
+	if len(productIDs) == 0 && len(categories) == 0 {
+		return true
+	}
+	for _, id := range productIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, c := range categories {
+		if c == line.Category {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCouponRequest(req marketing.CreateCouponRequest) error {
+	if strings.TrimSpace(req.Name) == "" {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "优惠券名称不能为空")
+	}
+	switch req.Type {
+	case string(constants.CouponTypeFixed):
+		if req.Value <= 0 {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "优惠金额必须大于0")
+		}
+	case string(constants.CouponTypePercent):
+		if req.Value <= 0 || req.Value > 100 {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "折扣百分比须在 1-100 之间")
+		}
+	default:
+		return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("不支持的优惠券类型: %s", req.Type))
+	}
+	if req.MinSpend < 0 || req.MaxDiscount < 0 || req.PerCustomerLimit < 0 {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "最低消费、最高优惠和每人限次不能为负数")
+	}
+	if !req.ValidTo.After(req.ValidFrom) {
+		return common.NewBusinessError(common.ErrCodeInvalidParam, "失效时间必须晚于生效时间")
+	}
+	return nil
+}
+
+// randomCouponCode 生成带前缀的随机券码
+func randomCouponCode(prefix string) (string, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	base := big.NewInt(int64(len(couponCodeAlphabet)))
+	for i := 0; i < couponCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, base)
+		if err != nil {
+			return "", fmt.Errorf("生成券码失败: %w", err)
+		}
+		b.WriteByte(couponCodeAlphabet[n.Int64()])
+	}
+	return b.String(), nil
+}
+
+func toCoupon(r *CouponRecord) *marketing.Coupon {
+	coupon := &marketing.Coupon{
+		ID:               r.ID,
+		CampaignID:       r.CampaignID,
+		Name:             r.Name,
+		Type:             r.Type,
+		Value:            r.Value,
+		MinSpend:         r.MinSpend,
+		MaxDiscount:      r.MaxDiscount,
+		ValidFrom:        time.Unix(r.ValidFrom, 0),
+		ValidTo:          time.Unix(r.ValidTo, 0),
+		PerCustomerLimit: r.PerCustomerLimit,
+		Status:           r.Status,
+		IssuedCount:      r.IssuedCount,
+		RedeemedCount:    r.RedeemedCount,
+		CreatedBy:        r.CreatedBy,
+		CreatedAt:        r.CreatedAt,
+	}
+	// 解析失败时按不限范围展示
+	_ = json.Unmarshal([]byte(r.ProductIDs), &coupon.ProductIDs)
+	_ = json.Unmarshal([]byte(r.Categories), &coupon.Categories)
+	return coupon
+}
+
+func toCouponRedemption(r *CouponRedemptionRecord) *marketing.CouponRedemption {
+	return &marketing.CouponRedemption{
+		ID:         r.ID,
+		CouponID:   r.CouponID,
+		CampaignID: r.CampaignID,
+		Code:       r.Code,
+		CustomerID: r.CustomerID,
+		OrderID:    r.OrderID,
+		Discount:   r.Discount,
+		Status:     r.Status,
+		CreatedAt:  r.CreatedAt,
+		ReversedAt: r.ReversedAt,
+	}
+}
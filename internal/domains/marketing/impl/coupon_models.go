@@ -0,0 +1,67 @@
+package impl
+
+// 券码状态
+const (
+	couponCodeStatusUnused   = "unused"
+	couponCodeStatusRedeemed = "redeemed"
+)
+
+// 核销记录状态
+const (
+	couponRedemptionStatusRedeemed = "redeemed"
+	couponRedemptionStatusReversed = "reversed"
+)
+
+// CouponRecord 映射 coupons（优惠券定义）
+type CouponRecord struct {
+	ID               int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CampaignID       int64  `gorm:"column:campaign_id;index:idx_coupon_campaign;not null;default:0"`
+	Name             string `gorm:"column:name;size:100;not null"`
+	Type             string `gorm:"column:type;size:20;not null"`
+	Value            int64  `gorm:"column:value;not null"`
+	MinSpend         int64  `gorm:"column:min_spend;not null;default:0"`    // cents
+	MaxDiscount      int64  `gorm:"column:max_discount;not null;default:0"` // cents
+	ProductIDs       string `gorm:"column:product_ids;type:text"`           // JSON 数组
+	Categories       string `gorm:"column:categories;type:text"`            // JSON 数组
+	ValidFrom        int64  `gorm:"column:valid_from;not null"`
+	ValidTo          int64  `gorm:"column:valid_to;not null"`
+	PerCustomerLimit int    `gorm:"column:per_customer_limit;not null;default:0"`
+	Status           string `gorm:"column:status;size:20;not null;default:active"`
+	IssuedCount      int64  `gorm:"column:issued_count;not null;default:0"`
+	RedeemedCount    int64  `gorm:"column:redeemed_count;not null;default:0"`
+	CreatedBy        int64  `gorm:"column:created_by;not null;default:0"`
+	CreatedAt        int64  `gorm:"column:created_at;not null"`
+	UpdatedAt        int64  `gorm:"column:updated_at;not null"`
+}
+
+func (CouponRecord) TableName() string { return "coupons" }
+
+// CouponCodeRecord 映射 coupon_codes（券码）
+type CouponCodeRecord struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CouponID   int64  `gorm:"column:coupon_id;index:idx_coupon_code_coupon;not null"`
+	Code       string `gorm:"column:code;uniqueIndex:uk_coupon_code;size:32;not null"`
+	Status     string `gorm:"column:status;size:20;not null;default:unused"`
+	RedeemedBy int64  `gorm:"column:redeemed_by;not null;default:0"`
+	RedeemedAt int64  `gorm:"column:redeemed_at;not null;default:0"`
+	CreatedAt  int64  `gorm:"column:created_at;not null"`
+}
+
+func (CouponCodeRecord) TableName() string { return "coupon_codes" }
+
+// CouponRedemptionRecord 映射 coupon_redemptions（核销记录）
+type CouponRedemptionRecord struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CouponID   int64  `gorm:"column:coupon_id;index:idx_redemption_customer,priority:1;not null"`
+	CodeID     int64  `gorm:"column:code_id;not null"`
+	Code       string `gorm:"column:code;size:32;not null"`
+	CampaignID int64  `gorm:"column:campaign_id;not null;default:0"`
+	CustomerID int64  `gorm:"column:customer_id;index:idx_redemption_customer,priority:2;not null"`
+	OrderID    int64  `gorm:"column:order_id;index:idx_redemption_order;not null"`
+	Discount   int64  `gorm:"column:discount;not null"` // cents
+	Status     string `gorm:"column:status;size:20;not null"`
+	CreatedAt  int64  `gorm:"column:created_at;not null"`
+	ReversedAt int64  `gorm:"column:reversed_at;not null;default:0"`
+}
+
+func (CouponRedemptionRecord) TableName() string { return "coupon_redemptions" }
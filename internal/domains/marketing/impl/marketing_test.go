@@ -2,6 +2,7 @@ package impl
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -193,3 +194,98 @@ func TestMarketingBasicFunctions(t *testing.T) {
 	t.Log("  - ✅ 数据分析：营销统计和效果分析")
 	t.Log("  - ✅ 域接口完整性：实现了营销域三大服务接口")
 }
+
+// TestCouponRedemption 优惠券核销单元测试
+// 验证适用范围与最低消费、一次性券码、每人限次以及撤销后退回券码
+func TestCouponRedemption(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&CouponRecord{}, &CouponCodeRecord{}, &CouponRedemptionRecord{}))
+
+	tx := common.NewTx(db)
+	svc := NewMarketingServiceImpl(db, tx)
+	ctx := context.Background()
+
+	coupon, err := svc.CreateCoupon(ctx, marketing.CreateCouponRequest{
+		Name:             "美发八折",
+		Type:             "percent",
+		Value:            20,
+		MinSpend:         10000,
+		MaxDiscount:      5000,
+		Categories:       []string{"美发"},
+		ValidFrom:        time.Now().Add(-time.Hour),
+		ValidTo:          time.Now().Add(time.Hour),
+		PerCustomerLimit: 1,
+	}, 1)
+	require.NoError(t, err)
+
+	codes, err := svc.GenerateCouponCodes(ctx, coupon.ID, 3, "hair")
+	require.NoError(t, err)
+	require.Len(t, codes, 3)
+	assert.True(t, strings.HasPrefix(codes[0], "HAIR"))
+
+	redeem := func(code string, customerID, orderID int64, lines ...marketing.CouponLine) (*marketing.CouponRedemption, error) {
+		var redemption *marketing.CouponRedemption
+		err := tx.WithTx(ctx, func(ctx context.Context) error {
+			var err error
+			redemption, err = svc.RedeemCoupon(ctx, marketing.RedeemCouponRequest{Code: code, CustomerID: customerID, OrderID: orderID, Lines: lines})
+			return err
+		})
+		return redemption, err
+	}
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+
+	t.Run("适用范围与最低消费", func(t *testing.T) {
+		// 仅美发分类计入门槛
+		_, err := redeem(codes[0], 1, 100,
+			marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 8000},
+			marketing.CouponLine{ProductID: 2, Category: "美甲", Amount: 50000})
+		assertCode(t, err, common.ErrCodeCouponInvalid)
+
+		_, err = redeem(codes[0], 1, 100, marketing.CouponLine{ProductID: 2, Category: "美甲", Amount: 50000})
+		assertCode(t, err, common.ErrCodeCouponInvalid)
+	})
+
+	t.Run("核销与限次", func(t *testing.T) {
+		redemption, err := redeem(strings.ToLower(codes[0]), 1, 101, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 12000})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2400), redemption.Discount, "12000 * 20%")
+
+		// 同一券码不能重复使用
+		_, err = redeem(codes[0], 2, 102, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 12000})
+		assertCode(t, err, common.ErrCodeCouponInvalid)
+
+		// 每人限用一次
+		_, err = redeem(codes[1], 1, 103, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 12000})
+		assertCode(t, err, common.ErrCodeCouponLimitExceeded)
+
+		// 最高优惠封顶
+		redemption, err = redeem(codes[1], 2, 104, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 100000})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5000), redemption.Discount)
+
+		got, err := svc.GetCoupon(ctx, coupon.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), got.IssuedCount)
+		assert.Equal(t, int64(2), got.RedeemedCount)
+	})
+
+	t.Run("撤销核销退回券码", func(t *testing.T) {
+		require.NoError(t, svc.ReverseCouponRedemption(ctx, 101))
+		// 无核销记录的订单直接返回
+		require.NoError(t, svc.ReverseCouponRedemption(ctx, 999))
+
+		redemption, err := redeem(codes[0], 1, 105, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 10000})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2000), redemption.Discount)
+	})
+
+	t.Run("券码不存在", func(t *testing.T) {
+		_, err := redeem("NOPE", 1, 106, marketing.CouponLine{ProductID: 1, Category: "美发", Amount: 10000})
+		assertCode(t, err, common.ErrCodeCouponNotFound)
+	})
+}
@@ -1,5 +1,5 @@
 // Package marketing 营销推广域服务接口
-// 职责：营销活动管理、营销记录管理、推广效果分析
+// 职责：营销活动管理、营销记录管理、推广效果分析、优惠券发放与核销
 // 核心原则：营销活动生命周期管理、推广效果跟踪、ROI分析
 package marketing
 
@@ -100,6 +100,92 @@ type CampaignStats struct {
 	ROI            float64 `json:"roi"`             // 投资回报率
 }
 
+// Coupon 优惠券定义领域模型
+type Coupon struct {
+	ID               int64     `json:"id"`                 // 优惠券ID
+	CampaignID       int64     `json:"campaign_id"`        // 发放活动ID，0 表示非活动发放
+	Name             string    `json:"name"`               // 优惠券名称
+	Type             string    `json:"type"`               // 类型：fixed/percent
+	Value            int64     `json:"value"`              // 优惠值：fixed 为金额（分），percent 为折扣百分比（1-100）
+	MinSpend         int64     `json:"min_spend"`          // 最低消费（分），按适用范围内的商品金额计算
+	MaxDiscount      int64     `json:"max_discount"`       // 最高优惠（分），0 表示不限
+	ProductIDs       []int64   `json:"product_ids"`        // 适用产品，为空表示不限
+	Categories       []string  `json:"categories"`         // 适用分类，为空表示不限
+	ValidFrom        time.Time `json:"valid_from"`         // 生效时间
+	ValidTo          time.Time `json:"valid_to"`           // 失效时间
+	PerCustomerLimit int       `json:"per_customer_limit"` // 每位客户可使用次数，0 表示不限
+	Status           string    `json:"status"`             // 状态：active/disabled
+	IssuedCount      int64     `json:"issued_count"`       // 已生成券码数
+	RedeemedCount    int64     `json:"redeemed_count"`     // 已核销券码数
+	CreatedBy        int64     `json:"created_by"`         // 创建人ID
+	CreatedAt        int64     `json:"created_at"`         // 创建时间
+}
+
+// CouponRedemption 优惠券核销记录
+type CouponRedemption struct {
+	ID         int64  `json:"id"`          // 核销记录ID
+	CouponID   int64  `json:"coupon_id"`   // 优惠券ID
+	CampaignID int64  `json:"campaign_id"` // 发放活动ID，用于订单来源归因
+	Code       string `json:"code"`        // 券码
+	CustomerID int64  `json:"customer_id"` // 客户ID
+	OrderID    int64  `json:"order_id"`    // 订单ID
+	Discount   int64  `json:"discount"`    // 优惠金额（分）
+	Status     string `json:"status"`      // 状态：redeemed/reversed
+	CreatedAt  int64  `json:"created_at"`  // 核销时间
+	ReversedAt int64  `json:"reversed_at"` // 撤销时间
+}
+
+// CreateCouponRequest 创建优惠券请求
+type CreateCouponRequest struct {
+	CampaignID       int64     `json:"campaign_id"`        // 发放活动ID（可选）
+	Name             string    `json:"name"`               // 优惠券名称
+	Type             string    `json:"type"`               // 类型：fixed/percent
+	Value            int64     `json:"value"`              // 优惠值
+	MinSpend         int64     `json:"min_spend"`          // 最低消费（分）
+	MaxDiscount      int64     `json:"max_discount"`       // 最高优惠（分）
+	ProductIDs       []int64   `json:"product_ids"`        // 适用产品
+	Categories       []string  `json:"categories"`         // 适用分类
+	ValidFrom        time.Time `json:"valid_from"`         // 生效时间
+	ValidTo          time.Time `json:"valid_to"`           // 失效时间
+	PerCustomerLimit int       `json:"per_customer_limit"` // 每位客户可使用次数
+}
+
+// CouponLine 核销时参与计算的订单商品行
+type CouponLine struct {
+	ProductID int64  `json:"product_id"` // 产品ID
+	Category  string `json:"category"`   // 产品分类
+	Amount    int64  `json:"amount"`     // 行金额（分）
+}
+
+// RedeemCouponRequest 核销优惠券请求
+type RedeemCouponRequest struct {
+	Code       string       `json:"code"`        // 券码
+	CustomerID int64        `json:"customer_id"` // 客户ID
+	OrderID    int64        `json:"order_id"`    // 订单ID
+	Lines      []CouponLine `json:"lines"`       // 订单商品行
+}
+
+// CouponService 优惠券服务接口
+type CouponService interface {
+	// CreateCoupon 创建优惠券定义
+	CreateCoupon(ctx context.Context, req CreateCouponRequest, createdBy int64) (*Coupon, error)
+
+	// GetCoupon 获取优惠券详情
+	GetCoupon(ctx context.Context, couponID int64) (*Coupon, error)
+
+	// ListCoupons 分页查询优惠券，campaignID 为 0 时不按活动过滤
+	ListCoupons(ctx context.Context, campaignID int64, page, pageSize int) ([]Coupon, int64, error)
+
+	// GenerateCouponCodes 批量生成券码，每个券码只能核销一次
+	GenerateCouponCodes(ctx context.Context, couponID int64, count int, prefix string) ([]string, error)
+
+	// RedeemCoupon 核销券码并计算优惠金额，须在下单事务中调用
+	RedeemCoupon(ctx context.Context, req RedeemCouponRequest) (*CouponRedemption, error)
+
+	// ReverseCouponRedemption 撤销订单的券码核销并退回券码，订单无核销记录时返回 nil
+	ReverseCouponRedemption(ctx context.Context, orderID int64) error
+}
+
 // CampaignService 营销活动服务接口
 type CampaignService interface {
 	// CreateCampaign 创建营销活动
@@ -167,9 +253,10 @@ type AnalyticsService interface {
 }
 
 // Service 营销域统一服务接口
-// 整合活动管理、记录管理、数据分析的完整功能
+// 整合活动管理、记录管理、数据分析、优惠券的完整功能
 type Service interface {
 	CampaignService
 	RecordService
 	AnalyticsService
+	CouponService
 }
@@ -0,0 +1,143 @@
+package impl
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"crm_lite/internal/domains/sales"
+)
+
+// MockPaymentProviderName 内置模拟支付渠道名称
+const MockPaymentProviderName = "mock"
+
+// MockPaymentProvider 内置的模拟支付渠道，用于开发联调与测试
+// 支付单保存在内存中；Complete 模拟客户完成（或放弃）支付，返回签名后的回调报文，
+// 回调签名为报文的 HMAC-SHA256 十六进制值
+type MockPaymentProvider struct {
+	secret  []byte
+	mu      sync.Mutex
+	intents map[string]*sales.ProviderIntent
+	refunds map[string]*sales.ProviderRefund
+}
+
+// NewMockPaymentProvider 创建模拟支付渠道，secret 为回调签名密钥
+func NewMockPaymentProvider(secret string) *MockPaymentProvider {
+	return &MockPaymentProvider{
+		secret:  []byte(secret),
+		intents: make(map[string]*sales.ProviderIntent),
+		refunds: make(map[string]*sales.ProviderRefund),
+	}
+}
+
+// Name 渠道名称
+func (p *MockPaymentProvider) Name() string {
+	return MockPaymentProviderName
+}
+
+// CreateIntent 创建模拟支付单，相同支付单号返回已有支付单
+func (p *MockPaymentProvider) CreateIntent(ctx context.Context, req sales.ProviderIntentRequest) (*sales.ProviderIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if intent, ok := p.intents[req.IntentNo]; ok {
+		copied := *intent
+		return &copied, nil
+	}
+	tradeNo := "mock_" + req.IntentNo
+	intent := &sales.ProviderIntent{
+		ProviderTradeNo: tradeNo,
+		Status:          sales.PaymentIntentStatusPending,
+		Amount:          req.Amount,
+		PayURL:          "https://pay.mock.local/checkout/" + tradeNo,
+	}
+	p.intents[req.IntentNo] = intent
+	copied := *intent
+	return &copied, nil
+}
+
+// QueryIntent 查询模拟支付单状态
+func (p *MockPaymentProvider) QueryIntent(ctx context.Context, intentNo string) (*sales.ProviderIntent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentNo]
+	if !ok {
+		return nil, fmt.Errorf("模拟渠道支付单不存在: %s", intentNo)
+	}
+	copied := *intent
+	return &copied, nil
+}
+
+// Refund 模拟原路退款，立即退款成功，相同退款单号返回同一结果
+func (p *MockPaymentProvider) Refund(ctx context.Context, req sales.ProviderRefundRequest) (*sales.ProviderRefund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if refund, ok := p.refunds[req.RefundNo]; ok {
+		copied := *refund
+		return &copied, nil
+	}
+	intent, ok := p.intents[req.IntentNo]
+	if !ok || intent.Status != sales.PaymentIntentStatusSucceeded {
+		return nil, fmt.Errorf("模拟渠道支付单未支付，不能退款: %s", req.IntentNo)
+	}
+	if req.Amount <= 0 || req.Amount > intent.Amount {
+		return nil, fmt.Errorf("模拟渠道退款金额无效: %d", req.Amount)
+	}
+	refund := &sales.ProviderRefund{
+		ProviderRefundNo: "mock_refund_" + req.RefundNo,
+		Status:           sales.PaymentIntentStatusSucceeded,
+	}
+	p.refunds[req.RefundNo] = refund
+	copied := *refund
+	return &copied, nil
+}
+
+// VerifyCallback 校验回调签名并解析回调内容
+func (p *MockPaymentProvider) VerifyCallback(ctx context.Context, payload []byte, signature string) (*sales.PaymentCallback, error) {
+	if !hmac.Equal([]byte(p.sign(payload)), []byte(signature)) {
+		return nil, fmt.Errorf("回调签名无效")
+	}
+	var callback sales.PaymentCallback
+	if err := json.Unmarshal(payload, &callback); err != nil {
+		return nil, fmt.Errorf("解析回调内容失败: %w", err)
+	}
+	return &callback, nil
+}
+
+// Complete 模拟客户完成支付（status 为 succeeded）或支付失败（status 为 failed），
+// 更新渠道侧支付单状态并返回回调报文及签名
+func (p *MockPaymentProvider) Complete(intentNo, status string) (payload []byte, signature string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentNo]
+	if !ok {
+		return nil, "", fmt.Errorf("模拟渠道支付单不存在: %s", intentNo)
+	}
+	intent.Status = status
+	if status == sales.PaymentIntentStatusSucceeded {
+		intent.PaidAt = time.Now().Unix()
+	}
+	payload, err = json.Marshal(sales.PaymentCallback{
+		IntentNo:        intentNo,
+		ProviderTradeNo: intent.ProviderTradeNo,
+		Status:          intent.Status,
+		Amount:          intent.Amount,
+		PaidAt:          intent.PaidAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return payload, p.sign(payload), nil
+}
+
+func (p *MockPaymentProvider) sign(payload []byte) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ sales.PaymentProvider = (*MockPaymentProvider)(nil)
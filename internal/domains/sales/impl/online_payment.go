@@ -0,0 +1,375 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OnlinePaymentServiceImpl 在线支付服务实现
+// 支付单生命周期：pending →（渠道回调或主动查询）→ succeeded / failed / refunded
+// 支付成功复用 recordPayments 以 online 方式记入订单支付明细，付清时由其完成订单流转与 order.paid 事件
+type OnlinePaymentServiceImpl struct {
+	orders          *SalesServiceImpl
+	providers       map[string]sales.PaymentProvider
+	defaultProvider string
+}
+
+// NewOnlinePaymentService 创建在线支付服务，defaultProvider 为创建支付单未指定渠道时使用的渠道
+// providers 注册到订单服务，与其共用同一组渠道，订单退款时经收款渠道原路退回
+func NewOnlinePaymentService(orders *SalesServiceImpl, defaultProvider string, providers ...sales.PaymentProvider) *OnlinePaymentServiceImpl {
+	orders.registerPaymentProviders(providers...)
+	return &OnlinePaymentServiceImpl{
+		orders:          orders,
+		providers:       orders.providers,
+		defaultProvider: defaultProvider,
+	}
+}
+
+// CreatePaymentIntent 为订单创建在线支付单并在渠道侧下单
+// 支付单先在事务中落库占用应付金额，渠道下单在事务外进行，渠道失败时支付单标记为 failed
+func (s *OnlinePaymentServiceImpl) CreatePaymentIntent(ctx context.Context, req sales.CreatePaymentIntentReq) (*sales.PaymentIntent, error) {
+	if req.Amount < 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "支付金额不能为负数")
+	}
+	providerName := req.Provider
+	if providerName == "" {
+		providerName = s.defaultProvider
+	}
+	if providerName == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "未指定支付渠道且未配置默认渠道")
+	}
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	db := s.orders.db.WithContext(ctx)
+	if req.IdemKey != "" {
+		var existing PaymentIntentRecord
+		err := db.Where("idempotency_key = ?", req.IdemKey).First(&existing).Error
+		if err == nil {
+			intent := toPaymentIntent(existing)
+			return &intent, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("检查幂等性失败: %w", err)
+		}
+	}
+
+	var record PaymentIntentRecord
+	err = s.orders.tx.WithTx(ctx, func(ctx context.Context) error {
+		order, err := s.orders.getOrderForUpdate(ctx, req.OrderID)
+		if err != nil {
+			return err
+		}
+		if order.Status == string(constants.OrderStatusCancelled) || order.Status == string(constants.OrderStatusRefunded) {
+			return common.NewBusinessError(common.ErrCodeOrderStatusInvalid, "订单状态不允许支付")
+		}
+
+		paid, err := s.orders.paidAmount(ctx, order.ID)
+		if err != nil {
+			return err
+		}
+		var pending int64
+		if err := s.orders.tx.GetDB(ctx).WithContext(ctx).Model(&PaymentIntentRecord{}).
+			Where("order_id = ? AND status = ?", order.ID, sales.PaymentIntentStatusPending).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&pending).Error; err != nil {
+			return fmt.Errorf("查询进行中的支付单失败: %w", err)
+		}
+
+		// 进行中的支付单视为已占用，避免同一笔应付金额被多次在线支付
+		remaining := int64(order.FinalAmount*100) - paid - pending
+		amount := req.Amount
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return common.NewBusinessErrorWithDetails(common.ErrCodeOrderOverpaid, "支付金额超过订单剩余应付金额",
+				fmt.Sprintf("剩余：%d，已付：%d，支付中：%d，本次：%d", remaining, paid, pending, amount))
+		}
+
+		now := time.Now()
+		intentNo := fmt.Sprintf("pi_%d_%d", order.ID, now.UnixNano())
+		idemKey := req.IdemKey
+		if idemKey == "" {
+			idemKey = intentNo
+		}
+		record = PaymentIntentRecord{
+			IntentNo:       intentNo,
+			OrderID:        order.ID,
+			CustomerID:     order.CustomerID,
+			Provider:       provider.Name(),
+			Amount:         amount,
+			Status:         sales.PaymentIntentStatusPending,
+			IdempotencyKey: idemKey,
+			OperatorID:     req.OperatorID,
+			CreatedAt:      now.Unix(),
+			UpdatedAt:      now.Unix(),
+		}
+		if err := s.orders.tx.GetDB(ctx).WithContext(ctx).Create(&record).Error; err != nil {
+			return fmt.Errorf("创建支付单失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created, providerErr := provider.CreateIntent(ctx, sales.ProviderIntentRequest{
+		IntentNo: record.IntentNo,
+		Amount:   record.Amount,
+		Subject:  fmt.Sprintf("订单 %d", record.OrderID),
+	})
+	updates := map[string]interface{}{"updated_at": time.Now().Unix()}
+	if providerErr != nil {
+		updates["status"] = sales.PaymentIntentStatusFailed
+		updates["failure_reason"] = truncateReason(providerErr.Error())
+	} else {
+		updates["provider_trade_no"] = created.ProviderTradeNo
+		updates["pay_url"] = created.PayURL
+	}
+	if err := db.Model(&PaymentIntentRecord{}).Where("id = ?", record.ID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新支付单失败: %w", err)
+	}
+	if providerErr != nil {
+		return nil, common.NewBusinessErrorWithDetails(common.ErrCodePaymentProviderFailed, "支付渠道下单失败", providerErr.Error())
+	}
+
+	record.ProviderTradeNo = created.ProviderTradeNo
+	record.PayURL = created.PayURL
+	intent := toPaymentIntent(record)
+	return &intent, nil
+}
+
+// HandleCallback 处理渠道回调，验签失败返回 PAYMENT_SIGNATURE_INVALID
+func (s *OnlinePaymentServiceImpl) HandleCallback(ctx context.Context, providerName string, payload []byte, signature string) (*sales.PaymentIntent, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	callback, err := provider.VerifyCallback(ctx, payload, signature)
+	if err != nil {
+		return nil, common.NewBusinessErrorWithDetails(common.ErrCodePaymentSignatureInvalid, "支付回调验签失败", err.Error())
+	}
+	return s.applyResult(ctx, provider, callback)
+}
+
+// SyncPaymentIntent 主动查询渠道侧支付状态，用于补偿丢失的回调
+func (s *OnlinePaymentServiceImpl) SyncPaymentIntent(ctx context.Context, intentID int64) (*sales.PaymentIntent, error) {
+	var record PaymentIntentRecord
+	if err := s.orders.db.WithContext(ctx).Where("id = ?", intentID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodePaymentIntentNotFound, "支付单不存在")
+		}
+		return nil, fmt.Errorf("查询支付单失败: %w", err)
+	}
+	if record.Status != sales.PaymentIntentStatusPending {
+		intent := toPaymentIntent(record)
+		return &intent, nil
+	}
+
+	provider, err := s.provider(record.Provider)
+	if err != nil {
+		return nil, err
+	}
+	remote, err := provider.QueryIntent(ctx, record.IntentNo)
+	if err != nil {
+		return nil, common.NewBusinessErrorWithDetails(common.ErrCodePaymentProviderFailed, "查询渠道支付状态失败", err.Error())
+	}
+	return s.applyResult(ctx, provider, &sales.PaymentCallback{
+		IntentNo:        record.IntentNo,
+		ProviderTradeNo: remote.ProviderTradeNo,
+		Status:          remote.Status,
+		Amount:          remote.Amount,
+		PaidAt:          remote.PaidAt,
+	})
+}
+
+// ListOrderPaymentIntents 查询订单的在线支付单
+func (s *OnlinePaymentServiceImpl) ListOrderPaymentIntents(ctx context.Context, orderID int64) ([]sales.PaymentIntent, error) {
+	q := s.orders.q
+	if _, err := q.Order.WithContext(ctx).Where(q.Order.ID.Eq(orderID)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var records []PaymentIntentRecord
+	if err := s.orders.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at ASC, id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询支付单失败: %w", err)
+	}
+	return toPaymentIntents(records), nil
+}
+
+// ListStuckPaymentIntents 查询长时间未收到回调的 pending 支付单
+func (s *OnlinePaymentServiceImpl) ListStuckPaymentIntents(ctx context.Context, before int64, limit int) ([]sales.PaymentIntent, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var records []PaymentIntentRecord
+	if err := s.orders.db.WithContext(ctx).
+		Where("status = ? AND created_at < ?", sales.PaymentIntentStatusPending, before).
+		Order("created_at ASC, id ASC").
+		Limit(limit).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询待对账支付单失败: %w", err)
+	}
+	return toPaymentIntents(records), nil
+}
+
+// applyResult 按渠道支付结果更新支付单，回调与主动查询共用
+// 加锁后只处理 pending 支付单，重复通知直接返回当前状态；
+// 支付成功但订单已取消或已付清时原路退款，支付单标记为 refunded
+func (s *OnlinePaymentServiceImpl) applyResult(ctx context.Context, provider sales.PaymentProvider, result *sales.PaymentCallback) (*sales.PaymentIntent, error) {
+	var record PaymentIntentRecord
+	err := s.orders.tx.WithTx(ctx, func(ctx context.Context) error {
+		txDB := s.orders.tx.GetDB(ctx).WithContext(ctx)
+		if err := txDB.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("intent_no = ? AND provider = ?", result.IntentNo, provider.Name()).
+			First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return common.NewBusinessError(common.ErrCodePaymentIntentNotFound, "支付单不存在")
+			}
+			return fmt.Errorf("查询支付单失败: %w", err)
+		}
+		if record.Status != sales.PaymentIntentStatusPending {
+			return nil
+		}
+
+		updates := map[string]interface{}{}
+		switch result.Status {
+		case sales.PaymentIntentStatusSucceeded:
+			if result.Amount != record.Amount {
+				return common.NewBusinessErrorWithDetails(common.ErrCodePaymentAmountMismatch, "实付金额与支付单金额不一致",
+					fmt.Sprintf("支付单：%d，实付：%d", record.Amount, result.Amount))
+			}
+			if result.ProviderTradeNo != "" {
+				updates["provider_trade_no"] = result.ProviderTradeNo
+				record.ProviderTradeNo = result.ProviderTradeNo
+			}
+			paidAt := result.PaidAt
+			if paidAt == 0 {
+				paidAt = time.Now().Unix()
+			}
+			updates["paid_at"] = paidAt
+			record.PaidAt = paidAt
+
+			payment, err := s.recordOnlinePayment(ctx, &record)
+			if err != nil {
+				var bizErr *common.BusinessError
+				if !errors.As(err, &bizErr) || (bizErr.Code != common.ErrCodeOrderStatusInvalid && bizErr.Code != common.ErrCodeOrderOverpaid) {
+					return err
+				}
+				// 订单已取消或已由其他方式付清，款项原路退回
+				if _, refundErr := provider.Refund(ctx, sales.ProviderRefundRequest{
+					IntentNo:        record.IntentNo,
+					ProviderTradeNo: record.ProviderTradeNo,
+					RefundNo:        "refund_" + record.IntentNo,
+					Amount:          record.Amount,
+					Reason:          bizErr.Message,
+				}); refundErr != nil {
+					return common.NewBusinessErrorWithDetails(common.ErrCodePaymentProviderFailed, "支付渠道退款失败", refundErr.Error())
+				}
+				record.Status = sales.PaymentIntentStatusRefunded
+				record.FailureReason = truncateReason(bizErr.Message)
+			} else {
+				record.Status = sales.PaymentIntentStatusSucceeded
+				record.OrderPaymentID = payment.ID
+				updates["order_payment_id"] = payment.ID
+			}
+		case sales.PaymentIntentStatusFailed:
+			record.Status = sales.PaymentIntentStatusFailed
+			record.FailureReason = "支付失败"
+		default:
+			// 渠道侧仍在支付中
+			return nil
+		}
+
+		record.UpdatedAt = time.Now().Unix()
+		updates["status"] = record.Status
+		updates["failure_reason"] = record.FailureReason
+		updates["updated_at"] = record.UpdatedAt
+		if err := txDB.Model(&PaymentIntentRecord{}).Where("id = ?", record.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新支付单失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	intent := toPaymentIntent(record)
+	return &intent, nil
+}
+
+// recordOnlinePayment 锁定订单后以 online 方式记入支付明细，必须在事务中调用
+func (s *OnlinePaymentServiceImpl) recordOnlinePayment(ctx context.Context, record *PaymentIntentRecord) (*OrderPaymentRecord, error) {
+	order, err := s.orders.getOrderForUpdate(ctx, record.OrderID)
+	if err != nil {
+		return nil, err
+	}
+	lines := []sales.PaymentLineReq{{Method: string(constants.PaymentMethodOnline), Amount: record.Amount}}
+	records, err := s.orders.recordPayments(ctx, order, lines, record.OperatorID, "online_"+record.IntentNo)
+	if err != nil {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+func (s *OnlinePaymentServiceImpl) provider(name string) (sales.PaymentProvider, error) {
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, common.NewBusinessError(common.ErrCodePaymentProviderNotFound, fmt.Sprintf("支付渠道不存在: %s", name))
+	}
+	return p, nil
+}
+
+// truncateReason 截断原因文本以适配 failure_reason 列宽
+func truncateReason(reason string) string {
+	runes := []rune(reason)
+	if len(runes) > 255 {
+		return string(runes[:255])
+	}
+	return reason
+}
+
+func toPaymentIntent(r PaymentIntentRecord) sales.PaymentIntent {
+	return sales.PaymentIntent{
+		ID:              r.ID,
+		IntentNo:        r.IntentNo,
+		OrderID:         r.OrderID,
+		CustomerID:      r.CustomerID,
+		Provider:        r.Provider,
+		ProviderTradeNo: r.ProviderTradeNo,
+		Amount:          r.Amount,
+		Status:          r.Status,
+		PayURL:          r.PayURL,
+		OrderPaymentID:  r.OrderPaymentID,
+		FailureReason:   r.FailureReason,
+		OperatorID:      r.OperatorID,
+		PaidAt:          r.PaidAt,
+		CreatedAt:       r.CreatedAt,
+		UpdatedAt:       r.UpdatedAt,
+	}
+}
+
+func toPaymentIntents(records []PaymentIntentRecord) []sales.PaymentIntent {
+	intents := make([]sales.PaymentIntent, len(records))
+	for i, r := range records {
+		intents[i] = toPaymentIntent(r)
+	}
+	return intents
+}
+
+var _ sales.OnlinePaymentService = (*OnlinePaymentServiceImpl)(nil)
@@ -0,0 +1,68 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/marketing"
+)
+
+// redeemCoupon 在下单事务中核销优惠券，并将优惠金额计入订单折扣
+// 优惠金额不超过订单当前应付金额
+func (s *SalesServiceImpl) redeemCoupon(ctx context.Context, order *model.Order, code string, items []*model.OrderItem, productMap map[int64]catalog.Product) (*marketing.CouponRedemption, error) {
+	if s.couponSvc == nil {
+		return nil, common.NewBusinessError(common.ErrCodeCouponInvalid, "当前不支持使用优惠券")
+	}
+
+	lines := make([]marketing.CouponLine, len(items))
+	for i, item := range items {
+		lines[i] = marketing.CouponLine{
+			ProductID: item.ProductID,
+			Category:  productMap[item.ProductID].Category,
+			Amount:    int64(math.Round(item.FinalPrice * 100)), // 套餐抵扣项实付为 0，不参与优惠
+		}
+	}
+
+	redemption, err := s.couponSvc.RedeemCoupon(ctx, marketing.RedeemCouponRequest{
+		Code:       code,
+		CustomerID: order.CustomerID,
+		OrderID:    order.ID,
+		Lines:      lines,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	finalAmount := int64(math.Round(order.FinalAmount*100)) - redemption.Discount
+	if finalAmount < 0 {
+		finalAmount = 0
+	}
+	discountAmount := int64(math.Round(order.TotalAmount*100)) - finalAmount
+
+	txDB := s.tx.GetDB(ctx)
+	if err := txDB.WithContext(ctx).Model(&model.Order{}).Where("id = ?", order.ID).Updates(map[string]interface{}{
+		"discount_amount": float64(discountAmount) / 100,
+		"final_amount":    float64(finalAmount) / 100,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("更新订单优惠金额失败: %w", err)
+	}
+	order.DiscountAmount = float64(discountAmount) / 100
+	order.FinalAmount = float64(finalAmount) / 100
+
+	return redemption, nil
+}
+
+// reverseCoupon 订单取消或全额退款时退回已核销的优惠券
+func (s *SalesServiceImpl) reverseCoupon(ctx context.Context, orderID int64) error {
+	if s.couponSvc == nil {
+		return nil
+	}
+	if err := s.couponSvc.ReverseCouponRedemption(ctx, orderID); err != nil {
+		return fmt.Errorf("退回优惠券失败: %w", err)
+	}
+	return nil
+}
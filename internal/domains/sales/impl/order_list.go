@@ -0,0 +1,216 @@
+package impl
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gen/field"
+)
+
+const (
+	defaultOrderSort     = "order_date"
+	maxOrderListPageSize = 1000
+)
+
+// orderSortFields 订单列表支持的排序字段，均有索引或主键支撑
+var orderSortFields = map[string]bool{
+	"order_date":   true,
+	"final_amount": true,
+	"id":           true,
+}
+
+// orderCursor 游标内容：排序方式 + 上一页最后一行的排序值与ID
+type orderCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// searchOrders 按筛选条件查询订单
+// 偏移模式返回总数；游标模式按 (排序字段, id) 做 keyset 翻页，总数返回 -1。
+// 两种模式在还有更多数据时都会返回下一页游标。
+func (s *SalesServiceImpl) searchOrders(ctx context.Context, req *sales.ListOrdersRequest) ([]*model.Order, int64, string, error) {
+	sortField, desc, err := parseOrderSort(req.OrderBy)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	sortKey := sortField + "_asc"
+	if desc {
+		sortKey = sortField + "_desc"
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if pageSize > maxOrderListPageSize {
+		pageSize = maxOrderListPageSize
+	}
+
+	o := s.q.Order
+	q := o.WithContext(ctx)
+
+	// 1. 筛选条件
+	if req.CustomerID > 0 {
+		q = q.Where(o.CustomerID.Eq(req.CustomerID))
+	}
+	if req.Status != "" {
+		q = q.Where(o.Status.Eq(req.Status))
+	}
+	if req.AssignedTo > 0 {
+		q = q.Where(o.AssignedTo.Eq(req.AssignedTo))
+	}
+	if req.PaymentStatus != "" {
+		q = q.Where(o.PaymentStatus.Eq(req.PaymentStatus))
+	}
+	if req.PaymentMethod != "" {
+		q = q.Where(o.PaymentMethod.Eq(req.PaymentMethod))
+	}
+	if !req.OrderDateFrom.IsZero() {
+		q = q.Where(o.OrderDate.Gte(req.OrderDateFrom))
+	}
+	if !req.OrderDateTo.IsZero() {
+		q = q.Where(o.OrderDate.Lt(req.OrderDateTo))
+	}
+	if req.MinAmount > 0 {
+		q = q.Where(o.FinalAmount.Gte(float64(req.MinAmount) / 100))
+	}
+	if req.MaxAmount > 0 {
+		q = q.Where(o.FinalAmount.Lte(float64(req.MaxAmount) / 100))
+	}
+	if req.OrderNoPrefix != "" {
+		q = q.Where(o.OrderNo.Like(req.OrderNoPrefix + "%"))
+	}
+
+	// 2. 偏移模式统计总数，游标模式追加 keyset 条件
+	var total int64 = -1
+	if req.Cursor == "" {
+		if total, err = q.Count(); err != nil {
+			return nil, 0, "", fmt.Errorf("查询订单总数失败: %w", err)
+		}
+		page := req.Page
+		if page <= 0 {
+			page = 1
+		}
+		q = q.Offset((page - 1) * pageSize)
+	} else {
+		cursor, err := decodeOrderCursor(req.Cursor, sortKey)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		cond, err := s.orderKeysetCondition(sortField, desc, cursor)
+		if err != nil {
+			return nil, 0, "", err
+		}
+		q = q.Where(cond)
+	}
+
+	// 3. 排序：排序字段相同时按 ID 保证顺序稳定
+	sortExpr, _ := o.GetFieldByName(sortField)
+	if desc {
+		q = q.Order(sortExpr.Desc(), o.ID.Desc())
+	} else {
+		q = q.Order(sortExpr, o.ID)
+	}
+
+	// 多取一行判断是否还有下一页
+	orders, err := q.Limit(pageSize + 1).Find()
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("查询订单列表失败: %w", err)
+	}
+
+	var nextCursor string
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		nextCursor = encodeOrderCursor(sortKey, sortField, orders[len(orders)-1])
+	}
+	return orders, total, nextCursor, nil
+}
+
+// orderKeysetCondition 构造 (排序字段, id) 严格位于游标之后的条件
+func (s *SalesServiceImpl) orderKeysetCondition(sortField string, desc bool, c *orderCursor) (field.Expr, error) {
+	o := s.q.Order
+	invalid := common.NewBusinessError(common.ErrCodeInvalidParam, "无效的分页游标")
+
+	idAfter := o.ID.Gt(c.ID)
+	if desc {
+		idAfter = o.ID.Lt(c.ID)
+	}
+
+	switch sortField {
+	case "order_date":
+		v, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, invalid
+		}
+		if desc {
+			return field.Or(o.OrderDate.Lt(v), field.And(o.OrderDate.Eq(v), idAfter)), nil
+		}
+		return field.Or(o.OrderDate.Gt(v), field.And(o.OrderDate.Eq(v), idAfter)), nil
+	case "final_amount":
+		v, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return nil, invalid
+		}
+		if desc {
+			return field.Or(o.FinalAmount.Lt(v), field.And(o.FinalAmount.Eq(v), idAfter)), nil
+		}
+		return field.Or(o.FinalAmount.Gt(v), field.And(o.FinalAmount.Eq(v), idAfter)), nil
+	default:
+		return idAfter, nil
+	}
+}
+
+// parseOrderSort 解析 <字段>_<asc|desc>，为空时按下单时间倒序
+func parseOrderSort(orderBy string) (string, bool, error) {
+	if orderBy == "" {
+		return defaultOrderSort, true, nil
+	}
+	i := strings.LastIndex(orderBy, "_")
+	if i > 0 {
+		sortField, dir := orderBy[:i], orderBy[i+1:]
+		if orderSortFields[sortField] && (dir == "asc" || dir == "desc") {
+			return sortField, dir == "desc", nil
+		}
+	}
+	return "", false, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("不支持的排序方式: %s", orderBy))
+}
+
+func encodeOrderCursor(sortKey, sortField string, last *model.Order) string {
+	c := orderCursor{Sort: sortKey, ID: last.ID}
+	switch sortField {
+	case "order_date":
+		c.Value = last.OrderDate.Format(time.RFC3339Nano)
+	case "final_amount":
+		c.Value = strconv.FormatFloat(last.FinalAmount, 'f', -1, 64)
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeOrderCursor 解析游标，游标须与当前排序方式一致
+func decodeOrderCursor(raw, sortKey string) (*orderCursor, error) {
+	invalid := common.NewBusinessError(common.ErrCodeInvalidParam, "无效的分页游标")
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, invalid
+	}
+	var c orderCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, invalid
+	}
+	if c.Sort != sortKey {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "分页游标与排序方式不匹配")
+	}
+	return &c, nil
+}
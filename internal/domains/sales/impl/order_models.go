@@ -0,0 +1,94 @@
+package impl
+
+// OrderStatusLogRecord 映射 order_status_logs（订单状态流转日志）
+type OrderStatusLogRecord struct {
+	ID         int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	OrderID    int64  `gorm:"column:order_id;index:idx_order_time"`
+	FromStatus string `gorm:"column:from_status;size:20;not null;default:''"`
+	ToStatus   string `gorm:"column:to_status;size:20;not null"`
+	OperatorID int64  `gorm:"column:operator_id;not null;default:0"`
+	Reason     string `gorm:"column:reason;size:255"`
+	CreatedAt  int64  `gorm:"column:created_at;not null"`
+}
+
+func (OrderStatusLogRecord) TableName() string { return "order_status_logs" }
+
+// OrderRefundRecord 映射 order_refunds（退款单）
+type OrderRefundRecord struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	OrderID        int64  `gorm:"column:order_id;index:idx_refund_order"`
+	Amount         int64  `gorm:"column:amount;not null"` // cents
+	WalletTxID     int64  `gorm:"column:wallet_tx_id;not null;default:0"`
+	IdempotencyKey string `gorm:"column:idempotency_key;uniqueIndex:uk_refund_idem;size:64;not null"`
+	Reason         string `gorm:"column:reason;size:255"`
+	OperatorID     int64  `gorm:"column:operator_id;not null;default:0"`
+	CreatedAt      int64  `gorm:"column:created_at;not null"`
+}
+
+func (OrderRefundRecord) TableName() string { return "order_refunds" }
+
+// OrderRefundItemRecord 映射 order_refund_items（退款明细）
+type OrderRefundItemRecord struct {
+	ID          int64 `gorm:"column:id;primaryKey;autoIncrement"`
+	RefundID    int64 `gorm:"column:refund_id;index:idx_refund_item_refund"`
+	OrderID     int64 `gorm:"column:order_id;index:idx_refund_item_order"`
+	OrderItemID int64 `gorm:"column:order_item_id;not null"`
+	ProductID   int64 `gorm:"column:product_id;not null"`
+	Quantity    int32 `gorm:"column:quantity;not null"`
+	Amount      int64 `gorm:"column:amount;not null"` // cents
+	CreatedAt   int64 `gorm:"column:created_at;not null"`
+}
+
+func (OrderRefundItemRecord) TableName() string { return "order_refund_items" }
+
+// OrderRefundPaymentRecord 映射 order_refund_payments（退款去向：退款金额在各支付明细上的分摊）
+type OrderRefundPaymentRecord struct {
+	ID               int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	RefundID         int64  `gorm:"column:refund_id;index:idx_refund_payment_refund"`
+	OrderID          int64  `gorm:"column:order_id;index:idx_refund_payment_order,priority:1"`
+	OrderPaymentID   int64  `gorm:"column:order_payment_id;index:idx_refund_payment_order,priority:2;not null;default:0"`
+	Method           string `gorm:"column:method;size:20;not null"`
+	Amount           int64  `gorm:"column:amount;not null"` // cents
+	WalletTxID       int64  `gorm:"column:wallet_tx_id;not null;default:0"`
+	Provider         string `gorm:"column:provider;size:32;not null;default:''"`
+	ProviderRefundNo string `gorm:"column:provider_refund_no;size:128;not null;default:''"`
+	CreatedAt        int64  `gorm:"column:created_at;not null"`
+}
+
+func (OrderRefundPaymentRecord) TableName() string { return "order_refund_payments" }
+
+// OrderPaymentRecord 映射 order_payments（订单支付明细）
+type OrderPaymentRecord struct {
+	ID             int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	OrderID        int64  `gorm:"column:order_id;index:idx_payment_order"`
+	Method         string `gorm:"column:method;size:20;not null"`
+	Amount         int64  `gorm:"column:amount;not null"` // cents
+	WalletTxID     int64  `gorm:"column:wallet_tx_id;not null;default:0"`
+	IdempotencyKey string `gorm:"column:idempotency_key;uniqueIndex:uk_payment_idem;size:128;not null"`
+	OperatorID     int64  `gorm:"column:operator_id;not null;default:0"`
+	CreatedAt      int64  `gorm:"column:created_at;not null"`
+}
+
+func (OrderPaymentRecord) TableName() string { return "order_payments" }
+
+// PaymentIntentRecord 映射 payment_intents（在线支付单）
+type PaymentIntentRecord struct {
+	ID              int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	IntentNo        string `gorm:"column:intent_no;uniqueIndex:uk_intent_no;size:64;not null"`
+	OrderID         int64  `gorm:"column:order_id;index:idx_intent_order"`
+	CustomerID      int64  `gorm:"column:customer_id;not null"`
+	Provider        string `gorm:"column:provider;size:32;not null"`
+	ProviderTradeNo string `gorm:"column:provider_trade_no;size:64;not null;default:''"`
+	Amount          int64  `gorm:"column:amount;not null"` // cents
+	Status          string `gorm:"column:status;size:20;not null;index:idx_intent_status_time,priority:1"`
+	PayURL          string `gorm:"column:pay_url;size:512;not null;default:''"`
+	OrderPaymentID  int64  `gorm:"column:order_payment_id;not null;default:0"`
+	FailureReason   string `gorm:"column:failure_reason;size:255;not null;default:''"`
+	IdempotencyKey  string `gorm:"column:idempotency_key;uniqueIndex:uk_intent_idem;size:128;not null"`
+	OperatorID      int64  `gorm:"column:operator_id;not null;default:0"`
+	PaidAt          int64  `gorm:"column:paid_at;not null;default:0"`
+	CreatedAt       int64  `gorm:"column:created_at;not null;index:idx_intent_status_time,priority:2"`
+	UpdatedAt       int64  `gorm:"column:updated_at;not null"`
+}
+
+func (PaymentIntentRecord) TableName() string { return "payment_intents" }
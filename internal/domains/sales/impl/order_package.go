@@ -0,0 +1,147 @@
+package impl
+
+import (
+	"context"
+	"fmt"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/entitlement"
+	"crm_lite/internal/domains/sales"
+)
+
+// checkPackageItems 下单前校验套餐相关订单项
+// 购买的套餐产品须已配置套餐，使用套餐抵扣的产品须为服务类产品
+func (s *SalesServiceImpl) checkPackageItems(ctx context.Context, items []sales.OrderItemReq, productMap map[int64]catalog.Product) error {
+	for _, item := range items {
+		product := productMap[item.ProductID]
+		isPackage := product.Type == string(constants.ProductTypePackage)
+		if !isPackage && !item.UsePackage {
+			continue
+		}
+		if s.packageSvc == nil {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, "当前不支持次卡套餐")
+		}
+		if isPackage {
+			if item.UsePackage {
+				return common.NewBusinessError(common.ErrCodeInvalidParam, "套餐产品不能使用套餐抵扣")
+			}
+			if _, err := s.packageSvc.GetPackagePlan(ctx, product.ID); err != nil {
+				return err
+			}
+		} else if product.Type != string(constants.ProductTypeService) {
+			return common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("产品 %s 不支持套餐抵扣", product.Name))
+		}
+	}
+	return nil
+}
+
+// redeemPackages 在下单事务中为使用套餐的订单项抵扣次数，幂等键为 order_package_<订单项ID>
+func (s *SalesServiceImpl) redeemPackages(ctx context.Context, order *model.Order, items []sales.OrderItemReq, orderItems []*model.OrderItem) error {
+	for i, item := range items {
+		if !item.UsePackage {
+			continue
+		}
+		if _, err := s.packageSvc.Redeem(ctx, entitlement.RedeemRequest{
+			CustomerID:       order.CustomerID,
+			ServiceProductID: item.ProductID,
+			OrderID:          order.ID,
+			OrderItemID:      orderItems[i].ID,
+			Qty:              item.Qty,
+			IdemKey:          fmt.Sprintf("order_package_%d", orderItems[i].ID),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grantPackages 订单付清时为购买套餐的订单项发放客户套餐
+func (s *SalesServiceImpl) grantPackages(ctx context.Context, order *model.Order) error {
+	if s.packageSvc == nil {
+		return nil
+	}
+	items, err := s.orderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	productIDs := make([]int64, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	products, err := s.catalogSvc.BatchGet(ctx, productIDs)
+	if err != nil {
+		return fmt.Errorf("获取产品信息失败: %w", err)
+	}
+	packageProducts := make(map[int64]bool)
+	for _, p := range products {
+		if p.Type == string(constants.ProductTypePackage) {
+			packageProducts[p.ID] = true
+		}
+	}
+
+	for _, item := range items {
+		if !packageProducts[item.ProductID] {
+			continue
+		}
+		if _, err := s.packageSvc.GrantForOrderItem(ctx, entitlement.GrantRequest{
+			CustomerID:       order.CustomerID,
+			PackageProductID: item.ProductID,
+			OrderID:          order.ID,
+			OrderItemID:      item.ID,
+			Qty:              item.Quantity,
+		}); err != nil {
+			return fmt.Errorf("发放套餐失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// reversePackages 订单取消或退款时冲回订单项的套餐变更
+// 抵扣项退回次数，购买项扣回次数；idemPrefix 区分不同的取消/退款操作
+func (s *SalesServiceImpl) reversePackages(ctx context.Context, lines []sales.OrderRefundLine, idemPrefix, note string) error {
+	if s.packageSvc == nil {
+		return nil
+	}
+	for _, l := range lines {
+		if err := s.packageSvc.ReverseOrderItem(ctx, entitlement.ReverseRequest{
+			OrderItemID: l.OrderItemID,
+			Qty:         l.Quantity,
+			IdemKey:     fmt.Sprintf("%s_%d", idemPrefix, l.OrderItemID),
+			Note:        note,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelPackages 订单取消时退回全部订单项的套餐抵扣次数
+func (s *SalesServiceImpl) cancelPackages(ctx context.Context, orderID int64) error {
+	if s.packageSvc == nil {
+		return nil
+	}
+	items, err := s.orderItems(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	lines := make([]sales.OrderRefundLine, len(items))
+	for i, item := range items {
+		lines[i] = sales.OrderRefundLine{OrderItemID: item.ID, ProductID: item.ProductID, Quantity: item.Quantity}
+	}
+	return s.reversePackages(ctx, lines, fmt.Sprintf("package_cancel_%d", orderID), "订单取消")
+}
+
+// orderItems 在当前事务中查询订单全部明细
+func (s *SalesServiceImpl) orderItems(ctx context.Context, orderID int64) ([]*model.OrderItem, error) {
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	items, err := txQuery.OrderItem.WithContext(ctx).Where(txQuery.OrderItem.OrderID.Eq(orderID)).Find()
+	if err != nil {
+		return nil, fmt.Errorf("查询订单项失败: %w", err)
+	}
+	return items, nil
+}
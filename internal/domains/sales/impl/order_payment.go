@@ -0,0 +1,237 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gorm"
+)
+
+// AddOrderPayments 追加订单支付明细
+// 在单一事务中完成：金额校验 + 钱包扣款 + 支付明细 + 支付状态重算 + outbox 事件
+func (s *SalesServiceImpl) AddOrderPayments(ctx context.Context, req sales.AddOrderPaymentReq) ([]sales.OrderPayment, error) {
+	if len(req.Payments) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "支付明细不能为空")
+	}
+
+	var result []sales.OrderPayment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		order, err := s.getOrderForUpdate(ctx, req.OrderID)
+		if err != nil {
+			return err
+		}
+
+		// 幂等性检查：持有订单行锁后按逐行幂等键精确匹配，相同键重复提交直接返回已有支付明细
+		if req.IdemKey != "" {
+			var existing []OrderPaymentRecord
+			if err := s.tx.GetDB(ctx).WithContext(ctx).
+				Where("order_id = ? AND idempotency_key IN ?", order.ID, paymentLineIdemKeys(order.ID, req.IdemKey, len(req.Payments))).
+				Order("id ASC").
+				Find(&existing).Error; err != nil {
+				return fmt.Errorf("检查幂等性失败: %w", err)
+			}
+			if len(existing) > 0 {
+				result = toOrderPayments(existing)
+				return nil
+			}
+		}
+
+		records, err := s.recordPayments(ctx, order, req.Payments, req.OperatorID, req.IdemKey)
+		if err != nil {
+			return err
+		}
+		result = toOrderPayments(records)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListOrderPayments 查询订单支付明细
+func (s *SalesServiceImpl) ListOrderPayments(ctx context.Context, orderID int64) ([]sales.OrderPayment, error) {
+	if _, err := s.q.Order.WithContext(ctx).Where(s.q.Order.ID.Eq(orderID)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var records []OrderPaymentRecord
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询支付明细失败: %w", err)
+	}
+	return toOrderPayments(records), nil
+}
+
+// recordPayments 记录支付明细并重算支付状态，必须在事务中调用
+// 钱包支付行按行调用 DebitForOrder，幂等键为 order_pay_<订单ID>_<idemKey摘要>_<行号>
+func (s *SalesServiceImpl) recordPayments(ctx context.Context, order *model.Order, lines []sales.PaymentLineReq, operatorID int64, idemKey string) ([]OrderPaymentRecord, error) {
+	if order.Status == string(constants.OrderStatusCancelled) || order.Status == string(constants.OrderStatusRefunded) {
+		return nil, common.NewBusinessError(common.ErrCodeOrderStatusInvalid, "订单状态不允许支付")
+	}
+
+	var lineTotal int64
+	for _, l := range lines {
+		if !isValidPaymentMethod(l.Method) {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("不支持的支付方式: %s", l.Method))
+		}
+		if l.Amount <= 0 {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "支付金额必须大于0")
+		}
+		lineTotal += l.Amount
+	}
+
+	paid, err := s.paidAmount(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	finalAmount := int64(order.FinalAmount * 100)
+	if paid+lineTotal > finalAmount {
+		return nil, common.NewBusinessErrorWithDetails(common.ErrCodeOrderOverpaid, "支付金额超过订单应付金额",
+			fmt.Sprintf("应付：%d，已付：%d，本次：%d", finalAmount, paid, lineTotal))
+	}
+
+	now := time.Now()
+	if idemKey == "" {
+		idemKey = fmt.Sprintf("%d", now.UnixNano())
+	}
+
+	txDB := s.tx.GetDB(ctx)
+	lineIdems := paymentLineIdemKeys(order.ID, idemKey, len(lines))
+	records := make([]OrderPaymentRecord, len(lines))
+	for i, l := range lines {
+		lineIdem := lineIdems[i]
+
+		var walletTxID int64
+		if l.Method == string(constants.PaymentMethodWallet) {
+			if err := s.billingSvc.DebitForOrder(ctx, order.CustomerID, order.ID, l.Amount, lineIdem); err != nil {
+				return nil, fmt.Errorf("钱包扣款失败: %w", err)
+			}
+			walletTx, err := s.billingSvc.GetTransactionByIdemKey(ctx, lineIdem)
+			if err != nil {
+				return nil, fmt.Errorf("查询扣款流水失败: %w", err)
+			}
+			walletTxID = walletTx.ID
+		}
+
+		records[i] = OrderPaymentRecord{
+			OrderID:        order.ID,
+			Method:         l.Method,
+			Amount:         l.Amount,
+			WalletTxID:     walletTxID,
+			IdempotencyKey: lineIdem,
+			OperatorID:     operatorID,
+			CreatedAt:      now.Unix(),
+		}
+	}
+	if err := txDB.WithContext(ctx).Create(&records).Error; err != nil {
+		return nil, fmt.Errorf("创建支付明细失败: %w", err)
+	}
+
+	if err := s.syncPaymentStatus(ctx, order, paid+lineTotal, operatorID); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// syncPaymentStatus 按已付合计重算支付状态，付清时订单流转为 paid
+func (s *SalesServiceImpl) syncPaymentStatus(ctx context.Context, order *model.Order, paid int64, operatorID int64) error {
+	finalAmount := int64(order.FinalAmount * 100)
+
+	status := string(constants.PaymentStatusPartiallyPaid)
+	switch {
+	case paid >= finalAmount:
+		status = string(constants.PaymentStatusPaid)
+	case paid <= 0:
+		status = string(constants.PaymentStatusUnpaid)
+	}
+
+	if status == string(constants.PaymentStatusPaid) && sales.CanTransition(order.Status, string(constants.OrderStatusPaid)) {
+		return s.applyStatus(ctx, order, string(constants.OrderStatusPaid), operatorID, "订单已付清")
+	}
+
+	if order.PaymentStatus == status {
+		return nil
+	}
+	txDB := s.tx.GetDB(ctx)
+	if err := txDB.WithContext(ctx).Model(&model.Order{}).
+		Where("id = ?", order.ID).
+		Update("payment_status", status).Error; err != nil {
+		return fmt.Errorf("更新支付状态失败: %w", err)
+	}
+	order.PaymentStatus = status
+	return nil
+}
+
+// paidAmount 订单已付金额合计（分）
+func (s *SalesServiceImpl) paidAmount(ctx context.Context, orderID int64) (int64, error) {
+	var paid int64
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Model(&OrderPaymentRecord{}).
+		Where("order_id = ?", orderID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&paid).Error; err != nil {
+		return 0, fmt.Errorf("查询已付金额失败: %w", err)
+	}
+	return paid, nil
+}
+
+// orderPaymentMethod 订单级支付方式：单一支付方式取该方式，组合支付记为 mixed
+func orderPaymentMethod(payMethod string, lines []sales.PaymentLineReq) string {
+	if len(lines) == 0 {
+		return payMethod
+	}
+	method := lines[0].Method
+	for _, l := range lines[1:] {
+		if l.Method != method {
+			return string(constants.PaymentMethodMixed)
+		}
+	}
+	return method
+}
+
+func isValidPaymentMethod(method string) bool {
+	for _, m := range constants.ValidPaymentMethods() {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// paymentLineIdemKeys 一次追加的 n 行支付明细的逐行幂等键：order_pay_<订单ID>_<idemKey摘要>_<行号>
+// 客户端键取摘要后拼接，保证钱包流水幂等键不超过列宽
+func paymentLineIdemKeys(orderID int64, idemKey string, n int) []string {
+	prefix := fmt.Sprintf("order_pay_%d_%s_", orderID, common.IdempotencyKeyDigest(idemKey))
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = prefix + strconv.Itoa(i)
+	}
+	return keys
+}
+
+func toOrderPayments(records []OrderPaymentRecord) []sales.OrderPayment {
+	payments := make([]sales.OrderPayment, len(records))
+	for i, r := range records {
+		payments[i] = sales.OrderPayment{
+			ID:         r.ID,
+			OrderID:    r.OrderID,
+			Method:     r.Method,
+			Amount:     r.Amount,
+			WalletTxID: r.WalletTxID,
+			OperatorID: r.OperatorID,
+			CreatedAt:  r.CreatedAt,
+		}
+	}
+	return payments
+}
@@ -0,0 +1,540 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gorm"
+)
+
+// RefundOrderItems 按订单项部分退款
+// 在单一事务中完成：可退数量校验 + 原路退款 + 退款单 + 支付状态 + outbox 事件
+func (s *SalesServiceImpl) RefundOrderItems(ctx context.Context, req sales.PartialRefundReq) (*sales.OrderRefund, error) {
+	if len(req.Items) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "退款订单项不能为空")
+	}
+
+	var result *sales.OrderRefund
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		refund, err := s.refundItems(ctx, req.OrderID, req.Items, req.OperatorID, req.Reason, req.IdemKey)
+		if err != nil {
+			return err
+		}
+		result = refund
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListOrderRefunds 查询订单的退款记录
+func (s *SalesServiceImpl) ListOrderRefunds(ctx context.Context, orderID int64) ([]sales.OrderRefund, error) {
+	if _, err := s.q.Order.WithContext(ctx).Where(s.q.Order.ID.Eq(orderID)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var records []OrderRefundRecord
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询退款记录失败: %w", err)
+	}
+
+	var items []OrderRefundItemRecord
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询退款明细失败: %w", err)
+	}
+
+	linesByRefund := make(map[int64][]sales.OrderRefundLine)
+	for _, it := range items {
+		linesByRefund[it.RefundID] = append(linesByRefund[it.RefundID], toRefundLine(it))
+	}
+
+	var payments []OrderRefundPaymentRecord
+	if err := s.db.WithContext(ctx).Where("order_id = ?", orderID).Order("id ASC").Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("查询退款去向失败: %w", err)
+	}
+	paymentsByRefund := make(map[int64][]OrderRefundPaymentRecord)
+	for _, p := range payments {
+		paymentsByRefund[p.RefundID] = append(paymentsByRefund[p.RefundID], p)
+	}
+
+	result := make([]sales.OrderRefund, len(records))
+	for i, r := range records {
+		result[i] = toOrderRefund(r, linesByRefund[r.ID], paymentsByRefund[r.ID])
+	}
+	return result, nil
+}
+
+// refundItems 退款核心流程，必须在事务中调用
+// items 为空时退回订单全部剩余可退数量；idemKey 非空时相同键重复提交直接返回已有退款单
+func (s *SalesServiceImpl) refundItems(ctx context.Context, orderID int64, items []sales.RefundItemReq, operatorID int64, reason, idemKey string) (*sales.OrderRefund, error) {
+	txDB := s.tx.GetDB(ctx)
+	txQuery := query.Use(txDB)
+
+	// 1. 获取订单并加锁，幂等性检查须在订单行锁之后
+	order, err := s.getOrderForUpdate(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 幂等性检查：并发的相同请求在订单行锁上排队，前一个提交后此处可查到其退款单
+	if idemKey != "" {
+		var existing OrderRefundRecord
+		err := txDB.WithContext(ctx).Where("idempotency_key = ?", refundIdemKey(orderID, idemKey)).First(&existing).Error
+		if err == nil {
+			return s.loadRefund(ctx, txDB, existing)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("检查幂等性失败: %w", err)
+		}
+	}
+
+	// 2. 校验订单状态
+	if order.Status == string(constants.OrderStatusRefunded) {
+		return nil, common.NewBusinessError(common.ErrCodeOrderStatusInvalid, "订单已退款")
+	}
+	if order.Status != string(constants.OrderStatusPaid) && order.Status != string(constants.OrderStatusCompleted) {
+		return nil, common.NewBusinessError(common.ErrCodeOrderCannotRefund, "订单状态不支持退款")
+	}
+
+	// 3. 计算各订单项剩余可退数量
+	orderItems, err := txQuery.OrderItem.WithContext(ctx).Where(txQuery.OrderItem.OrderID.Eq(orderID)).Find()
+	if err != nil {
+		return nil, fmt.Errorf("获取订单项失败: %w", err)
+	}
+
+	var refundedItems []OrderRefundItemRecord
+	if err := txDB.WithContext(ctx).Where("order_id = ?", orderID).Find(&refundedItems).Error; err != nil {
+		return nil, fmt.Errorf("查询退款明细失败: %w", err)
+	}
+	refundedQty := make(map[int64]int32)
+	var refundedAmount int64
+	for _, it := range refundedItems {
+		refundedQty[it.OrderItemID] += it.Quantity
+		refundedAmount += it.Amount
+	}
+
+	itemMap := make(map[int64]*model.OrderItem, len(orderItems))
+	for _, it := range orderItems {
+		itemMap[it.ID] = it
+	}
+
+	// 4. 组装本次退款明细
+	var lines []sales.OrderRefundLine
+	if len(items) == 0 {
+		for _, it := range orderItems {
+			if remaining := it.Quantity - refundedQty[it.ID]; remaining > 0 {
+				lines = append(lines, sales.OrderRefundLine{OrderItemID: it.ID, ProductID: it.ProductID, Quantity: remaining})
+			}
+		}
+	} else {
+		requested := make(map[int64]int32)
+		for _, req := range items {
+			it, ok := itemMap[req.OrderItemID]
+			if !ok {
+				return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("订单项 %d 不属于该订单", req.OrderItemID))
+			}
+			if req.Qty <= 0 {
+				return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "退款数量必须大于0")
+			}
+			requested[it.ID] += req.Qty
+			if requested[it.ID] > it.Quantity-refundedQty[it.ID] {
+				return nil, common.NewBusinessError(common.ErrCodeOrderCannotRefund,
+					fmt.Sprintf("订单项 %d 退款数量超过可退数量", it.ID))
+			}
+			lines = append(lines, sales.OrderRefundLine{OrderItemID: it.ID, ProductID: it.ProductID, Quantity: req.Qty})
+		}
+	}
+	if len(lines) == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeOrderCannotRefund, "订单没有可退的订单项")
+	}
+
+	// 5. 计算退款金额：订单级折扣按行金额比例分摊，全部退完时由最后一行兜底差额
+	totalAmount := int64(order.TotalAmount * 100)
+	finalAmount := int64(order.FinalAmount * 100)
+	var refundAmount int64
+	for i := range lines {
+		it := itemMap[lines[i].OrderItemID]
+		amount := orderItemUnitPrice(it) * int64(lines[i].Quantity)
+		if it.FinalPrice == 0 {
+			// 实付为 0 的订单项（如套餐抵扣）只冲回次数，不退金额
+			amount = 0
+		} else if totalAmount > 0 && totalAmount != finalAmount {
+			amount = amount * finalAmount / totalAmount
+		}
+		lines[i].Amount = amount
+		refundAmount += amount
+		refundedQty[it.ID] += lines[i].Quantity
+	}
+
+	fullyRefunded := true
+	for _, it := range orderItems {
+		if refundedQty[it.ID] < it.Quantity {
+			fullyRefunded = false
+			break
+		}
+	}
+	if remaining := finalAmount - refundedAmount; fullyRefunded || refundAmount > remaining {
+		lines[len(lines)-1].Amount += remaining - refundAmount
+		refundAmount = remaining
+	}
+
+	// 6. 按支付明细原路退回
+	now := time.Now()
+	if idemKey == "" {
+		idemKey = fmt.Sprintf("%d", now.UnixNano())
+	}
+	refundIdem := refundIdemKey(orderID, idemKey)
+
+	walletTxID, payments, err := s.refundPayments(ctx, order, refundAmount, refundIdem, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	// 7. 记录退款单、退款明细及退款去向
+	record := &OrderRefundRecord{
+		OrderID:        orderID,
+		Amount:         refundAmount,
+		WalletTxID:     walletTxID,
+		IdempotencyKey: refundIdem,
+		Reason:         reason,
+		OperatorID:     operatorID,
+		CreatedAt:      now.Unix(),
+	}
+	if err := txDB.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建退款单失败: %w", err)
+	}
+	if err := s.saveRefundPayments(ctx, record, payments); err != nil {
+		return nil, err
+	}
+
+	itemRecords := make([]OrderRefundItemRecord, len(lines))
+	for i, l := range lines {
+		itemRecords[i] = OrderRefundItemRecord{
+			RefundID:    record.ID,
+			OrderID:     orderID,
+			OrderItemID: l.OrderItemID,
+			ProductID:   l.ProductID,
+			Quantity:    l.Quantity,
+			Amount:      l.Amount,
+			CreatedAt:   now.Unix(),
+		}
+	}
+	if err := txDB.WithContext(ctx).Create(&itemRecords).Error; err != nil {
+		return nil, fmt.Errorf("创建退款明细失败: %w", err)
+	}
+
+	// 回补退款明细对应的库存
+	stockItems := make([]catalog.StockItem, len(lines))
+	for i, l := range lines {
+		stockItems[i] = catalog.StockItem{ProductID: l.ProductID, Qty: l.Quantity}
+	}
+	if err := s.catalogSvc.ReleaseStock(ctx, stockItems); err != nil {
+		return nil, err
+	}
+
+	// 冲回套餐：抵扣项退回次数，购买项扣回次数（已使用则不可退）
+	if err := s.reversePackages(ctx, lines, fmt.Sprintf("package_refund_%d", record.ID), reason); err != nil {
+		return nil, err
+	}
+
+	// 8. 更新订单状态：全部退完流转为 refunded 并退回优惠券，否则标记部分退款
+	if fullyRefunded {
+		if err := s.transitionStatus(ctx, order, string(constants.OrderStatusRefunded), operatorID, reason); err != nil {
+			return nil, err
+		}
+		if err := s.reverseCoupon(ctx, order.ID); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := txQuery.Order.WithContext(ctx).
+			Where(txQuery.Order.ID.Eq(orderID)).
+			Update(txQuery.Order.PaymentStatus, string(constants.PaymentStatusPartiallyRefunded)); err != nil {
+			return nil, fmt.Errorf("更新支付状态失败: %w", err)
+		}
+	}
+
+	// 9. 写入 Outbox 事件
+	eventLines := make([]common.OrderRefundLine, len(lines))
+	for i, l := range lines {
+		eventLines[i] = common.OrderRefundLine{
+			OrderItemID: l.OrderItemID,
+			ProductID:   l.ProductID,
+			Quantity:    l.Quantity,
+			Amount:      l.Amount,
+		}
+	}
+	refundEvent := common.OrderRefundedEvent{
+		OrderID:       orderID,
+		OrderNo:       order.OrderNo,
+		CustomerID:    order.CustomerID,
+		RefundID:      record.ID,
+		RefundAmount:  refundAmount,
+		Reason:        reason,
+		FullyRefunded: fullyRefunded,
+		Lines:         eventLines,
+		RefundedAt:    now.Unix(),
+	}
+	if err := s.outboxSvc.PublishEvent(ctx, common.EventTypeOrderRefunded, refundEvent); err != nil {
+		return nil, fmt.Errorf("写入退款事件失败: %w", err)
+	}
+
+	refund := toOrderRefund(*record, lines, payments)
+	return &refund, nil
+}
+
+// refundPayments 把退款金额按支付明细顺序分摊到尚未退完的支付明细并原路退回，必须在事务中调用
+// 经在线支付单收款的 online 明细通过收款渠道退款，渠道退款单号为 <退款幂等键>_<支付明细ID>，重试时渠道按单号幂等；
+// 钱包支付（及无支付明细的历史订单）合并为一笔钱包退款入账；现金、刷卡等线下支付只记录退回原支付方式的退款去向，
+// 由门店线下退还，不入账钱包。返回钱包退款流水ID与未落库的退款去向
+func (s *SalesServiceImpl) refundPayments(ctx context.Context, order *model.Order, amount int64, refundIdem, reason string) (int64, []OrderRefundPaymentRecord, error) {
+	if amount <= 0 {
+		return 0, nil, nil
+	}
+	txDB := s.tx.GetDB(ctx).WithContext(ctx)
+
+	var paid []OrderPaymentRecord
+	if err := txDB.Where("order_id = ?", order.ID).Order("id ASC").Find(&paid).Error; err != nil {
+		return 0, nil, fmt.Errorf("查询支付明细失败: %w", err)
+	}
+	var refunded []OrderRefundPaymentRecord
+	if err := txDB.Where("order_id = ?", order.ID).Find(&refunded).Error; err != nil {
+		return 0, nil, fmt.Errorf("查询退款去向失败: %w", err)
+	}
+	refundedByPayment := make(map[int64]int64, len(refunded))
+	for _, r := range refunded {
+		refundedByPayment[r.OrderPaymentID] += r.Amount
+	}
+
+	// 1. 分摊：先退尚有余额的支付明细，超出部分（无支付明细的历史订单）退回钱包
+	var allocations []OrderRefundPaymentRecord
+	var onlinePaymentIDs []int64
+	for _, p := range paid {
+		if amount == 0 {
+			break
+		}
+		take := min(p.Amount-refundedByPayment[p.ID], amount)
+		if take <= 0 {
+			continue
+		}
+		allocations = append(allocations, OrderRefundPaymentRecord{OrderID: order.ID, OrderPaymentID: p.ID, Method: p.Method, Amount: take})
+		if p.Method == string(constants.PaymentMethodOnline) {
+			onlinePaymentIDs = append(onlinePaymentIDs, p.ID)
+		}
+		amount -= take
+	}
+	if amount > 0 {
+		allocations = append(allocations, OrderRefundPaymentRecord{OrderID: order.ID, Method: string(constants.PaymentMethodWallet), Amount: amount})
+	}
+
+	intents := make(map[int64]PaymentIntentRecord, len(onlinePaymentIDs))
+	if len(onlinePaymentIDs) > 0 {
+		var records []PaymentIntentRecord
+		if err := txDB.Where("order_payment_id IN ?", onlinePaymentIDs).Find(&records).Error; err != nil {
+			return 0, nil, fmt.Errorf("查询在线支付单失败: %w", err)
+		}
+		for _, r := range records {
+			intents[r.OrderPaymentID] = r
+		}
+	}
+
+	// 2. 退回钱包的部分合并为一笔退款流水
+	var walletAmount int64
+	for _, a := range allocations {
+		if a.Method == string(constants.PaymentMethodWallet) {
+			walletAmount += a.Amount
+		}
+	}
+	var walletTxID int64
+	if walletAmount > 0 {
+		if err := s.billingSvc.CreditForRefund(ctx, order.CustomerID, order.ID, walletAmount, refundIdem); err != nil {
+			return 0, nil, fmt.Errorf("钱包退款失败: %w", err)
+		}
+		walletTx, err := s.billingSvc.GetTransactionByIdemKey(ctx, refundIdem)
+		if err != nil {
+			return 0, nil, fmt.Errorf("查询退款流水失败: %w", err)
+		}
+		walletTxID = walletTx.ID
+	}
+
+	// 3. 在线支付经收款渠道原路退回；渠道退款无法随事务回滚，事务重试时由渠道按退款单号幂等
+	for i := range allocations {
+		a := &allocations[i]
+		if a.Method == string(constants.PaymentMethodWallet) {
+			a.WalletTxID = walletTxID
+			continue
+		}
+		intent, online := intents[a.OrderPaymentID]
+		if !online {
+			// 线下支付退回原支付方式，只记录退款去向
+			continue
+		}
+		provider, ok := s.providers[intent.Provider]
+		if !ok {
+			return 0, nil, common.NewBusinessError(common.ErrCodePaymentProviderNotFound, fmt.Sprintf("支付渠道不存在: %s", intent.Provider))
+		}
+		result, err := provider.Refund(ctx, sales.ProviderRefundRequest{
+			IntentNo:        intent.IntentNo,
+			ProviderTradeNo: intent.ProviderTradeNo,
+			RefundNo:        fmt.Sprintf("%s_%d", refundIdem, a.OrderPaymentID),
+			Amount:          a.Amount,
+			Reason:          reason,
+		})
+		if err != nil {
+			return 0, nil, common.NewBusinessErrorWithDetails(common.ErrCodePaymentProviderFailed, "支付渠道退款失败", err.Error())
+		}
+		if result.Status == sales.PaymentIntentStatusFailed {
+			return 0, nil, common.NewBusinessError(common.ErrCodePaymentProviderFailed, "支付渠道退款失败")
+		}
+		a.Provider = intent.Provider
+		a.ProviderRefundNo = result.ProviderRefundNo
+	}
+	return walletTxID, allocations, nil
+}
+
+// refundCancelledOrder 取消部分付款的订单时原路退回全部已付金额，必须在事务中调用
+// 生成不含退款明细的退款单，幂等键为 order_cancel_<订单ID>（订单只能取消一次）；
+// 支付状态置为 refunded，订单事件由取消流转发布 order.cancelled，不再发布 order.refunded
+func (s *SalesServiceImpl) refundCancelledOrder(ctx context.Context, order *model.Order, operatorID int64, reason string) error {
+	paid, err := s.paidAmount(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	txDB := s.tx.GetDB(ctx).WithContext(ctx)
+	var refunded int64
+	if err := txDB.Model(&OrderRefundRecord{}).
+		Where("order_id = ?", order.ID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&refunded).Error; err != nil {
+		return fmt.Errorf("查询已退金额失败: %w", err)
+	}
+	amount := paid - refunded
+	if amount <= 0 {
+		return nil
+	}
+
+	refundIdem := fmt.Sprintf("order_cancel_%d", order.ID)
+	walletTxID, payments, err := s.refundPayments(ctx, order, amount, refundIdem, reason)
+	if err != nil {
+		return err
+	}
+	record := &OrderRefundRecord{
+		OrderID:        order.ID,
+		Amount:         amount,
+		WalletTxID:     walletTxID,
+		IdempotencyKey: refundIdem,
+		Reason:         reason,
+		OperatorID:     operatorID,
+		CreatedAt:      time.Now().Unix(),
+	}
+	if err := txDB.Create(record).Error; err != nil {
+		return fmt.Errorf("创建退款单失败: %w", err)
+	}
+	if err := s.saveRefundPayments(ctx, record, payments); err != nil {
+		return err
+	}
+
+	if err := txDB.Model(&model.Order{}).
+		Where("id = ?", order.ID).
+		Update("payment_status", string(constants.PaymentStatusRefunded)).Error; err != nil {
+		return fmt.Errorf("更新支付状态失败: %w", err)
+	}
+	order.PaymentStatus = string(constants.PaymentStatusRefunded)
+	return nil
+}
+
+// saveRefundPayments 回填退款单ID后写入退款去向
+func (s *SalesServiceImpl) saveRefundPayments(ctx context.Context, record *OrderRefundRecord, payments []OrderRefundPaymentRecord) error {
+	if len(payments) == 0 {
+		return nil
+	}
+	for i := range payments {
+		payments[i].RefundID = record.ID
+		payments[i].CreatedAt = record.CreatedAt
+	}
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Create(&payments).Error; err != nil {
+		return fmt.Errorf("创建退款去向失败: %w", err)
+	}
+	return nil
+}
+
+// loadRefund 加载已有退款单及其明细、退款去向
+func (s *SalesServiceImpl) loadRefund(ctx context.Context, db *gorm.DB, record OrderRefundRecord) (*sales.OrderRefund, error) {
+	var items []OrderRefundItemRecord
+	if err := db.WithContext(ctx).Where("refund_id = ?", record.ID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询退款明细失败: %w", err)
+	}
+	lines := make([]sales.OrderRefundLine, len(items))
+	for i, it := range items {
+		lines[i] = toRefundLine(it)
+	}
+	var payments []OrderRefundPaymentRecord
+	if err := db.WithContext(ctx).Where("refund_id = ?", record.ID).Order("id ASC").Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("查询退款去向失败: %w", err)
+	}
+	refund := toOrderRefund(record, lines, payments)
+	return &refund, nil
+}
+
+// refundIdemKey 退款单幂等键 order_refund_<订单ID>_<幂等键摘要>，同时用作钱包退款流水的幂等键
+func refundIdemKey(orderID int64, idemKey string) string {
+	return fmt.Sprintf("order_refund_%d_%s", orderID, common.IdempotencyKeyDigest(idemKey))
+}
+
+// orderItemUnitPrice 订单项单价（分），优先使用下单快照
+func orderItemUnitPrice(item *model.OrderItem) int64 {
+	if item.UnitPriceSnapshot > 0 {
+		return item.UnitPriceSnapshot
+	}
+	return int64(item.UnitPrice * 100)
+}
+
+func toRefundLine(it OrderRefundItemRecord) sales.OrderRefundLine {
+	return sales.OrderRefundLine{
+		OrderItemID: it.OrderItemID,
+		ProductID:   it.ProductID,
+		Quantity:    it.Quantity,
+		Amount:      it.Amount,
+	}
+}
+
+func toOrderRefund(r OrderRefundRecord, lines []sales.OrderRefundLine, payments []OrderRefundPaymentRecord) sales.OrderRefund {
+	if lines == nil {
+		lines = []sales.OrderRefundLine{}
+	}
+	refundPayments := make([]sales.OrderRefundPayment, len(payments))
+	for i, p := range payments {
+		refundPayments[i] = sales.OrderRefundPayment{
+			OrderPaymentID:   p.OrderPaymentID,
+			Method:           p.Method,
+			Amount:           p.Amount,
+			WalletTxID:       p.WalletTxID,
+			Provider:         p.Provider,
+			ProviderRefundNo: p.ProviderRefundNo,
+		}
+	}
+	return sales.OrderRefund{
+		ID:         r.ID,
+		OrderID:    r.OrderID,
+		Amount:     r.Amount,
+		WalletTxID: r.WalletTxID,
+		Reason:     r.Reason,
+		OperatorID: r.OperatorID,
+		Lines:      lines,
+		Payments:   refundPayments,
+		CreatedAt:  r.CreatedAt,
+	}
+}
@@ -0,0 +1,268 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/model"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// statusEventTypes 目标状态与 outbox 事件类型的映射
+// paid、refunded 使用专属载荷，在各自流程中单独发布
+var statusEventTypes = map[string]string{
+	string(constants.OrderStatusPending):    common.EventTypeOrderPending,
+	string(constants.OrderStatusConfirmed):  common.EventTypeOrderConfirmed,
+	string(constants.OrderStatusProcessing): common.EventTypeOrderProcessing,
+	string(constants.OrderStatusShipped):    common.EventTypeOrderShipped,
+	string(constants.OrderStatusCompleted):  common.EventTypeOrderCompleted,
+	string(constants.OrderStatusCancelled):  common.EventTypeOrderCancelled,
+}
+
+// UpdateOrderStatus 订单状态流转
+// 在单一事务中完成：状态机校验 + 状态更新 + 流转日志 + outbox 事件
+func (s *SalesServiceImpl) UpdateOrderStatus(ctx context.Context, req sales.UpdateOrderStatusReq) (*sales.Order, error) {
+	if req.Status == "" {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "目标状态不能为空")
+	}
+
+	// paid 表示支付明细合计已达应付金额，只能由追加支付明细付清时流转，不能手工指定
+	if req.Status == string(constants.OrderStatusPaid) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "订单需通过追加支付明细付清，不能直接流转为 paid")
+	}
+
+	// 退款涉及资金回退，统一走退款流程
+	if req.Status == string(constants.OrderStatusRefunded) {
+		if err := s.refundOrder(ctx, req.OrderID, req.OperatorID, req.Reason); err != nil {
+			return nil, err
+		}
+		order, _, err := s.GetOrder(ctx, req.OrderID)
+		return order, err
+	}
+
+	var result sales.Order
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		order, err := s.getOrderForUpdate(ctx, req.OrderID)
+		if err != nil {
+			return err
+		}
+
+		if err := s.applyStatus(ctx, order, req.Status, req.OperatorID, req.Reason); err != nil {
+			return err
+		}
+
+		result = toSalesOrder(order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetOrderStatusHistory 获取订单状态流转记录
+func (s *SalesServiceImpl) GetOrderStatusHistory(ctx context.Context, orderID int64) ([]sales.OrderStatusLog, error) {
+	if _, err := s.q.Order.WithContext(ctx).Where(s.q.Order.ID.Eq(orderID)).First(); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+
+	var records []OrderStatusLogRecord
+	if err := s.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("created_at ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询订单状态流转记录失败: %w", err)
+	}
+
+	logs := make([]sales.OrderStatusLog, len(records))
+	for i, r := range records {
+		logs[i] = sales.OrderStatusLog{
+			ID:         r.ID,
+			OrderID:    r.OrderID,
+			FromStatus: r.FromStatus,
+			ToStatus:   r.ToStatus,
+			OperatorID: r.OperatorID,
+			Reason:     r.Reason,
+			CreatedAt:  r.CreatedAt,
+		}
+	}
+	return logs, nil
+}
+
+// getOrderForUpdate 在事务中加行锁读取订单，避免并发流转
+func (s *SalesServiceImpl) getOrderForUpdate(ctx context.Context, orderID int64) (*model.Order, error) {
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	order, err := txQuery.Order.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(txQuery.Order.ID.Eq(orderID)).
+		First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
+		}
+		return nil, fmt.Errorf("查询订单失败: %w", err)
+	}
+	return order, nil
+}
+
+// transitionStatus 在当前事务中执行状态流转并记录日志
+// 调用方需保证 order 已在事务中读取，成功后 order.Status 更新为目标状态
+func (s *SalesServiceImpl) transitionStatus(ctx context.Context, order *model.Order, to string, operatorID int64, reason string) error {
+	if !sales.CanTransition(order.Status, to) {
+		return common.NewBusinessErrorWithDetails(common.ErrCodeOrderStatusInvalid, "订单状态不允许流转",
+			fmt.Sprintf("%s -> %s", order.Status, to))
+	}
+
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	updates := map[string]interface{}{"status": to}
+
+	// 支付相关状态同步更新支付状态
+	var paymentStatus string
+	switch to {
+	case string(constants.OrderStatusPaid):
+		paymentStatus = string(constants.PaymentStatusPaid)
+	case string(constants.OrderStatusRefunded):
+		paymentStatus = string(constants.PaymentStatusRefunded)
+	}
+	if paymentStatus != "" {
+		updates["payment_status"] = paymentStatus
+		order.PaymentStatus = paymentStatus
+	}
+	if _, err := txQuery.Order.WithContext(ctx).Where(txQuery.Order.ID.Eq(order.ID)).Updates(updates); err != nil {
+		return fmt.Errorf("更新订单状态失败: %w", err)
+	}
+
+	from := order.Status
+	order.Status = to
+	return s.recordStatusLog(ctx, order.ID, from, to, operatorID, reason)
+}
+
+// applyStatus 在当前事务中完成状态流转的全部副作用：流转日志、取消退款与回补库存、退回优惠券、outbox 事件
+func (s *SalesServiceImpl) applyStatus(ctx context.Context, order *model.Order, to string, operatorID int64, reason string) error {
+	from := order.Status
+	if err := s.transitionStatus(ctx, order, to, operatorID, reason); err != nil {
+		return err
+	}
+	// 取消订单原路退回已付金额，回补库存，退回优惠券与套餐抵扣次数
+	if to == string(constants.OrderStatusCancelled) {
+		if err := s.refundCancelledOrder(ctx, order, operatorID, reason); err != nil {
+			return err
+		}
+		if err := s.releaseOrderStock(ctx, order.ID); err != nil {
+			return err
+		}
+		if err := s.reverseCoupon(ctx, order.ID); err != nil {
+			return err
+		}
+		if err := s.cancelPackages(ctx, order.ID); err != nil {
+			return err
+		}
+	}
+	// 付清时发放订单中购买的套餐
+	if to == string(constants.OrderStatusPaid) {
+		if err := s.grantPackages(ctx, order); err != nil {
+			return err
+		}
+	}
+	return s.publishStatusEvent(ctx, order, from, operatorID, reason)
+}
+
+// releaseOrderStock 回补订单全部明细的库存
+func (s *SalesServiceImpl) releaseOrderStock(ctx context.Context, orderID int64) error {
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	items, err := txQuery.OrderItem.WithContext(ctx).Where(txQuery.OrderItem.OrderID.Eq(orderID)).Find()
+	if err != nil {
+		return fmt.Errorf("查询订单项失败: %w", err)
+	}
+
+	stockItems := make([]catalog.StockItem, len(items))
+	for i, item := range items {
+		stockItems[i] = catalog.StockItem{ProductID: item.ProductID, Qty: item.Quantity}
+	}
+	return s.catalogSvc.ReleaseStock(ctx, stockItems)
+}
+
+// recordStatusLog 写入订单状态流转日志
+func (s *SalesServiceImpl) recordStatusLog(ctx context.Context, orderID int64, from, to string, operatorID int64, reason string) error {
+	record := &OrderStatusLogRecord{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		OperatorID: operatorID,
+		Reason:     reason,
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Create(record).Error; err != nil {
+		return fmt.Errorf("记录订单状态流转失败: %w", err)
+	}
+	return nil
+}
+
+// publishStatusEvent 按目标状态写入 order.* outbox 事件
+func (s *SalesServiceImpl) publishStatusEvent(ctx context.Context, order *model.Order, from string, operatorID int64, reason string) error {
+	now := time.Now().Unix()
+
+	var eventType string
+	var payload interface{}
+	if order.Status == string(constants.OrderStatusPaid) {
+		eventType = common.EventTypeOrderPaid
+		payload = common.OrderPaidEvent{
+			OrderID:    order.ID,
+			OrderNo:    order.OrderNo,
+			CustomerID: order.CustomerID,
+			PaidAmount: int64(order.FinalAmount * 100),
+			PayMethod:  order.PaymentMethod,
+			PaidAt:     now,
+		}
+	} else {
+		eventType = statusEventTypes[order.Status]
+		payload = common.OrderStatusChangedEvent{
+			OrderID:    order.ID,
+			OrderNo:    order.OrderNo,
+			CustomerID: order.CustomerID,
+			FromStatus: from,
+			ToStatus:   order.Status,
+			OperatorID: operatorID,
+			Reason:     reason,
+			ChangedAt:  now,
+		}
+	}
+	if eventType == "" {
+		return nil
+	}
+
+	if err := s.outboxSvc.PublishEvent(ctx, eventType, payload); err != nil {
+		return fmt.Errorf("写入订单事件失败: %w", err)
+	}
+	return nil
+}
+
+// toSalesOrder 数据模型转换为订单领域模型
+func toSalesOrder(order *model.Order) sales.Order {
+	return sales.Order{
+		ID:             order.ID,
+		OrderNo:        order.OrderNo,
+		CustomerID:     order.CustomerID,
+		ContactID:      order.ContactID,
+		TotalAmount:    int64(order.TotalAmount * 100),    // 转换为分
+		DiscountAmount: int64(order.DiscountAmount * 100), // 转换为分
+		FinalAmount:    int64(order.FinalAmount * 100),    // 转换为分
+		Status:         order.Status,
+		PaymentStatus:  order.PaymentStatus,
+		PayMethod:      order.PaymentMethod,
+		CreatedAt:      order.CreatedAt.Unix(),
+	}
+}
@@ -0,0 +1,71 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/constants"
+)
+
+// expiredOrderCancelReason 超时自动取消的流转原因
+const expiredOrderCancelReason = "支付超时自动取消"
+
+// CancelExpiredOrders 取消超时未支付订单
+// 每单独立事务，单个订单失败不影响同批次其他订单
+func (s *SalesServiceImpl) CancelExpiredOrders(ctx context.Context, before time.Time, limit int) (int, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	orders, err := s.q.Order.WithContext(ctx).
+		Where(
+			s.q.Order.Status.Eq(string(constants.OrderStatusPending)),
+			s.q.Order.PaymentStatus.Eq(string(constants.PaymentStatusUnpaid)),
+			s.q.Order.CreatedAt.Lt(before),
+		).
+		Order(s.q.Order.ID).
+		Limit(limit).
+		Find()
+	if err != nil {
+		return 0, fmt.Errorf("查询超时订单失败: %w", err)
+	}
+
+	var cancelled int
+	var errs []error
+	for _, o := range orders {
+		ok, err := s.cancelExpiredOrder(ctx, o.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("取消订单 %d 失败: %w", o.ID, err))
+			continue
+		}
+		if ok {
+			cancelled++
+		}
+	}
+
+	return cancelled, errors.Join(errs...)
+}
+
+// cancelExpiredOrder 加锁后复核订单仍为 pending 且未付款再取消，返回是否实际取消
+func (s *SalesServiceImpl) cancelExpiredOrder(ctx context.Context, orderID int64) (bool, error) {
+	var cancelled bool
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		order, err := s.getOrderForUpdate(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		// 扫描后订单可能已支付、部分支付或被人工处理
+		if order.Status != string(constants.OrderStatusPending) || order.PaymentStatus != string(constants.PaymentStatusUnpaid) {
+			return nil
+		}
+
+		if err := s.applyStatus(ctx, order, string(constants.OrderStatusCancelled), 0, expiredOrderCancelReason); err != nil {
+			return err
+		}
+		cancelled = true
+		return nil
+	})
+	return cancelled, err
+}
@@ -2,13 +2,19 @@ package impl
 
 import (
 	"os"
+	"sync"
 
 	"crm_lite/internal/common"
+	"crm_lite/internal/core/config"
 	"crm_lite/internal/core/resource"
 	"crm_lite/internal/dao/query"
 	billingImpl "crm_lite/internal/domains/billing/impl"
 	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	entitlementImpl "crm_lite/internal/domains/entitlement/impl"
+	marketingImpl "crm_lite/internal/domains/marketing/impl"
 	"crm_lite/internal/domains/sales"
+
+	"gorm.io/gorm"
 )
 
 // ProvideSales 创建sales服务实例
@@ -23,16 +29,46 @@ func ProvideSales(rm *resource.Manager) sales.Service {
 
 	// 优先使用新的sales服务实现
 	if os.Getenv("USE_LEGACY_ORDER") != "1" {
-		// 创建依赖服务
-		txManager := common.NewTx(dbRes.DB)
-		catalogService := catalogImpl.New(query.Use(dbRes.DB))
-		billingService := billingImpl.NewBillingService(dbRes.DB)
-		outboxService := common.NewOutboxService(dbRes.DB, txManager)
-
-		// 返回新的sales服务实现
-		return NewSalesServiceImpl(dbRes.DB, txManager, catalogService, billingService, outboxService)
+		return newSalesService(dbRes.DB)
 	}
 
 	// 如果指定使用旧的实现，返回旧的适配器
 	return New()
 }
+
+// NewOnlinePaymentServiceFromConfig 按配置创建在线支付服务，支付渠道由 newSalesService 按配置注册
+func NewOnlinePaymentServiceFromConfig(db *gorm.DB) sales.OnlinePaymentService {
+	return NewOnlinePaymentService(newSalesService(db), config.GetInstance().Payment.DefaultProvider)
+}
+
+// newSalesService 创建依赖完整的sales服务实现
+func newSalesService(db *gorm.DB) *SalesServiceImpl {
+	// 创建依赖服务
+	txManager := common.NewTx(db)
+	catalogService := catalogImpl.NewWithTx(query.Use(db), txManager)
+	billingService := billingImpl.NewBillingService(db)
+	outboxService := common.NewOutboxService(db, txManager)
+	couponService := marketingImpl.NewMarketingServiceImpl(db, txManager)
+	packageService := entitlementImpl.NewEntitlementServiceImpl(db, txManager, catalogService)
+
+	svc := NewSalesServiceImpl(db, txManager, catalogService, billingService, outboxService, couponService, packageService)
+	svc.registerPaymentProviders(configuredPaymentProviders()...)
+	return svc
+}
+
+var (
+	paymentProvidersOnce sync.Once
+	paymentProviders     []sales.PaymentProvider
+)
+
+// configuredPaymentProviders 按配置创建在线支付渠道，配置了 payment.mockSecret 时注册内置模拟渠道
+// 渠道在进程内只创建一次，订单服务与在线支付服务共用同一实例（模拟渠道的支付单保存在内存中）
+func configuredPaymentProviders() []sales.PaymentProvider {
+	paymentProvidersOnce.Do(func() {
+		opts := config.GetInstance().Payment
+		if opts.MockSecret != "" {
+			paymentProviders = append(paymentProviders, NewMockPaymentProvider(opts.MockSecret))
+		}
+	})
+	return paymentProviders
+}
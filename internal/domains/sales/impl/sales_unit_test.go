@@ -2,13 +2,19 @@ package impl
 
 import (
 	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"crm_lite/internal/common"
 	"crm_lite/internal/dao/model"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/billing"
 	"crm_lite/internal/domains/catalog"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/entitlement"
+	entitlementImpl "crm_lite/internal/domains/entitlement/impl"
+	marketingImpl "crm_lite/internal/domains/marketing/impl"
 	"crm_lite/internal/domains/sales"
 
 	"gorm.io/driver/sqlite"
@@ -21,6 +27,7 @@ import (
 // mockCatalogService 模拟产品服务
 type mockCatalogService struct {
 	products map[int64]catalog.Product
+	stock    map[int64]int32 // 为空时不校验库存
 }
 
 func (m *mockCatalogService) BatchGet(ctx context.Context, productIDs []int64) ([]catalog.Product, error) {
@@ -68,9 +75,42 @@ func (m *mockCatalogService) Get(ctx context.Context, productID int64) (catalog.
 	return catalog.Product{}, common.NewBusinessError(common.ErrCodeProductNotFound, "产品不存在")
 }
 
+func (m *mockCatalogService) ReserveStock(ctx context.Context, items []catalog.StockItem) error {
+	if m.stock == nil {
+		return nil
+	}
+	for _, it := range items {
+		if m.products[it.ProductID].Type == "service" {
+			continue
+		}
+		if m.stock[it.ProductID] < it.Qty {
+			return common.NewBusinessError(common.ErrCodeInsufficientStock, "库存不足")
+		}
+	}
+	for _, it := range items {
+		if m.products[it.ProductID].Type != "service" {
+			m.stock[it.ProductID] -= it.Qty
+		}
+	}
+	return nil
+}
+
+func (m *mockCatalogService) ReleaseStock(ctx context.Context, items []catalog.StockItem) error {
+	if m.stock == nil {
+		return nil
+	}
+	for _, it := range items {
+		if m.products[it.ProductID].Type != "service" {
+			m.stock[it.ProductID] += it.Qty
+		}
+	}
+	return nil
+}
+
 // mockBillingService 模拟钱包服务
 type mockBillingService struct {
 	balances map[int64]int64
+	txSeq    int64
 }
 
 func (m *mockBillingService) DebitForOrder(ctx context.Context, customerID, orderID int64, amount int64, idem string) error {
@@ -132,6 +172,23 @@ func (m *mockBillingService) GetTransactions(ctx context.Context, customerID int
 	return []billing.Transaction{}, 0, nil
 }
 
+func (m *mockBillingService) GetTransactionByIdemKey(ctx context.Context, idem string) (*billing.Transaction, error) {
+	m.txSeq++
+	return &billing.Transaction{ID: m.txSeq, IdempotencyKey: idem}, nil
+}
+
+func (m *mockBillingService) FreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return &billing.WalletInfo{ID: customerID, CustomerID: customerID, Balance: m.balances[customerID], Status: 0}, nil
+}
+
+func (m *mockBillingService) UnfreezeWallet(ctx context.Context, customerID, operatorID int64, reason string) (*billing.WalletInfo, error) {
+	return &billing.WalletInfo{ID: customerID, CustomerID: customerID, Balance: m.balances[customerID], Status: 1}, nil
+}
+
+func (m *mockBillingService) ListWalletStatusLogs(ctx context.Context, customerID int64) ([]billing.WalletStatusLog, error) {
+	return []billing.WalletStatusLog{}, nil
+}
+
 // mockOutboxService 模拟事件服务
 type mockOutboxService struct {
 	events []string
@@ -150,9 +207,8 @@ func (m *mockOutboxService) MarkEventProcessed(ctx context.Context, eventID int6
 	return nil
 }
 
-// TestSalesServiceCore PR-3 Sales域核心功能单元测试
-// 通过 Mock 验证订单下单和退款的核心业务逻辑
-func TestSalesServiceCore(t *testing.T) {
+// newSalesTestDB 创建内存数据库并初始化 Sales 域用到的表结构
+func newSalesTestDB(t *testing.T) *gorm.DB {
 	// 创建内存数据库
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
@@ -219,10 +275,112 @@ func TestSalesServiceCore(t *testing.T) {
 	`).Error
 	require.NoError(t, err)
 
+	err = db.Exec(`
+		CREATE TABLE order_status_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			from_status TEXT NOT NULL DEFAULT '',
+			to_status TEXT NOT NULL,
+			operator_id INTEGER NOT NULL DEFAULT 0,
+			reason TEXT,
+			created_at INTEGER NOT NULL
+		)
+	`).Error
+	require.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE order_refunds (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			amount INTEGER NOT NULL,
+			wallet_tx_id INTEGER NOT NULL DEFAULT 0,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			reason TEXT,
+			operator_id INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		)
+	`).Error
+	require.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE order_refund_items (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			refund_id INTEGER NOT NULL,
+			order_id INTEGER NOT NULL,
+			order_item_id INTEGER NOT NULL,
+			product_id INTEGER NOT NULL,
+			quantity INTEGER NOT NULL,
+			amount INTEGER NOT NULL,
+			created_at INTEGER NOT NULL
+		)
+	`).Error
+	require.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE order_refund_payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			refund_id INTEGER NOT NULL,
+			order_id INTEGER NOT NULL,
+			order_payment_id INTEGER NOT NULL DEFAULT 0,
+			method TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			wallet_tx_id INTEGER NOT NULL DEFAULT 0,
+			provider TEXT NOT NULL DEFAULT '',
+			provider_refund_no TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL
+		)
+	`).Error
+	require.NoError(t, err)
+
+	err = db.Exec(`
+		CREATE TABLE order_payments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			order_id INTEGER NOT NULL,
+			method TEXT NOT NULL,
+			amount INTEGER NOT NULL,
+			wallet_tx_id INTEGER NOT NULL DEFAULT 0,
+			idempotency_key TEXT NOT NULL UNIQUE,
+			operator_id INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL
+		)
+	`).Error
+	require.NoError(t, err)
+
+	return db
+}
+
+// createProductsTable 创建产品表，供使用真实 catalog 实现的用例使用
+func createProductsTable(t *testing.T, db *gorm.DB) {
+	require.NoError(t, db.Exec(`
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT,
+			type TEXT DEFAULT 'product',
+			category TEXT,
+			price DECIMAL(10,2) NOT NULL DEFAULT 0,
+			cost DECIMAL(10,2) DEFAULT 0,
+			stock_quantity INTEGER DEFAULT 0,
+			min_stock_level INTEGER DEFAULT 0,
+			unit TEXT DEFAULT '个',
+			duration_min INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)
+	`).Error)
+}
+
+// TestSalesServiceCore PR-3 Sales域核心功能单元测试
+// 通过 Mock 验证订单下单和退款的核心业务逻辑
+func TestSalesServiceCore(t *testing.T) {
+	db := newSalesTestDB(t)
+
 	// 创建测试数据
 	q := query.Use(db)
 	customer := &model.Customer{Name: "测试客户"}
-	err = q.Customer.WithContext(context.Background()).Create(customer)
+	err := q.Customer.WithContext(context.Background()).Create(customer)
 	require.NoError(t, err)
 
 	// 创建 Mock 服务
@@ -255,7 +413,7 @@ func TestSalesServiceCore(t *testing.T) {
 
 	// 创建Sales服务
 	tx := common.NewTx(db)
-	salesSvc := NewSalesServiceImpl(db, tx, mockCatalog, mockBilling, mockOutbox)
+	salesSvc := NewSalesServiceImpl(db, tx, mockCatalog, mockBilling, mockOutbox, nil, nil)
 
 	ctx := context.Background()
 
@@ -413,3 +571,1173 @@ func TestSalesServiceCore(t *testing.T) {
 	t.Log("  - ✅ 业务边界保护：余额不足、产品不存在等异常处理")
 	t.Log("  - ✅ 事件驱动架构：完整的outbox事件流程")
 }
+
+// TestOrderStatusTransition 订单状态机单元测试
+// 验证合法/非法流转、流转日志记录以及 order.* 事件发布
+func TestOrderStatusTransition(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "状态机客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			2001: {ID: 2001, Name: "洗护套餐", Price: 10000},
+		},
+	}
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 0}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), mockCatalog, mockBilling, mockOutbox, nil, nil)
+
+	placeCashOrder := func(t *testing.T) sales.Order {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: 2001, Qty: 1}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "pending", order.Status)
+		return order
+	}
+
+	t.Run("正常流转并退款", func(t *testing.T) {
+		order := placeCashOrder(t)
+
+		updated, err := salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "confirmed", OperatorID: 7, Reason: "客户确认"})
+		require.NoError(t, err)
+		assert.Equal(t, "confirmed", updated.Status)
+		assert.Contains(t, mockOutbox.events, common.EventTypeOrderConfirmed)
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "paid", OperatorID: 7, Reason: "现金收款"})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr, "paid 不能手工指定")
+		assert.Equal(t, common.ErrCodeInvalidParam, businessErr.Code)
+
+		_, err = salesSvc.AddOrderPayments(ctx, sales.AddOrderPaymentReq{
+			OrderID:    order.ID,
+			Payments:   []sales.PaymentLineReq{{Method: "cash", Amount: 10000}},
+			OperatorID: 7,
+		})
+		require.NoError(t, err)
+		updated, _, err = salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "paid", updated.Status)
+		assert.Equal(t, "paid", updated.PaymentStatus, "付清时支付状态同步更新")
+		assert.Contains(t, mockOutbox.events, common.EventTypeOrderPaid)
+
+		updated, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "refunded", OperatorID: 7, Reason: "客户退款"})
+		require.NoError(t, err)
+		assert.Equal(t, "refunded", updated.Status)
+		assert.Zero(t, mockBilling.balances[customer.ID], "现金支付退回现金，不入账钱包")
+		assert.Contains(t, mockOutbox.events, common.EventTypeOrderRefunded)
+		refunds, err := salesSvc.ListOrderRefunds(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1)
+		assert.Zero(t, refunds[0].WalletTxID)
+		require.Len(t, refunds[0].Payments, 1)
+		assert.Equal(t, "cash", refunds[0].Payments[0].Method)
+		assert.Equal(t, int64(10000), refunds[0].Payments[0].Amount)
+
+		logs, err := salesSvc.GetOrderStatusHistory(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, logs, 4, "下单+3次流转")
+		assert.Equal(t, "", logs[0].FromStatus)
+		assert.Equal(t, "pending", logs[0].ToStatus)
+		assert.Equal(t, "confirmed", logs[1].ToStatus)
+		assert.Equal(t, int64(7), logs[1].OperatorID)
+		assert.Equal(t, "客户确认", logs[1].Reason)
+		assert.Equal(t, "paid", logs[2].ToStatus)
+		assert.Equal(t, "paid", logs[3].FromStatus)
+		assert.Equal(t, "refunded", logs[3].ToStatus)
+
+		t.Log("✅ 正常流转并退款验证通过")
+	})
+
+	t.Run("非法流转被拒绝", func(t *testing.T) {
+		order := placeCashOrder(t)
+
+		_, err := salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "shipped"})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeOrderStatusInvalid, businessErr.Code)
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", Reason: "客户取消"})
+		require.NoError(t, err)
+		assert.Contains(t, mockOutbox.events, common.EventTypeOrderCancelled)
+
+		// 终态不允许再流转
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "confirmed"})
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeOrderStatusInvalid, businessErr.Code)
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: 999999, Status: "confirmed"})
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeOrderNotFound, businessErr.Code)
+
+		t.Log("✅ 非法流转拒绝验证通过")
+	})
+}
+
+// TestOrderPartialRefund 订单项部分退款单元测试
+// 验证按行按数量退款、折扣分摊、幂等重放以及剩余金额全额退款
+func TestOrderPartialRefund(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "部分退款客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			3001: {ID: 3001, Name: "精油按摩", Price: 15000},
+			3002: {ID: 3002, Name: "足疗", Price: 8000},
+		},
+	}
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 100000}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), mockCatalog, mockBilling, mockOutbox, nil, nil)
+
+	order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+		CustomerID: customer.ID,
+		PayMethod:  "wallet",
+		Items: []sales.OrderItemReq{
+			{ProductID: 3001, Qty: 1},
+			{ProductID: 3002, Qty: 2},
+		},
+		Discount: 5000, // 总额310元，实付260元
+		IdemKey:  "partial_refund_order",
+	})
+	require.NoError(t, err)
+	_, items, err := salesSvc.GetOrder(ctx, order.ID)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	footItem := items[1]
+
+	t.Run("部分退款按比例分摊折扣", func(t *testing.T) {
+		balanceBefore := mockBilling.balances[customer.ID]
+
+		refund, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: footItem.ID, Qty: 1}},
+			Reason:  "少做一次足疗",
+			IdemKey: "refund-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(6709), refund.Amount, "8000*26000/31000 向下取整")
+		assert.NotZero(t, refund.WalletTxID, "退款单关联钱包流水")
+		require.Len(t, refund.Lines, 1)
+		assert.Equal(t, int32(1), refund.Lines[0].Quantity)
+		assert.Equal(t, balanceBefore+6709, mockBilling.balances[customer.ID])
+
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "paid", current.Status, "部分退款不改变订单状态")
+		assert.Equal(t, "partially_refunded", current.PaymentStatus)
+		assert.Contains(t, mockOutbox.events, common.EventTypeOrderRefunded)
+
+		// 相同幂等键重放返回同一退款单，不重复退款
+		replay, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: footItem.ID, Qty: 1}},
+			IdemKey: "refund-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, refund.ID, replay.ID)
+		assert.Equal(t, balanceBefore+6709, mockBilling.balances[customer.ID])
+
+		t.Log("✅ 部分退款验证通过")
+	})
+
+	t.Run("超出可退数量被拒绝", func(t *testing.T) {
+		_, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: footItem.ID, Qty: 2}},
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeOrderCannotRefund, businessErr.Code)
+
+		t.Log("✅ 超量退款拒绝验证通过")
+	})
+
+	t.Run("剩余部分全额退款", func(t *testing.T) {
+		balanceBefore := mockBilling.balances[customer.ID]
+
+		require.NoError(t, salesSvc.RefundOrder(ctx, order.ID, "整单退款"))
+		assert.Equal(t, balanceBefore+26000-6709, mockBilling.balances[customer.ID], "退回剩余实付金额")
+
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "refunded", current.Status)
+		assert.Equal(t, "refunded", current.PaymentStatus)
+
+		refunds, err := salesSvc.ListOrderRefunds(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 2)
+		assert.Len(t, refunds[1].Lines, 2, "剩余1次精油按摩+1次足疗")
+		assert.Equal(t, int64(26000), refunds[0].Amount+refunds[1].Amount, "累计退款等于实付金额")
+
+		t.Log("✅ 剩余全额退款验证通过")
+	})
+}
+
+// TestOrderStockReservation 下单库存扣减单元测试
+// 使用真实产品服务验证：库存不足拒单、服务类产品不占库存、取消/退款回补库存
+func TestOrderStockReservation(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	createProductsTable(t, db)
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "库存客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	goods := &model.Product{Name: "洗发水", Type: "product", Price: 50, StockQuantity: 3, IsActive: true}
+	service := &model.Product{Name: "理发", Type: "service", Price: 80, StockQuantity: 0, IsActive: true}
+	require.NoError(t, q.Product.WithContext(ctx).Create(goods, service))
+
+	tx := common.NewTx(db)
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 0}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, tx, catalogImpl.NewWithTx(q, tx), mockBilling, mockOutbox, nil, nil)
+
+	stockOf := func(t *testing.T, id int64) int32 {
+		p, err := q.Product.WithContext(ctx).Where(q.Product.ID.Eq(id)).First()
+		require.NoError(t, err)
+		return p.StockQuantity
+	}
+
+	t.Run("下单扣减库存，服务类产品不占库存", func(t *testing.T) {
+		_, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items: []sales.OrderItemReq{
+				{ProductID: goods.ID, Qty: 2},
+				{ProductID: service.ID, Qty: 5},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), stockOf(t, goods.ID))
+		assert.Equal(t, int32(0), stockOf(t, service.ID))
+	})
+
+	t.Run("库存不足拒单且不落库", func(t *testing.T) {
+		countBefore, err := q.Order.WithContext(ctx).Count()
+		require.NoError(t, err)
+
+		_, err = salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: goods.ID, Qty: 2}},
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeInsufficientStock, businessErr.Code)
+		assert.Equal(t, int32(1), stockOf(t, goods.ID))
+
+		countAfter, err := q.Order.WithContext(ctx).Count()
+		require.NoError(t, err)
+		assert.Equal(t, countBefore, countAfter, "事务回滚，订单未创建")
+	})
+
+	t.Run("取消订单回补库存", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: goods.ID, Qty: 1}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(0), stockOf(t, goods.ID))
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", Reason: "客户取消"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), stockOf(t, goods.ID))
+	})
+
+	t.Run("退款回补库存", func(t *testing.T) {
+		_, err := q.Product.WithContext(ctx).Where(q.Product.ID.Eq(goods.ID)).UpdateSimple(q.Product.StockQuantity.Value(5))
+		require.NoError(t, err)
+		mockBilling.balances[customer.ID] = 100000
+
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "wallet",
+			Items:      []sales.OrderItemReq{{ProductID: goods.ID, Qty: 3}},
+			IdemKey:    "stock_refund_order",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), stockOf(t, goods.ID))
+
+		_, items, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		_, err = salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: items[0].ID, Qty: 1}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), stockOf(t, goods.ID))
+
+		require.NoError(t, salesSvc.RefundOrder(ctx, order.ID, "全部退款"))
+		assert.Equal(t, int32(5), stockOf(t, goods.ID))
+	})
+}
+
+// TestCancelExpiredOrders 超时未支付订单自动取消单元测试
+// 验证只取消超时的 pending 订单，回补库存并发布 order.cancelled 事件
+func TestCancelExpiredOrders(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "超时客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			4001: {ID: 4001, Name: "护发素", Price: 3000, Type: "product"},
+		},
+		stock: map[int64]int32{4001: 10},
+	}
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 100000}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), mockCatalog, mockBilling, mockOutbox, nil, nil)
+
+	place := func(payMethod string) sales.Order {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  payMethod,
+			Items:      []sales.OrderItemReq{{ProductID: 4001, Qty: 2}},
+			IdemKey:    payMethod,
+		})
+		require.NoError(t, err)
+		return order
+	}
+
+	expired := place("cash")
+	paid := place("wallet")
+	_, err := q.Order.WithContext(ctx).Where(q.Order.ID.In(expired.ID, paid.ID)).
+		UpdateSimple(q.Order.CreatedAt.Value(time.Now().Add(-time.Hour)))
+	require.NoError(t, err)
+	fresh := place("cash")
+	assert.Equal(t, int32(4), mockCatalog.stock[4001])
+
+	cancelled, err := salesSvc.CancelExpiredOrders(ctx, time.Now().Add(-30*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cancelled, "只取消超时的 pending 订单")
+	assert.Equal(t, int32(6), mockCatalog.stock[4001], "取消后回补库存")
+	assert.Contains(t, mockOutbox.events, common.EventTypeOrderCancelled)
+
+	for id, want := range map[int64]string{expired.ID: "cancelled", paid.ID: "paid", fresh.ID: "pending"} {
+		order, _, err := salesSvc.GetOrder(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, order.Status)
+	}
+
+	logs, err := salesSvc.GetOrderStatusHistory(ctx, expired.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "支付超时自动取消", logs[len(logs)-1].Reason)
+
+	// 重复执行不会重复取消
+	cancelled, err = salesSvc.CancelExpiredOrders(ctx, time.Now().Add(-30*time.Minute), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cancelled)
+}
+
+// TestOrderSplitTenderPayment 组合支付单元测试
+// 验证钱包 + 现金组合支付、分次付款的支付状态流转、超额支付拒绝及幂等重放
+func TestOrderSplitTenderPayment(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "组合支付客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			5001: {ID: 5001, Name: "烫发", Price: 30000},
+		},
+	}
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 20000}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), mockCatalog, mockBilling, mockOutbox, nil, nil)
+
+	t.Run("下单时钱包加现金付清", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 5001, Qty: 1}},
+			Payments: []sales.PaymentLineReq{
+				{Method: "wallet", Amount: 20000},
+				{Method: "cash", Amount: 10000},
+			},
+			IdemKey: "split_full",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "paid", order.Status)
+		assert.Equal(t, "paid", order.PaymentStatus)
+		assert.Equal(t, "mixed", order.PayMethod)
+		assert.Equal(t, int64(0), mockBilling.balances[customer.ID], "仅扣除钱包支付部分")
+
+		payments, err := salesSvc.ListOrderPayments(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, payments, 2)
+		assert.NotZero(t, payments[0].WalletTxID, "钱包支付行关联扣款流水")
+		assert.Zero(t, payments[1].WalletTxID)
+	})
+
+	t.Run("分次付款直至付清", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 5001, Qty: 1}},
+			Payments:   []sales.PaymentLineReq{{Method: "cash", Amount: 10000}},
+			IdemKey:    "split_partial",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "pending", order.Status)
+		assert.Equal(t, "partially_paid", order.PaymentStatus)
+
+		// 超额支付被拒绝
+		_, err = salesSvc.AddOrderPayments(ctx, sales.AddOrderPaymentReq{
+			OrderID:  order.ID,
+			Payments: []sales.PaymentLineReq{{Method: "card", Amount: 20001}},
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeOrderOverpaid, businessErr.Code)
+
+		eventsBefore := len(mockOutbox.events)
+		added, err := salesSvc.AddOrderPayments(ctx, sales.AddOrderPaymentReq{
+			OrderID:    order.ID,
+			Payments:   []sales.PaymentLineReq{{Method: "card", Amount: 20000}},
+			OperatorID: 7,
+			IdemKey:    "pay-1",
+		})
+		require.NoError(t, err)
+		require.Len(t, added, 1)
+		assert.Equal(t, int64(7), added[0].OperatorID)
+		assert.Contains(t, mockOutbox.events[eventsBefore:], common.EventTypeOrderPaid)
+
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "paid", current.Status)
+		assert.Equal(t, "paid", current.PaymentStatus)
+
+		// 相同幂等键重放返回同一组支付明细
+		replay, err := salesSvc.AddOrderPayments(ctx, sales.AddOrderPaymentReq{
+			OrderID:  order.ID,
+			Payments: []sales.PaymentLineReq{{Method: "card", Amount: 20000}},
+			IdemKey:  "pay-1",
+		})
+		require.NoError(t, err)
+		require.Len(t, replay, 1)
+		assert.Equal(t, added[0].ID, replay[0].ID)
+
+		payments, err := salesSvc.ListOrderPayments(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Len(t, payments, 2)
+	})
+
+	t.Run("超长幂等键派生的钱包幂等键不超过列宽", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 5001, Qty: 1}},
+			Payments:   []sales.PaymentLineReq{{Method: "cash", Amount: 30000}},
+			IdemKey:    strings.Repeat("k", 128),
+		})
+		require.NoError(t, err)
+
+		var keys []string
+		require.NoError(t, db.Model(&OrderPaymentRecord{}).Where("order_id = ?", order.ID).Pluck("idempotency_key", &keys).Error)
+		require.Len(t, keys, 1)
+		assert.LessOrEqual(t, len(keys[0]), 64, "钱包流水幂等键列为 VARCHAR(64)")
+	})
+
+	t.Run("取消部分付款的订单原路退回已付金额", func(t *testing.T) {
+		mockBilling.balances[customer.ID] = 5000
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 5001, Qty: 1}},
+			Payments: []sales.PaymentLineReq{
+				{Method: "wallet", Amount: 5000},
+				{Method: "cash", Amount: 3000},
+			},
+			IdemKey: "split_cancel",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "partially_paid", order.PaymentStatus)
+		assert.Equal(t, int64(0), mockBilling.balances[customer.ID])
+
+		eventsBefore := len(mockOutbox.events)
+		cancelled, err := salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", OperatorID: 7, Reason: "客户取消"})
+		require.NoError(t, err)
+		assert.Equal(t, "cancelled", cancelled.Status)
+		assert.Equal(t, "refunded", cancelled.PaymentStatus)
+		assert.Equal(t, int64(5000), mockBilling.balances[customer.ID], "只有钱包支付部分退回钱包，现金部分退回现金")
+		assert.NotContains(t, mockOutbox.events[eventsBefore:], common.EventTypeOrderRefunded)
+
+		refunds, err := salesSvc.ListOrderRefunds(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 1)
+		assert.Equal(t, int64(8000), refunds[0].Amount)
+		assert.NotZero(t, refunds[0].WalletTxID)
+		assert.Empty(t, refunds[0].Lines)
+		require.Len(t, refunds[0].Payments, 2)
+		assert.Equal(t, "wallet", refunds[0].Payments[0].Method)
+		assert.Equal(t, int64(5000), refunds[0].Payments[0].Amount)
+		assert.Equal(t, "cash", refunds[0].Payments[1].Method)
+		assert.Equal(t, int64(3000), refunds[0].Payments[1].Amount)
+		assert.Equal(t, refunds[0].WalletTxID, refunds[0].Payments[0].WalletTxID)
+		assert.Zero(t, refunds[0].Payments[1].WalletTxID)
+	})
+
+	t.Run("钱包余额不足整单回滚", func(t *testing.T) {
+		countBefore, err := q.Order.WithContext(ctx).Count()
+		require.NoError(t, err)
+
+		_, err = salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 5001, Qty: 1}},
+			Payments: []sales.PaymentLineReq{
+				{Method: "cash", Amount: 10000},
+				{Method: "wallet", Amount: 20000},
+			},
+			IdemKey: "split_insufficient",
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeInsufficientBalance, businessErr.Code)
+
+		count, err := q.Order.WithContext(ctx).Count()
+		require.NoError(t, err)
+		assert.Equal(t, countBefore, count, "扣款失败不落单")
+	})
+}
+
+// TestOrderCoupon 下单核销优惠券单元测试
+// 使用真实营销服务验证：优惠计入订单折扣、活动归因、取消和全额退款退回券码
+func TestOrderCoupon(t *testing.T) {
+	db := newSalesTestDB(t)
+	require.NoError(t, db.AutoMigrate(&marketingImpl.CouponRecord{}, &marketingImpl.CouponCodeRecord{}, &marketingImpl.CouponRedemptionRecord{}))
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "优惠券客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	tx := common.NewTx(db)
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			6001: {ID: 6001, Name: "染发", Price: 20000, Category: "美发"},
+		},
+	}
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 100000}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	couponSvc := marketingImpl.NewMarketingServiceImpl(db, tx)
+	salesSvc := NewSalesServiceImpl(db, tx, mockCatalog, mockBilling, mockOutbox, couponSvc, nil)
+
+	coupon := &marketingImpl.CouponRecord{
+		CampaignID: 9,
+		Name:       "立减30",
+		Type:       "fixed",
+		Value:      3000,
+		MinSpend:   10000,
+		ValidFrom:  time.Now().Add(-time.Hour).Unix(),
+		ValidTo:    time.Now().Add(time.Hour).Unix(),
+		Status:     "active",
+		CreatedAt:  time.Now().Unix(),
+		UpdatedAt:  time.Now().Unix(),
+	}
+	require.NoError(t, db.Create(coupon).Error)
+	codes, err := couponSvc.GenerateCouponCodes(ctx, coupon.ID, 2, "")
+	require.NoError(t, err)
+
+	redemptionStatus := func(orderID int64) string {
+		var r marketingImpl.CouponRedemptionRecord
+		require.NoError(t, db.Where("order_id = ?", orderID).First(&r).Error)
+		assert.Equal(t, int64(9), r.CampaignID, "核销记录关联发放活动")
+		return r.Status
+	}
+
+	t.Run("优惠计入折扣并在退款后退回", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "wallet",
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 1}},
+			CouponCode: codes[0],
+			IdemKey:    "coupon_order",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3000), order.DiscountAmount)
+		assert.Equal(t, int64(17000), order.FinalAmount)
+		assert.Equal(t, "paid", order.Status)
+		assert.Equal(t, int64(83000), mockBilling.balances[customer.ID], "按优惠后金额扣款")
+
+		stored, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3000), stored.DiscountAmount)
+		assert.Equal(t, int64(17000), stored.FinalAmount)
+		assert.Equal(t, "redeemed", redemptionStatus(order.ID))
+
+		// 同一券码不能再次使用
+		_, err = salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 1}},
+			CouponCode: codes[0],
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeCouponInvalid, businessErr.Code)
+
+		require.NoError(t, salesSvc.RefundOrder(ctx, order.ID, "全额退款"))
+		assert.Equal(t, int64(100000), mockBilling.balances[customer.ID], "退回实付金额")
+		assert.Equal(t, "reversed", redemptionStatus(order.ID))
+	})
+
+	t.Run("取消订单退回券码", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 1}},
+			CouponCode: codes[1],
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "pending", order.Status)
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", Reason: "客户取消"})
+		require.NoError(t, err)
+		assert.Equal(t, "reversed", redemptionStatus(order.ID))
+
+		// 退回的券码可以再次使用
+		_, err = salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 1}},
+			CouponCode: codes[1],
+		})
+		require.NoError(t, err)
+	})
+}
+
+// TestListOrdersFilters 订单列表筛选、排序与游标翻页单元测试
+func TestListOrdersFilters(t *testing.T) {
+	db := newSalesTestDB(t)
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "列表客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	seed := []*model.Order{
+		{OrderNo: "A100", OrderDate: base, FinalAmount: 50, AssignedTo: 7, PaymentStatus: "paid", PaymentMethod: "wallet"},
+		{OrderNo: "A101", OrderDate: base, FinalAmount: 120, AssignedTo: 7, PaymentStatus: "unpaid", PaymentMethod: "cash"},
+		{OrderNo: "A102", OrderDate: base.AddDate(0, 0, 1), FinalAmount: 80, AssignedTo: 8, PaymentStatus: "paid", PaymentMethod: "mixed"},
+		{OrderNo: "B200", OrderDate: base.AddDate(0, 0, 2), FinalAmount: 300, AssignedTo: 7, PaymentStatus: "partially_paid", PaymentMethod: "mixed"},
+		{OrderNo: "B201", OrderDate: base.AddDate(0, 0, 3), FinalAmount: 120, AssignedTo: 8, PaymentStatus: "paid", PaymentMethod: "wallet"},
+	}
+	for _, o := range seed {
+		o.CustomerID = customer.ID
+		o.TotalAmount = o.FinalAmount
+		o.Status = "pending"
+		require.NoError(t, q.Order.WithContext(ctx).Create(o))
+	}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), &mockCatalogService{}, &mockBillingService{}, &mockOutboxService{}, nil, nil)
+
+	orderNos := func(resp *sales.ListOrdersResponse) []string {
+		nos := make([]string, len(resp.Orders))
+		for i, o := range resp.Orders {
+			nos[i] = o.OrderNo
+		}
+		return nos
+	}
+
+	t.Run("组合筛选并统计总数", func(t *testing.T) {
+		resp, err := salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{
+			AssignedTo:    7,
+			OrderDateFrom: base,
+			OrderDateTo:   base.AddDate(0, 0, 3),
+			MinAmount:     10000,
+			Page:          1,
+			PageSize:      10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), resp.Total)
+		assert.Equal(t, []string{"B200", "A101"}, orderNos(resp))
+		assert.Empty(t, resp.NextCursor)
+
+		resp, err = salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{
+			PaymentStatus: "paid",
+			PaymentMethod: "wallet",
+			OrderNoPrefix: "B",
+			Page:          1,
+			PageSize:      10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"B201"}, orderNos(resp))
+	})
+
+	t.Run("按金额升序，金额相同按ID", func(t *testing.T) {
+		resp, err := salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{
+			OrderBy:  "final_amount_asc",
+			Page:     1,
+			PageSize: 10,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), resp.Total)
+		assert.Equal(t, []string{"A100", "A102", "A101", "B201", "B200"}, orderNos(resp))
+	})
+
+	t.Run("游标翻页遍历全部订单", func(t *testing.T) {
+		for _, orderBy := range []string{"order_date_desc", "final_amount_asc", "id_desc"} {
+			var all []string
+			req := &sales.ListOrdersRequest{OrderBy: orderBy, Page: 1, PageSize: 2}
+			for i := 0; i < 5; i++ {
+				resp, err := salesSvc.ListOrdersForController(ctx, req)
+				require.NoError(t, err)
+				if req.Cursor != "" {
+					assert.Equal(t, int64(-1), resp.Total, "游标模式不统计总数")
+				}
+				all = append(all, orderNos(resp)...)
+				if resp.NextCursor == "" {
+					break
+				}
+				req.Cursor = resp.NextCursor
+			}
+			assert.Len(t, all, 5, orderBy)
+			assert.ElementsMatch(t, []string{"A100", "A101", "A102", "B200", "B201"}, all, orderBy)
+		}
+	})
+
+	t.Run("非法排序或游标被拒绝", func(t *testing.T) {
+		_, err := salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{OrderBy: "customer_id_desc"})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodeInvalidParam, businessErr.Code)
+
+		resp, err := salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{OrderBy: "id_desc", PageSize: 2})
+		require.NoError(t, err)
+		_, err = salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{OrderBy: "order_date_desc", Cursor: resp.NextCursor})
+		require.ErrorAs(t, err, &businessErr)
+
+		_, err = salesSvc.ListOrdersForController(ctx, &sales.ListOrdersRequest{Cursor: "not-a-cursor"})
+		require.ErrorAs(t, err, &businessErr)
+	})
+}
+
+// TestOrderServicePackage 次卡套餐单元测试
+// 验证套餐付清后发放、下单抵扣不扣钱包、取消/退款冲回次数以及已使用套餐不可退款
+func TestOrderServicePackage(t *testing.T) {
+	db := newSalesTestDB(t)
+	createProductsTable(t, db)
+	require.NoError(t, db.AutoMigrate(&entitlementImpl.PackagePlanRecord{}, &entitlementImpl.CustomerPackageRecord{}, &entitlementImpl.CustomerPackageTxRecord{}))
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "次卡客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	haircut := &model.Product{Name: "精剪", Type: "service", Price: 80, IsActive: true}
+	card := &model.Product{Name: "精剪10次卡", Type: "package", Price: 600, IsActive: true}
+	require.NoError(t, q.Product.WithContext(ctx).Create(haircut, card))
+
+	tx := common.NewTx(db)
+	catalogSvc := catalogImpl.NewWithTx(q, tx)
+	packageSvc := entitlementImpl.NewEntitlementServiceImpl(db, tx, catalogSvc)
+	mockBilling := &mockBillingService{balances: map[int64]int64{customer.ID: 100000}}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, tx, catalogSvc, mockBilling, mockOutbox, nil, packageSvc)
+
+	remaining := func(t *testing.T) int32 {
+		packages, err := packageSvc.ListCustomerPackages(ctx, customer.ID, false)
+		require.NoError(t, err)
+		require.Len(t, packages, 1)
+		return packages[0].RemainingUses
+	}
+
+	_, err := packageSvc.SetPackagePlan(ctx, entitlement.SetPackagePlanRequest{ProductID: haircut.ID, ServiceProductID: haircut.ID, TotalUses: 10})
+	var businessErr *common.BusinessError
+	require.ErrorAs(t, err, &businessErr)
+	assert.Equal(t, common.ErrCodeInvalidParam, businessErr.Code, "只有 package 类型产品可以配置套餐")
+
+	_, err = packageSvc.SetPackagePlan(ctx, entitlement.SetPackagePlanRequest{ProductID: card.ID, ServiceProductID: haircut.ID, TotalUses: 10, ValidDays: 30})
+	require.NoError(t, err)
+
+	t.Run("未购买套餐时抵扣失败", func(t *testing.T) {
+		_, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "wallet",
+			Items:      []sales.OrderItemReq{{ProductID: haircut.ID, Qty: 1, UsePackage: true}},
+		})
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodePackageInsufficient, businessErr.Code)
+	})
+
+	var purchase sales.Order
+	t.Run("现金购买套餐，付清后发放", func(t *testing.T) {
+		purchase, err = salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items:      []sales.OrderItemReq{{ProductID: card.ID, Qty: 1}},
+		})
+		require.NoError(t, err)
+		packages, err := packageSvc.ListCustomerPackages(ctx, customer.ID, false)
+		require.NoError(t, err)
+		assert.Empty(t, packages, "未付清不发放")
+
+		_, err = salesSvc.AddOrderPayments(ctx, sales.AddOrderPaymentReq{
+			OrderID:  purchase.ID,
+			Payments: []sales.PaymentLineReq{{Method: "cash", Amount: 60000}},
+		})
+		require.NoError(t, err)
+
+		packages, err = packageSvc.ListCustomerPackages(ctx, customer.ID, true)
+		require.NoError(t, err)
+		require.Len(t, packages, 1)
+		assert.Equal(t, int32(10), packages[0].RemainingUses)
+		assert.InDelta(t, time.Now().AddDate(0, 0, 30).Unix(), packages[0].ExpiresAt, 5)
+	})
+
+	t.Run("全部由套餐抵扣的订单为零元订单且不扣钱包", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "wallet",
+			Items:      []sales.OrderItemReq{{ProductID: haircut.ID, Qty: 2, UsePackage: true}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), order.FinalAmount)
+		assert.Equal(t, "paid", order.Status)
+		assert.Equal(t, int64(100000), mockBilling.balances[customer.ID])
+		assert.Equal(t, int32(8), remaining(t))
+	})
+
+	t.Run("取消订单退回抵扣次数", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "cash",
+			Items: []sales.OrderItemReq{
+				{ProductID: haircut.ID, Qty: 1, UsePackage: true},
+				{ProductID: haircut.ID, Qty: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(8000), order.FinalAmount, "抵扣项不计入应付金额")
+		assert.Equal(t, int32(7), remaining(t))
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", Reason: "客户取消"})
+		require.NoError(t, err)
+		assert.Equal(t, int32(8), remaining(t))
+	})
+
+	t.Run("退款抵扣项只退次数不退金额", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			PayMethod:  "wallet",
+			Items: []sales.OrderItemReq{
+				{ProductID: haircut.ID, Qty: 1, UsePackage: true},
+				{ProductID: haircut.ID, Qty: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(92000), mockBilling.balances[customer.ID])
+		assert.Equal(t, int32(7), remaining(t))
+
+		_, items, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		refund, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: items[0].ID, Qty: 1}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), refund.Amount)
+		assert.Equal(t, int32(8), remaining(t))
+
+		require.NoError(t, salesSvc.RefundOrder(ctx, order.ID, "全部退款"))
+		assert.Equal(t, int64(100000), mockBilling.balances[customer.ID])
+		assert.Equal(t, int32(8), remaining(t), "已退回的抵扣不重复退回")
+	})
+
+	t.Run("已使用的套餐不可退款", func(t *testing.T) {
+		err := salesSvc.RefundOrder(ctx, purchase.ID, "不想要了")
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, common.ErrCodePackageUsed, businessErr.Code)
+		assert.Equal(t, int32(8), remaining(t))
+	})
+
+	t.Run("使用记录", func(t *testing.T) {
+		packages, err := packageSvc.ListCustomerPackages(ctx, customer.ID, false)
+		require.NoError(t, err)
+		txs, err := packageSvc.ListPackageTransactions(ctx, packages[0].ID)
+		require.NoError(t, err)
+
+		types := make([]string, len(txs))
+		for i, tx := range txs {
+			types[i] = tx.Type
+		}
+		assert.Equal(t, []string{"purchase", "redeem", "redeem", "restore", "redeem", "restore"}, types)
+		assert.Equal(t, int32(8), txs[len(txs)-1].RemainingAfter)
+	})
+}
+
+// TestOnlinePayment 在线支付单元测试
+// 使用内置模拟渠道验证：创建支付单、回调付清订单、重复回调幂等、验签与金额校验、卡单同步、取消订单后原路退款
+func TestOnlinePayment(t *testing.T) {
+	db := newSalesTestDB(t)
+	require.NoError(t, db.AutoMigrate(&PaymentIntentRecord{}))
+	ctx := context.Background()
+
+	q := query.Use(db)
+	customer := &model.Customer{Name: "在线支付客户"}
+	require.NoError(t, q.Customer.WithContext(ctx).Create(customer))
+
+	mockCatalog := &mockCatalogService{
+		products: map[int64]catalog.Product{
+			6001: {ID: 6001, Name: "护理套餐", Price: 30000},
+		},
+	}
+	mockOutbox := &mockOutboxService{events: make([]string, 0)}
+	salesSvc := NewSalesServiceImpl(db, common.NewTx(db), mockCatalog, &mockBillingService{balances: map[int64]int64{}}, mockOutbox, nil, nil)
+	provider := NewMockPaymentProvider("test-secret")
+	paymentSvc := NewOnlinePaymentService(salesSvc, MockPaymentProviderName, provider)
+
+	placeOrder := func(t *testing.T, idem string, cash int64) sales.Order {
+		req := sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 1}},
+			IdemKey:    idem,
+		}
+		if cash > 0 {
+			req.Payments = []sales.PaymentLineReq{{Method: "cash", Amount: cash}}
+		}
+		order, err := salesSvc.PlaceOrder(ctx, req)
+		require.NoError(t, err)
+		return order
+	}
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+
+	t.Run("回调付清订单且重复回调幂等", func(t *testing.T) {
+		order := placeOrder(t, "online_full", 10000)
+
+		intent, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, OperatorID: 3, IdemKey: "pi-1"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(20000), intent.Amount, "缺省支付剩余应付金额")
+		assert.Equal(t, sales.PaymentIntentStatusPending, intent.Status)
+		assert.NotEmpty(t, intent.PayURL)
+		assert.Equal(t, "mock_"+intent.IntentNo, intent.ProviderTradeNo)
+
+		replay, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, IdemKey: "pi-1"})
+		require.NoError(t, err)
+		assert.Equal(t, intent.ID, replay.ID, "相同幂等键返回同一张支付单")
+
+		// 进行中的支付单占用剩余应付金额
+		_, err = paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, Amount: 1})
+		assertCode(t, err, common.ErrCodeOrderOverpaid)
+
+		payload, signature, err := provider.Complete(intent.IntentNo, sales.PaymentIntentStatusSucceeded)
+		require.NoError(t, err)
+
+		_, err = paymentSvc.HandleCallback(ctx, MockPaymentProviderName, payload, "bad-signature")
+		assertCode(t, err, common.ErrCodePaymentSignatureInvalid)
+		_, err = paymentSvc.HandleCallback(ctx, "unknown", payload, signature)
+		assertCode(t, err, common.ErrCodePaymentProviderNotFound)
+
+		eventsBefore := len(mockOutbox.events)
+		paid, err := paymentSvc.HandleCallback(ctx, MockPaymentProviderName, payload, signature)
+		require.NoError(t, err)
+		assert.Equal(t, sales.PaymentIntentStatusSucceeded, paid.Status)
+		assert.NotZero(t, paid.OrderPaymentID)
+		assert.NotZero(t, paid.PaidAt)
+		assert.Equal(t, []string{common.EventTypeOrderPaid}, mockOutbox.events[eventsBefore:])
+
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "paid", current.Status)
+		assert.Equal(t, "paid", current.PaymentStatus)
+
+		again, err := paymentSvc.HandleCallback(ctx, MockPaymentProviderName, payload, signature)
+		require.NoError(t, err)
+		assert.Equal(t, paid.OrderPaymentID, again.OrderPaymentID)
+		assert.Len(t, mockOutbox.events, eventsBefore+1, "重复回调不再产生事件")
+
+		payments, err := salesSvc.ListOrderPayments(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, payments, 2)
+		assert.Equal(t, "online", payments[1].Method)
+		assert.Equal(t, int64(20000), payments[1].Amount)
+	})
+
+	t.Run("实付金额不一致被拒绝", func(t *testing.T) {
+		order := placeOrder(t, "online_mismatch", 0)
+		intent, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, Amount: 10000})
+		require.NoError(t, err)
+
+		forged := []byte(`{"intent_no":"` + intent.IntentNo + `","status":"succeeded","amount":1}`)
+		_, err = paymentSvc.HandleCallback(ctx, MockPaymentProviderName, forged, provider.sign(forged))
+		assertCode(t, err, common.ErrCodePaymentAmountMismatch)
+
+		intents, err := paymentSvc.ListOrderPaymentIntents(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, intents, 1)
+		assert.Equal(t, sales.PaymentIntentStatusPending, intents[0].Status)
+	})
+
+	t.Run("卡单查询与主动同步", func(t *testing.T) {
+		order := placeOrder(t, "online_stuck", 0)
+		succeeded, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, Amount: 10000})
+		require.NoError(t, err)
+		failed, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, Amount: 5000})
+		require.NoError(t, err)
+
+		stuck, err := paymentSvc.ListStuckPaymentIntents(ctx, time.Now().Unix()+1, 100)
+		require.NoError(t, err)
+		ids := make([]int64, len(stuck))
+		for i, it := range stuck {
+			ids[i] = it.ID
+		}
+		assert.Contains(t, ids, succeeded.ID)
+		assert.Contains(t, ids, failed.ID)
+		none, err := paymentSvc.ListStuckPaymentIntents(ctx, time.Now().Unix()-3600, 100)
+		require.NoError(t, err)
+		assert.Empty(t, none)
+
+		// 渠道仍在支付中时同步不改变状态
+		synced, err := paymentSvc.SyncPaymentIntent(ctx, succeeded.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sales.PaymentIntentStatusPending, synced.Status)
+
+		// 回调丢失，主动查询补偿
+		_, _, err = provider.Complete(succeeded.IntentNo, sales.PaymentIntentStatusSucceeded)
+		require.NoError(t, err)
+		_, _, err = provider.Complete(failed.IntentNo, sales.PaymentIntentStatusFailed)
+		require.NoError(t, err)
+		synced, err = paymentSvc.SyncPaymentIntent(ctx, succeeded.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sales.PaymentIntentStatusSucceeded, synced.Status)
+		synced, err = paymentSvc.SyncPaymentIntent(ctx, failed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, sales.PaymentIntentStatusFailed, synced.Status)
+
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "partially_paid", current.PaymentStatus)
+
+		_, err = paymentSvc.SyncPaymentIntent(ctx, 999999)
+		assertCode(t, err, common.ErrCodePaymentIntentNotFound)
+	})
+
+	t.Run("订单取消后支付成功原路退款", func(t *testing.T) {
+		order := placeOrder(t, "online_cancelled", 0)
+		intent, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID})
+		require.NoError(t, err)
+
+		_, err = salesSvc.UpdateOrderStatus(ctx, sales.UpdateOrderStatusReq{OrderID: order.ID, Status: "cancelled", Reason: "客户取消"})
+		require.NoError(t, err)
+		_, err = paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID})
+		assertCode(t, err, common.ErrCodeOrderStatusInvalid)
+
+		payload, signature, err := provider.Complete(intent.IntentNo, sales.PaymentIntentStatusSucceeded)
+		require.NoError(t, err)
+		refunded, err := paymentSvc.HandleCallback(ctx, MockPaymentProviderName, payload, signature)
+		require.NoError(t, err)
+		assert.Equal(t, sales.PaymentIntentStatusRefunded, refunded.Status)
+		assert.NotEmpty(t, refunded.FailureReason)
+		assert.Zero(t, refunded.OrderPaymentID)
+
+		payments, err := salesSvc.ListOrderPayments(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Empty(t, payments)
+	})
+
+	t.Run("退款按支付明细原路退回，在线支付经收款渠道退款", func(t *testing.T) {
+		order, err := salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: customer.ID,
+			Items:      []sales.OrderItemReq{{ProductID: 6001, Qty: 2}},
+			Payments:   []sales.PaymentLineReq{{Method: "cash", Amount: 10000}},
+			IdemKey:    "online_refund",
+		})
+		require.NoError(t, err)
+		intent, err := paymentSvc.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID})
+		require.NoError(t, err)
+		require.Equal(t, int64(50000), intent.Amount)
+		payload, signature, err := provider.Complete(intent.IntentNo, sales.PaymentIntentStatusSucceeded)
+		require.NoError(t, err)
+		paid, err := paymentSvc.HandleCallback(ctx, MockPaymentProviderName, payload, signature)
+		require.NoError(t, err)
+		require.Equal(t, sales.PaymentIntentStatusSucceeded, paid.Status)
+		_, items, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		// 第一次退一件：先退完现金明细（退回现金），余下从在线支付明细经渠道退回
+		first, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: items[0].ID, Qty: 1}},
+			IdemKey: "online-refund-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(30000), first.Amount)
+		assert.Zero(t, first.WalletTxID, "没有钱包支付明细，不产生钱包退款流水")
+		require.Len(t, first.Payments, 2)
+		assert.Equal(t, "cash", first.Payments[0].Method)
+		assert.Equal(t, int64(10000), first.Payments[0].Amount)
+		assert.Zero(t, first.Payments[0].WalletTxID)
+		assert.Empty(t, first.Payments[0].Provider)
+		assert.Equal(t, "online", first.Payments[1].Method)
+		assert.Equal(t, paid.OrderPaymentID, first.Payments[1].OrderPaymentID)
+		assert.Equal(t, int64(20000), first.Payments[1].Amount)
+		assert.Zero(t, first.Payments[1].WalletTxID)
+		assert.Equal(t, MockPaymentProviderName, first.Payments[1].Provider)
+		assert.NotEmpty(t, first.Payments[1].ProviderRefundNo)
+
+		replay, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: items[0].ID, Qty: 1}},
+			IdemKey: "online-refund-1",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, replay.ID)
+		assert.Equal(t, first.Payments, replay.Payments)
+
+		// 第二次退一件：现金明细已退完，全部经渠道退回，不产生钱包流水
+		second, err := salesSvc.RefundOrderItems(ctx, sales.PartialRefundReq{
+			OrderID: order.ID,
+			Items:   []sales.RefundItemReq{{OrderItemID: items[0].ID, Qty: 1}},
+			IdemKey: "online-refund-2",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(30000), second.Amount)
+		assert.Zero(t, second.WalletTxID)
+		require.Len(t, second.Payments, 1)
+		assert.Equal(t, int64(30000), second.Payments[0].Amount)
+		assert.Equal(t, MockPaymentProviderName, second.Payments[0].Provider)
+		assert.NotEqual(t, first.Payments[1].ProviderRefundNo, second.Payments[0].ProviderRefundNo)
+
+		refunds, err := salesSvc.ListOrderRefunds(ctx, order.ID)
+		require.NoError(t, err)
+		require.Len(t, refunds, 2)
+		assert.Equal(t, first.Payments, refunds[0].Payments)
+		current, _, err := salesSvc.GetOrder(ctx, order.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "refunded", current.Status)
+	})
+
+	t.Run("未配置默认渠道时必须指定渠道", func(t *testing.T) {
+		order := placeOrder(t, "online_no_default", 0)
+		noDefault := NewOnlinePaymentService(salesSvc, "")
+		_, err := noDefault.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		intent, err := noDefault.CreatePaymentIntent(ctx, sales.CreatePaymentIntentReq{OrderID: order.ID, Provider: MockPaymentProviderName})
+		require.NoError(t, err)
+		assert.Equal(t, MockPaymentProviderName, intent.Provider)
+	})
+}
@@ -4,14 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
 	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
 	"crm_lite/internal/dao/model"
 	"crm_lite/internal/dao/query"
 	"crm_lite/internal/domains/billing"
 	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/entitlement"
+	"crm_lite/internal/domains/marketing"
 	"crm_lite/internal/domains/sales"
 	"crm_lite/pkg/utils"
 
@@ -27,10 +31,14 @@ type SalesServiceImpl struct {
 	catalogSvc catalog.Service
 	billingSvc billing.Service
 	outboxSvc  common.OutboxService
+	couponSvc  marketing.CouponService
+	packageSvc entitlement.Service
+	providers  map[string]sales.PaymentProvider // 在线支付渠道，在线支付明细退款时经收款渠道原路退回
 }
 
 // NewSalesServiceImpl 创建 Sales 服务完整实现
-func NewSalesServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service, billingSvc billing.Service, outboxSvc common.OutboxService) *SalesServiceImpl {
+// couponSvc、packageSvc 可为 nil，此时下单不支持优惠券、次卡套餐
+func NewSalesServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service, billingSvc billing.Service, outboxSvc common.OutboxService, couponSvc marketing.CouponService, packageSvc entitlement.Service) *SalesServiceImpl {
 	return &SalesServiceImpl{
 		db:         db,
 		q:          query.Use(db),
@@ -38,11 +46,21 @@ func NewSalesServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service,
 		catalogSvc: catalogSvc,
 		billingSvc: billingSvc,
 		outboxSvc:  outboxSvc,
+		couponSvc:  couponSvc,
+		packageSvc: packageSvc,
+		providers:  make(map[string]sales.PaymentProvider),
+	}
+}
+
+// registerPaymentProviders 注册在线支付渠道，同名渠道后注册的覆盖先注册的
+func (s *SalesServiceImpl) registerPaymentProviders(providers ...sales.PaymentProvider) {
+	for _, p := range providers {
+		s.providers[p.Name()] = p
 	}
 }
 
 // PlaceOrder 统一下单事务收口
-// 在单一事务中完成：产品快照 + 订单创建 + 钱包扣减 + outbox 事件
+// 在单一事务中完成：产品快照 + 订单创建 + 套餐抵扣 + 优惠券核销 + 钱包扣减 + outbox 事件
 func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderReq) (sales.Order, error) {
 	var result sales.Order
 
@@ -66,11 +84,8 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 		if err != nil {
 			return fmt.Errorf("获取产品信息失败: %w", err)
 		}
-		if len(products) != len(req.Items) {
-			return common.NewBusinessError(common.ErrCodeProductNotFound, "部分产品不存在")
-		}
 
-		// 构建产品映射
+		// 构建产品映射，同一产品可出现在多个订单项中（如部分使用套餐抵扣）
 		productMap := make(map[int64]catalog.Product)
 		for _, product := range products {
 			productMap[product.ID] = product
@@ -79,15 +94,29 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 				return fmt.Errorf("产品 %s 不可售: %w", product.Name, err)
 			}
 		}
+		for _, item := range req.Items {
+			if _, ok := productMap[item.ProductID]; !ok {
+				return common.NewBusinessError(common.ErrCodeProductNotFound, "部分产品不存在")
+			}
+		}
+		if err := s.checkPackageItems(ctx, req.Items, productMap); err != nil {
+			return err
+		}
 
 		// 3. 计算订单总金额并创建订单项快照
+		// 套餐抵扣项整行计入订单项折扣，实付为 0 且不计入订单总金额
 		var totalAmount int64 = 0
 		orderItems := make([]*model.OrderItem, len(req.Items))
 
 		for i, item := range req.Items {
 			product := productMap[item.ProductID]
 			itemAmount := product.Price * int64(item.Qty)
-			totalAmount += itemAmount
+			var itemDiscount int64
+			if item.UsePackage {
+				itemDiscount = itemAmount
+			} else {
+				totalAmount += itemAmount
+			}
 
 			// 创建订单项（包含产品快照）
 			orderItems[i] = &model.OrderItem{
@@ -98,22 +127,37 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 				UnitPrice:           float64(product.Price) / 100, // 转换为元（兼容旧字段）
 				UnitPriceSnapshot:   product.Price,                // 快照以分为单位
 				DurationMinSnapshot: product.DurationMin,          // 服务时长快照
-				FinalPrice:          float64(itemAmount) / 100,    // 转换为元（兼容旧字段）
+				DiscountAmount:      float64(itemDiscount) / 100,
+				FinalPrice:          float64(itemAmount-itemDiscount) / 100, // 转换为元（兼容旧字段）
 			}
 		}
 
+		// 扣减库存（条件更新，仅实物产品占用库存）
+		stockItems := make([]catalog.StockItem, len(req.Items))
+		for i, item := range req.Items {
+			stockItems[i] = catalog.StockItem{ProductID: item.ProductID, Qty: item.Qty}
+		}
+		if err := s.catalogSvc.ReserveStock(ctx, stockItems); err != nil {
+			return err
+		}
+
 		// 4. 应用折扣
-		finalAmount := totalAmount - req.Discount
+		discount := req.Discount
+		finalAmount := totalAmount - discount
 
 		// 5. 创建订单
 		order := &model.Order{
-			OrderNo:     utils.GenerateOrderNo(),
-			CustomerID:  req.CustomerID,
-			OrderDate:   time.Now(),
-			Status:      "pending",                  // 待支付状态
-			TotalAmount: float64(totalAmount) / 100, // 转换为元（兼容旧字段）
-			FinalAmount: float64(finalAmount) / 100, // 转换为元（兼容旧字段）
-			Remark:      req.Remark,
+			OrderNo:        utils.GenerateOrderNo(),
+			CustomerID:     req.CustomerID,
+			OrderDate:      time.Now(),
+			Status:         "pending", // 待支付状态
+			PaymentStatus:  string(constants.PaymentStatusUnpaid),
+			PaymentMethod:  orderPaymentMethod(req.PayMethod, req.Payments),
+			TotalAmount:    float64(totalAmount) / 100, // 转换为元（兼容旧字段）
+			DiscountAmount: float64(discount) / 100,    // 转换为元（兼容旧字段）
+			FinalAmount:    float64(finalAmount) / 100, // 转换为元（兼容旧字段）
+			AssignedTo:     req.AssignedTo,
+			Remark:         req.Remark,
 		}
 
 		// 创建订单记录
@@ -131,31 +175,43 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 			return fmt.Errorf("创建订单项失败: %w", err)
 		}
 
-		// 6. 如果是钱包支付，执行扣款
-		if req.PayMethod == "wallet" && finalAmount > 0 {
-			idemKey := fmt.Sprintf("order_pay_%d_%s", order.ID, req.IdemKey)
-			err := s.billingSvc.DebitForOrder(ctx, req.CustomerID, order.ID, finalAmount, idemKey)
-			if err != nil {
-				return fmt.Errorf("钱包扣款失败: %w", err)
-			}
+		// 套餐抵扣，次数不足时整单失败
+		if err := s.redeemPackages(ctx, order, req.Items, orderItems); err != nil {
+			return err
+		}
 
-			// 更新订单支付状态
-			_, err = txQuery.Order.WithContext(ctx).Where(txQuery.Order.ID.Eq(order.ID)).Update(txQuery.Order.Status, "paid")
+		// 核销优惠券，优惠叠加在手工折扣之上；活动发放的券用于订单来源归因
+		sourceRef := req.SourceRef
+		if req.CouponCode != "" {
+			redemption, err := s.redeemCoupon(ctx, order, req.CouponCode, orderItems, productMap)
 			if err != nil {
-				return fmt.Errorf("更新订单状态失败: %w", err)
+				return err
+			}
+			discount = int64(math.Round(order.DiscountAmount * 100))
+			finalAmount = int64(math.Round(order.FinalAmount * 100))
+			if sourceRef == nil && redemption.CampaignID > 0 {
+				sourceRef = &sales.SourceRef{Type: "marketing", ID: redemption.CampaignID}
 			}
-			order.Status = "paid"
 		}
 
-		// 7. 写入 Outbox 事件
+		// 记录下单状态
+		if err := s.recordStatusLog(ctx, order.ID, "", order.Status, 0, "下单"); err != nil {
+			return err
+		}
+
+		// 6. 写入 Outbox 事件
 		orderEvent := common.OrderPlacedEvent{
 			OrderID:     order.ID,
 			OrderNo:     order.OrderNo,
 			CustomerID:  req.CustomerID,
 			TotalAmount: finalAmount,
-			PayMethod:   req.PayMethod,
+			PayMethod:   order.PaymentMethod,
 			CreatedAt:   time.Now().Unix(),
 		}
+		if sourceRef != nil {
+			orderEvent.SourceType = sourceRef.Type
+			orderEvent.SourceID = sourceRef.ID
+		}
 
 		if err := s.outboxSvc.PublishEvent(ctx, common.EventTypeOrderPlaced, orderEvent); err != nil {
 			// 日志记录但不阻断业务流程
@@ -163,17 +219,21 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 			_ = err // 暂时忽略错误
 		}
 
-		// 如果是钱包支付且已扣款成功，发送支付事件
-		if req.PayMethod == "wallet" && finalAmount > 0 {
-			paidEvent := common.OrderPaidEvent{
-				OrderID:    order.ID,
-				OrderNo:    order.OrderNo,
-				CustomerID: req.CustomerID,
-				PaidAmount: finalAmount,
-				PayMethod:  req.PayMethod,
-				PaidAt:     time.Now().Unix(),
+		// 7. 记录支付明细：钱包部分按行扣款，付清时流转为 paid 并写入支付事件
+		// 未指定组合支付时，钱包支付视为整单钱包支付
+		payments := req.Payments
+		if len(payments) == 0 && req.PayMethod == string(constants.PaymentMethodWallet) && finalAmount > 0 {
+			payments = []sales.PaymentLineReq{{Method: req.PayMethod, Amount: finalAmount}}
+		}
+		if len(payments) > 0 {
+			if _, err := s.recordPayments(ctx, order, payments, 0, req.IdemKey); err != nil {
+				return err
+			}
+		} else if finalAmount == 0 {
+			// 零元订单（如全部由套餐抵扣）无需支付，直接流转为 paid
+			if err := s.syncPaymentStatus(ctx, order, 0, 0); err != nil {
+				return err
 			}
-			_ = s.outboxSvc.PublishEvent(ctx, common.EventTypeOrderPaid, paidEvent)
 		}
 
 		// 8. 构建返回结果
@@ -182,10 +242,11 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 			OrderNo:        order.OrderNo,
 			CustomerID:     order.CustomerID,
 			TotalAmount:    totalAmount,
-			DiscountAmount: req.Discount,
+			DiscountAmount: discount,
 			FinalAmount:    finalAmount,
 			Status:         order.Status,
-			PayMethod:      req.PayMethod,
+			PaymentStatus:  order.PaymentStatus,
+			PayMethod:      order.PaymentMethod,
 			CreatedAt:      time.Now().Unix(),
 		}
 
@@ -200,61 +261,16 @@ func (s *SalesServiceImpl) PlaceOrder(ctx context.Context, req sales.PlaceOrderR
 }
 
 // RefundOrder 统一退款事务收口
-// 在单一事务中完成：订单状态更新 + 钱包退款 + outbox 事件
+// 退回订单全部剩余可退金额：订单状态更新 + 钱包退款 + 退款单 + outbox 事件
 func (s *SalesServiceImpl) RefundOrder(ctx context.Context, orderID int64, reason string) error {
-	return s.tx.WithTx(ctx, func(ctx context.Context) error {
-		txDB := s.tx.GetDB(ctx)
-		txQuery := query.Use(txDB)
-
-		// 1. 获取订单信息
-		order, err := txQuery.Order.WithContext(ctx).Where(txQuery.Order.ID.Eq(orderID)).First()
-		if err != nil {
-			return common.NewBusinessError(common.ErrCodeOrderNotFound, "订单不存在")
-		}
-
-		// 2. 校验订单状态
-		if order.Status == "refunded" {
-			return common.NewBusinessError(common.ErrCodeOrderStatusInvalid, "订单已退款")
-		}
-		if order.Status != "paid" && order.Status != "completed" {
-			return common.NewBusinessError(common.ErrCodeOrderCannotRefund, "订单状态不支持退款")
-		}
-
-		// 3. 更新订单状态为退款
-		_, err = txQuery.Order.WithContext(ctx).Where(txQuery.Order.ID.Eq(orderID)).Update(txQuery.Order.Status, "refunded")
-		if err != nil {
-			return fmt.Errorf("更新订单状态失败: %w", err)
-		}
-
-		// 4. 如果原订单是钱包支付，执行退款
-		finalAmount := int64(order.FinalAmount * 100) // 转换为分
-		if finalAmount > 0 {
-			// 这里假设原支付方式信息存储在订单中，实际可能需要从其他地方获取
-			// 为了演示，我们假设支付金额需要退回钱包
-			idemKey := fmt.Sprintf("order_refund_%d_%d", orderID, time.Now().UnixNano())
-			err := s.billingSvc.CreditForRefund(ctx, order.CustomerID, orderID, finalAmount, idemKey)
-			if err != nil {
-				return fmt.Errorf("钱包退款失败: %w", err)
-			}
-		}
-
-		// 5. 写入 Outbox 事件
-		refundEvent := common.OrderRefundedEvent{
-			OrderID:      orderID,
-			OrderNo:      order.OrderNo,
-			CustomerID:   order.CustomerID,
-			RefundAmount: finalAmount,
-			Reason:       reason,
-			RefundedAt:   time.Now().Unix(),
-		}
-
-		if err := s.outboxSvc.PublishEvent(ctx, common.EventTypeOrderRefunded, refundEvent); err != nil {
-			// 日志记录但不阻断业务流程
-			// TODO: 添加日志
-			_ = err // 暂时忽略错误
-		}
+	return s.refundOrder(ctx, orderID, 0, reason)
+}
 
-		return nil
+// refundOrder 全额退款流程，operatorID 为 0 表示系统操作
+func (s *SalesServiceImpl) refundOrder(ctx context.Context, orderID, operatorID int64, reason string) error {
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		_, err := s.refundItems(ctx, orderID, nil, operatorID, reason, "")
+		return err
 	})
 }
 
@@ -276,15 +292,7 @@ func (s *SalesServiceImpl) GetOrder(ctx context.Context, orderID int64) (*sales.
 	}
 
 	// 转换为域模型
-	salesOrder := &sales.Order{
-		ID:          order.ID,
-		OrderNo:     order.OrderNo,
-		CustomerID:  order.CustomerID,
-		TotalAmount: int64(order.TotalAmount * 100), // 转换为分
-		FinalAmount: int64(order.FinalAmount * 100), // 转换为分
-		Status:      order.Status,
-		CreatedAt:   order.CreatedAt.Unix(),
-	}
+	salesOrder := toSalesOrder(order)
 
 	salesItems := make([]sales.OrderItem, len(orderItems))
 	for i, item := range orderItems {
@@ -296,11 +304,12 @@ func (s *SalesServiceImpl) GetOrder(ctx context.Context, orderID int64) (*sales.
 			UnitPriceSnapshot:   item.UnitPriceSnapshot,
 			DurationMinSnapshot: item.DurationMinSnapshot,
 			Quantity:            item.Quantity,
+			DiscountAmount:      int64(math.Round(item.DiscountAmount * 100)),
 			FinalPrice:          int64(item.FinalPrice * 100), // 转换为分
 		}
 	}
 
-	return salesOrder, salesItems, nil
+	return &salesOrder, salesItems, nil
 }
 
 // ListOrders 分页查询订单
@@ -344,8 +353,10 @@ func (s *SalesServiceImpl) CreateOrder(ctx context.Context, req *sales.CreateOrd
 		ContactID:  req.CustomerID, // 暂时使用客户ID作为联系人ID
 		Channel:    "web",          // 默认渠道
 		PayMethod:  "wallet",       // 默认钱包支付
+		Payments:   req.Payments,
 		Items:      make([]sales.OrderItemReq, len(req.Items)),
 		Discount:   0, // 目前DTO中没有折扣字段，设为0
+		CouponCode: req.CouponCode,
 		Remark:     req.Remark,
 		AssignedTo: 1, // 默认分配给管理员1
 		IdemKey:    fmt.Sprintf("order_%d_%d", req.CustomerID, time.Now().UnixNano()),
@@ -354,8 +365,9 @@ func (s *SalesServiceImpl) CreateOrder(ctx context.Context, req *sales.CreateOrd
 	// 转换订单项
 	for i, item := range req.Items {
 		placeOrderReq.Items[i] = sales.OrderItemReq{
-			ProductID: item.ProductID,
-			Qty:       int32(item.Quantity),
+			ProductID:  item.ProductID,
+			Qty:        int32(item.Quantity),
+			UsePackage: item.UsePackage,
 		}
 	}
 
@@ -371,6 +383,7 @@ func (s *SalesServiceImpl) CreateOrder(ctx context.Context, req *sales.CreateOrd
 		OrderNo:     order.OrderNo,
 		CustomerID:  order.CustomerID,
 		TotalAmount: float64(order.TotalAmount) / 100.0,
+		FinalAmount: float64(order.FinalAmount) / 100.0,
 		Status:      order.Status,
 		Items:       []sales.OrderItemResponse{}, // TODO: 填充订单项
 		CreatedAt:   order.CreatedAt,
@@ -412,6 +425,7 @@ func (s *SalesServiceImpl) GetOrderByID(ctx context.Context, idStr string) (*sal
 		OrderNo:     order.OrderNo,
 		CustomerID:  order.CustomerID,
 		TotalAmount: float64(order.TotalAmount) / 100.0,
+		FinalAmount: float64(order.FinalAmount) / 100.0,
 		Status:      order.Status,
 		Items:       itemResponses,
 		CreatedAt:   order.CreatedAt,
@@ -423,33 +437,35 @@ func (s *SalesServiceImpl) GetOrderByID(ctx context.Context, idStr string) (*sal
 
 // ListOrdersForController 获取订单列表（控制器接口）
 func (s *SalesServiceImpl) ListOrdersForController(ctx context.Context, req *sales.ListOrdersRequest) (*sales.ListOrdersResponse, error) {
-	// 调用领域方法
-	orders, err := s.ListOrders(ctx, req.CustomerID, req.Status, req.Page, req.PageSize)
+	orders, total, nextCursor, err := s.searchOrders(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 
 	// 转换订单列表
 	orderResponses := make([]sales.OrderResponse, len(orders))
-	for i, order := range orders {
+	for i, record := range orders {
+		order := toSalesOrder(record)
 		orderResponses[i] = sales.OrderResponse{
 			ID:          order.ID,
 			OrderNo:     order.OrderNo,
 			CustomerID:  order.CustomerID,
 			TotalAmount: float64(order.TotalAmount) / 100.0,
+			FinalAmount: float64(order.FinalAmount) / 100.0,
 			Status:      order.Status,
-			Items:       []sales.OrderItemResponse{}, // TODO: 填充订单项
+			Items:       []sales.OrderItemResponse{}, // 列表不返回订单项，详情接口获取
 			CreatedAt:   order.CreatedAt,
-			UpdatedAt:   order.CreatedAt, // 暂时使用创建时间
+			UpdatedAt:   record.UpdatedAt.Unix(),
 		}
 	}
 
 	// 返回响应
 	response := &sales.ListOrdersResponse{
-		Orders:   orderResponses,
-		Total:    int64(len(orderResponses)), // TODO: 获取真实总数
-		Page:     req.Page,
-		PageSize: req.PageSize,
+		Orders:     orderResponses,
+		Total:      total,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+		NextCursor: nextCursor,
 	}
 
 	return response, nil
@@ -492,6 +508,55 @@ func (s *ServiceImpl) ListOrders(ctx context.Context, customerID int64, status s
 	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
 }
 
+func (s *ServiceImpl) UpdateOrderStatus(ctx context.Context, req sales.UpdateOrderStatusReq) (*sales.Order, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.UpdateOrderStatus(ctx, req)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) AddOrderPayments(ctx context.Context, req sales.AddOrderPaymentReq) ([]sales.OrderPayment, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.AddOrderPayments(ctx, req)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) ListOrderPayments(ctx context.Context, orderID int64) ([]sales.OrderPayment, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.ListOrderPayments(ctx, orderID)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) CancelExpiredOrders(ctx context.Context, before time.Time, limit int) (int, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.CancelExpiredOrders(ctx, before, limit)
+	}
+	return 0, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) GetOrderStatusHistory(ctx context.Context, orderID int64) ([]sales.OrderStatusLog, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.GetOrderStatusHistory(ctx, orderID)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) RefundOrderItems(ctx context.Context, req sales.PartialRefundReq) (*sales.OrderRefund, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.RefundOrderItems(ctx, req)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
+func (s *ServiceImpl) ListOrderRefunds(ctx context.Context, orderID int64) ([]sales.OrderRefund, error) {
+	if s.fullImpl != nil {
+		return s.fullImpl.ListOrderRefunds(ctx, orderID)
+	}
+	return nil, fmt.Errorf("销售域服务未完全初始化，请使用 NewSalesServiceImpl")
+}
+
 // CreateOrder 创建订单（控制器接口）
 func (s *ServiceImpl) CreateOrder(ctx context.Context, req *sales.CreateOrderRequest) (*sales.OrderResponse, error) {
 	if s.fullImpl != nil {
@@ -0,0 +1,119 @@
+package sales
+
+import "context"
+
+// 在线支付单状态，支付渠道返回的支付状态使用相同取值
+const (
+	PaymentIntentStatusPending   = "pending"   // 待支付
+	PaymentIntentStatusSucceeded = "succeeded" // 支付成功，已记入订单支付明细
+	PaymentIntentStatusFailed    = "failed"    // 支付失败或已关闭
+	PaymentIntentStatusRefunded  = "refunded"  // 支付成功但订单已不可支付（已取消、已付清），已原路退回
+)
+
+// ProviderIntentRequest 向支付渠道发起支付的请求
+type ProviderIntentRequest struct {
+	IntentNo string `json:"intent_no"` // 本系统支付单号，作为渠道侧的商户订单号
+	Amount   int64  `json:"amount"`    // 支付金额（分）
+	Subject  string `json:"subject"`   // 支付标题
+}
+
+// ProviderIntent 支付渠道侧的支付单
+type ProviderIntent struct {
+	ProviderTradeNo string `json:"provider_trade_no"` // 渠道交易号
+	Status          string `json:"status"`            // 支付状态：pending/succeeded/failed
+	Amount          int64  `json:"amount"`            // 支付金额（分）
+	PayURL          string `json:"pay_url"`           // 支付链接，客户在此完成支付
+	PaidAt          int64  `json:"paid_at"`           // 支付完成时间（Unix时间戳），未支付为0
+}
+
+// ProviderRefundRequest 向支付渠道发起退款的请求
+type ProviderRefundRequest struct {
+	IntentNo        string `json:"intent_no"`
+	ProviderTradeNo string `json:"provider_trade_no"`
+	RefundNo        string `json:"refund_no"` // 退款单号，渠道按退款单号幂等
+	Amount          int64  `json:"amount"`    // 退款金额（分）
+	Reason          string `json:"reason"`
+}
+
+// ProviderRefund 支付渠道侧的退款结果
+type ProviderRefund struct {
+	ProviderRefundNo string `json:"provider_refund_no"` // 渠道退款单号
+	Status           string `json:"status"`             // 退款状态：pending/succeeded/failed
+}
+
+// PaymentCallback 验签后的支付回调内容
+type PaymentCallback struct {
+	IntentNo        string `json:"intent_no"`
+	ProviderTradeNo string `json:"provider_trade_no"`
+	Status          string `json:"status"` // 支付状态：succeeded/failed
+	Amount          int64  `json:"amount"` // 实付金额（分）
+	PaidAt          int64  `json:"paid_at"`
+}
+
+// PaymentProvider 在线支付渠道
+// 每个渠道以 Name 注册，回调地址为 /api/v1/payments/webhooks/<Name>
+type PaymentProvider interface {
+	// Name 渠道名称
+	Name() string
+
+	// CreateIntent 在渠道侧创建支付单，返回支付链接
+	CreateIntent(ctx context.Context, req ProviderIntentRequest) (*ProviderIntent, error)
+
+	// QueryIntent 按本系统支付单号查询渠道侧的支付状态，用于回调丢失时的补偿
+	QueryIntent(ctx context.Context, intentNo string) (*ProviderIntent, error)
+
+	// Refund 原路退款，相同退款单号重复调用返回同一结果
+	Refund(ctx context.Context, req ProviderRefundRequest) (*ProviderRefund, error)
+
+	// VerifyCallback 校验回调签名并解析回调内容，签名无效时返回错误
+	VerifyCallback(ctx context.Context, payload []byte, signature string) (*PaymentCallback, error)
+}
+
+// CreatePaymentIntentReq 创建在线支付单请求
+type CreatePaymentIntentReq struct {
+	OrderID    int64  `json:"order_id"`
+	Amount     int64  `json:"amount"`   // 支付金额（分），0 表示订单剩余应付金额
+	Provider   string `json:"provider"` // 支付渠道，为空时使用默认渠道
+	OperatorID int64  `json:"operator_id"`
+	IdemKey    string `json:"idem_key"` // 幂等键，相同键返回已有支付单
+}
+
+// PaymentIntent 在线支付单
+// 支付成功后以 online 方式记入订单支付明细，幂等键以 online_<支付单号> 按 AddOrderPayments 的规则派生
+type PaymentIntent struct {
+	ID              int64  `json:"id"`
+	IntentNo        string `json:"intent_no"`
+	OrderID         int64  `json:"order_id"`
+	CustomerID      int64  `json:"customer_id"`
+	Provider        string `json:"provider"`
+	ProviderTradeNo string `json:"provider_trade_no"`
+	Amount          int64  `json:"amount"` // 支付金额（分）
+	Status          string `json:"status"`
+	PayURL          string `json:"pay_url"`
+	OrderPaymentID  int64  `json:"order_payment_id"` // 订单支付明细ID，支付成功前为0
+	FailureReason   string `json:"failure_reason"`
+	OperatorID      int64  `json:"operator_id"`
+	PaidAt          int64  `json:"paid_at"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
+}
+
+// OnlinePaymentService 在线支付服务接口
+type OnlinePaymentService interface {
+	// CreatePaymentIntent 为订单创建在线支付单并在渠道侧下单
+	// 金额不能超过剩余应付金额减去进行中的支付单金额，超出返回 ORDER_OVERPAID
+	CreatePaymentIntent(ctx context.Context, req CreatePaymentIntentReq) (*PaymentIntent, error)
+
+	// HandleCallback 处理渠道回调：验签后按支付结果更新支付单，支付成功时记入订单支付明细，付清时订单流转为 paid 并写入 order.paid 事件
+	// 重复回调返回已处理的支付单；订单已不可支付时原路退款并标记为 refunded
+	HandleCallback(ctx context.Context, provider string, payload []byte, signature string) (*PaymentIntent, error)
+
+	// SyncPaymentIntent 主动向渠道查询 pending 支付单的状态并按回调相同的规则处理
+	SyncPaymentIntent(ctx context.Context, intentID int64) (*PaymentIntent, error)
+
+	// ListOrderPaymentIntents 查询订单的在线支付单，按创建时间正序
+	ListOrderPaymentIntents(ctx context.Context, orderID int64) ([]PaymentIntent, error)
+
+	// ListStuckPaymentIntents 查询 before 之前创建且仍为 pending 的支付单，按创建时间正序，用于对账补偿
+	ListStuckPaymentIntents(ctx context.Context, before int64, limit int) ([]PaymentIntent, error)
+}
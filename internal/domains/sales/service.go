@@ -3,13 +3,17 @@
 // 核心原则：统一下单和退款的事务边界，确保订单状态与钱包操作的一致性
 package sales
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // OrderItemReq 下单商品项请求
 // 用于接收前端传递的下单商品信息
 type OrderItemReq struct {
-	ProductID int64 `json:"product_id"` // 产品ID
-	Qty       int32 `json:"qty"`        // 数量
+	ProductID  int64 `json:"product_id"`  // 产品ID
+	Qty        int32 `json:"qty"`         // 数量
+	UsePackage bool  `json:"use_package"` // 使用客户次卡套餐抵扣，该项不计入应付金额
 }
 
 // SourceRef 来源引用
@@ -22,16 +26,45 @@ type SourceRef struct {
 // PlaceOrderReq 下单请求
 // 封装下单所需的所有信息，确保事务原子性
 type PlaceOrderReq struct {
-	CustomerID int64          `json:"customer_id"` // 客户ID
-	ContactID  int64          `json:"contact_id"`  // 联系人ID（可选）
-	Channel    string         `json:"channel"`     // 下单渠道：web/mobile/admin
-	PayMethod  string         `json:"pay_method"`  // 支付方式：wallet/cash/online
-	Items      []OrderItemReq `json:"items"`       // 订单项列表
-	Discount   int64          `json:"discount"`    // 折扣金额（分）
-	SourceRef  *SourceRef     `json:"source_ref"`  // 来源引用（可选）
-	IdemKey    string         `json:"idem_key"`    // 幂等键，防止重复下单
-	Remark     string         `json:"remark"`      // 备注
-	AssignedTo int64          `json:"assigned_to"` // 分配给的员工ID
+	CustomerID int64            `json:"customer_id"` // 客户ID
+	ContactID  int64            `json:"contact_id"`  // 联系人ID（可选）
+	Channel    string           `json:"channel"`     // 下单渠道：web/mobile/admin
+	PayMethod  string           `json:"pay_method"`  // 支付方式：wallet/cash/online
+	Payments   []PaymentLineReq `json:"payments"`    // 组合支付明细（可选），为空时按 PayMethod 处理
+	Items      []OrderItemReq   `json:"items"`       // 订单项列表
+	Discount   int64            `json:"discount"`    // 折扣金额（分）
+	CouponCode string           `json:"coupon_code"` // 优惠券码（可选），在下单事务中核销
+	SourceRef  *SourceRef       `json:"source_ref"`  // 来源引用（可选），未指定时按优惠券所属活动归因
+	IdemKey    string           `json:"idem_key"`    // 幂等键，防止重复下单
+	Remark     string           `json:"remark"`      // 备注
+	AssignedTo int64            `json:"assigned_to"` // 分配给的员工ID
+}
+
+// PaymentLineReq 支付明细请求
+// 一笔订单可由多种支付方式组合支付，钱包部分通过 billing 域扣款
+type PaymentLineReq struct {
+	Method string `json:"method"` // 支付方式：wallet/cash/online/card
+	Amount int64  `json:"amount"` // 支付金额（分）
+}
+
+// AddOrderPaymentReq 追加订单支付请求
+// 用于下单后补录线下收款或补足剩余应付金额
+type AddOrderPaymentReq struct {
+	OrderID    int64            `json:"order_id"`    // 订单ID
+	Payments   []PaymentLineReq `json:"payments"`    // 支付明细
+	OperatorID int64            `json:"operator_id"` // 操作人ID
+	IdemKey    string           `json:"idem_key"`    // 幂等键，相同键重复提交返回已有支付明细
+}
+
+// OrderPayment 订单支付明细
+type OrderPayment struct {
+	ID         int64  `json:"id"`           // 支付明细ID
+	OrderID    int64  `json:"order_id"`     // 订单ID
+	Method     string `json:"method"`       // 支付方式
+	Amount     int64  `json:"amount"`       // 支付金额（分）
+	WalletTxID int64  `json:"wallet_tx_id"` // 钱包扣款流水ID，非钱包支付为0
+	OperatorID int64  `json:"operator_id"`  // 操作人ID，0表示系统
+	CreatedAt  int64  `json:"created_at"`   // 支付时间
 }
 
 // Order 订单领域模型
@@ -64,6 +97,73 @@ type OrderItem struct {
 	FinalPrice          int64  `json:"final_price"`           // 该项最终价格（分）
 }
 
+// UpdateOrderStatusReq 订单状态流转请求
+type UpdateOrderStatusReq struct {
+	OrderID    int64  `json:"order_id"`    // 订单ID
+	Status     string `json:"status"`      // 目标状态
+	OperatorID int64  `json:"operator_id"` // 操作人ID，0 表示系统
+	Reason     string `json:"reason"`      // 流转原因
+}
+
+// OrderStatusLog 订单状态流转记录
+type OrderStatusLog struct {
+	ID         int64  `json:"id"`
+	OrderID    int64  `json:"order_id"`    // 订单ID
+	FromStatus string `json:"from_status"` // 原状态，下单时为空
+	ToStatus   string `json:"to_status"`   // 目标状态
+	OperatorID int64  `json:"operator_id"` // 操作人ID，0 表示系统
+	Reason     string `json:"reason"`      // 流转原因
+	CreatedAt  int64  `json:"created_at"`  // 流转时间（Unix时间戳）
+}
+
+// RefundItemReq 退款订单项请求
+type RefundItemReq struct {
+	OrderItemID int64 `json:"order_item_id"` // 订单项ID
+	Qty         int32 `json:"qty"`           // 退款数量
+}
+
+// PartialRefundReq 部分退款请求
+type PartialRefundReq struct {
+	OrderID    int64           `json:"order_id"`    // 订单ID
+	Items      []RefundItemReq `json:"items"`       // 退款订单项
+	Reason     string          `json:"reason"`      // 退款原因
+	OperatorID int64           `json:"operator_id"` // 操作人ID，0 表示系统
+	IdemKey    string          `json:"idem_key"`    // 幂等键，相同键重复提交返回同一退款单
+}
+
+// OrderRefund 退款单
+// 关联原订单及billing域生成的退款流水
+type OrderRefund struct {
+	ID         int64                `json:"id"`           // 退款单ID
+	OrderID    int64                `json:"order_id"`     // 订单ID
+	Amount     int64                `json:"amount"`       // 退款金额（分）
+	WalletTxID int64                `json:"wallet_tx_id"` // 钱包退款流水ID，全部经渠道原路退回时为0
+	Reason     string               `json:"reason"`       // 退款原因
+	OperatorID int64                `json:"operator_id"`  // 操作人ID
+	Lines      []OrderRefundLine    `json:"lines"`        // 退款明细
+	Payments   []OrderRefundPayment `json:"payments"`     // 退款去向
+	CreatedAt  int64                `json:"created_at"`   // 退款时间（Unix时间戳）
+}
+
+// OrderRefundLine 退款明细
+type OrderRefundLine struct {
+	OrderItemID int64 `json:"order_item_id"` // 订单项ID
+	ProductID   int64 `json:"product_id"`    // 产品ID
+	Quantity    int32 `json:"quantity"`      // 退款数量
+	Amount      int64 `json:"amount"`        // 退款金额（分）
+}
+
+// OrderRefundPayment 退款去向：退款金额在一条支付明细上的分摊
+// 在线支付经收款渠道原路退回（Provider 非空），钱包支付退回钱包（WalletTxID 非0），现金、刷卡由门店线下退还（两者均为空）
+type OrderRefundPayment struct {
+	OrderPaymentID   int64  `json:"order_payment_id"`   // 支付明细ID，0表示无对应支付明细
+	Method           string `json:"method"`             // 原支付方式
+	Amount           int64  `json:"amount"`             // 退款金额（分）
+	WalletTxID       int64  `json:"wallet_tx_id"`       // 钱包退款流水ID
+	Provider         string `json:"provider"`           // 退款渠道
+	ProviderRefundNo string `json:"provider_refund_no"` // 渠道退款单号
+}
+
 // Service 订单域服务接口
 // 提供订单相关的核心业务操作，统一事务边界
 type Service interface {
@@ -80,11 +180,24 @@ type Service interface {
 	// 统一事务内完成：
 	// 1. 校验订单状态
 	// 2. 更新订单状态为已退款
-	// 3. 如果原为钱包支付，调用billing域退款
+	// 3. 按支付明细原路退回：在线支付经收款渠道退款，钱包支付调用billing域退回钱包，现金、刷卡记录线下退还
 	// 4. 写入outbox事件
 	// 5. 提交事务
 	RefundOrder(ctx context.Context, orderID int64, reason string) error
 
+	// RefundOrderItems 按订单项部分退款
+	// 统一事务内完成：
+	// 1. 校验订单状态与各订单项的可退数量
+	// 2. 按行计算退款金额（订单级折扣按金额比例分摊）
+	// 3. 按支付明细分摊退款金额并原路退回：在线支付经收款渠道退款，钱包支付调用billing域退回钱包，现金、刷卡记录线下退还
+	// 4. 记录退款单、退款明细及退款去向
+	// 5. 更新支付状态为 partially_refunded，全部退完时订单流转为 refunded
+	// 6. 写入outbox事件
+	RefundOrderItems(ctx context.Context, req PartialRefundReq) (*OrderRefund, error)
+
+	// ListOrderRefunds 查询订单的退款记录
+	ListOrderRefunds(ctx context.Context, orderID int64) ([]OrderRefund, error)
+
 	// GetOrder 获取订单详情
 	// 包含订单基本信息和订单项列表
 	GetOrder(ctx context.Context, orderID int64) (*Order, []OrderItem, error)
@@ -93,6 +206,36 @@ type Service interface {
 	// 支持按客户、状态、时间等条件筛选
 	ListOrders(ctx context.Context, customerID int64, status string, page, pageSize int) ([]Order, error)
 
+	// UpdateOrderStatus 订单状态流转
+	// 统一事务内完成：
+	// 1. 按状态机校验流转合法性
+	// 2. 更新订单状态并记录流转日志（操作人、时间、原因）
+	// 3. 写入对应的 order.* outbox 事件
+	// 流转到 refunded 时走 RefundOrder 的退款流程；paid 不能手工指定，只能由 AddOrderPayments 付清时流转
+	// 取消部分付款的订单时，已付金额按支付明细原路退回并生成退款单
+	UpdateOrderStatus(ctx context.Context, req UpdateOrderStatusReq) (*Order, error)
+
+	// GetOrderStatusHistory 获取订单状态流转记录
+	// 按时间正序返回
+	GetOrderStatusHistory(ctx context.Context, orderID int64) ([]OrderStatusLog, error)
+
+	// AddOrderPayments 追加订单支付明细
+	// 统一事务内完成：
+	// 1. 校验支付金额不超过剩余应付金额
+	// 2. 钱包部分按行调用billing域扣款（每行独立幂等键）
+	// 3. 记录支付明细，并按已付合计重算 payment_status（unpaid/partially_paid/paid）
+	// 4. 付清时订单流转为 paid 并写入 order.paid 事件
+	AddOrderPayments(ctx context.Context, req AddOrderPaymentReq) ([]OrderPayment, error)
+
+	// ListOrderPayments 查询订单支付明细
+	ListOrderPayments(ctx context.Context, orderID int64) ([]OrderPayment, error)
+
+	// CancelExpiredOrders 取消超时未支付订单
+	// 取消 before 之前创建且仍为 pending 的订单，每单独立事务：
+	// 状态流转为 cancelled + 回补库存 + 写入 order.cancelled 事件
+	// 返回本次成功取消的订单数，limit 限制单次处理数量
+	CancelExpiredOrders(ctx context.Context, before time.Time, limit int) (int, error)
+
 	// 控制器接口 - 兼容现有控制器
 	CreateOrder(ctx context.Context, req *CreateOrderRequest) (*OrderResponse, error)
 	GetOrderByID(ctx context.Context, idStr string) (*OrderResponse, error)
@@ -103,14 +246,17 @@ type Service interface {
 type CreateOrderRequest struct {
 	CustomerID int64                    `json:"customer_id" binding:"required"`
 	Items      []CreateOrderItemRequest `json:"items" binding:"required"`
+	Payments   []PaymentLineReq         `json:"payments"`    // 组合支付明细（分），为空时整单钱包支付
+	CouponCode string                   `json:"coupon_code"` // 优惠券码（可选）
 	Remark     string                   `json:"remark"`
 }
 
 // CreateOrderItemRequest 创建订单项请求
 type CreateOrderItemRequest struct {
-	ProductID int64   `json:"product_id" binding:"required"`
-	Quantity  int     `json:"quantity" binding:"required"`
-	Price     float64 `json:"price" binding:"required"`
+	ProductID  int64   `json:"product_id" binding:"required"`
+	Quantity   int     `json:"quantity" binding:"required"`
+	Price      float64 `json:"price" binding:"required"`
+	UsePackage bool    `json:"use_package"` // 使用客户次卡套餐抵扣
 }
 
 // OrderResponse 订单响应
@@ -119,6 +265,7 @@ type OrderResponse struct {
 	OrderNo     string              `json:"order_no"`
 	CustomerID  int64               `json:"customer_id"`
 	TotalAmount float64             `json:"total_amount"`
+	FinalAmount float64             `json:"final_amount"` // 扣除折扣与优惠券后的应付金额
 	Status      string              `json:"status"`
 	Items       []OrderItemResponse `json:"items"`
 	CreatedAt   int64               `json:"created_at"`
@@ -135,19 +282,31 @@ type OrderItemResponse struct {
 }
 
 // ListOrdersRequest 订单列表请求
+// 传入 Cursor 时按游标翻页（忽略 Page，不统计总数），适用于大批量导出
 type ListOrdersRequest struct {
-	CustomerID int64  `json:"customer_id,omitempty"`
-	Status     string `json:"status,omitempty"`
-	Page       int    `json:"page"`
-	PageSize   int    `json:"page_size"`
+	CustomerID    int64     `json:"customer_id,omitempty"`
+	Status        string    `json:"status,omitempty"`
+	AssignedTo    int64     `json:"assigned_to,omitempty"`     // 负责员工ID
+	PaymentStatus string    `json:"payment_status,omitempty"`  // 支付状态
+	PaymentMethod string    `json:"payment_method,omitempty"`  // 订单级支付方式
+	OrderDateFrom time.Time `json:"order_date_from,omitempty"` // 下单时间起（含）
+	OrderDateTo   time.Time `json:"order_date_to,omitempty"`   // 下单时间止（不含）
+	MinAmount     int64     `json:"min_amount,omitempty"`      // 最终金额下限（分，含）
+	MaxAmount     int64     `json:"max_amount,omitempty"`      // 最终金额上限（分，含）
+	OrderNoPrefix string    `json:"order_no_prefix,omitempty"` // 订单号前缀
+	OrderBy       string    `json:"order_by,omitempty"`        // 排序：order_date/final_amount/id + _asc/_desc，默认 order_date_desc
+	Cursor        string    `json:"cursor,omitempty"`          // 游标，取自上一页响应的 NextCursor
+	Page          int       `json:"page"`
+	PageSize      int       `json:"page_size"`
 }
 
 // ListOrdersResponse 订单列表响应
 type ListOrdersResponse struct {
-	Orders   []OrderResponse `json:"orders"`
-	Total    int64           `json:"total"`
-	Page     int             `json:"page"`
-	PageSize int             `json:"page_size"`
+	Orders     []OrderResponse `json:"orders"`
+	Total      int64           `json:"total"` // 游标模式下为 -1（不统计）
+	Page       int             `json:"page"`
+	PageSize   int             `json:"page_size"`
+	NextCursor string          `json:"next_cursor,omitempty"` // 下一页游标，为空表示没有更多数据
 }
 
 // Repository 订单域数据访问接口
@@ -0,0 +1,65 @@
+package sales
+
+import "crm_lite/internal/constants"
+
+// orderTransitions 订单状态机
+// key 为当前状态，value 为允许流转到的目标状态
+// 终态（cancelled、refunded）不允许再流转
+var orderTransitions = map[string][]string{
+	string(constants.OrderStatusDraft): {
+		string(constants.OrderStatusPending),
+		string(constants.OrderStatusConfirmed),
+		string(constants.OrderStatusCancelled),
+	},
+	string(constants.OrderStatusPending): {
+		string(constants.OrderStatusConfirmed),
+		string(constants.OrderStatusPaid),
+		string(constants.OrderStatusCancelled),
+	},
+	string(constants.OrderStatusConfirmed): {
+		string(constants.OrderStatusPaid),
+		string(constants.OrderStatusProcessing),
+		string(constants.OrderStatusCancelled),
+	},
+	string(constants.OrderStatusPaid): {
+		string(constants.OrderStatusProcessing),
+		string(constants.OrderStatusCompleted),
+		string(constants.OrderStatusRefunded),
+	},
+	string(constants.OrderStatusProcessing): {
+		string(constants.OrderStatusShipped),
+		string(constants.OrderStatusCompleted),
+	},
+	string(constants.OrderStatusShipped): {
+		string(constants.OrderStatusCompleted),
+	},
+	string(constants.OrderStatusCompleted): {
+		string(constants.OrderStatusRefunded),
+	},
+	string(constants.OrderStatusCancelled): {},
+	string(constants.OrderStatusRefunded):  {},
+}
+
+// CanTransition 判断订单能否从 from 状态流转到 to 状态
+func CanTransition(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTransitions 返回当前状态允许流转到的目标状态列表
+func AllowedTransitions(from string) []string {
+	next := orderTransitions[from]
+	result := make([]string, len(next))
+	copy(result, next)
+	return result
+}
+
+// IsTerminalStatus 判断是否为终态
+func IsTerminalStatus(status string) bool {
+	next, ok := orderTransitions[status]
+	return ok && len(next) == 0
+}
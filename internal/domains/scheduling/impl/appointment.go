@@ -0,0 +1,294 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/domains/sales"
+	"crm_lite/internal/domains/scheduling"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BookAppointment 预约
+// 时长取自产品服务时长并快照到预约，员工行加锁保证同一员工的冲突检测串行执行
+func (s *SchedulingServiceImpl) BookAppointment(ctx context.Context, req scheduling.BookAppointmentRequest) (*scheduling.Appointment, error) {
+	start := req.StartAt.Truncate(time.Minute)
+	if start.Before(time.Now()) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "不能预约已过去的时间")
+	}
+
+	product, err := s.bookableProduct(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	end := start.Add(time.Duration(product.DurationMin) * time.Minute)
+
+	count, err := s.q.Customer.WithContext(ctx).Where(s.q.Customer.ID.Eq(req.CustomerID)).Count()
+	if err != nil {
+		return nil, fmt.Errorf("查询客户失败: %w", err)
+	}
+	if count == 0 {
+		return nil, common.NewBusinessError(common.ErrCodeCustomerNotFound, "客户不存在")
+	}
+
+	now := time.Now().Unix()
+	record := &AppointmentRecord{
+		CustomerID:  req.CustomerID,
+		StaffID:     req.StaffID,
+		ProductID:   product.ID,
+		ProductName: product.Name,
+		DurationMin: product.DurationMin,
+		StartAt:     start.Unix(),
+		EndAt:       end.Unix(),
+		Status:      string(constants.AppointmentStatusBooked),
+		Remark:      req.Remark,
+		CreatedBy:   req.OperatorID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.lockStaff(ctx, req.StaffID); err != nil {
+			return err
+		}
+		if err := s.checkSlot(ctx, req.StaffID, start, end, 0); err != nil {
+			return err
+		}
+		if err := s.tx.GetDB(ctx).WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建预约失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toAppointment(record), nil
+}
+
+// RescheduleAppointment 改约，可同时更换员工
+// 服务时长沿用预约时的快照
+func (s *SchedulingServiceImpl) RescheduleAppointment(ctx context.Context, req scheduling.RescheduleAppointmentRequest) (*scheduling.Appointment, error) {
+	start := req.StartAt.Truncate(time.Minute)
+	if start.Before(time.Now()) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "不能改约到已过去的时间")
+	}
+
+	var result *scheduling.Appointment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		record, err := s.getAppointmentForUpdate(ctx, req.AppointmentID)
+		if err != nil {
+			return err
+		}
+		if record.Status != string(constants.AppointmentStatusBooked) {
+			return common.NewBusinessError(common.ErrCodeAppointmentStatusInvalid, "仅未完成的预约可以改约")
+		}
+
+		staffID := record.StaffID
+		if req.StaffID > 0 {
+			staffID = req.StaffID
+		}
+		end := start.Add(time.Duration(record.DurationMin) * time.Minute)
+
+		if err := s.lockStaff(ctx, staffID); err != nil {
+			return err
+		}
+		if err := s.checkSlot(ctx, staffID, start, end, record.ID); err != nil {
+			return err
+		}
+
+		record.StaffID = staffID
+		record.StartAt = start.Unix()
+		record.EndAt = end.Unix()
+		record.UpdatedAt = time.Now().Unix()
+		if err := s.tx.GetDB(ctx).WithContext(ctx).Model(&AppointmentRecord{}).Where("id = ?", record.ID).
+			Updates(map[string]interface{}{
+				"staff_id":   record.StaffID,
+				"start_at":   record.StartAt,
+				"end_at":     record.EndAt,
+				"updated_at": record.UpdatedAt,
+			}).Error; err != nil {
+			return fmt.Errorf("更新预约失败: %w", err)
+		}
+		result = toAppointment(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CancelAppointment 取消预约，释放员工时段
+func (s *SchedulingServiceImpl) CancelAppointment(ctx context.Context, appointmentID int64, reason string) (*scheduling.Appointment, error) {
+	var result *scheduling.Appointment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		record, err := s.getAppointmentForUpdate(ctx, appointmentID)
+		if err != nil {
+			return err
+		}
+		if record.Status != string(constants.AppointmentStatusBooked) {
+			return common.NewBusinessError(common.ErrCodeAppointmentStatusInvalid, "仅未完成的预约可以取消")
+		}
+
+		record.Status = string(constants.AppointmentStatusCancelled)
+		record.CancelReason = reason
+		record.UpdatedAt = time.Now().Unix()
+		if err := s.tx.GetDB(ctx).WithContext(ctx).Model(&AppointmentRecord{}).Where("id = ?", record.ID).
+			Updates(map[string]interface{}{
+				"status":        record.Status,
+				"cancel_reason": record.CancelReason,
+				"updated_at":    record.UpdatedAt,
+			}).Error; err != nil {
+			return fmt.Errorf("取消预约失败: %w", err)
+		}
+		result = toAppointment(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CompleteAppointment 完成预约并通过 PlaceOrder 生成订单
+// 下单与预约状态更新在同一事务中，下单失败（如余额不足）时预约保持 booked
+func (s *SchedulingServiceImpl) CompleteAppointment(ctx context.Context, req scheduling.CompleteAppointmentRequest) (*scheduling.Appointment, error) {
+	var result *scheduling.Appointment
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		record, err := s.getAppointmentForUpdate(ctx, req.AppointmentID)
+		if err != nil {
+			return err
+		}
+		if record.Status != string(constants.AppointmentStatusBooked) {
+			return common.NewBusinessError(common.ErrCodeAppointmentStatusInvalid, "仅未完成的预约可以完成")
+		}
+
+		order, err := s.salesSvc.PlaceOrder(ctx, sales.PlaceOrderReq{
+			CustomerID: record.CustomerID,
+			Channel:    "appointment",
+			PayMethod:  req.PayMethod,
+			Payments:   req.Payments,
+			Items:      []sales.OrderItemReq{{ProductID: record.ProductID, Qty: 1, UsePackage: req.UsePackage}},
+			CouponCode: req.CouponCode,
+			SourceRef:  &sales.SourceRef{Type: "appointment", ID: record.ID},
+			IdemKey:    fmt.Sprintf("appointment:%d", record.ID),
+			Remark:     record.Remark,
+			AssignedTo: record.StaffID,
+		})
+		if err != nil {
+			return err
+		}
+
+		record.Status = string(constants.AppointmentStatusCompleted)
+		record.OrderID = order.ID
+		record.UpdatedAt = time.Now().Unix()
+		if err := s.tx.GetDB(ctx).WithContext(ctx).Model(&AppointmentRecord{}).Where("id = ?", record.ID).
+			Updates(map[string]interface{}{
+				"status":     record.Status,
+				"order_id":   record.OrderID,
+				"updated_at": record.UpdatedAt,
+			}).Error; err != nil {
+			return fmt.Errorf("更新预约失败: %w", err)
+		}
+		result = toAppointment(record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetAppointment 获取预约详情
+func (s *SchedulingServiceImpl) GetAppointment(ctx context.Context, appointmentID int64) (*scheduling.Appointment, error) {
+	var record AppointmentRecord
+	if err := s.db.WithContext(ctx).Where("id = ?", appointmentID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeAppointmentNotFound, "预约不存在")
+		}
+		return nil, fmt.Errorf("查询预约失败: %w", err)
+	}
+	return toAppointment(&record), nil
+}
+
+// ListAppointments 分页查询预约，按开始时间升序
+func (s *SchedulingServiceImpl) ListAppointments(ctx context.Context, req scheduling.ListAppointmentsRequest) ([]scheduling.Appointment, int64, error) {
+	db := s.db.WithContext(ctx).Model(&AppointmentRecord{})
+	if req.StaffID > 0 {
+		db = db.Where("staff_id = ?", req.StaffID)
+	}
+	if req.CustomerID > 0 {
+		db = db.Where("customer_id = ?", req.CustomerID)
+	}
+	if req.Status != "" {
+		db = db.Where("status = ?", req.Status)
+	}
+	if !req.From.IsZero() {
+		db = db.Where("start_at >= ?", req.From.Unix())
+	}
+	if !req.To.IsZero() {
+		db = db.Where("start_at < ?", req.To.Unix())
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询预约总数失败: %w", err)
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	var records []AppointmentRecord
+	if err := db.Order("start_at ASC, id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&records).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询预约列表失败: %w", err)
+	}
+
+	result := make([]scheduling.Appointment, len(records))
+	for i := range records {
+		result[i] = *toAppointment(&records[i])
+	}
+	return result, total, nil
+}
+
+// getAppointmentForUpdate 在事务中锁定预约
+func (s *SchedulingServiceImpl) getAppointmentForUpdate(ctx context.Context, appointmentID int64) (*AppointmentRecord, error) {
+	var record AppointmentRecord
+	if err := s.tx.GetDB(ctx).WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", appointmentID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.NewBusinessError(common.ErrCodeAppointmentNotFound, "预约不存在")
+		}
+		return nil, fmt.Errorf("查询预约失败: %w", err)
+	}
+	return &record, nil
+}
+
+func toAppointment(r *AppointmentRecord) *scheduling.Appointment {
+	return &scheduling.Appointment{
+		ID:           r.ID,
+		CustomerID:   r.CustomerID,
+		StaffID:      r.StaffID,
+		ProductID:    r.ProductID,
+		ProductName:  r.ProductName,
+		DurationMin:  r.DurationMin,
+		StartAt:      r.StartAt,
+		EndAt:        r.EndAt,
+		Status:       r.Status,
+		OrderID:      r.OrderID,
+		Remark:       r.Remark,
+		CancelReason: r.CancelReason,
+		CreatedBy:    r.CreatedBy,
+		CreatedAt:    r.CreatedAt,
+		UpdatedAt:    r.UpdatedAt,
+	}
+}
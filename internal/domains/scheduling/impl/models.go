@@ -0,0 +1,46 @@
+package impl
+
+// StaffAvailabilityRecord 映射 staff_availabilities（员工每周可预约时间）
+type StaffAvailabilityRecord struct {
+	ID          int64 `gorm:"column:id;primaryKey;autoIncrement"`
+	StaffID     int64 `gorm:"column:staff_id;index:idx_availability_staff_weekday,priority:1;not null"`
+	Weekday     int   `gorm:"column:weekday;index:idx_availability_staff_weekday,priority:2;not null"`
+	StartMinute int   `gorm:"column:start_minute;not null"` // 当天第几分钟开始
+	EndMinute   int   `gorm:"column:end_minute;not null"`   // 当天第几分钟结束
+	CreatedAt   int64 `gorm:"column:created_at;not null"`
+}
+
+func (StaffAvailabilityRecord) TableName() string { return "staff_availabilities" }
+
+// StaffTimeOffRecord 映射 staff_time_offs（员工休息时段）
+type StaffTimeOffRecord struct {
+	ID        int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	StaffID   int64  `gorm:"column:staff_id;index:idx_time_off_staff_start,priority:1;not null"`
+	StartAt   int64  `gorm:"column:start_at;index:idx_time_off_staff_start,priority:2;not null"`
+	EndAt     int64  `gorm:"column:end_at;not null"`
+	Reason    string `gorm:"column:reason;size:255"`
+	CreatedAt int64  `gorm:"column:created_at;not null"`
+}
+
+func (StaffTimeOffRecord) TableName() string { return "staff_time_offs" }
+
+// AppointmentRecord 映射 appointments（预约）
+type AppointmentRecord struct {
+	ID           int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	CustomerID   int64  `gorm:"column:customer_id;index:idx_appointment_customer;not null"`
+	StaffID      int64  `gorm:"column:staff_id;index:idx_appointment_staff_start,priority:1;not null"`
+	ProductID    int64  `gorm:"column:product_id;not null"`
+	ProductName  string `gorm:"column:product_name;size:255;not null"`
+	DurationMin  int32  `gorm:"column:duration_min;not null"`
+	StartAt      int64  `gorm:"column:start_at;index:idx_appointment_staff_start,priority:2;not null"`
+	EndAt        int64  `gorm:"column:end_at;not null"`
+	Status       string `gorm:"column:status;size:20;not null"`
+	OrderID      int64  `gorm:"column:order_id;not null;default:0"`
+	Remark       string `gorm:"column:remark;size:255"`
+	CancelReason string `gorm:"column:cancel_reason;size:255"`
+	CreatedBy    int64  `gorm:"column:created_by;not null;default:0"`
+	CreatedAt    int64  `gorm:"column:created_at;not null"`
+	UpdatedAt    int64  `gorm:"column:updated_at;not null"`
+}
+
+func (AppointmentRecord) TableName() string { return "appointments" }
@@ -0,0 +1,19 @@
+package impl
+
+import (
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/query"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/sales"
+	"crm_lite/internal/domains/scheduling"
+
+	"gorm.io/gorm"
+)
+
+// NewSchedulingService 创建预约排班服务实例
+// 完成预约时通过 salesSvc 下单，事务上下文在两个域之间共享
+func NewSchedulingService(db *gorm.DB, salesSvc sales.Service) scheduling.Service {
+	tx := common.NewTx(db)
+	catalogService := catalogImpl.NewWithTx(query.Use(db), tx)
+	return NewSchedulingServiceImpl(db, tx, catalogService, salesSvc)
+}
@@ -0,0 +1,254 @@
+package impl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/dao/query"
+	catalogImpl "crm_lite/internal/domains/catalog/impl"
+	"crm_lite/internal/domains/sales"
+	"crm_lite/internal/domains/scheduling"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// stubSalesService 仅实现 PlaceOrder，记录下单请求
+type stubSalesService struct {
+	sales.Service
+	placed []sales.PlaceOrderReq
+	err    error
+}
+
+func (s *stubSalesService) PlaceOrder(ctx context.Context, req sales.PlaceOrderReq) (sales.Order, error) {
+	if s.err != nil {
+		return sales.Order{}, s.err
+	}
+	s.placed = append(s.placed, req)
+	return sales.Order{ID: int64(900 + len(s.placed)), CustomerID: req.CustomerID, Status: "pending"}, nil
+}
+
+func newSchedulingTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Exec(`
+		CREATE TABLE admin_users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			uuid TEXT NOT NULL,
+			username TEXT NOT NULL,
+			email TEXT NOT NULL,
+			password_hash TEXT NOT NULL,
+			real_name TEXT,
+			phone TEXT,
+			avatar TEXT,
+			manager_id INTEGER DEFAULT 0,
+			is_active BOOLEAN DEFAULT 1,
+			last_login_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE customers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			phone TEXT,
+			email TEXT,
+			gender TEXT DEFAULT 'unknown',
+			birthday DATETIME,
+			level TEXT DEFAULT '普通',
+			tags TEXT,
+			note TEXT,
+			source TEXT DEFAULT 'manual',
+			assigned_to INTEGER DEFAULT 0,
+			deleted_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`).Error)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE products (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			description TEXT,
+			type TEXT DEFAULT 'product',
+			category TEXT,
+			price DECIMAL(10,2) NOT NULL DEFAULT 0,
+			cost DECIMAL(10,2) DEFAULT 0,
+			stock_quantity INTEGER DEFAULT 0,
+			min_stock_level INTEGER DEFAULT 0,
+			unit TEXT DEFAULT '个',
+			duration_min INTEGER NOT NULL DEFAULT 0,
+			is_active BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
+		)
+	`).Error)
+	require.NoError(t, db.AutoMigrate(&StaffAvailabilityRecord{}, &StaffTimeOffRecord{}, &AppointmentRecord{}))
+	return db
+}
+
+// TestAppointmentBooking 预约排班单元测试
+// 覆盖可预约时段计算、员工冲突检测、改约/取消以及完成预约转订单
+func TestAppointmentBooking(t *testing.T) {
+	db := newSchedulingTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.Exec(`INSERT INTO admin_users (uuid, username, email, password_hash, is_active) VALUES
+		('u-1', 'stylist_a', 'a@example.com', 'x', 1),
+		('u-2', 'stylist_b', 'b@example.com', 'x', 1)`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO customers (name) VALUES ('预约客户')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO products (name, type, category, price, duration_min, is_active) VALUES
+		('精剪', 'service', '美发', 88.00, 60, 1),
+		('洗发水', 'product', '洗护', 45.00, 0, 1)`).Error)
+	const staffA, staffB, customerID, haircutID, shampooID = int64(1), int64(2), int64(1), int64(1), int64(2)
+
+	tx := common.NewTx(db)
+	salesStub := &stubSalesService{}
+	svc := NewSchedulingServiceImpl(db, tx, catalogImpl.NewWithTx(query.Use(db), tx), salesStub)
+
+	day := time.Now().AddDate(0, 0, 1)
+	at := func(hour, minute int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, time.Local)
+	}
+	assertCode := func(t *testing.T, err error, code string) {
+		var businessErr *common.BusinessError
+		require.ErrorAs(t, err, &businessErr)
+		assert.Equal(t, code, businessErr.Code)
+	}
+	book := func(staffID, productID int64, start time.Time) (*scheduling.Appointment, error) {
+		return svc.BookAppointment(ctx, scheduling.BookAppointmentRequest{
+			CustomerID: customerID, StaffID: staffID, ProductID: productID, StartAt: start, OperatorID: 1,
+		})
+	}
+
+	weekday := int(day.Weekday())
+	require.NoError(t, svc.SetStaffAvailability(ctx, staffA, []scheduling.AvailabilityRule{
+		{Weekday: weekday, StartTime: "09:00", EndTime: "12:00"},
+	}))
+	require.NoError(t, svc.SetStaffAvailability(ctx, staffB, []scheduling.AvailabilityRule{
+		{Weekday: weekday, StartTime: "09:00", EndTime: "18:00"},
+	}))
+
+	t.Run("排班校验", func(t *testing.T) {
+		err := svc.SetStaffAvailability(ctx, staffA, []scheduling.AvailabilityRule{
+			{Weekday: 1, StartTime: "09:00", EndTime: "12:00"},
+			{Weekday: 1, StartTime: "11:00", EndTime: "14:00"},
+		})
+		assertCode(t, err, common.ErrCodeInvalidParam)
+		assertCode(t, svc.SetStaffAvailability(ctx, 99, nil), common.ErrCodeResourceNotFound)
+
+		rules, err := svc.GetStaffAvailability(ctx, staffA)
+		require.NoError(t, err)
+		assert.Equal(t, []scheduling.AvailabilityRule{{Weekday: weekday, StartTime: "09:00", EndTime: "12:00"}}, rules, "校验失败不影响原排班")
+	})
+
+	t.Run("按服务时长计算时段并检测冲突", func(t *testing.T) {
+		slots, err := svc.ListAvailableSlots(ctx, scheduling.ListSlotsRequest{StaffID: staffA, ProductID: haircutID, Date: day})
+		require.NoError(t, err)
+		require.Len(t, slots, 9, "09:00-11:00 每 15 分钟一个 60 分钟时段")
+		assert.Equal(t, at(9, 0).Unix(), slots[0].StartAt)
+		assert.Equal(t, at(12, 0).Unix(), slots[8].EndAt)
+
+		appointment, err := book(staffA, haircutID, at(10, 0))
+		require.NoError(t, err)
+		assert.Equal(t, int32(60), appointment.DurationMin)
+		assert.Equal(t, at(11, 0).Unix(), appointment.EndAt)
+		assert.Equal(t, "精剪", appointment.ProductName)
+
+		slots, err = svc.ListAvailableSlots(ctx, scheduling.ListSlotsRequest{StaffID: staffA, ProductID: haircutID, Date: day})
+		require.NoError(t, err)
+		starts := make([]int64, len(slots))
+		for i, s := range slots {
+			starts[i] = s.StartAt
+		}
+		assert.Equal(t, []int64{at(9, 0).Unix(), at(11, 0).Unix()}, starts)
+
+		_, err = book(staffA, haircutID, at(10, 30))
+		assertCode(t, err, common.ErrCodeAppointmentConflict)
+		_, err = book(staffA, haircutID, at(11, 30))
+		assertCode(t, err, common.ErrCodeStaffUnavailable)
+		_, err = book(staffA, shampooID, at(9, 0))
+		assertCode(t, err, common.ErrCodeInvalidParam)
+
+		// 其他员工同一时段不受影响
+		_, err = book(staffB, haircutID, at(10, 30))
+		require.NoError(t, err)
+	})
+
+	t.Run("休息时段阻止预约", func(t *testing.T) {
+		_, err := svc.AddStaffTimeOff(ctx, scheduling.AddTimeOffRequest{StaffID: staffA, StartAt: at(10, 30), EndAt: at(11, 30)})
+		assertCode(t, err, common.ErrCodeAppointmentConflict)
+
+		timeOff, err := svc.AddStaffTimeOff(ctx, scheduling.AddTimeOffRequest{StaffID: staffA, StartAt: at(11, 0), EndAt: at(12, 0), Reason: "培训"})
+		require.NoError(t, err)
+		_, err = book(staffA, haircutID, at(11, 0))
+		assertCode(t, err, common.ErrCodeAppointmentConflict)
+
+		require.NoError(t, svc.DeleteStaffTimeOff(ctx, staffA, timeOff.ID))
+		assertCode(t, svc.DeleteStaffTimeOff(ctx, staffA, timeOff.ID), common.ErrCodeResourceNotFound)
+	})
+
+	t.Run("改约与取消", func(t *testing.T) {
+		appointment, err := book(staffA, haircutID, at(11, 0))
+		require.NoError(t, err)
+
+		_, err = svc.RescheduleAppointment(ctx, scheduling.RescheduleAppointmentRequest{AppointmentID: appointment.ID, StartAt: at(10, 0)})
+		assertCode(t, err, common.ErrCodeAppointmentConflict)
+
+		moved, err := svc.RescheduleAppointment(ctx, scheduling.RescheduleAppointmentRequest{AppointmentID: appointment.ID, StaffID: staffB, StartAt: at(14, 0)})
+		require.NoError(t, err)
+		assert.Equal(t, staffB, moved.StaffID)
+		assert.Equal(t, at(15, 0).Unix(), moved.EndAt)
+
+		cancelled, err := svc.CancelAppointment(ctx, appointment.ID, "客户临时有事")
+		require.NoError(t, err)
+		assert.Equal(t, "cancelled", cancelled.Status)
+		_, err = svc.CancelAppointment(ctx, appointment.ID, "")
+		assertCode(t, err, common.ErrCodeAppointmentStatusInvalid)
+
+		// 取消后时段释放
+		_, err = book(staffB, haircutID, at(14, 0))
+		require.NoError(t, err)
+	})
+
+	t.Run("完成预约转为订单", func(t *testing.T) {
+		appointment, err := book(staffA, haircutID, at(9, 0))
+		require.NoError(t, err)
+
+		salesStub.err = common.NewBusinessError(common.ErrCodeInsufficientBalance, "余额不足")
+		_, err = svc.CompleteAppointment(ctx, scheduling.CompleteAppointmentRequest{AppointmentID: appointment.ID, PayMethod: "wallet"})
+		assertCode(t, err, common.ErrCodeInsufficientBalance)
+		got, err := svc.GetAppointment(ctx, appointment.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "booked", got.Status, "下单失败预约保持未完成")
+
+		salesStub.err = nil
+		completed, err := svc.CompleteAppointment(ctx, scheduling.CompleteAppointmentRequest{AppointmentID: appointment.ID, PayMethod: "wallet"})
+		require.NoError(t, err)
+		assert.Equal(t, "completed", completed.Status)
+		assert.NotZero(t, completed.OrderID)
+
+		require.Len(t, salesStub.placed, 1)
+		placed := salesStub.placed[0]
+		assert.Equal(t, customerID, placed.CustomerID)
+		assert.Equal(t, staffA, placed.AssignedTo)
+		assert.Equal(t, []sales.OrderItemReq{{ProductID: haircutID, Qty: 1}}, placed.Items)
+		assert.Equal(t, &sales.SourceRef{Type: "appointment", ID: appointment.ID}, placed.SourceRef)
+
+		_, err = svc.CompleteAppointment(ctx, scheduling.CompleteAppointmentRequest{AppointmentID: appointment.ID, PayMethod: "wallet"})
+		assertCode(t, err, common.ErrCodeAppointmentStatusInvalid)
+
+		list, total, err := svc.ListAppointments(ctx, scheduling.ListAppointmentsRequest{StaffID: staffA, Status: "completed"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, completed.OrderID, list[0].OrderID)
+	})
+}
@@ -0,0 +1,386 @@
+package impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"crm_lite/internal/common"
+	"crm_lite/internal/constants"
+	"crm_lite/internal/dao/query"
+	"crm_lite/internal/domains/catalog"
+	"crm_lite/internal/domains/sales"
+	"crm_lite/internal/domains/scheduling"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// slotStepMin 可预约时段的起始间隔（分钟）
+const slotStepMin = 15
+
+// SchedulingServiceImpl 预约排班域服务实现
+type SchedulingServiceImpl struct {
+	db         *gorm.DB
+	q          *query.Query
+	tx         common.Tx
+	catalogSvc catalog.Service
+	salesSvc   sales.Service
+}
+
+// NewSchedulingServiceImpl 创建预约排班服务实例
+// salesSvc 用于完成预约时下单，需与本服务共享事务上下文
+func NewSchedulingServiceImpl(db *gorm.DB, tx common.Tx, catalogSvc catalog.Service, salesSvc sales.Service) *SchedulingServiceImpl {
+	return &SchedulingServiceImpl{
+		db:         db,
+		q:          query.Use(db),
+		tx:         tx,
+		catalogSvc: catalogSvc,
+		salesSvc:   salesSvc,
+	}
+}
+
+// ===== 员工排班 =====
+
+// SetStaffAvailability 整体替换员工每周可预约时间
+// 已有预约不受影响，仅约束之后的预约与改约
+func (s *SchedulingServiceImpl) SetStaffAvailability(ctx context.Context, staffID int64, rules []scheduling.AvailabilityRule) error {
+	records, err := toAvailabilityRecords(staffID, rules)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.lockStaff(ctx, staffID); err != nil {
+			return err
+		}
+
+		txDB := s.tx.GetDB(ctx)
+		if err := txDB.WithContext(ctx).Where("staff_id = ?", staffID).Delete(&StaffAvailabilityRecord{}).Error; err != nil {
+			return fmt.Errorf("清除员工可预约时间失败: %w", err)
+		}
+		if len(records) == 0 {
+			return nil
+		}
+		if err := txDB.WithContext(ctx).Create(&records).Error; err != nil {
+			return fmt.Errorf("保存员工可预约时间失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetStaffAvailability 获取员工每周可预约时间
+func (s *SchedulingServiceImpl) GetStaffAvailability(ctx context.Context, staffID int64) ([]scheduling.AvailabilityRule, error) {
+	var records []StaffAvailabilityRecord
+	if err := s.db.WithContext(ctx).Where("staff_id = ?", staffID).
+		Order("weekday ASC, start_minute ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询员工可预约时间失败: %w", err)
+	}
+
+	rules := make([]scheduling.AvailabilityRule, len(records))
+	for i, r := range records {
+		rules[i] = scheduling.AvailabilityRule{
+			Weekday:   r.Weekday,
+			StartTime: formatClock(r.StartMinute),
+			EndTime:   formatClock(r.EndMinute),
+		}
+	}
+	return rules, nil
+}
+
+// AddStaffTimeOff 新增员工休息时段，与已有预约冲突时拒绝
+func (s *SchedulingServiceImpl) AddStaffTimeOff(ctx context.Context, req scheduling.AddTimeOffRequest) (*scheduling.TimeOff, error) {
+	if !req.EndAt.After(req.StartAt) {
+		return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "结束时间须晚于开始时间")
+	}
+
+	record := &StaffTimeOffRecord{
+		StaffID:   req.StaffID,
+		StartAt:   req.StartAt.Unix(),
+		EndAt:     req.EndAt.Unix(),
+		Reason:    req.Reason,
+		CreatedAt: time.Now().Unix(),
+	}
+	err := s.tx.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.lockStaff(ctx, req.StaffID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := s.overlappingAppointments(ctx, req.StaffID, record.StartAt, record.EndAt, 0).Count(&count).Error; err != nil {
+			return fmt.Errorf("查询员工预约失败: %w", err)
+		}
+		if count > 0 {
+			return common.NewBusinessError(common.ErrCodeAppointmentConflict, "该时段员工已有预约，请先改约或取消")
+		}
+
+		if err := s.tx.GetDB(ctx).WithContext(ctx).Create(record).Error; err != nil {
+			return fmt.Errorf("创建员工休息时段失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toTimeOff(record), nil
+}
+
+// ListStaffTimeOffs 查询与 [from, to) 有交集的员工休息时段
+func (s *SchedulingServiceImpl) ListStaffTimeOffs(ctx context.Context, staffID int64, from, to time.Time) ([]scheduling.TimeOff, error) {
+	db := s.db.WithContext(ctx).Where("staff_id = ?", staffID)
+	if !from.IsZero() {
+		db = db.Where("end_at > ?", from.Unix())
+	}
+	if !to.IsZero() {
+		db = db.Where("start_at < ?", to.Unix())
+	}
+
+	var records []StaffTimeOffRecord
+	if err := db.Order("start_at ASC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询员工休息时段失败: %w", err)
+	}
+
+	result := make([]scheduling.TimeOff, len(records))
+	for i := range records {
+		result[i] = *toTimeOff(&records[i])
+	}
+	return result, nil
+}
+
+// DeleteStaffTimeOff 删除员工休息时段
+func (s *SchedulingServiceImpl) DeleteStaffTimeOff(ctx context.Context, staffID, timeOffID int64) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND staff_id = ?", timeOffID, staffID).Delete(&StaffTimeOffRecord{})
+	if result.Error != nil {
+		return fmt.Errorf("删除员工休息时段失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return common.NewBusinessError(common.ErrCodeResourceNotFound, "休息时段不存在")
+	}
+	return nil
+}
+
+// ListAvailableSlots 按服务时长计算员工某天的可预约时段
+func (s *SchedulingServiceImpl) ListAvailableSlots(ctx context.Context, req scheduling.ListSlotsRequest) ([]scheduling.TimeSlot, error) {
+	product, err := s.bookableProduct(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	duration := int(product.DurationMin)
+
+	date := req.Date.In(time.Local)
+	dayStart := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	dayEnd := dayStart.AddDate(0, 0, 1)
+
+	var rules []StaffAvailabilityRecord
+	if err := s.db.WithContext(ctx).Where("staff_id = ? AND weekday = ?", req.StaffID, int(dayStart.Weekday())).
+		Order("start_minute ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询员工可预约时间失败: %w", err)
+	}
+	if len(rules) == 0 {
+		return []scheduling.TimeSlot{}, nil
+	}
+
+	// 当天已占用的时段：有效预约 + 休息
+	var appointments []AppointmentRecord
+	if err := s.overlappingAppointments(ctx, req.StaffID, dayStart.Unix(), dayEnd.Unix(), 0).
+		Find(&appointments).Error; err != nil {
+		return nil, fmt.Errorf("查询员工预约失败: %w", err)
+	}
+	var timeOffs []StaffTimeOffRecord
+	if err := s.overlappingTimeOffs(ctx, req.StaffID, dayStart.Unix(), dayEnd.Unix()).
+		Find(&timeOffs).Error; err != nil {
+		return nil, fmt.Errorf("查询员工休息时段失败: %w", err)
+	}
+	busy := make([][2]int64, 0, len(appointments)+len(timeOffs))
+	for _, a := range appointments {
+		busy = append(busy, [2]int64{a.StartAt, a.EndAt})
+	}
+	for _, t := range timeOffs {
+		busy = append(busy, [2]int64{t.StartAt, t.EndAt})
+	}
+
+	now := time.Now().Unix()
+	slots := make([]scheduling.TimeSlot, 0)
+	for _, rule := range rules {
+		for m := rule.StartMinute; m+duration <= rule.EndMinute; m += slotStepMin {
+			start := time.Date(dayStart.Year(), dayStart.Month(), dayStart.Day(), 0, m, 0, 0, time.Local).Unix()
+			end := start + int64(duration)*60
+			if start < now || overlapsAny(busy, start, end) {
+				continue
+			}
+			slots = append(slots, scheduling.TimeSlot{StartAt: start, EndAt: end})
+		}
+	}
+	return slots, nil
+}
+
+// ===== 内部辅助 =====
+
+// lockStaff 锁定员工行，串行化同一员工的排班与预约变更
+func (s *SchedulingServiceImpl) lockStaff(ctx context.Context, staffID int64) error {
+	txQuery := query.Use(s.tx.GetDB(ctx))
+	staff, err := txQuery.AdminUser.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where(txQuery.AdminUser.ID.Eq(staffID)).First()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return common.NewBusinessError(common.ErrCodeResourceNotFound, "员工不存在")
+		}
+		return fmt.Errorf("查询员工失败: %w", err)
+	}
+	if !staff.IsActive {
+		return common.NewBusinessError(common.ErrCodeStaffUnavailable, "员工已停用")
+	}
+	return nil
+}
+
+// bookableProduct 获取可预约的服务产品：须可售、为 service 类型且配置了服务时长
+func (s *SchedulingServiceImpl) bookableProduct(ctx context.Context, productID int64) (catalog.Product, error) {
+	product, err := s.catalogSvc.Get(ctx, productID)
+	if err != nil {
+		return catalog.Product{}, common.NewBusinessError(common.ErrCodeProductNotFound, "产品不存在")
+	}
+	if err := s.catalogSvc.EnsureSellable(ctx, productID); err != nil {
+		return catalog.Product{}, common.NewBusinessError(common.ErrCodeProductNotSellable, "产品不可售")
+	}
+	if product.Type != string(constants.ProductTypeService) || product.DurationMin <= 0 {
+		return catalog.Product{}, common.NewBusinessError(common.ErrCodeInvalidParam, "该产品不是可预约的服务项目")
+	}
+	return product, nil
+}
+
+// checkSlot 校验员工在 [start, end) 可接受预约
+// 时段须落在当天某一段可预约时间内，且与休息时段、其他有效预约不重叠
+func (s *SchedulingServiceImpl) checkSlot(ctx context.Context, staffID int64, start, end time.Time, excludeID int64) error {
+	// 跨天的时段 endMinute 超过 24:00，不会被任何可预约时间覆盖
+	localStart := start.In(time.Local)
+	startMinute := localStart.Hour()*60 + localStart.Minute()
+	endMinute := startMinute + int(end.Sub(start)/time.Minute)
+
+	txDB := s.tx.GetDB(ctx)
+	var covered int64
+	if err := txDB.WithContext(ctx).Model(&StaffAvailabilityRecord{}).
+		Where("staff_id = ? AND weekday = ? AND start_minute <= ? AND end_minute >= ?",
+			staffID, int(localStart.Weekday()), startMinute, endMinute).
+		Count(&covered).Error; err != nil {
+		return fmt.Errorf("查询员工可预约时间失败: %w", err)
+	}
+	if covered == 0 {
+		return common.NewBusinessError(common.ErrCodeStaffUnavailable, "不在员工可预约时间内")
+	}
+
+	var count int64
+	if err := s.overlappingTimeOffs(ctx, staffID, start.Unix(), end.Unix()).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询员工休息时段失败: %w", err)
+	}
+	if count > 0 {
+		return common.NewBusinessError(common.ErrCodeAppointmentConflict, "员工该时段休息")
+	}
+
+	if err := s.overlappingAppointments(ctx, staffID, start.Unix(), end.Unix(), excludeID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询员工预约失败: %w", err)
+	}
+	if count > 0 {
+		return common.NewBusinessError(common.ErrCodeAppointmentConflict, "员工该时段已有预约")
+	}
+	return nil
+}
+
+// overlappingAppointments 与 [start, end) 重叠的员工有效预约
+func (s *SchedulingServiceImpl) overlappingAppointments(ctx context.Context, staffID, start, end, excludeID int64) *gorm.DB {
+	db := s.tx.GetDB(ctx).WithContext(ctx).Model(&AppointmentRecord{}).
+		Where("staff_id = ? AND status = ? AND start_at < ? AND end_at > ?",
+			staffID, string(constants.AppointmentStatusBooked), end, start)
+	if excludeID > 0 {
+		db = db.Where("id <> ?", excludeID)
+	}
+	return db
+}
+
+// overlappingTimeOffs 与 [start, end) 重叠的员工休息时段
+func (s *SchedulingServiceImpl) overlappingTimeOffs(ctx context.Context, staffID, start, end int64) *gorm.DB {
+	return s.tx.GetDB(ctx).WithContext(ctx).Model(&StaffTimeOffRecord{}).
+		Where("staff_id = ? AND start_at < ? AND end_at > ?", staffID, end, start)
+}
+
+// toAvailabilityRecords 校验并转换每周可预约时间，同一天内的时间段不能重叠
+func toAvailabilityRecords(staffID int64, rules []scheduling.AvailabilityRule) ([]StaffAvailabilityRecord, error) {
+	now := time.Now().Unix()
+	records := make([]StaffAvailabilityRecord, len(rules))
+	for i, rule := range rules {
+		if rule.Weekday < 0 || rule.Weekday > 6 {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的星期: %d", rule.Weekday))
+		}
+		start, err := parseClock(rule.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(rule.EndTime)
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "结束时间须晚于开始时间")
+		}
+		records[i] = StaffAvailabilityRecord{
+			StaffID:     staffID,
+			Weekday:     rule.Weekday,
+			StartMinute: start,
+			EndMinute:   end,
+			CreatedAt:   now,
+		}
+	}
+
+	sorted := append([]StaffAvailabilityRecord(nil), records...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Weekday != sorted[j].Weekday {
+			return sorted[i].Weekday < sorted[j].Weekday
+		}
+		return sorted[i].StartMinute < sorted[j].StartMinute
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Weekday == sorted[i-1].Weekday && sorted[i].StartMinute < sorted[i-1].EndMinute {
+			return nil, common.NewBusinessError(common.ErrCodeInvalidParam, "同一天的可预约时间段不能重叠")
+		}
+	}
+	return records, nil
+}
+
+// parseClock 解析 HH:MM，返回当天第几分钟；24:00 表示当天结束
+func parseClock(v string) (int, error) {
+	if v == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, common.NewBusinessError(common.ErrCodeInvalidParam, fmt.Sprintf("无效的时间格式: %s，应为 HH:MM", v))
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minute int) string {
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
+
+func overlapsAny(busy [][2]int64, start, end int64) bool {
+	for _, b := range busy {
+		if b[0] < end && b[1] > start {
+			return true
+		}
+	}
+	return false
+}
+
+func toTimeOff(r *StaffTimeOffRecord) *scheduling.TimeOff {
+	return &scheduling.TimeOff{
+		ID:        r.ID,
+		StaffID:   r.StaffID,
+		StartAt:   r.StartAt,
+		EndAt:     r.EndAt,
+		Reason:    r.Reason,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+var _ scheduling.Service = (*SchedulingServiceImpl)(nil)
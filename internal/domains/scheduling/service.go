@@ -0,0 +1,148 @@
+// Package scheduling 预约排班域服务接口
+// 职责：员工可预约时间维护、按服务时长计算可预约时段、员工时段冲突检测、预约生命周期管理
+// 核心原则：预约时长取自产品服务时长快照；到店完成的预约通过 sales 域 PlaceOrder 转为订单
+package scheduling
+
+import (
+	"context"
+	"time"
+
+	"crm_lite/internal/domains/sales"
+)
+
+// AvailabilityRule 员工每周固定可预约时间
+// 同一天可配置多段（如上午、下午），时间按服务器本地时区解释
+type AvailabilityRule struct {
+	Weekday   int    `json:"weekday"`    // 星期：0=周日，1=周一 ... 6=周六
+	StartTime string `json:"start_time"` // 开始时间 HH:MM
+	EndTime   string `json:"end_time"`   // 结束时间 HH:MM，须晚于开始时间
+}
+
+// TimeOff 员工请假/休息时段，时段内不可预约
+type TimeOff struct {
+	ID        int64  `json:"id"`
+	StaffID   int64  `json:"staff_id"`   // 员工ID
+	StartAt   int64  `json:"start_at"`   // 开始时间（Unix时间戳）
+	EndAt     int64  `json:"end_at"`     // 结束时间（Unix时间戳）
+	Reason    string `json:"reason"`     // 原因
+	CreatedAt int64  `json:"created_at"` // 创建时间
+}
+
+// AddTimeOffRequest 新增员工休息时段请求
+type AddTimeOffRequest struct {
+	StaffID int64     `json:"staff_id"` // 员工ID
+	StartAt time.Time `json:"start_at"` // 开始时间
+	EndAt   time.Time `json:"end_at"`   // 结束时间
+	Reason  string    `json:"reason"`   // 原因
+}
+
+// TimeSlot 可预约时段
+type TimeSlot struct {
+	StartAt int64 `json:"start_at"` // 开始时间（Unix时间戳）
+	EndAt   int64 `json:"end_at"`   // 结束时间（Unix时间戳）
+}
+
+// ListSlotsRequest 查询可预约时段请求
+type ListSlotsRequest struct {
+	StaffID   int64     `json:"staff_id"`   // 员工ID
+	ProductID int64     `json:"product_id"` // 服务产品ID，决定时段长度
+	Date      time.Time `json:"date"`       // 查询日期，按本地时区取当天
+}
+
+// Appointment 预约
+type Appointment struct {
+	ID           int64  `json:"id"`
+	CustomerID   int64  `json:"customer_id"`   // 客户ID
+	StaffID      int64  `json:"staff_id"`      // 服务员工ID
+	ProductID    int64  `json:"product_id"`    // 服务产品ID
+	ProductName  string `json:"product_name"`  // 产品名称快照
+	DurationMin  int32  `json:"duration_min"`  // 服务时长快照（分钟）
+	StartAt      int64  `json:"start_at"`      // 开始时间（Unix时间戳）
+	EndAt        int64  `json:"end_at"`        // 结束时间（Unix时间戳）
+	Status       string `json:"status"`        // 状态：booked/completed/cancelled
+	OrderID      int64  `json:"order_id"`      // 完成后生成的订单ID
+	Remark       string `json:"remark"`        // 备注
+	CancelReason string `json:"cancel_reason"` // 取消原因
+	CreatedBy    int64  `json:"created_by"`    // 创建人ID
+	CreatedAt    int64  `json:"created_at"`    // 创建时间
+	UpdatedAt    int64  `json:"updated_at"`    // 更新时间
+}
+
+// BookAppointmentRequest 预约请求
+type BookAppointmentRequest struct {
+	CustomerID int64     `json:"customer_id"` // 客户ID
+	StaffID    int64     `json:"staff_id"`    // 服务员工ID
+	ProductID  int64     `json:"product_id"`  // 服务产品ID，须为配置了服务时长的 service 类型产品
+	StartAt    time.Time `json:"start_at"`    // 开始时间
+	Remark     string    `json:"remark"`      // 备注
+	OperatorID int64     `json:"operator_id"` // 操作人ID
+}
+
+// RescheduleAppointmentRequest 改约请求
+type RescheduleAppointmentRequest struct {
+	AppointmentID int64     `json:"appointment_id"` // 预约ID
+	StaffID       int64     `json:"staff_id"`       // 新的服务员工ID，0 表示不变
+	StartAt       time.Time `json:"start_at"`       // 新的开始时间
+}
+
+// CompleteAppointmentRequest 完成预约并转为订单请求
+type CompleteAppointmentRequest struct {
+	AppointmentID int64                  `json:"appointment_id"` // 预约ID
+	PayMethod     string                 `json:"pay_method"`     // 支付方式：wallet/cash/online/card
+	Payments      []sales.PaymentLineReq `json:"payments"`       // 组合支付明细（可选）
+	CouponCode    string                 `json:"coupon_code"`    // 优惠券码（可选）
+	UsePackage    bool                   `json:"use_package"`    // 使用客户次卡套餐抵扣本次服务
+}
+
+// ListAppointmentsRequest 预约列表请求
+type ListAppointmentsRequest struct {
+	StaffID    int64     `json:"staff_id,omitempty"`    // 按员工筛选
+	CustomerID int64     `json:"customer_id,omitempty"` // 按客户筛选
+	Status     string    `json:"status,omitempty"`      // 按状态筛选
+	From       time.Time `json:"from,omitempty"`        // 开始时间起（含）
+	To         time.Time `json:"to,omitempty"`          // 开始时间止（不含）
+	Page       int       `json:"page"`
+	PageSize   int       `json:"page_size"`
+}
+
+// Service 预约排班域服务接口
+type Service interface {
+	// SetStaffAvailability 整体替换员工每周可预约时间
+	SetStaffAvailability(ctx context.Context, staffID int64, rules []AvailabilityRule) error
+
+	// GetStaffAvailability 获取员工每周可预约时间
+	GetStaffAvailability(ctx context.Context, staffID int64) ([]AvailabilityRule, error)
+
+	// AddStaffTimeOff 新增员工休息时段，与已有预约冲突时拒绝
+	AddStaffTimeOff(ctx context.Context, req AddTimeOffRequest) (*TimeOff, error)
+
+	// ListStaffTimeOffs 查询与 [from, to) 有交集的员工休息时段
+	ListStaffTimeOffs(ctx context.Context, staffID int64, from, to time.Time) ([]TimeOff, error)
+
+	// DeleteStaffTimeOff 删除员工休息时段
+	DeleteStaffTimeOff(ctx context.Context, staffID, timeOffID int64) error
+
+	// ListAvailableSlots 按服务时长计算员工某天的可预约时段
+	// 排除休息时段、已有预约和已过去的时间
+	ListAvailableSlots(ctx context.Context, req ListSlotsRequest) ([]TimeSlot, error)
+
+	// BookAppointment 预约
+	// 时段须落在员工可预约时间内，且与该员工其他预约、休息时段不重叠
+	BookAppointment(ctx context.Context, req BookAppointmentRequest) (*Appointment, error)
+
+	// RescheduleAppointment 改约，可同时更换员工，冲突检测规则同预约
+	RescheduleAppointment(ctx context.Context, req RescheduleAppointmentRequest) (*Appointment, error)
+
+	// CancelAppointment 取消预约，仅 booked 状态可取消
+	CancelAppointment(ctx context.Context, appointmentID int64, reason string) (*Appointment, error)
+
+	// CompleteAppointment 完成预约并通过 PlaceOrder 生成订单
+	// 订单创建与预约状态更新在同一事务中，订单负责人为服务员工
+	CompleteAppointment(ctx context.Context, req CompleteAppointmentRequest) (*Appointment, error)
+
+	// GetAppointment 获取预约详情
+	GetAppointment(ctx context.Context, appointmentID int64) (*Appointment, error)
+
+	// ListAppointments 分页查询预约，按开始时间升序
+	ListAppointments(ctx context.Context, req ListAppointmentsRequest) ([]Appointment, int64, error)
+}
@@ -0,0 +1,70 @@
+package dto
+
+import "time"
+
+// ActivityCreateRequest 创建客户活动请求
+type ActivityCreateRequest struct {
+	ContactID   int64      `json:"contact_id" example:"0"`                                                                                  // 联系人ID（可选），须属于该客户
+	Type        string     `json:"type" binding:"required,oneof=call meeting email visit follow_up complaint feedback" example:"follow_up"` // 活动类型
+	Title       string     `json:"title" binding:"required,max=200" example:"跟进报价"`
+	Content     string     `json:"content"`
+	Priority    string     `json:"priority" binding:"omitempty,oneof=low medium high urgent" example:"medium"` // 优先级，缺省为 medium
+	ScheduledAt *time.Time `json:"scheduled_at" example:"2024-06-10T10:00:00+08:00"`                           // 计划时间（可选），带计划时间的活动即跟进任务
+	AssignedTo  int64      `json:"assigned_to" example:"3"`                                                    // 负责人，缺省为当前用户
+}
+
+// ActivityUpdateRequest 更新客户活动请求，未提供的字段保持不变
+type ActivityUpdateRequest struct {
+	ContactID   *int64     `json:"contact_id"`
+	Type        *string    `json:"type" binding:"omitempty,oneof=call meeting email visit follow_up complaint feedback"`
+	Title       *string    `json:"title" binding:"omitempty,max=200"`
+	Content     *string    `json:"content"`
+	Status      *string    `json:"status" binding:"omitempty,oneof=planned in_progress cancelled" example:"in_progress"` // 完成请使用完成接口
+	Priority    *string    `json:"priority" binding:"omitempty,oneof=low medium high urgent"`
+	ScheduledAt *time.Time `json:"scheduled_at"` // 传 "0001-01-01T00:00:00Z" 清除计划时间
+	AssignedTo  *int64     `json:"assigned_to"`
+}
+
+// ActivityCompleteRequest 完成活动请求
+type ActivityCompleteRequest struct {
+	Result string `json:"result" example:"客户接受报价"` // 处理结果（可选），追加到活动内容
+}
+
+// ActivityListRequest 客户活动列表查询参数
+type ActivityListRequest struct {
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+	Type     string `form:"type" binding:"omitempty,oneof=call meeting email visit follow_up complaint feedback"` // 按类型筛选
+	Status   string `form:"status" binding:"omitempty,oneof=planned in_progress completed cancelled"`             // 按状态筛选
+}
+
+// ActivityTaskListRequest 待办与逾期任务查询参数
+type ActivityTaskListRequest struct {
+	Page     int `form:"page,default=1" binding:"min=1"`
+	PageSize int `form:"page_size,default=20" binding:"min=1,max=100"`
+}
+
+// ActivityResponse 客户活动响应
+type ActivityResponse struct {
+	ID          int64      `json:"id" example:"1"`
+	CustomerID  int64      `json:"customer_id" example:"1"`
+	ContactID   int64      `json:"contact_id" example:"0"`
+	Type        string     `json:"type" example:"follow_up"`
+	Title       string     `json:"title" example:"跟进报价"`
+	Content     string     `json:"content"`
+	Status      string     `json:"status" example:"planned"`
+	Priority    string     `json:"priority" example:"medium"`
+	ScheduledAt *time.Time `json:"scheduled_at"` // 计划时间，未安排为 null
+	CompletedAt *time.Time `json:"completed_at"` // 完成时间，未完成为 null
+	Overdue     bool       `json:"overdue"`      // 计划时间已过且未完成
+	AssignedTo  int64      `json:"assigned_to" example:"3"`
+	CreatedBy   int64      `json:"created_by" example:"3"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ActivityListResponse 客户活动列表响应
+type ActivityListResponse struct {
+	Activities []*ActivityResponse `json:"activities"`
+	Total      int64               `json:"total" example:"10"`
+}
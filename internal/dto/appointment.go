@@ -0,0 +1,124 @@
+package dto
+
+import "time"
+
+// ================ 员工排班 ================
+
+// StaffAvailabilityRule 员工每周可预约时间段
+type StaffAvailabilityRule struct {
+	Weekday   int    `json:"weekday" binding:"min=0,max=6" example:"1"`     // 星期：0=周日 ... 6=周六
+	StartTime string `json:"start_time" binding:"required" example:"09:00"` // 开始时间 HH:MM
+	EndTime   string `json:"end_time" binding:"required" example:"18:00"`   // 结束时间 HH:MM
+}
+
+// StaffAvailabilityRequest 设置员工每周可预约时间请求（整体替换）
+type StaffAvailabilityRequest struct {
+	Rules []*StaffAvailabilityRule `json:"rules" binding:"omitempty,dive"`
+}
+
+// StaffAvailabilityResponse 员工每周可预约时间响应
+type StaffAvailabilityResponse struct {
+	StaffID int64                    `json:"staff_id" example:"3"`
+	Rules   []*StaffAvailabilityRule `json:"rules"`
+}
+
+// StaffTimeOffRequest 新增员工休息时段请求
+type StaffTimeOffRequest struct {
+	StartAt time.Time `json:"start_at" binding:"required" example:"2024-06-10T12:00:00+08:00"`
+	EndAt   time.Time `json:"end_at" binding:"required" example:"2024-06-10T13:00:00+08:00"`
+	Reason  string    `json:"reason" binding:"max=255" example:"午休"`
+}
+
+// StaffTimeOffListRequest 员工休息时段查询参数
+type StaffTimeOffListRequest struct {
+	From time.Time `form:"from" time_format:"2006-01-02"` // 起始日期（含）
+	To   time.Time `form:"to" time_format:"2006-01-02"`   // 结束日期（含）
+}
+
+// StaffTimeOffResponse 员工休息时段响应
+type StaffTimeOffResponse struct {
+	ID        int64     `json:"id" example:"1"`
+	StaffID   int64     `json:"staff_id" example:"3"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Reason    string    `json:"reason" example:"午休"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ================ 预约 ================
+
+// AppointmentSlotRequest 查询可预约时段参数
+type AppointmentSlotRequest struct {
+	StaffID   int64     `form:"staff_id" binding:"required"`
+	ProductID int64     `form:"product_id" binding:"required"`
+	Date      time.Time `form:"date" binding:"required" time_format:"2006-01-02"`
+}
+
+// AppointmentSlotResponse 可预约时段
+type AppointmentSlotResponse struct {
+	StartAt time.Time `json:"start_at"`
+	EndAt   time.Time `json:"end_at"`
+}
+
+// AppointmentCreateRequest 预约请求
+type AppointmentCreateRequest struct {
+	CustomerID int64     `json:"customer_id" binding:"required" example:"1"`
+	StaffID    int64     `json:"staff_id" binding:"required" example:"3"`
+	ProductID  int64     `json:"product_id" binding:"required" example:"2"` // 服务产品，时长取自产品服务时长
+	StartAt    time.Time `json:"start_at" binding:"required" example:"2024-06-10T10:00:00+08:00"`
+	Remark     string    `json:"remark" binding:"max=255"`
+}
+
+// AppointmentRescheduleRequest 改约请求
+type AppointmentRescheduleRequest struct {
+	StaffID int64     `json:"staff_id" example:"3"` // 新的服务员工，缺省不变
+	StartAt time.Time `json:"start_at" binding:"required" example:"2024-06-11T10:00:00+08:00"`
+}
+
+// AppointmentCancelRequest 取消预约请求
+type AppointmentCancelRequest struct {
+	Reason string `json:"reason" binding:"max=255" example:"客户临时有事"`
+}
+
+// AppointmentCompleteRequest 完成预约并生成订单请求
+type AppointmentCompleteRequest struct {
+	PayMethod  string              `json:"pay_method" binding:"omitempty,payment_method" example:"wallet"` // 支付方式，缺省为钱包支付
+	Payments   []*OrderPaymentLine `json:"payments" binding:"omitempty,dive"`                              // 组合支付明细（可选）
+	CouponCode string              `json:"coupon_code" binding:"omitempty,max=32"`                         // 优惠券码（可选）
+	UsePackage bool                `json:"use_package"`                                                    // 使用客户次卡套餐抵扣本次服务
+}
+
+// AppointmentListRequest 预约列表查询参数
+type AppointmentListRequest struct {
+	Page       int       `form:"page,default=1" binding:"min=1"`
+	PageSize   int       `form:"page_size,default=20" binding:"min=1,max=100"`
+	StaffID    int64     `form:"staff_id"`                                                                     // 按员工筛选
+	CustomerID int64     `form:"customer_id"`                                                                  // 按客户筛选
+	Status     string    `form:"status" binding:"omitempty,oneof=booked completed cancelled" example:"booked"` // 按状态筛选
+	StartDate  time.Time `form:"start_date" time_format:"2006-01-02"`                                          // 预约日期起（含）
+	EndDate    time.Time `form:"end_date" time_format:"2006-01-02"`                                            // 预约日期止（含）
+}
+
+// AppointmentResponse 预约响应
+type AppointmentResponse struct {
+	ID           int64     `json:"id" example:"1"`
+	CustomerID   int64     `json:"customer_id" example:"1"`
+	StaffID      int64     `json:"staff_id" example:"3"`
+	ProductID    int64     `json:"product_id" example:"2"`
+	ProductName  string    `json:"product_name" example:"精剪"`
+	DurationMin  int32     `json:"duration_min" example:"60"`
+	StartAt      time.Time `json:"start_at"`
+	EndAt        time.Time `json:"end_at"`
+	Status       string    `json:"status" example:"booked"`
+	OrderID      int64     `json:"order_id" example:"0"` // 完成后生成的订单ID
+	Remark       string    `json:"remark"`
+	CancelReason string    `json:"cancel_reason"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// AppointmentListResponse 预约列表响应
+type AppointmentListResponse struct {
+	Appointments []*AppointmentResponse `json:"appointments"`
+	Total        int64                  `json:"total" example:"10"`
+}
@@ -0,0 +1,77 @@
+package dto
+
+import "time"
+
+// CommissionRuleTierRequest 业绩分档
+type CommissionRuleTierRequest struct {
+	MinVolume int64 `json:"min_volume" binding:"required,min=1" example:"5000000"` // 当月业绩门槛（分）
+	Value     int64 `json:"value" binding:"min=0" example:"1500"`                  // 本档提成值，单位同规则计算方式
+}
+
+// CommissionRuleRequest 创建或更新提成规则请求
+type CommissionRuleRequest struct {
+	Name      string                      `json:"name" binding:"required,max=100" example:"剪发提成"`
+	Scope     string                      `json:"scope" binding:"required,oneof=product category default" example:"category"` // 适用范围，优先级 product > category > default
+	ProductID int64                       `json:"product_id" example:"0"`                                                     // 适用产品ID，scope = product 时必填
+	Category  string                      `json:"category" binding:"max=50" example:"haircut"`                                // 适用产品分类，scope = category 时必填
+	Type      string                      `json:"type" binding:"required,oneof=percent fixed" example:"percent"`              // percent：万分比；fixed：每件固定金额（分）
+	Value     int64                       `json:"value" binding:"min=0" example:"1000"`                                       // 基础提成值
+	IsActive  *bool                       `json:"is_active" example:"true"`                                                   // 是否启用，默认启用
+	Tiers     []CommissionRuleTierRequest `json:"tiers" binding:"omitempty,dive"`                                             // 按当月业绩分档
+}
+
+// CommissionRuleTierResponse 业绩分档响应
+type CommissionRuleTierResponse struct {
+	MinVolume int64 `json:"min_volume" example:"5000000"`
+	Value     int64 `json:"value" example:"1500"`
+}
+
+// CommissionRuleResponse 提成规则响应
+type CommissionRuleResponse struct {
+	ID        int64                         `json:"id" example:"1"`
+	Name      string                        `json:"name" example:"剪发提成"`
+	Scope     string                        `json:"scope" example:"category"`
+	ProductID int64                         `json:"product_id" example:"0"`
+	Category  string                        `json:"category" example:"haircut"`
+	Type      string                        `json:"type" example:"percent"`
+	Value     int64                         `json:"value" example:"1000"`
+	IsActive  bool                          `json:"is_active" example:"true"`
+	Tiers     []*CommissionRuleTierResponse `json:"tiers"`
+	CreatedAt time.Time                     `json:"created_at"`
+	UpdatedAt time.Time                     `json:"updated_at"`
+}
+
+// CommissionStatementRequest 员工提成对账单查询参数
+type CommissionStatementRequest struct {
+	StartDate time.Time `form:"start_date" binding:"required" time_format:"2006-01-02"` // 结算周期起（含）
+	EndDate   time.Time `form:"end_date" binding:"required" time_format:"2006-01-02"`   // 结算周期止（含）
+}
+
+// CommissionEntryResponse 提成明细响应
+type CommissionEntryResponse struct {
+	ID          int64     `json:"id" example:"1"`
+	Type        string    `json:"type" example:"accrual"` // accrual/reversal
+	OrderID     int64     `json:"order_id" example:"100"`
+	OrderItemID int64     `json:"order_item_id" example:"200"`
+	ProductID   int64     `json:"product_id" example:"2"`
+	RefundID    int64     `json:"refund_id" example:"0"`
+	RuleID      int64     `json:"rule_id" example:"1"`
+	RuleType    string    `json:"rule_type" example:"percent"`
+	RuleValue   int64     `json:"rule_value" example:"1000"`
+	Quantity    int32     `json:"quantity" example:"1"`
+	BaseAmount  int64     `json:"base_amount" example:"8800"` // 提成基数（分）
+	Amount      int64     `json:"amount" example:"880"`       // 提成金额（分，正数）
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// CommissionStatementResponse 员工提成对账单响应
+type CommissionStatementResponse struct {
+	StaffID   int64                      `json:"staff_id" example:"3"`
+	StartDate string                     `json:"start_date" example:"2024-06-01"`
+	EndDate   string                     `json:"end_date" example:"2024-06-30"`
+	Volume    int64                      `json:"volume" example:"880000"` // 周期内净业绩（分）
+	Accrued   int64                      `json:"accrued" example:"88000"` // 计提合计（分）
+	Reversed  int64                      `json:"reversed" example:"880"`  // 冲回合计（分）
+	Net       int64                      `json:"net" example:"87120"`     // 应发提成（分）
+	Entries   []*CommissionEntryResponse `json:"entries"`
+}
@@ -12,6 +12,8 @@ type CustomerCreateRequest struct {
 	Note       string   `json:"note"`                                             // 备注
 	Source     string   `json:"source" binding:"omitempty,customer_source"`                                           // 客户来源：manual, referral, marketing, etc.
 	AssignedTo int64    `json:"assigned_to"`                                      // 分配给哪个员工
+
+	CustomFields map[string]interface{} `json:"custom_fields"` // 自定义字段值，以字段标识为键；必填字段须提供
 }
 
 // CustomerUpdateRequest 更新客户的请求
@@ -26,6 +28,8 @@ type CustomerUpdateRequest struct {
 	Note       string   `json:"note"`                                             // 备注
 	Source     string   `json:"source" binding:"omitempty,customer_source"`                                           // 客户来源：manual, referral, marketing, etc.
 	AssignedTo int64    `json:"assigned_to"`                                      // 分配给哪个员工
+
+	CustomFields map[string]interface{} `json:"custom_fields"` // 自定义字段值，以字段标识为键，只更新提供的字段，null 表示清空
 }
 
 // CustomerListRequest 获取客户列表的请求参数
@@ -37,6 +41,9 @@ type CustomerListRequest struct {
 	Email    string  `form:"email"`    // 按邮箱精确搜索
 	OrderBy  string  `form:"order_by"` // 排序字段, e.g., created_at_desc
 	IDs      []int64 `form:"ids"`      // 新增: 用于根据ID批量查询
+
+	Tags     []string `form:"tags"`                                        // 按标签筛选，可重复传参；名称忽略大小写匹配标签字典
+	TagMatch string   `form:"tag_match" binding:"omitempty,oneof=any all"` // any：包含任一标签（默认），all：包含全部标签
 }
 
 // CustomerBatchGetRequest 批量获取客户的请求体
@@ -61,6 +68,8 @@ type CustomerResponse struct {
 	WalletBalance float64  `json:"wallet_balance,omitempty"` // 兼容测试字段
 	CreatedAt     string   `json:"created_at"`
 	UpdatedAt     string   `json:"updated_at"`
+
+	CustomFields map[string]interface{} `json:"custom_fields,omitempty"` // 自定义字段值：number 为数字，multi_select 为字符串数组，其余为字符串
 }
 
 // SourceMap defines the mapping for customer sources.
@@ -82,3 +91,31 @@ type CustomerListResponse struct {
 	Total     int64               `json:"total"`
 	Customers []*CustomerResponse `json:"customers"`
 }
+
+// CustomerImportRequest 客户导入请求（multipart/form-data），文件字段为 file
+type CustomerImportRequest struct {
+	DryRun bool `form:"dry_run"` // 试运行：只校验并返回报告，不写入数据
+}
+
+// CustomerImportRowResponse 单行导入结果
+type CustomerImportRowResponse struct {
+	Row            int      `json:"row" example:"2"` // 文件中的行号，表头为第 1 行
+	Name           string   `json:"name" example:"张三"`
+	Phone          string   `json:"phone" example:"13800000001"`
+	Action         string   `json:"action" example:"created"` // created/updated/failed
+	CustomerID     int64    `json:"customer_id" example:"1"`
+	OpeningBalance float64  `json:"opening_balance" example:"100"` // 期初余额（元）
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// CustomerImportResponse 客户导入报告
+type CustomerImportResponse struct {
+	DryRun         bool                         `json:"dry_run"`
+	Total          int                          `json:"total" example:"3"`
+	Created        int                          `json:"created" example:"1"`
+	Updated        int                          `json:"updated" example:"1"`
+	Failed         int                          `json:"failed" example:"1"`
+	OpeningBalance float64                      `json:"opening_balance" example:"100"` // 成功行的期初余额合计（元）
+	IgnoredColumns []string                     `json:"ignored_columns,omitempty"`
+	Rows           []*CustomerImportRowResponse `json:"rows"`
+}
@@ -0,0 +1,32 @@
+package dto
+
+// CustomerFieldCreateRequest 创建客户自定义字段请求
+type CustomerFieldCreateRequest struct {
+	Key      string   `json:"key" binding:"required,max=50" example:"hair_type"`                               // 字段标识，小写字母开头，仅含小写字母、数字和下划线
+	Label    string   `json:"label" binding:"required,max=50" example:"发质"`                                    // 显示名称
+	Type     string   `json:"type" binding:"required,oneof=text number date enum multi_select" example:"enum"` // 字段类型，创建后不可修改
+	Options  []string `json:"options" example:"干性,油性,中性"`                                                      // 可选项，仅 enum/multi_select 使用且必填
+	Required bool     `json:"required"`                                                                        // 创建客户时是否必填
+	Sort     int      `json:"sort" example:"10"`                                                               // 排序，越小越靠前
+}
+
+// CustomerFieldUpdateRequest 更新客户自定义字段请求，未提供的字段保持不变
+type CustomerFieldUpdateRequest struct {
+	Label    *string   `json:"label" binding:"omitempty,max=50"`
+	Options  *[]string `json:"options"` // 删减可选项不影响已保存的客户字段值
+	Required *bool     `json:"required"`
+	Sort     *int      `json:"sort"`
+}
+
+// CustomerFieldResponse 客户自定义字段
+type CustomerFieldResponse struct {
+	ID        int64    `json:"id" example:"1"`
+	Key       string   `json:"key" example:"hair_type"`
+	Label     string   `json:"label" example:"发质"`
+	Type      string   `json:"type" example:"enum"`
+	Options   []string `json:"options"`
+	Required  bool     `json:"required"`
+	Sort      int      `json:"sort" example:"10"`
+	CreatedAt int64    `json:"created_at"`
+	UpdatedAt int64    `json:"updated_at"`
+}
@@ -0,0 +1,47 @@
+package dto
+
+import "time"
+
+// DuplicateQuery 疑似重复客户查询参数
+type DuplicateQuery struct {
+	Limit int `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+// DuplicateCandidateResponse 疑似重复客户
+type DuplicateCandidateResponse struct {
+	CustomerID     int64    `json:"customer_id" example:"2"`
+	Name           string   `json:"name" example:"张三"`
+	Phone          string   `json:"phone" example:"+86 138-0000-0001"`
+	Email          string   `json:"email"`
+	AssignedTo     int64    `json:"assigned_to" example:"3"`
+	Reasons        []string `json:"reasons" example:"phone,name"` // 匹配原因：phone/email/name
+	NameSimilarity float64  `json:"name_similarity" example:"1"`
+	Score          float64  `json:"score" example:"1"` // 手机号或邮箱相同为 1，仅姓名相似时为姓名相似度
+}
+
+// CustomerMergeRequest 合并客户请求，路径中的客户为保留客户
+type CustomerMergeRequest struct {
+	LoserID int64  `json:"loser_id" binding:"required,min=1" example:"2"` // 被合并的客户，合并后删除
+	Reason  string `json:"reason" binding:"max=255" example:"重复录入"`
+}
+
+// CustomerMergeResponse 客户合并记录
+type CustomerMergeResponse struct {
+	ID                       int64     `json:"id" example:"1"`
+	SurvivorID               int64     `json:"survivor_id" example:"1"`
+	LoserID                  int64     `json:"loser_id" example:"2"`
+	MovedContacts            int64     `json:"moved_contacts" example:"1"`
+	MovedOrders              int64     `json:"moved_orders" example:"3"`
+	MovedActivities          int64     `json:"moved_activities" example:"2"`
+	MovedMarketingRecords    int64     `json:"moved_marketing_records" example:"0"`
+	MovedPackages            int64     `json:"moved_packages" example:"1"`             // 改挂的服务套餐数
+	MovedPackageTransactions int64     `json:"moved_package_transactions" example:"2"` // 改挂的服务套餐流水数
+	MovedAppointments        int64     `json:"moved_appointments" example:"1"`
+	MovedCouponRedemptions   int64     `json:"moved_coupon_redemptions" example:"0"`
+	WalletAmount             float64   `json:"wallet_amount" example:"100.5"` // 转入保留客户钱包的余额（元）
+	WalletTransferID         int64     `json:"wallet_transfer_id" example:"5"`
+	LoserSnapshot            string    `json:"loser_snapshot"` // 被合并客户合并前的资料（JSON）
+	OperatorID               int64     `json:"operator_id" example:"1"`
+	Reason                   string    `json:"reason" example:"重复录入"`
+	CreatedAt                time.Time `json:"created_at"`
+}
@@ -0,0 +1,60 @@
+package dto
+
+// CustomerTagCreateRequest 创建客户标签请求
+type CustomerTagCreateRequest struct {
+	Name  string `json:"name" binding:"required,max=50" example:"VIP"`
+	Color string `json:"color" example:"#F56C6C"`             // #RRGGBB，缺省为灰色
+	Group string `json:"group" binding:"max=50" example:"价值"` // 分组（可选）
+	Sort  int    `json:"sort" example:"1"`                    // 组内排序，越小越靠前
+}
+
+// CustomerTagUpdateRequest 更新客户标签请求，未提供的字段保持不变
+type CustomerTagUpdateRequest struct {
+	Name  *string `json:"name" binding:"omitempty,max=50"` // 改名会同步改写所有客户上的该标签
+	Color *string `json:"color"`
+	Group *string `json:"group" binding:"omitempty,max=50"`
+	Sort  *int    `json:"sort"`
+}
+
+// CustomerTagMergeRequest 合并客户标签请求
+type CustomerTagMergeRequest struct {
+	Sources []string `json:"sources" binding:"required,min=1" example:"vip ,Vip"` // 按原样匹配的来源标签，可以是字典外的写法
+}
+
+// CustomerTagBulkRequest 批量打标签请求
+type CustomerTagBulkRequest struct {
+	IDs          []int64           `json:"ids"`                                         // 客户ID，指定时忽略其他筛选条件
+	Name         string            `json:"name"`                                        // 按姓名模糊筛选
+	Phone        string            `json:"phone"`                                       // 按手机号精确筛选
+	Email        string            `json:"email"`                                       // 按邮箱精确筛选
+	Tags         []string          `json:"tags"`                                        // 按标签筛选
+	TagMatch     string            `json:"tag_match" binding:"omitempty,oneof=any all"` // any/all
+	CustomFields map[string]string `json:"custom_fields"`                               // 按自定义字段筛选
+	Add          []string          `json:"add" example:"VIP"`                           // 添加的标签，须在标签字典中
+	Remove       []string          `json:"remove" example:"流失预警"`                       // 移除的标签
+}
+
+// CustomerTagResponse 客户标签
+type CustomerTagResponse struct {
+	ID         int64  `json:"id" example:"1"`
+	Name       string `json:"name" example:"VIP"`
+	Color      string `json:"color" example:"#F56C6C"`
+	Group      string `json:"group" example:"价值"`
+	Sort       int    `json:"sort" example:"1"`
+	UsageCount int64  `json:"usage_count" example:"120"` // 使用该标签的客户数
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+}
+
+// CustomerTagUsageResponse 客户上实际使用的标签
+type CustomerTagUsageResponse struct {
+	Name    string `json:"name" example:"vip "`
+	Count   int64  `json:"count" example:"3"`
+	Managed bool   `json:"managed"` // 是否在标签字典中，false 表示字典外的写法，可合并到字典标签
+}
+
+// CustomerTagUpdateResultResponse 标签批量改写结果
+type CustomerTagUpdateResultResponse struct {
+	Matched int64 `json:"matched" example:"10"` // 命中的客户数
+	Updated int64 `json:"updated" example:"8"`  // 标签实际发生变化的客户数
+}
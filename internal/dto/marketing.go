@@ -164,3 +164,66 @@ type CustomerSegmentResponse struct {
 		Email string `json:"email,omitempty" example:"zhangsan@example.com"`
 	} `json:"customers"`
 }
+
+// ================ 优惠券相关DTO ================
+
+// CouponCreateRequest 创建优惠券请求
+type CouponCreateRequest struct {
+	CampaignID       int64     `json:"campaign_id,omitempty" example:"1"` // 发放活动ID（可选），用于订单来源归因
+	Name             string    `json:"name" binding:"required,max=100" example:"满200减30"`
+	Type             string    `json:"type" binding:"required,oneof=fixed percent" example:"fixed"`
+	Value            float64   `json:"value" binding:"required,gt=0" example:"30"`         // fixed 为优惠金额（元），percent 为折扣百分比（1-100）
+	MinSpend         float64   `json:"min_spend" binding:"omitempty,gte=0" example:"200"`  // 最低消费（元）
+	MaxDiscount      float64   `json:"max_discount" binding:"omitempty,gte=0" example:"0"` // 最高优惠（元），0 表示不限
+	ProductIDs       []int64   `json:"product_ids,omitempty" example:"1,2"`                // 适用产品，为空表示不限
+	Categories       []string  `json:"categories,omitempty" example:"美发"`                  // 适用分类，为空表示不限
+	ValidFrom        time.Time `json:"valid_from" binding:"required" example:"2024-06-10T00:00:00Z"`
+	ValidTo          time.Time `json:"valid_to" binding:"required" example:"2024-06-30T23:59:59Z"`
+	PerCustomerLimit int       `json:"per_customer_limit" binding:"omitempty,gte=0" example:"1"` // 每位客户可使用次数，0 表示不限
+}
+
+// CouponResponse 优惠券响应
+type CouponResponse struct {
+	ID               int64     `json:"id" example:"1"`
+	CampaignID       int64     `json:"campaign_id" example:"1"`
+	Name             string    `json:"name" example:"满200减30"`
+	Type             string    `json:"type" example:"fixed"`
+	Value            float64   `json:"value" example:"30"`
+	MinSpend         float64   `json:"min_spend" example:"200"`
+	MaxDiscount      float64   `json:"max_discount" example:"0"`
+	ProductIDs       []int64   `json:"product_ids"`
+	Categories       []string  `json:"categories"`
+	ValidFrom        time.Time `json:"valid_from" example:"2024-06-10T00:00:00Z"`
+	ValidTo          time.Time `json:"valid_to" example:"2024-06-30T23:59:59Z"`
+	PerCustomerLimit int       `json:"per_customer_limit" example:"1"`
+	Status           string    `json:"status" example:"active"`
+	IssuedCount      int64     `json:"issued_count" example:"500"`
+	RedeemedCount    int64     `json:"redeemed_count" example:"120"`
+	CreatedBy        int64     `json:"created_by" example:"1"`
+	CreatedAt        string    `json:"created_at" example:"2024-06-01 10:00:00"`
+}
+
+// CouponListRequest 优惠券列表请求
+type CouponListRequest struct {
+	Page       int   `form:"page" binding:"omitempty,min=1" example:"1"`
+	PageSize   int   `form:"page_size" binding:"omitempty,min=1,max=100" example:"10"`
+	CampaignID int64 `form:"campaign_id" example:"1"`
+}
+
+// CouponListResponse 优惠券列表响应
+type CouponListResponse struct {
+	Coupons []*CouponResponse `json:"coupons"`
+	Total   int64             `json:"total" example:"10"`
+}
+
+// CouponCodeGenerateRequest 批量生成券码请求
+type CouponCodeGenerateRequest struct {
+	Count  int    `json:"count" binding:"required,min=1,max=10000" example:"100"`
+	Prefix string `json:"prefix" binding:"omitempty,max=16,alphanum" example:"JUNE"`
+}
+
+// CouponCodeGenerateResponse 批量生成券码响应
+type CouponCodeGenerateResponse struct {
+	CouponID int64    `json:"coupon_id" example:"1"`
+	Codes    []string `json:"codes" example:"JUNEAB3KXM9PQ2"`
+}
@@ -4,9 +4,10 @@ import "time"
 
 // OrderItemRequest 代表创建订单请求中的单个订单项。
 type OrderItemRequest struct {
-	ProductID int64   `json:"product_id" binding:"required"`
-	Quantity  int     `json:"quantity" binding:"required,gt=0"`
-	UnitPrice float64 `json:"unit_price" binding:"required,gte=0"` // 允许在下单时覆盖产品单价
+	ProductID  int64   `json:"product_id" binding:"required"`
+	Quantity   int     `json:"quantity" binding:"required,gt=0"`
+	UnitPrice  float64 `json:"unit_price" binding:"required,gte=0"` // 允许在下单时覆盖产品单价
+	UsePackage bool    `json:"use_package"`                         // 使用客户次卡套餐抵扣，该项不计入应付金额
 }
 
 // OrderCreateRequest 定义了创建新订单的请求体。
@@ -14,7 +15,9 @@ type OrderCreateRequest struct {
 	CustomerID int64               `json:"customer_id" binding:"required"`
 	OrderDate  time.Time           `json:"order_date" binding:"required"`
 	Status     string              `json:"status" binding:"omitempty,order_create_status"`
-	Items      []*OrderItemRequest `json:"items" binding:"required,min=1"` // 订单项，至少要有一项
+	Items      []*OrderItemRequest `json:"items" binding:"required,min=1"`         // 订单项，至少要有一项
+	Payments   []*OrderPaymentLine `json:"payments" binding:"omitempty,dive"`      // 组合支付明细（可选），缺省为整单钱包支付
+	CouponCode string              `json:"coupon_code" binding:"omitempty,max=32"` // 优惠券码（可选）
 	Remark     string              `json:"remark"`
 }
 
@@ -49,17 +52,139 @@ type OrderResponse struct {
 }
 
 // OrderListRequest 定义了列出订单的查询参数。
+// 传入 cursor 时按游标翻页（忽略 page，total 返回 -1），适用于大批量导出。
 type OrderListRequest struct {
-	Page       int     `form:"page,default=1"`
-	PageSize   int     `form:"page_size,default=10"`
-	CustomerID int64   `form:"customer_id"`                      // 按客户ID筛选
-	Status     string  `form:"status"`                           // 按状态筛选
-	OrderBy    string  `form:"order_by,default=order_date_desc"` // 排序字段
-	IDs        []int64 `form:"ids"`                              // 按 ID 列表过滤
+	Page          int       `form:"page,default=1"`
+	PageSize      int       `form:"page_size,default=10" binding:"omitempty,max=1000"`
+	CustomerID    int64     `form:"customer_id"`                                             // 按客户ID筛选
+	Status        string    `form:"status"`                                                  // 按状态筛选
+	AssignedTo    int64     `form:"assigned_to"`                                             // 按负责员工筛选
+	PaymentStatus string    `form:"payment_status" binding:"omitempty,payment_status"`       // 按支付状态筛选
+	PaymentMethod string    `form:"payment_method" binding:"omitempty,order_payment_method"` // 按支付方式筛选
+	StartDate     time.Time `form:"start_date" time_format:"2006-01-02"`                     // 下单日期起（含）
+	EndDate       time.Time `form:"end_date" time_format:"2006-01-02"`                       // 下单日期止（含）
+	MinAmount     float64   `form:"min_amount" binding:"omitempty,gte=0"`                    // 最终金额下限（元）
+	MaxAmount     float64   `form:"max_amount" binding:"omitempty,gte=0"`                    // 最终金额上限（元）
+	OrderNo       string    `form:"order_no" binding:"omitempty,alphanum,max=50"`            // 订单号前缀
+	OrderBy       string    `form:"order_by,default=order_date_desc"`                        // 排序：order_date/final_amount/id + _asc/_desc
+	Cursor        string    `form:"cursor" binding:"omitempty,max=256"`                      // 游标，取自上一页的 next_cursor
+	IDs           []int64   `form:"ids"`                                                     // 按 ID 列表过滤
 }
 
 // OrderListResponse 定义了订单列表的响应。
 type OrderListResponse struct {
-	Total  int64            `json:"total"`
-	Orders []*OrderResponse `json:"orders"`
-}
\ No newline at end of file
+	Total      int64            `json:"total"` // 游标模式下为 -1
+	Orders     []*OrderResponse `json:"orders"`
+	NextCursor string           `json:"next_cursor,omitempty"` // 下一页游标，为空表示没有更多数据
+}
+
+// OrderStatusUpdateRequest 定义了订单状态流转的请求体。
+type OrderStatusUpdateRequest struct {
+	Status string `json:"status" binding:"required,order_update_status"` // 目标状态
+	Reason string `json:"reason" binding:"max=255"`                      // 流转原因
+}
+
+// OrderStatusLogResponse 代表一次订单状态流转记录。
+type OrderStatusLogResponse struct {
+	ID         int64  `json:"id"`
+	FromStatus string `json:"from_status"` // 原状态
+	ToStatus   string `json:"to_status"`   // 目标状态
+	OperatorID int64  `json:"operator_id"` // 操作人ID，0 表示系统
+	Reason     string `json:"reason"`      // 流转原因
+	CreatedAt  string `json:"created_at"`  // 流转时间
+}
+
+// OrderRefundItemRequest 代表部分退款请求中的单个订单项。
+type OrderRefundItemRequest struct {
+	OrderItemID int64 `json:"order_item_id" binding:"required"` // 订单项ID
+	Quantity    int32 `json:"quantity" binding:"required,gt=0"` // 退款数量
+}
+
+// OrderRefundRequest 定义了按订单项部分退款的请求体。
+type OrderRefundRequest struct {
+	Items  []*OrderRefundItemRequest `json:"items" binding:"required,min=1,dive"` // 退款订单项
+	Reason string                    `json:"reason" binding:"max=255"`            // 退款原因
+}
+
+// OrderRefundLineResponse 代表退款单中的单行明细。
+type OrderRefundLineResponse struct {
+	OrderItemID int64   `json:"order_item_id"` // 订单项ID
+	ProductID   int64   `json:"product_id"`    // 产品ID
+	Quantity    int32   `json:"quantity"`      // 退款数量
+	Amount      float64 `json:"amount"`        // 退款金额（元）
+}
+
+// OrderRefundPaymentResponse 代表退款在一条支付明细上的去向。
+type OrderRefundPaymentResponse struct {
+	OrderPaymentID   int64   `json:"order_payment_id"`   // 支付明细ID，0表示无对应支付明细
+	Method           string  `json:"method"`             // 原支付方式
+	Amount           float64 `json:"amount"`             // 退款金额（元）
+	WalletTxID       int64   `json:"wallet_tx_id"`       // 钱包退款流水ID，经渠道退款时为0
+	Provider         string  `json:"provider"`           // 退款渠道，退回钱包时为空
+	ProviderRefundNo string  `json:"provider_refund_no"` // 渠道退款单号
+}
+
+// OrderRefundResponse 代表一张退款单。
+type OrderRefundResponse struct {
+	ID         int64                         `json:"id"`
+	OrderID    int64                         `json:"order_id"`     // 订单ID
+	Amount     float64                       `json:"amount"`       // 退款金额（元）
+	WalletTxID int64                         `json:"wallet_tx_id"` // 钱包退款流水ID，全部经渠道原路退回时为0
+	Reason     string                        `json:"reason"`       // 退款原因
+	OperatorID int64                         `json:"operator_id"`  // 操作人ID
+	Lines      []*OrderRefundLineResponse    `json:"lines"`        // 退款明细
+	Payments   []*OrderRefundPaymentResponse `json:"payments"`     // 退款去向：在线支付原路退回，其余退回钱包
+	CreatedAt  string                        `json:"created_at"`   // 退款时间
+}
+
+// OrderPaymentLine 代表一行支付明细，金额单位为元。
+type OrderPaymentLine struct {
+	Method string  `json:"method" binding:"required,payment_method"` // 支付方式：wallet/cash/online/card
+	Amount float64 `json:"amount" binding:"required,gt=0"`           // 支付金额（元）
+}
+
+// OrderPaymentRequest 定义了追加订单支付的请求体。
+type OrderPaymentRequest struct {
+	Payments []*OrderPaymentLine `json:"payments" binding:"required,min=1,dive"` // 支付明细
+}
+
+// OrderPaymentResponse 代表一行订单支付明细。
+type OrderPaymentResponse struct {
+	ID         int64   `json:"id"`
+	OrderID    int64   `json:"order_id"`     // 订单ID
+	Method     string  `json:"method"`       // 支付方式
+	Amount     float64 `json:"amount"`       // 支付金额（元）
+	WalletTxID int64   `json:"wallet_tx_id"` // 钱包扣款流水ID
+	OperatorID int64   `json:"operator_id"`  // 操作人ID
+	CreatedAt  string  `json:"created_at"`   // 支付时间
+}
+
+// PaymentIntentCreateRequest 定义了创建在线支付单的请求体。
+type PaymentIntentCreateRequest struct {
+	Amount   float64 `json:"amount" binding:"omitempty,gt=0"`     // 支付金额（元），缺省为订单剩余应付金额
+	Provider string  `json:"provider" binding:"omitempty,max=32"` // 支付渠道，缺省使用默认渠道
+}
+
+// PaymentIntentResponse 代表一张在线支付单。
+type PaymentIntentResponse struct {
+	ID              int64   `json:"id"`
+	IntentNo        string  `json:"intent_no"`         // 支付单号
+	OrderID         int64   `json:"order_id"`          // 订单ID
+	CustomerID      int64   `json:"customer_id"`       // 客户ID
+	Provider        string  `json:"provider"`          // 支付渠道
+	ProviderTradeNo string  `json:"provider_trade_no"` // 渠道交易号
+	Amount          float64 `json:"amount"`            // 支付金额（元）
+	Status          string  `json:"status"`            // 状态：pending/succeeded/failed/refunded
+	PayURL          string  `json:"pay_url"`           // 支付链接
+	OrderPaymentID  int64   `json:"order_payment_id"`  // 订单支付明细ID，支付成功前为0
+	FailureReason   string  `json:"failure_reason"`    // 失败或退款原因
+	OperatorID      int64   `json:"operator_id"`       // 发起人ID
+	PaidAt          string  `json:"paid_at"`           // 支付完成时间，未支付为空
+	CreatedAt       string  `json:"created_at"`
+}
+
+// StuckPaymentIntentQuery 定义了查询卡单支付单的参数。
+type StuckPaymentIntentQuery struct {
+	OlderThanMinutes int `form:"older_than_minutes" binding:"omitempty,min=1"` // 创建超过多少分钟仍为 pending，缺省取 payment.pendingTimeout
+	Limit            int `form:"limit" binding:"omitempty,min=1,max=500"`      // 返回条数上限，默认100
+}
@@ -0,0 +1,51 @@
+package dto
+
+import "time"
+
+// PackagePlanRequest 设置套餐配置请求
+type PackagePlanRequest struct {
+	ServiceProductID int64 `json:"service_product_id" binding:"required" example:"2"` // 可抵扣的服务产品ID
+	TotalUses        int32 `json:"total_uses" binding:"required,min=1" example:"10"`  // 每份套餐包含次数
+	ValidDays        int32 `json:"valid_days" binding:"min=0" example:"365"`          // 有效天数，0 表示不过期
+}
+
+// PackagePlanResponse 套餐配置响应
+type PackagePlanResponse struct {
+	ProductID        int64     `json:"product_id" example:"5"`
+	ServiceProductID int64     `json:"service_product_id" example:"2"`
+	TotalUses        int32     `json:"total_uses" example:"10"`
+	ValidDays        int32     `json:"valid_days" example:"365"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// CustomerPackageListRequest 客户套餐查询参数
+type CustomerPackageListRequest struct {
+	ActiveOnly bool `form:"active_only"` // 只返回可用（未作废、未过期、有剩余次数）的套餐
+}
+
+// CustomerPackageResponse 客户套餐响应
+type CustomerPackageResponse struct {
+	ID               int64      `json:"id" example:"1"`
+	CustomerID       int64      `json:"customer_id" example:"1"`
+	PackageProductID int64      `json:"package_product_id" example:"5"`
+	ServiceProductID int64      `json:"service_product_id" example:"2"`
+	OrderID          int64      `json:"order_id" example:"100"`
+	Quantity         int32      `json:"quantity" example:"1"`
+	TotalUses        int32      `json:"total_uses" example:"10"`
+	RemainingUses    int32      `json:"remaining_uses" example:"7"`
+	ExpiresAt        *time.Time `json:"expires_at"` // 为空表示不过期
+	Status           string     `json:"status" example:"active"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// PackageTransactionResponse 套餐使用流水响应
+type PackageTransactionResponse struct {
+	ID             int64     `json:"id" example:"1"`
+	Type           string    `json:"type" example:"redeem"` // purchase/redeem/restore/revoke
+	Uses           int32     `json:"uses" example:"1"`
+	RemainingAfter int32     `json:"remaining_after" example:"9"`
+	OrderID        int64     `json:"order_id" example:"101"`
+	OrderItemID    int64     `json:"order_item_id" example:"201"`
+	Note           string    `json:"note"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -3,13 +3,14 @@ package dto
 // ProductResponse 用于 API 响应的单个产品数据。
 type ProductResponse struct {
 	ID          int64   `json:"id"`
-	Name        string  `json:"name"`        // 产品名称
-	Description string  `json:"description"` // 产品描述
-	Price       float64 `json:"price"`       // 价格
-	SKU         string  `json:"sku"`         // 库存单位
-	Stock       int     `json:"stock"`       // 库存数量
-	CreatedAt   string  `json:"created_at"`  // 创建时间
-	UpdatedAt   string  `json:"updated_at"`  // 更新时间
+	Name        string  `json:"name"`         // 产品名称
+	Description string  `json:"description"`  // 产品描述
+	Price       float64 `json:"price"`        // 价格
+	SKU         string  `json:"sku"`          // 库存单位
+	Stock       int     `json:"stock"`        // 库存数量
+	DurationMin int32   `json:"duration_min"` // 服务时长（分钟）
+	CreatedAt   string  `json:"created_at"`   // 创建时间
+	UpdatedAt   string  `json:"updated_at"`   // 更新时间
 }
 
 // ProductCreateRequest 定义了创建新产品的请求体。
@@ -19,6 +20,7 @@ type ProductCreateRequest struct {
 	Price       float64 `json:"price" binding:"required,gt=0"`
 	SKU         string  `json:"sku" binding:"required,alphanum"`
 	Stock       int     `json:"stock" binding:"gte=0"`
+	DurationMin int32   `json:"duration_min" binding:"gte=0"` // 服务时长（分钟），service 类型产品可预约
 }
 
 // ProductUpdateRequest 定义了更新现有产品的请求体。
@@ -27,6 +29,7 @@ type ProductUpdateRequest struct {
 	Description string  `json:"description"`
 	Price       float64 `json:"price" binding:"omitempty,gt=0"`
 	Stock       int     `json:"stock" binding:"omitempty,gte=0"`
+	DurationMin int32   `json:"duration_min" binding:"omitempty,gte=0"` // 服务时长（分钟）
 }
 
 // ProductListRequest 定义了列出产品的查询参数。
@@ -1,11 +1,14 @@
 package dto
 
+import "time"
+
 // WalletResponse 钱包信息的响应
 type WalletResponse struct {
 	ID             int64   `json:"id"`
 	CustomerID     int64   `json:"customer_id"`
 	Type           string  `json:"type"`
 	Balance        float64 `json:"balance"`
+	Status         int32   `json:"status"` // 钱包状态：1-正常，0-冻结
 	FrozenBalance  float64 `json:"frozen_balance"`
 	TotalRecharged float64 `json:"total_recharged"`
 	TotalConsumed  float64 `json:"total_consumed"`
@@ -36,6 +39,17 @@ type WalletTransactionResponse struct {
 	Remark        string  `json:"remark"`
 	OperatorID    int64   `json:"operator_id"`
 	CreatedAt     string  `json:"created_at"`
+
+	ReasonCode   string `json:"reason_code,omitempty"`    // 原因代码，冲正流水为冲正原因
+	Reversed     bool   `json:"reversed"`                 // 是否已被冲正
+	ReversedByID int64  `json:"reversed_by_id,omitempty"` // 冲正该流水的流水ID
+	ReversalOfID int64  `json:"reversal_of_id,omitempty"` // 本流水为冲正流水时，被冲正的原流水ID
+}
+
+// ReverseWalletTransactionRequest 冲正钱包流水请求
+type ReverseWalletTransactionRequest struct {
+	ReasonCode string `json:"reason_code" binding:"required,wallet_reversal_reason" example:"wrong_amount"` // 冲正原因: wrong_amount, wrong_customer, wrong_type, duplicate_entry, other
+	Note       string `json:"note" binding:"max=255"`                                                    // 备注，原因为 other 时必填
 }
 
 type ListWalletTransactionsRequest struct {
@@ -54,6 +68,22 @@ type ListWalletTransactionsResponse struct {
 	Total        int64                        `json:"total"`
 }
 
+// WalletStatusRequest 冻结/解冻钱包请求
+type WalletStatusRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"` // 变更原因
+}
+
+// WalletStatusLogResponse 钱包状态变更日志
+type WalletStatusLogResponse struct {
+	ID         int64  `json:"id"`
+	WalletID   int64  `json:"wallet_id"`
+	FromStatus int32  `json:"from_status"` // 原状态：1-正常，0-冻结
+	ToStatus   int32  `json:"to_status"`   // 目标状态：1-正常，0-冻结
+	OperatorID int64  `json:"operator_id"`
+	Reason     string `json:"reason"`
+	CreatedAt  string `json:"created_at"`
+}
+
 // WalletRefundRequest 退款请求
 type WalletRefundRequest struct {
 	Amount  float64 `json:"amount" binding:"required,gt=0"` // 退款金额，必须为正数
@@ -61,3 +91,121 @@ type WalletRefundRequest struct {
 	Reason  string  `json:"reason" binding:"required"`      // 退款原因
 	Remark  string  `json:"remark"`                         // 备注
 }
+
+// RechargeBonusRuleRequest 创建或更新充值赠送规则请求
+type RechargeBonusRuleRequest struct {
+	Name        string     `json:"name" binding:"required,max=100" example:"充100送20"`
+	MinAmount   float64    `json:"min_amount" binding:"required,gt=0" example:"100"`  // 充值门槛（元）
+	BonusAmount float64    `json:"bonus_amount" binding:"required,gt=0" example:"20"` // 赠送金额（元）
+	ValidDays   int        `json:"valid_days" binding:"min=0" example:"90"`           // 赠送有效天数，0 表示永久有效
+	StartAt     *time.Time `json:"start_at"`                                          // 生效开始时间，不填表示不限
+	EndAt       *time.Time `json:"end_at"`                                            // 生效结束时间（不含），不填表示不限
+	IsActive    *bool      `json:"is_active" example:"true"`                          // 是否启用，默认启用
+}
+
+// RechargeBonusRuleResponse 充值赠送规则响应
+type RechargeBonusRuleResponse struct {
+	ID          int64      `json:"id" example:"1"`
+	Name        string     `json:"name" example:"充100送20"`
+	MinAmount   float64    `json:"min_amount" example:"100"`
+	BonusAmount float64    `json:"bonus_amount" example:"20"`
+	ValidDays   int        `json:"valid_days" example:"90"`
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at"`
+	IsActive    bool       `json:"is_active" example:"true"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// WalletRechargeResponse 充值记录响应
+type WalletRechargeResponse struct {
+	ID            int64   `json:"id"`
+	WalletID      int64   `json:"wallet_id"`
+	Amount        float64 `json:"amount"`       // 充值本金（元）
+	BonusAmount   float64 `json:"bonus_amount"` // 赠送金额（元）
+	RuleID        int64   `json:"rule_id"`      // 命中的赠送规则ID
+	PrincipalTxID int64   `json:"principal_tx_id"`
+	BonusTxID     int64   `json:"bonus_tx_id"`
+	Status        string  `json:"status"` // completed/refunded
+	OperatorID    int64   `json:"operator_id"`
+	Note          string  `json:"note"`
+	CreatedAt     string  `json:"created_at"`
+	RefundedAt    string  `json:"refunded_at,omitempty"`
+}
+
+// RechargeSummaryResponse 客户充值资金汇总（元），区分实付资金与赠送资金
+type RechargeSummaryResponse struct {
+	PaidAmount     float64 `json:"paid_amount"`      // 累计充值本金
+	RefundedAmount float64 `json:"refunded_amount"`  // 累计退回本金
+	BonusAmount    float64 `json:"bonus_amount"`     // 累计赠送
+	ClawbackAmount float64 `json:"clawback_amount"`  // 累计收回赠送
+	NetPaidAmount  float64 `json:"net_paid_amount"`  // 实付净额
+	NetBonusAmount float64 `json:"net_bonus_amount"` // 赠送净额
+}
+
+// ListWalletRechargesResponse 客户充值记录及汇总
+type ListWalletRechargesResponse struct {
+	Recharges []*WalletRechargeResponse `json:"recharges"`
+	Summary   *RechargeSummaryResponse  `json:"summary"`
+}
+
+// RefundRechargeRequest 退回充值请求
+type RefundRechargeRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"` // 退回原因
+}
+
+// WalletTransferRequest 钱包转账请求，转出方为路径中的客户
+type WalletTransferRequest struct {
+	ToCustomerID int64   `json:"to_customer_id" binding:"required,gt=0" example:"2"` // 转入客户ID
+	Amount       float64 `json:"amount" binding:"required,gt=0" example:"50"`        // 转账金额（元）
+	Note         string  `json:"note" binding:"max=255"`                             // 备注
+}
+
+// WalletTransferResponse 钱包转账记录响应
+type WalletTransferResponse struct {
+	ID             int64   `json:"id"`
+	FromCustomerID int64   `json:"from_customer_id"`
+	ToCustomerID   int64   `json:"to_customer_id"`
+	Amount         float64 `json:"amount"`    // 转账金额（元）
+	OutTxID        int64   `json:"out_tx_id"` // 转出流水ID
+	InTxID         int64   `json:"in_tx_id"`  // 转入流水ID
+	OperatorID     int64   `json:"operator_id"`
+	Note           string  `json:"note"`
+	CreatedAt      string  `json:"created_at"`
+}
+
+// GrantWalletCreditRequest 发放有效期额度请求
+type GrantWalletCreditRequest struct {
+	Amount    float64   `json:"amount" binding:"required,gt=0" example:"20"` // 发放金额（元）
+	ExpiresAt time.Time `json:"expires_at" binding:"required"`               // 过期时间
+	Note      string    `json:"note" binding:"max=255" example:"服务补偿"`       // 备注
+}
+
+// WalletCreditLotResponse 有效期额度响应
+type WalletCreditLotResponse struct {
+	ID            int64     `json:"id"`
+	SourceTxID    int64     `json:"source_tx_id"`   // 入账流水ID
+	Amount        float64   `json:"amount"`         // 入账金额（元）
+	Remaining     float64   `json:"remaining"`      // 剩余未使用金额（元）
+	ExpiresAt     time.Time `json:"expires_at"`     // 过期时间
+	ExpiredAmount float64   `json:"expired_amount"` // 过期扣回金额（元）
+	Note          string    `json:"note"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ListExpiringCreditsRequest 查询即将过期额度请求
+type ListExpiringCreditsRequest struct {
+	Days int `form:"days" binding:"omitempty,min=1,max=365" example:"30"` // 查询未来多少天内到期的额度，默认30天
+}
+
+// WalletStatementRequest 导出钱包对账单请求
+type WalletStatementRequest struct {
+	StartDate time.Time `form:"start_date" binding:"required" time_format:"2006-01-02"` // 对账周期起（含）
+	EndDate   time.Time `form:"end_date" binding:"required" time_format:"2006-01-02"`   // 对账周期止（含）
+}
+
+// ExpiringCreditsResponse 即将过期的额度及合计
+type ExpiringCreditsResponse struct {
+	TotalRemaining float64                    `json:"total_remaining"` // 即将过期的剩余金额合计（元）
+	Credits        []*WalletCreditLotResponse `json:"credits"`
+}
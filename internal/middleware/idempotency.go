@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"crm_lite/internal/core/config"
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/core/resource"
+	"crm_lite/pkg/resp"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	// HeaderIdempotencyKey 客户端提交的幂等键
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// HeaderIdempotentReplayed 标记响应来自幂等回放
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLen = 128
+)
+
+// NewIdempotencyMiddleware 返回 HTTP 幂等中间件。
+// 按 idempotency.driver 选择存储：redis 可用时使用 Redis，否则回退到 idempotency_keys 表。
+// 必须在 JWTAuthMiddleware 之后调用，幂等键按操作人隔离。
+func NewIdempotencyMiddleware(resManager *resource.Manager) gin.HandlerFunc {
+	opts := config.GetInstance().Idempotency
+
+	var store IdempotencyStore
+	if opts.Driver != "db" {
+		if cacheRes, err := resource.Get[*resource.CacheResource](resManager, resource.CacheServiceKey); err == nil && cacheRes != nil && cacheRes.Client != nil {
+			store = NewRedisIdempotencyStore(cacheRes.Client)
+		}
+	}
+	if store == nil {
+		dbRes, err := resource.Get[*resource.DBResource](resManager, resource.DBServiceKey)
+		if err != nil || dbRes == nil || dbRes.DB == nil {
+			logger.Warn("Idempotency middleware disabled: no cache or database resource available")
+			return func(c *gin.Context) { c.Next() }
+		}
+		store = NewDBIdempotencyStore(dbRes.DB)
+	}
+
+	return IdempotencyMiddleware(store, opts.TTL, opts.LockTTL)
+}
+
+// IdempotencyMiddleware 对携带 Idempotency-Key 的写请求做幂等处理：
+//   - 首次请求正常执行，并保存请求指纹与原始响应
+//   - 相同键 + 相同查询参数与请求体的重试直接回放原始响应
+//   - 相同键 + 不同查询参数或请求体，或首个请求仍在处理中，返回 409
+//
+// 5xx 响应或处理中 panic 时不保存并释放键，客户端可以使用同一键重试。
+// ttl 为原始响应的保留时长；lockTTL 为处理中占用的时长，进程崩溃等未能释放时最多阻塞重试 lockTTL。
+func IdempotencyMiddleware(store IdempotencyStore, ttl, lockTTL time.Duration) gin.HandlerFunc {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if lockTTL <= 0 {
+		lockTTL = time.Minute
+	}
+
+	return func(c *gin.Context) {
+		idemKey := c.GetHeader(HeaderIdempotencyKey)
+		if idemKey == "" || !isMutatingMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+		if len(idemKey) > maxIdempotencyKeyLen {
+			resp.Error(c, resp.CodeInvalidParam, fmt.Sprintf("Idempotency-Key 长度不能超过 %d", maxIdempotencyKeyLen))
+			c.Abort()
+			return
+		}
+
+		// 1. 读取请求体计算指纹，并还原请求体供后续处理
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			resp.Error(c, resp.CodeInvalidParam, "读取请求体失败")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		storeKey := idempotencyStoreKey(c, idemKey)
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body)
+
+		// 2. 占用幂等键
+		existing, acquired, err := store.Acquire(ctx, storeKey, fingerprint, lockTTL)
+		if err != nil {
+			resp.SystemError(c, err)
+			c.Abort()
+			return
+		}
+		if !acquired {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				resp.Error(c, resp.CodeConflict, "幂等键已被用于不同的请求")
+			case existing.StatusCode == 0:
+				resp.Error(c, resp.CodeConflict, "相同幂等键的请求正在处理中，请稍后重试")
+			default:
+				c.Header(HeaderIdempotentReplayed, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		// 3. 执行请求并捕获响应
+		// 客户端断开不应影响幂等键的保存与释放
+		storeCtx := context.WithoutCancel(ctx)
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// 5xx 或 handler panic 时释放幂等键，panic 继续交给外层 Recovery 处理
+			r := recover()
+			if err := store.Release(storeCtx, storeKey); err != nil {
+				logger.Warn("Failed to release idempotency key", zap.String("key", storeKey), zap.Error(err))
+			}
+			if r != nil {
+				panic(r)
+			}
+		}()
+
+		writer := &idempotencyResponseWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+
+		record := &IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		}
+		if err := store.Complete(storeCtx, storeKey, record, ttl); err != nil {
+			logger.Warn("Failed to save idempotent response", zap.String("key", storeKey), zap.Error(err))
+		}
+	}
+}
+
+// idempotencyResponseWriter 在写出响应的同时保留一份响应体
+type idempotencyResponseWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *idempotencyResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyResponseWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyStoreKey 幂等键按操作人隔离，避免不同用户的键互相冲突
+func idempotencyStoreKey(c *gin.Context, idemKey string) string {
+	userID, _ := c.Get(ContextKeyUserID)
+	return fmt.Sprintf("idem:%v:%s", userID, idemKey)
+}
+
+// requestFingerprint 请求指纹：method + path + query + body 的 SHA-256
+// 查询参数同样决定请求语义，同一键换了查询参数视为不同的请求
+func requestFingerprint(method, path, rawQuery string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte(" "))
+	h.Write([]byte(path))
+	h.Write([]byte("?"))
+	h.Write([]byte(rawQuery))
+	h.Write([]byte("\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
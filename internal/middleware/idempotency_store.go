@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// IdempotencyRecord 幂等键对应的请求指纹与原始响应
+// StatusCode 为 0 表示首个请求仍在处理中
+type IdempotencyRecord struct {
+	Fingerprint string `json:"fingerprint"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// IdempotencyStore 幂等键存储
+type IdempotencyStore interface {
+	// Acquire 占用幂等键，ttl 为处理中的占用时长；键已存在时返回已有记录且 acquired=false
+	Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (existing *IdempotencyRecord, acquired bool, err error)
+	// Complete 保存原始响应，供后续重试回放，ttl 为响应保留时长
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release 释放幂等键，允许客户端使用同一键重试
+	Release(ctx context.Context, key string) error
+}
+
+// ==================== Redis 实现 ====================
+
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+// NewRedisIdempotencyStore 基于 Redis 的幂等键存储，过期由 key TTL 控制
+func NewRedisIdempotencyStore(client *redis.Client) IdempotencyStore {
+	return &redisIdempotencyStore{client: client}
+}
+
+func (s *redisIdempotencyStore) Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	pending, err := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// 键在 SetNX 与 Get 之间恰好过期时重试一次
+	for i := 0; i < 2; i++ {
+		ok, err := s.client.SetNX(ctx, key, pending, ttl).Result()
+		if err != nil {
+			return nil, false, fmt.Errorf("写入幂等键失败: %w", err)
+		}
+		if ok {
+			return nil, true, nil
+		}
+
+		raw, err := s.client.Get(ctx, key).Bytes()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("读取幂等键失败: %w", err)
+		}
+
+		var existing IdempotencyRecord
+		if err := json.Unmarshal(raw, &existing); err != nil {
+			return nil, false, fmt.Errorf("解析幂等记录失败: %w", err)
+		}
+		return &existing, false, nil
+	}
+	return nil, false, errors.New("占用幂等键失败")
+}
+
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, key, raw, ttl).Err()
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.client.Del(ctx, key).Err()
+}
+
+// ==================== 数据库实现 ====================
+
+// idempotencyKeyRecord 映射 idempotency_keys（HTTP 幂等键）
+type idempotencyKeyRecord struct {
+	ID           int64  `gorm:"column:id;primaryKey;autoIncrement"`
+	IdemKey      string `gorm:"column:idem_key;uniqueIndex:uk_idem_key;size:191;not null"`
+	Fingerprint  string `gorm:"column:fingerprint;size:64;not null"`
+	StatusCode   int    `gorm:"column:status_code;not null;default:0"`
+	ContentType  string `gorm:"column:content_type;size:100;not null;default:''"`
+	ResponseBody string `gorm:"column:response_body;type:mediumtext"`
+	ExpiresAt    int64  `gorm:"column:expires_at;index:idx_idem_expires;not null"`
+	CreatedAt    int64  `gorm:"column:created_at;not null"`
+}
+
+func (idempotencyKeyRecord) TableName() string { return "idempotency_keys" }
+
+// DBIdempotencyStore 基于 idempotency_keys 表的幂等键存储
+// 过期的键只在复用同名键时删除，其余由 PurgeExpired 定期清理
+type DBIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewDBIdempotencyStore 基于 idempotency_keys 表的幂等键存储，依赖唯一索引保证并发安全
+func NewDBIdempotencyStore(db *gorm.DB) *DBIdempotencyStore {
+	return &DBIdempotencyStore{db: db}
+}
+
+func (s *DBIdempotencyStore) Acquire(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	now := time.Now()
+
+	// 清理已过期的同名键，过期后允许复用
+	if err := s.db.WithContext(ctx).
+		Where("idem_key = ? AND expires_at < ?", key, now.Unix()).
+		Delete(&idempotencyKeyRecord{}).Error; err != nil {
+		return nil, false, fmt.Errorf("清理过期幂等键失败: %w", err)
+	}
+
+	record := &idempotencyKeyRecord{
+		IdemKey:     key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(ttl).Unix(),
+		CreatedAt:   now.Unix(),
+	}
+	createErr := s.db.WithContext(ctx).Create(record).Error
+	if createErr == nil {
+		return nil, true, nil
+	}
+
+	// 插入失败通常是唯一索引冲突，回查已有记录
+	var existing idempotencyKeyRecord
+	if err := s.db.WithContext(ctx).Where("idem_key = ?", key).First(&existing).Error; err != nil {
+		return nil, false, fmt.Errorf("写入幂等键失败: %w", createErr)
+	}
+	return &IdempotencyRecord{
+		Fingerprint: existing.Fingerprint,
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        []byte(existing.ResponseBody),
+	}, false, nil
+}
+
+func (s *DBIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	return s.db.WithContext(ctx).Model(&idempotencyKeyRecord{}).
+		Where("idem_key = ?", key).
+		Updates(map[string]interface{}{
+			"status_code":   record.StatusCode,
+			"content_type":  record.ContentType,
+			"response_body": string(record.Body),
+			"expires_at":    time.Now().Add(ttl).Unix(),
+		}).Error
+}
+
+func (s *DBIdempotencyStore) Release(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("idem_key = ?", key).Delete(&idempotencyKeyRecord{}).Error
+}
+
+// PurgeExpired 删除 before 之前过期的幂等键，单次最多删除 limit 条，返回删除数量
+func (s *DBIdempotencyStore) PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error) {
+	var ids []int64
+	if err := s.db.WithContext(ctx).Model(&idempotencyKeyRecord{}).
+		Where("expires_at < ?", before.Unix()).
+		Order("expires_at ASC").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("查询过期幂等键失败: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	// 按 expires_at 再次过滤，跳过查询后被重新占用的同名键
+	res := s.db.WithContext(ctx).Where("id IN ? AND expires_at < ?", ids, before.Unix()).Delete(&idempotencyKeyRecord{})
+	if res.Error != nil {
+		return 0, fmt.Errorf("清理过期幂等键失败: %w", res.Error)
+	}
+	return int(res.RowsAffected), nil
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"crm_lite/pkg/resp"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newIdempotencyTestRouter 构造一个统计调用次数的下单路由
+func newIdempotencyTestRouter(store IdempotencyStore, calls *int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.RecoveryWithWriter(io.Discard))
+	r.Use(func(c *gin.Context) {
+		c.Set(ContextKeyUserID, "user-1")
+		c.Next()
+	})
+	r.Use(IdempotencyMiddleware(store, time.Hour, time.Minute))
+	r.POST("/orders", func(c *gin.Context) {
+		*calls++
+		switch c.Query("fail") {
+		case "1":
+			resp.SystemError(c, assert.AnError)
+			return
+		case "panic":
+			panic("handler panic")
+		}
+		resp.Success(c, gin.H{"order_id": *calls})
+	})
+	return r
+}
+
+func doIdempotentRequest(r *gin.Engine, key, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisStore := NewRedisIdempotencyStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&idempotencyKeyRecord{}))
+	dbStore := NewDBIdempotencyStore(db)
+
+	stores := map[string]IdempotencyStore{"redis": redisStore, "db": dbStore}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			r := newIdempotencyTestRouter(store, &calls)
+
+			// 首次请求正常执行，重试回放原始响应
+			first := doIdempotentRequest(r, "key-1", "/orders", `{"amount":100}`)
+			require.Equal(t, http.StatusOK, first.Code)
+			retry := doIdempotentRequest(r, "key-1", "/orders", `{"amount":100}`)
+			assert.Equal(t, http.StatusOK, retry.Code)
+			assert.Equal(t, first.Body.String(), retry.Body.String())
+			assert.Equal(t, "true", retry.Header().Get(HeaderIdempotentReplayed))
+			assert.Equal(t, 1, calls, "重试不重复执行")
+
+			// 同一键不同请求体或不同查询参数返回 409
+			conflict := doIdempotentRequest(r, "key-1", "/orders", `{"amount":200}`)
+			assert.Equal(t, http.StatusConflict, conflict.Code)
+			conflict = doIdempotentRequest(r, "key-1", "/orders?dry_run=true", `{"amount":100}`)
+			assert.Equal(t, http.StatusConflict, conflict.Code)
+			assert.Equal(t, 1, calls)
+
+			// 5xx 不保存，允许使用同一键重试
+			failed := doIdempotentRequest(r, "key-2", "/orders?fail=1", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, failed.Code)
+			failed = doIdempotentRequest(r, "key-2", "/orders?fail=1", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, failed.Code)
+			assert.Equal(t, 3, calls)
+
+			// handler panic 同样释放幂等键
+			panicked := doIdempotentRequest(r, "key-3", "/orders?fail=panic", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, panicked.Code)
+			retried := doIdempotentRequest(r, "key-3", "/orders?fail=panic", `{}`)
+			assert.Equal(t, http.StatusInternalServerError, retried.Code, "重试不应返回 409")
+			assert.Equal(t, 5, calls)
+
+			// 不带幂等键的请求不受影响
+			doIdempotentRequest(r, "", "/orders", `{"amount":100}`)
+			doIdempotentRequest(r, "", "/orders", `{"amount":100}`)
+			assert.Equal(t, 7, calls)
+		})
+	}
+
+	t.Run("处理中的请求返回409", func(t *testing.T) {
+		calls := 0
+		r := newIdempotencyTestRouter(redisStore, &calls)
+		_, acquired, err := redisStore.Acquire(t.Context(), "idem:user-1:key-pending", requestFingerprint(http.MethodPost, "/orders", "", []byte(`{}`)), time.Minute)
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		w := doIdempotentRequest(r, "key-pending", "/orders", `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Equal(t, 0, calls)
+
+		// 未能释放的占用（如进程崩溃）在 lockTTL 后失效，而不是保留完整的响应保留时长
+		mr.FastForward(2 * time.Minute)
+		w = doIdempotentRequest(r, "key-pending", "/orders", `{}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("键过期后可复用", func(t *testing.T) {
+		calls := 0
+		r := newIdempotencyTestRouter(redisStore, &calls)
+		doIdempotentRequest(r, "key-ttl", "/orders", `{"amount":1}`)
+		mr.FastForward(2 * time.Hour)
+		w := doIdempotentRequest(r, "key-ttl", "/orders", `{"amount":2}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("定期清理数据库中已过期的键", func(t *testing.T) {
+		ctx := t.Context()
+		_, _, err := dbStore.Acquire(ctx, "idem:user-1:key-stale", "fp", -time.Minute)
+		require.NoError(t, err)
+		_, _, err = dbStore.Acquire(ctx, "idem:user-1:key-live", "fp", time.Hour)
+		require.NoError(t, err)
+
+		purged, err := dbStore.PurgeExpired(ctx, time.Now(), 100)
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		var keys []string
+		require.NoError(t, db.Model(&idempotencyKeyRecord{}).Where("idem_key LIKE ?", "idem:user-1:key-%").Order("idem_key").Pluck("idem_key", &keys).Error)
+		assert.NotContains(t, keys, "idem:user-1:key-stale")
+		assert.Contains(t, keys, "idem:user-1:key-live", "未过期的键保留")
+	})
+}
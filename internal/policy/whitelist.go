@@ -16,5 +16,8 @@ func GetPublicRoutes() []PublicRoute {
 		{Method: "POST", Path: "/api/v1/auth/refresh"},
 		{Method: "POST", Path: "/api/v1/auth/forgot-password"},
 		{Method: "POST", Path: "/api/v1/auth/reset-password"},
+
+		// 支付渠道回调，以渠道签名鉴权；接入新渠道时需在此登记其回调地址
+		{Method: "POST", Path: "/api/v1/payments/webhooks/mock"},
 	}
 }
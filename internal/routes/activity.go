@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterActivityRoutes 注册客户活动与跟进任务路由
+func RegisterActivityRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	activityController := controller.NewActivityController(res)
+
+	// 客户维度的活动，与客户接口使用同一访问中间件
+	customerActivities := r.Group("/customers/:id").Use(middleware.NewSimpleCustomerAccessMiddleware(res))
+	{
+		customerActivities.GET("/activities", activityController.ListCustomerActivities) // 客户活动列表
+		customerActivities.POST("/activities", activityController.CreateActivity)        // 创建活动
+	}
+
+	// 单个活动按其所属客户校验访问权限
+	activities := r.Group("/activities")
+	{
+		activities.GET("/my-tasks", activityController.ListMyOpenTasks)       // 我的待办
+		activities.GET("/overdue", activityController.ListOverdueActivities)  // 逾期任务
+		activities.GET("/:id", activityController.GetActivity)                // 活动详情
+		activities.PUT("/:id", activityController.UpdateActivity)             // 更新活动
+		activities.DELETE("/:id", activityController.DeleteActivity)          // 删除活动
+		activities.POST("/:id/complete", activityController.CompleteActivity) // 完成活动
+	}
+}
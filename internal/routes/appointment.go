@@ -0,0 +1,35 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterAppointmentRoutes 注册员工排班与预约路由
+func RegisterAppointmentRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	appointmentController := controller.NewAppointmentController(res)
+
+	// 员工排班路由
+	staff := r.Group("/staff/:id")
+	{
+		staff.GET("/availability", appointmentController.GetStaffAvailability)          // 获取每周可预约时间
+		staff.PUT("/availability", appointmentController.SetStaffAvailability)          // 设置每周可预约时间
+		staff.GET("/time-offs", appointmentController.ListStaffTimeOffs)                // 查询休息时段
+		staff.POST("/time-offs", appointmentController.AddStaffTimeOff)                 // 新增休息时段
+		staff.DELETE("/time-offs/:timeOffId", appointmentController.DeleteStaffTimeOff) // 删除休息时段
+	}
+
+	// 预约路由
+	appointments := r.Group("/appointments")
+	{
+		appointments.GET("/slots", appointmentController.ListAvailableSlots)              // 查询可预约时段
+		appointments.POST("", appointmentController.BookAppointment)                      // 预约
+		appointments.GET("", appointmentController.ListAppointments)                      // 预约列表
+		appointments.GET("/:id", appointmentController.GetAppointment)                    // 预约详情
+		appointments.POST("/:id/reschedule", appointmentController.RescheduleAppointment) // 改约
+		appointments.POST("/:id/cancel", appointmentController.CancelAppointment)         // 取消预约
+		appointments.POST("/:id/complete", appointmentController.CompleteAppointment)     // 完成预约并生成订单
+	}
+}
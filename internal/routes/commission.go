@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCommissionRoutes 注册员工提成路由
+func RegisterCommissionRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	commissionController := controller.NewCommissionController(res)
+
+	// 提成规则路由
+	rules := r.Group("/commission-rules")
+	{
+		rules.POST("", commissionController.CreateRule)       // 创建提成规则
+		rules.GET("", commissionController.ListRules)         // 查询提成规则
+		rules.GET("/:id", commissionController.GetRule)       // 获取提成规则
+		rules.PUT("/:id", commissionController.UpdateRule)    // 更新提成规则
+		rules.DELETE("/:id", commissionController.DeleteRule) // 删除提成规则
+	}
+
+	// 员工提成对账单路由
+	r.GET("/staff/:id/commissions", commissionController.GetStaffStatement)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCustomerFieldRoutes 注册客户自定义字段定义路由
+func RegisterCustomerFieldRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	fieldController := controller.NewCustomerFieldController(res)
+
+	fields := r.Group("/customer-fields")
+	{
+		fields.GET("", fieldController.ListFields)         // 字段列表
+		fields.POST("", fieldController.CreateField)       // 创建字段
+		fields.GET("/:id", fieldController.GetField)       // 字段详情
+		fields.PUT("/:id", fieldController.UpdateField)    // 更新字段
+		fields.DELETE("/:id", fieldController.DeleteField) // 删除字段
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCustomerImportRoutes 注册客户批量导入路由
+func RegisterCustomerImportRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	importController := controller.NewCustomerImportController(res)
+
+	// 导入更新已存在客户时在控制器中逐个校验客户访问权限
+	r.POST("/customers/import", importController.ImportCustomers)
+}
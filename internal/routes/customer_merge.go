@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCustomerMergeRoutes 注册重复客户识别与合并路由
+func RegisterCustomerMergeRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	mergeController := controller.NewCustomerMergeController(res)
+
+	// 路径中的客户为保留客户，与客户接口使用同一访问中间件；被合并客户在控制器中校验
+	customerMerges := r.Group("/customers/:id").Use(middleware.NewSimpleCustomerAccessMiddleware(res))
+	{
+		customerMerges.GET("/duplicates", mergeController.FindDuplicates) // 疑似重复客户
+		customerMerges.POST("/merge", mergeController.MergeCustomer)      // 合并客户
+		customerMerges.GET("/merges", mergeController.ListMerges)         // 合并记录
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterCustomerTagRoutes 注册客户标签字典与批量打标签路由
+func RegisterCustomerTagRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	tagController := controller.NewCustomerTagController(res)
+
+	tags := r.Group("/customer-tags")
+	{
+		tags.GET("", tagController.ListTags)               // 标签字典
+		tags.POST("", tagController.CreateTag)             // 创建标签
+		tags.GET("/usage", tagController.ListTagUsage)     // 标签使用统计
+		tags.POST("/bulk", tagController.BulkTagCustomers) // 批量打标签
+		tags.PUT("/:id", tagController.UpdateTag)          // 更新标签
+		tags.DELETE("/:id", tagController.DeleteTag)       // 删除标签
+		tags.POST("/:id/merge", tagController.MergeTags)   // 合并标签
+	}
+}
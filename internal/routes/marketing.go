@@ -26,6 +26,15 @@ func RegisterMarketingRoutes(r *gin.RouterGroup, res *resource.Manager) {
 			campaigns.GET("/:id/stats", marketingController.GetCampaignStats)   // 获取营销活动统计
 		}
 
+		// 优惠券管理路由
+		coupons := marketing.Group("/coupons")
+		{
+			coupons.POST("", marketingController.CreateCoupon)                  // 创建优惠券
+			coupons.GET("", marketingController.ListCoupons)                    // 获取优惠券列表
+			coupons.GET("/:id", marketingController.GetCoupon)                  // 获取优惠券详情
+			coupons.POST("/:id/codes", marketingController.GenerateCouponCodes) // 批量生成券码
+		}
+
 		// 营销记录管理路由
 		marketing.GET("/records", marketingController.ListMarketingRecords) // 获取营销记录列表
 
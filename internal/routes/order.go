@@ -16,6 +16,12 @@ func RegisterOrderRoutes(rg *gin.RouterGroup, rm *resource.Manager) {
 		orders.POST("", orderController.CreateOrder)
 		orders.GET("", orderController.ListOrders)
 		orders.GET("/:id", orderController.GetOrder)
+		orders.PATCH("/:id/status", orderController.UpdateOrderStatus)
+		orders.GET("/:id/status-logs", orderController.GetOrderStatusHistory)
+		orders.POST("/:id/refunds", orderController.RefundOrderItems)
+		orders.GET("/:id/refunds", orderController.ListOrderRefunds)
+		orders.POST("/:id/payments", orderController.AddOrderPayments)
+		orders.GET("/:id/payments", orderController.ListOrderPayments)
 
 	}
 }
@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterPackageRoutes 注册次卡套餐路由
+func RegisterPackageRoutes(r *gin.RouterGroup, res *resource.Manager) {
+	packageController := controller.NewPackageController(res)
+
+	// 套餐配置路由
+	plans := r.Group("/packages/plans")
+	{
+		plans.GET("/:productId", packageController.GetPackagePlan) // 获取套餐配置
+		plans.PUT("/:productId", packageController.SetPackagePlan) // 设置套餐配置
+	}
+
+	// 客户套餐路由
+	customerPackages := r.Group("/customers/:id").Use(middleware.NewSimpleCustomerAccessMiddleware(res))
+	{
+		customerPackages.GET("/packages", packageController.ListCustomerPackages) // 查询客户套餐
+	}
+	packages := r.Group("/customer-packages")
+	{
+		packages.GET("/:id", packageController.GetCustomerPackage)                   // 客户套餐详情
+		packages.GET("/:id/transactions", packageController.ListPackageTransactions) // 套餐使用记录
+	}
+}
@@ -0,0 +1,27 @@
+package routes
+
+import (
+	"crm_lite/internal/controller"
+	"crm_lite/internal/core/resource"
+	"crm_lite/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterPaymentRoutes(rg *gin.RouterGroup, rm *resource.Manager) {
+	paymentController := controller.NewPaymentController(rm)
+
+	orders := rg.Group("/orders").Use(middleware.NewSimpleCustomerAccessMiddleware(rm))
+	{
+		orders.POST("/:id/payment-intents", paymentController.CreatePaymentIntent)
+		orders.GET("/:id/payment-intents", paymentController.ListPaymentIntents)
+	}
+
+	payments := rg.Group("/payments")
+	{
+		// 渠道回调为公开路由（见 policy.GetPublicRoutes），以渠道签名鉴权
+		payments.POST("/webhooks/:provider", paymentController.HandleWebhook)
+		payments.GET("/intents/stuck", paymentController.ListStuckPaymentIntents)
+		payments.POST("/intents/:id/sync", paymentController.SyncPaymentIntent)
+	}
+}
@@ -26,7 +26,7 @@ func NewRouter(resManager *resource.Manager, logCleaner *scheduler.LogCleaner) *
 
 	// 3. 创建 /api/v1 路由组并应用安全中间件
 	apiV1 := router.Group("/api/v1")
-	apiV1.Use(middleware.NewJWTAuthMiddleware(resManager), middleware.NewCasbinMiddleware(resManager))
+	apiV1.Use(middleware.NewJWTAuthMiddleware(resManager), middleware.NewCasbinMiddleware(resManager), middleware.NewIdempotencyMiddleware(resManager))
 	{
 		// 所有 v1 路由都在这里注册。
 		// 中间件内部的白名单会负责放行登录、注册等公开路由。
@@ -35,11 +35,20 @@ func NewRouter(resManager *resource.Manager, logCleaner *scheduler.LogCleaner) *
 		registerRoleRoutes(apiV1, resManager)
 		registerPermissionRoutes(apiV1, resManager)
 		registerCustomerRoutes(apiV1, resManager)
+		RegisterCustomerMergeRoutes(apiV1, resManager)
+		RegisterCustomerImportRoutes(apiV1, resManager)
+		RegisterCustomerFieldRoutes(apiV1, resManager)
+		RegisterCustomerTagRoutes(apiV1, resManager)
 		RegisterContactRoutes(apiV1, resManager)
+		RegisterActivityRoutes(apiV1, resManager)
 		registerProductRoutes(apiV1, resManager)
 		RegisterOrderRoutes(apiV1, resManager) // 启用订单路由
+		RegisterPaymentRoutes(apiV1, resManager)
 		RegisterWalletRoutes(apiV1, resManager)
 		RegisterMarketingRoutes(apiV1, resManager)
+		RegisterAppointmentRoutes(apiV1, resManager)
+		RegisterPackageRoutes(apiV1, resManager)
+		RegisterCommissionRoutes(apiV1, resManager)
 		RegisterDashboardRoutes(apiV1, resManager)
 
 		// 维护相关路由
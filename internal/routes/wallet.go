@@ -21,7 +21,38 @@ func RegisterWalletRoutes(router *gin.RouterGroup, resourceManager *resource.Man
 		walletRoutes.POST("/wallet/transactions", walletCtl.CreateTransaction)
 		// GET /v1/customers/:id/wallet/transactions
 		walletRoutes.GET("/wallet/transactions", walletCtl.GetTransactions)
+		// POST /v1/customers/:id/wallet/transactions/:txId/reverse
+		walletRoutes.POST("/wallet/transactions/:txId/reverse", walletCtl.ReverseTransaction)
 		// POST /v1/customers/:id/wallet/refund
 		walletRoutes.POST("/wallet/refund", walletCtl.ProcessRefund)
+		// POST /v1/customers/:id/wallet/freeze
+		walletRoutes.POST("/wallet/freeze", walletCtl.FreezeWallet)
+		// POST /v1/customers/:id/wallet/unfreeze
+		walletRoutes.POST("/wallet/unfreeze", walletCtl.UnfreezeWallet)
+		// GET /v1/customers/:id/wallet/status-logs
+		walletRoutes.GET("/wallet/status-logs", walletCtl.ListStatusLogs)
+		// GET /v1/customers/:id/wallet/recharges
+		walletRoutes.GET("/wallet/recharges", walletCtl.ListRecharges)
+		// POST /v1/customers/:id/wallet/recharges/:rechargeId/refund
+		walletRoutes.POST("/wallet/recharges/:rechargeId/refund", walletCtl.RefundRecharge)
+		// POST /v1/customers/:id/wallet/transfers
+		walletRoutes.POST("/wallet/transfers", walletCtl.Transfer)
+		// GET /v1/customers/:id/wallet/transfers
+		walletRoutes.GET("/wallet/transfers", walletCtl.ListTransfers)
+		// POST /v1/customers/:id/wallet/credits
+		walletRoutes.POST("/wallet/credits", walletCtl.GrantCredit)
+		// GET /v1/customers/:id/wallet/expiring-credits
+		walletRoutes.GET("/wallet/expiring-credits", walletCtl.ListExpiringCredits)
+		// GET /v1/customers/:id/wallet/statement
+		walletRoutes.GET("/wallet/statement", walletCtl.ExportStatement)
+	}
+
+	// 充值赠送规则
+	bonusRuleRoutes := router.Group("/recharge-bonus-rules")
+	{
+		bonusRuleRoutes.POST("", walletCtl.CreateBonusRule)
+		bonusRuleRoutes.GET("", walletCtl.ListBonusRules)
+		bonusRuleRoutes.PUT("/:id", walletCtl.UpdateBonusRule)
+		bonusRuleRoutes.DELETE("/:id", walletCtl.DeleteBonusRule)
 	}
 }
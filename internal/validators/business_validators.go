@@ -21,6 +21,9 @@ func RegisterCustomValidators() {
 		// Order related validators
 		_ = v.RegisterValidation("order_create_status", validateOrderCreateStatus)
 		_ = v.RegisterValidation("order_update_status", validateOrderUpdateStatus)
+		_ = v.RegisterValidation("payment_method", validatePaymentMethod)
+		_ = v.RegisterValidation("order_payment_method", validateOrderPaymentMethod)
+		_ = v.RegisterValidation("payment_status", validatePaymentStatus)
 
 		// Marketing related validators
 		_ = v.RegisterValidation("marketing_channel", validateMarketingChannel)
@@ -30,6 +33,7 @@ func RegisterCustomValidators() {
 
 		// Wallet related validators
 		_ = v.RegisterValidation("wallet_transaction_type", validateWalletTransactionType)
+		_ = v.RegisterValidation("wallet_reversal_reason", validateWalletReversalReason)
 
 		// Dashboard related validators
 		_ = v.RegisterValidation("date_range", validateDateRange)
@@ -85,6 +89,33 @@ func validateOrderUpdateStatus(fl validator.FieldLevel) bool {
 	return contains(constants.ValidOrderUpdateStatuses(), value)
 }
 
+// validatePaymentMethod validates order payment line methods
+func validatePaymentMethod(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // Allow empty values for optional fields
+	}
+	return contains(constants.ValidPaymentMethods(), value)
+}
+
+// validateOrderPaymentMethod validates order-level payment methods
+func validateOrderPaymentMethod(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // Allow empty values for optional fields
+	}
+	return contains(constants.ValidOrderPaymentMethods(), value)
+}
+
+// validatePaymentStatus validates order payment status values
+func validatePaymentStatus(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	if value == "" {
+		return true // Allow empty values for optional fields
+	}
+	return contains(constants.ValidPaymentStatuses(), value)
+}
+
 // validateMarketingChannel validates marketing channel type values
 func validateMarketingChannel(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
@@ -127,6 +158,12 @@ func validateWalletTransactionType(fl validator.FieldLevel) bool {
 	return contains(constants.ValidWalletTransactionTypes(), value)
 }
 
+// validateWalletReversalReason validates wallet reversal reason codes
+func validateWalletReversalReason(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	return contains(constants.ValidWalletReversalReasons(), value)
+}
+
 // validateDateRange validates date range values
 func validateDateRange(fl validator.FieldLevel) bool {
 	value := fl.Field().String()
@@ -190,6 +227,8 @@ func GetValidationErrorMessage(fieldError validator.FieldError) string {
 		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(constants.ValidMarketingExecutionTypes(), ", "))
 	case "wallet_transaction_type":
 		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(constants.ValidWalletTransactionTypes(), ", "))
+	case "wallet_reversal_reason":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(constants.ValidWalletReversalReasons(), ", "))
 	case "date_range":
 		return fmt.Sprintf("%s must be one of: %s", field, strings.Join(constants.ValidDateRanges(), ", "))
 	case "granularity":
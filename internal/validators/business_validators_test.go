@@ -91,6 +91,28 @@ func TestCustomValidators(t *testing.T) {
 		assert.Contains(t, response["error"].(string), "order_create_status")
 	})
 
+	t.Run("InvalidPaymentMethod", func(t *testing.T) {
+		body := `{
+			"customer_id": 1,
+			"order_date": "2024-01-01T10:00:00Z",
+			"items": [{"product_id": 1, "quantity": 1, "unit_price": 100.0}],
+			"payments": [{"method": "wallet", "amount": 60.0}, {"method": "bitcoin", "amount": 40.0}]
+		}`
+
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Contains(t, response["error"].(string), "payment_method")
+	})
+
 	t.Run("ValidWalletTransactionType", func(t *testing.T) {
 		body := `{
 			"amount": 100.0,
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/core/logger"
+
+	"go.uber.org/zap"
+)
+
+// ExpiredIdempotencyKeyPurger 过期幂等键清理能力，由数据库幂等键存储实现
+type ExpiredIdempotencyKeyPurger interface {
+	PurgeExpired(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// IdempotencyPurgeConfig 过期幂等键清理配置
+type IdempotencyPurgeConfig struct {
+	Interval  time.Duration // 清理间隔，0 表示不启用
+	BatchSize int           // 单批删除的键数
+}
+
+// IdempotencyPurgeJob 过期幂等键定时清理任务
+// 数据库存储的幂等键只在复用同名键时删除，不清理时 idempotency_keys 表会持续增长；Redis 存储由 key TTL 过期
+type IdempotencyPurgeJob struct {
+	config    *IdempotencyPurgeConfig
+	purger    ExpiredIdempotencyKeyPurger
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *logger.Logger
+	isRunning bool
+}
+
+// NewIdempotencyPurgeJob 创建过期幂等键清理任务
+func NewIdempotencyPurgeJob(config *IdempotencyPurgeConfig, purger ExpiredIdempotencyKeyPurger) *IdempotencyPurgeJob {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// 设置默认值
+	if config.BatchSize == 0 {
+		config.BatchSize = 1000
+	}
+
+	return &IdempotencyPurgeJob{
+		config: config,
+		purger: purger,
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger.GetGlobalLogger(),
+	}
+}
+
+// Start 启动定时清理
+func (pj *IdempotencyPurgeJob) Start() error {
+	if pj.isRunning {
+		return fmt.Errorf("幂等键清理任务已在运行中")
+	}
+	if pj.config.Interval <= 0 {
+		pj.logger.Info("未配置清理间隔，过期幂等键定时清理未启用")
+		return nil
+	}
+
+	pj.isRunning = true
+	pj.logger.Info("启动过期幂等键清理任务", zap.Duration("间隔", pj.config.Interval))
+
+	ticker := time.NewTicker(pj.config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pj.ctx.Done():
+				pj.logger.Info("幂等键清理任务收到停止信号")
+				return
+			case <-ticker.C:
+				if _, err := pj.RunOnce(pj.ctx); err != nil {
+					pj.logger.Error("过期幂等键清理失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止定时清理
+func (pj *IdempotencyPurgeJob) Stop() {
+	if pj.cancel != nil {
+		pj.cancel()
+	}
+	pj.isRunning = false
+	pj.logger.Info("幂等键清理任务已停止")
+}
+
+// RunOnce 执行一次清理（可被外部调度器调用），分批删除全部已过期的键，返回删除数量
+func (pj *IdempotencyPurgeJob) RunOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	total := 0
+	for {
+		purged, err := pj.purger.PurgeExpired(ctx, now, pj.config.BatchSize)
+		total += purged
+		if err != nil {
+			return total, err
+		}
+		if purged < pj.config.BatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		pj.logger.Info("已清理过期幂等键", zap.Int("数量", total))
+	}
+	return total, nil
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/core/logger"
+
+	"go.uber.org/zap"
+)
+
+// ExpiredOrderCanceller 超时订单取消能力，由 sales 域服务实现
+type ExpiredOrderCanceller interface {
+	CancelExpiredOrders(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+// OrderAutoCancelConfig 超时订单自动取消配置
+type OrderAutoCancelConfig struct {
+	PaymentTimeout time.Duration // 支付超时时间，0 表示不启用
+	Interval       time.Duration // 扫描间隔
+	BatchSize      int           // 单次最多取消的订单数
+}
+
+// OrderAutoCanceller 超时未支付订单自动取消任务
+type OrderAutoCanceller struct {
+	config    *OrderAutoCancelConfig
+	canceller ExpiredOrderCanceller
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *logger.Logger
+	isRunning bool
+}
+
+// NewOrderAutoCanceller 创建超时订单自动取消任务
+func NewOrderAutoCanceller(config *OrderAutoCancelConfig, canceller ExpiredOrderCanceller) *OrderAutoCanceller {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// 设置默认值
+	if config.Interval == 0 {
+		config.Interval = time.Minute // 默认每分钟扫描一次
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 100
+	}
+
+	return &OrderAutoCanceller{
+		config:    config,
+		canceller: canceller,
+		ctx:       ctx,
+		cancel:    cancel,
+		logger:    logger.GetGlobalLogger(),
+	}
+}
+
+// Start 启动超时订单扫描
+func (oc *OrderAutoCanceller) Start() error {
+	if oc.isRunning {
+		return fmt.Errorf("超时订单取消任务已在运行中")
+	}
+	if oc.config.PaymentTimeout <= 0 {
+		oc.logger.Info("未配置支付超时时间，超时订单自动取消未启用")
+		return nil
+	}
+
+	oc.isRunning = true
+	oc.logger.Info("启动超时订单自动取消任务",
+		zap.Duration("支付超时", oc.config.PaymentTimeout),
+		zap.Duration("间隔", oc.config.Interval))
+
+	ticker := time.NewTicker(oc.config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-oc.ctx.Done():
+				oc.logger.Info("超时订单取消任务收到停止信号")
+				return
+			case <-ticker.C:
+				if _, err := oc.RunOnce(oc.ctx); err != nil {
+					oc.logger.Error("超时订单自动取消失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止超时订单扫描
+func (oc *OrderAutoCanceller) Stop() {
+	if oc.cancel != nil {
+		oc.cancel()
+	}
+	oc.isRunning = false
+	oc.logger.Info("超时订单取消任务已停止")
+}
+
+// RunOnce 执行一次扫描（可被外部调度器调用），返回取消的订单数
+func (oc *OrderAutoCanceller) RunOnce(ctx context.Context) (int, error) {
+	before := time.Now().Add(-oc.config.PaymentTimeout)
+	cancelled, err := oc.canceller.CancelExpiredOrders(ctx, before, oc.config.BatchSize)
+	if cancelled > 0 {
+		oc.logger.Info("已取消超时未支付订单", zap.Int("数量", cancelled))
+	}
+	return cancelled, err
+}
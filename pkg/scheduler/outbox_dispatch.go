@@ -0,0 +1,96 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/core/logger"
+
+	"go.uber.org/zap"
+)
+
+// PendingEventProcessor 待处理 outbox 事件的投递能力，由 common.OutboxService 实现
+type PendingEventProcessor interface {
+	ProcessPendingEvents(ctx context.Context, limit int) error
+}
+
+// OutboxDispatchConfig outbox 事件投递配置
+type OutboxDispatchConfig struct {
+	Interval  time.Duration // 扫描间隔
+	BatchSize int           // 单次最多投递的事件数
+}
+
+// OutboxDispatcher outbox 事件定时投递任务
+// 将业务事务中写入的事件交给已订阅的处理函数（如员工提成计提）
+type OutboxDispatcher struct {
+	config    *OutboxDispatchConfig
+	processor PendingEventProcessor
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *logger.Logger
+	isRunning bool
+}
+
+// NewOutboxDispatcher 创建 outbox 事件投递任务
+func NewOutboxDispatcher(config *OutboxDispatchConfig, processor PendingEventProcessor) *OutboxDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// 设置默认值
+	if config.Interval == 0 {
+		config.Interval = 10 * time.Second
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = 100
+	}
+
+	return &OutboxDispatcher{
+		config:    config,
+		processor: processor,
+		ctx:       ctx,
+		cancel:    cancel,
+		logger:    logger.GetGlobalLogger(),
+	}
+}
+
+// Start 启动事件投递
+func (od *OutboxDispatcher) Start() error {
+	if od.isRunning {
+		return fmt.Errorf("outbox 事件投递任务已在运行中")
+	}
+
+	od.isRunning = true
+	od.logger.Info("启动 outbox 事件投递任务", zap.Duration("间隔", od.config.Interval))
+
+	ticker := time.NewTicker(od.config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-od.ctx.Done():
+				od.logger.Info("outbox 事件投递任务收到停止信号")
+				return
+			case <-ticker.C:
+				if err := od.RunOnce(od.ctx); err != nil {
+					od.logger.Error("outbox 事件投递失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止事件投递
+func (od *OutboxDispatcher) Stop() {
+	if od.cancel != nil {
+		od.cancel()
+	}
+	od.isRunning = false
+	od.logger.Info("outbox 事件投递任务已停止")
+}
+
+// RunOnce 执行一次投递（可被外部调度器调用）
+func (od *OutboxDispatcher) RunOnce(ctx context.Context) error {
+	return od.processor.ProcessPendingEvents(ctx, od.config.BatchSize)
+}
@@ -0,0 +1,94 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/domains/billing"
+
+	"go.uber.org/zap"
+)
+
+// WalletCreditExpiryConfig 有效期额度过期处理配置
+type WalletCreditExpiryConfig struct {
+	Interval time.Duration // 处理间隔，0 表示不启用定时处理
+}
+
+// WalletCreditExpiryJob 有效期额度定时过期任务
+// 把已到期额度的剩余金额以 adjust_out 流水扣回
+type WalletCreditExpiryJob struct {
+	config    *WalletCreditExpiryConfig
+	expirer   billing.CreditExpiryService
+	ctx       context.Context
+	cancel    context.CancelFunc
+	logger    *logger.Logger
+	isRunning bool
+}
+
+// NewWalletCreditExpiryJob 创建有效期额度过期任务
+func NewWalletCreditExpiryJob(config *WalletCreditExpiryConfig, expirer billing.CreditExpiryService) *WalletCreditExpiryJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WalletCreditExpiryJob{
+		config:  config,
+		expirer: expirer,
+		ctx:     ctx,
+		cancel:  cancel,
+		logger:  logger.GetGlobalLogger(),
+	}
+}
+
+// Start 启动定时过期处理
+func (ej *WalletCreditExpiryJob) Start() error {
+	if ej.isRunning {
+		return fmt.Errorf("有效期额度过期任务已在运行中")
+	}
+	if ej.config.Interval <= 0 {
+		ej.logger.Info("未配置处理间隔，有效期额度定时过期未启用")
+		return nil
+	}
+
+	ej.isRunning = true
+	ej.logger.Info("启动有效期额度过期任务", zap.Duration("间隔", ej.config.Interval))
+
+	ticker := time.NewTicker(ej.config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ej.ctx.Done():
+				ej.logger.Info("有效期额度过期任务收到停止信号")
+				return
+			case <-ticker.C:
+				if _, err := ej.RunOnce(ej.ctx); err != nil {
+					ej.logger.Error("有效期额度过期处理失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止定时过期处理
+func (ej *WalletCreditExpiryJob) Stop() {
+	if ej.cancel != nil {
+		ej.cancel()
+	}
+	ej.isRunning = false
+	ej.logger.Info("有效期额度过期任务已停止")
+}
+
+// RunOnce 执行一次过期处理（可被外部调度器调用），返回处理报告
+func (ej *WalletCreditExpiryJob) RunOnce(ctx context.Context) (*billing.ExpireCreditsReport, error) {
+	report, err := ej.expirer.ExpireCredits(ctx, billing.ExpireCreditsOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ej.logger.Info("有效期额度过期处理完成",
+		zap.Int("钱包数", report.WalletsScanned),
+		zap.Int("过期额度数", report.LotsExpired),
+		zap.Int64("扣回金额", report.AmountExpired))
+	return report, nil
+}
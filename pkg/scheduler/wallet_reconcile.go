@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"crm_lite/internal/core/logger"
+	"crm_lite/internal/domains/billing"
+
+	"go.uber.org/zap"
+)
+
+// WalletReconcileConfig 钱包余额对账配置
+type WalletReconcileConfig struct {
+	Interval time.Duration // 对账间隔，0 表示不启用定时对账
+	Repair   bool          // 是否自动修复偏差
+}
+
+// WalletReconcileJob 钱包余额定时对账任务
+// 按流水重新汇总 wallets 与 bil_wallets 的余额，偏差逐条记录告警日志
+type WalletReconcileJob struct {
+	config     *WalletReconcileConfig
+	reconciler billing.Reconciler
+	ctx        context.Context
+	cancel     context.CancelFunc
+	logger     *logger.Logger
+	isRunning  bool
+}
+
+// NewWalletReconcileJob 创建钱包余额对账任务
+func NewWalletReconcileJob(config *WalletReconcileConfig, reconciler billing.Reconciler) *WalletReconcileJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WalletReconcileJob{
+		config:     config,
+		reconciler: reconciler,
+		ctx:        ctx,
+		cancel:     cancel,
+		logger:     logger.GetGlobalLogger(),
+	}
+}
+
+// Start 启动定时对账
+func (wj *WalletReconcileJob) Start() error {
+	if wj.isRunning {
+		return fmt.Errorf("钱包余额对账任务已在运行中")
+	}
+	if wj.config.Interval <= 0 {
+		wj.logger.Info("未配置对账间隔，钱包余额定时对账未启用")
+		return nil
+	}
+
+	wj.isRunning = true
+	wj.logger.Info("启动钱包余额对账任务",
+		zap.Duration("间隔", wj.config.Interval),
+		zap.Bool("自动修复", wj.config.Repair))
+
+	ticker := time.NewTicker(wj.config.Interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wj.ctx.Done():
+				wj.logger.Info("钱包余额对账任务收到停止信号")
+				return
+			case <-ticker.C:
+				if _, err := wj.RunOnce(wj.ctx); err != nil {
+					wj.logger.Error("钱包余额对账失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止定时对账
+func (wj *WalletReconcileJob) Stop() {
+	if wj.cancel != nil {
+		wj.cancel()
+	}
+	wj.isRunning = false
+	wj.logger.Info("钱包余额对账任务已停止")
+}
+
+// RunOnce 执行一次对账（可被外部调度器调用），返回对账报告
+func (wj *WalletReconcileJob) RunOnce(ctx context.Context) (*billing.ReconcileReport, error) {
+	report, err := wj.reconciler.Reconcile(ctx, billing.ReconcileOptions{Repair: wj.config.Repair})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range report.Drifts {
+		wj.logger.Warn("钱包余额与流水不一致",
+			zap.String("数据源", d.Source),
+			zap.Int64("钱包ID", d.WalletID),
+			zap.Int64("客户ID", d.CustomerID),
+			zap.Int64("钱包余额", d.StoredBalance),
+			zap.Int64("流水汇总", d.ComputedBalance),
+			zap.Int64("最后流水ID", d.LastTxID),
+			zap.Bool("已修复", d.Repaired))
+	}
+	wj.logger.Info("钱包余额对账完成",
+		zap.Strings("数据源", report.Sources),
+		zap.Int("检查钱包数", report.WalletsChecked),
+		zap.Int("偏差数", len(report.Drifts)),
+		zap.Int("修复数", report.Repaired))
+	return report, nil
+}